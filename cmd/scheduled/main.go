@@ -2,15 +2,26 @@
 package main
 
 import (
+	"fmt"
 	"github.com/axetroy/go-server/cmd/scheduled/migrate"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/transfer"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/reconciliation"
+	"github.com/axetroy/go-server/internal/service/token_key"
 	"github.com/axetroy/go-server/pkg/daemon"
 	"github.com/jasonlvhit/gocron"
 	"github.com/urfave/cli/v2"
 	"log"
 	"os"
+	"time"
 )
 
 func runJobs() error {
+	database.Connect()
+
+	defer database.Dispose()
+
 	// 每天凌晨 3 点检查 login_log 表，并且进行切割数据
 	if err := gocron.Every(1).Day().At("03:00:01").Do(func() {
 		if err := migrate.LoginLogMigrate.Do(); err != nil {
@@ -29,12 +40,68 @@ func runJobs() error {
 		return err
 	}
 
+	// 每分钟检查一次, 自动拒绝超时未确认的转账
+	if err := gocron.Every(1).Minute().Do(func() {
+		if _, err := transfer.RejectExpired(time.Now()); err != nil {
+			log.Println(err)
+		}
+	}); err != nil {
+		return err
+	}
+
+	// 每天凌晨 5 点对账, 检查钱包余额与财务流水是否一致
+	if err := gocron.Every(1).Day().At("05:00:01").Do(func() {
+		if _, err := reconcile(); err != nil {
+			log.Println(err)
+		}
+	}); err != nil {
+		return err
+	}
+
+	// 每天凌晨 2 点检查, 轮换超过轮换周期的 token 签名密钥
+	if err := gocron.Every(1).Day().At("02:00:01").Do(func() {
+		if keys, err := token_key.RotateExpired(database.Db, time.Now()); err != nil {
+			log.Println(err)
+		} else {
+			printKeys(keys)
+		}
+	}); err != nil {
+		return err
+	}
+
 	// 启动定时任务
 	<-gocron.Start()
 
 	return nil
 }
 
+// 执行一次对账, 发现差异时输出到日志
+func reconcile() (*model.Reconciliation, error) {
+	r, err := reconciliation.Run(database.Db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Status {
+	case model.ReconciliationStatusFailed:
+		return r, fmt.Errorf("对账 %s 失败: %s", r.Id, *r.Error)
+	case model.ReconciliationStatusMismatch:
+		log.Printf("对账 %s 发现 %d 处差异, 共检查 %d 个钱包, %d 条流水\n", r.Id, r.Discrepancies, r.Wallets, r.Logs)
+	default:
+		log.Printf("对账 %s 完成, 共检查 %d 个钱包, %d 条流水\n", r.Id, r.Wallets, r.Logs)
+	}
+
+	return r, nil
+}
+
+// 输出新生成的签名密钥
+func printKeys(keys []model.TokenKey) {
+	for _, k := range keys {
+		log.Printf("已生成 %s 的签名密钥 %s, 将于 %s 开始签发\n", k.State, k.Id, k.ActivatedAt.Format(time.RFC3339))
+	}
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Usage = "定时任务"
@@ -54,6 +121,47 @@ func main() {
 				return daemon.Start(runJobs, c.Bool("daemon"))
 			},
 		},
+		{
+			Name:  "reconcile",
+			Usage: "立即执行一次对账",
+			Action: func(c *cli.Context) error {
+				database.Connect()
+
+				defer database.Dispose()
+
+				r, err := reconcile()
+
+				if err != nil {
+					return err
+				}
+
+				// 发现差异时以非零状态退出, 方便在脚本中使用
+				if r.Status != model.ReconciliationStatusBalanced {
+					return cli.Exit("", 1)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "rotate-key",
+			Usage: "立即轮换 token 的签名密钥",
+			Action: func(c *cli.Context) error {
+				database.Connect()
+
+				defer database.Dispose()
+
+				keys, err := token_key.Rotate(database.Db)
+
+				if err != nil {
+					return err
+				}
+
+				printKeys(keys)
+
+				return nil
+			},
+		},
 		{
 			Name:  "stop",
 			Usage: "停止定时任务",
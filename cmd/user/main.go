@@ -3,6 +3,7 @@ package main
 
 import (
 	"github.com/axetroy/go-server/internal/app/user_server"
+	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/pkg/daemon"
 	"github.com/urfave/cli/v2"
 	"log"
@@ -45,6 +46,9 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
+				// 第三方登陆的回调地址等需要使用对外的地址
+				config.Common.SetDomain(c.String("domain"))
+
 				// 判断当其是否是子进程，当父进程return之后，子进程会被系统1号进程接管
 				return daemon.Start(func() error {
 					return user_server.Serve(c.String("host"), c.String("port"))
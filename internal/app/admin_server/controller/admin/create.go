@@ -6,12 +6,12 @@ import (
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/authentication"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 	"github.com/mitchellh/mapstructure"
 	"time"
@@ -75,10 +75,16 @@ func CreateAdmin(input CreateAdminParams, isSuper bool) (res schema.Response) {
 		return
 	}
 
+	hashed, err := password.Generate(input.Password)
+
+	if err != nil {
+		return
+	}
+
 	adminInfo := model.Admin{
 		Username:  input.Account,
 		Name:      input.Name,
-		Password:  util.GeneratePassword(input.Password),
+		Password:  hashed,
 		Status:    model.AdminStatusInit,
 		Accession: []string{},
 		IsSuper:   isSuper,
@@ -93,8 +99,12 @@ func CreateAdmin(input CreateAdminParams, isSuper bool) (res schema.Response) {
 	}
 
 	// generate token
-	if data.Token, err = authentication.Gateway(true).Generate(adminInfo.Id); err != nil {
+	if t, er := authentication.Gateway(true).Generate(adminInfo.Id); er != nil {
+		err = er
 		return
+	} else {
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	data.CreatedAt = adminInfo.CreatedAt.Format(time.RFC3339Nano)
@@ -6,14 +6,18 @@ import (
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/authentication"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/notify"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/webauthn"
 	"github.com/jinzhu/gorm"
 	"github.com/mitchellh/mapstructure"
+	"log"
 	"time"
 )
 
@@ -23,11 +27,12 @@ type SignInParams struct {
 	Duration *int64 `json:"duration" validate:"omitempty,number,gt=0" comment:"有效时间"`
 }
 
-func Login(input SignInParams) (res schema.Response) {
+func Login(c helper.Context, input SignInParams) (res schema.Response) {
 	var (
-		err  error
-		data = schema.AdminProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = schema.AdminProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -50,7 +55,11 @@ func Login(input SignInParams) (res schema.Response) {
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	// 参数校验
@@ -58,20 +67,54 @@ func Login(input SignInParams) (res schema.Response) {
 		return
 	}
 
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	targets := []guard.Target{guard.Account(guard.ScopeAdmin, input.Username), guard.Ip(c.Ip)}
+
+	// 检查帐号和 IP 是否被锁定, 并记录这次尝试
+	if err = g.Attempt(targets...); err != nil {
+		return
+	}
+
 	tx = database.Db.Begin()
 
 	adminInfo := model.Admin{
 		Username: input.Username,
-		Password: util.GeneratePassword(input.Password),
 	}
 
-	if err = database.Db.Where(&adminInfo).First(&adminInfo).Error; err != nil {
+	if err = tx.Where(&adminInfo).First(&adminInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// 帐号不存在时同样校验一次密码, 避免通过响应时间判断帐号是否存在
+			password.Verify(input.Password, password.Dummy())
+			loginFail(g, c, "", targets...)
 			err = exception.InvalidAccountOrPassword
 		}
 		return
 	}
 
+	if !password.Verify(input.Password, adminInfo.Password) {
+		loginFail(g, c, adminInfo.Id, targets...)
+		err = exception.InvalidAccountOrPassword
+		return
+	}
+
+	// 旧的密码 hash 在登录成功后升级为当前的算法
+	if password.NeedRehash(adminInfo.Password) {
+		var hashed string
+
+		if hashed, err = password.Generate(input.Password); err != nil {
+			return
+		}
+
+		if err = tx.Model(&adminInfo).Update("password", hashed).Error; err != nil {
+			return
+		}
+	}
+
 	if err = mapstructure.Decode(adminInfo, &data.AdminProfilePure); err != nil {
 		return
 	}
@@ -85,23 +128,91 @@ func Login(input SignInParams) (res schema.Response) {
 		duration = time.Duration(*input.Duration * int64(time.Second))
 	}
 
+	credentials, err := webauthn.Credentials(tx, adminInfo.Id, true)
+
+	if err != nil {
+		return
+	}
+
+	// 开启了双重身份认证或者注册了通行密钥, 先返回挑战, 通过动态密码或者通行密钥的验证之后才生成身份令牌
+	methods := make([]string, 0)
+
+	if adminInfo.EnableTOTP {
+		methods = append(methods, "totp")
+	}
+
+	if len(credentials) != 0 {
+		methods = append(methods, "webauthn")
+	}
+
+	if len(methods) != 0 {
+		// 只撤销 IP 的这次尝试, 帐号的失败次数在第二步通过之后才清除
+		if err = g.Reset(targets[1]); err != nil {
+			return
+		}
+
+		challenge, err = newTOTPChallenge(adminInfo.Id, duration, methods, targets[0])
+		return
+	}
+
+	if err = g.Reset(targets...); err != nil {
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(true).Generate(adminInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	return
 }
 
+// 登陆失败, 记录失败次数. 帐号存在时写入失败的登陆记录, 帐号被锁定时推送提醒
+func loginFail(g *guard.Guard, c helper.Context, adminID string, targets ...guard.Target) {
+	locked, err := g.Fail(targets...)
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	if adminID == "" {
+		return
+	}
+
+	// 登陆的事务会回滚, 失败的登陆记录需要单独写入
+	if err := database.Db.Create(&model.LoginLog{
+		Uid:     adminID,                        // 管理员ID
+		Type:    model.LoginLogTypeUserName,     // 登陆方式
+		Command: model.LoginLogCommandLoginFail, // 登陆失败
+		Client:  c.UserAgent,                    // 管理员的 userAgent
+		LastIp:  c.Ip,                           // 管理员的IP
+	}).Error; err != nil {
+		log.Println(err)
+	}
+
+	for _, t := range locked {
+		if t.IsIp() {
+			continue
+		}
+
+		go func() {
+			if err := notify.Notify.SendNotifyToAdminForAccountLocked(adminID, c.Ip); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
 var LoginRouter = router.Handler(func(c router.Context) {
 	var (
 		input SignInParams
 	)
 
 	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
-		return Login(input)
+		return Login(helper.NewContext(&c), input)
 	})
 })
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/admin"
 	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/token"
 	"github.com/axetroy/go-server/tester"
@@ -24,7 +25,7 @@ func init() {
 func TestLogin(t *testing.T) {
 	// 登陆超级管理员-失败
 	{
-		r := admin.Login(admin.SignInParams{
+		r := admin.Login(helper.Context{}, admin.SignInParams{
 			Username: "admin",
 			Password: "admin123",
 		})
@@ -35,7 +36,7 @@ func TestLogin(t *testing.T) {
 
 	// 登陆超级管理员-成功
 	{
-		r := admin.Login(admin.SignInParams{
+		r := admin.Login(helper.Context{}, admin.SignInParams{
 			Username: "admin",
 			Password: "123456",
 		})
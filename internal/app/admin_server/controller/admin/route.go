@@ -0,0 +1,63 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/schema"
+)
+
+// 获取需要权限的路由, 以及当前管理员是否可以访问, 用于前端隐藏无法进行的操作
+func GetRoutes(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.AdminRoute, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	cc, err := rbac.NewAdmin(c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	for _, route := range rbac.AdminRoutes() {
+		d := schema.AdminRoute{
+			Method:    route.Method,
+			Path:      route.Path,
+			Accession: make([]string, 0, len(route.Accession)),
+			Allowed:   cc.Require(route.Accession),
+		}
+
+		for _, a := range route.Accession {
+			d.Accession = append(d.Accession, a.Name)
+		}
+
+		data = append(data, d)
+	}
+
+	return
+}
+
+var GetRoutesRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetRoutes(helper.NewContext(&c))
+	})
+})
@@ -0,0 +1,414 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"time"
+)
+
+type TOTPCodeParams struct {
+	Code string `json:"code" validate:"required,numeric,len=6" comment:"动态密码"` // 身份验证器上的动态密码
+}
+
+type DisableTOTPParams struct {
+	Code         string `json:"code" validate:"omitempty,numeric,len=6" comment:"动态密码"`  // 身份验证器上的动态密码
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=16" comment:"恢复码"` // 丢失身份验证器时可以使用恢复码
+}
+
+// 读取管理员双重身份认证的设置
+func totpAccount(tx *gorm.DB, id string) (*totp.Account, error) {
+	adminInfo := model.Admin{Id: id}
+
+	if err := tx.Where(&adminInfo).Last(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.AdminNotExist
+		}
+		return nil, err
+	}
+
+	return newTOTPAccount(adminInfo), nil
+}
+
+func newTOTPAccount(adminInfo model.Admin) *totp.Account {
+	return &totp.Account{
+		Model:   &model.Admin{},
+		Id:      adminInfo.Id,
+		Name:    adminInfo.Username,
+		Secret:  adminInfo.Secret,
+		Enabled: adminInfo.EnableTOTP,
+	}
+}
+
+// 开始设置双重身份认证, 生成新的密钥
+// 需要用身份验证器扫码之后, 再调用 EnableTOTP 确认开启
+func EnrollTOTP(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPEnroll
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	enrollment, err := account.Enroll(tx)
+
+	if err != nil {
+		return
+	}
+
+	data.Secret = enrollment.Secret
+	data.URI = enrollment.URI
+	data.QRCode = enrollment.QRCode
+
+	return
+}
+
+// 用第一个动态密码确认开启双重身份认证, 并生成恢复码
+func EnableTOTP(c helper.Context, input TOTPCodeParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPRecoveryCodes
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	data.Codes, err = account.Enable(tx, input.Code)
+
+	return
+}
+
+// 关闭双重身份认证, 需要提供动态密码或者恢复码
+func DisableTOTP(c helper.Context, input DisableTOTPParams) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	err = account.Disable(tx, input.Code, input.RecoveryCode)
+
+	return
+}
+
+// 重新生成恢复码, 旧的恢复码全部失效
+func RegenerateTOTPRecoveryCodes(c helper.Context, input TOTPCodeParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPRecoveryCodes
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	data.Codes, err = account.RegenerateRecoveryCodes(tx, input.Code)
+
+	return
+}
+
+var EnrollTOTPRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return EnrollTOTP(helper.NewContext(&c))
+	})
+})
+
+var EnableTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input TOTPCodeParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return EnableTOTP(helper.NewContext(&c), input)
+	})
+})
+
+var DisableTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input DisableTOTPParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return DisableTOTP(helper.NewContext(&c), input)
+	})
+})
+
+var RegenerateTOTPRecoveryCodesRouter = router.Handler(func(c router.Context) {
+	var (
+		input TOTPCodeParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RegenerateTOTPRecoveryCodes(helper.NewContext(&c), input)
+	})
+})
+
+type LoginWithTOTPParams struct {
+	Challenge    string `json:"challenge" validate:"required,max=64" comment:"挑战ID"`     // 登陆时返回的挑战ID
+	Code         string `json:"code" validate:"omitempty,numeric,len=6" comment:"动态密码"`  // 身份验证器上的动态密码
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=16" comment:"恢复码"` // 丢失身份验证器时可以使用恢复码
+}
+
+// 生成双重身份认证的挑战, methods 为可以使用的验证方式
+func newTOTPChallenge(adminId string, duration time.Duration, methods []string, account guard.Target) (*schema.TOTPChallenge, error) {
+	c, err := totp.NewChallenge(adminId, true, duration, model.LoginLogTypeUserName, account)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.TOTPChallenge{
+		Challenge: c.Id,
+		ExpiredAt: c.ExpiredAt.Format(time.RFC3339Nano),
+		Methods:   methods,
+	}, nil
+}
+
+// 登陆的第二步, 使用挑战 + 动态密码 (或者恢复码) 换取身份令牌
+func LoginWithTOTP(c helper.Context, input LoginWithTOTPParams) (res schema.Response) {
+	var (
+		err  error
+		data = schema.AdminProfileWithToken{}
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	challenge, err := totp.GetChallenge(input.Challenge, true)
+
+	if err != nil {
+		return
+	}
+
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	// 第二步的失败次数同样计入登陆的帐号
+	targets := []guard.Target{challenge.Account, guard.Ip(c.Ip)}
+
+	if err = g.Attempt(targets...); err != nil {
+		return
+	}
+
+	adminInfo := model.Admin{Id: challenge.Uid}
+
+	tx = database.Db.Begin()
+
+	if err = tx.Where(&adminInfo).First(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	if err = challenge.Verify(tx, newTOTPAccount(adminInfo), input.Code, input.RecoveryCode); err != nil {
+		if err == exception.InvalidTOTPCode {
+			loginFail(g, c, adminInfo.Id, targets...)
+		}
+		return
+	}
+
+	if err = g.Reset(targets...); err != nil {
+		return
+	}
+
+	if err = mapstructure.Decode(adminInfo, &data.AdminProfilePure); err != nil {
+		return
+	}
+
+	data.CreatedAt = adminInfo.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = adminInfo.UpdatedAt.Format(time.RFC3339Nano)
+
+	// generate token
+	if t, er := authentication.Gateway(true).Generate(adminInfo.Id, challenge.Duration); er != nil {
+		err = er
+		return
+	} else {
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
+	}
+
+	return
+}
+
+var LoginWithTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input LoginWithTOTPParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return LoginWithTOTP(helper.NewContext(&c), input)
+	})
+})
@@ -6,10 +6,10 @@ import (
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/service/password"
 	"time"
 
 	"github.com/axetroy/go-server/internal/library/exception"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
@@ -78,12 +78,16 @@ func UpdatePassword(c helper.Context, input UpdatePasswordParams) (res schema.Re
 	}
 
 	// 校验密码是否正确
-	if myInfo.Password != util.GeneratePassword(input.OldPassword) {
+	if !password.Verify(input.OldPassword, myInfo.Password) {
 		err = exception.InvalidOldPassword
 		return
 	}
 
-	newPassword := util.GeneratePassword(input.NewPassword)
+	newPassword, err := password.Generate(input.NewPassword)
+
+	if err != nil {
+		return
+	}
 
 	if err = tx.Model(&myInfo).Update("password", newPassword).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
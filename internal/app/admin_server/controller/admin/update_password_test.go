@@ -4,7 +4,7 @@ package admin_test
 import (
 	"encoding/json"
 	"github.com/axetroy/go-server/internal/library/helper"
-	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/password"
 	"net/http"
 	"testing"
 
@@ -50,7 +50,7 @@ func TestUpdatePassword(t *testing.T) {
 	m := model.Admin{Id: testAdminInfo.Id}
 
 	assert.Nil(t, database.Db.First(&m).Error)
-	assert.Equal(t, util.GeneratePassword("321321"), m.Password)
+	assert.True(t, password.Verify("321321", m.Password))
 }
 
 func TestUpdatePasswordRouter(t *testing.T) {
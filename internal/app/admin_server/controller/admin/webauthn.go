@@ -0,0 +1,482 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/axetroy/go-server/internal/service/webauthn"
+	nativeWebAuthn "github.com/axetroy/go-server/pkg/webauthn"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"time"
+)
+
+type RegisterWebAuthnParams struct {
+	Name       string                             `json:"name" validate:"required,max=32" comment:"名称"` // 通行密钥的名称, 方便区分不同的设备
+	Credential nativeWebAuthn.AttestationResponse `json:"credential" validate:"required" comment:"凭证"`  // navigator.credentials.create() 返回的凭证
+}
+
+type RenameWebAuthnParams struct {
+	Name string `json:"name" validate:"required,max=32" comment:"名称"` // 新的名称
+}
+
+type LoginWebAuthnOptionsParams struct {
+	Challenge string `json:"challenge" validate:"required,max=64" comment:"挑战ID"` // 登陆时返回的挑战ID
+}
+
+type LoginWithWebAuthnParams struct {
+	Challenge  string                           `json:"challenge" validate:"required,max=64" comment:"挑战ID"` // 登陆时返回的挑战ID
+	Credential nativeWebAuthn.AssertionResponse `json:"credential" validate:"required" comment:"凭证"`         // navigator.credentials.get() 返回的凭证
+}
+
+func webAuthnToSchema(c model.WebAuthnCredential) schema.WebAuthnCredential {
+	s := schema.WebAuthnCredential{
+		Id:         c.Id,
+		Name:       c.Name,
+		AAGUID:     c.AAGUID,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt.Format(time.RFC3339Nano),
+		UpdatedAt:  c.UpdatedAt.Format(time.RFC3339Nano),
+	}
+
+	if s.Transports == nil {
+		s.Transports = []string{}
+	}
+
+	if c.LastUsedAt != nil {
+		t := c.LastUsedAt.Format(time.RFC3339Nano)
+		s.LastUsedAt = &t
+	}
+
+	return s
+}
+
+// 注册通行密钥的第一步, 获取调用 navigator.credentials.create() 的参数
+// 注册之后, 登陆时可以使用通行密钥作为第二步验证
+func RegisterWebAuthnOptions(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data *nativeWebAuthn.CreationOptions
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	adminInfo := model.Admin{Id: c.Uid}
+
+	if err = database.Db.Where(&adminInfo).Last(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	data, err = webauthn.BeginRegistration(database.Db, adminInfo.Id, true, adminInfo.Username, adminInfo.Name)
+
+	return
+}
+
+// 注册通行密钥的第二步, 校验通过之后保存
+func RegisterWebAuthn(c helper.Context, input RegisterWebAuthnParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	info, err := webauthn.FinishRegistration(tx, c.Uid, true, input.Name, input.Credential)
+
+	if err != nil {
+		return
+	}
+
+	data = webAuthnToSchema(*info)
+
+	return
+}
+
+// 获取已注册的通行密钥
+func GetWebAuthnList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.WebAuthnCredential, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list, err := webauthn.Credentials(database.Db, c.Uid, true)
+
+	if err != nil {
+		return
+	}
+
+	for _, info := range list {
+		data = append(data, webAuthnToSchema(info))
+	}
+
+	return
+}
+
+// 重命名通行密钥
+func RenameWebAuthn(c helper.Context, id string, input RenameWebAuthnParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	info, err := webauthn.Rename(tx, c.Uid, true, id, input.Name)
+
+	if err != nil {
+		return
+	}
+
+	data = webAuthnToSchema(*info)
+
+	return
+}
+
+// 删除通行密钥
+func DeleteWebAuthn(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	info, err := webauthn.Delete(tx, c.Uid, true, id)
+
+	if err != nil {
+		return
+	}
+
+	data = webAuthnToSchema(*info)
+
+	return
+}
+
+// 登陆的第二步, 使用通行密钥验证之前, 获取调用 navigator.credentials.get() 的参数
+func LoginWebAuthnOptions(input LoginWebAuthnOptionsParams) (res schema.Response) {
+	var (
+		err  error
+		data *nativeWebAuthn.RequestOptions
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	challenge, err := totp.GetChallenge(input.Challenge, true)
+
+	if err != nil {
+		return
+	}
+
+	data, err = webauthn.BeginLogin(database.Db, webauthn.PurposeSecondFactor, challenge.Uid, true)
+
+	return
+}
+
+// 登陆的第二步, 使用挑战 + 通行密钥换取身份令牌
+func LoginWithWebAuthn(c helper.Context, input LoginWithWebAuthnParams) (res schema.Response) {
+	var (
+		err  error
+		data = schema.AdminProfileWithToken{}
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	challenge, err := totp.GetChallenge(input.Challenge, true)
+
+	if err != nil {
+		return
+	}
+
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	// 第二步的失败次数同样计入登陆的帐号
+	targets := []guard.Target{challenge.Account, guard.Ip(c.Ip)}
+
+	if err = g.Attempt(targets...); err != nil {
+		return
+	}
+
+	adminInfo := model.Admin{Id: challenge.Uid}
+
+	tx = database.Db.Begin()
+
+	if err = tx.Where(&adminInfo).First(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	credential, _, err := webauthn.FinishLogin(tx, webauthn.PurposeSecondFactor, true, input.Credential)
+
+	// 只能使用该管理员自己的通行密钥
+	if err == nil && credential.Uid != adminInfo.Id {
+		err = exception.InvalidWebAuthnCredential
+	}
+
+	if err != nil {
+		if err == exception.InvalidWebAuthnCredential || err == exception.WebAuthnCredentialNotExist {
+			loginFail(g, c, adminInfo.Id, targets...)
+
+			if er := challenge.Fail(); er != nil {
+				err = er
+			}
+		}
+		return
+	}
+
+	if err = challenge.Done(); err != nil {
+		return
+	}
+
+	if err = g.Reset(targets...); err != nil {
+		return
+	}
+
+	if err = mapstructure.Decode(adminInfo, &data.AdminProfilePure); err != nil {
+		return
+	}
+
+	data.CreatedAt = adminInfo.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = adminInfo.UpdatedAt.Format(time.RFC3339Nano)
+
+	// generate token
+	if t, er := authentication.Gateway(true).Generate(adminInfo.Id, challenge.Duration); er != nil {
+		err = er
+		return
+	} else {
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
+	}
+
+	return
+}
+
+var RegisterWebAuthnOptionsRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return RegisterWebAuthnOptions(helper.NewContext(&c))
+	})
+})
+
+var RegisterWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input RegisterWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RegisterWebAuthn(helper.NewContext(&c), input)
+	})
+})
+
+var GetWebAuthnListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetWebAuthnList(helper.NewContext(&c))
+	})
+})
+
+var RenameWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input RenameWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RenameWebAuthn(helper.NewContext(&c), c.Param("webauthn_id"), input)
+	})
+})
+
+var DeleteWebAuthnRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return DeleteWebAuthn(helper.NewContext(&c), c.Param("webauthn_id"))
+	})
+})
+
+var LoginWebAuthnOptionsRouter = router.Handler(func(c router.Context) {
+	var (
+		input LoginWebAuthnOptionsParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return LoginWebAuthnOptions(input)
+	})
+})
+
+var LoginWithWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input LoginWithWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return LoginWithWebAuthn(helper.NewContext(&c), input)
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/admin"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/internal/service/webauthn"
+	nativeWebAuthn "github.com/axetroy/go-server/pkg/webauthn"
+	"github.com/axetroy/go-server/pkg/webauthn/webauthntest"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoginWithWebAuthn(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+
+	var (
+		authenticator = webauthntest.NewAuthenticator()
+		origin        = webauthn.RelyingParty().Origins[0]
+		credential    = schema.WebAuthnCredential{}
+	)
+
+	// 注册通行密钥
+	{
+		r := admin.RegisterWebAuthnOptions(helper.Context{Uid: adminInfo.Id})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+
+		options := nativeWebAuthn.CreationOptions{}
+
+		assert.Nil(t, r.Decode(&options))
+
+		res, err := authenticator.Create(origin, options)
+
+		assert.Nil(t, err)
+
+		r = admin.RegisterWebAuthn(helper.Context{Uid: adminInfo.Id}, admin.RegisterWebAuthnParams{
+			Name:       "security key",
+			Credential: *res,
+		})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&credential))
+	}
+
+	// 删除之后其他测试可以直接登陆
+	defer admin.DeleteWebAuthn(helper.Context{Uid: adminInfo.Id}, credential.Id)
+
+	// 登陆时返回挑战
+	r := admin.Login(helper.Context{}, admin.SignInParams{
+		Username: "admin",
+		Password: "123456",
+	})
+
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	challenge := schema.TOTPChallenge{}
+
+	assert.Nil(t, r.Decode(&challenge))
+	assert.NotEmpty(t, challenge.Challenge)
+	assert.Contains(t, challenge.Methods, "webauthn")
+
+	r = admin.LoginWebAuthnOptions(admin.LoginWebAuthnOptionsParams{Challenge: challenge.Challenge})
+
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+
+	options := nativeWebAuthn.RequestOptions{}
+
+	assert.Nil(t, r.Decode(&options))
+	assert.Len(t, options.AllowCredentials, 1)
+
+	// 挑战ID不正确
+	{
+		res, err := authenticator.Get(origin, options)
+
+		assert.Nil(t, err)
+
+		r = admin.LoginWithWebAuthn(helper.Context{}, admin.LoginWithWebAuthnParams{Challenge: "invalid", Credential: *res})
+
+		assert.Equal(t, exception.TOTPChallengeExpired.Code(), r.Status)
+		assert.Equal(t, exception.TOTPChallengeExpired.Error(), r.Message)
+	}
+
+	r = admin.LoginWebAuthnOptions(admin.LoginWebAuthnOptionsParams{Challenge: challenge.Challenge})
+
+	assert.Nil(t, r.Decode(&options))
+
+	res, err := authenticator.Get(origin, options)
+
+	assert.Nil(t, err)
+
+	r = admin.LoginWithWebAuthn(helper.Context{}, admin.LoginWithWebAuthnParams{Challenge: challenge.Challenge, Credential: *res})
+
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Equal(t, "", r.Message)
+
+	profile := schema.AdminProfileWithToken{}
+
+	assert.Nil(t, r.Decode(&profile))
+
+	if c, er := token.Parse(token.Prefix+" "+profile.Token, token.StateAdmin); er != nil {
+		t.Error(er)
+	} else {
+		assert.Equal(t, adminInfo.Id, c.Uid)
+	}
+
+	// 挑战只能使用一次
+	r = admin.LoginWebAuthnOptions(admin.LoginWebAuthnOptionsParams{Challenge: challenge.Challenge})
+
+	assert.Equal(t, exception.TOTPChallengeExpired.Code(), r.Status)
+}
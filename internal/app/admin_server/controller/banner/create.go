@@ -74,11 +74,6 @@ func Create(c helper.Context, input CreateParams) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	if input.Platform == model.BannerPlatformPc {
 		// PC 端
 	} else if input.Platform == model.BannerPlatformApp {
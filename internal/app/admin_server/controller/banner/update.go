@@ -75,11 +75,6 @@ func Update(c helper.Context, bannerId string, input UpdateParams) (res schema.R
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	bannerInfo := model.Banner{
 		Id: bannerId,
 	}
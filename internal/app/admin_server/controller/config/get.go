@@ -67,11 +67,6 @@ func Get(c helper.Context, configName string) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	ConfigInfo := model.Config{
 		Name: configName,
 	}
@@ -69,11 +69,6 @@ func GetList(c helper.Context, query Query) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	list := make([]model.Config, 0)
 
 	filter := map[string]interface{}{}
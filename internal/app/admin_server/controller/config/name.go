@@ -57,11 +57,6 @@ func GetName(c helper.Context) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	for _, f := range model.ConfigFields {
 		n := schema.Name{}
 		if err = mapstructure.Decode(f, &n); err != nil {
@@ -0,0 +1,103 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oauth"
+	"github.com/jinzhu/gorm"
+)
+
+// 获取第三方登陆的服务提供商, 并检查是否可用
+func GetOAuthProviderList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.OAuthProvider, 0)
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	adminInfo := model.Admin{
+		Id: c.Uid,
+	}
+
+	if err = tx.First(&adminInfo).Error; err != nil {
+		// 没有找到管理员
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	fields, _, err := oauth.LoadConfig(tx)
+
+	if err != nil {
+		return
+	}
+
+	// 检查服务提供商需要请求外部的地址, 先结束事务, 不要在等待的时候占用数据库连接
+	err = tx.Commit().Error
+	tx = nil
+
+	if err != nil {
+		return
+	}
+
+	for i, health := range oauth.CheckAll(fields.Providers) {
+		p := fields.Providers[i]
+
+		scopes := p.Scopes
+
+		if scopes == nil {
+			scopes = []string{}
+		}
+
+		data = append(data, schema.OAuthProvider{
+			Name:        p.Name,
+			Type:        string(p.Type),
+			Enabled:     p.Enabled,
+			CallbackUrl: oauth.CallbackURL(p.Name),
+			Scopes:      scopes,
+			Healthy:     health.Healthy,
+			Error:       health.Error,
+			Latency:     health.Latency.Milliseconds(),
+		})
+	}
+
+	return
+}
+
+var GetOAuthProviderListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetOAuthProviderList(helper.NewContext(&c))
+	})
+})
@@ -74,11 +74,6 @@ func Update(c helper.Context, configName string, fields []byte) (res schema.Resp
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	configInfo := model.Config{
 		Name: configName,
 	}
@@ -0,0 +1,92 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+)
+
+type CreateParams struct {
+	Code      string `json:"code" validate:"required,max=12" comment:"币种代码"` // 币种代码, 大写字母和数字, 创建后不可修改
+	Name      string `json:"name" validate:"required,max=32" comment:"名称"`   // 显示名称
+	Precision int    `json:"precision" validate:"gte=0,lte=8" comment:"精度"`  // 金额允许的最大小数位数, 创建后不可修改
+	Enabled   *bool  `json:"enabled" validate:"omitempty" comment:"是否启用"`    // 是否启用, 默认启用
+}
+
+// 添加新币种, 会自动创建对应的表并为所有用户开通钱包
+func Create(c helper.Context, input CreateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Currency
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	enabled := true
+
+	if input.Enabled != nil {
+		enabled = *input.Enabled
+	}
+
+	currencyInfo, err := currency.Register(tx, model.Currency{
+		Code:      input.Code,
+		Name:      input.Name,
+		Precision: input.Precision,
+		Enabled:   enabled,
+	})
+
+	if err != nil {
+		return
+	}
+
+	mapToSchema(*currencyInfo, &data)
+
+	return
+}
+
+var CreateRouter = router.Handler(func(c router.Context) {
+	var (
+		input CreateParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Create(helper.NewContext(&c), input)
+	})
+})
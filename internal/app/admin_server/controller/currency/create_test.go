@@ -0,0 +1,118 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency_test
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/currency"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// 删除测试产生的币种和对应的表
+func deleteCurrency(code string) {
+	for _, tableName := range []string{model.WalletTableName(code), model.TransferLogTableName(code), model.FinanceLogTableName(code)} {
+		_ = database.Db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, tableName)).Error
+	}
+	database.DeleteRowByTable("currency", "code", code)
+}
+
+func TestCreate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	code := "T" + strings.ToUpper(util.RandomString(6))
+
+	defer deleteCurrency(code)
+
+	// 无效的币种代码
+	{
+		r := currency.Create(helper.Context{Uid: adminInfo.Id}, currency.CreateParams{
+			Code: "t-1",
+			Name: "test",
+		})
+
+		assert.Equal(t, exception.CurrencyInvalidCode.Error(), r.Message)
+	}
+
+	r := currency.Create(helper.Context{Uid: adminInfo.Id}, currency.CreateParams{
+		Code:      strings.ToLower(code),
+		Name:      "test",
+		Precision: 4,
+	})
+
+	data := schema.Currency{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, r.Decode(&data))
+	assert.Equal(t, code, data.Code)
+	assert.Equal(t, 4, data.Precision)
+	assert.True(t, data.Enabled)
+	assert.False(t, data.BuiltIn)
+
+	// 对应的表已经创建
+	assert.True(t, database.Db.HasTable(model.WalletTableName(code)))
+	assert.True(t, database.Db.HasTable(model.TransferLogTableName(code)))
+	assert.True(t, database.Db.HasTable(model.FinanceLogTableName(code)))
+
+	// 已有的用户已经开通了钱包
+	w := model.Wallet{}
+	assert.Nil(t, database.Db.Table(model.WalletTableName(code)).Where("id = ?", userInfo.Id).First(&w).Error)
+	assert.Equal(t, code, w.Currency)
+
+	// 不能重复添加
+	{
+		r := currency.Create(helper.Context{Uid: adminInfo.Id}, currency.CreateParams{
+			Code: code,
+			Name: "test",
+		})
+
+		assert.Equal(t, exception.CurrencyExist.Error(), r.Message)
+	}
+
+	// 停用
+	{
+		enabled := false
+
+		r := currency.Update(helper.Context{Uid: adminInfo.Id}, code, currency.UpdateParams{
+			Enabled: &enabled,
+		})
+
+		data := schema.Currency{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&data))
+		assert.False(t, data.Enabled)
+	}
+
+	// 列表中包含内置币种和新添加的币种
+	{
+		r := currency.GetList(helper.Context{Uid: adminInfo.Id})
+
+		list := make([]schema.Currency, 0)
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&list))
+
+		codes := map[string]bool{}
+
+		for _, v := range list {
+			codes[v.Code] = true
+		}
+
+		assert.True(t, codes[model.WalletCNY])
+		assert.True(t, codes[model.WalletUSD])
+		assert.True(t, codes[model.WalletCOIN])
+		assert.True(t, codes[code])
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+)
+
+// 获取所有币种, 币种数量很少, 不分页
+func GetList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Currency, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list, err := currency.List(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.Currency{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+func Get(c helper.Context, code string) (res schema.Response) {
+	var (
+		err  error
+		data schema.Currency
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	currencyInfo, err := currency.Get(database.Db, code)
+
+	if err != nil {
+		return
+	}
+
+	mapToSchema(*currencyInfo, &data)
+
+	return
+}
+
+var GetListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetList(helper.NewContext(&c))
+	})
+})
+
+var GetRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return Get(helper.NewContext(&c), c.Param("code"))
+	})
+})
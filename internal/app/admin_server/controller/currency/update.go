@@ -0,0 +1,100 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+)
+
+// 币种代码和精度不允许修改, 否则已有的数据会不一致
+type UpdateParams struct {
+	Name    *string `json:"name" validate:"omitempty,max=32" comment:"名称"` // 显示名称
+	Enabled *bool   `json:"enabled" validate:"omitempty" comment:"是否启用"`   // 是否启用
+}
+
+func Update(c helper.Context, code string, input UpdateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.Currency
+		tx           *gorm.DB
+		shouldUpdate bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil || !shouldUpdate {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	currencyInfo, err := currency.Get(tx, code)
+
+	if err != nil {
+		return
+	}
+
+	// 使用 map 更新, 否则 enabled=false 会被忽略
+	updateModel := map[string]interface{}{}
+
+	if input.Name != nil {
+		shouldUpdate = true
+		updateModel["name"] = *input.Name
+	}
+
+	if input.Enabled != nil {
+		shouldUpdate = true
+		updateModel["enabled"] = *input.Enabled
+	}
+
+	if shouldUpdate {
+		if err = tx.Model(currencyInfo).Updates(updateModel).Error; err != nil {
+			return
+		}
+	}
+
+	mapToSchema(*currencyInfo, &data)
+
+	return
+}
+
+var UpdateRouter = router.Handler(func(c router.Context) {
+	var (
+		input UpdateParams
+	)
+
+	code := c.Param("code")
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Update(helper.NewContext(&c), code, input)
+	})
+})
@@ -0,0 +1,18 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"time"
+)
+
+func mapToSchema(c model.Currency, d *schema.Currency) {
+	d.Code = c.Code
+	d.Name = c.Name
+	d.Precision = c.Precision
+	d.Enabled = c.Enabled
+	d.BuiltIn = c.BuiltIn
+	d.CreatedAt = c.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = c.UpdatedAt.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,200 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type SetRateParams struct {
+	From    string `json:"from" validate:"required" comment:"兑出的币种"`             // 兑出的币种
+	To      string `json:"to" validate:"required" comment:"兑入的币种"`               // 兑入的币种
+	Rate    string `json:"rate" validate:"required,numeric,gt=0" comment:"汇率"`   // 1 个单位的 From 可以兑换多少 To
+	FeeRate string `json:"fee_rate" validate:"omitempty,numeric" comment:"手续费率"` // 手续费率, 取值范围 [0, 1), 默认为 0
+	Enabled *bool  `json:"enabled" validate:"omitempty" comment:"是否启用"`          // 是否启用, 默认启用
+}
+
+func mapToSchema(r model.ExchangeRate, d *schema.ExchangeRate) {
+	d.Id = r.Id
+	d.From = r.From
+	d.To = r.To
+	d.Rate = r.Rate.String()
+	d.FeeRate = r.FeeRate.String()
+	d.Enabled = r.Enabled
+	d.CreatedAt = r.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = r.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 获取所有的汇率, 包括已停用的
+func GetRateList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.ExchangeRate, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list := make([]model.ExchangeRate, 0)
+
+	if err = database.Db.Order("\"from\"").Order("\"to\"").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.ExchangeRate{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 设置某个方向的汇率, 不存在则创建
+// 已经发出的报价不受影响, 在有效期内仍然按照报价时的汇率成交
+func SetRate(c helper.Context, input SetRateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.ExchangeRate
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	var rate, feeRate decimal.Decimal
+
+	if rate, err = decimal.NewFromString(input.Rate); err != nil || !rate.IsPositive() {
+		err = exception.InvalidParams
+		return
+	}
+
+	if input.FeeRate != "" {
+		// 手续费率必须在 [0, 1) 之间
+		if feeRate, err = decimal.NewFromString(input.FeeRate); err != nil || feeRate.IsNegative() || !feeRate.LessThan(decimal.NewFromInt(1)) {
+			err = exception.InvalidParams
+			return
+		}
+	}
+
+	enabled := true
+
+	if input.Enabled != nil {
+		enabled = *input.Enabled
+	}
+
+	tx = database.Db.Begin()
+
+	fromCurrency, err := currency.Get(tx, input.From)
+
+	if err != nil {
+		return
+	}
+
+	toCurrency, err := currency.Get(tx, input.To)
+
+	if err != nil {
+		return
+	}
+
+	if fromCurrency.Code == toCurrency.Code {
+		err = exception.ExchangeSameCurrency
+		return
+	}
+
+	rateInfo := model.ExchangeRate{}
+
+	if err = tx.Where("\"from\" = ? AND \"to\" = ?", fromCurrency.Code, toCurrency.Code).First(&rateInfo).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		rateInfo = model.ExchangeRate{
+			From:    fromCurrency.Code,
+			To:      toCurrency.Code,
+			Rate:    rate,
+			FeeRate: feeRate,
+			Enabled: enabled,
+		}
+
+		if err = tx.Create(&rateInfo).Error; err != nil {
+			return
+		}
+	} else {
+		// 使用 map 更新, 否则 enabled=false 和 fee_rate=0 会被忽略
+		if err = tx.Model(&rateInfo).Updates(map[string]interface{}{
+			"rate":     rate,
+			"fee_rate": feeRate,
+			"enabled":  enabled,
+		}).Error; err != nil {
+			return
+		}
+	}
+
+	mapToSchema(rateInfo, &data)
+
+	return
+}
+
+var GetRateListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetRateList(helper.NewContext(&c))
+	})
+})
+
+var SetRateRouter = router.Handler(func(c router.Context) {
+	var (
+		input SetRateParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return SetRate(helper.NewContext(&c), input)
+	})
+})
@@ -77,11 +77,6 @@ func Create(c helper.Context, input CreateMessageParams) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	userInfo := model.User{
 		Id: input.Uid,
 	}
@@ -70,11 +70,6 @@ func Update(c helper.Context, messageId string, input UpdateParams) (res schema.
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	messageInfo := model.Message{
 		Id: messageId,
 	}
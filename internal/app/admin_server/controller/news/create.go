@@ -77,11 +77,6 @@ func Create(c helper.Context, input CreateNewParams) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	NewsInfo := model.News{
 		Author:  c.Uid,
 		Title:   input.Title,
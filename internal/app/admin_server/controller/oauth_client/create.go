@@ -0,0 +1,107 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+)
+
+type CreateParams struct {
+	Name         string   `json:"name" validate:"required,max=36" comment:"名称"`                        // 应用名称
+	Description  *string  `json:"description" validate:"omitempty,max=255" comment:"描述"`               // 应用描述
+	Logo         *string  `json:"logo" validate:"omitempty,url,max=255" comment:"图标"`                  // 应用图标
+	Homepage     *string  `json:"homepage" validate:"omitempty,url,max=255" comment:"主页"`              // 应用主页
+	Public       bool     `json:"public" comment:"是否是公开应用"`                                            // 是否是公开应用, 创建后不可修改
+	RedirectUris []string `json:"redirect_uris" validate:"required,min=1,dive,max=255" comment:"回调地址"` // 允许的回调地址
+	Scopes       []string `json:"scopes" validate:"required,min=1" comment:"授权范围"`                     // 允许申请的 scope
+}
+
+// 登记新的应用, 私密应用会生成密钥, 密钥只在这里返回一次
+func Create(c helper.Context, input CreateParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthClientWithSecret
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	if err = checkRedirectUris(input.RedirectUris); err != nil {
+		return
+	}
+
+	if err = checkScopes(input.Scopes); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	clientInfo := model.OAuthClient{
+		Name:         input.Name,
+		Description:  input.Description,
+		Logo:         input.Logo,
+		Homepage:     input.Homepage,
+		Public:       input.Public,
+		RedirectUris: input.RedirectUris,
+		Scopes:       input.Scopes,
+		Status:       model.OAuthClientStatusEnabled,
+	}
+
+	if !input.Public {
+		if data.Secret, clientInfo.Secret, err = oidc.GenerateSecret(); err != nil {
+			return
+		}
+	}
+
+	if err = tx.Create(&clientInfo).Error; err != nil {
+		return
+	}
+
+	mapToSchema(clientInfo, &data.OAuthClient)
+
+	return
+}
+
+var CreateRouter = router.Handler(func(c router.Context) {
+	var (
+		input CreateParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Create(helper.NewContext(&c), input)
+	})
+})
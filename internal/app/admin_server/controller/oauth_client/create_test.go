@@ -0,0 +1,130 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/oauth_client"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+
+	// 无效的回调地址
+	{
+		r := oauth_client.Create(helper.Context{Uid: adminInfo.Id}, oauth_client.CreateParams{
+			Name:         "test",
+			RedirectUris: []string{"https://example.com/callback#fragment"},
+			Scopes:       []string{oidc.ScopeOpenId},
+		})
+
+		assert.Equal(t, exception.InvalidRedirectUri.Error(), r.Message)
+	}
+
+	// 不支持的 scope
+	{
+		r := oauth_client.Create(helper.Context{Uid: adminInfo.Id}, oauth_client.CreateParams{
+			Name:         "test",
+			RedirectUris: []string{"https://example.com/callback"},
+			Scopes:       []string{"admin"},
+		})
+
+		assert.Equal(t, exception.InvalidOAuthScope.Error(), r.Message)
+	}
+
+	r := oauth_client.Create(helper.Context{Uid: adminInfo.Id}, oauth_client.CreateParams{
+		Name:         "test",
+		RedirectUris: []string{"https://example.com/callback"},
+		Scopes:       []string{oidc.ScopeOpenId, oidc.ScopeProfile},
+	})
+
+	data := schema.OAuthClientWithSecret{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, r.Decode(&data))
+
+	defer oauth_client.DeleteClientById(data.Id)
+
+	assert.NotEmpty(t, data.Secret)
+	assert.False(t, data.Public)
+	assert.Equal(t, model.OAuthClientStatusEnabled, data.Status)
+
+	// 只存储密钥的 hash
+	{
+		c := model.OAuthClient{Id: data.Id}
+		assert.Nil(t, database.Db.First(&c).Error)
+		assert.Equal(t, oidc.HashSecret(data.Secret), c.Secret)
+
+		_, err := oidc.Authenticate(database.Db, data.Id, data.Secret)
+		assert.Nil(t, err)
+
+		_, err = oidc.Authenticate(database.Db, data.Id, "invalid")
+		assert.NotNil(t, err)
+	}
+
+	// 重置密钥之后旧的密钥失效
+	{
+		r := oauth_client.ResetSecret(helper.Context{Uid: adminInfo.Id}, data.Id)
+
+		reset := schema.OAuthClientWithSecret{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&reset))
+		assert.NotEqual(t, data.Secret, reset.Secret)
+
+		_, err := oidc.Authenticate(database.Db, data.Id, data.Secret)
+		assert.NotNil(t, err)
+
+		_, err = oidc.Authenticate(database.Db, data.Id, reset.Secret)
+		assert.Nil(t, err)
+	}
+
+	// 停用
+	{
+		status := model.OAuthClientStatusDisabled
+
+		r := oauth_client.Update(helper.Context{Uid: adminInfo.Id}, data.Id, oauth_client.UpdateParams{
+			Status: &status,
+		})
+
+		updated := schema.OAuthClient{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&updated))
+		assert.Equal(t, model.OAuthClientStatusDisabled, updated.Status)
+
+		_, err := oidc.GetClient(database.Db, data.Id)
+		assert.NotNil(t, err)
+	}
+
+	// 公开应用没有密钥
+	{
+		r := oauth_client.Create(helper.Context{Uid: adminInfo.Id}, oauth_client.CreateParams{
+			Name:         "test",
+			Public:       true,
+			RedirectUris: []string{"https://example.com/callback"},
+			Scopes:       []string{oidc.ScopeOpenId},
+		})
+
+		public := schema.OAuthClientWithSecret{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&public))
+
+		defer oauth_client.DeleteClientById(public.Id)
+
+		assert.Empty(t, public.Secret)
+
+		r = oauth_client.ResetSecret(helper.Context{Uid: adminInfo.Id}, public.Id)
+
+		assert.Equal(t, exception.OAuthClientIsPublic.Error(), r.Message)
+	}
+}
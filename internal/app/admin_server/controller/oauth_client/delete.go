@@ -0,0 +1,78 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+)
+
+func DeleteClientById(id string) {
+	database.DeleteRowByTable("oauth_consent", "client_id", id)
+	database.DeleteRowByTable("oauth_client", "id", id)
+}
+
+// 删除应用, 用户的授权记录一并删除, 已签发的 token 全部失效
+func Delete(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthClient
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	clientInfo, err := getClient(tx, id)
+
+	if err != nil {
+		return
+	}
+
+	if err = tx.Where("client_id = ?", clientInfo.Id).Delete(model.OAuthConsent{}).Error; err != nil {
+		return
+	}
+
+	if err = tx.Delete(model.OAuthClient{Id: clientInfo.Id}).Error; err != nil {
+		return
+	}
+
+	mapToSchema(*clientInfo, &data)
+
+	return
+}
+
+var DeleteRouter = router.Handler(func(c router.Context) {
+	id := c.Param("client_id")
+
+	c.ResponseFunc(nil, func() schema.Response {
+		return Delete(helper.NewContext(&c), id)
+	})
+})
@@ -0,0 +1,51 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+)
+
+func Get(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthClient
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	clientInfo, err := getClient(database.Db, id)
+
+	if err != nil {
+		return
+	}
+
+	mapToSchema(*clientInfo, &data)
+
+	return
+}
+
+var GetRouter = router.Handler(func(c router.Context) {
+	id := c.Param("client_id")
+
+	c.ResponseFunc(nil, func() schema.Response {
+		return Get(helper.NewContext(&c), id)
+	})
+})
@@ -0,0 +1,88 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+)
+
+type Query struct {
+	schema.Query
+	Status *model.OAuthClientStatus `json:"status" url:"status" validate:"omitempty,oneof=-1 1" comment:"状态"` // 根据状态筛选
+}
+
+func GetList(c helper.Context, query Query) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.OAuthClient, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = query.Validate(); err != nil {
+		return
+	}
+
+	list := make([]model.OAuthClient, 0)
+
+	filter := map[string]interface{}{}
+
+	if query.Status != nil {
+		filter["status"] = *query.Status
+	}
+
+	var total int64
+
+	if err = query.Order(database.Db.Limit(query.Limit).Offset(query.Limit * query.Page)).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.OAuthClient{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.OAuthClient{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+var GetListRouter = router.Handler(func(c router.Context) {
+	var (
+		query Query
+	)
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetList(helper.NewContext(&c), query)
+	})
+})
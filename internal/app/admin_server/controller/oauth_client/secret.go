@@ -0,0 +1,80 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+)
+
+// 重置应用密钥, 旧的密钥立即失效. 新的密钥只在这里返回一次
+func ResetSecret(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthClientWithSecret
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	clientInfo, err := getClient(tx, id)
+
+	if err != nil {
+		return
+	}
+
+	if clientInfo.Public {
+		err = exception.OAuthClientIsPublic
+		return
+	}
+
+	var hash string
+
+	if data.Secret, hash, err = oidc.GenerateSecret(); err != nil {
+		return
+	}
+
+	if err = tx.Model(clientInfo).Update("secret", hash).Error; err != nil {
+		return
+	}
+
+	mapToSchema(*clientInfo, &data.OAuthClient)
+
+	return
+}
+
+var ResetSecretRouter = router.Handler(func(c router.Context) {
+	id := c.Param("client_id")
+
+	c.ResponseFunc(nil, func() schema.Response {
+		return ResetSecret(helper.NewContext(&c), id)
+	})
+})
@@ -0,0 +1,133 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// 应用是否公开不允许修改, 否则需要重新生成密钥
+type UpdateParams struct {
+	Name         *string                  `json:"name" validate:"omitempty,max=36" comment:"名称"`                        // 应用名称
+	Description  *string                  `json:"description" validate:"omitempty,max=255" comment:"描述"`                // 应用描述
+	Logo         *string                  `json:"logo" validate:"omitempty,url,max=255" comment:"图标"`                   // 应用图标
+	Homepage     *string                  `json:"homepage" validate:"omitempty,url,max=255" comment:"主页"`               // 应用主页
+	RedirectUris []string                 `json:"redirect_uris" validate:"omitempty,min=1,dive,max=255" comment:"回调地址"` // 允许的回调地址
+	Scopes       []string                 `json:"scopes" validate:"omitempty,min=1" comment:"授权范围"`                     // 允许申请的 scope
+	Status       *model.OAuthClientStatus `json:"status" validate:"omitempty,oneof=-1 1" comment:"状态"`                  // 状态, 停用之后已签发的 token 全部失效
+}
+
+func Update(c helper.Context, id string, input UpdateParams) (res schema.Response) {
+	var (
+		err          error
+		data         schema.OAuthClient
+		tx           *gorm.DB
+		shouldUpdate bool
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil || !shouldUpdate {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	clientInfo, err := getClient(tx, id)
+
+	if err != nil {
+		return
+	}
+
+	updated := map[string]interface{}{}
+
+	if input.Name != nil {
+		updated["name"] = *input.Name
+	}
+
+	if input.Description != nil {
+		updated["description"] = *input.Description
+	}
+
+	if input.Logo != nil {
+		updated["logo"] = *input.Logo
+	}
+
+	if input.Homepage != nil {
+		updated["homepage"] = *input.Homepage
+	}
+
+	if input.RedirectUris != nil {
+		if err = checkRedirectUris(input.RedirectUris); err != nil {
+			return
+		}
+
+		updated["redirect_uris"] = pq.StringArray(input.RedirectUris)
+	}
+
+	if input.Scopes != nil {
+		if err = checkScopes(input.Scopes); err != nil {
+			return
+		}
+
+		updated["scopes"] = pq.StringArray(input.Scopes)
+	}
+
+	if input.Status != nil {
+		updated["status"] = *input.Status
+	}
+
+	if len(updated) > 0 {
+		shouldUpdate = true
+
+		if err = tx.Model(clientInfo).Updates(updated).Error; err != nil {
+			return
+		}
+	}
+
+	mapToSchema(*clientInfo, &data)
+
+	return
+}
+
+var UpdateRouter = router.Handler(func(c router.Context) {
+	var (
+		input UpdateParams
+	)
+
+	id := c.Param("client_id")
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Update(helper.NewContext(&c), id, input)
+	})
+})
@@ -0,0 +1,62 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_client
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+	"net/url"
+	"time"
+)
+
+func mapToSchema(c model.OAuthClient, d *schema.OAuthClient) {
+	d.Id = c.Id
+	d.Name = c.Name
+	d.Description = c.Description
+	d.Logo = c.Logo
+	d.Homepage = c.Homepage
+	d.Public = c.Public
+	d.RedirectUris = c.RedirectUris
+	d.Scopes = c.Scopes
+	d.Status = c.Status
+	d.CreatedAt = c.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = c.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+// 回调地址必须是完整的地址, 并且不能带有 fragment (RFC 6749 3.1.2)
+func checkRedirectUris(list []string) error {
+	for _, v := range list {
+		u, err := url.Parse(v)
+
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return exception.InvalidRedirectUri
+		}
+	}
+
+	return nil
+}
+
+func checkScopes(list []string) error {
+	for _, v := range list {
+		if !oidc.IsValidScope(v) {
+			return exception.InvalidOAuthScope
+		}
+	}
+
+	return nil
+}
+
+func getClient(db *gorm.DB, id string) (*model.OAuthClient, error) {
+	client := model.OAuthClient{Id: id}
+
+	if err := db.Where(&client).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.OAuthClientNotExist
+		}
+		return nil, err
+	}
+
+	return &client, nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package reconciliation
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+type Query struct {
+	schema.Query
+	Status *model.ReconciliationStatus `json:"status" url:"status" validate:"omitempty" comment:"对账状态"` // 根据对账状态筛选
+}
+
+type ItemQuery struct {
+	schema.Query
+	Currency *string                   `json:"currency" url:"currency" validate:"omitempty" comment:"币种"`  // 根据币种筛选
+	Kind     *model.ReconciliationKind `json:"kind" url:"kind" validate:"omitempty,max=32" comment:"差异类型"` // 根据差异类型筛选
+	Uid      *string                   `json:"uid" url:"uid" validate:"omitempty,max=32" comment:"用户ID"`   // 根据用户筛选
+}
+
+// 获取对账记录列表
+func GetList(c helper.Context, query Query) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Reconciliation, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = validator.ValidateStruct(query); err != nil {
+		return
+	}
+
+	filter := map[string]interface{}{}
+
+	if query.Status != nil {
+		filter["status"] = *query.Status
+	}
+
+	list := make([]model.Reconciliation, 0)
+
+	var total int64
+
+	if err = query.Order(database.Db.Limit(query.Limit).Offset(query.Limit * query.Page)).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.Reconciliation{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.Reconciliation{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+// 获取单次对账的结果
+func Get(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.Reconciliation
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	info := model.Reconciliation{Id: id}
+
+	if err = database.Db.Where(&info).First(&info).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.ReconciliationNotExist
+		}
+		return
+	}
+
+	mapToSchema(info, &data)
+
+	return
+}
+
+// 获取单次对账发现的差异
+func GetItems(c helper.Context, id string, query ItemQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.ReconciliationItem, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = validator.ValidateStruct(query); err != nil {
+		return
+	}
+
+	info := model.Reconciliation{Id: id}
+
+	if err = database.Db.Where(&info).First(&info).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.ReconciliationNotExist
+		}
+		return
+	}
+
+	filter := map[string]interface{}{
+		"reconciliation_id": info.Id,
+	}
+
+	if query.Currency != nil {
+		filter["currency"] = strings.ToUpper(*query.Currency)
+	}
+
+	if query.Kind != nil {
+		filter["kind"] = *query.Kind
+	}
+
+	if query.Uid != nil {
+		filter["uid"] = *query.Uid
+	}
+
+	list := make([]model.ReconciliationItem, 0)
+
+	var total int64
+
+	if err = query.Order(database.Db.Limit(query.Limit).Offset(query.Limit * query.Page)).Where(filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.ReconciliationItem{}).Where(filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.ReconciliationItem{}
+		mapItemToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+var GetListRouter = router.Handler(func(c router.Context) {
+	var (
+		query Query
+	)
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetList(helper.NewContext(&c), query)
+	})
+})
+
+var GetRouter = router.Handler(func(c router.Context) {
+	id := c.Param("reconciliation_id")
+
+	c.ResponseFunc(nil, func() schema.Response {
+		return Get(helper.NewContext(&c), id)
+	})
+})
+
+var GetItemsRouter = router.Handler(func(c router.Context) {
+	var (
+		query ItemQuery
+	)
+
+	id := c.Param("reconciliation_id")
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetItems(helper.NewContext(&c), id, query)
+	})
+})
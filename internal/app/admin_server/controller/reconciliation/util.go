@@ -0,0 +1,40 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package reconciliation
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"time"
+)
+
+func mapToSchema(r model.Reconciliation, d *schema.Reconciliation) {
+	d.Id = r.Id
+	d.Status = r.Status
+	d.Wallets = r.Wallets
+	d.Logs = r.Logs
+	d.Discrepancies = r.Discrepancies
+	d.Error = r.Error
+
+	if r.FinishedAt != nil {
+		finishedAt := r.FinishedAt.Format(time.RFC3339Nano)
+		d.FinishedAt = &finishedAt
+	}
+
+	d.CreatedAt = r.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = r.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+func mapItemToSchema(r model.ReconciliationItem, d *schema.ReconciliationItem) {
+	d.Id = r.Id
+	d.ReconciliationId = r.ReconciliationId
+	d.Currency = r.Currency
+	d.Uid = r.Uid
+	d.Kind = r.Kind
+	d.LogId = r.LogId
+	d.ExpectedBalance = r.ExpectedBalance.String()
+	d.ActualBalance = r.ActualBalance.String()
+	d.ExpectedFrozen = r.ExpectedFrozen.String()
+	d.ActualFrozen = r.ActualFrozen.String()
+	d.CreatedAt = r.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = r.UpdatedAt.Format(time.RFC3339Nano)
+}
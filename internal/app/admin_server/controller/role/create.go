@@ -72,11 +72,6 @@ func Create(c helper.Context, input CreateParams) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.AdminNotSuper
-		return
-	}
-
 	if !accession.Valid(input.Accession) {
 		err = exception.InvalidParams
 		return
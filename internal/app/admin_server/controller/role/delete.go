@@ -61,11 +61,6 @@ func Delete(c helper.Context, roleName string) (res schema.Response) {
 		return
 	}
 
-	if !adminInfo.IsSuper {
-		err = exception.NoPermission
-		return
-	}
-
 	roleInfo := model.Role{
 		Name: roleName,
 	}
@@ -0,0 +1,107 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/invite"
+	"github.com/jinzhu/gorm"
+)
+
+// 会员的实名认证审核通过, 被邀请的会员进入实名认证的阶段, 并发放这个阶段的邀请奖励
+func AuthByAdmin(c helper.Context, userId string) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	if err = tx.First(&model.User{Id: userId}).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	err = invite.Advance(tx, userId, model.StatusInviteAuth)
+
+	return
+}
+
+// 重新发放会员没有结清的邀请奖励, 用于修正奖励规则的配置之后补发
+func RetryInviteRewardByAdmin(c helper.Context, userId string) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	err = invite.Retry(tx, userId)
+
+	return
+}
+
+var AuthByAdminRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return AuthByAdmin(helper.NewContext(&c), c.Param("user_id"))
+	})
+})
+
+var RetryInviteRewardByAdminRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return RetryInviteRewardByAdmin(helper.NewContext(&c), c.Param("user_id"))
+	})
+})
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"github.com/axetroy/go-server/internal/app/admin_server/controller/wallet"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
@@ -13,8 +12,10 @@ import (
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/axetroy/go-server/internal/service/message_queue"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/axetroy/go-server/internal/service/redis"
 	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
@@ -139,10 +140,16 @@ func CreateUser(input CreateUserParams) (res schema.Response) {
 		nickname = input.Nickname
 	}
 
+	hashed, err := password.Generate(input.Password)
+
+	if err != nil {
+		return
+	}
+
 	userInfo := model.User{
 		Username: username,
 		Nickname: nickname,
-		Password: util.GeneratePassword(input.Password),
+		Password: hashed,
 		Status:   model.UserStatusInactivated, // 开始时未激活状态
 		Role:     pq.StringArray{model.DefaultUser.Name},
 		Phone:    input.Phone,
@@ -163,15 +170,8 @@ func CreateUser(input CreateUserParams) (res schema.Response) {
 	data.UpdatedAt = userInfo.UpdatedAt.Format(time.RFC3339Nano)
 
 	// 创建用户对应的钱包账号
-	for _, walletName := range model.Wallets {
-		if err = tx.Table(wallet.GetTableName(walletName)).Create(&model.Wallet{
-			Id:       userInfo.Id,
-			Currency: walletName,
-			Balance:  0,
-			Frozen:   0,
-		}).Error; err != nil {
-			return
-		}
+	if err = currency.CreateWallets(tx, userInfo.Id); err != nil {
+		return
 	}
 
 	// 如果是以邮箱注册的，那么发送激活链接
@@ -6,11 +6,11 @@ import (
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 )
 
@@ -64,12 +64,6 @@ func UpdatePasswordByAdmin(c helper.Context, userId string, input UpdatePassword
 		return
 	}
 
-	// 只有超级管理员才能操作
-	if !adminInfo.IsSuper {
-		err = exception.NoPermission
-		return
-	}
-
 	userInfo := model.User{Id: userId}
 
 	if err = tx.First(&userInfo).Error; err != nil {
@@ -79,7 +73,11 @@ func UpdatePasswordByAdmin(c helper.Context, userId string, input UpdatePassword
 		return
 	}
 
-	newPassword := util.GeneratePassword(input.NewPassword)
+	newPassword, err := password.Generate(input.NewPassword)
+
+	if err != nil {
+		return
+	}
 
 	if err = tx.Model(&userInfo).Update(model.User{Password: newPassword}).Error; err != nil {
 		return
@@ -6,10 +6,10 @@ import (
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/user"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
 	"github.com/axetroy/go-server/internal/library/helper"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/axetroy/go-server/internal/service/token"
 	"github.com/axetroy/go-server/tester"
 	"github.com/axetroy/mocker"
@@ -91,6 +91,6 @@ func TestUpdatePasswordByAdminRouter(t *testing.T) {
 		user := model.User{Id: userInfo.Id}
 
 		assert.Nil(t, database.Db.First(&user).Error)
-		assert.Equal(t, util.GeneratePassword("321321"), user.Password)
+		assert.True(t, password.Verify("321321", user.Password))
 	}
 }
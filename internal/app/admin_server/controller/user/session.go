@@ -0,0 +1,119 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"time"
+)
+
+// 获取会员所有登陆中的会话
+func GetSessionsByAdmin(c helper.Context, userId string) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Session, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	if err = database.Db.First(&model.User{Id: userId}).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	list, err := authentication.Gateway(false).Sessions(userId)
+
+	if err != nil {
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt.After(list[j].LastSeenAt)
+	})
+
+	for _, info := range list {
+		s := schema.Session{
+			Id:        info.Id,
+			Ip:        info.Ip,
+			Device:    info.Device,
+			CreatedAt: info.CreatedAt.Format(time.RFC3339Nano),
+			ExpiredAt: info.ExpiredAt.Format(time.RFC3339Nano),
+		}
+
+		if !info.LastSeenAt.IsZero() {
+			s.LastSeenAt = info.LastSeenAt.Format(time.RFC3339Nano)
+		}
+
+		data = append(data, s)
+	}
+
+	return
+}
+
+// 强制会员下线, 会员所有的 token 立即失效
+func ForceSignOutByAdmin(c helper.Context, userId string) (res schema.Response) {
+	var (
+		err error
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	if err = database.Db.First(&model.User{Id: userId}).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	_, err = authentication.Gateway(false).RemoveAllSessions(userId, "")
+
+	return
+}
+
+var GetSessionsByAdminRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetSessionsByAdmin(helper.NewContext(&c), c.Param("user_id"))
+	})
+})
+
+var ForceSignOutByAdminRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return ForceSignOutByAdmin(helper.NewContext(&c), c.Param("user_id"))
+	})
+})
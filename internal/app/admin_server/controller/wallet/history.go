@@ -0,0 +1,201 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package wallet
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+)
+
+type FinanceLogQuery struct {
+	schema.Query
+	Currency string             `json:"currency" url:"currency" validate:"required" comment:"币种"`   // 币种, 每个币种的流水是分开存放的
+	Type     *model.FinanceType `json:"type" url:"type" validate:"omitempty,max=32" comment:"流水类型"` // 根据流水类型筛选
+}
+
+type TransferLogQuery struct {
+	schema.Query
+	Currency string                `json:"currency" url:"currency" validate:"required" comment:"币种"` // 币种, 每个币种的转账记录是分开存放的
+	Status   *model.TransferStatus `json:"status" url:"status" validate:"omitempty" comment:"转账状态"`  // 根据转账状态筛选
+}
+
+// 获取某个用户的财务流水
+func GetFinanceLogs(c helper.Context, userId string, query FinanceLogQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.FinanceLog, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = validator.ValidateStruct(query); err != nil {
+		return
+	}
+
+	currencyInfo, err := currency.Get(database.Db, query.Currency)
+
+	if err != nil {
+		return
+	}
+
+	tableName := model.FinanceLogTableName(currencyInfo.Code)
+
+	list := make([]model.FinanceLog, 0)
+
+	var total int64
+
+	if database.Db.HasTable(tableName) {
+		filter := map[string]interface{}{
+			"uid": userId,
+		}
+
+		if query.Type != nil {
+			filter["type"] = *query.Type
+		}
+
+		if err = query.Order(database.Db.Table(tableName).Limit(query.Limit).Offset(query.Limit * query.Page)).Where(filter).Find(&list).Error; err != nil {
+			return
+		}
+
+		if err = database.Db.Table(tableName).Where(filter).Count(&total).Error; err != nil {
+			return
+		}
+	}
+
+	for _, v := range list {
+		d := schema.FinanceLog{}
+		mapFinanceLogToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+// 获取某个用户的转账记录, 包括转出和转入
+func GetTransferLogs(c helper.Context, userId string, query TransferLogQuery) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.TransferLog, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = validator.ValidateStruct(query); err != nil {
+		return
+	}
+
+	currencyInfo, err := currency.Get(database.Db, query.Currency)
+
+	if err != nil {
+		return
+	}
+
+	tableName := model.TransferLogTableName(currencyInfo.Code)
+
+	list := make([]model.TransferLog, 0)
+
+	var total int64
+
+	if database.Db.HasTable(tableName) {
+		where := func(db *gorm.DB) *gorm.DB {
+			db = db.Table(tableName).Where(`"from" = ? OR "to" = ?`, userId, userId)
+
+			if query.Status != nil {
+				db = db.Where("status = ?", *query.Status)
+			}
+
+			return db
+		}
+
+		if err = query.Order(where(database.Db).Limit(query.Limit).Offset(query.Limit * query.Page)).Find(&list).Error; err != nil {
+			return
+		}
+
+		if err = where(database.Db).Count(&total).Error; err != nil {
+			return
+		}
+	}
+
+	for _, v := range list {
+		d := schema.TransferLog{}
+		mapTransferLogToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+var GetFinanceLogsRouter = router.Handler(func(c router.Context) {
+	var (
+		query FinanceLogQuery
+	)
+
+	userId := c.Param("user_id")
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetFinanceLogs(helper.NewContext(&c), userId, query)
+	})
+})
+
+var GetTransferLogsRouter = router.Handler(func(c router.Context) {
+	var (
+		query TransferLogQuery
+	)
+
+	userId := c.Param("user_id")
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetTransferLogs(helper.NewContext(&c), userId, query)
+	})
+})
@@ -0,0 +1,191 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package wallet
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/logger"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/ledger"
+	"github.com/jinzhu/gorm"
+	"github.com/kataras/iris/v12"
+	"strings"
+)
+
+type OperateParams struct {
+	Currency string `json:"currency" validate:"required" comment:"币种"`            // 币种
+	Amount   string `json:"amount" validate:"required,numeric,gt=0" comment:"数量"` // 操作的数量, 必须为正数
+	Note     string `json:"note" validate:"required,max=128" comment:"备注"`        // 操作备注, 用于审计, 必填
+}
+
+// 加款: 增加可用余额
+func Credit(c helper.Context, userId string, input OperateParams) (res schema.Response) {
+	return operate(c, userId, input, model.FinanceTypeAdminCredit)
+}
+
+// 扣款: 减少可用余额
+func Debit(c helper.Context, userId string, input OperateParams) (res schema.Response) {
+	return operate(c, userId, input, model.FinanceTypeAdminDebit)
+}
+
+// 冻结: 可用余额转入冻结余额
+func Freeze(c helper.Context, userId string, input OperateParams) (res schema.Response) {
+	return operate(c, userId, input, model.FinanceTypeFreeze)
+}
+
+// 解冻: 冻结余额转回可用余额
+func Unfreeze(c helper.Context, userId string, input OperateParams) (res schema.Response) {
+	return operate(c, userId, input, model.FinanceTypeUnfreeze)
+}
+
+func operate(c helper.Context, userId string, input OperateParams, financeType model.FinanceType) (res schema.Response) {
+	var (
+		err  error
+		data = schema.FinanceLog{}
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				logger.Infof("Admin %s %s wallet of user %s %v", c.Uid, financeType, userId, input)
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	var amount decimal.Decimal
+
+	if amount, err = decimal.NewFromString(input.Amount); err != nil || !amount.IsPositive() {
+		err = exception.InvalidParams
+		return
+	}
+
+	note := strings.TrimSpace(input.Note)
+
+	if note == "" {
+		err = exception.InvalidParams
+		return
+	}
+
+	var balanceMutation, frozenMutation decimal.Decimal
+
+	switch financeType {
+	case model.FinanceTypeAdminCredit:
+		balanceMutation = amount
+	case model.FinanceTypeAdminDebit:
+		balanceMutation = amount.Neg()
+	case model.FinanceTypeFreeze:
+		balanceMutation, frozenMutation = amount.Neg(), amount
+	case model.FinanceTypeUnfreeze:
+		balanceMutation, frozenMutation = amount, amount.Neg()
+	default:
+		err = exception.InvalidParams
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	adminInfo := model.Admin{Id: c.Uid}
+
+	if err = tx.First(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	// 停用的币种仍然允许管理员操作, 以便处理遗留的余额
+	currencyInfo, err := currency.Get(tx, input.Currency)
+
+	if err != nil {
+		return
+	}
+
+	if !currencyInfo.ValidAmount(amount) {
+		err = exception.InvalidParams
+		return
+	}
+
+	userInfo := model.User{Id: userId}
+
+	if err = tx.First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	wallets, err := ledger.Lock(tx, currencyInfo.Code, userId)
+
+	if err != nil {
+		return
+	}
+
+	log, err := ledger.Mutate(tx, wallets[userId], balanceMutation, frozenMutation)
+
+	if err != nil {
+		return
+	}
+
+	log.Type = financeType
+	log.Note = &note
+	log.Operator = &adminInfo.Id
+
+	// 管理员操作的流水没有对应的订单
+	if err = ledger.WriteLogs(tx, currencyInfo.Code, "", log); err != nil {
+		return
+	}
+
+	mapFinanceLogToSchema(*log, &data)
+
+	return
+}
+
+func operateRouter(fn func(c helper.Context, userId string, input OperateParams) schema.Response) iris.Handler {
+	return router.Handler(func(c router.Context) {
+		var (
+			input OperateParams
+		)
+
+		userId := c.Param("user_id")
+
+		c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+			return fn(helper.NewContext(&c), userId, input)
+		})
+	})
+}
+
+var (
+	CreditRouter   = operateRouter(Credit)
+	DebitRouter    = operateRouter(Debit)
+	FreezeRouter   = operateRouter(Freeze)
+	UnfreezeRouter = operateRouter(Unfreeze)
+)
@@ -0,0 +1,215 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package wallet_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/wallet"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/tester"
+	"github.com/axetroy/mocker"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestOperate(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	c := helper.Context{Uid: adminInfo.Id}
+
+	// 备注必填
+	{
+		r := wallet.Credit(c, userInfo.Id, wallet.OperateParams{
+			Currency: model.WalletCNY,
+			Amount:   "100",
+		})
+
+		assert.Equal(t, exception.InvalidParams.Code(), r.Status)
+	}
+
+	// 不支持的币种
+	{
+		r := wallet.Credit(c, userInfo.Id, wallet.OperateParams{
+			Currency: "BTC",
+			Amount:   "100",
+			Note:     "test",
+		})
+
+		assert.Equal(t, exception.InvalidWallet.Error(), r.Message)
+	}
+
+	// 余额不足, 不能扣款
+	{
+		r := wallet.Debit(c, userInfo.Id, wallet.OperateParams{
+			Currency: model.WalletCNY,
+			Amount:   "1",
+			Note:     "test",
+		})
+
+		assert.Equal(t, exception.NotEnoughBalance.Error(), r.Message)
+	}
+
+	steps := []struct {
+		fn              func(c helper.Context, userId string, input wallet.OperateParams) schema.Response
+		amount          string
+		financeType     model.FinanceType
+		balanceMutation string
+		frozenMutation  string
+		afterBalance    string
+		afterFrozen     string
+	}{
+		{wallet.Credit, "100", model.FinanceTypeAdminCredit, "100.00000000", "0.00000000", "100.00000000", "0.00000000"},
+		{wallet.Debit, "20.5", model.FinanceTypeAdminDebit, "-20.50000000", "0.00000000", "79.50000000", "0.00000000"},
+		{wallet.Freeze, "30", model.FinanceTypeFreeze, "-30.00000000", "30.00000000", "49.50000000", "30.00000000"},
+		{wallet.Unfreeze, "10", model.FinanceTypeUnfreeze, "10.00000000", "-10.00000000", "59.50000000", "20.00000000"},
+	}
+
+	for _, step := range steps {
+		r := step.fn(c, userInfo.Id, wallet.OperateParams{
+			Currency: model.WalletCNY,
+			Amount:   step.amount,
+			Note:     "test " + string(step.financeType),
+		})
+
+		log := schema.FinanceLog{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Nil(t, r.Decode(&log))
+		assert.Equal(t, step.financeType, log.Type)
+		assert.Equal(t, userInfo.Id, log.Uid)
+		assert.Equal(t, step.balanceMutation, log.BalanceMutation)
+		assert.Equal(t, step.frozenMutation, log.FrozenMutation)
+		assert.Equal(t, step.afterBalance, log.AfterBalance)
+		assert.Equal(t, step.afterFrozen, log.AfterFrozen)
+		assert.Equal(t, "test "+string(step.financeType), *log.Note)
+		assert.Equal(t, adminInfo.Id, *log.Operator)
+	}
+
+	// 解冻的数量不能超过冻结的数量
+	{
+		r := wallet.Unfreeze(c, userInfo.Id, wallet.OperateParams{
+			Currency: model.WalletCNY,
+			Amount:   "21",
+			Note:     "test",
+		})
+
+		assert.Equal(t, exception.NotEnoughFrozen.Error(), r.Message)
+	}
+
+	// 查询流水
+	{
+		r := wallet.GetFinanceLogs(c, userInfo.Id, wallet.FinanceLogQuery{
+			Currency: model.WalletCNY,
+		})
+
+		list := make([]schema.FinanceLog, 0)
+
+		assert.Equal(t, "", r.Message)
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Nil(t, r.Decode(&list))
+		assert.Len(t, list, len(steps))
+		assert.Equal(t, int64(len(steps)), r.Meta.Total)
+	}
+}
+
+func TestOperateWithoutAccession(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	hashed, err := password.Generate("123123")
+
+	assert.Nil(t, err)
+
+	adminInfo := model.Admin{
+		Username:  "test-" + util.RandomString(6),
+		Name:      "test",
+		Password:  hashed,
+		Accession: []string{accession.AdminWalletGet.Name},
+	}
+
+	assert.Nil(t, database.Db.Create(&adminInfo).Error)
+
+	defer database.DeleteRowByTable("admin", "id", adminInfo.Id)
+
+	t2, err := authentication.Gateway(true).Generate(adminInfo.Id)
+
+	assert.Nil(t, err)
+
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + t2.Token,
+	}
+
+	// 只有查看权限, 不能加款. 权限由路由的中间件校验
+	{
+		body, _ := json.Marshal(&wallet.OperateParams{
+			Currency: model.WalletCNY,
+			Amount:   "100",
+			Note:     "test",
+		})
+
+		res := schema.Response{}
+
+		assert.Nil(t, json.Unmarshal(tester.HttpAdmin.Post("/v1/wallet/"+userInfo.Id+"/credit", body, &header).Body.Bytes(), &res))
+		assert.Equal(t, exception.NoPermission.Error(), res.Message)
+	}
+
+	{
+		res := schema.Response{}
+
+		assert.Nil(t, json.Unmarshal(tester.HttpAdmin.Get("/v1/wallet/"+userInfo.Id+"/transfer?currency="+model.WalletCNY, nil, &header).Body.Bytes(), &res))
+		assert.Equal(t, "", res.Message)
+		assert.Equal(t, schema.StatusSuccess, res.Status)
+	}
+}
+
+func TestCreditRouter(t *testing.T) {
+	adminInfo, _ := tester.LoginAdmin()
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + adminInfo.Token,
+	}
+
+	body, _ := json.Marshal(&wallet.OperateParams{
+		Currency: model.WalletCNY,
+		Amount:   "100",
+		Note:     "test",
+	})
+
+	r := tester.HttpAdmin.Post("/v1/wallet/"+userInfo.Id+"/credit", body, &header)
+
+	if !assert.Equal(t, http.StatusOK, r.Code) {
+		return
+	}
+
+	res := schema.Response{}
+
+	if !assert.Nil(t, json.Unmarshal(r.Body.Bytes(), &res)) {
+		return
+	}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+
+	log := schema.FinanceLog{}
+
+	assert.Nil(t, res.Decode(&log))
+	assert.Equal(t, model.FinanceTypeAdminCredit, log.Type)
+	assert.Equal(t, "100.00000000", log.AfterBalance)
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
 	"reflect"
 	"strings"
+	"time"
 )
 
 func GetTableName(currency string) string {
-	return "wallet_" + strings.ToLower(currency)
+	return model.WalletTableName(currency)
 }
 
 type QueryParams struct {
@@ -18,7 +20,8 @@ type QueryParams struct {
 	Currency *string `json:"currency"` // 钱包币种
 }
 
-func GenerateWalletSQL(filter QueryParams, limit int, count bool) string {
+// currencies 为需要查询的币种, 通常是币种注册表中的所有币种
+func GenerateWalletSQL(filter QueryParams, currencies []string, limit int, count bool) string {
 	suffix := `("deleted_at" IS NULL OR "deleted_at"='0001-01-01 00:00:00')`
 
 	filterArray := make([]string, 0)
@@ -69,11 +72,41 @@ func GenerateWalletSQL(filter QueryParams, limit int, count bool) string {
 		selected = "COUNT(*)"
 	}
 
-	for _, tableName := range model.WalletTableNames {
-		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, tableName, filterStr, suffix)
+	for _, currency := range currencies {
+		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, GetTableName(currency), filterStr, suffix)
 		SQLs = append(SQLs, sql)
 	}
 
 	sql := fmt.Sprintf("%s LIMIT %d", strings.Join(SQLs[:], " UNION "), limit)
 	return sql
 }
+
+func mapFinanceLogToSchema(log model.FinanceLog, d *schema.FinanceLog) {
+	d.Id = log.Id
+	d.Currency = log.Currency
+	d.OrderId = log.OrderId
+	d.Uid = log.Uid
+	d.BeforeBalance = log.BeforeBalance.String()
+	d.BalanceMutation = log.BalanceMutation.String()
+	d.AfterBalance = log.AfterBalance.String()
+	d.BeforeFrozen = log.BeforeFrozen.String()
+	d.FrozenMutation = log.FrozenMutation.String()
+	d.AfterFrozen = log.AfterFrozen.String()
+	d.Type = log.Type
+	d.Note = log.Note
+	d.Operator = log.Operator
+	d.CreatedAt = log.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = log.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+func mapTransferLogToSchema(log model.TransferLog, d *schema.TransferLog) {
+	d.Id = log.Id
+	d.Currency = log.Currency
+	d.From = log.From
+	d.To = log.To
+	d.Amount = log.Amount.String()
+	d.Status = log.Status
+	d.Note = log.Note
+	d.CreatedAt = log.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = log.UpdatedAt.Format(time.RFC3339Nano)
+}
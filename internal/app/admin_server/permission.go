@@ -0,0 +1,28 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin_server
+
+import (
+	"github.com/axetroy/go-server/internal/middleware"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// 声明路由需要的权限, 拥有其中任意一个即可
+// 权限校验在 Token 校验之后, 业务处理之前执行, 同时登记到路由权限表中, 供前端查询
+func require(route *router.Route, accessions ...*accession.Accession) *router.Route {
+	list := make([]accession.Accession, 0, len(accessions))
+
+	for _, a := range accessions {
+		list = append(list, *a)
+	}
+
+	handlers := route.Handlers
+
+	// 最后一个是业务处理的 handler, 权限校验插入到它的前面
+	route.Handlers = append(append(handlers[:len(handlers)-1:len(handlers)-1], middleware.AdminPermission(list...)), handlers[len(handlers)-1])
+
+	rbac.RegisterAdminRoute(route.Method, route.Tmpl().Src, list...)
+
+	return route
+}
@@ -0,0 +1,145 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package admin_server_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/admin_server"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/admin"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/tester"
+	"github.com/axetroy/mocker"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 不需要权限的路由, 登陆之后所有的管理员都可以访问
+var publicRoutes = map[string]bool{
+	"GET /v1":                           true,
+	"POST /v1/login":                    true,
+	"POST /v1/login/totp":               true,
+	"POST /v1/token/refresh":            true,
+	"GET /v1/profile":                   true,
+	"PUT /v1/password":                  true,
+	"POST /v1/totp":                     true,
+	"POST /v1/totp/enable":              true,
+	"POST /v1/totp/disable":             true,
+	"POST /v1/totp/recovery":            true,
+	"POST /v1/login/webauthn/options":   true,
+	"POST /v1/login/webauthn":           true,
+	"GET /v1/webauthn":                  true,
+	"POST /v1/webauthn/options":         true,
+	"POST /v1/webauthn":                 true,
+	"PUT /v1/webauthn/{webauthn_id}":    true,
+	"DELETE /v1/webauthn/{webauthn_id}": true,
+	"GET /v1/admin/accession":           true,
+	"GET /v1/admin/route":               true,
+	"GET /v1/area/provinces":            true,
+	"GET /v1/area/{code}":               true,
+	"GET /v1/area/{code}/children":      true,
+	"GET /v1/area":                      true,
+}
+
+func TestRoutesHaveAccession(t *testing.T) {
+	registered := map[string]bool{}
+
+	for _, r := range rbac.AdminRoutes() {
+		registered[r.Method+" "+r.Path] = true
+		assert.NotEmpty(t, r.Accession, r.Path)
+	}
+
+	for _, r := range admin_server.AdminRouter.GetRoutes() {
+		if !strings.HasPrefix(r.Tmpl().Src, "/v1") || r.Method == http.MethodOptions {
+			continue
+		}
+
+		key := r.Method + " " + r.Tmpl().Src
+
+		if publicRoutes[key] {
+			continue
+		}
+
+		assert.True(t, registered[key], "route %s does not declare its accession", key)
+	}
+}
+
+func TestAdminPermission(t *testing.T) {
+	input := admin.CreateAdminParams{
+		Account:  "test-TestAdminPermission",
+		Name:     "test-TestAdminPermission",
+		Password: "123123",
+	}
+
+	r := admin.CreateAdmin(input, false)
+
+	assert.Equal(t, "", r.Message)
+
+	defer admin.DeleteAdminByAccount(input.Account)
+
+	profile := schema.AdminProfile{}
+
+	assert.Nil(t, r.Decode(&profile))
+
+	r = admin.Login(helper.Context{}, admin.SignInParams{Username: input.Account, Password: input.Password})
+
+	adminInfo := schema.AdminProfileWithToken{}
+
+	assert.Nil(t, r.Decode(&adminInfo))
+
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + adminInfo.Token,
+	}
+
+	// 没有权限
+	{
+		res := schema.Response{}
+
+		assert.Nil(t, json.Unmarshal(tester.HttpAdmin.Get("/v1/news", nil, &header).Body.Bytes(), &res))
+		assert.Equal(t, exception.NoPermission.Code(), res.Status)
+		assert.Equal(t, exception.NoPermission.Error(), res.Message)
+	}
+
+	// 路由列表中标记为不可访问
+	getAllowed := func() map[string]bool {
+		res := schema.Response{}
+		routes := make([]schema.AdminRoute, 0)
+
+		assert.Nil(t, json.Unmarshal(tester.HttpAdmin.Get("/v1/admin/route", nil, &header).Body.Bytes(), &res))
+		assert.Equal(t, schema.StatusSuccess, res.Status)
+		assert.Nil(t, res.Decode(&routes))
+
+		allowed := map[string]bool{}
+
+		for _, route := range routes {
+			allowed[route.Method+" "+route.Path] = route.Allowed
+		}
+
+		return allowed
+	}
+
+	assert.False(t, getAllowed()["GET /v1/news"])
+
+	// 授予权限
+	assert.Nil(t, database.Db.Model(&model.Admin{Id: profile.Id}).Update("accession", pq.StringArray{accession.AdminNewsGet.Name}).Error)
+
+	{
+		res := schema.Response{}
+
+		assert.Nil(t, json.Unmarshal(tester.HttpAdmin.Get("/v1/news", nil, &header).Body.Bytes(), &res))
+		assert.Equal(t, schema.StatusSuccess, res.Status)
+	}
+
+	allowed := getAllowed()
+
+	assert.True(t, allowed["GET /v1/news"])
+	assert.False(t, allowed["POST /v1/news"])
+}
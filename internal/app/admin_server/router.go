@@ -7,24 +7,32 @@ import (
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/area"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/banner"
 	Configuration "github.com/axetroy/go-server/internal/app/admin_server/controller/config"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/currency"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/exchange"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/help"
 	loginLog "github.com/axetroy/go-server/internal/app/admin_server/controller/logger/login"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/menu"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/message"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/news"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/notification"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/oauth_client"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/push"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/reconciliation"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/report"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/role"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/system"
 	"github.com/axetroy/go-server/internal/app/admin_server/controller/user"
+	"github.com/axetroy/go-server/internal/app/admin_server/controller/wallet"
 	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/middleware"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/pkg/rate_limit"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
 	"net/http"
+	"time"
 )
 
 var AdminRouter *iris.Application
@@ -47,7 +55,7 @@ func init() {
 		v1.Use(recover.New())
 		v1.Use(middleware.Common())
 		v1.Use(middleware.CORS())
-		v1.Use(middleware.RateLimit(30))
+		v1.Use(middleware.RateLimit("admin", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 30})) // 每个 IP 每秒 5 个请求, 最多允许 30 个请求的突发
 
 		if config.Common.Mode != "production" {
 			v1.Use(logger.New())
@@ -63,139 +71,214 @@ func init() {
 		adminAuthMiddleware := middleware.AuthenticateNew(true) // 管理员Token的中间件
 
 		// 登陆
-		v1.Post("/login", admin.LoginRouter) // 管理员登陆
+		v1.Post("/login", admin.LoginRouter)                                 // 管理员登陆
+		v1.Post("/login/totp", admin.LoginWithTOTPRouter)                    // 开启了双重身份认证的管理员, 登陆的第二步
+		v1.Post("/login/webauthn/options", admin.LoginWebAuthnOptionsRouter) // 注册了通行密钥的管理员, 登陆的第二步, 获取认证参数
+		v1.Post("/login/webauthn", admin.LoginWithWebAuthnRouter)            // 注册了通行密钥的管理员, 登陆的第二步, 提交认证器的签名
+		v1.Post("/token/refresh", admin.RefreshTokenRouter)                  // 使用刷新令牌换取新的身份令牌
 
 		v1.Use(adminAuthMiddleware)
 
 		v1.Get("/profile", adminAuthMiddleware, admin.GetAdminInfoRouter)    // 获取管理员自己的信息
 		v1.Put("/password", adminAuthMiddleware, admin.UpdatePasswordRouter) // 更改自己的密码
 
+		// 双重身份认证
+		{
+			totpRouter := v1.Party("/totp")
+			totpRouter.Post("", admin.EnrollTOTPRouter)                           // 生成新的密钥, 返回 otpauth:// 地址和二维码
+			totpRouter.Post("/enable", admin.EnableTOTPRouter)                    // 用第一个动态密码确认开启, 返回恢复码
+			totpRouter.Post("/disable", admin.DisableTOTPRouter)                  // 关闭双重身份认证
+			totpRouter.Post("/recovery", admin.RegenerateTOTPRecoveryCodesRouter) // 重新生成恢复码
+		}
+
+		// 通行密钥, 用于登陆时的第二步验证
+		{
+			webAuthnRouter := v1.Party("/webauthn")
+			webAuthnRouter.Get("", admin.GetWebAuthnListRouter)                  // 获取已注册的通行密钥
+			webAuthnRouter.Post("/options", admin.RegisterWebAuthnOptionsRouter) // 注册的第一步, 获取注册参数
+			webAuthnRouter.Post("", admin.RegisterWebAuthnRouter)                // 注册的第二步, 提交认证器创建的凭证
+			webAuthnRouter.Put("/{webauthn_id}", admin.RenameWebAuthnRouter)     // 重命名通行密钥
+			webAuthnRouter.Delete("/{webauthn_id}", admin.DeleteWebAuthnRouter)  // 删除通行密钥
+		}
+
 		// 管理员类
 		{
 			adminRouter := v1.Party("/admin")
-			adminRouter.Post("", admin.CreateAdminRouter)                  // 创建管理员
-			adminRouter.Get("", admin.GetListRouter)                       // 获取管理员列表
-			adminRouter.Get("/accession", admin.GetAccessionRouter)        // 获取管理员的所有权限列表
-			adminRouter.Get("/{admin_id}", admin.GetAdminInfoByIdRouter)   // 获取某个管理员的信息
-			adminRouter.Put("/{admin_id}", admin.UpdateRouter)             // 修改某个管理员的信息
-			adminRouter.Delete("/{admin_id}", admin.DeleteAdminByIdRouter) // 修改某个管理员的信息
+			require(adminRouter.Post("", admin.CreateAdminRouter), accession.AdminAdminCreate)                  // 创建管理员
+			require(adminRouter.Get("", admin.GetListRouter), accession.AdminAdminGet)                          // 获取管理员列表
+			adminRouter.Get("/accession", admin.GetAccessionRouter)                                             // 获取管理员的所有权限列表
+			adminRouter.Get("/route", admin.GetRoutesRouter)                                                    // 获取需要权限的路由, 以及自己是否可以访问
+			require(adminRouter.Get("/{admin_id}", admin.GetAdminInfoByIdRouter), accession.AdminAdminGet)      // 获取某个管理员的信息
+			require(adminRouter.Put("/{admin_id}", admin.UpdateRouter), accession.AdminAdminUpdate)             // 修改某个管理员的信息
+			require(adminRouter.Delete("/{admin_id}", admin.DeleteAdminByIdRouter), accession.AdminAdminDelete) // 修改某个管理员的信息
 		}
 
 		// 用户类
 		{
 			userRouter := v1.Party("/user")
-			userRouter.Get("", user.GetListRouter)                                  // 获取会员列表
-			userRouter.Post("", user.CreateUserRouter)                              // 创建会员
-			userRouter.Get("/{user_id}", user.GetProfileByAdminRouter)              // 获取单个会员的信息
-			userRouter.Put("/{user_id}/password", user.UpdatePasswordByAdminRouter) // 修改会员密码
-			userRouter.Put("/{user_id}", user.UpdateProfileByAdminRouter)           // 更新会员信息
-			userRouter.Put("/{user_id}/role", role.UpdateUserRoleRouter)            // 修改用户的角色
+			require(userRouter.Get("", user.GetListRouter), accession.AdminUserGet)                                      // 获取会员列表
+			require(userRouter.Post("", user.CreateUserRouter), accession.AdminUserCreate)                               // 创建会员
+			require(userRouter.Get("/{user_id}", user.GetProfileByAdminRouter), accession.AdminUserGet)                  // 获取单个会员的信息
+			require(userRouter.Put("/{user_id}/password", user.UpdatePasswordByAdminRouter), accession.AdminUserUpdate)  // 修改会员密码
+			require(userRouter.Put("/{user_id}", user.UpdateProfileByAdminRouter), accession.AdminUserUpdate)            // 更新会员信息
+			require(userRouter.Put("/{user_id}/role", role.UpdateUserRoleRouter), accession.AdminUserUpdate)             // 修改用户的角色
+			require(userRouter.Get("/{user_id}/session", user.GetSessionsByAdminRouter), accession.AdminUserGet)         // 获取会员登陆中的会话
+			require(userRouter.Delete("/{user_id}/session", user.ForceSignOutByAdminRouter), accession.AdminUserUpdate)  // 强制会员下线
+			require(userRouter.Put("/{user_id}/auth", user.AuthByAdminRouter), accession.AdminUserUpdate)                // 会员的实名认证审核通过
+			require(userRouter.Put("/{user_id}/invite", user.RetryInviteRewardByAdminRouter), accession.AdminUserUpdate) // 补发会员没有结清的邀请奖励
+		}
+
+		// 币种
+		{
+			currencyRouter := v1.Party("/currency")
+			require(currencyRouter.Get("", currency.GetListRouter), accession.AdminCurrencyGet)          // 获取币种列表
+			require(currencyRouter.Post("", currency.CreateRouter), accession.AdminCurrencyCreate)       // 添加新币种
+			require(currencyRouter.Get("/{code}", currency.GetRouter), accession.AdminCurrencyGet)       // 获取币种详情
+			require(currencyRouter.Put("/{code}", currency.UpdateRouter), accession.AdminCurrencyUpdate) // 修改币种 (名称/是否启用)
+		}
+
+		// 币种兑换
+		{
+			exchangeRouter := v1.Party("/exchange")
+			require(exchangeRouter.Get("/rate", exchange.GetRateListRouter), accession.AdminExchangeGet) // 获取所有的汇率
+			require(exchangeRouter.Put("/rate", exchange.SetRateRouter), accession.AdminExchangeUpdate)  // 设置汇率, 不存在则创建
+		}
+
+		// 钱包对账
+		{
+			reconciliationRouter := v1.Party("/reconciliation")
+			require(reconciliationRouter.Get("", reconciliation.GetListRouter), accession.AdminReconciliationGet)                           // 获取对账记录列表
+			require(reconciliationRouter.Get("/{reconciliation_id}", reconciliation.GetRouter), accession.AdminReconciliationGet)           // 获取单次对账的结果
+			require(reconciliationRouter.Get("/{reconciliation_id}/item", reconciliation.GetItemsRouter), accession.AdminReconciliationGet) // 获取单次对账发现的差异
+		}
+
+		// 用户钱包
+		{
+			walletRouter := v1.Party("/wallet/{user_id}")
+			require(walletRouter.Get("/finance", wallet.GetFinanceLogsRouter), accession.AdminWalletGet)   // 获取用户的财务流水
+			require(walletRouter.Get("/transfer", wallet.GetTransferLogsRouter), accession.AdminWalletGet) // 获取用户的转账记录
+			require(walletRouter.Post("/credit", wallet.CreditRouter), accession.AdminWalletUpdate)        // 给用户加款
+			require(walletRouter.Post("/debit", wallet.DebitRouter), accession.AdminWalletUpdate)          // 给用户扣款
+			require(walletRouter.Post("/freeze", wallet.FreezeRouter), accession.AdminWalletUpdate)        // 冻结用户的余额
+			require(walletRouter.Post("/unfreeze", wallet.UnfreezeRouter), accession.AdminWalletUpdate)    // 解冻用户的余额
+		}
+
+		// 接入的第三方应用, 使用本平台的帐号登陆
+		{
+			clientRouter := v1.Party("/oauth_client")
+			require(clientRouter.Get("", oauth_client.GetListRouter), accession.AdminOAuthClientGet)                           // 获取应用列表
+			require(clientRouter.Post("", oauth_client.CreateRouter), accession.AdminOAuthClientCreate)                        // 登记新的应用
+			require(clientRouter.Get("/{client_id}", oauth_client.GetRouter), accession.AdminOAuthClientGet)                   // 获取应用详情
+			require(clientRouter.Put("/{client_id}", oauth_client.UpdateRouter), accession.AdminOAuthClientUpdate)             // 修改应用
+			require(clientRouter.Delete("/{client_id}", oauth_client.DeleteRouter), accession.AdminOAuthClientDelete)          // 删除应用
+			require(clientRouter.Put("/{client_id}/secret", oauth_client.ResetSecretRouter), accession.AdminOAuthClientUpdate) // 重置应用密钥
 		}
 
 		// 用户角色
 		{
 			roleRouter := v1.Party("/role")
-			roleRouter.Get("", role.GetListRouter)                // 获取角色列表
-			roleRouter.Post("", role.CreateRouter)                // 创建角色
-			roleRouter.Put("/{name}", role.UpdateRouter)          // 修改角色
-			roleRouter.Delete("/{name}", role.DeleteRouter)       // 删除角色
-			roleRouter.Get("/{name}", role.GetRouter)             // 获取角色详情
-			roleRouter.Get("/accession", role.GetAccessionRouter) // 获取用户的所有的权限列表
+			require(roleRouter.Get("", role.GetListRouter), accession.AdminRoleGet)                // 获取角色列表
+			require(roleRouter.Post("", role.CreateRouter), accession.AdminRoleCreate)             // 创建角色
+			require(roleRouter.Put("/{name}", role.UpdateRouter), accession.AdminRoleUpdate)       // 修改角色
+			require(roleRouter.Delete("/{name}", role.DeleteRouter), accession.AdminRoleDelete)    // 删除角色
+			require(roleRouter.Get("/{name}", role.GetRouter), accession.AdminRoleGet)             // 获取角色详情
+			require(roleRouter.Get("/accession", role.GetAccessionRouter), accession.AdminRoleGet) // 获取用户的所有的权限列表
 		}
 
 		// 新闻咨询类
 		{
 			newsRouter := v1.Party("/news")
-			newsRouter.Post("", news.CreateRouter)             // 新建新闻公告
-			newsRouter.Get("", news.GetNewsListRouter)         // 获取新闻列表
-			newsRouter.Get("/{news_id}", news.GetNewsRouter)   // 获取新闻详情
-			newsRouter.Put("/{news_id}", news.UpdateRouter)    // 更新新闻公告
-			newsRouter.Delete("/{news_id}", news.DeleteRouter) // 删除新闻
+			require(newsRouter.Post("", news.CreateRouter), accession.AdminNewsCreate)             // 新建新闻公告
+			require(newsRouter.Get("", news.GetNewsListRouter), accession.AdminNewsGet)            // 获取新闻列表
+			require(newsRouter.Get("/{news_id}", news.GetNewsRouter), accession.AdminNewsGet)      // 获取新闻详情
+			require(newsRouter.Put("/{news_id}", news.UpdateRouter), accession.AdminNewsUpdate)    // 更新新闻公告
+			require(newsRouter.Delete("/{news_id}", news.DeleteRouter), accession.AdminNewsDelete) // 删除新闻
 		}
 
 		// 系统通知
 		{
 			notificationRouter := v1.Party("/notification")
-			notificationRouter.Post("", notification.CreateRouter)                    // 创建系统通知
-			notificationRouter.Get("", notification.GetNotificationListByAdminRouter) // 获取系统通知列表
-			notificationRouter.Put("/{id}", notification.UpdateRouter)                // 更新系统通知
-			notificationRouter.Delete("/{id}", notification.DeleteRouter)             // 删除系统通知
-			notificationRouter.Get("/{id}", notification.GetRouter)                   // 获取单条系统通知
+			require(notificationRouter.Post("", notification.CreateRouter), accession.AdminNotificationCreate)                 // 创建系统通知
+			require(notificationRouter.Get("", notification.GetNotificationListByAdminRouter), accession.AdminNotificationGet) // 获取系统通知列表
+			require(notificationRouter.Put("/{id}", notification.UpdateRouter), accession.AdminNotificationUpdate)             // 更新系统通知
+			require(notificationRouter.Delete("/{id}", notification.DeleteRouter), accession.AdminNotificationDelete)          // 删除系统通知
+			require(notificationRouter.Get("/{id}", notification.GetRouter), accession.AdminNotificationGet)                   // 获取单条系统通知
 		}
 
 		// 个人消息
 		{
 			messageRouter := v1.Party("/message")
-			messageRouter.Post("", message.CreateRouter)                       // 创建个人消息
-			messageRouter.Get("", message.GetMessageListByAdminRouter)         // 获取消息列表
-			messageRouter.Get("/{message_id}", message.GetAdminRouter)         // 获取个人消息
-			messageRouter.Put("/{message_id}", message.UpdateRouter)           // 更新个人消息
-			messageRouter.Delete("/{message_id}", message.DeleteByAdminRouter) // 删除个人消息
+			require(messageRouter.Post("", message.CreateRouter), accession.AdminMessageCreate)                       // 创建个人消息
+			require(messageRouter.Get("", message.GetMessageListByAdminRouter), accession.AdminMessageGet)            // 获取消息列表
+			require(messageRouter.Get("/{message_id}", message.GetAdminRouter), accession.AdminMessageGet)            // 获取个人消息
+			require(messageRouter.Put("/{message_id}", message.UpdateRouter), accession.AdminMessageUpdate)           // 更新个人消息
+			require(messageRouter.Delete("/{message_id}", message.DeleteByAdminRouter), accession.AdminMessageDelete) // 删除个人消息
 		}
 
 		// 用户反馈
 		{
 			reportRouter := v1.Party("/report")
 			reportRouter.Use(adminAuthMiddleware)
-			reportRouter.Get("/type", report.GetTypesRouter)                // 获取类型列表
-			reportRouter.Get("", report.GetListByAdminRouter)               // 获取我的反馈列表
-			reportRouter.Get("/{report_id}", report.GetReportByAdminRouter) // 获取反馈详情
-			reportRouter.Put("/{report_id}", report.UpdateByAdminRouter)    // 更新用户反馈
+			require(reportRouter.Get("/type", report.GetTypesRouter), accession.AdminReportGet)                // 获取类型列表
+			require(reportRouter.Get("", report.GetListByAdminRouter), accession.AdminReportGet)               // 获取我的反馈列表
+			require(reportRouter.Get("/{report_id}", report.GetReportByAdminRouter), accession.AdminReportGet) // 获取反馈详情
+			require(reportRouter.Put("/{report_id}", report.UpdateByAdminRouter), accession.AdminReportUpdate) // 更新用户反馈
 		}
 
 		// 帮助中心
 		{
 			helpRouter := v1.Party("/help")
-			helpRouter.Get("", help.GetHelpListRouter)         // 创建帮助列表
-			helpRouter.Post("", help.CreateRouter)             // 创建帮助
-			helpRouter.Put("/{help_id}", help.UpdateRouter)    // 更新帮助
-			helpRouter.Get("/{help_id}", help.GetHelpRouter)   // 获取帮助详情
-			helpRouter.Delete("/{help_id}", help.DeleteRouter) // 删除帮助
+			require(helpRouter.Get("", help.GetHelpListRouter), accession.AdminHelpGet)            // 创建帮助列表
+			require(helpRouter.Post("", help.CreateRouter), accession.AdminHelpCreate)             // 创建帮助
+			require(helpRouter.Put("/{help_id}", help.UpdateRouter), accession.AdminHelpUpdate)    // 更新帮助
+			require(helpRouter.Get("/{help_id}", help.GetHelpRouter), accession.AdminHelpGet)      // 获取帮助详情
+			require(helpRouter.Delete("/{help_id}", help.DeleteRouter), accession.AdminHelpDelete) // 删除帮助
 		}
 
 		// Banner
 		{
 			bannerRouter := v1.Party("/banner")
-			bannerRouter.Get("", banner.GetBannerListRouter)         // 获取 banner 列表
-			bannerRouter.Post("", banner.CreateRouter)               // 创建 banner
-			bannerRouter.Put("/{banner_id}", banner.UpdateRouter)    // 更新 banner
-			bannerRouter.Get("/{banner_id}", banner.GetBannerRouter) // 获取 banner 详情
-			bannerRouter.Delete("/{banner_id}", banner.DeleteRouter) // 删除 banner
+			require(bannerRouter.Get("", banner.GetBannerListRouter), accession.AdminBannerGet)            // 获取 banner 列表
+			require(bannerRouter.Post("", banner.CreateRouter), accession.AdminBannerCreate)               // 创建 banner
+			require(bannerRouter.Put("/{banner_id}", banner.UpdateRouter), accession.AdminBannerUpdate)    // 更新 banner
+			require(bannerRouter.Get("/{banner_id}", banner.GetBannerRouter), accession.AdminBannerGet)    // 获取 banner 详情
+			require(bannerRouter.Delete("/{banner_id}", banner.DeleteRouter), accession.AdminBannerDelete) // 删除 banner
 		}
 
 		// 后台管理员菜单
 		{
 			menuRouter := v1.Party("/menu")
-			menuRouter.Get("", menu.GetListRouter)             // 获取菜单列表
-			menuRouter.Post("", menu.CreateRouter)             // 创建菜单
-			menuRouter.Get("/tree", menu.CreateFromTreeRouter) // 创建菜单
-			menuRouter.Put("/{menu_id}", menu.UpdateRouter)    // 更新菜单
-			menuRouter.Get("/{menu_id}", menu.GetMenuRouter)   // 获取菜单详情
-			menuRouter.Delete("/{menu_id}", menu.DeleteRouter) // 删除菜单
+			require(menuRouter.Get("", menu.GetListRouter), accession.AdminMenuGet)                // 获取菜单列表
+			require(menuRouter.Post("", menu.CreateRouter), accession.AdminMenuCreate)             // 创建菜单
+			require(menuRouter.Get("/tree", menu.CreateFromTreeRouter), accession.AdminMenuCreate) // 创建菜单
+			require(menuRouter.Put("/{menu_id}", menu.UpdateRouter), accession.AdminMenuUpdate)    // 更新菜单
+			require(menuRouter.Get("/{menu_id}", menu.GetMenuRouter), accession.AdminMenuGet)      // 获取菜单详情
+			require(menuRouter.Delete("/{menu_id}", menu.DeleteRouter), accession.AdminMenuDelete) // 删除菜单
 		}
 
 		// 日志
 		{
 			logRouter := v1.Party("/log")
-			logRouter.Get("/login", loginLog.GetLoginLogsRouter)         // 获取用户的登陆日志列表
-			logRouter.Get("/login/{log_id}", loginLog.GetLoginLogRouter) // 用户单条登陆记录
+			require(logRouter.Get("/login", loginLog.GetLoginLogsRouter), accession.AdminLogGet)         // 获取用户的登陆日志列表
+			require(logRouter.Get("/login/{log_id}", loginLog.GetLoginLogRouter), accession.AdminLogGet) // 用户单条登陆记录
 		}
 
 		// 配置项
 		{
 			configRouter := v1.Party("/config")
-			configRouter.Get("/", Configuration.GetListRouter)             // 获取配置列表
-			configRouter.Get("/name", Configuration.GetNameRouter)         // 获取配置名称列表 (查看有哪些配置)
-			configRouter.Get("/:config_name", Configuration.GetRouter)     // 获取指定的配置
-			configRouter.Post("/:config_name", Configuration.CreateRouter) // 创建指定的配置
-			configRouter.Put("/:config_name", Configuration.UpdateRouter)  // 更新指定的配置
+			require(configRouter.Get("/", Configuration.GetListRouter), accession.AdminConfigGet)                            // 获取配置列表
+			require(configRouter.Get("/name", Configuration.GetNameRouter), accession.AdminConfigGet)                        // 获取配置名称列表 (查看有哪些配置)
+			require(configRouter.Get("/oauth/provider", Configuration.GetOAuthProviderListRouter), accession.AdminConfigGet) // 获取第三方登陆的服务提供商和可用状态
+			require(configRouter.Get("/:config_name", Configuration.GetRouter), accession.AdminConfigGet)                    // 获取指定的配置
+			require(configRouter.Post("/:config_name", Configuration.CreateRouter), accession.AdminConfigUpdate)             // 创建指定的配置
+			require(configRouter.Put("/:config_name", Configuration.UpdateRouter), accession.AdminConfigUpdate)              // 更新指定的配置
 		}
 
 		// 推送
 		{
-			configRouter := v1.Party("/push")
-			configRouter.Get("/notification", push.CreateNotificationRouter)  // TODO: 获取推送列表
-			configRouter.Post("/notification", push.CreateNotificationRouter) // 生成一个推送到指定用户
+			pushRouter := v1.Party("/push")
+			require(pushRouter.Get("/notification", push.CreateNotificationRouter), accession.AdminPushCreate)  // TODO: 获取推送列表
+			require(pushRouter.Post("/notification", push.CreateNotificationRouter), accession.AdminPushCreate) // 生成一个推送到指定用户
 		}
 
 		// 地区接口
@@ -207,7 +290,7 @@ func init() {
 			areaRouter.Get("", area.GetArea)                     // 获取所有地区
 		}
 
-		v1.Get("/system", system.GetSystemInfoRouter) // 获取系统相关信息
+		require(v1.Get("/system", system.GetSystemInfoRouter), accession.AdminSystemGet) // 获取系统相关信息
 	}
 
 	_ = app.Build()
@@ -0,0 +1,69 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+)
+
+// 读取配置中心中客服的技能, 接待人数和营业时间, 没有配置则所有客服都没有技能
+func loadRouting() (*model.ConfigFieldCustomerService, error) {
+	c := model.Config{Name: model.ConfigFieldNameCustomerService.Field}
+
+	routing := model.ConfigFieldCustomerService{}
+
+	if err := database.Db.Model(&c).Where(&c).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &routing, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(c.Fields), &routing); err != nil {
+		return nil, err
+	}
+
+	return &routing, nil
+}
+
+// 获取客服的接待能力
+func getAbility(waiterID string) (ws.Ability, error) {
+	routing, err := loadRouting()
+
+	if err != nil {
+		return ws.Ability{}, err
+	}
+
+	for _, waiter := range routing.Waiters {
+		if waiter.Uid == waiterID {
+			return ws.Ability{
+				Skills:   waiter.Skills,
+				Capacity: waiter.Capacity,
+			}, nil
+		}
+	}
+
+	return ws.Ability{}, nil
+}
+
+// 是否有客服拥有该技能
+func hasSkill(topic string) (bool, error) {
+	routing, err := loadRouting()
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, waiter := range routing.Waiters {
+		for _, skill := range waiter.Skills {
+			if skill == topic {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
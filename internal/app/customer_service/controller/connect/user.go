@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/library/util"
@@ -40,7 +41,11 @@ var UserRouter = router.Handler(func(c router.Context) {
 	client := ws.NewClient(websocket)
 
 	// 注册新的用户
-	ws.UserPoll.Add(client)
+	if err := ws.UserPoll.Add(client); err != nil {
+		_ = client.WriteError(err, ws.Message{})
+		_ = ws.UserPoll.Remove(client)
+		return
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -56,40 +61,38 @@ var UserRouter = router.Handler(func(c router.Context) {
 			fmt.Printf("%+v\n", err)
 		}
 
-		waiterId := ws.MatcherPool.GetMyWaiter(client.UUID)
-
-		// 通知客服，我断开连接
-		if waiterId != nil {
-			waiterClient := ws.WaiterPoll.Get(*waiterId)
+		// 会话已经在新的连接恢复, 只需要关闭当前连接
+		if replaced, _ := ws.UserPoll.IsReplaced(client); replaced {
+			_ = ws.UserPoll.Remove(client)
+			return
+		}
 
-			_ = waiterClient.WriteJSON(ws.Message{
-				From:    client.UUID,
-				To:      *waiterId,
-				Type:    string(ws.TypeResponseWaiterDisconnected),
-				Payload: nil,
-			})
+		waiterId, _ := ws.MatcherPool.GetMyWaiter(client.UUID)
 
-			hash := util.MD5(client.UUID + waiterClient.UUID)
+		// 不是服务端主动关闭的连接, 说明是网络断开
+		// 保留会话一段时间, 等待用户重新连接, 客服端不会收到断开的通知
+		if waiterId != nil && !client.Closed && config.CustomerService.ResumeGrace > 0 {
+			if suspended, err := ws.UserPoll.Suspend(client, config.CustomerService.ResumeGrace); err == nil {
+				if suspended {
+					UUID := client.UUID
 
-			now := time.Now()
+					time.AfterFunc(config.CustomerService.ResumeGrace, func() {
+						// 到期之前没有恢复, 结束会话
+						if expired, _ := ws.UserPoll.Expire(UUID); expired {
+							closeUserSession(UUID)
+						}
+					})
+				}
 
-			// 标记会话为已关闭
-			_ = database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
-				ClosedAt: &now,
-			}).Error
+				_ = client.Close()
+				return
+			}
 		}
 
 		// 从池中删除该链接
-		ws.UserPoll.Remove(client.UUID)
-
-		// 断开匹配
-		ws.MatcherPool.Leave(client.UUID)
+		_ = ws.UserPoll.Remove(client)
 
-		// 因为当前连接已经断开，应该会空出一个位置
-		// 让客服继续接待下一个
-		ws.MatcherPool.Broadcast <- true
-
-		_ = client.Close()
+		closeUserSession(client.UUID)
 	}()
 
 	ticker := time.NewTicker(time.Minute * 1)
@@ -125,7 +128,7 @@ var UserRouter = router.Handler(func(c router.Context) {
 					})
 
 					// 断开于客服的匹配
-					ws.MatcherPool.Leave(client.UUID)
+					_ = ws.MatcherPool.Leave(client.UUID)
 					// 关闭 socket
 					_ = client.Close()
 					ticker.Stop()
@@ -210,9 +213,53 @@ var UserRouter = router.Handler(func(c router.Context) {
 				_ = client.WriteError(err, msg)
 			}
 			break typeCondition
+		// 断线重连之后恢复会话
+		case ws.TypeRequestUserResume:
+			if err := userTypeResumeHandler(client, msg); err != nil {
+				_ = client.WriteError(err, msg)
+			}
+			break typeCondition
 		default:
 			_ = client.WriteError(exception.InvalidParams.New("未知的消息类型"), msg)
 			break typeCondition
 		}
 	}
 })
+
+// 结束用户的会话: 通知客服, 关闭会话, 断开匹配
+func closeUserSession(userSocketUUID string) {
+	waiterId, _ := ws.MatcherPool.GetMyWaiter(userSocketUUID)
+
+	// 通知客服，我断开连接
+	if waiterId != nil {
+		_ = ws.WaiterPoll.Send(*waiterId, ws.Message{
+			From:    userSocketUUID,
+			To:      *waiterId,
+			Type:    string(ws.TypeResponseWaiterDisconnected),
+			Payload: nil,
+		})
+
+		hash := util.MD5(userSocketUUID + *waiterId)
+
+		now := time.Now()
+
+		// 标记会话为已关闭
+		_ = database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
+			ClosedAt: &now,
+		}).Error
+	}
+
+	// 告诉正在监控的主管会话已结束, 断开匹配之后就没有监控了
+	ws.DefaultNode.Mirror(userSocketUUID, ws.Message{
+		From: userSocketUUID,
+		Type: string(ws.TypeResponseWaiterDisconnected),
+		Date: time.Now().Format(time.RFC3339Nano),
+	})
+
+	// 断开匹配
+	_ = ws.MatcherPool.Leave(userSocketUUID)
+
+	// 因为当前连接已经断开，应该会空出一个位置
+	// 让客服继续接待下一个
+	ws.MatcherPool.Broadcast <- true
+}
@@ -0,0 +1,41 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/controller/ticket"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"time"
+)
+
+// 没有客服接待, 之后用户的消息转为留言, 直到用户重新请求连接客服
+func goOffline(userClient *ws.Client, reason string) error {
+	userClient.SetOffline(true)
+
+	return userClient.WriteJSON(ws.Message{
+		To:   userClient.UUID,
+		Type: string(ws.TypeResponseUserOffline),
+		Payload: ws.OfflinePayload{
+			Reason: reason,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+	})
+}
+
+// 把用户的消息写入留言, 并且给用户端一个回执
+func leaveMessage(userClient *ws.Client, msg ws.Message, receipt ws.TypeResponseUser, text string, image string) error {
+	report, err := ticket.Append(userClient.GetProfile().Id, text, image)
+
+	if err != nil {
+		return err
+	}
+
+	return userClient.WriteJSON(ws.Message{
+		Id:      report.Id,
+		Type:    string(receipt),
+		From:    userClient.UUID,
+		To:      userClient.UUID,
+		Payload: msg.Payload,
+		Date:    report.UpdatedAt,
+		OpID:    msg.OpID,
+	})
+}
@@ -43,7 +43,9 @@ func userTypeAuthHandler(userClient *ws.Client, msg ws.Message) (err error) {
 		return err
 	}
 
-	userClient.UpdateProfile(profile)
+	if err = ws.UserPoll.UpdateProfile(userClient, profile); err != nil {
+		return err
+	}
 
 	// 告诉客户端它的身份信息
 	if err = userClient.WriteJSON(ws.Message{
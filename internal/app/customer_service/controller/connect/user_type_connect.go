@@ -7,6 +7,7 @@ import (
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/service/database"
 )
@@ -17,7 +18,61 @@ func userTypeConnectHandler(userClient *ws.Client, msg ws.Message) (err error) {
 		err = exception.UserNotLogin
 		return
 	}
-	waiterID, location := ws.MatcherPool.Join(userClient.UUID)
+
+	var body ws.ConnectPayload
+
+	if msg.Payload != nil {
+		if err = util.Decode(&body, msg.Payload); err != nil {
+			return
+		}
+
+		if err = validator.ValidateStruct(&body); err != nil {
+			return
+		}
+	}
+
+	// 重新请求连接客服, 之后的消息不再转为留言, 之前排队的定时器也不再需要
+	userClient.SetOffline(false)
+	userClient.StopOfflineTimer()
+
+	routing, err := loadRouting()
+
+	if err != nil {
+		return
+	}
+
+	// 不在营业时间, 不需要排队, 直接转为留言
+	if routing.BusinessHours != nil && !routing.BusinessHours.IsOpen(time.Now()) {
+		return goOffline(userClient, ws.OfflineReasonClosed)
+	}
+
+	// 没有客服拥有该技能, 排队也不会被接待
+	if body.Topic != "" {
+		var ok bool
+
+		if ok, err = hasSkill(body.Topic); err != nil {
+			return
+		} else if !ok {
+			err = exception.InvalidParams.New("不支持该咨询话题")
+			return
+		}
+	}
+
+	// 等级越高的用户越优先被接待
+	user := model.User{Id: userClient.GetProfile().Id}
+
+	if err = database.Db.Model(&user).Where(&user).First(&user).Error; err != nil {
+		return
+	}
+
+	waiterID, location, err := ws.MatcherPool.Join(userClient.UUID, ws.Demand{
+		Topic:    body.Topic,
+		Priority: int(user.Level),
+	})
+
+	if err != nil {
+		return
+	}
 
 	// 如果找不到合适的客服，则添加到等待队列
 	if waiterID == nil {
@@ -32,57 +87,92 @@ func userTypeConnectHandler(userClient *ws.Client, msg ws.Message) (err error) {
 		}); err != nil {
 			return
 		}
+
+		// 队列发生变化, 通知调度器分配客服, 并且告诉排队的用户新的位置
+		ws.MatcherPool.Broadcast <- true
+
+		// 排队超时仍然没有客服接待, 退出排队并且转为留言
+		if routing.OfflineAfter > 0 {
+			userClient.SetOfflineTimer(time.Duration(routing.OfflineAfter)*time.Second, func() {
+				if userClient.IsClosed() {
+					return
+				}
+
+				// 已经被分配了客服, 或者已经离开
+				if withdrawn, err := ws.MatcherPool.Withdraw(userClient.UUID); err != nil || !withdrawn {
+					return
+				}
+
+				_ = goOffline(userClient, ws.OfflineReasonTimeout)
+
+				// 排在后面的用户位置发生了变化
+				ws.MatcherPool.Broadcast <- true
+			})
+		}
+
 		return
 	}
 
-	waiterClient := ws.WaiterPoll.Get(*waiterID)
+	// 客服可能连接在其他节点
+	waiterProfile, err := ws.WaiterPoll.GetProfile(*waiterID)
 
-	if waiterClient != nil {
+	if err != nil {
+		return
+	}
 
-		// 连接成功，那么数据库创建一个会话
-		tx := database.Db.Begin()
+	// 连接成功，那么数据库创建一个会话
+	tx := database.Db.Begin()
 
-		defer func() {
-			if err != nil {
-				_ = tx.Rollback()
-			} else {
-				_ = tx.Commit()
-			}
-		}()
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
 
-		hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	hash := util.MD5(userClient.UUID + *waiterID)
 
-		session := model.CustomerSession{
-			Id:       hash,
-			Uid:      userClient.GetProfile().Id,
-			WaiterID: waiterClient.GetProfile().Id,
-		}
+	session := model.CustomerSession{
+		Id:       hash,
+		Uid:      userClient.GetProfile().Id,
+		WaiterID: waiterProfile.Id,
+	}
 
-		if err = tx.Create(&session).Error; err != nil {
-			return
-		}
+	if err = tx.Create(&session).Error; err != nil {
+		return
+	}
 
-		// 告诉用户端已连接成功
-		if err = userClient.WriteJSON(ws.Message{
-			Type:    string(ws.TypeResponseUserConnectSuccess),
-			From:    *waiterID,
-			To:      userClient.UUID,
-			Payload: waiterClient.GetProfile(),
-			Date:    time.Now().Format(time.RFC3339Nano),
-		}); err != nil {
-			return
-		}
+	// 断线重连之后, 用户凭该令牌恢复会话
+	token, err := ws.UserPoll.IssueResumeToken(userClient.UUID, session.Uid)
 
-		// 告诉客服端有新的连接接入
-		if err = waiterClient.WriteJSON(ws.Message{
-			From:    userClient.UUID,
-			To:      *waiterID,
-			Type:    string(ws.TypeResponseWaiterNewConnection),
-			Payload: userClient.GetProfile(),
-			Date:    time.Now().Format(time.RFC3339Nano),
-		}); err != nil {
-			return
-		}
+	if err != nil {
+		return
+	}
+
+	// 告诉用户端已连接成功
+	if err = userClient.WriteJSON(ws.Message{
+		Type: string(ws.TypeResponseUserConnectSuccess),
+		From: *waiterID,
+		To:   userClient.UUID,
+		Payload: ws.ConnectSuccessPayload{
+			ProfilePublic: *waiterProfile,
+			Token:         token,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+	}); err != nil {
+		return
+	}
+
+	// 告诉客服端有新的连接接入
+	if err = ws.WaiterPoll.Send(*waiterID, ws.Message{
+		From:    userClient.UUID,
+		To:      *waiterID,
+		Type:    string(ws.TypeResponseWaiterNewConnection),
+		Payload: userClient.GetProfile(),
+		Date:    time.Now().Format(time.RFC3339Nano),
+	}); err != nil {
+		return
 	}
 
 	return
@@ -16,15 +16,17 @@ func userTypeDisconnectHandler(userClient *ws.Client) error {
 		return exception.UserNotLogin
 	}
 
-	waiterId := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+	waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+
+	if err != nil {
+		return err
+	}
 
 	var fromId string
 
 	// 通知客服，我断开连接
 	if waiterId != nil {
-		waiterClient := ws.WaiterPoll.Get(*waiterId)
-
-		_ = waiterClient.WriteJSON(ws.Message{
+		_ = ws.WaiterPoll.Send(*waiterId, ws.Message{
 			From:    userClient.UUID,
 			To:      *waiterId,
 			Type:    string(ws.TypeResponseWaiterDisconnected),
@@ -35,7 +37,7 @@ func userTypeDisconnectHandler(userClient *ws.Client) error {
 		fromId = *waiterId
 
 		// 关闭会话
-		hash := util.MD5(userClient.UUID + waiterClient.UUID)
+		hash := util.MD5(userClient.UUID + *waiterId)
 
 		now := time.Now()
 
@@ -47,7 +49,12 @@ func userTypeDisconnectHandler(userClient *ws.Client) error {
 		}
 	}
 
-	ws.MatcherPool.Leave(userClient.UUID)
+	if err := ws.MatcherPool.Leave(userClient.UUID); err != nil {
+		return err
+	}
+
+	// 空出一个位置, 或者排队的用户离开, 让调度器重新分配
+	ws.MatcherPool.Broadcast <- true
 
 	// 通知自己，连接已断开
 	_ = userClient.WriteJSON(ws.Message{
@@ -58,7 +65,5 @@ func userTypeDisconnectHandler(userClient *ws.Client) error {
 		Date:    time.Now().Format(time.RFC3339Nano),
 	})
 
-	userClient.RegenerateUUID()
-
-	return nil
+	return ws.UserPoll.RegenerateUUID(userClient)
 }
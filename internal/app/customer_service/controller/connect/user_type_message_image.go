@@ -15,7 +15,11 @@ func userTypeMessageImageHandler(userClient *ws.Client, msg ws.Message) (err err
 		return exception.UserNotLogin
 	}
 
-	waiterId := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+	waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+
+	if err != nil {
+		return err
+	}
 
 	var body ws.MessageImagePayload
 
@@ -37,6 +41,9 @@ func userTypeMessageImageHandler(userClient *ws.Client, msg ws.Message) (err err
 			Payload: msg.Payload,
 			Date:    time.Now().Format(time.RFC3339Nano),
 		}
+	} else if userClient.IsOffline() {
+		// 没有客服接待, 转为留言
+		return leaveMessage(userClient, msg, ws.TypeResponseUserMessageImageSuccess, "", body.Image)
 	} else {
 		if err = userClient.WriteJSON(ws.Message{
 			To:   userClient.UUID,
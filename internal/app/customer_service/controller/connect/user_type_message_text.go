@@ -15,7 +15,11 @@ func userTypeMessageHandler(userClient *ws.Client, msg ws.Message) (err error) {
 		return exception.UserNotLogin
 	}
 
-	waiterId := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+	waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+
+	if err != nil {
+		return err
+	}
 
 	var body ws.MessageTextPayload
 
@@ -37,6 +41,9 @@ func userTypeMessageHandler(userClient *ws.Client, msg ws.Message) (err error) {
 			Payload: msg.Payload,
 			Date:    time.Now().Format(time.RFC3339Nano),
 		}
+	} else if userClient.IsOffline() {
+		// 没有客服接待, 转为留言
+		return leaveMessage(userClient, msg, ws.TypeResponseUserMessageTextSuccess, body.Text, "")
 	} else {
 		if err = userClient.WriteJSON(ws.Message{
 			To:   userClient.UUID,
@@ -28,62 +28,60 @@ func userTypeRateHandler(userClient *ws.Client, msg ws.Message) (err error) {
 		return err
 	}
 
-	waiterId := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+	waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+
+	if err != nil {
+		return err
+	}
 
 	if waiterId == nil {
 		return errors.New("未连接")
 	}
 
-	waiterClient := ws.WaiterPoll.Get(*waiterId)
-
-	if waiterClient != nil {
-		var tx = database.Db.Begin()
-
-		defer func() {
-			if err != nil {
-				err = tx.Rollback().Error
-			} else {
-				err = tx.Commit().Error
-			}
-		}()
-
-		sessionID := util.MD5(userClient.UUID + waiterClient.UUID)
-
-		// 更新评分
-		if err = tx.Model(model.CustomerSession{}).
-			Where("id = ?", sessionID).
-			Where("uid = ?", userClient.GetProfile().Id).
-			Where("rate = NULL").
-			Where("closed_at = NULL").
-			Update("rate = ?", body.Rate).
-			Error; err != nil {
-			return
-		}
+	var tx = database.Db.Begin()
 
-		now := time.Now().Format(time.RFC3339Nano)
-
-		// 给用户回执
-		_ = userClient.WriteJSON(ws.Message{
-			To:      userClient.UUID,
-			Type:    ws.TypeResponseUserRateSuccess.String(),
-			Payload: body,
-			Date:    now,
-		})
-
-		// 给客服回执
-		_ = waiterClient.WriteJSON(ws.Message{
-			From:    userClient.UUID,
-			To:      waiterClient.UUID,
-			Type:    ws.TypeResponseWaiterRateUserSuccess.String(),
-			Payload: body,
-			Date:    now,
-		})
-
-		// 断开 socket 连接
-		_ = userClient.Close()
-	} else {
-		return errors.New("未连接")
+	defer func() {
+		if err != nil {
+			err = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	sessionID := util.MD5(userClient.UUID + *waiterId)
+
+	// 更新评分
+	if err = tx.Model(model.CustomerSession{}).
+		Where("id = ?", sessionID).
+		Where("uid = ?", userClient.GetProfile().Id).
+		Where("rate = NULL").
+		Where("closed_at = NULL").
+		Update("rate = ?", body.Rate).
+		Error; err != nil {
+		return
 	}
 
+	now := time.Now().Format(time.RFC3339Nano)
+
+	// 给用户回执
+	_ = userClient.WriteJSON(ws.Message{
+		To:      userClient.UUID,
+		Type:    ws.TypeResponseUserRateSuccess.String(),
+		Payload: body,
+		Date:    now,
+	})
+
+	// 给客服回执, 客服可能连接在其他节点
+	_ = ws.WaiterPoll.Send(*waiterId, ws.Message{
+		From:    userClient.UUID,
+		To:      *waiterId,
+		Type:    ws.TypeResponseWaiterRateUserSuccess.String(),
+		Payload: body,
+		Date:    now,
+	})
+
+	// 断开 socket 连接
+	_ = userClient.Close()
+
 	return err
 }
@@ -0,0 +1,145 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"time"
+)
+
+func userTypeResumeHandler(userClient *ws.Client, msg ws.Message) (err error) {
+	// 如果还没有认证
+	if userClient.GetProfile() == nil {
+		return exception.UserNotLogin
+	}
+
+	var body ws.ResumePayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return err
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return err
+	}
+
+	var since time.Time
+
+	if body.Since != "" {
+		if since, err = time.Parse(time.RFC3339Nano, body.Since); err != nil {
+			return exception.InvalidParams.New(err.Error())
+		}
+	}
+
+	// 当前连接已经在和客服对话
+	if waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID); err != nil {
+		return err
+	} else if waiterId != nil {
+		return exception.InvalidParams.New("已连接客服, 无需恢复会话")
+	}
+
+	oldUUID := userClient.UUID
+
+	suspendedAt, err := ws.UserPoll.Resume(userClient, body.Token)
+
+	if err != nil {
+		return err
+	}
+
+	// 当前连接可能正在排队
+	_ = ws.MatcherPool.Leave(oldUUID)
+
+	if since.IsZero() {
+		since = suspendedAt
+	}
+
+	waiterId, err := ws.MatcherPool.GetMyWaiter(userClient.UUID)
+
+	if err != nil {
+		return err
+	}
+
+	if waiterId == nil {
+		return exception.CustomerSessionExpired
+	}
+
+	// 客服可能连接在其他节点
+	waiterProfile, err := ws.WaiterPoll.GetProfile(*waiterId)
+
+	if err != nil {
+		return err
+	}
+
+	// 令牌只能使用一次, 签发新的令牌用于下一次恢复
+	token, err := ws.UserPoll.IssueResumeToken(userClient.UUID, userClient.GetProfile().Id)
+
+	if err != nil {
+		return err
+	}
+
+	if err = userClient.WriteJSON(ws.Message{
+		Type: string(ws.TypeResponseUserResumeSuccess),
+		From: *waiterId,
+		To:   userClient.UUID,
+		Payload: ws.ConnectSuccessPayload{
+			ProfilePublic: *waiterProfile,
+			Token:         token,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+		OpID: msg.OpID,
+	}); err != nil {
+		return err
+	}
+
+	// 推送断线期间客服发来的消息. 断线期间会话可能被转接给其他客服, 所以按照用户在这段时间内的所有会话查找
+	var items []model.CustomerSessionItem
+
+	uid := userClient.GetProfile().Id
+
+	sessions := database.Db.
+		Model(model.CustomerSession{}).
+		Select("id").
+		Where("uid = ?", uid).
+		Where("closed_at IS NULL OR closed_at > ?", since).
+		SubQuery()
+
+	if err = database.Db.
+		Where("session_id IN (?)", sessions).
+		Where("receiver_id = ?", uid).
+		Where("created_at > ?", since).
+		Order("created_at asc").
+		Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var t ws.TypeResponseUser
+
+		switch item.Type {
+		case model.SessionTypeText:
+			t = ws.TypeResponseUserMessageText
+		case model.SessionTypeImage:
+			t = ws.TypeResponseUserMessageImage
+		default:
+			continue
+		}
+
+		if err = userClient.WriteJSON(ws.Message{
+			Id:      item.Id,
+			From:    *waiterId,
+			To:      userClient.UUID,
+			Type:    string(t),
+			Payload: json.RawMessage(item.Payload),
+			Date:    item.CreatedAt.Format(time.RFC3339Nano),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
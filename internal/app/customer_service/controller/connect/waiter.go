@@ -57,34 +57,33 @@ var WaiterRouter = router.Handler(func(c router.Context) {
 		_ = webscoket.Close()
 		if client != nil {
 			// 移除客户端
-			ws.WaiterPoll.Remove(client.UUID)
+			_ = ws.WaiterPoll.Remove(client)
 			// 通知已连接的用户断开连接
-			users := ws.MatcherPool.GetMyUsers(client.UUID)
+			users, _ := ws.MatcherPool.GetMyUsers(client.UUID)
 
 			for _, user := range users {
-				userSocket := ws.UserPoll.Get(user)
-				if userSocket != nil {
-					_ = userSocket.WriteJSON(ws.Message{
-						From:    client.UUID,
-						To:      user,
-						Type:    string(ws.TypeResponseUserDisconnected),
-						Payload: nil,
-						Date:    time.Now().Format(time.RFC3339Nano),
-					})
-
-					// 关闭会话
-					hash := util.MD5(userSocket.UUID + client.UUID)
-
-					now := time.Now()
-
-					// 标记会话为已关闭
-					_ = database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
-						ClosedAt: &now,
-					}).Error
-				}
+				_ = ws.UserPoll.Send(user, ws.Message{
+					From:    client.UUID,
+					To:      user,
+					Type:    string(ws.TypeResponseUserDisconnected),
+					Payload: nil,
+					Date:    time.Now().Format(time.RFC3339Nano),
+				})
+
+				// 关闭会话
+				hash := util.MD5(user + client.UUID)
+
+				now := time.Now()
+
+				// 标记会话为已关闭
+				_ = database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
+					ClosedAt: &now,
+				}).Error
 			}
 			// 从客服池中移除
-			ws.MatcherPool.RemoveWaiter(client.UUID)
+			_ = ws.MatcherPool.RemoveWaiter(client.UUID)
+			// 停止监控其他会话
+			_ = ws.MatcherPool.UnwatchAll(client.UUID)
 
 			// 因为当前连接已经断开，正在连接的用户会被加入到队列
 			// 所以触发一次任务调度
@@ -95,7 +94,10 @@ var WaiterRouter = router.Handler(func(c router.Context) {
 	client = ws.NewClient(webscoket)
 
 	// 注册新的客户端
-	ws.WaiterPoll.Add(client)
+	if err := ws.WaiterPoll.Add(client); err != nil {
+		_ = client.WriteError(err, ws.Message{})
+		return
+	}
 
 	for {
 		var msg ws.Message
@@ -160,6 +162,43 @@ var WaiterRouter = router.Handler(func(c router.Context) {
 				_ = client.WriteError(exception.InvalidParams.New(er.Error()), msg)
 			}
 			break typeCondition
+		case ws.TypeRequestWaiterTransfer:
+			if er := waiterTypeTransferHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		// 以下为主管的操作
+		case ws.TypeRequestWaiterWatch:
+			if er := waiterTypeWatchHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		case ws.TypeRequestWaiterUnwatch:
+			if er := waiterTypeUnwatchHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		case ws.TypeRequestWaiterWhisper:
+			if er := waiterTypeWhisperHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		case ws.TypeRequestWaiterTakeover:
+			if er := waiterTypeTakeoverHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		// 用户留言
+		case ws.TypeRequestWaiterGetTickets:
+			if er := waiterTypeGetTicketsHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
+		case ws.TypeRequestWaiterReplyTicket:
+			if er := waiterTypeReplyTicketHandler(client, msg); er != nil {
+				_ = client.WriteError(er, msg)
+			}
+			break typeCondition
 		default:
 			_ = client.WriteError(exception.InvalidParams.New("未知的消息类型"), msg)
 			break typeCondition
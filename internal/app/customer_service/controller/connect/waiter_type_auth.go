@@ -3,9 +3,12 @@ package connect
 
 import (
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/rbac/accession"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/authentication"
 	"github.com/axetroy/go-server/internal/service/database"
@@ -33,34 +36,48 @@ func waiterTypeAuthHandler(waiterClient *ws.Client, msg ws.Message) (err error)
 		Id: uid,
 	}
 
-	if err = database.Db.Model(&userInfo).Where(&userInfo).Where("role @> ARRAY[?::varchar]", "waiter").First(&userInfo).Error; err != nil {
+	if err = database.Db.Model(&userInfo).Where(&userInfo).First(&userInfo).Error; err != nil {
 		return err
 	}
 
+	// 客服和主管的角色都拥有连接客服的权限
+	c, err := rbac.New(uid)
+
+	if err != nil {
+		return err
+	}
+
+	if !c.Has(*accession.CustomerServiceConnect) {
+		return exception.NoPermission
+	}
+
 	var profile schema.ProfilePublic
 
 	if err = util.Decode(&profile, userInfo); err != nil {
 		return err
 	}
 
-	waiterClient.UpdateProfile(profile)
-
-	// 如果这个客服之前已经登录，那么我们就把原有的连接关闭
-	if waiters := ws.WaiterPoll.GetWaiterFromUserID(profile.Id); len(waiters) > 0 {
-		for _, c := range waiters {
-			// 其他连接都要关闭
-			if c.UUID != waiterClient.UUID {
-				// 推送断开连接
-				if err = c.WriteJSON(ws.Message{
-					Type: string(ws.TypeResponseWaiterKickOut),
-					From: waiterClient.UUID,
-					To:   c.UUID,
-					Date: time.Now().Format(time.RFC3339Nano),
-				}); err != nil {
-					return
-				}
-				_ = c.Close()
-			}
+	if err = ws.WaiterPoll.UpdateProfile(waiterClient, profile); err != nil {
+		return err
+	}
+
+	// 如果这个客服之前已经登录，那么我们就把原有的连接关闭, 原有的连接可能在其他节点
+	waiters, err := ws.WaiterPoll.GetClientsFromUserID(profile.Id)
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range waiters {
+		// 其他连接都要关闭
+		if id != waiterClient.UUID {
+			// 推送断开连接
+			_ = ws.WaiterPoll.Kick(id, ws.Message{
+				Type: string(ws.TypeResponseWaiterKickOut),
+				From: waiterClient.UUID,
+				To:   id,
+				Date: time.Now().Format(time.RFC3339Nano),
+			})
 		}
 	}
 
@@ -26,45 +26,60 @@ func waiterTypeDisconnectHandler(waiterClient *ws.Client, msg ws.Message) error
 		return err
 	}
 
-	userClient := ws.UserPoll.Get(body.UUID)
-
-	if userClient != nil {
-		ws.MatcherPool.Leave(body.UUID)
-
-		// 告诉用户端断开连接
-		_ = userClient.WriteJSON(ws.Message{
-			From:    waiterClient.UUID,
-			To:      userClient.UUID,
-			Type:    string(ws.TypeResponseUserDisconnected),
-			Payload: nil,
-			Date:    time.Now().Format(time.RFC3339Nano),
-		})
-
-		// 告诉客服端已断开连接
-		_ = waiterClient.WriteJSON(ws.Message{
-			From:    userClient.UUID,
-			To:      waiterClient.UUID,
-			Type:    string(ws.TypeResponseWaiterDisconnected),
-			Payload: nil,
-			Date:    time.Now().Format(time.RFC3339Nano),
-		})
-
-		// 关闭会话
-		hash := util.MD5(userClient.UUID + waiterClient.UUID)
-
-		now := time.Now()
-
-		// 标记会话为已关闭
-		if err := database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
-			ClosedAt: &now,
-		}).Error; err != nil {
-			return err
-		}
-	} else {
+	// 只能断开自己正在接待的用户, 用户可能连接在其他节点
+	waiterId, err := ws.MatcherPool.GetMyWaiter(body.UUID)
+
+	if err != nil {
+		return err
+	}
+
+	if waiterId == nil || *waiterId != waiterClient.UUID {
 		return exception.InvalidParams.New("未连接")
 	}
 
-	waiterClient.RegenerateUUID()
+	// 告诉正在监控的主管会话已结束, 断开匹配之后就没有监控了
+	ws.DefaultNode.Mirror(body.UUID, ws.Message{
+		From: waiterClient.UUID,
+		Type: string(ws.TypeResponseUserDisconnected),
+		Date: time.Now().Format(time.RFC3339Nano),
+	})
+
+	if err := ws.MatcherPool.Leave(body.UUID); err != nil {
+		return err
+	}
+
+	// 告诉用户端断开连接
+	_ = ws.UserPoll.Send(body.UUID, ws.Message{
+		From:    waiterClient.UUID,
+		To:      body.UUID,
+		Type:    string(ws.TypeResponseUserDisconnected),
+		Payload: nil,
+		Date:    time.Now().Format(time.RFC3339Nano),
+	})
+
+	// 告诉客服端已断开连接
+	_ = waiterClient.WriteJSON(ws.Message{
+		From:    body.UUID,
+		To:      waiterClient.UUID,
+		Type:    string(ws.TypeResponseWaiterDisconnected),
+		Payload: nil,
+		Date:    time.Now().Format(time.RFC3339Nano),
+	})
+
+	// 关闭会话
+	hash := util.MD5(body.UUID + waiterClient.UUID)
+
+	now := time.Now()
+
+	// 标记会话为已关闭
+	if err := database.Db.Model(model.CustomerSession{}).Where("id = ?", hash).Update(model.CustomerSession{
+		ClosedAt: &now,
+	}).Error; err != nil {
+		return err
+	}
+
+	// 空出了一个位置, 继续接待下一个
+	ws.MatcherPool.Broadcast <- true
 
 	return nil
 }
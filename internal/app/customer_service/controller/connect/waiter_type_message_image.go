@@ -31,6 +31,13 @@ func waiterTypeMessageImageHandler(waiterClient *ws.Client, msg ws.Message) (err
 		return
 	}
 
+	// 只能给正在接待的用户发送消息, 用户可能已经被转接给其他客服
+	if waiterId, er := ws.MatcherPool.GetMyWaiter(msg.To); er != nil {
+		return er
+	} else if waiterId == nil || *waiterId != waiterClient.UUID {
+		return exception.CustomerNotServing
+	}
+
 	// 如果这个客户端没有连接客服，那么消息不会发送
 	ws.UserPoll.Broadcast <- ws.Message{
 		Type:    msg.Type,
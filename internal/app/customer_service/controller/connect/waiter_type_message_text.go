@@ -31,6 +31,13 @@ func waiterTypeMessageHandler(waiterClient *ws.Client, msg ws.Message) (err erro
 		return
 	}
 
+	// 只能给正在接待的用户发送消息, 用户可能已经被转接给其他客服
+	if waiterId, er := ws.MatcherPool.GetMyWaiter(msg.To); er != nil {
+		return er
+	} else if waiterId == nil || *waiterId != waiterClient.UUID {
+		return exception.CustomerNotServing
+	}
+
 	// 把收到的消息发送给用户
 	ws.UserPoll.Broadcast <- ws.Message{
 		From:    waiterClient.UUID,
@@ -2,7 +2,6 @@
 package connect
 
 import (
-	"errors"
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/util"
@@ -32,31 +31,26 @@ func waiterTypeRateHandler(waiterClient *ws.Client, msg ws.Message) (err error)
 		return
 	}
 
-	userClient := ws.UserPoll.Get(msg.To)
-
-	if userClient != nil {
-		now := time.Now().Format(time.RFC3339Nano)
-		// 发给客户
-		if err = userClient.WriteJSON(ws.Message{
-			Type: ws.TypeResponseUserRate.String(),
-			From: waiterClient.UUID,
-			To:   userClient.UUID,
-			Date: now,
-		}); err != nil {
-			return
-		}
-
-		// 给客服回执
-		_ = waiterClient.WriteJSON(ws.Message{
-			From:    userClient.UUID,
-			To:      waiterClient.UUID,
-			Type:    ws.TypeResponseWaiterRateSuccess.String(),
-			Payload: body,
-			Date:    now,
-		})
-	} else {
-		return errors.New("未连接")
+	now := time.Now().Format(time.RFC3339Nano)
+
+	// 发给客户, 客户可能连接在其他节点
+	if err = ws.UserPoll.Send(msg.To, ws.Message{
+		Type: ws.TypeResponseUserRate.String(),
+		From: waiterClient.UUID,
+		To:   msg.To,
+		Date: now,
+	}); err != nil {
+		return
 	}
 
+	// 给客服回执
+	_ = waiterClient.WriteJSON(ws.Message{
+		From:    msg.To,
+		To:      waiterClient.UUID,
+		Type:    ws.TypeResponseWaiterRateSuccess.String(),
+		Payload: body,
+		Date:    now,
+	})
+
 	return err
 }
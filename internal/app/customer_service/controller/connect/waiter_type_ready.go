@@ -12,21 +12,25 @@ func waiterTypeReadyHandler(waiterClient *ws.Client) (err error) {
 		return
 	}
 
-	waiterClient.SetReady(true)
+	// 客服的技能和接待人数在配置中心设置
+	ability, err := getAbility(waiterClient.GetProfile().Id)
 
-	ws.MatcherPool.AddWaiter(waiterClient.UUID)
+	if err != nil {
+		return
+	}
 
-	i := 0
+	waiterClient.SetReady(true)
 
-	// 让这个客服接满客
-	for {
-		if i > ws.MatcherPool.Max {
-			break
-		}
-		// 通知匹配池，开始匹配
-		ws.MatcherPool.Broadcast <- true
-		i = i + 1
+	if err = ws.MatcherPool.AddWaiter(waiterClient.UUID, ability); err != nil {
+		return
 	}
 
+	if err = ws.MatcherPool.SetReady(waiterClient.UUID, true); err != nil {
+		return
+	}
+
+	// 通知匹配池，开始匹配, 调度器会让这个客服接满客
+	ws.MatcherPool.Broadcast <- true
+
 	return err
 }
@@ -0,0 +1,197 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/controller/history"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"time"
+)
+
+// 只有拥有主管权限的客服才可以监控和接管会话
+func requireSupervisor(waiterClient *ws.Client) error {
+	profile := waiterClient.GetProfile()
+
+	// 如果还没有认证
+	if profile == nil {
+		return exception.UserNotLogin
+	}
+
+	c, err := rbac.New(profile.Id)
+
+	if err != nil {
+		return err
+	}
+
+	if !c.Has(*accession.CustomerServiceSupervise) {
+		return exception.NoPermission
+	}
+
+	return nil
+}
+
+// 开始监控用户的会话, 之后会话中的消息都会抄送一份
+func waiterTypeWatchHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	if err = requireSupervisor(waiterClient); err != nil {
+		return
+	}
+
+	var body ws.WatchPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	waiterId, err := ws.MatcherPool.GetMyWaiter(body.UUID)
+
+	if err != nil {
+		return
+	}
+
+	if waiterId == nil {
+		return exception.CustomerNotConnected
+	}
+
+	if err = ws.MatcherPool.Watch(body.UUID, waiterClient.UUID); err != nil {
+		return
+	}
+
+	// 推送当前会话的聊天记录, 包括转接之前的
+	list, err := history.GetSessionHistory(util.MD5(body.UUID + *waiterId))
+
+	if err != nil {
+		return
+	}
+
+	return waiterClient.WriteJSON(ws.Message{
+		From: body.UUID,
+		To:   waiterClient.UUID,
+		Type: string(ws.TypeResponseWaiterWatchSuccess),
+		Payload: map[string]interface{}{
+			"uuid":   body.UUID,
+			"waiter": *waiterId,
+			"data":   list,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+		OpID: msg.OpID,
+	})
+}
+
+// 停止监控用户的会话
+func waiterTypeUnwatchHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	if err = requireSupervisor(waiterClient); err != nil {
+		return
+	}
+
+	var body ws.WatchPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	if err = ws.MatcherPool.Unwatch(body.UUID, waiterClient.UUID); err != nil {
+		return
+	}
+
+	return waiterClient.WriteJSON(ws.Message{
+		From:    body.UUID,
+		To:      waiterClient.UUID,
+		Type:    string(ws.TypeResponseWaiterUnwatchSuccess),
+		Payload: body,
+		Date:    time.Now().Format(time.RFC3339Nano),
+		OpID:    msg.OpID,
+	})
+}
+
+// 悄悄提醒正在接待该用户的客服, 用户不会收到, 也不会写入会话
+func waiterTypeWhisperHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	if err = requireSupervisor(waiterClient); err != nil {
+		return
+	}
+
+	var body ws.WhisperPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	if err = ws.DefaultNode.Whisper(body.UUID, waiterClient.UUID, body.Text); err != nil {
+		return
+	}
+
+	return waiterClient.WriteJSON(ws.Message{
+		From:    waiterClient.UUID,
+		To:      waiterClient.UUID,
+		Type:    string(ws.TypeResponseWaiterWhisperSuccess),
+		Payload: body,
+		Date:    time.Now().Format(time.RFC3339Nano),
+		OpID:    msg.OpID,
+	})
+}
+
+// 接管用户的会话, 原来的客服不再接待该用户
+// 主管会像被分配了用户一样收到 new_connection 和 transfer_in
+func waiterTypeTakeoverHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	if err = requireSupervisor(waiterClient); err != nil {
+		return
+	}
+
+	var body ws.TakeoverPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	waiterId, err := ws.MatcherPool.GetMyWaiter(body.UUID)
+
+	if err != nil {
+		return
+	}
+
+	if waiterId == nil {
+		return exception.CustomerNotConnected
+	}
+
+	if *waiterId == waiterClient.UUID {
+		return exception.InvalidParams.New("已在接待该用户")
+	}
+
+	if err = ws.MatcherPool.Takeover(body.UUID, *waiterId, waiterClient.UUID, ws.Transfer{
+		SessionID: util.MD5(body.UUID + *waiterId),
+		By:        waiterClient.GetProfile().Id,
+		Reason:    body.Reason,
+	}); err != nil {
+		return
+	}
+
+	// 告诉原来的客服, 用户已经被接管
+	_ = ws.WaiterPoll.Send(*waiterId, ws.Message{
+		From:    waiterClient.UUID,
+		To:      *waiterId,
+		Type:    string(ws.TypeResponseWaiterTakenOver),
+		Payload: body,
+		Date:    time.Now().Format(time.RFC3339Nano),
+	})
+
+	return handover(body.UUID, *waiterId, waiterClient.UUID)
+}
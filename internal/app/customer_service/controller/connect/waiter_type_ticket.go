@@ -0,0 +1,95 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/controller/ticket"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/service/message_queue"
+	"time"
+)
+
+// 获取待处理的用户留言
+func waiterTypeGetTicketsHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	profile := waiterClient.GetProfile()
+
+	// 如果还没有认证
+	if profile == nil {
+		return exception.UserNotLogin
+	}
+
+	list, err := ticket.GetPending(profile.Id)
+
+	if err != nil {
+		return
+	}
+
+	return waiterClient.WriteJSON(ws.Message{
+		Type: string(ws.TypeResponseWaiterTicketList),
+		To:   waiterClient.UUID,
+		Payload: map[string]interface{}{
+			"data": list,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+		OpID: msg.OpID,
+	})
+}
+
+// 回复用户的留言, 用户会收到一条个人消息和 APP 推送, 在线的话还会马上收到回复
+func waiterTypeReplyTicketHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	profile := waiterClient.GetProfile()
+
+	// 如果还没有认证
+	if profile == nil {
+		return exception.UserNotLogin
+	}
+
+	var body ws.ReplyTicketPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	report, message, err := ticket.Reply(body.Id, profile.Id, body.Text, body.Resolve)
+
+	if err != nil {
+		return
+	}
+
+	// 通过 APP 推送给这个用户
+	_ = message_queue.PublishUserMessage(message.Id)
+
+	// 用户可能连接在其他节点, 也可能有多个连接
+	if users, err := ws.UserPoll.GetClientsFromUserID(report.Uid); err == nil {
+		for _, user := range users {
+			_ = ws.UserPoll.Send(user, ws.Message{
+				Id:   report.Id,
+				From: waiterClient.UUID,
+				To:   user,
+				Type: string(ws.TypeResponseUserTicketReply),
+				Payload: ws.TicketReplyPayload{
+					Id:      report.Id,
+					Message: message.Id,
+					Text:    body.Text,
+				},
+				Date: message.CreatedAt.Format(time.RFC3339Nano),
+			})
+		}
+	}
+
+	return waiterClient.WriteJSON(ws.Message{
+		Id:      report.Id,
+		Type:    string(ws.TypeResponseWaiterReplyTicketSuccess),
+		From:    waiterClient.UUID,
+		To:      report.Uid,
+		Payload: report,
+		Date:    message.CreatedAt.Format(time.RFC3339Nano),
+		OpID:    msg.OpID,
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package connect
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/worker"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"time"
+)
+
+func waiterTypeTransferHandler(waiterClient *ws.Client, msg ws.Message) (err error) {
+	profile := waiterClient.GetProfile()
+
+	// 如果还没有认证
+	if profile == nil {
+		return exception.UserNotLogin
+	}
+
+	var body ws.TransferPayload
+
+	if err = util.Decode(&body, msg.Payload); err != nil {
+		return
+	}
+
+	if err = validator.ValidateStruct(&body); err != nil {
+		return
+	}
+
+	if body.Waiter != "" && body.Topic != "" {
+		return exception.InvalidParams.New("不能同时指定客服和话题")
+	}
+
+	// 没有客服拥有该技能, 排队也不会被接待
+	if body.Topic != "" {
+		var ok bool
+
+		if ok, err = hasSkill(body.Topic); err != nil {
+			return
+		} else if !ok {
+			return exception.InvalidParams.New("不支持该咨询话题")
+		}
+	}
+
+	transfer := ws.Transfer{
+		SessionID: util.MD5(body.UUID + waiterClient.UUID),
+		By:        profile.Id,
+		Reason:    body.Reason,
+	}
+
+	if body.Waiter != "" {
+		err = ws.MatcherPool.TransferTo(body.UUID, waiterClient.UUID, body.Waiter, transfer)
+	} else {
+		err = ws.MatcherPool.TransferToQueue(body.UUID, waiterClient.UUID, body.Topic, transfer)
+	}
+
+	if err != nil {
+		return
+	}
+
+	// 用户已经不归当前客服接待, 即使交接失败也要给客服回执
+	err = handover(body.UUID, waiterClient.UUID, body.Waiter)
+
+	_ = waiterClient.WriteJSON(ws.Message{
+		From:    body.UUID,
+		To:      waiterClient.UUID,
+		Type:    string(ws.TypeResponseWaiterTransferSuccess),
+		Payload: body,
+		Date:    time.Now().Format(time.RFC3339Nano),
+		OpID:    msg.OpID,
+	})
+
+	return
+}
+
+// 用户被转接之后, 关闭原来的会话并且告诉用户
+// 指定了客服时马上建立新的会话, 否则等待调度器分配
+func handover(userSocketUUID string, fromWaiterSocketUUID string, toWaiterSocketUUID string) (err error) {
+	// 原来的客服空出了一个位置, 重新排队的用户也需要分配
+	defer func() {
+		ws.MatcherPool.Broadcast <- true
+	}()
+
+	now := time.Now()
+
+	// 标记会话为已转接
+	if err = database.Db.Model(model.CustomerSession{}).Where("id = ?", util.MD5(userSocketUUID+fromWaiterSocketUUID)).Update(model.CustomerSession{
+		ClosedAt:      &now,
+		TransferredAt: &now,
+	}).Error; err != nil {
+		return
+	}
+
+	_ = ws.UserPoll.Send(userSocketUUID, ws.Message{
+		From: fromWaiterSocketUUID,
+		To:   userSocketUUID,
+		Type: string(ws.TypeResponseUserTransfer),
+		Date: now.Format(time.RFC3339Nano),
+	})
+
+	// 监控跟随用户, 转接之后仍然可以看到
+	ws.DefaultNode.Mirror(userSocketUUID, ws.Message{
+		From: fromWaiterSocketUUID,
+		Type: string(ws.TypeResponseUserTransfer),
+		Date: now.Format(time.RFC3339Nano),
+	})
+
+	if toWaiterSocketUUID == "" {
+		return
+	}
+
+	return worker.Open(userSocketUUID, toWaiterSocketUUID)
+}
@@ -15,6 +15,11 @@ func waiterTypeUnReadyHandler(waiterClient *ws.Client) (err error) {
 
 	waiterClient.SetReady(false)
 
+	// 不再分配新的用户, 正在接待的用户不受影响
+	if err = ws.MatcherPool.SetReady(waiterClient.UUID, false); err != nil {
+		return
+	}
+
 	// 给予回执
 	_ = waiterClient.WriteJSON(ws.Message{
 		From: waiterClient.UUID,
@@ -268,3 +268,62 @@ func GetWaiterSession(waiterID string, txs ...*gorm.DB) (result []Session, err e
 
 	return
 }
+
+// 获取会话的聊天记录, 包括转接之前的会话, 按时间顺序
+func GetSessionHistory(sessionID string, txs ...*gorm.DB) (result []History, err error) {
+	var tx *gorm.DB
+
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	if tx == nil {
+		tx = database.Db.Begin()
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			_ = tx.Commit().Error
+		}
+	}()
+
+	var (
+		ids     = make([]string, 0)
+		visited = map[string]bool{}
+		id      = &sessionID
+	)
+
+	// 沿着转接的记录往前找, 转接回原来的客服时会出现环
+	for id != nil && !visited[*id] {
+		session := model.CustomerSession{}
+
+		if err = tx.Where("id = ?", *id).First(&session).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				err = nil
+				break
+			}
+			return
+		}
+
+		visited[*id] = true
+		ids = append(ids, session.Id)
+		id = session.TransferFrom
+	}
+
+	list := make([]model.CustomerSessionItem, 0)
+
+	if len(ids) > 0 {
+		if err = tx.Model(model.CustomerSessionItem{}).Where("session_id IN (?)", ids).Order("created_at DESC").Preload("Sender").Preload("Receiver").Limit(100).Find(&list).Error; err != nil {
+			return
+		}
+	}
+
+	// 最早的消息在前面
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+
+	return SessionItemToMap(list)
+}
@@ -14,17 +14,35 @@ type Status struct {
 	Matchers        map[string][]string `json:"matchers"`          // 当前正在配对的连接
 }
 
+func getStatus() (status Status, err error) {
+	if status.OnlineUserNum, err = ws.UserPoll.Count(); err != nil {
+		return
+	}
+
+	if status.OnlineWaiterNum, err = ws.WaiterPoll.Count(); err != nil {
+		return
+	}
+
+	if status.PendingNum, err = ws.MatcherPool.GetPendingLength(); err != nil {
+		return
+	}
+
+	if status.Matchers, err = ws.MatcherPool.GetMatcher(); err != nil {
+		return
+	}
+
+	return
+}
+
+// 所有节点的状态
 var GetStatusRouter = router.Handler(func(c router.Context) {
-	c.ResponseFunc(nil, func() schema.Response {
+	status, err := getStatus()
+
+	c.ResponseFunc(err, func() schema.Response {
 		return schema.Response{
 			Message: "",
 			Status:  schema.StatusSuccess,
-			Data: Status{
-				OnlineUserNum:   ws.UserPoll.Length(),
-				OnlineWaiterNum: ws.WaiterPoll.Length(),
-				PendingNum:      ws.MatcherPool.GetPendingLength(),
-				Matchers:        ws.MatcherPool.GetMatcher(),
-			},
+			Data:    status,
 		}
 	})
 })
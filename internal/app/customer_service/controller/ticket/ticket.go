@@ -0,0 +1,215 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ticket
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"time"
+)
+
+// 没有客服接待时, 用户的消息转为留言, 保存为客服留言类型的反馈
+// 每个用户只有一条待处理的留言, 之后的消息会追加到这条留言中, 标记为已解决之后再留言会创建新的留言
+
+func toSchema(info model.Report) (data schema.Report, err error) {
+	if err = mapstructure.Decode(info, &data.ReportPure); err != nil {
+		return
+	}
+
+	data.CreatedAt = info.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = info.UpdatedAt.Format(time.RFC3339Nano)
+
+	return
+}
+
+// 追加用户的留言, 文本追加到内容中, 图片追加到截图中
+func Append(uid string, text string, image string, txs ...*gorm.DB) (data schema.Report, err error) {
+	var tx *gorm.DB
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	if tx == nil {
+		tx = database.Db.Begin()
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	reportInfo := model.Report{}
+
+	if err = tx.Where(&model.Report{Uid: uid, Type: model.ReportTypeCustomerService}).Where("status = ?", model.ReportStatusPending).Order("created_at DESC").First(&reportInfo).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		reportInfo = model.Report{
+			Uid:         uid,
+			Title:       "客服留言",
+			Content:     text,
+			Type:        model.ReportTypeCustomerService,
+			Status:      model.ReportStatusPending,
+			Screenshots: []string{},
+		}
+
+		if image != "" {
+			reportInfo.Screenshots = append(reportInfo.Screenshots, image)
+		}
+
+		if err = tx.Create(&reportInfo).Error; err != nil {
+			return
+		}
+
+		return toSchema(reportInfo)
+	}
+
+	if text != "" {
+		if reportInfo.Content != "" {
+			reportInfo.Content += "\n"
+		}
+		reportInfo.Content += text
+	}
+
+	if image != "" {
+		reportInfo.Screenshots = append(reportInfo.Screenshots, image)
+	}
+
+	if err = tx.Model(&reportInfo).Update(map[string]interface{}{
+		"content":     reportInfo.Content,
+		"screenshots": reportInfo.Screenshots,
+	}).Error; err != nil {
+		return
+	}
+
+	return toSchema(reportInfo)
+}
+
+// 获取客服可以处理的留言, 包括还没有客服处理的和自己处理过的, 先留言的排在前面
+func GetPending(waiterID string, txs ...*gorm.DB) (result []schema.Report, err error) {
+	var tx *gorm.DB
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	if tx == nil {
+		tx = database.Db.Begin()
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			_ = tx.Commit().Error
+		}
+	}()
+
+	list := make([]model.Report, 0)
+
+	if err = tx.Where(&model.Report{Type: model.ReportTypeCustomerService}).
+		Where("status = ?", model.ReportStatusPending).
+		Where("waiter_id IS NULL OR waiter_id = ?", waiterID).
+		Order("created_at ASC").
+		Limit(100).
+		Find(&list).Error; err != nil {
+		return
+	}
+
+	result = make([]schema.Report, 0)
+
+	for _, info := range list {
+		var data schema.Report
+
+		if data, err = toSchema(info); err != nil {
+			return
+		}
+
+		result = append(result, data)
+	}
+
+	return
+}
+
+// 客服回复留言, 留言归该客服处理, 并且给用户发送一条个人消息
+// resolve 为 true 时标记留言为已解决
+func Reply(id string, waiterID string, text string, resolve bool, txs ...*gorm.DB) (report schema.Report, message model.Message, err error) {
+	var tx *gorm.DB
+	if len(txs) > 0 {
+		tx = txs[0]
+	}
+
+	if tx == nil {
+		tx = database.Db.Begin()
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback().Error
+		} else {
+			err = tx.Commit().Error
+		}
+	}()
+
+	reportInfo := model.Report{}
+
+	if err = tx.Where(&model.Report{Id: id, Type: model.ReportTypeCustomerService}).First(&reportInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.NoData
+		}
+		return
+	}
+
+	if reportInfo.WaiterID != nil && *reportInfo.WaiterID != waiterID {
+		err = exception.CustomerTicketClaimed
+		return
+	}
+
+	updated := map[string]interface{}{
+		"waiter_id": waiterID,
+	}
+
+	if resolve {
+		updated["status"] = model.ReportStatusResolve
+	}
+
+	// 只有还没有客服处理, 或者由自己处理的留言才会更新, 避免两个客服同时认领
+	result := tx.Model(model.Report{}).Where("id = ?", id).Where("waiter_id IS NULL OR waiter_id = ?", waiterID).Update(updated)
+
+	if err = result.Error; err != nil {
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		err = exception.CustomerTicketClaimed
+		return
+	}
+
+	reportInfo.WaiterID = &waiterID
+
+	if resolve {
+		reportInfo.Status = model.ReportStatusResolve
+	}
+
+	message = model.Message{
+		Uid:     reportInfo.Uid,
+		Title:   "客服回复了您的留言",
+		Content: text,
+		Note:    &reportInfo.Id,
+	}
+
+	// 生成一个用户的个人消息
+	if err = tx.Create(&message).Error; err != nil {
+		return
+	}
+
+	report, err = toSchema(reportInfo)
+
+	return
+}
@@ -10,10 +10,12 @@ import (
 	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/middleware"
+	"github.com/axetroy/go-server/pkg/rate_limit"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
 	"net/http"
+	"time"
 )
 
 var (
@@ -37,7 +39,7 @@ func init() {
 		v1.Use(recover.New())
 		v1.Use(middleware.Common())
 		v1.Use(middleware.CORS())
-		v1.Use(middleware.RateLimit(30))
+		v1.Use(middleware.RateLimit("customer_service", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 30})) // 每个 IP 每秒 5 个请求, 最多允许 30 个请求的突发
 
 		if config.Common.Mode != "production" {
 			v1.Use(logger.New())
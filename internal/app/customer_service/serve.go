@@ -3,9 +3,11 @@ package customer_service
 
 import (
 	"context"
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
 	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/token_key"
 	"log"
 	"net"
 	"net/http"
@@ -29,6 +31,22 @@ func Serve(host string, port string) error {
 		database.Dispose()
 	}()
 
+	// 加载 token 的签名密钥, 并定时同步其他实例轮换的密钥
+	if err := token_key.Load(database.Db); err != nil {
+		return err
+	}
+
+	token_key.Watch(database.Db)
+
+	// 接收其他节点转发过来的消息
+	if err := ws.DefaultNode.Start(); err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = ws.DefaultNode.Close()
+	}()
+
 	s := &http.Server{
 		Addr:           net.JoinHostPort(host, port),
 		Handler:        CustomerServiceRouter,
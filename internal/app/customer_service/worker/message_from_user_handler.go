@@ -11,10 +11,16 @@ import (
 )
 
 func textMessageFromUserHandler(msg ws.Message) (err error) {
-	waiterClient := ws.WaiterPoll.Get(msg.To)
 	userClient := ws.UserPoll.Get(msg.From)
 
-	if waiterClient == nil || userClient == nil {
+	if userClient == nil {
+		return
+	}
+
+	// 客服可能连接在其他节点
+	waiterProfile, err := ws.WaiterPoll.GetProfile(msg.To)
+
+	if err != nil {
 		return
 	}
 
@@ -29,12 +35,12 @@ func textMessageFromUserHandler(msg ws.Message) (err error) {
 		}
 	}()
 
-	hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	hash := util.MD5(userClient.UUID + msg.To)
 
 	session := model.CustomerSession{
 		Id:       hash,
 		Uid:      userClient.GetProfile().Id,
-		WaiterID: waiterClient.GetProfile().Id,
+		WaiterID: waiterProfile.Id,
 	}
 
 	// 获取会话
@@ -51,7 +57,7 @@ func textMessageFromUserHandler(msg ws.Message) (err error) {
 	sessionItem := model.CustomerSessionItem{
 		SessionID:  session.Id,
 		Type:       model.SessionTypeText,
-		ReceiverID: waiterClient.GetProfile().Id,
+		ReceiverID: waiterProfile.Id,
 		SenderID:   userClient.GetProfile().Id,
 		Payload:    string(raw),
 	}
@@ -62,7 +68,7 @@ func textMessageFromUserHandler(msg ws.Message) (err error) {
 	}
 
 	// 推送消息给客服
-	_ = waiterClient.WriteJSON(ws.Message{
+	_ = ws.WaiterPoll.Send(msg.To, ws.Message{
 		Id:      session.Id,
 		From:    msg.From,
 		To:      msg.To,
@@ -71,12 +77,21 @@ func textMessageFromUserHandler(msg ws.Message) (err error) {
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 	})
 
+	// 抄送给正在监控的主管
+	ws.DefaultNode.Mirror(msg.From, ws.Message{
+		Id:      sessionItem.Id,
+		From:    msg.From,
+		Type:    string(ws.TypeResponseUserMessageText),
+		Payload: msg.Payload,
+		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
+	})
+
 	// 给用户端一个回执
 	_ = userClient.WriteJSON(ws.Message{
 		Id:      sessionItem.Id,
 		Type:    string(ws.TypeResponseUserMessageTextSuccess),
 		From:    userClient.UUID,
-		To:      waiterClient.UUID,
+		To:      msg.To,
 		Payload: msg.Payload,
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 		OpID:    msg.OpID,
@@ -86,10 +101,16 @@ func textMessageFromUserHandler(msg ws.Message) (err error) {
 }
 
 func imageMessageFromUserHandler(msg ws.Message) (err error) {
-	waiterClient := ws.WaiterPoll.Get(msg.To)
 	userClient := ws.UserPoll.Get(msg.From)
 
-	if waiterClient == nil || userClient == nil {
+	if userClient == nil {
+		return
+	}
+
+	// 客服可能连接在其他节点
+	waiterProfile, err := ws.WaiterPoll.GetProfile(msg.To)
+
+	if err != nil {
 		return
 	}
 
@@ -104,12 +125,12 @@ func imageMessageFromUserHandler(msg ws.Message) (err error) {
 		}
 	}()
 
-	hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	hash := util.MD5(userClient.UUID + msg.To)
 
 	session := model.CustomerSession{
 		Id:       hash,
 		Uid:      userClient.GetProfile().Id,
-		WaiterID: waiterClient.GetProfile().Id,
+		WaiterID: waiterProfile.Id,
 	}
 
 	// 获取会话
@@ -126,7 +147,7 @@ func imageMessageFromUserHandler(msg ws.Message) (err error) {
 	sessionItem := model.CustomerSessionItem{
 		SessionID:  session.Id,
 		Type:       model.SessionTypeImage,
-		ReceiverID: waiterClient.GetProfile().Id,
+		ReceiverID: waiterProfile.Id,
 		SenderID:   userClient.GetProfile().Id,
 		Payload:    string(raw),
 	}
@@ -137,7 +158,7 @@ func imageMessageFromUserHandler(msg ws.Message) (err error) {
 	}
 
 	// 推送消息给客服
-	_ = waiterClient.WriteJSON(ws.Message{
+	_ = ws.WaiterPoll.Send(msg.To, ws.Message{
 		Id:      session.Id,
 		From:    msg.From,
 		To:      msg.To,
@@ -146,12 +167,21 @@ func imageMessageFromUserHandler(msg ws.Message) (err error) {
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 	})
 
+	// 抄送给正在监控的主管
+	ws.DefaultNode.Mirror(msg.From, ws.Message{
+		Id:      sessionItem.Id,
+		From:    msg.From,
+		Type:    string(ws.TypeResponseUserMessageImage),
+		Payload: msg.Payload,
+		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
+	})
+
 	// 给用户端一个回执
 	_ = userClient.WriteJSON(ws.Message{
 		Id:      sessionItem.Id,
 		Type:    string(ws.TypeResponseUserMessageImageSuccess),
 		From:    userClient.UUID,
-		To:      waiterClient.UUID,
+		To:      msg.To,
 		Payload: msg.Payload,
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 		OpID:    msg.OpID,
@@ -12,9 +12,15 @@ import (
 
 func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 	waiterClient := ws.WaiterPoll.Get(msg.From)
-	userClient := ws.UserPoll.Get(msg.To)
 
-	if waiterClient == nil || userClient == nil {
+	if waiterClient == nil {
+		return
+	}
+
+	// 用户可能连接在其他节点
+	userProfile, err := ws.UserPoll.GetProfile(msg.To)
+
+	if err != nil {
 		return
 	}
 
@@ -29,11 +35,11 @@ func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 		}
 	}()
 
-	hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	hash := util.MD5(msg.To + waiterClient.UUID)
 
 	session := model.CustomerSession{
 		Id:       hash,
-		Uid:      userClient.GetProfile().Id,
+		Uid:      userProfile.Id,
 		WaiterID: waiterClient.GetProfile().Id,
 	}
 
@@ -51,7 +57,7 @@ func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 	sessionItem := model.CustomerSessionItem{
 		SessionID:  session.Id,
 		Type:       model.SessionTypeText,
-		ReceiverID: userClient.GetProfile().Id,
+		ReceiverID: userProfile.Id,
 		SenderID:   waiterClient.GetProfile().Id,
 		Payload:    string(raw),
 	}
@@ -65,7 +71,7 @@ func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 	// 不管成功与否，因为服务端已经收到
 
 	// 发送给客户端
-	_ = userClient.WriteJSON(ws.Message{
+	_ = ws.UserPoll.Send(msg.To, ws.Message{
 		Id:      session.Id,
 		From:    msg.From,
 		To:      msg.To,
@@ -74,12 +80,21 @@ func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 	})
 
+	// 抄送给正在监控的主管
+	ws.DefaultNode.Mirror(msg.To, ws.Message{
+		Id:      sessionItem.Id,
+		From:    msg.From,
+		Type:    string(ws.TypeResponseUserMessageText),
+		Payload: msg.Payload,
+		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
+	})
+
 	// 给客服端一个回执
 	_ = waiterClient.WriteJSON(ws.Message{
 		Id:      sessionItem.Id,
 		Type:    string(ws.TypeResponseWaiterMessageTextSuccess),
 		From:    waiterClient.UUID,
-		To:      userClient.UUID,
+		To:      msg.To,
 		Payload: msg.Payload,
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 		OpID:    msg.OpID,
@@ -90,9 +105,15 @@ func textMessageFromWaiterHandler(msg ws.Message) (err error) {
 
 func imageMessageFromWaiterHandler(msg ws.Message) (err error) {
 	waiterClient := ws.WaiterPoll.Get(msg.From)
-	userClient := ws.UserPoll.Get(msg.To)
 
-	if waiterClient == nil || userClient == nil {
+	if waiterClient == nil {
+		return
+	}
+
+	// 用户可能连接在其他节点
+	userProfile, err := ws.UserPoll.GetProfile(msg.To)
+
+	if err != nil {
 		return
 	}
 
@@ -107,11 +128,11 @@ func imageMessageFromWaiterHandler(msg ws.Message) (err error) {
 		}
 	}()
 
-	hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	hash := util.MD5(msg.To + waiterClient.UUID)
 
 	session := model.CustomerSession{
 		Id:       hash,
-		Uid:      userClient.GetProfile().Id,
+		Uid:      userProfile.Id,
 		WaiterID: waiterClient.GetProfile().Id,
 	}
 
@@ -129,7 +150,7 @@ func imageMessageFromWaiterHandler(msg ws.Message) (err error) {
 	sessionItem := model.CustomerSessionItem{
 		SessionID:  session.Id,
 		Type:       model.SessionTypeImage,
-		ReceiverID: userClient.GetProfile().Id,
+		ReceiverID: userProfile.Id,
 		SenderID:   waiterClient.GetProfile().Id,
 		Payload:    string(raw),
 	}
@@ -143,7 +164,7 @@ func imageMessageFromWaiterHandler(msg ws.Message) (err error) {
 	// 不管成功与否，因为服务端已经收到
 
 	// 发送给客户端
-	_ = userClient.WriteJSON(ws.Message{
+	_ = ws.UserPoll.Send(msg.To, ws.Message{
 		Id:      session.Id,
 		From:    msg.From,
 		To:      msg.To,
@@ -152,12 +173,21 @@ func imageMessageFromWaiterHandler(msg ws.Message) (err error) {
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 	})
 
+	// 抄送给正在监控的主管
+	ws.DefaultNode.Mirror(msg.To, ws.Message{
+		Id:      sessionItem.Id,
+		From:    msg.From,
+		Type:    string(ws.TypeResponseUserMessageImage),
+		Payload: msg.Payload,
+		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
+	})
+
 	// 给客服端一个回执
 	_ = waiterClient.WriteJSON(ws.Message{
 		Id:      sessionItem.Id,
 		Type:    string(ws.TypeResponseWaiterMessageImageSuccess),
 		From:    waiterClient.UUID,
-		To:      userClient.UUID,
+		To:      msg.To,
 		Payload: msg.Payload,
 		Date:    sessionItem.CreatedAt.Format(time.RFC3339Nano),
 		OpID:    msg.OpID,
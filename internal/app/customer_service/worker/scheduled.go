@@ -2,6 +2,7 @@
 package worker
 
 import (
+	"github.com/axetroy/go-server/internal/app/customer_service/controller/history"
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/util"
@@ -11,101 +12,196 @@ import (
 	"time"
 )
 
-func handle() (err error) {
-	idleWaiter := ws.MatcherPool.GetIdleWaiter()
-	userSocketUUID := ws.MatcherPool.ShiftPending()
-
-	// 没有排队的用户，那么啥也不干
-	if userSocketUUID == nil {
-		return nil
-	}
-
-	// 虽然有用户还在排队，但是客服不空闲，通知下排队的用户排队的情况
-	if idleWaiter == nil {
-		// 把用户重新加入到队列中
-		ws.MatcherPool.Join(*userSocketUUID, true)
-		for location, userID := range ws.MatcherPool.GetPendingQueue() {
-			userClient := ws.UserPoll.Get(userID)
-
-			if userClient == nil {
-				continue
-			}
-
-			// 告诉客户端要排队
-			if err = userClient.WriteJSON(ws.Message{
-				Type: string(ws.TypeResponseUserConnectQueue),
-				To:   userClient.UUID,
-				Date: time.Now().Format(time.RFC3339Nano),
-				Payload: map[string]interface{}{
-					"location": location,
-				},
-			}); err != nil {
-				return
-			}
-		}
+// 通知排队的用户排队的情况
+func notifyQueue() error {
+	queue, err := ws.MatcherPool.GetPendingQueue()
+
+	if err != nil {
+		return err
 	}
 
-	// 如果有空闲的客服和正在排队的用户，那么就匹配他们
-	{
-		waiterID, _ := ws.MatcherPool.Join(*userSocketUUID)
+	for location, userID := range queue {
+		// 用户可能连接在其他节点
+		_ = ws.UserPoll.Send(userID, ws.Message{
+			Type: string(ws.TypeResponseUserConnectQueue),
+			To:   userID,
+			Date: time.Now().Format(time.RFC3339Nano),
+			Payload: ws.QueuePayload{
+				Location: uint(location),
+			},
+		})
+	}
 
-		if waiterID == nil {
-			return exception.NoData.New("找不到 waiter")
-		}
+	return nil
+}
 
-		userClient := ws.UserPoll.Get(*userSocketUUID)
-		waiterClient := ws.WaiterPoll.Get(*waiterID)
+// 分配所有可以被接待的用户, 然后通知排队的用户新的位置
+func handle() error {
+	for {
+		matched, err := match()
 
-		if userClient == nil || waiterClient == nil {
-			return exception.NoData.New("找不到socket连接")
+		if err != nil {
+			log.Println(err)
+		}
+
+		if !matched {
+			break
 		}
+	}
+
+	return notifyQueue()
+}
+
+// 分配一个用户, 没有可以被接待的用户时返回 false
+func match() (matched bool, err error) {
+	// 匹配的状态在 redis 中, 任何一个节点都可以匹配其他节点的用户和客服
+	userSocketUUID, waiterID, err := ws.MatcherPool.Next()
+
+	if err != nil {
+		return false, err
+	}
+
+	// 没有排队的用户，或者没有可以接待的客服
+	if userSocketUUID == nil || waiterID == nil {
+		return false, nil
+	}
+
+	matched = true
+
+	return matched, Open(*userSocketUUID, *waiterID)
+}
+
+// 用户分配到客服之后, 创建会话并且通知双方
+// 转接过来的用户, 新的会话记录转接的信息, 并且把之前的聊天记录推送给新的客服
+func Open(userSocketUUID string, waiterSocketUUID string) (err error) {
+	transfer, err := ws.MatcherPool.TakeTransfer(userSocketUUID)
+
+	if err != nil {
+		return
+	}
+
+	userProfile, err := ws.UserPoll.GetProfile(userSocketUUID)
+
+	if err != nil {
+		// 用户已经断开
+		_ = ws.MatcherPool.Leave(userSocketUUID)
+		return
+	}
 
-		// 连接成功，那么数据库创建一个会话
-		tx := database.Db.Begin()
+	waiterProfile, err := ws.WaiterPoll.GetProfile(waiterSocketUUID)
 
-		defer func() {
-			if err != nil {
-				_ = tx.Rollback()
-			} else {
-				_ = tx.Commit()
-			}
-		}()
+	if err != nil {
+		// 客服已经断开, 正在接待的用户会重新排队
+		_ = ws.MatcherPool.RemoveWaiter(waiterSocketUUID)
+		return
+	}
 
-		hash := util.MD5(userClient.UUID + waiterClient.UUID)
+	// 连接成功，那么数据库创建一个会话
+	tx := database.Db.Begin()
 
-		session := model.CustomerSession{
-			Id:       hash,
-			Uid:      userClient.GetProfile().Id,
-			WaiterID: waiterClient.GetProfile().Id,
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
 		}
+	}()
 
-		// 创建 session
-		if err = tx.Create(&session).Error; err != nil {
-			return
+	hash := util.MD5(userSocketUUID + waiterSocketUUID)
+
+	session := model.CustomerSession{
+		Id:       hash,
+		Uid:      userProfile.Id,
+		WaiterID: waiterProfile.Id,
+	}
+
+	if transfer != nil {
+		session.TransferFrom = &transfer.SessionID
+		session.TransferBy = &transfer.By
+
+		if transfer.Reason != "" {
+			session.TransferReason = &transfer.Reason
 		}
+	}
+
+	count := 0
 
-		if err = userClient.WriteJSON(ws.Message{
-			From:    *waiterID,
-			To:      *userSocketUUID,
-			Type:    string(ws.TypeResponseUserConnectSuccess),
-			Payload: waiterClient.GetProfile(),
-			Date:    time.Now().Format(time.RFC3339Nano),
-		}); err != nil {
+	if err = tx.Model(model.CustomerSession{}).Where("id = ?", hash).Count(&count).Error; err != nil {
+		return
+	}
+
+	if count > 0 {
+		// 转接回之前接待过的客服, 重新打开原来的会话
+		if err = tx.Model(model.CustomerSession{}).Where("id = ?", hash).Updates(map[string]interface{}{
+			"closed_at":       nil,
+			"transferred_at":  nil,
+			"transfer_from":   session.TransferFrom,
+			"transfer_by":     session.TransferBy,
+			"transfer_reason": session.TransferReason,
+		}).Error; err != nil {
 			return
 		}
+	} else if err = tx.Create(&session).Error; err != nil {
+		// 创建 session
+		return
+	}
 
-		if err = waiterClient.WriteJSON(ws.Message{
-			From:    *userSocketUUID,
-			To:      *waiterID,
-			Type:    string(ws.TypeResponseWaiterNewConnection),
-			Payload: userClient.GetProfile(),
-			Date:    time.Now().Format(time.RFC3339Nano),
-		}); err != nil {
-			return
+	// 断线重连之后, 用户凭该令牌恢复会话
+	token, err := ws.UserPoll.IssueResumeToken(userSocketUUID, userProfile.Id)
+
+	if err != nil {
+		return
+	}
+
+	if err = ws.UserPoll.Send(userSocketUUID, ws.Message{
+		From: waiterSocketUUID,
+		To:   userSocketUUID,
+		Type: string(ws.TypeResponseUserConnectSuccess),
+		Payload: ws.ConnectSuccessPayload{
+			ProfilePublic: *waiterProfile,
+			Token:         token,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+	}); err != nil {
+		if err == exception.CustomerNotConnected {
+			_ = ws.MatcherPool.Leave(userSocketUUID)
 		}
+		return
 	}
 
-	return nil
+	if err = ws.WaiterPoll.Send(waiterSocketUUID, ws.Message{
+		From:    userSocketUUID,
+		To:      waiterSocketUUID,
+		Type:    string(ws.TypeResponseWaiterNewConnection),
+		Payload: userProfile,
+		Date:    time.Now().Format(time.RFC3339Nano),
+	}); err != nil {
+		return
+	}
+
+	if transfer == nil {
+		return
+	}
+
+	// 之前的会话已经提交, 不需要在当前的事务中查询
+	list, err := history.GetSessionHistory(transfer.SessionID)
+
+	if err != nil {
+		return
+	}
+
+	return ws.WaiterPoll.Send(waiterSocketUUID, ws.Message{
+		From: userSocketUUID,
+		To:   waiterSocketUUID,
+		Type: string(ws.TypeResponseWaiterTransferIn),
+		Payload: map[string]interface{}{
+			"session_id": transfer.SessionID,
+			"by":         transfer.By,
+			"reason":     transfer.Reason,
+			"data":       list,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+	})
 }
 
 // 任务分配调度器
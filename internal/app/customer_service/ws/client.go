@@ -24,12 +24,16 @@ type Message struct {
 
 type Client struct {
 	sync.RWMutex
+	writer          sync.Mutex            // 连接不支持并发写入, 本地的处理函数和转发的消息可能同时写
 	conn            *websocket.Conn       // Socket 连接
+	id              string                // 连接 ID, 不会改变. 恢复会话之后, 新的连接会沿用原来的 UUID, 需要用它区分新旧连接
 	UUID            string                // Socket 连接的唯一标识符
 	profile         *schema.ProfilePublic // 用户的身份信息，仅用于成功身份认证的连接
 	LatestReceiveAt time.Time             // 最近接收到的消息的时间，用于判断用户是否空闲
 	Closed          bool                  // 连接是否已关闭
 	Ready           bool                  // 该客户端是否已准备就绪，给客服端用的，ready  = true 的时候系统才会分配用户
+	Offline         bool                  // 没有客服接待, 用户的消息转为留言，给用户端用的
+	offlineTimer    *time.Timer           // 排队超时转为留言的定时器, 每个连接同时只有一个
 }
 
 func NewClient(conn *websocket.Conn) *Client {
@@ -39,8 +43,15 @@ func NewClient(conn *websocket.Conn) *Client {
 		log.Printf("%+v\n", err)
 	}
 
+	connID, err := uuid.NewRandom()
+
+	if err != nil {
+		log.Printf("%+v\n", err)
+	}
+
 	return &Client{
 		conn:            conn,
+		id:              connID.String(),
 		UUID:            id.String(),
 		profile:         nil,
 		LatestReceiveAt: time.Now(),
@@ -61,6 +72,12 @@ func (c *Client) RegenerateUUID() {
 	c.UUID = id.String()
 }
 
+func (c *Client) setUUID(UUID string) {
+	c.Lock()
+	defer c.Unlock()
+	c.UUID = UUID
+}
+
 func (c *Client) UpdateProfile(profile schema.ProfilePublic) {
 	c.Lock()
 	defer c.Unlock()
@@ -69,6 +86,8 @@ func (c *Client) UpdateProfile(profile schema.ProfilePublic) {
 
 // 向客户端写数据
 func (c *Client) WriteJSON(data Message) error {
+	c.writer.Lock()
+	defer c.writer.Unlock()
 	return c.conn.WriteJSON(data)
 }
 
@@ -111,11 +130,79 @@ func (c *Client) Close() error {
 	c.Lock()
 	defer c.Unlock()
 	c.Closed = true
+	c.stopOfflineTimer()
 	return c.conn.Close()
 }
 
+func (c *Client) IsClosed() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Closed
+}
+
 func (c *Client) SetReady(ready bool) {
 	c.Lock()
 	defer c.Unlock()
 	c.Ready = ready
 }
+
+func (c *Client) SetOffline(offline bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.Offline = offline
+}
+
+func (c *Client) IsOffline() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Offline
+}
+
+// 排队超时之后执行 f, 会取消之前的定时器
+func (c *Client) SetOfflineTimer(d time.Duration, f func()) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stopOfflineTimer()
+
+	var timer *time.Timer
+
+	timer = time.AfterFunc(d, func() {
+		c.Lock()
+		current := c.offlineTimer == timer
+		if current {
+			c.offlineTimer = nil
+		}
+		c.Unlock()
+
+		// 定时器已经被取消或者替换, 但是已经开始执行了
+		if current {
+			f()
+		}
+	})
+
+	c.offlineTimer = timer
+}
+
+// 取消排队超时的定时器, 例如已经分配了客服或者重新请求连接
+func (c *Client) StopOfflineTimer() {
+	c.Lock()
+	defer c.Unlock()
+	c.stopOfflineTimer()
+}
+
+func (c *Client) stopOfflineTimer() {
+	if c.offlineTimer != nil {
+		c.offlineTimer.Stop()
+		c.offlineTimer = nil
+	}
+}
+
+// 把消息写给本地的连接. 连接成功说明已经分配了客服, 不再需要排队超时的定时器
+func (c *Client) deliver(msg Message) error {
+	if msg.Type == string(TypeResponseUserConnectSuccess) {
+		c.StopOfflineTimer()
+	}
+
+	return c.WriteJSON(msg)
+}
@@ -1,23 +1,67 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
 package ws
 
-import "sync"
+import (
+	"context"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/schema"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"strings"
+	"sync"
+)
 
 var (
-	UserPoll   = NewPool()
-	WaiterPoll = NewPool()
+	UserPoll    = DefaultNode.UserPoll
+	WaiterPoll  = DefaultNode.WaiterPoll
+	MatcherPool = DefaultNode.MatcherPool
 )
 
+// 连接池只持有连接到当前节点的 socket
+// 属于某个节点时, 在线的连接和身份信息同时登记到 redis 中, 用于其他节点查询和转发消息
+// {prefix}online:{role}            hash   socket UUID -> 所在的位置, 即 {节点 ID}/{连接 ID}
+// {prefix}profile:{role}           hash   socket UUID -> 身份信息
+// {prefix}account:{role}:{user id} set    该帐号的所有 socket UUID
 type Pool struct {
 	sync.RWMutex
+	node      *Node
+	role      role
 	clients   map[*Client]bool // 已连接的客户端
 	Broadcast chan Message     // 广播频道
 }
 
+// 删除连接的登记
+// 登记在其他连接时(会话已经被新的连接恢复)不删除
+// 没有登记, 但是会话正在等待恢复时, 保留身份信息
+var forgetScript = nativeRedis.NewScript(`
+local current = redis.call("HGET", KEYS[1], ARGV[1])
+
+if current then
+	if current ~= ARGV[2] then
+		return 0
+	end
+	redis.call("HDEL", KEYS[1], ARGV[1])
+elseif redis.call("EXISTS", KEYS[4]) == 1 then
+	return 0
+end
+
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("SREM", KEYS[3], ARGV[1])
+
+return 1
+`)
+
 // 添加一个连接
-func (c *Pool) Add(client *Client) {
-	c.RLock()
-	defer c.RUnlock()
+func (c *Pool) Add(client *Client) error {
+	c.Lock()
 	c.clients[client] = true
+	c.Unlock()
+
+	if c.node == nil {
+		return nil
+	}
+
+	return c.node.client().HSet(context.Background(), c.onlineKey(), client.UUID, c.location(client)).Err()
 }
 
 // 获取连接
@@ -32,46 +76,303 @@ func (c *Pool) Get(UUID string) *Client {
 	return nil
 }
 
-// 获取连接
-func (c *Pool) GetWaiterFromUserID(UserID string) (list []*Client) {
+// 删除连接
+func (c *Pool) Remove(client *Client) error {
+	c.Lock()
+	delete(c.clients, client)
+	c.Unlock()
+
+	_ = client.Close()
+
+	if c.node == nil {
+		return nil
+	}
+
+	var accountID string
+
+	if profile := client.GetProfile(); profile != nil {
+		accountID = profile.Id
+	}
+
+	return c.forget(client.UUID, c.location(client), accountID)
+}
+
+// 从 redis 中删除连接的登记, location 为空时只清理没有登记在线的连接
+func (c *Pool) forget(UUID string, location string, accountID string) error {
+	ctx := context.Background()
+
+	// 不知道所属的帐号时, 从登记的身份信息中获取
+	if accountID == "" {
+		if profile, err := c.GetProfile(UUID); err == nil {
+			accountID = profile.Id
+		}
+	}
+
+	return forgetScript.Run(ctx, c.node.client(), []string{c.onlineKey(), c.profileKey(), c.accountKey(accountID), c.suspendKey(UUID)}, UUID, location).Err()
+}
+
+// 连接是否已经被其他连接接管, 即用户在新的连接恢复了会话
+func (c *Pool) IsReplaced(client *Client) (bool, error) {
+	if c.node == nil {
+		return false, nil
+	}
+
+	location, err := c.node.client().HGet(context.Background(), c.onlineKey(), client.UUID).Result()
+
+	if err == nativeRedis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return location != c.location(client), nil
+}
+
+// 获取本节点的连接长度
+func (c *Pool) Length() int {
 	c.RLock()
 	defer c.RUnlock()
-	for client := range c.clients {
-		profile := client.GetProfile()
+	return len(c.clients)
+}
+
+// 获取所有节点的连接数量
+func (c *Pool) Count() (int, error) {
+	if c.node == nil {
+		return c.Length(), nil
+	}
+
+	n, err := c.node.client().HLen(context.Background(), c.onlineKey()).Result()
+
+	return int(n), err
+}
+
+// 更新连接的身份信息
+func (c *Pool) UpdateProfile(client *Client, profile schema.ProfilePublic) error {
+	client.UpdateProfile(profile)
+
+	if c.node == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(profile)
+
+	if err != nil {
+		return err
+	}
 
-		if profile == nil {
-			continue
+	ctx := context.Background()
+
+	_, err = c.node.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.HSet(ctx, c.profileKey(), client.UUID, raw)
+		pipe.SAdd(ctx, c.accountKey(profile.Id), client.UUID)
+		return nil
+	})
+
+	return err
+}
+
+// 获取连接的身份信息, 连接可以在其他节点
+func (c *Pool) GetProfile(UUID string) (*schema.ProfilePublic, error) {
+	if client := c.Get(UUID); client != nil {
+		if profile := client.GetProfile(); profile != nil {
+			return profile, nil
 		}
+		return nil, exception.UserNotLogin
+	}
+
+	if c.node == nil {
+		return nil, exception.CustomerNotConnected
+	}
+
+	raw, err := c.node.client().HGet(context.Background(), c.profileKey(), UUID).Bytes()
 
-		if profile.Id == UserID {
-			list = append(list, client)
+	if err == nativeRedis.Nil {
+		return nil, exception.CustomerNotConnected
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var profile schema.ProfilePublic
+
+	if err := json.Unmarshal(raw, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// 获取帐号的所有连接, 包括其他节点的连接
+func (c *Pool) GetClientsFromUserID(userID string) ([]string, error) {
+	if c.node == nil {
+		var list []string
+
+		c.RLock()
+		defer c.RUnlock()
+
+		for client := range c.clients {
+			if profile := client.GetProfile(); profile != nil && profile.Id == userID {
+				list = append(list, client.UUID)
+			}
 		}
+
+		return list, nil
 	}
-	return
+
+	return c.node.client().SMembers(context.Background(), c.accountKey(userID)).Result()
 }
 
-// 删除连接
-func (c *Pool) Remove(UUID string) {
-	c.RLock()
-	defer c.RUnlock()
-	for client := range c.clients {
-		if client.UUID == UUID {
+// 重新生成连接的 UUID, 并更新 redis 中的登记
+func (c *Pool) RegenerateUUID(client *Client) error {
+	old := client.UUID
+
+	client.RegenerateUUID()
+
+	if c.node == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	_, err := c.node.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.HDel(ctx, c.onlineKey(), old)
+		pipe.HDel(ctx, c.profileKey(), old)
+		pipe.HSet(ctx, c.onlineKey(), client.UUID, c.location(client))
+
+		if profile := client.GetProfile(); profile != nil {
+			raw, err := json.Marshal(profile)
+
+			if err != nil {
+				return err
+			}
+
+			pipe.HSet(ctx, c.profileKey(), client.UUID, raw)
+			pipe.SRem(ctx, c.accountKey(profile.Id), old)
+			pipe.SAdd(ctx, c.accountKey(profile.Id), client.UUID)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// 发送消息给连接, 连接在其他节点时通过 redis 转发
+func (c *Pool) Send(UUID string, msg Message) error {
+	return c.send(UUID, msg, false)
+}
+
+// 发送消息给连接, 然后关闭连接
+func (c *Pool) Kick(UUID string, msg Message) error {
+	return c.send(UUID, msg, true)
+}
+
+func (c *Pool) send(UUID string, msg Message, close bool) error {
+	if client := c.Get(UUID); client != nil {
+		err := client.deliver(msg)
+
+		if close {
 			_ = client.Close()
-			delete(c.clients, client)
 		}
+
+		return err
+	}
+
+	if c.node == nil {
+		return exception.CustomerNotConnected
+	}
+
+	ctx := context.Background()
+
+	location, err := c.node.client().HGet(ctx, c.onlineKey(), UUID).Result()
+
+	if err != nil && err != nativeRedis.Nil {
+		return err
+	}
+
+	// 没有登记, 或者登记在当前节点但是本地已经没有这个连接了
+	if err == nativeRedis.Nil || nodeOf(location) == c.node.ID {
+		_ = c.forget(UUID, location, "")
+		return exception.CustomerNotConnected
 	}
+
+	receivers, err := c.publish(nodeOf(location), UUID, msg, close)
+
+	if err != nil {
+		return err
+	}
+
+	// 没有节点订阅, 说明该节点已经下线, 清理残留的登记
+	if receivers == 0 {
+		_ = c.forget(UUID, location, "")
+		return exception.CustomerNotConnected
+	}
+
+	return nil
 }
 
-// 获取连接长度
-func (c *Pool) Length() int {
+// 通过 redis 把消息转发给其他节点上的连接, 返回收到消息的节点数量
+func (c *Pool) publish(nodeID string, UUID string, msg Message, close bool) (int64, error) {
+	raw, err := json.Marshal(envelope{
+		Role:    c.role,
+		To:      UUID,
+		Close:   close,
+		Message: msg,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return c.node.client().Publish(context.Background(), c.node.channel(nodeID), raw).Result()
+}
+
+// 关闭本节点的所有连接
+func (c *Pool) closeAll() {
 	c.RLock()
 	defer c.RUnlock()
-	return len(c.clients)
+	for client := range c.clients {
+		_ = client.Close()
+	}
+}
+
+// 连接在 redis 中登记的位置
+func (c *Pool) location(client *Client) string {
+	return c.node.ID + "/" + client.id
+}
+
+// 从登记的位置中获取节点 ID
+func nodeOf(location string) string {
+	return strings.SplitN(location, "/", 2)[0]
+}
+
+func (c *Pool) onlineKey() string {
+	return c.node.prefix + "online:" + string(c.role)
 }
 
+func (c *Pool) profileKey() string {
+	return c.node.prefix + "profile:" + string(c.role)
+}
+
+func (c *Pool) accountKey(userID string) string {
+	return c.node.prefix + "account:" + string(c.role) + ":" + userID
+}
+
+// 只在本地使用的连接池
 func NewPool() *Pool {
 	return &Pool{
 		clients:   map[*Client]bool{},
 		Broadcast: make(chan Message),
 	}
 }
+
+func newPool(node *Node, r role) *Pool {
+	pool := NewPool()
+
+	pool.node = node
+	pool.role = r
+
+	return pool
+}
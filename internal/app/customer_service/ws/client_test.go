@@ -0,0 +1,39 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_SetOfflineTimer(t *testing.T) {
+	client := ws.NewClient(nil)
+
+	var first, second int32
+
+	// 重新设置之后, 之前的定时器不再执行
+	client.SetOfflineTimer(time.Millisecond*50, func() {
+		atomic.AddInt32(&first, 1)
+	})
+	client.SetOfflineTimer(time.Millisecond*50, func() {
+		atomic.AddInt32(&second, 1)
+	})
+
+	time.Sleep(time.Millisecond * 150)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&first))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&second))
+
+	// 取消之后不再执行
+	client.SetOfflineTimer(time.Millisecond*50, func() {
+		atomic.AddInt32(&first, 1)
+	})
+	client.StopOfflineTimer()
+
+	time.Sleep(time.Millisecond * 150)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&first))
+}
@@ -2,225 +2,660 @@
 package ws
 
 import (
-	"sync"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	nativeRedis "github.com/go-redis/redis/v8"
 )
 
+// 匹配的状态保存在 redis 中, 多个节点共享
+// {prefix}queue           zset   排队的用户 socket, 分数越小越靠前, 优先级高的用户排在前面, 相同优先级先到先得
+// {prefix}topic           hash   用户 socket -> 咨询的话题
+// {prefix}waiters         set    已添加的客服 socket
+// {prefix}ready           zset   已就绪的客服 socket, 分数为正在接待的用户数量
+// {prefix}capacity        hash   客服 socket -> 最多接待的用户数量, 没有则使用 Max
+// {prefix}skills:{uuid}   set    客服的技能
+// {prefix}assigned        hash   客服 socket -> 最近一次分配用户的序号, 越小说明越久没有分配过用户
+// {prefix}seq             string 排队和分配用户的序号
+// {prefix}waiter:{uuid}   list   客服正在接待的用户 socket
+// {prefix}user:{uuid}     string 接待该用户的客服 socket
+// {prefix}exclude         hash   用户 socket -> 不再分配的客服 socket, 即转接之前接待该用户的客服
+// {prefix}transfer        hash   用户 socket -> 转接的信息, 分配到新的客服时取出
+// {prefix}watchers:{uuid} set    正在监控该用户的会话的主管 socket
+// {prefix}watching:{uuid} set    主管正在监控的用户 socket
+// 脚本中根据前缀拼接 key, 所以不支持 redis 集群模式
+
+// 每个脚本共用的函数, KEYS[1] 为排队的队列, KEYS[2] 为已就绪的客服, ARGV[1] 为前缀, ARGV[2] 为默认的接待人数
+const matchFunctions = `
+local prefix, max = ARGV[1], tonumber(ARGV[2])
+
+-- 可以接待该用户的客服: 拥有用户需要的技能, 并且还没有接满
+-- 选择正在接待的用户最少的, 相同时选择最久没有分配过用户的
+local function pick(user, ready)
+	local topic = redis.call("HGET", prefix .. "topic", user)
+	local exclude = redis.call("HGET", prefix .. "exclude", user)
+	local best, bestLoad, bestSeq
+
+	for i = 1, #ready, 2 do
+		local waiter, load = ready[i], tonumber(ready[i + 1])
+		local capacity = tonumber(redis.call("HGET", prefix .. "capacity", waiter)) or max
+
+		if load < capacity and waiter ~= exclude and (not topic or redis.call("SISMEMBER", prefix .. "skills:" .. waiter, topic) == 1) then
+			local seq = tonumber(redis.call("HGET", prefix .. "assigned", waiter)) or 0
+
+			if not best or load < bestLoad or (load == bestLoad and seq < bestSeq) then
+				best, bestLoad, bestSeq = waiter, load, seq
+			end
+		end
+	end
+
+	return best
+end
+
+local function assign(user, waiter)
+	redis.call("ZINCRBY", KEYS[2], 1, waiter)
+	redis.call("RPUSH", prefix .. "waiter:" .. waiter, user)
+	redis.call("SET", prefix .. "user:" .. user, waiter)
+	redis.call("ZREM", KEYS[1], user)
+	redis.call("HDEL", prefix .. "exclude", user)
+	redis.call("HSET", prefix .. "assigned", waiter, redis.call("INCR", prefix .. "seq"))
+end
+
+-- 放到队列的最前面
+local function prepend(user)
+	local first = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+	redis.call("ZADD", KEYS[1], string.format("%.0f", (tonumber(first[2]) or 0) - 1), user)
+end
+`
+
+// 用户加入队列, 排在前面的用户都不能被接待时, 把用户分配给合适的客服
+// 返回 {客服, 排队的位置}, 没有分配到客服时, 客服为空字符串
+var joinScript = nativeRedis.NewScript(matchFunctions + `
+local user, topic, priority = ARGV[3], ARGV[4], tonumber(ARGV[5])
+
+local waiter = redis.call("GET", prefix .. "user:" .. user)
+
+if waiter then
+	return {waiter, 0}
+end
+
+if not redis.call("ZSCORE", KEYS[1], user) then
+	if ARGV[6] == "1" then
+		prepend(user)
+	else
+		redis.call("ZADD", KEYS[1], string.format("%.0f", redis.call("INCR", prefix .. "seq") - priority * 1e12), user)
+	end
+
+	if topic ~= "" then
+		redis.call("HSET", prefix .. "topic", user, topic)
+	end
+end
+
+local ready = redis.call("ZRANGE", KEYS[2], 0, -1, "WITHSCORES")
+
+for i, id in ipairs(redis.call("ZRANGE", KEYS[1], 0, -1)) do
+	local idle = pick(id, ready)
+
+	if id == user then
+		if idle then
+			assign(user, idle)
+			return {idle, 0}
+		end
+		return {"", i - 1}
+	end
+
+	-- 排在前面的用户可以被接待, 交给调度器按顺序分配
+	if idle then
+		return {"", redis.call("ZRANK", KEYS[1], user)}
+	end
+end
+
+return {"", 0}
+`)
+
+// 按顺序把第一个可以被接待的用户分配给合适的客服
+// 返回 {用户, 客服}, 没有可以被接待的用户时返回空数组
+var nextScript = nativeRedis.NewScript(matchFunctions + `
+local ready = redis.call("ZRANGE", KEYS[2], 0, -1, "WITHSCORES")
+
+if #ready == 0 then
+	return {}
+end
+
+for _, user in ipairs(redis.call("ZRANGE", KEYS[1], 0, -1)) do
+	local waiter = pick(user, ready)
+
+	if waiter then
+		assign(user, waiter)
+		return {user, waiter}
+	end
+end
+
+return {}
+`)
+
+// 获取最空闲的客服, 只考虑不指定话题的用户
+var idleScript = nativeRedis.NewScript(matchFunctions + `
+return pick("", redis.call("ZRANGE", KEYS[2], 0, -1, "WITHSCORES")) or ""
+`)
+
+// 用户离开, 返回原来接待的客服
+var leaveScript = nativeRedis.NewScript(`
+local prefix, user = ARGV[1], ARGV[2]
+
+redis.call("ZREM", KEYS[1], user)
+redis.call("HDEL", prefix .. "topic", user)
+redis.call("HDEL", prefix .. "exclude", user)
+redis.call("HDEL", prefix .. "transfer", user)
+
+for _, supervisor in ipairs(redis.call("SMEMBERS", prefix .. "watchers:" .. user)) do
+	redis.call("SREM", prefix .. "watching:" .. supervisor, user)
+end
+
+redis.call("DEL", prefix .. "watchers:" .. user)
+
+local waiter = redis.call("GET", prefix .. "user:" .. user)
+
+if not waiter then
+	return ""
+end
+
+redis.call("DEL", prefix .. "user:" .. user)
+
+if redis.call("LREM", prefix .. "waiter:" .. waiter, 0, user) > 0 and redis.call("ZSCORE", KEYS[2], waiter) then
+	redis.call("ZINCRBY", KEYS[2], -1, waiter)
+end
+
+return waiter
+`)
+
+// 只有还在排队的用户才会离开, 已经被分配了客服的用户不受影响
+var withdrawScript = nativeRedis.NewScript(`
+local prefix, user = ARGV[1], ARGV[2]
+
+if redis.call("ZREM", KEYS[1], user) == 0 then
+	return 0
+end
+
+redis.call("HDEL", prefix .. "topic", user)
+redis.call("HDEL", prefix .. "exclude", user)
+redis.call("HDEL", prefix .. "transfer", user)
+
+return 1
+`)
+
+// 移除客服, 正在接待的用户按顺序放到队列的最前面
+var removeWaiterScript = nativeRedis.NewScript(matchFunctions + `
+local waiter = ARGV[3]
+
+local users = redis.call("LRANGE", prefix .. "waiter:" .. waiter, 0, -1)
+
+for _, user in ipairs(users) do
+	redis.call("DEL", prefix .. "user:" .. user)
+	prepend(user)
+end
+
+redis.call("DEL", prefix .. "waiter:" .. waiter, prefix .. "skills:" .. waiter)
+redis.call("HDEL", prefix .. "capacity", waiter)
+redis.call("HDEL", prefix .. "assigned", waiter)
+redis.call("ZREM", KEYS[2], waiter)
+redis.call("SREM", prefix .. "waiters", waiter)
+
+return users
+`)
+
+// 把正在接待的用户转接出去, 用户的 socket 不变
+// ARGV[3] 用户, ARGV[4] 原来的客服, 为空则不限制. ARGV[5] 目标客服, 为空则重新排队
+// ARGV[6] 重新排队时咨询的话题, ARGV[7] 转接的信息, ARGV[8] 为 "1" 时不检查目标客服是否就绪和接待人数
+// 返回 0 说明用户不是由原来的客服接待, -1 说明目标客服不能接待, 1 说明转接成功
+var transferScript = nativeRedis.NewScript(matchFunctions + `
+local user, from, to, topic, info, force = ARGV[3], ARGV[4], ARGV[5], ARGV[6], ARGV[7], ARGV[8] == "1"
+
+local current = redis.call("GET", prefix .. "user:" .. user)
+
+if not current or (from ~= "" and current ~= from) then
+	return 0
+end
+
+if to == current then
+	return -1
+end
+
+if to ~= "" and not force then
+	local load = tonumber(redis.call("ZSCORE", KEYS[2], to))
+	local capacity = tonumber(redis.call("HGET", prefix .. "capacity", to)) or max
+
+	if not load or load >= capacity then
+		return -1
+	end
+end
+
+if redis.call("LREM", prefix .. "waiter:" .. current, 0, user) > 0 and redis.call("ZSCORE", KEYS[2], current) then
+	redis.call("ZINCRBY", KEYS[2], -1, current)
+end
+
+redis.call("DEL", prefix .. "user:" .. user)
+redis.call("HSET", prefix .. "transfer", user, info)
+
+-- 重新排队, 排在最前面, 不会再分配给原来的客服
+if to == "" then
+	if topic ~= "" then
+		redis.call("HSET", prefix .. "topic", user, topic)
+	else
+		redis.call("HDEL", prefix .. "topic", user)
+	end
+
+	redis.call("HSET", prefix .. "exclude", user, current)
+	prepend(user)
+
+	return 1
+end
+
+-- 主管接管时可能还没有就绪, 只有就绪的客服才更新接待人数
+redis.call("SADD", prefix .. "waiters", to)
+
+if redis.call("ZSCORE", KEYS[2], to) then
+	redis.call("ZINCRBY", KEYS[2], 1, to)
+end
+
+redis.call("RPUSH", prefix .. "waiter:" .. to, user)
+redis.call("SET", prefix .. "user:" .. user, to)
+redis.call("HSET", prefix .. "assigned", to, redis.call("INCR", prefix .. "seq"))
+
+return 1
+`)
+
+// 设置客服是否就绪, 就绪的客服才会被分配用户
+var readyScript = nativeRedis.NewScript(`
+local prefix, waiter = ARGV[1], ARGV[2]
+
+if ARGV[3] == "1" then
+	redis.call("SADD", KEYS[1], waiter)
+	redis.call("ZADD", KEYS[2], redis.call("LLEN", prefix .. "waiter:" .. waiter), waiter)
+else
+	redis.call("ZREM", KEYS[2], waiter)
+end
+
+return 1
+`)
+
+var errInvalidReply = errors.New("invalid matcher reply")
+
+// 用户对客服的要求
+type Demand struct {
+	Topic    string // 咨询的话题, 只会分配给拥有该技能的客服, 为空则不限制
+	Priority int    // 优先级, 越大越优先, 例如用户的等级. 相同优先级的用户先到先得
+}
+
+// 转接的信息, 分配到新的客服之后写入新的会话
+type Transfer struct {
+	SessionID string `json:"session_id"` // 转接之前的会话 ID
+	By        string `json:"by"`         // 发起转接的客服或者主管的用户 ID
+	Reason    string `json:"reason"`     // 转接的原因
+}
+
+// 客服的接待能力
+type Ability struct {
+	Skills   []string // 技能标签, 例如语言或者产品线
+	Capacity int      // 最多同时接待多少个用户, 为 0 则使用 Max
+}
+
 type Matcher struct {
-	sync.RWMutex
-	Broadcast       chan bool               // 调度器，当收到通知时，就安排客服接待排队的用户
-	Max             int                     // 一个客服最多接待多少个用户
-	matcher         map[string][]string     // 已经匹配的 socket对, 以客服的 UUID 作为 key
-	pending         []string                // 排队的用户 socket
-	getWaiterClient func(id string) *Client // 内部函数，获取 socket 连接
-	getUserClient   func(id string) *Client // 内部函数，获取 socket 连接
+	Broadcast chan bool                  // 调度器，当收到通知时，就安排客服接待排队的用户
+	Max       int                        // 一个客服默认最多接待多少个用户
+	client    func() nativeRedis.Cmdable // redis 客户端在服务启动时才连接, 所以在使用时再获取
+	prefix    string
 }
 
-func NewMatcher() *Matcher {
+func NewMatcher(client func() nativeRedis.Cmdable, namespace string) *Matcher {
 	return &Matcher{
 		Max:       5, // 一个客服最多接待 5 个用户
-		matcher:   map[string][]string{},
 		Broadcast: make(chan bool),
-		pending:   []string{},
-		getWaiterClient: func(id string) *Client {
-			return WaiterPoll.Get(id)
-		},
-		getUserClient: func(id string) *Client {
-			return UserPoll.Get(id)
-		},
+		client:    client,
+		prefix:    namespace + ":",
 	}
 }
 
-var MatcherPool = NewMatcher()
+func (c *Matcher) queueKey() string {
+	return c.prefix + "queue"
+}
+
+func (c *Matcher) waitersKey() string {
+	return c.prefix + "waiters"
+}
 
-func (c *Matcher) SetWaiterClientFunc(fn func(id string) *Client) {
-	c.RLock()
-	defer c.RUnlock()
-	c.getWaiterClient = fn
+func (c *Matcher) readyKey() string {
+	return c.prefix + "ready"
 }
 
-func (c *Matcher) SetUserClientFunc(fn func(id string) *Client) {
-	c.RLock()
-	defer c.RUnlock()
-	c.getUserClient = fn
+func (c *Matcher) waiterKey(waiterSocketUUID string) string {
+	return c.prefix + "waiter:" + waiterSocketUUID
 }
 
-func (c *Matcher) GetPendingLength() int {
-	c.RLock()
-	defer c.RUnlock()
-	return len(c.pending)
+func (c *Matcher) userKey(userSocketUUID string) string {
+	return c.prefix + "user:" + userSocketUUID
 }
 
-func (c *Matcher) GetPendingQueue() []string {
-	return c.pending
+func (c *Matcher) skillsKey(waiterSocketUUID string) string {
+	return c.prefix + "skills:" + waiterSocketUUID
 }
 
-func (c *Matcher) ShiftPending() *string {
-	c.RLock()
-	defer c.RUnlock()
-	if len(c.pending) == 0 {
+func (c *Matcher) capacityKey() string {
+	return c.prefix + "capacity"
+}
+
+func (c *Matcher) transferKey() string {
+	return c.prefix + "transfer"
+}
+
+// 脚本中使用的 key
+func (c *Matcher) keys() []string {
+	return []string{c.queueKey(), c.readyKey()}
+}
+
+func optional(s string) *string {
+	if s == "" {
 		return nil
 	}
-	userSocketUUID := c.pending[len(c.pending)-1]
 
-	c.pending = c.pending[1:]
+	return &s
+}
+
+func (c *Matcher) GetPendingLength() (int, error) {
+	n, err := c.client().ZCard(context.Background(), c.queueKey()).Result()
+
+	return int(n), err
+}
+
+// 按排队的顺序获取所有用户
+func (c *Matcher) GetPendingQueue() ([]string, error) {
+	return c.client().ZRange(context.Background(), c.queueKey(), 0, -1).Result()
+}
+
+// 取出排在第一位的用户
+func (c *Matcher) ShiftPending() (*string, error) {
+	list, err := c.client().ZPopMin(context.Background(), c.queueKey()).Result()
+
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	userSocketUUID, _ := list[0].Member.(string)
 
-	return &userSocketUUID
+	return &userSocketUUID, nil
 }
 
-func (c *Matcher) GetMatcher() map[string][]string {
-	return c.matcher
+// 获取所有客服正在接待的用户, 以客服的 UUID 作为 key
+func (c *Matcher) GetMatcher() (map[string][]string, error) {
+	ctx := context.Background()
+
+	waiters, err := c.client().SMembers(ctx, c.waitersKey()).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*nativeRedis.StringSliceCmd, len(waiters))
+
+	if _, err := c.client().Pipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		for i, waiter := range waiters {
+			cmds[i] = pipe.LRange(ctx, c.waiterKey(waiter), 0, -1)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result := map[string][]string{}
+
+	for i, waiter := range waiters {
+		result[waiter] = cmds[i].Val()
+	}
+
+	return result, nil
 }
 
 // 用户加入匹配池
 // 返回接待的客服 UUID
 // 如果返回空，那么说明没有找到合适的客服，加入等待队列
-// 第二个参数用于插入到最前面的队列，出于最优先级
+// 第二个参数为用户对客服的要求, 不传则不限制
 // 返回 int 代表出于队列的地 n 位
-func (c *Matcher) Join(userSocketUUID string, prepend ...bool) (*string, uint) {
-	c.RLock()
-	defer c.RUnlock()
-	idleWaiter := c.GetIdleWaiter()
-
-	// 如果找不到最佳的客服，那么先加入队列
-	if idleWaiter == nil {
-		// 确保当前连接不在队列中
-		for index, id := range c.pending {
-			if id == userSocketUUID {
-				return nil, uint(index)
-			}
-		}
+func (c *Matcher) Join(userSocketUUID string, demand ...Demand) (*string, uint, error) {
+	return c.join(userSocketUUID, false, demand...)
+}
 
-		if len(prepend) > 0 && prepend[0] {
-			c.pending = append([]string{userSocketUUID}, c.pending...)
-		} else {
-			c.pending = append(c.pending, userSocketUUID)
-		}
-		return nil, uint(len(c.pending) - 1)
-	} else {
-		for waiter, users := range c.matcher {
-			if waiter == *idleWaiter {
-				c.matcher[waiter] = append(users, userSocketUUID)
-				return idleWaiter, 0
-			}
-		}
+// 用户加入匹配池, 并且插入到队列的最前面, 出于最优先级
+func (c *Matcher) Prepend(userSocketUUID string, demand ...Demand) (*string, uint, error) {
+	return c.join(userSocketUUID, true, demand...)
+}
+
+func (c *Matcher) join(userSocketUUID string, prepend bool, demand ...Demand) (*string, uint, error) {
+	var d Demand
+
+	if len(demand) > 0 {
+		d = demand[0]
 	}
 
-	return nil, 0
+	flag := "0"
+
+	if prepend {
+		flag = "1"
+	}
+
+	reply, err := joinScript.Run(context.Background(), c.client(), c.keys(), c.prefix, c.Max, userSocketUUID, d.Topic, d.Priority, flag).Result()
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list, ok := reply.([]interface{})
+
+	if !ok || len(list) != 2 {
+		return nil, 0, errInvalidReply
+	}
+
+	waiter, _ := list[0].(string)
+	location, _ := list[1].(int64)
+
+	return optional(waiter), uint(location), nil
 }
 
-// 用户离开匹配池
-func (c *Matcher) Leave(userSocketUUID string) {
-	c.RLock()
-	defer c.RUnlock()
-	for waiter, users := range c.matcher {
-		for index, user := range users {
-			if user == userSocketUUID {
-				c.matcher[waiter] = append(c.matcher[waiter][:index], c.matcher[waiter][index+1:]...)
-			}
-		}
+// 按排队的顺序, 把第一个可以被接待的用户分配给合适的客服
+// 没有可以被接待的用户时, 返回空
+func (c *Matcher) Next() (userSocketUUID *string, waiterSocketUUID *string, err error) {
+	reply, err := nextScript.Run(context.Background(), c.client(), c.keys(), c.prefix, c.Max).Result()
+
+	if err != nil {
+		return nil, nil, err
 	}
 
-	for index, id := range c.pending {
-		if id == userSocketUUID {
-			c.pending = append(c.pending[:index], c.pending[index+1:]...)
-		}
+	list, ok := reply.([]interface{})
+
+	if !ok {
+		return nil, nil, errInvalidReply
+	}
+
+	if len(list) != 2 {
+		return nil, nil, nil
 	}
+
+	user, _ := list[0].(string)
+	waiter, _ := list[1].(string)
+
+	return optional(user), optional(waiter), nil
+}
+
+// 用户离开匹配池
+func (c *Matcher) Leave(userSocketUUID string) error {
+	return leaveScript.Run(context.Background(), c.client(), c.keys(), c.prefix, userSocketUUID).Err()
+}
+
+// 用户退出排队, 返回用户是否还在排队
+// 和调度器同时进行时, 用户要么被分配了客服, 要么退出排队, 不会两者都发生
+func (c *Matcher) Withdraw(userSocketUUID string) (bool, error) {
+	n, err := withdrawScript.Run(context.Background(), c.client(), c.keys(), c.prefix, userSocketUUID).Int()
+
+	return n == 1, err
 }
 
 // 获取这个客服当前服务的用户
-func (c *Matcher) GetMyUsers(waiterSocketUUID string) []string {
-	c.RLock()
-	defer c.RUnlock()
-
-	for id, users := range c.matcher {
-		if id == waiterSocketUUID {
-			if len(users) > c.Max {
-				return users[:c.Max]
-			} else {
-				return users
+func (c *Matcher) GetMyUsers(waiterSocketUUID string) ([]string, error) {
+	return c.client().LRange(context.Background(), c.waiterKey(waiterSocketUUID), 0, -1).Result()
+}
+
+// 添加客服, 第二个参数为客服的接待能力, 不传则没有技能, 使用默认的接待人数
+func (c *Matcher) AddWaiter(waiterSocketUUID string, ability ...Ability) error {
+	var a Ability
+
+	if len(ability) > 0 {
+		a = ability[0]
+	}
+
+	ctx := context.Background()
+
+	_, err := c.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.SAdd(ctx, c.waitersKey(), waiterSocketUUID)
+		pipe.Del(ctx, c.skillsKey(waiterSocketUUID))
+
+		if len(a.Skills) > 0 {
+			skills := make([]interface{}, len(a.Skills))
+
+			for i, skill := range a.Skills {
+				skills[i] = skill
 			}
+
+			pipe.SAdd(ctx, c.skillsKey(waiterSocketUUID), skills...)
 		}
+
+		if a.Capacity > 0 {
+			pipe.HSet(ctx, c.capacityKey(), waiterSocketUUID, a.Capacity)
+		} else {
+			pipe.HDel(ctx, c.capacityKey(), waiterSocketUUID)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// 移除客服
+// 还出于连接的用户，放入到队列中, 并且优先放在第一排
+func (c *Matcher) RemoveWaiter(waiterSocketUUID string) error {
+	return removeWaiterScript.Run(context.Background(), c.client(), c.keys(), c.prefix, c.Max, waiterSocketUUID).Err()
+}
+
+// 设置客服是否就绪
+func (c *Matcher) SetReady(waiterSocketUUID string, ready bool) error {
+	flag := "0"
+
+	if ready {
+		flag = "1"
 	}
 
-	return []string{}
+	return readyScript.Run(context.Background(), c.client(), []string{c.waitersKey(), c.readyKey()}, c.prefix, waiterSocketUUID, flag).Err()
 }
 
-// 添加客服
-func (c *Matcher) AddWaiter(waiterSocketUUID string) {
-	c.RLock()
-	defer c.RUnlock()
+// 获取当前最空闲的客服, 只考虑不指定话题的用户
+func (c *Matcher) GetIdleWaiter() (*string, error) {
+	waiter, err := idleScript.Run(context.Background(), c.client(), c.keys(), c.prefix, c.Max).Text()
 
-	for id := range c.matcher {
-		if id == waiterSocketUUID {
-			return
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	c.matcher[waiterSocketUUID] = []string{}
+	return optional(waiter), nil
 }
 
-// 移除客服
-func (c *Matcher) RemoveWaiter(waiterSocketUUID string) {
-	c.RLock()
-	defer c.RUnlock()
-
-	for id, users := range c.matcher {
-		if id == waiterSocketUUID {
-			delete(c.matcher, id)
-
-			// 还出于连接的用户，放入到队列中
-			// 并且优先放在第一排
-			for _, user := range users {
-				c.Join(user, true)
-			}
-		}
+// 获取当前接待我的客服
+func (c *Matcher) GetMyWaiter(userSocketUUID string) (*string, error) {
+	waiter, err := c.client().Get(context.Background(), c.userKey(userSocketUUID)).Result()
+
+	if err == nativeRedis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
 	}
+
+	return &waiter, nil
 }
 
-// 获取当前最空闲的客服
-func (c *Matcher) GetIdleWaiter() *string {
-	c.RLock()
-	defer c.RUnlock()
+// 把客服正在接待的用户转接给另一个客服, 目标客服需要已就绪并且还没有接满
+func (c *Matcher) TransferTo(userSocketUUID string, fromWaiterSocketUUID string, toWaiterSocketUUID string, transfer Transfer) error {
+	return c.transfer(userSocketUUID, fromWaiterSocketUUID, toWaiterSocketUUID, "", transfer, false)
+}
 
-	var (
-		bestWaiterId      *string
-		currentUserNumber = c.Max
-	)
+// 把客服正在接待的用户放回队列的最前面, 按照指定的话题重新分配, 不会再分配给原来的客服
+func (c *Matcher) TransferToQueue(userSocketUUID string, fromWaiterSocketUUID string, topic string, transfer Transfer) error {
+	return c.transfer(userSocketUUID, fromWaiterSocketUUID, "", topic, transfer, false)
+}
 
-	for waiter, users := range c.matcher {
-		client := c.getWaiterClient(waiter)
+// 主管接管客服正在接待的用户, 不受就绪状态和接待人数的限制
+func (c *Matcher) Takeover(userSocketUUID string, fromWaiterSocketUUID string, supervisorSocketUUID string, transfer Transfer) error {
+	return c.transfer(userSocketUUID, fromWaiterSocketUUID, supervisorSocketUUID, "", transfer, true)
+}
 
-		if client == nil {
-			continue
-		}
+func (c *Matcher) transfer(userSocketUUID string, from string, to string, topic string, transfer Transfer, force bool) error {
+	info, err := json.Marshal(transfer)
 
-		if !client.Ready {
-			continue
-		}
+	if err != nil {
+		return err
+	}
 
-		if len(users) == 0 {
-			bestWaiterId = &waiter
-			break
-		} else if len(users) < currentUserNumber {
-			bestWaiterId = &waiter
-		}
+	flag := "0"
+
+	if force {
+		flag = "1"
 	}
 
-	return bestWaiterId
-}
+	status, err := transferScript.Run(context.Background(), c.client(), c.keys(), c.prefix, c.Max, userSocketUUID, from, to, topic, info, flag).Int()
 
-// 获取当前接待我的客服
-func (c *Matcher) GetMyWaiter(userSocketUUID string) *string {
-	c.RLock()
-	defer c.RUnlock()
-
-	for id, users := range c.matcher {
-		for _, u := range users {
-			if u == userSocketUUID {
-				return &id
-			}
-		}
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case 0:
+		return exception.CustomerNotServing
+	case -1:
+		return exception.CustomerWaiterBusy
 	}
 
 	return nil
 }
+
+// 取出用户的转接信息, 不是转接过来的用户返回空
+func (c *Matcher) TakeTransfer(userSocketUUID string) (*Transfer, error) {
+	ctx := context.Background()
+
+	var get *nativeRedis.StringCmd
+
+	if _, err := c.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		get = pipe.HGet(ctx, c.transferKey(), userSocketUUID)
+		pipe.HDel(ctx, c.transferKey(), userSocketUUID)
+		return nil
+	}); err != nil && err != nativeRedis.Nil {
+		return nil, err
+	}
+
+	raw, err := get.Bytes()
+
+	if err == nativeRedis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var transfer Transfer
+
+	if err := json.Unmarshal(raw, &transfer); err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
@@ -4,73 +4,104 @@ package ws_test
 import (
 	"fmt"
 	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
+// 每个测试使用独立的命名空间
+func newMatcher() *ws.Matcher {
+	return ws.NewMatcher(func() nativeRedis.Cmdable {
+		return redis.ClientCustomer
+	}, "test:"+util.RandomString(8))
+}
+
 func TestMatcher_Join(t *testing.T) {
 	// 没有客服的时候
 	// 应该在排队
-	matcher := ws.NewMatcher()
-
-	matcher.SetWaiterClientFunc(func(id string) *ws.Client {
-		return &ws.Client{
-			UUID:  "waiter",
-			Ready: true,
-		}
-	})
+	matcher := newMatcher()
 
 	{
-		waiter, location := matcher.Join("test")
+		waiter, location, err := matcher.Join("test")
 
+		assert.Nil(t, err)
 		assert.Nil(t, waiter)
 		assert.Equal(t, uint(0), location)
 
-		assert.Equal(t, 1, matcher.GetPendingLength())
+		length, err := matcher.GetPendingLength()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, length)
 
-		assert.Equal(t, "test", *matcher.ShiftPending())
-		assert.Equal(t, 0, matcher.GetPendingLength())
+		user, err := matcher.ShiftPending()
+		assert.Nil(t, err)
+		assert.Equal(t, "test", *user)
+
+		length, err = matcher.GetPendingLength()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, length)
 	}
 
 	// 测试离开
 	{
-		_, _ = matcher.Join("test")
+		_, _, _ = matcher.Join("test")
 
-		assert.Equal(t, 1, matcher.GetPendingLength())
+		length, _ := matcher.GetPendingLength()
+		assert.Equal(t, 1, length)
 
-		matcher.Leave("test")
+		assert.Nil(t, matcher.Leave("test"))
 
-		assert.Equal(t, 0, matcher.GetPendingLength())
+		length, _ = matcher.GetPendingLength()
+		assert.Equal(t, 0, length)
 	}
 
 	// 如果客服已存在
 	{
-		matcher.AddWaiter("waiter")
-		matcher.Join("user1")
+		assert.Nil(t, matcher.AddWaiter("waiter"))
+		assert.Nil(t, matcher.SetReady("waiter", true))
+
+		waiter, _, err := matcher.Join("user1")
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *waiter)
 
+		m, err := matcher.GetMatcher()
+		assert.Nil(t, err)
 		assert.Equal(t, map[string][]string{
 			"waiter": {"user1"},
-		}, matcher.GetMatcher())
+		}, m)
 
-		matcher.Join("user2")
+		_, _, _ = matcher.Join("user2")
+
+		m, _ = matcher.GetMatcher()
 		assert.Equal(t, map[string][]string{
 			"waiter": {"user1", "user2"},
-		}, matcher.GetMatcher())
+		}, m)
+
+		// 重复加入, 返回正在接待的客服
+		waiter, _, err = matcher.Join("user2")
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *waiter)
 
-		matcher.Leave("user1")
-		matcher.Leave("user2")
+		myWaiter, err := matcher.GetMyWaiter("user2")
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *myWaiter)
+
+		assert.Nil(t, matcher.Leave("user1"))
+		assert.Nil(t, matcher.Leave("user2"))
+
+		myWaiter, err = matcher.GetMyWaiter("user2")
+		assert.Nil(t, err)
+		assert.Nil(t, myWaiter)
 	}
 
 	// 测试
 	{
-		matcher = ws.NewMatcher()
-		matcher.SetWaiterClientFunc(func(id string) *ws.Client {
-			return &ws.Client{
-				UUID:  "waiter",
-				Ready: true,
-			}
-		})
-		matcher.AddWaiter("waiter")
+		matcher = newMatcher()
+
+		assert.Nil(t, matcher.AddWaiter("waiter"))
+		assert.Nil(t, matcher.SetReady("waiter", true))
 
 		index := 0
 
@@ -79,69 +110,442 @@ func TestMatcher_Join(t *testing.T) {
 				break
 			}
 
-			_, _ = matcher.Join(fmt.Sprintf("%d", index))
+			_, _, _ = matcher.Join(fmt.Sprintf("%d", index))
 
 			index = index + 1
 		}
 
+		m, _ := matcher.GetMatcher()
 		assert.Equal(t, map[string][]string{
 			"waiter": {"0", "1", "2", "3", "4"},
-		}, matcher.GetMatcher())
+		}, m)
+
+		queue, _ := matcher.GetPendingQueue()
+		assert.Equal(t, []string{"5", "6"}, queue)
+
+		// 已经在队列中, 返回所在的位置
+		_, location, _ := matcher.Join("6")
+		assert.Equal(t, uint(1), location)
+
+		idle, err := matcher.GetIdleWaiter()
+		assert.Nil(t, err)
+		assert.Nil(t, idle)
 
-		assert.Equal(t, []string{"5", "6"}, matcher.GetPendingQueue())
+		// 空出一个位置之后, 排在第一位的用户被接待
+		assert.Nil(t, matcher.Leave("0"))
 
+		user, waiter, err := matcher.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "5", *user)
+		assert.Equal(t, "waiter", *waiter)
+
+		user, waiter, err = matcher.Next()
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+		assert.Nil(t, waiter)
+
+		queue, _ = matcher.GetPendingQueue()
+		assert.Equal(t, []string{"6"}, queue)
 	}
 }
 
+// 未就绪的客服不会被分配用户
+func TestMatcher_SetReady(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Nil(t, matcher.AddWaiter("waiter"))
+
+	waiter, _, err := matcher.Join("user1")
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+
+	assert.Nil(t, matcher.SetReady("waiter", true))
+
+	user, waiter, err := matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", *user)
+	assert.Equal(t, "waiter", *waiter)
+
+	assert.Nil(t, matcher.SetReady("waiter", false))
+
+	waiter, _, err = matcher.Join("user2")
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+
+	// 正在接待的用户不受影响
+	users, err := matcher.GetMyUsers("waiter")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user1"}, users)
+
+	// 重新就绪之后, 按照正在接待的用户数量分配
+	matcher.Max = 2
+
+	assert.Nil(t, matcher.SetReady("waiter", true))
+
+	user, _, err = matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "user2", *user)
+
+	waiter, _, err = matcher.Join("user3")
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+}
+
 func TestMatcher_AddWaiter(t *testing.T) {
-	matcher := ws.NewMatcher()
+	matcher := newMatcher()
 
 	// 添加客服
 	{
-		matcher.AddWaiter("test")
+		assert.Nil(t, matcher.AddWaiter("test"))
 
+		m, _ := matcher.GetMatcher()
 		assert.Equal(t, map[string][]string{
 			"test": {},
-		}, matcher.GetMatcher())
+		}, m)
 	}
 
 	// 再添加相同的客服
 	{
-		matcher.AddWaiter("test")
+		assert.Nil(t, matcher.AddWaiter("test"))
 
+		m, _ := matcher.GetMatcher()
 		assert.Equal(t, map[string][]string{
 			"test": {},
-		}, matcher.GetMatcher())
+		}, m)
 	}
 
 	// 客服离开
 	{
-		matcher.RemoveWaiter("test")
+		assert.Nil(t, matcher.RemoveWaiter("test"))
 
-		assert.Equal(t, map[string][]string{}, ws.MatcherPool.GetMatcher())
+		m, _ := matcher.GetMatcher()
+		assert.Equal(t, map[string][]string{}, m)
 	}
 }
 
 // 客服移除，那么剩下的用户会分配到队列中
 func TestMatcher_RemoveWaiter(t *testing.T) {
-	matcher := ws.NewMatcher()
+	matcher := newMatcher()
+
+	// 添加客服
+	assert.Nil(t, matcher.AddWaiter("test"))
+	assert.Nil(t, matcher.SetReady("test", true))
+
+	_, _, _ = matcher.Join("user1")
+	_, _, _ = matcher.Join("user2")
+
+	queue, _ := matcher.GetPendingQueue()
+	assert.Equal(t, []string{}, queue)
+
+	assert.Nil(t, matcher.RemoveWaiter("test"))
+
+	queue, _ = matcher.GetPendingQueue()
+	assert.Equal(t, []string{"user2", "user1"}, queue)
+
+	idle, err := matcher.GetIdleWaiter()
+	assert.Nil(t, err)
+	assert.Nil(t, idle)
+}
+
+// 指定话题的用户只分配给拥有该技能的客服
+func TestMatcher_Skills(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Nil(t, matcher.AddWaiter("en", ws.Ability{Skills: []string{"en"}}))
+	assert.Nil(t, matcher.AddWaiter("zh", ws.Ability{Skills: []string{"zh", "wallet"}}))
+	assert.Nil(t, matcher.SetReady("en", true))
+	assert.Nil(t, matcher.SetReady("zh", true))
+
+	waiter, _, err := matcher.Join("user1", ws.Demand{Topic: "wallet"})
+	assert.Nil(t, err)
+	assert.Equal(t, "zh", *waiter)
+
+	waiter, _, err = matcher.Join("user2", ws.Demand{Topic: "en"})
+	assert.Nil(t, err)
+	assert.Equal(t, "en", *waiter)
+
+	// 没有客服拥有该技能, 排队等待
+	waiter, location, err := matcher.Join("user3", ws.Demand{Topic: "jp"})
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+	assert.Equal(t, uint(0), location)
+
+	// 排在前面的用户不能被接待, 不影响后面的用户
+	// 两个客服都在接待一个用户, zh 更久没有分配过用户
+	waiter, _, err = matcher.Join("user4")
+	assert.Nil(t, err)
+	assert.Equal(t, "zh", *waiter)
+
+	queue, _ := matcher.GetPendingQueue()
+	assert.Equal(t, []string{"user3"}, queue)
+
+	// 移除客服之后, 重新排队的用户仍然需要相同的技能
+	assert.Nil(t, matcher.RemoveWaiter("zh"))
+
+	queue, _ = matcher.GetPendingQueue()
+	assert.Equal(t, []string{"user4", "user1", "user3"}, queue)
+
+	user, waiter, err := matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "user4", *user)
+	assert.Equal(t, "en", *waiter)
+
+	user, waiter, err = matcher.Next()
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+	assert.Nil(t, waiter)
+
+	assert.Nil(t, matcher.AddWaiter("wallet", ws.Ability{Skills: []string{"wallet"}}))
+	assert.Nil(t, matcher.SetReady("wallet", true))
+
+	user, waiter, err = matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", *user)
+	assert.Equal(t, "wallet", *waiter)
+}
+
+// 每个客服的接待人数不同, 优先分配给接待人数最少的, 相同时分配给最久没有分配过用户的
+func TestMatcher_Capacity(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Nil(t, matcher.AddWaiter("a", ws.Ability{Capacity: 1}))
+	assert.Nil(t, matcher.AddWaiter("b", ws.Ability{Capacity: 2}))
+	assert.Nil(t, matcher.SetReady("a", true))
+	assert.Nil(t, matcher.SetReady("b", true))
 
-	matcher.SetWaiterClientFunc(func(id string) *ws.Client {
-		return &ws.Client{
-			UUID:  "test",
-			Ready: true,
+	var assigned []string
+
+	for _, user := range []string{"user1", "user2", "user3", "user4"} {
+		waiter, _, err := matcher.Join(user)
+		assert.Nil(t, err)
+
+		if waiter != nil {
+			assigned = append(assigned, *waiter)
 		}
-	})
+	}
 
-	// 添加客服
-	matcher.AddWaiter("test")
+	assert.Equal(t, []string{"a", "b", "b"}, assigned)
+
+	queue, _ := matcher.GetPendingQueue()
+	assert.Equal(t, []string{"user4"}, queue)
+
+	idle, err := matcher.GetIdleWaiter()
+	assert.Nil(t, err)
+	assert.Nil(t, idle)
+
+	// a 和 b 都空出一个位置, b 最近分配过用户
+	assert.Nil(t, matcher.Leave("user1"))
+	assert.Nil(t, matcher.Leave("user3"))
+
+	idle, err = matcher.GetIdleWaiter()
+	assert.Nil(t, err)
+	assert.Equal(t, "a", *idle)
+
+	user, waiter, err := matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "user4", *user)
+	assert.Equal(t, "a", *waiter)
+}
+
+// 优先级高的用户排在前面
+func TestMatcher_Priority(t *testing.T) {
+	matcher := newMatcher()
+
+	_, _, _ = matcher.Join("user1", ws.Demand{Priority: 1})
+	_, _, _ = matcher.Join("user2", ws.Demand{Priority: 1})
+
+	_, location, err := matcher.Join("vip", ws.Demand{Priority: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), location)
+
+	_, location, err = matcher.Join("user3", ws.Demand{Priority: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), location)
 
-	matcher.Join("user1")
-	matcher.Join("user2")
+	queue, _ := matcher.GetPendingQueue()
+	assert.Equal(t, []string{"vip", "user1", "user2", "user3"}, queue)
 
-	assert.Equal(t, []string{}, matcher.GetPendingQueue())
+	assert.Nil(t, matcher.AddWaiter("waiter"))
+	assert.Nil(t, matcher.SetReady("waiter", true))
 
-	matcher.RemoveWaiter("test")
+	// 排在前面的用户还没有被接待, 新加入的用户继续排队
+	waiter, location, err := matcher.Join("user4", ws.Demand{Priority: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+	assert.Equal(t, uint(4), location)
 
-	assert.Equal(t, []string{"user2", "user1"}, matcher.GetPendingQueue())
+	user, _, err := matcher.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "vip", *user)
+}
+
+// 客服把用户转接给其他客服, 或者放回队列
+func TestMatcher_Transfer(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Nil(t, matcher.AddWaiter("a", ws.Ability{Skills: []string{"zh"}}))
+	assert.Nil(t, matcher.AddWaiter("b", ws.Ability{Skills: []string{"en"}}))
+	assert.Nil(t, matcher.SetReady("a", true))
+	assert.Nil(t, matcher.SetReady("b", true))
+
+	waiter, _, err := matcher.Join("user1")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", *waiter)
+
+	// 只能转接自己正在接待的用户
+	assert.Equal(t, exception.CustomerNotServing, matcher.TransferTo("user1", "b", "a", ws.Transfer{}))
+	assert.Equal(t, exception.CustomerNotServing, matcher.TransferTo("user2", "a", "b", ws.Transfer{}))
+
+	// 目标客服需要已就绪
+	assert.Equal(t, exception.CustomerWaiterBusy, matcher.TransferTo("user1", "a", "c", ws.Transfer{}))
+
+	// 转接给指定的客服
+	{
+		assert.Nil(t, matcher.TransferTo("user1", "a", "b", ws.Transfer{SessionID: "session1", By: "waiter-a", Reason: "english"}))
+
+		waiter, err = matcher.GetMyWaiter("user1")
+		assert.Nil(t, err)
+		assert.Equal(t, "b", *waiter)
+
+		m, _ := matcher.GetMatcher()
+		assert.Equal(t, map[string][]string{
+			"a": {},
+			"b": {"user1"},
+		}, m)
+
+		transfer, err := matcher.TakeTransfer("user1")
+		assert.Nil(t, err)
+		assert.Equal(t, ws.Transfer{SessionID: "session1", By: "waiter-a", Reason: "english"}, *transfer)
+
+		// 只能取出一次
+		transfer, err = matcher.TakeTransfer("user1")
+		assert.Nil(t, err)
+		assert.Nil(t, transfer)
+	}
+
+	// 放回队列, 不会再分配给原来的客服
+	{
+		assert.Nil(t, matcher.TransferToQueue("user1", "b", "", ws.Transfer{SessionID: "session2"}))
+
+		waiter, err = matcher.GetMyWaiter("user1")
+		assert.Nil(t, err)
+		assert.Nil(t, waiter)
+
+		queue, _ := matcher.GetPendingQueue()
+		assert.Equal(t, []string{"user1"}, queue)
+
+		user, waiter, err := matcher.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "user1", *user)
+		assert.Equal(t, "a", *waiter)
+
+		transfer, err := matcher.TakeTransfer("user1")
+		assert.Nil(t, err)
+		assert.Equal(t, "session2", transfer.SessionID)
+	}
+
+	// 转到技能队列, 排在其他用户的前面
+	{
+		matcher.Max = 1
+
+		waiter, _, _ := matcher.Join("user2")
+		assert.Equal(t, "b", *waiter)
+
+		_, location, _ := matcher.Join("user3")
+		assert.Equal(t, uint(0), location)
+
+		assert.Nil(t, matcher.TransferToQueue("user1", "a", "en", ws.Transfer{}))
+
+		queue, _ := matcher.GetPendingQueue()
+		assert.Equal(t, []string{"user1", "user3"}, queue)
+
+		// 只有 b 拥有该技能, 空出位置之后才会被分配
+		user, waiter, err := matcher.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "user3", *user)
+		assert.Equal(t, "a", *waiter)
+
+		assert.Nil(t, matcher.Leave("user2"))
+
+		user, waiter, err = matcher.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "user1", *user)
+		assert.Equal(t, "b", *waiter)
+	}
+}
+
+// 主管接管会话, 不受就绪状态和接待人数的限制
+func TestMatcher_Takeover(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Nil(t, matcher.AddWaiter("waiter", ws.Ability{Capacity: 1}))
+	assert.Nil(t, matcher.SetReady("waiter", true))
+
+	_, _, _ = matcher.Join("user1")
+
+	assert.Equal(t, exception.CustomerNotServing, matcher.Takeover("user1", "other", "supervisor", ws.Transfer{}))
+
+	assert.Nil(t, matcher.Takeover("user1", "waiter", "supervisor", ws.Transfer{SessionID: "session1", By: "supervisor"}))
+
+	m, _ := matcher.GetMatcher()
+	assert.Equal(t, map[string][]string{
+		"waiter":     {},
+		"supervisor": {"user1"},
+	}, m)
+
+	// 主管没有就绪, 不会被分配排队的用户
+	waiter, _, err := matcher.Join("user2")
+	assert.Nil(t, err)
+	assert.Equal(t, "waiter", *waiter)
+
+	waiter, _, err = matcher.Join("user3")
+	assert.Nil(t, err)
+	assert.Nil(t, waiter)
+
+	// 主管断开之后, 接管的用户重新排队
+	assert.Nil(t, matcher.RemoveWaiter("supervisor"))
+
+	queue, _ := matcher.GetPendingQueue()
+	assert.Equal(t, []string{"user1", "user3"}, queue)
+}
+
+func TestMatcher_Withdraw(t *testing.T) {
+	matcher := newMatcher()
+
+	_, _, err := matcher.Join("user1", ws.Demand{Topic: "en"})
+	assert.Nil(t, err)
+
+	// 还在排队, 退出之后不会再被分配
+	{
+		ok, err := matcher.Withdraw("user1")
+		assert.Nil(t, err)
+		assert.True(t, ok)
+
+		length, err := matcher.GetPendingLength()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, length)
+
+		assert.Nil(t, matcher.AddWaiter("waiter", ws.Ability{Skills: []string{"en"}}))
+		assert.Nil(t, matcher.SetReady("waiter", true))
+
+		user, waiter, err := matcher.Next()
+		assert.Nil(t, err)
+		assert.Nil(t, user)
+		assert.Nil(t, waiter)
+	}
+
+	// 已经被接待了, 不受影响
+	{
+		waiter, _, err := matcher.Join("user2", ws.Demand{Topic: "en"})
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *waiter)
+
+		ok, err := matcher.Withdraw("user2")
+		assert.Nil(t, err)
+		assert.False(t, ok)
+
+		waiter, err = matcher.GetMyWaiter("user2")
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *waiter)
+	}
 }
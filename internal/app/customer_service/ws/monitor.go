@@ -0,0 +1,140 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws
+
+import (
+	"context"
+	"github.com/axetroy/go-server/internal/library/exception"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"time"
+)
+
+// 主管监控用户的会话, 以用户的 socket 为单位, 所以会话被转接之后仍然可以看到
+// 用户离开匹配池时, 监控自动结束
+
+func (c *Matcher) watchersKey(userSocketUUID string) string {
+	return c.prefix + "watchers:" + userSocketUUID
+}
+
+func (c *Matcher) watchingKey(supervisorSocketUUID string) string {
+	return c.prefix + "watching:" + supervisorSocketUUID
+}
+
+// 主管开始监控用户的会话, 用户需要正在被客服接待
+func (c *Matcher) Watch(userSocketUUID string, supervisorSocketUUID string) error {
+	waiter, err := c.GetMyWaiter(userSocketUUID)
+
+	if err != nil {
+		return err
+	}
+
+	if waiter == nil {
+		return exception.CustomerNotConnected
+	}
+
+	ctx := context.Background()
+
+	_, err = c.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.SAdd(ctx, c.watchersKey(userSocketUUID), supervisorSocketUUID)
+		pipe.SAdd(ctx, c.watchingKey(supervisorSocketUUID), userSocketUUID)
+		return nil
+	})
+
+	return err
+}
+
+// 主管停止监控用户的会话
+func (c *Matcher) Unwatch(userSocketUUID string, supervisorSocketUUID string) error {
+	ctx := context.Background()
+
+	_, err := c.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.SRem(ctx, c.watchersKey(userSocketUUID), supervisorSocketUUID)
+		pipe.SRem(ctx, c.watchingKey(supervisorSocketUUID), userSocketUUID)
+		return nil
+	})
+
+	return err
+}
+
+// 主管断开连接时, 停止所有的监控
+func (c *Matcher) UnwatchAll(supervisorSocketUUID string) error {
+	users, err := c.GetWatching(supervisorSocketUUID)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	_, err = c.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		for _, user := range users {
+			pipe.SRem(ctx, c.watchersKey(user), supervisorSocketUUID)
+		}
+		pipe.Del(ctx, c.watchingKey(supervisorSocketUUID))
+		return nil
+	})
+
+	return err
+}
+
+// 获取正在监控该用户的会话的主管
+func (c *Matcher) GetWatchers(userSocketUUID string) ([]string, error) {
+	return c.client().SMembers(context.Background(), c.watchersKey(userSocketUUID)).Result()
+}
+
+// 获取主管正在监控的用户
+func (c *Matcher) GetWatching(supervisorSocketUUID string) ([]string, error) {
+	return c.client().SMembers(context.Background(), c.watchingKey(supervisorSocketUUID)).Result()
+}
+
+// 把会话中的消息抄送给正在监控的主管, 主管可能连接在其他节点
+func (n *Node) Mirror(userSocketUUID string, msg Message) {
+	supervisors, err := n.MatcherPool.GetWatchers(userSocketUUID)
+
+	if err != nil {
+		return
+	}
+
+	for _, supervisor := range supervisors {
+		err := n.WaiterPoll.Send(supervisor, Message{
+			Id:   msg.Id,
+			From: msg.From,
+			To:   supervisor,
+			Type: string(TypeResponseWaiterMonitor),
+			Payload: MonitorPayload{
+				UUID:    userSocketUUID,
+				Type:    msg.Type,
+				Payload: msg.Payload,
+			},
+			Date: msg.Date,
+		})
+
+		// 主管已经断开
+		if err == exception.CustomerNotConnected {
+			_ = n.MatcherPool.Unwatch(userSocketUUID, supervisor)
+		}
+	}
+}
+
+// 主管悄悄提醒正在接待该用户的客服, 用户不会收到
+func (n *Node) Whisper(userSocketUUID string, supervisorSocketUUID string, text string) error {
+	waiter, err := n.MatcherPool.GetMyWaiter(userSocketUUID)
+
+	if err != nil {
+		return err
+	}
+
+	if waiter == nil {
+		return exception.CustomerNotConnected
+	}
+
+	return n.WaiterPoll.Send(*waiter, Message{
+		From: supervisorSocketUUID,
+		To:   *waiter,
+		Type: string(TypeResponseWaiterWhisper),
+		Payload: WhisperPayload{
+			UUID: userSocketUUID,
+			Text: text,
+		},
+		Date: time.Now().Format(time.RFC3339Nano),
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 监控跟随用户, 用户离开之后自动结束
+func TestMatcher_Watch(t *testing.T) {
+	matcher := newMatcher()
+
+	assert.Equal(t, exception.CustomerNotConnected, matcher.Watch("user1", "supervisor"))
+
+	assert.Nil(t, matcher.AddWaiter("a"))
+	assert.Nil(t, matcher.AddWaiter("b"))
+	assert.Nil(t, matcher.SetReady("a", true))
+	assert.Nil(t, matcher.SetReady("b", true))
+
+	_, _, _ = matcher.Join("user1")
+	_, _, _ = matcher.Join("user2")
+
+	assert.Nil(t, matcher.Watch("user1", "supervisor"))
+	assert.Nil(t, matcher.Watch("user2", "supervisor"))
+
+	watchers, err := matcher.GetWatchers("user1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"supervisor"}, watchers)
+
+	watching, err := matcher.GetWatching("supervisor")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"user1", "user2"}, watching)
+
+	// 转接之后仍然在监控
+	assert.Nil(t, matcher.TransferTo("user1", "a", "b", ws.Transfer{}))
+
+	watchers, _ = matcher.GetWatchers("user1")
+	assert.Equal(t, []string{"supervisor"}, watchers)
+
+	assert.Nil(t, matcher.Leave("user1"))
+
+	watchers, _ = matcher.GetWatchers("user1")
+	assert.Empty(t, watchers)
+
+	watching, _ = matcher.GetWatching("supervisor")
+	assert.Equal(t, []string{"user2"}, watching)
+
+	assert.Nil(t, matcher.UnwatchAll("supervisor"))
+
+	watchers, _ = matcher.GetWatchers("user2")
+	assert.Empty(t, watchers)
+}
+
+// 主管和客服连接在其他节点
+func TestNode_Mirror(t *testing.T) {
+	a, b := newNodes(t)
+
+	defer a.Close()
+	defer b.Close()
+
+	server := serve(b.WaiterPoll)
+	defer server.Close()
+
+	waiterConn, waiterUUID := dial(t, server)
+	defer waiterConn.Close()
+
+	supervisorConn, supervisorUUID := dial(t, server)
+	defer supervisorConn.Close()
+
+	assert.Nil(t, b.WaiterPoll.UpdateProfile(b.WaiterPoll.Get(supervisorUUID), schema.ProfilePublic{Id: "supervisor"}))
+
+	assert.Nil(t, a.MatcherPool.AddWaiter(waiterUUID))
+	assert.Nil(t, a.MatcherPool.SetReady(waiterUUID, true))
+
+	_, _, err := a.MatcherPool.Join("user")
+	assert.Nil(t, err)
+
+	assert.Nil(t, a.MatcherPool.Watch("user", supervisorUUID))
+
+	// 会话中的消息抄送给主管
+	{
+		a.Mirror("user", ws.Message{
+			From:    "user",
+			Type:    string(ws.TypeResponseUserMessageText),
+			Payload: ws.MessageTextPayload{Text: "hello"},
+		})
+
+		msg := read(t, supervisorConn)
+		assert.Equal(t, string(ws.TypeResponseWaiterMonitor), msg.Type)
+		assert.Equal(t, "user", msg.From)
+		assert.Equal(t, map[string]interface{}{
+			"uuid":    "user",
+			"type":    string(ws.TypeResponseUserMessageText),
+			"payload": map[string]interface{}{"text": "hello"},
+		}, msg.Payload)
+	}
+
+	// 提醒只发给正在接待的客服
+	{
+		assert.Nil(t, a.Whisper("user", supervisorUUID, "be polite"))
+
+		msg := read(t, waiterConn)
+		assert.Equal(t, string(ws.TypeResponseWaiterWhisper), msg.Type)
+		assert.Equal(t, supervisorUUID, msg.From)
+		assert.Equal(t, map[string]interface{}{"uuid": "user", "text": "be polite"}, msg.Payload)
+
+		assert.Equal(t, exception.CustomerNotConnected, a.Whisper("nobody", supervisorUUID, "hi"))
+	}
+
+	// 主管断开之后, 不再抄送
+	{
+		assert.Nil(t, supervisorConn.Close())
+
+		for i := 0; i < 30; i++ {
+			if n, _ := a.WaiterPoll.Count(); n == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond * 100)
+		}
+
+		a.Mirror("user", ws.Message{From: "user", Type: string(ws.TypeResponseUserMessageText)})
+
+		watchers, err := a.MatcherPool.GetWatchers("user")
+		assert.Nil(t, err)
+		assert.Empty(t, watchers)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+)
+
+// 连接的角色
+type role string
+
+const (
+	roleUser   role = "user"
+	roleWaiter role = "waiter"
+)
+
+// 通过 redis 转发给其他节点的消息
+type envelope struct {
+	Role    role    `json:"role"`
+	To      string  `json:"to"`
+	Close   bool    `json:"close"` // 发送之后是否关闭连接
+	Message Message `json:"message"`
+}
+
+// 客服系统的节点, 即一个客服服务的实例
+// 同一个命名空间的节点共享 redis 中的匹配状态, 用户和客服可以连接在不同的节点
+// 每个节点订阅自己的频道, 发给其他节点的连接的消息通过该频道转发
+type Node struct {
+	sync.Mutex
+	ID          string // 节点 ID, 每次启动都不一样
+	UserPoll    *Pool  // 连接到本节点的用户
+	WaiterPoll  *Pool  // 连接到本节点的客服
+	MatcherPool *Matcher
+	client      func() *nativeRedis.Client // redis 客户端在服务启动时才连接, 所以在使用时再获取
+	prefix      string
+	pubsub      *nativeRedis.PubSub
+}
+
+// 当前进程的节点
+var DefaultNode = NewNode(func() *nativeRedis.Client {
+	return redis.ClientCustomer
+}, "customer_service")
+
+func NewNode(client func() *nativeRedis.Client, namespace string) *Node {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		log.Printf("%+v\n", err)
+	}
+
+	n := &Node{
+		ID:     id.String(),
+		client: client,
+		prefix: namespace + ":",
+	}
+
+	n.UserPoll = newPool(n, roleUser)
+	n.WaiterPoll = newPool(n, roleWaiter)
+	n.MatcherPool = NewMatcher(func() nativeRedis.Cmdable {
+		return client()
+	}, namespace)
+
+	return n
+}
+
+func (n *Node) channel(nodeID string) string {
+	return n.prefix + "node:" + nodeID
+}
+
+func (n *Node) pool(r role) *Pool {
+	if r == roleWaiter {
+		return n.WaiterPoll
+	}
+
+	return n.UserPoll
+}
+
+// 开始接收其他节点转发过来的消息
+func (n *Node) Start() error {
+	n.Lock()
+	defer n.Unlock()
+
+	if n.pubsub != nil {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	pubsub := n.client().Subscribe(ctx, n.channel(n.ID))
+
+	// 等待订阅成功, 否则在此之前转发过来的消息会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	n.pubsub = pubsub
+
+	go n.receive(pubsub.Channel())
+
+	return nil
+}
+
+func (n *Node) receive(ch <-chan *nativeRedis.Message) {
+	for m := range ch {
+		var e envelope
+
+		if err := json.Unmarshal([]byte(m.Payload), &e); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		client := n.pool(e.Role).Get(e.To)
+
+		// 连接已经断开
+		if client == nil {
+			continue
+		}
+
+		if err := client.deliver(e.Message); err != nil {
+			log.Println(err)
+		}
+
+		if e.Close {
+			_ = client.Close()
+		}
+	}
+}
+
+// 停止接收消息, 并且关闭本节点的所有连接
+func (n *Node) Close() error {
+	n.Lock()
+	defer n.Unlock()
+
+	n.UserPoll.closeAll()
+	n.WaiterPoll.closeAll()
+
+	if n.pubsub == nil {
+		return nil
+	}
+
+	err := n.pubsub.Close()
+
+	n.pubsub = nil
+
+	return err
+}
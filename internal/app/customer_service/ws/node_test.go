@@ -0,0 +1,273 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 同一个 redis 上的两个节点
+func newNodes(t *testing.T) (*ws.Node, *ws.Node) {
+	var (
+		namespace = "test:" + util.RandomString(8)
+		client    = func() *nativeRedis.Client {
+			return redis.ClientCustomer
+		}
+		a = ws.NewNode(client, namespace)
+		b = ws.NewNode(client, namespace)
+	)
+
+	assert.Nil(t, a.Start())
+	assert.Nil(t, b.Start())
+
+	return a, b
+}
+
+// 连接到节点的 socket 服务, 连接之后告诉客户端它的 UUID
+func serve(pool *ws.Pool) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			return
+		}
+
+		client := ws.NewClient(conn)
+
+		defer func() {
+			_ = pool.Remove(client)
+		}()
+
+		if err := pool.Add(client); err != nil {
+			return
+		}
+
+		if err := client.WriteJSON(ws.Message{Type: "uuid", To: client.UUID}); err != nil {
+			return
+		}
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func dial(t *testing.T, s *httptest.Server) (*websocket.Conn, string) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+
+	assert.Nil(t, err)
+
+	var msg ws.Message
+
+	assert.Nil(t, conn.ReadJSON(&msg))
+
+	return conn, msg.To
+}
+
+func read(t *testing.T, conn *websocket.Conn) ws.Message {
+	var msg ws.Message
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+
+	assert.Nil(t, conn.ReadJSON(&msg))
+
+	return msg
+}
+
+// 用户和客服连接在不同的节点
+func TestNode(t *testing.T) {
+	a, b := newNodes(t)
+
+	defer a.Close()
+	defer b.Close()
+
+	userServer := serve(a.UserPoll)
+	defer userServer.Close()
+
+	waiterServer := serve(b.WaiterPoll)
+	defer waiterServer.Close()
+
+	userConn, userUUID := dial(t, userServer)
+	defer userConn.Close()
+
+	waiterConn, waiterUUID := dial(t, waiterServer)
+	defer waiterConn.Close()
+
+	// 连接和身份信息在所有节点可见
+	{
+		assert.Nil(t, b.WaiterPoll.UpdateProfile(b.WaiterPoll.Get(waiterUUID), schema.ProfilePublic{Id: "waiter"}))
+
+		profile, err := a.WaiterPoll.GetProfile(waiterUUID)
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", profile.Id)
+
+		_, err = a.UserPoll.GetProfile(userUUID)
+		assert.Equal(t, exception.UserNotLogin, err)
+
+		_, err = b.UserPoll.GetProfile("not exist")
+		assert.Equal(t, exception.CustomerNotConnected, err)
+
+		for _, node := range []*ws.Node{a, b} {
+			n, err := node.UserPoll.Count()
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+
+			n, err = node.WaiterPoll.Count()
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+		}
+
+		assert.Equal(t, 1, a.UserPoll.Length())
+		assert.Equal(t, 0, a.WaiterPoll.Length())
+	}
+
+	// 匹配的状态在所有节点共享
+	{
+		assert.Nil(t, b.MatcherPool.AddWaiter(waiterUUID))
+		assert.Nil(t, b.MatcherPool.SetReady(waiterUUID, true))
+
+		waiter, _, err := a.MatcherPool.Join(userUUID)
+		assert.Nil(t, err)
+		assert.Equal(t, waiterUUID, *waiter)
+
+		waiter, err = b.MatcherPool.GetMyWaiter(userUUID)
+		assert.Nil(t, err)
+		assert.Equal(t, waiterUUID, *waiter)
+	}
+
+	// 消息转发到对方所在的节点
+	{
+		assert.Nil(t, a.WaiterPoll.Send(waiterUUID, ws.Message{
+			From:    userUUID,
+			To:      waiterUUID,
+			Type:    string(ws.TypeResponseWaiterMessageText),
+			Payload: ws.MessageTextPayload{Text: "hello"},
+		}))
+
+		msg := read(t, waiterConn)
+		assert.Equal(t, string(ws.TypeResponseWaiterMessageText), msg.Type)
+		assert.Equal(t, userUUID, msg.From)
+		assert.Equal(t, map[string]interface{}{"text": "hello"}, msg.Payload)
+
+		assert.Nil(t, b.UserPoll.Send(userUUID, ws.Message{
+			From:    waiterUUID,
+			To:      userUUID,
+			Type:    string(ws.TypeResponseUserMessageText),
+			Payload: ws.MessageTextPayload{Text: "world"},
+		}))
+
+		msg = read(t, userConn)
+		assert.Equal(t, string(ws.TypeResponseUserMessageText), msg.Type)
+		assert.Equal(t, waiterUUID, msg.From)
+		assert.Equal(t, map[string]interface{}{"text": "world"}, msg.Payload)
+
+		assert.Equal(t, exception.CustomerNotConnected, a.UserPoll.Send("not exist", ws.Message{}))
+	}
+
+	// 同一个帐号在另一个节点登陆, 踢掉原来的连接
+	{
+		otherServer := serve(a.WaiterPoll)
+		defer otherServer.Close()
+
+		otherConn, otherUUID := dial(t, otherServer)
+		defer otherConn.Close()
+
+		assert.Nil(t, a.WaiterPoll.UpdateProfile(a.WaiterPoll.Get(otherUUID), schema.ProfilePublic{Id: "waiter"}))
+
+		list, err := a.WaiterPoll.GetClientsFromUserID("waiter")
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{waiterUUID, otherUUID}, list)
+
+		assert.Nil(t, a.WaiterPoll.Kick(waiterUUID, ws.Message{
+			From: otherUUID,
+			To:   waiterUUID,
+			Type: string(ws.TypeResponseWaiterKickOut),
+		}))
+
+		msg := read(t, waiterConn)
+		assert.Equal(t, string(ws.TypeResponseWaiterKickOut), msg.Type)
+
+		// 连接被关闭, 所在的节点删除登记
+		_ = waiterConn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		_, _, err = waiterConn.ReadMessage()
+		assert.NotNil(t, err)
+
+		for i := 0; i < 30; i++ {
+			if list, _ = a.WaiterPoll.GetClientsFromUserID("waiter"); len(list) == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond * 100)
+		}
+
+		assert.Equal(t, []string{otherUUID}, list)
+
+		_, err = a.WaiterPoll.GetProfile(waiterUUID)
+		assert.Equal(t, exception.CustomerNotConnected, err)
+	}
+}
+
+// 节点下线之后, 残留的连接在发送消息时被清理
+func TestNode_Offline(t *testing.T) {
+	var (
+		namespace = "test:" + util.RandomString(8)
+		client    = func() *nativeRedis.Client {
+			return redis.ClientCustomer
+		}
+		online  = ws.NewNode(client, namespace)
+		offline = ws.NewNode(client, namespace) // 不接收消息, 也不清理 redis 中的登记, 相当于节点崩溃
+		userID  = util.RandomString(8)
+	)
+
+	assert.Nil(t, online.Start())
+
+	defer online.Close()
+
+	assert.Nil(t, offline.UserPoll.Add(&ws.Client{UUID: userID}))
+
+	n, err := online.UserPoll.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, exception.CustomerNotConnected, online.UserPoll.Send(userID, ws.Message{}))
+
+	n, err = online.UserPoll.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
+
+// 关闭节点时, 关闭本节点的所有连接
+func TestNode_Close(t *testing.T) {
+	a, b := newNodes(t)
+
+	defer a.Close()
+
+	userServer := serve(b.UserPoll)
+	defer userServer.Close()
+
+	userConn, userUUID := dial(t, userServer)
+	defer userConn.Close()
+
+	assert.Nil(t, a.UserPoll.Send(userUUID, ws.Message{Type: string(ws.TypeResponseUserIdle)}))
+	assert.Equal(t, string(ws.TypeResponseUserIdle), read(t, userConn).Type)
+
+	assert.Nil(t, b.Close())
+
+	_ = userConn.SetReadDeadline(time.Now().Add(time.Second * 3))
+	_, _, err := userConn.ReadMessage()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,265 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// 用户的网络断开之后, 会话保留一段时间, 用户可以在新的连接中凭令牌恢复会话
+// 新的连接沿用原来的 UUID, 所以会话 ID 和匹配的状态都不变, 客服端感知不到
+// {prefix}resume:{token}          string  令牌对应的连接, 在连接客服成功时签发, 只能使用一次
+// {prefix}suspend:{role}:{uuid}   string  连接断开的时间(毫秒), 存在时说明会话正在等待恢复
+
+// 令牌的有效期, 会话结束之后令牌也就失效了, 这里只是防止残留
+var resumeTokenTTL = time.Hour * 24
+
+type resumeToken struct {
+	UUID   string `json:"uuid"`    // 原来的连接 UUID
+	UserID string `json:"user_id"` // 用户 ID, 只有同一个用户才可以恢复
+}
+
+// 挂起连接, 登记在其他连接时不挂起
+var suspendScript = nativeRedis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+
+redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("SET", KEYS[2], ARGV[3], "PX", ARGV[4])
+
+return 1
+`)
+
+// 使用令牌接管原来的连接
+// 返回 {断开的时间, 原来的位置}, 原来的连接还在线时, 断开的时间为空字符串
+// 令牌已经使用过, 或者原来的连接既不在线也没有挂起时返回空数组
+var resumeScript = nativeRedis.NewScript(`
+if redis.call("DEL", KEYS[3]) == 0 then
+	return {}
+end
+
+local at = redis.call("GET", KEYS[2])
+local previous = redis.call("HGET", KEYS[1], ARGV[1])
+
+if not at and not previous then
+	return {}
+end
+
+redis.call("DEL", KEYS[2])
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+
+return {at or "", previous or ""}
+`)
+
+// 签发恢复会话的令牌
+func (c *Pool) IssueResumeToken(UUID string, userID string) (string, error) {
+	if c.node == nil {
+		return "", nil
+	}
+
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	raw, err := json.Marshal(resumeToken{
+		UUID:   UUID,
+		UserID: userID,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.node.client().Set(context.Background(), c.resumeKey(token), raw, resumeTokenTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// 挂起断开的连接, 保留身份信息和匹配的状态, 等待用户恢复
+// 返回 false 说明会话已经在其他连接恢复了
+func (c *Pool) Suspend(client *Client, grace time.Duration) (bool, error) {
+	c.Lock()
+	delete(c.clients, client)
+	c.Unlock()
+
+	if c.node == nil {
+		return false, nil
+	}
+
+	// 多保留一段时间, 让断开的节点到期之后还可以结束会话
+	n, err := suspendScript.Run(context.Background(), c.node.client(), []string{c.onlineKey(), c.suspendKey(client.UUID)}, client.UUID, c.location(client), time.Now().UnixNano()/int64(time.Millisecond), int64(grace*2/time.Millisecond)).Int()
+
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// 挂起的连接到期, 删除身份信息
+// 返回 false 说明会话已经被恢复, 不需要再结束会话
+func (c *Pool) Expire(UUID string) (bool, error) {
+	if c.node == nil {
+		return false, nil
+	}
+
+	n, err := c.node.client().Del(context.Background(), c.suspendKey(UUID)).Result()
+
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	return true, c.forget(UUID, "", "")
+}
+
+// 在新的连接中恢复会话, 新的连接会沿用原来的 UUID
+// 原来的连接还在线时(例如网络断开但是服务端还没有察觉), 原来的连接会收到 kickout 并被断开
+// 返回原来的连接断开的时间, 原来的连接还在线时返回零值
+func (c *Pool) Resume(client *Client, token string) (time.Time, error) {
+	var suspendedAt time.Time
+
+	profile := client.GetProfile()
+
+	if profile == nil {
+		return suspendedAt, exception.UserNotLogin
+	}
+
+	if c.node == nil {
+		return suspendedAt, exception.CustomerSessionExpired
+	}
+
+	ctx := context.Background()
+
+	raw, err := c.node.client().Get(ctx, c.resumeKey(token)).Bytes()
+
+	if err == nativeRedis.Nil {
+		return suspendedAt, exception.CustomerSessionExpired
+	}
+
+	if err != nil {
+		return suspendedAt, err
+	}
+
+	var t resumeToken
+
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return suspendedAt, err
+	}
+
+	if t.UserID != profile.Id {
+		return suspendedAt, exception.CustomerSessionExpired
+	}
+
+	// 会话已经结束
+	if waiter, err := c.node.MatcherPool.GetMyWaiter(t.UUID); err != nil {
+		return suspendedAt, err
+	} else if waiter == nil {
+		return suspendedAt, exception.CustomerSessionExpired
+	}
+
+	reply, err := resumeScript.Run(ctx, c.node.client(), []string{c.onlineKey(), c.suspendKey(t.UUID), c.resumeKey(token)}, t.UUID, c.location(client)).Result()
+
+	if err != nil {
+		return suspendedAt, err
+	}
+
+	list, ok := reply.([]interface{})
+
+	if !ok {
+		return suspendedAt, errInvalidReply
+	}
+
+	if len(list) != 2 {
+		return suspendedAt, exception.CustomerSessionExpired
+	}
+
+	at, _ := list[0].(string)
+	previous, _ := list[1].(string)
+
+	if at != "" {
+		ms, err := strconv.ParseInt(at, 10, 64)
+
+		if err != nil {
+			return suspendedAt, err
+		}
+
+		suspendedAt = time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	if previous != "" {
+		c.replace(t.UUID, previous, client)
+	}
+
+	// 把当前连接的登记转移到原来的 UUID 上
+	old := client.UUID
+
+	client.setUUID(t.UUID)
+
+	profileRaw, err := json.Marshal(profile)
+
+	if err != nil {
+		return suspendedAt, err
+	}
+
+	_, err = c.node.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.HDel(ctx, c.onlineKey(), old)
+		pipe.HDel(ctx, c.profileKey(), old)
+		pipe.SRem(ctx, c.accountKey(profile.Id), old)
+		pipe.HSet(ctx, c.profileKey(), t.UUID, profileRaw)
+		pipe.SAdd(ctx, c.accountKey(profile.Id), t.UUID)
+		return nil
+	})
+
+	return suspendedAt, err
+}
+
+// 断开被接管的连接
+func (c *Pool) replace(UUID string, location string, current *Client) {
+	msg := Message{
+		To:   UUID,
+		Type: string(TypeResponseUserKickOut),
+		Date: time.Now().Format(time.RFC3339Nano),
+	}
+
+	if nodeOf(location) != c.node.ID {
+		_, _ = c.publish(nodeOf(location), UUID, msg, true)
+		return
+	}
+
+	var replaced []*Client
+
+	c.Lock()
+	for client := range c.clients {
+		if client.UUID == UUID && client != current {
+			delete(c.clients, client)
+			replaced = append(replaced, client)
+		}
+	}
+	c.Unlock()
+
+	for _, client := range replaced {
+		_ = client.WriteJSON(msg)
+		_ = client.Close()
+	}
+}
+
+func (c *Pool) resumeKey(token string) string {
+	return c.node.prefix + "resume:" + token
+}
+
+func (c *Pool) suspendKey(UUID string) string {
+	return c.node.prefix + "suspend:" + string(c.role) + ":" + UUID
+}
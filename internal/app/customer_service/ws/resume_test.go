@@ -0,0 +1,184 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package ws_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/customer_service/ws"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 用户在一个节点断线, 在另一个节点恢复会话
+func TestPool_Resume(t *testing.T) {
+	a, b := newNodes(t)
+
+	defer a.Close()
+	defer b.Close()
+
+	serverA := serve(a.UserPoll)
+	defer serverA.Close()
+
+	serverB := serve(b.UserPoll)
+	defer serverB.Close()
+
+	conn, userUUID := dial(t, serverA)
+	defer conn.Close()
+
+	assert.Nil(t, a.UserPoll.UpdateProfile(a.UserPoll.Get(userUUID), schema.ProfilePublic{Id: "user"}))
+
+	assert.Nil(t, a.MatcherPool.AddWaiter("waiter"))
+	assert.Nil(t, a.MatcherPool.SetReady("waiter", true))
+
+	_, _, err := a.MatcherPool.Join(userUUID)
+	assert.Nil(t, err)
+
+	token, err := a.UserPoll.IssueResumeToken(userUUID, "user")
+	assert.Nil(t, err)
+
+	// 网络断开, 会话挂起
+	{
+		suspended, err := a.UserPoll.Suspend(a.UserPoll.Get(userUUID), time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, suspended)
+
+		n, err := b.UserPoll.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, n)
+
+		// 发送失败, 但是身份信息保留, 客服发来的消息仍然可以写入会话
+		assert.Equal(t, exception.CustomerNotConnected, b.UserPoll.Send(userUUID, ws.Message{}))
+
+		profile, err := b.UserPoll.GetProfile(userUUID)
+		assert.Nil(t, err)
+		assert.Equal(t, "user", profile.Id)
+	}
+
+	// 其他用户不能使用这个令牌
+	{
+		otherConn, otherUUID := dial(t, serverB)
+		defer otherConn.Close()
+
+		assert.Nil(t, b.UserPoll.UpdateProfile(b.UserPoll.Get(otherUUID), schema.ProfilePublic{Id: "other"}))
+
+		_, err := b.UserPoll.Resume(b.UserPoll.Get(otherUUID), token)
+		assert.Equal(t, exception.CustomerSessionExpired, err)
+	}
+
+	// 在另一个节点恢复会话, 沿用原来的 UUID
+	newConn, newUUID := dial(t, serverB)
+	defer newConn.Close()
+
+	{
+		client := b.UserPoll.Get(newUUID)
+
+		assert.Nil(t, b.UserPoll.UpdateProfile(client, schema.ProfilePublic{Id: "user"}))
+
+		suspendedAt, err := b.UserPoll.Resume(client, token)
+		assert.Nil(t, err)
+		assert.False(t, suspendedAt.IsZero())
+		assert.Equal(t, userUUID, client.UUID)
+
+		waiter, err := a.MatcherPool.GetMyWaiter(userUUID)
+		assert.Nil(t, err)
+		assert.Equal(t, "waiter", *waiter)
+
+		assert.Nil(t, a.UserPoll.Send(userUUID, ws.Message{Type: string(ws.TypeResponseUserMessageText)}))
+		assert.Equal(t, string(ws.TypeResponseUserMessageText), read(t, newConn).Type)
+
+		_, err = b.UserPoll.GetProfile(newUUID)
+		assert.Equal(t, exception.CustomerNotConnected, err)
+
+		// 令牌只能使用一次
+		_, err = b.UserPoll.Resume(client, token)
+		assert.Equal(t, exception.CustomerSessionExpired, err)
+	}
+
+	// 原来的连接还在线时恢复, 原来的连接被断开
+	{
+		token, err := b.UserPoll.IssueResumeToken(userUUID, "user")
+		assert.Nil(t, err)
+
+		thirdConn, thirdUUID := dial(t, serverA)
+		defer thirdConn.Close()
+
+		client := a.UserPoll.Get(thirdUUID)
+
+		assert.Nil(t, a.UserPoll.UpdateProfile(client, schema.ProfilePublic{Id: "user"}))
+
+		suspendedAt, err := a.UserPoll.Resume(client, token)
+		assert.Nil(t, err)
+		assert.True(t, suspendedAt.IsZero())
+
+		assert.Equal(t, string(ws.TypeResponseUserKickOut), read(t, newConn).Type)
+
+		_ = newConn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		_, _, err = newConn.ReadMessage()
+		assert.NotNil(t, err)
+
+		// 被断开的连接不会删除新连接的登记
+		time.Sleep(time.Millisecond * 200)
+
+		n, err := a.UserPoll.Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n) // 另外一个是 other 用户
+
+		assert.Nil(t, b.UserPoll.Send(userUUID, ws.Message{Type: string(ws.TypeResponseUserIdle)}))
+		assert.Equal(t, string(ws.TypeResponseUserIdle), read(t, thirdConn).Type)
+	}
+
+	// 会话结束之后不能恢复
+	{
+		token, err := a.UserPoll.IssueResumeToken(userUUID, "user")
+		assert.Nil(t, err)
+
+		assert.Nil(t, a.MatcherPool.Leave(userUUID))
+
+		otherConn, otherUUID := dial(t, serverB)
+		defer otherConn.Close()
+
+		client := b.UserPoll.Get(otherUUID)
+
+		assert.Nil(t, b.UserPoll.UpdateProfile(client, schema.ProfilePublic{Id: "user"}))
+
+		_, err = b.UserPoll.Resume(client, token)
+		assert.Equal(t, exception.CustomerSessionExpired, err)
+	}
+}
+
+// 挂起的连接到期之后, 删除身份信息
+func TestPool_Expire(t *testing.T) {
+	a, b := newNodes(t)
+
+	defer a.Close()
+	defer b.Close()
+
+	server := serve(a.UserPoll)
+	defer server.Close()
+
+	conn, userUUID := dial(t, server)
+	defer conn.Close()
+
+	assert.Nil(t, a.UserPoll.UpdateProfile(a.UserPoll.Get(userUUID), schema.ProfilePublic{Id: "user"}))
+
+	suspended, err := a.UserPoll.Suspend(a.UserPoll.Get(userUUID), time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, suspended)
+
+	expired, err := b.UserPoll.Expire(userUUID)
+	assert.Nil(t, err)
+	assert.True(t, expired)
+
+	_, err = b.UserPoll.GetProfile(userUUID)
+	assert.Equal(t, exception.CustomerNotConnected, err)
+
+	list, err := b.UserPoll.GetClientsFromUserID("user")
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+
+	// 已经结束过了
+	expired, err = b.UserPoll.Expire(userUUID)
+	assert.Nil(t, err)
+	assert.False(t, expired)
+}
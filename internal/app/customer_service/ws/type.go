@@ -36,6 +36,7 @@ const (
 	TypeRequestUserMessageImage TypeRequestUser = "message_image" // 发送图片
 	TypeRequestUserGetHistory   TypeRequestUser = "get_history"   // 请求获取用户聊天记录，应该返回 `message_history`
 	TypeRequestUserRate         TypeRequestUser = "rate"          // 对于本次会话进行评价
+	TypeRequestUserResume       TypeRequestUser = "resume"        // 断线重连之后, 恢复原来的会话
 )
 
 // 用户收到的类型
@@ -54,6 +55,11 @@ const (
 	TypeResponseUserError               TypeResponseUser = "error"                 // 用户收到一个错误
 	TypeResponseUserRate                TypeResponseUser = "rate"                  // 对于本次会话的评分
 	TypeResponseUserRateSuccess         TypeResponseUser = "rate_success"          // 用户评分之后的回执
+	TypeResponseUserResumeSuccess       TypeResponseUser = "resume_success"        // 恢复会话成功
+	TypeResponseUserKickOut             TypeResponseUser = "kickout"               // 会话在新的连接恢复, 当前连接被断开
+	TypeResponseUserTransfer            TypeResponseUser = "transfer"              // 会话正在转接给其他客服
+	TypeResponseUserOffline             TypeResponseUser = "offline"               // 没有客服接待, 之后的消息转为留言
+	TypeResponseUserTicketReply         TypeResponseUser = "ticket_reply"          // 客服回复了用户的留言
 )
 
 // 客服发出的消息类型
@@ -68,6 +74,13 @@ const (
 	TypeRequestWaiterGetHistory        TypeRequestWaiter = "get_history"         // 请求获取用户聊天记录，应该返回 `message_history`, 需要指定 payload
 	TypeRequestWaiterGetHistorySession TypeRequestWaiter = "get_history_session" // 请求获取客服的会话记录，应该返回 `session_history`
 	TypeRequestWaiterRate              TypeRequestWaiter = "rate"                // 客服发送评分的操作
+	TypeRequestWaiterTransfer          TypeRequestWaiter = "transfer"            // 把正在接待的用户转接给其他客服, 或者转到技能队列
+	TypeRequestWaiterWatch             TypeRequestWaiter = "watch"               // 主管开始监控用户的会话
+	TypeRequestWaiterUnwatch           TypeRequestWaiter = "unwatch"             // 主管停止监控用户的会话
+	TypeRequestWaiterWhisper           TypeRequestWaiter = "whisper"             // 主管悄悄提醒正在接待的客服, 用户看不到
+	TypeRequestWaiterTakeover          TypeRequestWaiter = "takeover"            // 主管接管用户的会话
+	TypeRequestWaiterGetTickets        TypeRequestWaiter = "get_tickets"         // 获取待处理的用户留言，应该返回 `ticket_list`
+	TypeRequestWaiterReplyTicket       TypeRequestWaiter = "reply_ticket"        // 回复用户的留言
 )
 
 // 客服收到的消息
@@ -85,4 +98,14 @@ const (
 	TypeResponseWaiterRateSuccess         TypeResponseWaiter = "rate_success"          // 客服发起评分之后的回执
 	TypeResponseWaiterRateUserSuccess     TypeResponseWaiter = "rate_user_success"     // 用户评分之后的回执
 	TypeResponseWaiterError               TypeResponseWaiter = "error"                 // 有新连接断开
+	TypeResponseWaiterTransferSuccess     TypeResponseWaiter = "transfer_success"      // 转接之后的回执
+	TypeResponseWaiterTransferIn          TypeResponseWaiter = "transfer_in"           // 有用户转接过来, 附带之前的聊天记录
+	TypeResponseWaiterTakenOver           TypeResponseWaiter = "taken_over"            // 正在接待的用户被主管接管
+	TypeResponseWaiterWatchSuccess        TypeResponseWaiter = "watch_success"         // 开始监控, 附带当前的聊天记录
+	TypeResponseWaiterUnwatchSuccess      TypeResponseWaiter = "unwatch_success"       // 停止监控之后的回执
+	TypeResponseWaiterMonitor             TypeResponseWaiter = "monitor"               // 正在监控的会话中的消息
+	TypeResponseWaiterWhisper             TypeResponseWaiter = "whisper"               // 收到主管的提醒
+	TypeResponseWaiterWhisperSuccess      TypeResponseWaiter = "whisper_success"       // whisper 的回执
+	TypeResponseWaiterTicketList          TypeResponseWaiter = "ticket_list"           // 待处理的用户留言
+	TypeResponseWaiterReplyTicketSuccess  TypeResponseWaiter = "reply_ticket_success"  // reply_ticket 的回执
 )
@@ -1,6 +1,8 @@
 // Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
 package ws
 
+import "github.com/axetroy/go-server/internal/schema"
+
 type MessageTextPayload struct {
 	Text string `json:"text" validate:"required,max=255" comment:"消息体"`
 }
@@ -13,6 +15,10 @@ type QueuePayload struct {
 	Location uint `json:"location" validate:"required,int,min=0" comment:"位置"`
 }
 
+type ConnectPayload struct {
+	Topic string `json:"topic" validate:"omitempty,max=32" comment:"咨询的话题"` // 只会分配给拥有该技能的客服, 例如语言或者产品线
+}
+
 type AuthPayload struct {
 	Token string `json:"token" validate:"required,min=0" comment:"身份令牌"`
 }
@@ -20,3 +26,65 @@ type AuthPayload struct {
 type RatePayload struct {
 	Rate uint `json:"rate" validate:"required,int,min=1,max=5" comment:"评分"`
 }
+
+// 连接客服成功时, 推送客服的信息和恢复会话的令牌
+type ConnectSuccessPayload struct {
+	schema.ProfilePublic
+	Token string `json:"token" comment:"恢复会话的令牌"` // 断线重连之后, 使用该令牌恢复会话
+}
+
+type ResumePayload struct {
+	Token string `json:"token" validate:"required" comment:"恢复会话的令牌"`
+	Since string `json:"since" validate:"omitempty" comment:"最后收到的消息的时间"` // 重新推送这个时间之后的消息, 不填则推送断线之后的消息
+}
+
+type TransferPayload struct {
+	UUID   string `json:"uuid" validate:"required" comment:"用户的 UUID"`
+	Waiter string `json:"waiter" validate:"omitempty" comment:"目标客服的 UUID"`     // 和 topic 都不填则转到普通队列
+	Topic  string `json:"topic" validate:"omitempty,max=32" comment:"转接到的技能队列"` // 重新排队, 只会分配给拥有该技能的客服
+	Reason string `json:"reason" validate:"omitempty,max=255" comment:"转接的原因"`
+}
+
+type WatchPayload struct {
+	UUID string `json:"uuid" validate:"required" comment:"用户的 UUID"`
+}
+
+type WhisperPayload struct {
+	UUID string `json:"uuid" validate:"required" comment:"用户的 UUID"`
+	Text string `json:"text" validate:"required,max=255" comment:"消息体"`
+}
+
+type TakeoverPayload struct {
+	UUID   string `json:"uuid" validate:"required" comment:"用户的 UUID"`
+	Reason string `json:"reason" validate:"omitempty,max=255" comment:"接管的原因"`
+}
+
+// 抄送给主管的消息, from 为消息的发送者
+type MonitorPayload struct {
+	UUID    string      `json:"uuid"`    // 被监控的用户 UUID
+	Type    string      `json:"type"`    // 原来的消息类型
+	Payload interface{} `json:"payload"` // 原来的消息体
+}
+
+// 转为留言的原因
+const (
+	OfflineReasonClosed  = "closed"  // 不在营业时间
+	OfflineReasonTimeout = "timeout" // 排队超时
+)
+
+type OfflinePayload struct {
+	Reason string `json:"reason"` // 转为留言的原因
+}
+
+type ReplyTicketPayload struct {
+	Id      string `json:"id" validate:"required" comment:"留言 ID"`
+	Text    string `json:"text" validate:"required,max=255" comment:"回复内容"`
+	Resolve bool   `json:"resolve" comment:"是否标记为已解决"` // 标记为已解决之后, 用户再留言会创建新的留言
+}
+
+// 推送给用户的留言回复
+type TicketReplyPayload struct {
+	Id      string `json:"id"`      // 留言 ID
+	Message string `json:"message"` // 站内信 ID
+	Text    string `json:"text"`    // 回复内容
+}
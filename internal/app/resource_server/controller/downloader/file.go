@@ -2,24 +2,17 @@
 package downloader
 
 import (
-	"fmt"
-	"github.com/axetroy/go-fs"
 	config2 "github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"net/http"
 	"path"
 )
 
 var File = router.Handler(func(c router.Context) {
-	filename := c.Param("filename")
-	filePath := path.Join(config2.Upload.Path, config2.Upload.File.Path, filename)
-	if !fs.PathExists(filePath) {
-		// if the path not found
-		http.NotFound(c.Writer(), c.Request())
-		return
-	}
-
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%v", filename))
+	filename := path.Base(c.Param("filename"))
 
-	http.ServeFile(c.Writer(), c.Request(), filePath)
+	if err := storage.ServeAttachment(storage.GetClient(), c.Writer(), c.Request(), path.Join(config2.Upload.File.Path, filename), filename); err != nil {
+		http.Error(c.Writer(), err.Error(), http.StatusInternalServerError)
+	}
 })
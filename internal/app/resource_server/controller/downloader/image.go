@@ -2,22 +2,17 @@
 package downloader
 
 import (
-	"fmt"
-	"github.com/axetroy/go-fs"
 	config2 "github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"net/http"
 	"path"
 )
 
 var Image = router.Handler(func(c router.Context) {
-	filename := c.Param("filename")
-	originImagePath := path.Join(config2.Upload.Path, config2.Upload.Image.Path, filename)
-	if !fs.PathExists(originImagePath) {
-		// if the path not found
-		http.NotFound(c.Writer(), c.Request())
-		return
+	filename := path.Base(c.Param("filename"))
+
+	if err := storage.ServeAttachment(storage.GetClient(), c.Writer(), c.Request(), path.Join(config2.Upload.Image.Path, filename), filename); err != nil {
+		http.Error(c.Writer(), err.Error(), http.StatusInternalServerError)
 	}
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%v", filename))
-	http.ServeFile(c.Writer(), c.Request(), originImagePath)
 })
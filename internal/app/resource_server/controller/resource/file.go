@@ -2,20 +2,17 @@
 package resource
 
 import (
-	"github.com/axetroy/go-fs"
 	config2 "github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"net/http"
 	"path"
 )
 
 var File = router.Handler(func(c router.Context) {
-	filename := c.Param("filename")
-	filePath := path.Join(config2.Upload.Path, config2.Upload.File.Path, filename)
-	if !fs.PathExists(filePath) {
-		// if the path not found
-		http.NotFound(c.Writer(), c.Request())
-		return
+	filename := path.Base(c.Param("filename"))
+
+	if err := storage.Serve(storage.GetClient(), c.Writer(), c.Request(), path.Join(config2.Upload.File.Path, filename)); err != nil {
+		http.Error(c.Writer(), err.Error(), http.StatusInternalServerError)
 	}
-	http.ServeFile(c.Writer(), c.Request(), filePath)
 })
@@ -2,11 +2,11 @@
 package resource
 
 import (
-	"github.com/axetroy/go-fs"
 	"github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"golang.org/x/image/draw"
 	"image"
 	"image/gif"
@@ -15,7 +15,6 @@ import (
 	"io"
 	"mime"
 	"net/http"
-	"os"
 	"path"
 	"strings"
 )
@@ -50,9 +49,8 @@ func cropImage(img image.Image, width int, height int) image.Image {
 	return m
 }
 
-// 将文件解析为 img
-func DecodeImage(file *os.File) (img image.Image, err error) {
-	extname := path.Ext(file.Name())
+// 将文件解析为 img, extname 为文件的后缀名
+func DecodeImage(file io.Reader, extname string) (img image.Image, err error) {
 	// decode jpeg into image.Image
 	switch extname {
 	case ".jpg", ".jpeg":
@@ -68,10 +66,8 @@ func DecodeImage(file *os.File) (img image.Image, err error) {
 	return
 }
 
-// 将图片写到流中
-func EncodeImage(img *image.Image, file *os.File, writer io.Writer, option Query) (err error) {
-	extname := path.Ext(file.Name())
-
+// 将图片写到流中, extname 为输出图片的后缀名
+func EncodeImage(img *image.Image, extname string, writer io.Writer, option Query) (err error) {
 	var newImage image.Image
 
 	if option.Scale != nil {
@@ -137,14 +133,21 @@ var Image = router.Handler(func(c router.Context) {
 	var (
 		img      image.Image
 		err      error
-		filename = c.Param("filename")
-		file     *os.File
+		filename = path.Base(c.Param("filename"))
+		file     io.ReadCloser
 		query    Query
+		client   = storage.GetClient()
 	)
-	originImagePath := path.Join(config.Upload.Path, config.Upload.Image.Path, filename)
-	if !fs.PathExists(originImagePath) {
+
+	key := path.Join(config.Upload.Image.Path, filename)
+
+	if _, err = client.Stat(key); err != nil {
 		// if the path not found
-		http.NotFound(c.Writer(), c.Request())
+		if err == exception.FileNotExist {
+			http.NotFound(c.Writer(), c.Request())
+		} else {
+			http.Error(c.Writer(), err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -165,65 +168,35 @@ var Image = router.Handler(func(c router.Context) {
 	// 如果有错误的，那么就返回原始图片作为 fallback
 	defer func() {
 		if err != nil {
-			http.ServeFile(c.Writer(), c.Request(), originImagePath)
+			err = storage.Serve(client, c.Writer(), c.Request(), key)
 		}
 	}()
 
-	// 缩放图片
-	if query.Scale != nil {
+	// 缩放图片或者按尺寸裁剪
+	if query.Scale != nil || query.Width != nil || query.Height != nil {
 		extname := strings.ToLower(path.Ext(filename))
 
 		// 读取文件
-		if file, err = os.Open(originImagePath); err != nil {
-			return
-		}
-
-		defer func() {
-			if err = file.Close(); err != nil {
-				return
-			}
-		}()
-
-		if img, err = DecodeImage(file); err != nil {
-			return
-		}
-
-		c.Header("Content-Type", mime.TypeByExtension(extname))
-
-		if err = EncodeImage(&img, file, c.Writer(), query); err != nil {
+		if file, err = client.Open(key); err != nil {
 			return
 		}
 
-		return
-	}
-
-	// 按此村裁剪
-	if query.Width != nil || query.Height != nil {
-		extname := strings.ToLower(path.Ext(filename))
-
-		// 读取文件
-		if file, err = os.Open(originImagePath); err != nil {
-			return
-		}
+		img, err = DecodeImage(file, extname)
 
-		defer func() {
-			if err = file.Close(); err != nil {
-				return
-			}
-		}()
+		_ = file.Close()
 
-		if img, err = DecodeImage(file); err != nil {
+		if err != nil {
 			return
 		}
 
 		c.Header("Content-Type", mime.TypeByExtension(extname))
 
-		if err = EncodeImage(&img, file, c.Writer(), query); err != nil {
+		if err = EncodeImage(&img, extname, c.Writer(), query); err != nil {
 			return
 		}
 
 		return
 	}
 
-	http.ServeFile(c.Writer(), c.Request(), originImagePath)
+	err = storage.Serve(client, c.Writer(), c.Request(), key)
 })
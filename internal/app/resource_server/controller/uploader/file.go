@@ -2,15 +2,11 @@
 package uploader
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	config2 "github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/schema"
-	"io"
-	"mime/multipart"
-	"os"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"path"
 )
 
@@ -30,7 +26,7 @@ var File = router.Handler(func(c router.Context) {
 	// Get the max post value size passed via iris.WithPostMaxMemory.
 	//maxSize := c.Application().ConfigurationReadOnly().GetPostMaxMemory()
 
-	err = c.Request().ParseMultipartForm(maxUploadSize)
+	err = c.Request().ParseMultipartForm(maxMemory)
 
 	if err != nil {
 		return
@@ -47,10 +43,6 @@ var File = router.Handler(func(c router.Context) {
 	}
 
 	for _, file := range files {
-		var (
-			src  multipart.File // 要读取的文件
-			dist *os.File       // 最终输出的文件
-		)
 		extname := path.Ext(file.Filename)
 
 		// 判断是否是合法的上传文件
@@ -75,42 +67,11 @@ var File = router.Handler(func(c router.Context) {
 			}
 		}
 
-		if src, err = file.Open(); err != nil {
-			return
-		}
-
-		hash := md5.New()
-
-		if _, err = io.Copy(hash, src); err != nil {
-			_ = src.Close()
-			return
-		} else {
-			_ = src.Close()
-		}
-
-		md5string := hex.EncodeToString(hash.Sum([]byte("")))
-
-		fileName := md5string + extname
-
-		// 输出到最终文件
-		distPath := path.Join(config2.Upload.Path, config2.Upload.File.Path, fileName)
-
-		if dist, err = os.Create(distPath); err != nil {
-			return
-		}
-
-		if src, err = file.Open(); err != nil {
-			return
-		}
+		md5string, fileName, key, storeErr := store(file, config2.Upload.File.Path, extname)
 
-		// Copy
-		if _, err = io.Copy(dist, src); err != nil {
-			_ = src.Close()
-			_ = dist.Close()
+		if storeErr != nil {
+			err = storeErr
 			return
-		} else {
-			_ = src.Close()
-			_ = dist.Close()
 		}
 
 		res := schema.FileResponse{
@@ -120,6 +81,7 @@ var File = router.Handler(func(c router.Context) {
 			Size:         file.Size,
 			RawPath:      "/v1/resource/file/" + fileName,
 			DownloadPath: "/v1/download/file/" + fileName,
+			URL:          storage.GetClient().URL(key),
 		}
 
 		data = append(data, res)
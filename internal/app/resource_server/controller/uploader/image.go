@@ -2,15 +2,11 @@
 package uploader
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/schema"
-	"io"
-	"mime/multipart"
-	"os"
+	"github.com/axetroy/go-server/internal/service/storage"
 	"path"
 	"strings"
 )
@@ -27,7 +23,6 @@ var Image = router.Handler(func(c router.Context) {
 		maxUploadSize = config.Upload.Image.MaxSize // 最大上传大小
 		err           error
 		data          = make([]ImageResponse, 0)
-		imageDir      = path.Join(config.Upload.Path, config.Upload.Image.Path)
 	)
 
 	defer func() {
@@ -37,7 +32,7 @@ var Image = router.Handler(func(c router.Context) {
 	// Get the max post value size passed via iris.WithPostMaxMemory.
 	//maxSize := c.Application().ConfigurationReadOnly().GetPostMaxMemory()
 
-	err = c.Request().ParseMultipartForm(maxUploadSize)
+	err = c.Request().ParseMultipartForm(maxMemory)
 
 	if err != nil {
 		return
@@ -54,11 +49,6 @@ var Image = router.Handler(func(c router.Context) {
 	}
 
 	for _, file := range files {
-		var (
-			src  multipart.File // 要读取的文件
-			dist *os.File       // 最终输出的文件
-		)
-
 		// 判断是否是合法的图片
 		extname := strings.ToLower(path.Ext(file.Filename))
 
@@ -74,42 +64,11 @@ var Image = router.Handler(func(c router.Context) {
 			}
 		}
 
-		if src, err = file.Open(); err != nil {
-			return
-		}
-
-		hash := md5.New()
-
-		if _, err = io.Copy(hash, src); err != nil {
-			_ = src.Close()
-			return
-		} else {
-			_ = src.Close()
-		}
-
-		md5string := hex.EncodeToString(hash.Sum([]byte("")))
-
-		fileName := md5string + extname
-
-		// 输出到最终文件
-		distPath := path.Join(imageDir, fileName)
-
-		if dist, err = os.Create(distPath); err != nil {
-			return
-		}
-
-		if src, err = file.Open(); err != nil {
-			return
-		}
+		md5string, fileName, key, storeErr := store(file, config.Upload.Image.Path, extname)
 
-		// Copy
-		if _, err = io.Copy(dist, src); err != nil {
-			_ = src.Close()
-			_ = dist.Close()
+		if storeErr != nil {
+			err = storeErr
 			return
-		} else {
-			_ = src.Close()
-			_ = dist.Close()
 		}
 
 		res := ImageResponse{
@@ -120,6 +79,7 @@ var Image = router.Handler(func(c router.Context) {
 				Size:         file.Size,
 				RawPath:      "/v1/resource/image/" + fileName,
 				DownloadPath: "/v1/download/image/" + fileName,
+				URL:          storage.GetClient().URL(key),
 			},
 		}
 
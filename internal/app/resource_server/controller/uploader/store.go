@@ -0,0 +1,46 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/axetroy/go-server/internal/service/storage"
+	"io"
+	"mime/multipart"
+	"path"
+)
+
+// 上传的文件在内存中最多保留的大小, 超过的部分写入临时文件, 不会整个读进内存
+const maxMemory = 32 << 20
+
+// 流式计算文件的 md5, 再回到开头写入存储, 文件以 md5 命名
+func store(file *multipart.FileHeader, dir string, extname string) (hash string, fileName string, key string, err error) {
+	var src multipart.File
+
+	if src, err = file.Open(); err != nil {
+		return
+	}
+
+	defer func() {
+		_ = src.Close()
+	}()
+
+	h := md5.New()
+
+	if _, err = io.Copy(h, src); err != nil {
+		return
+	}
+
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	hash = hex.EncodeToString(h.Sum(nil))
+	fileName = hash + extname
+	key = path.Join(dir, fileName)
+
+	// 输出到存储中
+	_, err = storage.GetClient().Store(src, key)
+
+	return
+}
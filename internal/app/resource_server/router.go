@@ -9,10 +9,12 @@ import (
 	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/middleware"
+	"github.com/axetroy/go-server/pkg/rate_limit"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
 	"net/http"
+	"time"
 )
 
 var ResourceRouter *iris.Application
@@ -46,18 +48,25 @@ func init() {
 			}))
 		}
 
+		// 每个路由单独计算额度, 每个 IP 每秒 5 个请求
+		var (
+			uploadRateLimit   = middleware.RateLimit("resource", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 10}, middleware.RateLimitByForwardedFor, middleware.RateLimitByRoute)
+			resourceRateLimit = middleware.RateLimit("resource", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 50}, middleware.RateLimitByForwardedFor, middleware.RateLimitByRoute)
+			downloadRateLimit = middleware.RateLimit("resource", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 5}, middleware.RateLimitByForwardedFor, middleware.RateLimitByRoute)
+		)
+
 		// 通用类
 		{
 			// 文件上传
-			v1.Post("/upload/file", middleware.RateLimit(10), uploader.File)      // 上传文件
-			v1.Post("/upload/image", middleware.RateLimit(10), uploader.Image)    // 上传图片
-			v1.Get("/upload/example", middleware.RateLimit(10), uploader.Example) // 上传文件的 example
+			v1.Post("/upload/file", uploadRateLimit, uploader.File)      // 上传文件
+			v1.Post("/upload/image", uploadRateLimit, uploader.Image)    // 上传图片
+			v1.Get("/upload/example", uploadRateLimit, uploader.Example) // 上传文件的 example
 			//// 单纯获取资源文本
-			v1.Get("/resource/file/{filename}", middleware.RateLimit(50), resource.File)   // 获取文件纯文本
-			v1.Get("/resource/image/{filename}", middleware.RateLimit(50), resource.Image) // 获取图片纯文本
+			v1.Get("/resource/file/{filename}", resourceRateLimit, resource.File)   // 获取文件纯文本
+			v1.Get("/resource/image/{filename}", resourceRateLimit, resource.Image) // 获取图片纯文本
 			//// 下载资源
-			v1.Get("/download/file/{filename}", middleware.RateLimit(5), downloader.File)   // 下载文件
-			v1.Get("/download/image/{filename}", middleware.RateLimit(5), downloader.Image) // 下载图片
+			v1.Get("/download/file/{filename}", downloadRateLimit, downloader.File)   // 下载文件
+			v1.Get("/download/image/{filename}", downloadRateLimit, downloader.Image) // 下载图片
 		}
 
 	}
@@ -3,8 +3,8 @@ package resource_server
 
 import (
 	"context"
-	resourceConfig "github.com/axetroy/go-server/internal/app/resource_server/config"
 	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/service/redis"
 	"log"
 	"net"
 	"net/http"
@@ -16,7 +16,12 @@ import (
 )
 
 func Serve(host string, port string) error {
-	resourceConfig.Init()
+	// 限流的计数存放在 redis 中
+	redis.Connect()
+
+	defer func() {
+		redis.Dispose()
+	}()
 
 	s := &http.Server{
 		Addr:           net.JoinHostPort(host, port),
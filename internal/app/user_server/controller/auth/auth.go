@@ -1,30 +1,53 @@
 package auth
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/email"
-	"github.com/axetroy/go-server/internal/service/redis"
-	"github.com/axetroy/go-server/internal/service/telephone"
-	"time"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/verifier"
+	"github.com/jinzhu/gorm"
 )
 
 type SendEmailAuthCodeParams struct {
-	Email string `json:"email" validate:"required,email" comment:"邮箱"`
+	Email   string `json:"email" validate:"required,email" comment:"邮箱"`
+	Purpose string `json:"purpose" validate:"omitempty,oneof=register signin reset bind" comment:"用途"` // 验证码的用途, 不同用途的验证码不能混用, 默认为 signin
 }
 
 type SendPhoneAuthCodeParams struct {
-	Phone string `json:"phone" validate:"required,numeric,len=11" comment:"手机号"`
+	Phone   string `json:"phone" validate:"required,numeric,len=11" comment:"手机号"`
+	Purpose string `json:"purpose" validate:"omitempty,oneof=register signin reset bind" comment:"用途"` // 验证码的用途, 不同用途的验证码不能混用, 默认为 signin
 }
 
-func GenerateAuthCode() string {
-	return util.RandomString(6)
+// 没有指定用途时, 兼容旧的客户端, 按照验证码登陆处理
+func authCodePurpose(purpose string) verifier.Purpose {
+	if purpose == "" {
+		return verifier.PurposeSignIn
+	}
+
+	return verifier.Purpose(purpose)
+}
+
+// 根据用途检查是否需要发送验证码
+// 注册和绑定要求没有被使用过, 登陆和重置密码要求帐号存在
+func shouldSendAuthCode(db *gorm.DB, purpose verifier.Purpose, column string, address string) (bool, error) {
+	var count int
+
+	if err := db.Model(&model.User{}).Where(column+" = ?", address).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	switch purpose {
+	case verifier.PurposeRegister, verifier.PurposeBind:
+		return count == 0, nil
+	default:
+		return count != 0, nil
+	}
 }
 
 // 发送邮箱验证码 (不需要登陆)
@@ -53,27 +76,33 @@ func SendEmailAuthCode(c helper.Context, input SendEmailAuthCodeParams) (res sch
 		return
 	}
 
-	// 生成验证码
-	activationCode := GenerateAuthCode()
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
 
-	// 缓存验证码到 redis
-	if err = redis.ClientAuthEmailCode.Set(context.Background(), activationCode, input.Email, time.Minute*10).Err(); err != nil {
+	// 限制同一个邮箱和 IP 的发送频率
+	if err = g.Send(guard.Account(guard.ScopeEmail, input.Email), guard.Ip(c.Ip)); err != nil {
 		return
 	}
 
-	e, err := email.NewMailer()
+	purpose := authCodePurpose(input.Purpose)
+
+	ok, err := shouldSendAuthCode(database.Db, purpose, "email", input.Email)
 
 	if err != nil {
 		return
 	}
 
-	// send email
-	if err = e.SendAuthEmail(input.Email, activationCode); err != nil {
-		// 邮件没发出去的话，删除redis的key
-		_ = redis.ClientAuthEmailCode.Del(context.Background(), activationCode).Err()
+	// 用途不适用时不发送, 但是返回和发送时相同的结果, 避免通过这个接口判断帐号是否存在
+	if !ok {
+		err = verifier.Skip(verifier.Email(input.Email), purpose)
 		return
 	}
 
+	err = verifier.Send(verifier.Email(input.Email), purpose)
+
 	return
 }
 
@@ -103,20 +132,33 @@ func SendPhoneAuthCode(c helper.Context, input SendPhoneAuthCodeParams) (res sch
 		return
 	}
 
-	// 生成验证码
-	activationCode := GenerateAuthCode()
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	// 限制同一个手机号和 IP 的发送频率
+	if err = g.Send(guard.Account(guard.ScopePhone, input.Phone), guard.Ip(c.Ip)); err != nil {
+		return
+	}
+
+	purpose := authCodePurpose(input.Purpose)
 
-	// 缓存验证码到 redis
-	if err = redis.ClientAuthPhoneCode.Set(context.Background(), activationCode, input.Phone, time.Minute*10).Err(); err != nil {
+	ok, err := shouldSendAuthCode(database.Db, purpose, "phone", input.Phone)
+
+	if err != nil {
 		return
 	}
 
-	if err = telephone.GetClient().SendAuthCode(input.Phone, activationCode); err != nil {
-		// 如果发送失败，则删除
-		_ = redis.ClientAuthPhoneCode.Del(context.Background(), activationCode).Err()
+	// 用途不适用时不发送, 但是返回和发送时相同的结果, 避免通过这个接口判断帐号是否存在
+	if !ok {
+		err = verifier.Skip(verifier.Phone(input.Phone), purpose)
 		return
 	}
 
+	err = verifier.Send(verifier.Phone(input.Phone), purpose)
+
 	return
 }
 
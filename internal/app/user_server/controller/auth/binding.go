@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
@@ -10,19 +9,19 @@ import (
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/axetroy/go-server/internal/service/wechat"
 	"github.com/jinzhu/gorm"
 )
 
 type BindingEmailParams struct {
 	Email string `json:"email" validate:"required,email,max=36" comment:"邮箱"` // 邮箱
-	Code  string `json:"code" validate:"required,len=6" comment:"验证码"`        // 邮箱收到的验证码
+	Code  string `json:"code" validate:"required" comment:"验证码"`              // 邮箱收到的验证码
 }
 
 type BindingPhoneParams struct {
 	Phone string `json:"phone" validate:"required,numeric,len=11" comment:"手机号"` // 手机号
-	Code  string `json:"code" validate:"required,len=6" comment:"验证码"`           // 手机收到的验证码
+	Code  string `json:"code" validate:"required" comment:"验证码"`                 // 手机收到的验证码
 }
 
 type BindingWechatMiniAppParams struct {
@@ -101,15 +100,7 @@ func BindingEmail(c helper.Context, input BindingEmailParams) (res schema.Respon
 	}
 
 	// 校验验证码正确不正确
-	email, err := redis.ClientAuthEmailCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
-	// 验证是否正确
-	if email != input.Email {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Email(input.Email), verifier.PurposeBind, input.Code); err != nil {
 		return
 	}
 
@@ -192,15 +183,7 @@ func BindingPhone(c helper.Context, input BindingPhoneParams) (res schema.Respon
 	}
 
 	// 校验验证码正确不正确
-	phone, err := redis.ClientAuthPhoneCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
-	// 验证是否正确
-	if phone != input.Phone {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Phone(input.Phone), verifier.PurposeBind, input.Code); err != nil {
 		return
 	}
 
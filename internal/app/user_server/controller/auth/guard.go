@@ -0,0 +1,76 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth
+
+import (
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/notify"
+	"log"
+)
+
+// 登陆之前检查帐号和 IP 是否被锁定, 并记录这次尝试
+func checkSignIn(c helper.Context, account guard.Target) (*guard.Guard, error) {
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.Attempt(account, guard.Ip(c.Ip)); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// 登陆失败, 记录失败次数. 帐号存在时写入失败的登陆记录, 帐号被锁定时推送提醒
+func signInFail(g *guard.Guard, c helper.Context, account guard.Target, uid string, loginType model.LoginLogType) {
+	locked, err := g.Fail(account, guard.Ip(c.Ip))
+
+	if err != nil {
+		log.Println(err)
+	}
+
+	if uid == "" {
+		return
+	}
+
+	// 登陆的事务会回滚, 失败的登陆记录需要单独写入
+	if err := database.Db.Create(&model.LoginLog{
+		Uid:     uid,                            // 用户ID
+		Type:    loginType,                      // 登陆方式
+		Command: model.LoginLogCommandLoginFail, // 登陆失败
+		Client:  c.UserAgent,                    // 用户的 userAgent
+		LastIp:  c.Ip,                           // 用户的IP
+	}).Error; err != nil {
+		log.Println(err)
+	}
+
+	for _, t := range locked {
+		if t.IsIp() {
+			continue
+		}
+
+		go func() {
+			if err := notify.Notify.SendNotifyToUserForAccountLocked(uid, c.Ip); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// 登陆成功, 清除帐号的失败次数
+func signInSuccess(g *guard.Guard, c helper.Context, account guard.Target) {
+	if err := g.Reset(account, guard.Ip(c.Ip)); err != nil {
+		log.Println(err)
+	}
+}
+
+// 第一步验证通过, 还需要第二步验证. 只撤销 IP 的这次尝试, 帐号的次数在第二步通过之后才清除
+func signInPending(g *guard.Guard, c helper.Context) {
+	if err := g.Reset(guard.Ip(c.Ip)); err != nil {
+		log.Println(err)
+	}
+}
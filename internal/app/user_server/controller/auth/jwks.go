@@ -0,0 +1,20 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/token"
+)
+
+// 获取用于验证用户 token 的公钥, 其他服务可以离线验证 token
+// 按照 RFC 7517 的格式直接输出, 不包装成通用的响应结构, 方便现成的 JWT 库直接使用
+var JWKSRouter = router.Handler(func(c router.Context) {
+	w := c.Writer()
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	// 密钥轮换时新的公钥会提前发布, 允许短时间的缓存
+	w.Header().Set("Cache-Control", "public, max-age=60")
+
+	_ = json.NewEncoder(w).Encode(token.JWKS(token.StateUser))
+})
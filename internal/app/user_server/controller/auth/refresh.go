@@ -0,0 +1,67 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"time"
+)
+
+type RefreshTokenParams struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=64" comment:"刷新令牌"` // 登陆时返回的刷新令牌
+}
+
+// 使用刷新令牌换取新的身份令牌, 刷新令牌只能使用一次
+func RefreshToken(c helper.Context, input RefreshTokenParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Token
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	t, err := authentication.Gateway(false).Refresh(input.RefreshToken)
+
+	if err != nil {
+		return
+	}
+
+	data.Token = t.Token
+	data.RefreshToken = t.RefreshToken
+	data.ExpiredAt = t.ExpiredAt.Format(time.RFC3339Nano)
+
+	return
+}
+
+var RefreshTokenRouter = router.Handler(func(c router.Context) {
+	var (
+		input RefreshTokenParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RefreshToken(helper.NewContext(&c), input)
+	})
+})
@@ -2,30 +2,30 @@
 package auth
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/jinzhu/gorm"
 )
 
 type ResetPasswordParams struct {
-	Code        string `json:"code" validate:"required" comment:"激活码"`
-	NewPassword string `json:"new_password" validate:"required,max=32" comment:"新密码"`
+	Email       *string `json:"email" validate:"omitempty,email,max=255" comment:"邮箱"`   // 接收重置码的邮箱, 和手机号二者提供其中一个
+	Phone       *string `json:"phone" validate:"omitempty,numeric,len=11" comment:"手机号"` // 接收重置码的手机号
+	Code        string  `json:"code" validate:"required" comment:"重置码"`
+	NewPassword string  `json:"new_password" validate:"required,max=32" comment:"新密码"`
 }
 
 func ResetPassword(input ResetPasswordParams) (res schema.Response) {
 	var (
 		err error
 		tx  *gorm.DB
-		uid string // 重置码对应的uid
 	)
 
 	defer func() {
@@ -56,14 +56,31 @@ func ResetPassword(input ResetPasswordParams) (res schema.Response) {
 		return
 	}
 
-	if uid, err = redis.ClientResetCode.Get(context.Background(), input.Code).Result(); err != nil {
-		err = exception.InvalidResetCode
+	var (
+		target   verifier.Target
+		userInfo model.User
+	)
+
+	if input.Email != nil {
+		target = verifier.Email(*input.Email)
+		userInfo.Email = input.Email
+	} else if input.Phone != nil {
+		target = verifier.Phone(*input.Phone)
+		userInfo.Phone = input.Phone
+	} else {
+		err = exception.InvalidParams
 		return
 	}
 
-	tx = database.Db.Begin()
+	// 重置码使用之后立即失效
+	if err = verifier.Verify(target, verifier.PurposeResetPassword, input.Code); err != nil {
+		if verifier.IsInvalid(err) {
+			err = exception.InvalidResetCode
+		}
+		return
+	}
 
-	userInfo := model.User{Id: uid}
+	tx = database.Db.Begin()
 
 	if err = tx.Where(&userInfo).First(&userInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -73,10 +90,13 @@ func ResetPassword(input ResetPasswordParams) (res schema.Response) {
 	}
 
 	// 更新密码
-	tx.Model(&userInfo).Update("password", util.GeneratePassword(input.NewPassword))
+	var hashed string
+
+	if hashed, err = password.Generate(input.NewPassword); err != nil {
+		return
+	}
 
-	// delete reset code from redis
-	if err = redis.ClientResetCode.Del(context.Background(), input.Code).Err(); err != nil {
+	if err = tx.Model(&userInfo).Update("password", hashed).Error; err != nil {
 		return
 	}
 
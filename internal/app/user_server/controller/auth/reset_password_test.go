@@ -2,21 +2,18 @@
 package auth_test
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
 	"github.com/axetroy/go-server/internal/library/exception"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/captcha"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/axetroy/go-server/tester"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
-	"time"
 )
 
 func TestResetPasswordWithEmptyBody(t *testing.T) {
@@ -37,7 +34,10 @@ func TestResetPasswordWithEmptyBody(t *testing.T) {
 func TestResetPasswordWithInvalidPassword(t *testing.T) {
 	newPassword := "321321"
 
+	email := "test-TestResetPasswordWithInvalidPassword@example.com"
+
 	body, _ := json.Marshal(&auth.ResetPasswordParams{
+		Email:       &email,
 		NewPassword: newPassword,
 		Code:        "123123", // 错误的重置码
 	})
@@ -60,9 +60,9 @@ func TestResetPasswordSuccess(t *testing.T) {
 	// 先创建一个测试账号
 	var (
 		username    = "test-TestResetPasswordSuccess"
+		email       = "test-TestResetPasswordSuccess@example.com"
 		oldPassword = "123123"
 		uid         string
-		resetCode   string
 		newPassword = "321321"
 	)
 	if r := auth.SignUpWithUsername(auth.SignUpWithUsernameParams{
@@ -81,16 +81,21 @@ func TestResetPasswordSuccess(t *testing.T) {
 		defer tester.DeleteUserByUserName(username)
 	}
 
-	resetCode = captcha.GenerateResetCode(uid)
+	// 绑定接收重置码的邮箱
+	if err := database.Db.Model(&model.User{Id: uid}).Update("email", email).Error; err != nil {
+		t.Error(err)
+		return
+	}
+
+	resetCode, err := verifier.Issue(verifier.Email(email), verifier.PurposeResetPassword)
 
-	// set to redis
-	// set activationCode to redis
-	if err := redis.ClientResetCode.Set(context.Background(), resetCode, uid, time.Minute*30).Err(); err != nil {
+	if err != nil {
 		t.Error(err)
 		return
 	}
 
 	body, _ := json.Marshal(&auth.ResetPasswordParams{
+		Email:       &email,
 		NewPassword: newPassword,
 		Code:        resetCode,
 	})
@@ -106,6 +111,13 @@ func TestResetPasswordSuccess(t *testing.T) {
 	assert.Equal(t, schema.StatusSuccess, res.Status)
 	assert.Equal(t, nil, res.Data)
 
+	// 重置码只能使用一次
+	r = tester.HttpUser.Put("/v1/auth/password/reset", body, nil)
+
+	assert.Nil(t, json.Unmarshal(r.Body.Bytes(), &res))
+	assert.Equal(t, exception.InvalidResetCode.Code(), res.Status)
+	assert.Equal(t, exception.InvalidResetCode.Error(), res.Message)
+
 	var (
 		ormErr error
 	)
@@ -118,7 +130,11 @@ func TestResetPasswordSuccess(t *testing.T) {
 			Id: uid,
 		}
 
-		ormErr = tx.Model(&userInfo).Update("password", util.GeneratePassword(oldPassword)).Error
+		hashed, err := password.Generate(oldPassword)
+
+		assert.Nil(t, err)
+
+		ormErr = tx.Model(&userInfo).Update("password", hashed).Error
 
 		if ormErr == nil {
 			tx.Commit()
@@ -137,5 +153,5 @@ func TestResetPasswordSuccess(t *testing.T) {
 	}
 
 	// 两次密码应该一致
-	assert.Equal(t, util.GeneratePassword(newPassword), userInfo.Password)
+	assert.True(t, password.Verify(newPassword, userInfo.Password))
 }
@@ -2,9 +2,7 @@
 package auth
 
 import (
-	"context"
 	"errors"
-	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
@@ -13,10 +11,14 @@ import (
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/axetroy/go-server/internal/service/dotenv"
+	"github.com/axetroy/go-server/internal/service/guard"
 	"github.com/axetroy/go-server/internal/service/message_queue"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/oauth"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/axetroy/go-server/internal/service/wechat"
 	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
@@ -62,9 +64,10 @@ type WechatCompleteParams struct {
 // 普通帐号登陆
 func SignIn(c helper.Context, input SignInParams) (res schema.Response) {
 	var (
-		err  error
-		data = &schema.ProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -87,37 +90,85 @@ func SignIn(c helper.Context, input SignInParams) (res schema.Response) {
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	if err = validator.ValidateStruct(input); err != nil {
 		return
 	}
 
-	userInfo := model.User{
-		Password: util.GeneratePassword(input.Password),
-	}
+	var (
+		userInfo  = model.User{}
+		account   guard.Target
+		loginType model.LoginLogType
+	)
 
 	if validator.IsPhone(input.Account) {
 		// 用手机号登陆
 		userInfo.Phone = &input.Account
+		account = guard.Account(guard.ScopePhone, input.Account)
+		loginType = model.LoginLogTypeTel
 	} else if validator.IsEmail(input.Account) {
 		// 用邮箱登陆
 		userInfo.Email = &input.Account
+		account = guard.Account(guard.ScopeEmail, input.Account)
+		loginType = model.LoginLogTypeEmail
 	} else {
 		// 用用户名
 		userInfo.Username = input.Account
+		account = guard.Account(guard.ScopeUser, input.Account)
+		loginType = model.LoginLogTypeUserName
+	}
+
+	// 检查帐号和 IP 是否被锁定
+	g, err := checkSignIn(c, account)
+
+	if err != nil {
+		return
 	}
 
 	tx = database.Db.Begin()
 
 	if err = tx.Where(&userInfo).Preload("Wechat").Last(&userInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// 帐号不存在时同样校验一次密码, 避免通过响应时间判断帐号是否存在
+			password.Verify(input.Password, password.Dummy())
+			signInFail(g, c, account, "", loginType)
 			err = exception.InvalidAccountOrPassword
 		}
 		return
 	}
 
+	if !password.Verify(input.Password, userInfo.Password) {
+		signInFail(g, c, account, userInfo.Id, loginType)
+		err = exception.InvalidAccountOrPassword
+		return
+	}
+
+	// 开启了双重身份认证的帐号, 通过第二步的验证之后才清除失败次数
+	if userInfo.EnableTOTP {
+		signInPending(g, c)
+	} else {
+		signInSuccess(g, c, account)
+	}
+
+	// 旧的密码 hash 在登录成功后升级为当前的算法
+	if password.NeedRehash(userInfo.Password) {
+		var hashed string
+
+		if hashed, err = password.Generate(input.Password); err != nil {
+			return
+		}
+
+		if err = tx.Model(&userInfo).Update("password", hashed).Error; err != nil {
+			return
+		}
+	}
+
 	// 检查用户登录状态
 	go func() {
 		if er := message_queue.PublishCheckUserLogin(userInfo.Id); er != nil {
@@ -151,18 +202,25 @@ func SignIn(c helper.Context, input SignInParams) (res schema.Response) {
 		duration = time.Hour * 6
 	}
 
+	// 开启了双重身份认证, 先返回挑战, 通过动态密码的验证之后才生成身份令牌
+	if userInfo.EnableTOTP {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, loginType, account)
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	// 写入登陆记录
 	loginLog := model.LoginLog{
 		Uid:     userInfo.Id,                       // 用户ID
-		Type:    model.LoginLogTypeUserName,        // 默认用户名登陆
+		Type:    loginType,                         // 登陆方式
 		Command: model.LoginLogCommandLoginSuccess, // 登陆成功
 		Client:  c.UserAgent,                       // 用户的 userAgent
 		LastIp:  c.Ip,                              // 用户的IP
@@ -178,9 +236,10 @@ func SignIn(c helper.Context, input SignInParams) (res schema.Response) {
 // 邮箱 + 验证码登陆
 func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.Response) {
 	var (
-		err  error
-		data = &schema.ProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -203,7 +262,11 @@ func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	// 参数校验
@@ -211,11 +274,13 @@ func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.
 		return
 	}
 
-	email, err := redis.ClientAuthEmailCode.Get(context.Background(), input.Code).Result()
+	account := guard.Account(guard.ScopeEmail, input.Email)
 
-	// 校验验证码是否正确
-	if err != nil || email != input.Email {
-		err = exception.InvalidParams
+	// 检查邮箱和 IP 是否被锁定
+	g, err := checkSignIn(c, account)
+
+	if err != nil {
+		return
 	}
 
 	userInfo := model.User{
@@ -226,11 +291,27 @@ func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.
 
 	if err = tx.Where(&userInfo).Preload("Wechat").Last(&userInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			signInFail(g, c, account, "", model.LoginLogTypeEmail)
 			err = exception.InvalidAccountOrPassword
 		}
 		return
 	}
 
+	// 校验验证码是否正确
+	if err = verifier.Verify(verifier.Email(input.Email), verifier.PurposeSignIn, input.Code); err != nil {
+		if verifier.IsInvalid(err) {
+			signInFail(g, c, account, userInfo.Id, model.LoginLogTypeEmail)
+		}
+		return
+	}
+
+	// 开启了双重身份认证的帐号, 通过第二步的验证之后才清除失败次数
+	if userInfo.EnableTOTP {
+		signInPending(g, c)
+	} else {
+		signInSuccess(g, c, account)
+	}
+
 	if err = userInfo.CheckStatusValid(); err != nil {
 		return
 	}
@@ -257,18 +338,25 @@ func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.
 		duration = time.Hour * 6
 	}
 
+	// 开启了双重身份认证, 先返回挑战, 通过动态密码的验证之后才生成身份令牌
+	if userInfo.EnableTOTP {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, model.LoginLogTypeEmail, account)
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	// 写入登陆记录
 	loginLog := model.LoginLog{
 		Uid:     userInfo.Id,                       // 用户ID
-		Type:    model.LoginLogTypeUserName,        // 默认用户名登陆
+		Type:    model.LoginLogTypeEmail,           // 邮箱登陆
 		Command: model.LoginLogCommandLoginSuccess, // 登陆成功
 		Client:  c.UserAgent,                       // 用户的 userAgent
 		LastIp:  c.Ip,                              // 用户的IP
@@ -284,9 +372,10 @@ func SignInWithEmail(c helper.Context, input SignInWithEmailParams) (res schema.
 // 手机 + 验证码登陆
 func SignInWithPhone(c helper.Context, input SignInWithPhoneParams) (res schema.Response) {
 	var (
-		err  error
-		data = &schema.ProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -309,7 +398,11 @@ func SignInWithPhone(c helper.Context, input SignInWithPhoneParams) (res schema.
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	// 参数校验
@@ -317,26 +410,44 @@ func SignInWithPhone(c helper.Context, input SignInWithPhoneParams) (res schema.
 		return
 	}
 
-	phone, err := redis.ClientAuthPhoneCode.Get(context.Background(), input.Code).Result()
+	account := guard.Account(guard.ScopePhone, input.Phone)
 
-	// 校验验证码是否正确
-	if err != nil || phone != input.Phone {
-		err = exception.InvalidParams
+	// 检查手机号和 IP 是否被锁定
+	g, err := checkSignIn(c, account)
+
+	if err != nil {
+		return
 	}
 
 	userInfo := model.User{
-		Email: &input.Phone,
+		Phone: &input.Phone,
 	}
 
 	tx = database.Db.Begin()
 
 	if err = tx.Where(&userInfo).Preload("Wechat").Last(&userInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			signInFail(g, c, account, "", model.LoginLogTypeTel)
 			err = exception.InvalidAccountOrPassword
 		}
 		return
 	}
 
+	// 校验验证码是否正确
+	if err = verifier.Verify(verifier.Phone(input.Phone), verifier.PurposeSignIn, input.Code); err != nil {
+		if verifier.IsInvalid(err) {
+			signInFail(g, c, account, userInfo.Id, model.LoginLogTypeTel)
+		}
+		return
+	}
+
+	// 开启了双重身份认证的帐号, 通过第二步的验证之后才清除失败次数
+	if userInfo.EnableTOTP {
+		signInPending(g, c)
+	} else {
+		signInSuccess(g, c, account)
+	}
+
 	if err = userInfo.CheckStatusValid(); err != nil {
 		return
 	}
@@ -363,18 +474,25 @@ func SignInWithPhone(c helper.Context, input SignInWithPhoneParams) (res schema.
 		duration = time.Hour * 6
 	}
 
+	// 开启了双重身份认证, 先返回挑战, 通过动态密码的验证之后才生成身份令牌
+	if userInfo.EnableTOTP {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, model.LoginLogTypeTel, account)
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	// 写入登陆记录
 	loginLog := model.LoginLog{
 		Uid:     userInfo.Id,                       // 用户ID
-		Type:    model.LoginLogTypeUserName,        // 默认用户名登陆
+		Type:    model.LoginLogTypeTel,             // 手机登陆
 		Command: model.LoginLogCommandLoginSuccess, // 登陆成功
 		Client:  c.UserAgent,                       // 用户的 userAgent
 		LastIp:  c.Ip,                              // 用户的IP
@@ -390,9 +508,10 @@ func SignInWithPhone(c helper.Context, input SignInWithPhoneParams) (res schema.
 // 使用微信小程序登陆
 func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schema.Response) {
 	var (
-		err  error
-		data = &schema.ProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -415,7 +534,11 @@ func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schem
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	// 参数校验
@@ -458,10 +581,16 @@ func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schem
 			username = "v" + uid
 		)
 
+		var hashed string
+
+		if hashed, err = password.Generate(uid); err != nil {
+			return
+		}
+
 		userInfo = &model.User{
 			Username:                username,
 			Nickname:                &username,
-			Password:                util.GeneratePassword(uid),
+			Password:                hashed,
 			Status:                  model.UserStatusInit,
 			Role:                    pq.StringArray{model.DefaultUser.Name},
 			Gender:                  model.GenderUnknown,
@@ -481,15 +610,8 @@ func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schem
 		}
 
 		// 创建用户对应的钱包账号
-		for _, walletName := range model.Wallets {
-			if err = tx.Table(wallet.GetTableName(walletName)).Create(&model.Wallet{
-				Id:       userInfo.Id,
-				Currency: walletName,
-				Balance:  0,
-				Frozen:   0,
-			}).Error; err != nil {
-				return
-			}
+		if err = currency.CreateWallets(tx, userInfo.Id); err != nil {
+			return
 		}
 
 	} else {
@@ -524,12 +646,19 @@ func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schem
 		duration = time.Hour * 6
 	}
 
+	// 开启了双重身份认证, 先返回挑战, 通过动态密码的验证之后才生成身份令牌
+	if userInfo.EnableTOTP {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, model.LoginLogTypeWechat, guard.Account(guard.ScopeUser, userInfo.Username))
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	// 写入登陆记录
@@ -551,9 +680,10 @@ func SignInWithWechat(c helper.Context, input SignInWithWechatParams) (res schem
 // 使用 oAuth 认证方式登陆
 func SignInWithOAuth(c helper.Context, input SignInWithOAuthParams) (res schema.Response) {
 	var (
-		err  error
-		data = &schema.ProfileWithToken{}
-		tx   *gorm.DB
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
 	)
 
 	defer func() {
@@ -576,7 +706,11 @@ func SignInWithOAuth(c helper.Context, input SignInWithOAuthParams) (res schema.
 			}
 		}
 
-		helper.Response(&res, data, nil, err)
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
 	}()
 
 	// 参数校验
@@ -584,7 +718,7 @@ func SignInWithOAuth(c helper.Context, input SignInWithOAuthParams) (res schema.
 		return
 	}
 
-	uid, err := redis.ClientOAuthCode.Get(context.Background(), input.Code).Result()
+	uid, err := oauth.UseSignInCode(input.Code)
 
 	if err != nil {
 		return
@@ -594,6 +728,8 @@ func SignInWithOAuth(c helper.Context, input SignInWithOAuthParams) (res schema.
 		Id: uid,
 	}
 
+	tx = database.Db.Begin()
+
 	if err = tx.Where(&userInfo).Preload("Wechat").Find(&userInfo).Error; err != nil {
 		return
 	}
@@ -620,12 +756,19 @@ func SignInWithOAuth(c helper.Context, input SignInWithOAuthParams) (res schema.
 		duration = time.Hour * 6
 	}
 
+	// 开启了双重身份认证, 先返回挑战, 通过动态密码的验证之后才生成身份令牌
+	if userInfo.EnableTOTP {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, model.LoginLogTypeUserName, guard.Account(guard.ScopeUser, userInfo.Username))
+		return
+	}
+
 	// generate token
 	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
 		err = er
 		return
 	} else {
-		data.Token = t
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
 	}
 
 	// 写入登陆记录
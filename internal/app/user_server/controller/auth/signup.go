@@ -2,9 +2,7 @@
 package auth
 
 import (
-	"context"
 	"errors"
-	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
@@ -12,9 +10,11 @@ import (
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/email"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/invite"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
 	"github.com/mitchellh/mapstructure"
@@ -68,6 +68,8 @@ func CreateUserTx(tx *gorm.DB, userInfo *model.User, inviterCode *string) (err e
 		return err
 	}
 
+	var inviteHistory *model.InviteHistory
+
 	if inviterCode != nil && len(*inviterCode) > 0 {
 
 		inviter := model.User{
@@ -83,7 +85,7 @@ func CreateUserTx(tx *gorm.DB, userInfo *model.User, inviterCode *string) (err e
 
 		// 如果存在邀请者的话，写入邀请列表中
 		if inviter.Id != "" {
-			inviteHistory := model.InviteHistory{
+			inviteHistory = &model.InviteHistory{
 				Inviter:       inviter.Id,
 				Invitee:       userInfo.Id,
 				Status:        model.StatusInviteRegistered,
@@ -91,20 +93,20 @@ func CreateUserTx(tx *gorm.DB, userInfo *model.User, inviterCode *string) (err e
 			}
 
 			// 创建邀请记录
-			if err = tx.Create(&inviteHistory).Error; err != nil {
+			if err = tx.Create(inviteHistory).Error; err != nil {
 				return err
 			}
 		}
 	}
 
 	// 创建用户对应的钱包账号
-	for _, walletName := range model.Wallets {
-		if err = tx.Table(wallet.GetTableName(walletName)).Create(&model.Wallet{
-			Id:       userInfo.Id,
-			Currency: walletName,
-			Balance:  0,
-			Frozen:   0,
-		}).Error; err != nil {
+	if err = currency.CreateWallets(tx, userInfo.Id); err != nil {
+		return err
+	}
+
+	// 发放注册的邀请奖励, 需要在钱包创建之后
+	if inviteHistory != nil {
+		if err = invite.Settle(tx, inviteHistory); err != nil {
 			return err
 		}
 	}
@@ -167,10 +169,16 @@ func SignUpWithUsername(input SignUpWithUsernameParams) (res schema.Response) {
 		return
 	}
 
+	hashed, err := password.Generate(input.Password)
+
+	if err != nil {
+		return
+	}
+
 	userInfo := model.User{
 		Username: input.Username,
 		Nickname: &input.Username,
-		Password: util.GeneratePassword(input.Password),
+		Password: hashed,
 		Status:   model.UserStatusInit,
 		Role:     pq.StringArray{model.DefaultUser.Name},
 		Phone:    nil,
@@ -229,15 +237,8 @@ func SignUpWithEmail(input SignUpWithEmailParams) (res schema.Response) {
 		return
 	}
 
-	emailAddr, err := redis.ClientAuthEmailCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
 	// 校验邮箱验证码是否一致
-	if emailAddr != input.Email {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Email(input.Email), verifier.PurposeRegister, input.Code); err != nil {
 		return
 	}
 
@@ -258,10 +259,16 @@ func SignUpWithEmail(input SignUpWithEmailParams) (res schema.Response) {
 
 	username := "u" + util.GenerateId()
 
+	hashed, err := password.Generate(input.Password)
+
+	if err != nil {
+		return
+	}
+
 	userInfo := model.User{
 		Username:                username,
 		Nickname:                &username,
-		Password:                util.GeneratePassword(input.Password),
+		Password:                hashed,
 		Status:                  model.UserStatusInit,
 		Role:                    pq.StringArray{model.DefaultUser.Name},
 		Phone:                   nil,
@@ -326,29 +333,19 @@ func SignUpWithEmailAction(input SignUpWithEmailActionParams) (res schema.Respon
 
 	tx = database.Db.Begin()
 
-	if err = tx.Where("email = ?", input.Email).Find(&model.User{Email: &input.Email}).Error; err != nil {
-		if err != gorm.ErrRecordNotFound {
-			err = exception.UserExist
-		}
-		return
-	}
-
-	code := GenerateAuthCode()
+	ok, err := shouldSendAuthCode(tx, verifier.PurposeRegister, "email", input.Email)
 
-	if err = redis.ClientAuthEmailCode.Set(context.Background(), code, input.Email, 10*time.Minute).Err(); err != nil {
+	if err != nil {
 		return
 	}
 
-	e, err := email.NewMailer()
-
-	if err != nil {
+	if !ok {
+		err = exception.UserExist
 		return
 	}
 
 	// 发送邮件
-	if err = e.SendAuthEmail(input.Email, code); err != nil {
-		return
-	}
+	err = verifier.Send(verifier.Email(input.Email), verifier.PurposeRegister)
 
 	return
 }
@@ -389,15 +386,8 @@ func SignUpWithPhone(input SignUpWithPhoneParams) (res schema.Response) {
 		return
 	}
 
-	phone, err := redis.ClientAuthPhoneCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
 	// 校验短信验证码是否一致
-	if phone != input.Phone {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Phone(input.Phone), verifier.PurposeRegister, input.Code); err != nil {
 		return
 	}
 
@@ -419,10 +409,16 @@ func SignUpWithPhone(input SignUpWithPhoneParams) (res schema.Response) {
 	username := "u" + util.GenerateId()
 	pwd := util.RandomString(6)
 
+	hashed, err := password.Generate(pwd)
+
+	if err != nil {
+		return
+	}
+
 	userInfo := model.User{
 		Username:                username,
 		Nickname:                &username,
-		Password:                util.GeneratePassword(pwd),
+		Password:                hashed,
 		Status:                  model.UserStatusInit,
 		Role:                    pq.StringArray{model.DefaultUser.Name},
 		Phone:                   &input.Phone,
@@ -0,0 +1,169 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"time"
+)
+
+type SignInWithTOTPParams struct {
+	Challenge    string `json:"challenge" validate:"required,max=64" comment:"挑战ID"`     // 登陆时返回的挑战ID
+	Code         string `json:"code" validate:"omitempty,numeric,len=6" comment:"动态密码"`  // 身份验证器上的动态密码
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=16" comment:"恢复码"` // 丢失身份验证器时可以使用恢复码
+}
+
+// 生成双重身份认证的挑战
+func newTOTPChallenge(uid string, duration time.Duration, loginType model.LoginLogType, account guard.Target) (*schema.TOTPChallenge, error) {
+	c, err := totp.NewChallenge(uid, false, duration, loginType, account)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.TOTPChallenge{
+		Challenge: c.Id,
+		ExpiredAt: c.ExpiredAt.Format(time.RFC3339Nano),
+	}, nil
+}
+
+// 登陆的第二步, 使用挑战 + 动态密码 (或者恢复码) 换取身份令牌
+func SignInWithTOTP(c helper.Context, input SignInWithTOTPParams) (res schema.Response) {
+	var (
+		err  error
+		data = &schema.ProfileWithToken{}
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	challenge, err := totp.GetChallenge(input.Challenge, false)
+
+	if err != nil {
+		return
+	}
+
+	// 第二步的失败次数同样计入第一步使用的帐号
+	g, err := checkSignIn(c, challenge.Account)
+
+	if err != nil {
+		return
+	}
+
+	userInfo := model.User{Id: challenge.Uid}
+
+	tx = database.Db.Begin()
+
+	if err = tx.Where(&userInfo).Preload("Wechat").Last(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	if err = userInfo.CheckStatusValid(); err != nil {
+		return
+	}
+
+	account := totp.Account{
+		Model:   &model.User{},
+		Id:      userInfo.Id,
+		Name:    userInfo.Username,
+		Secret:  userInfo.Secret,
+		Enabled: userInfo.EnableTOTP,
+	}
+
+	if err = challenge.Verify(tx, &account, input.Code, input.RecoveryCode); err != nil {
+		if err == exception.InvalidTOTPCode {
+			signInFail(g, c, challenge.Account, userInfo.Id, challenge.Type)
+		}
+		return
+	}
+
+	signInSuccess(g, c, challenge.Account)
+
+	if err = mapstructure.Decode(userInfo, &data.ProfilePure); err != nil {
+		return
+	}
+
+	if userInfo.WechatOpenID != nil {
+		if err = mapstructure.Decode(userInfo.Wechat, &data.Wechat); err != nil {
+			return
+		}
+	}
+
+	data.PayPassword = userInfo.PayPassword != nil && len(*userInfo.PayPassword) != 0
+	data.CreatedAt = userInfo.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = userInfo.UpdatedAt.Format(time.RFC3339Nano)
+
+	// generate token
+	if t, er := authentication.Gateway(false).Generate(userInfo.Id, challenge.Duration); er != nil {
+		err = er
+		return
+	} else {
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
+	}
+
+	// 写入登陆记录
+	loginLog := model.LoginLog{
+		Uid:     userInfo.Id,                       // 用户ID
+		Type:    challenge.Type,                    // 第一步使用的登陆方式
+		Command: model.LoginLogCommandLoginSuccess, // 登陆成功
+		Client:  c.UserAgent,                       // 用户的 userAgent
+		LastIp:  c.Ip,                              // 用户的IP
+	}
+
+	if err = tx.Create(&loginLog).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+var SignInWithTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input SignInWithTOTPParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return SignInWithTOTP(helper.NewContext(&c), input)
+	})
+})
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
@@ -10,7 +9,7 @@ import (
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/axetroy/go-server/internal/service/wechat"
 	"github.com/jinzhu/gorm"
 )
@@ -65,7 +64,7 @@ func UnbindingEmail(c helper.Context, input UnbindingEmailParams) (res schema.Re
 
 	tx = database.Db.Begin()
 
-	userInfo := model.User{}
+	userInfo := model.User{Id: c.Uid}
 
 	if err = tx.Where(&userInfo).Find(&userInfo).Error; err != nil {
 		return
@@ -78,15 +77,7 @@ func UnbindingEmail(c helper.Context, input UnbindingEmailParams) (res schema.Re
 	}
 
 	// 校验验证码正确不正确
-	email, err := redis.ClientAuthEmailCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
-	// 如果邮箱不匹配，则校验失败
-	if email != *userInfo.Email {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Email(*userInfo.Email), verifier.PurposeBind, input.Code); err != nil {
 		return
 	}
 
@@ -135,7 +126,7 @@ func UnbindingPhone(c helper.Context, input UnbindingPhoneParams) (res schema.Re
 
 	tx = database.Db.Begin()
 
-	userInfo := model.User{}
+	userInfo := model.User{Id: c.Uid}
 
 	if err = tx.Where(&userInfo).Find(&userInfo).Error; err != nil {
 		return
@@ -148,15 +139,7 @@ func UnbindingPhone(c helper.Context, input UnbindingPhoneParams) (res schema.Re
 	}
 
 	// 校验验证码正确不正确
-	phone, err := redis.ClientAuthPhoneCode.Get(context.Background(), input.Code).Result()
-
-	if err != nil {
-		return
-	}
-
-	// 如果邮箱不匹配，则校验失败
-	if phone != *userInfo.Phone {
-		err = exception.InvalidParams
+	if err = verifier.Verify(verifier.Phone(*userInfo.Phone), verifier.PurposeBind, input.Code); err != nil {
 		return
 	}
 
@@ -205,7 +188,7 @@ func UnbindingWechat(c helper.Context, input UnbindingWechatParams) (res schema.
 
 	tx = database.Db.Begin()
 
-	userInfo := model.User{}
+	userInfo := model.User{Id: c.Uid}
 
 	if err = tx.Where(&userInfo).Find(&userInfo).Error; err != nil {
 		return
@@ -214,38 +197,14 @@ func UnbindingWechat(c helper.Context, input UnbindingWechatParams) (res schema.
 	// 校验验证码是否正确
 	if userInfo.Phone != nil {
 		// 如果用户已有手机号，则用手机号作为验证码
-
-		// 校验验证码正确不正确
-		phone, err := redis.ClientAuthPhoneCode.Get(context.Background(), input.Code).Result()
-
-		if err != nil {
+		if err = verifier.Verify(verifier.Phone(*userInfo.Phone), verifier.PurposeBind, input.Code); err != nil {
 			return
 		}
-
-		// 如果不匹配，则校验失败
-		if phone != *userInfo.Phone {
-			err = exception.InvalidParams
-			if err != nil {
-				return
-			}
-		}
 	} else if userInfo.Email != nil {
 		// 	如果用户已有邮箱，则用邮箱作为验证码
-
-		// 校验验证码正确不正确
-		email, err := redis.ClientAuthEmailCode.Get(context.Background(), input.Code).Result()
-
-		if err != nil {
+		if err = verifier.Verify(verifier.Email(*userInfo.Email), verifier.PurposeBind, input.Code); err != nil {
 			return
 		}
-
-		// 如果邮箱不匹配，则校验失败
-		if email != *userInfo.Email {
-			err = exception.InvalidParams
-			if err != nil {
-				return
-			}
-		}
 	} else {
 		// 否则按照 `wx.login()` 返回的 code
 		weRes, err := wechat.FetchOpenID(input.Code)
@@ -0,0 +1,324 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/webauthn"
+	nativeWebAuthn "github.com/axetroy/go-server/pkg/webauthn"
+	"github.com/jinzhu/gorm"
+	"github.com/mitchellh/mapstructure"
+	"log"
+	"time"
+)
+
+type SignInWithWebAuthnParams struct {
+	Credential nativeWebAuthn.AssertionResponse `json:"credential" validate:"required" comment:"凭证"` // navigator.credentials.get() 返回的凭证
+	Duration   *int64                           `json:"duration" validate:"omitempty,number,gt=0" comment:"有效时间"`
+}
+
+type RegisterWebAuthnParams struct {
+	Name       string                             `json:"name" validate:"required,max=32" comment:"名称"` // 通行密钥的名称, 方便区分不同的设备
+	Credential nativeWebAuthn.AttestationResponse `json:"credential" validate:"required" comment:"凭证"`  // navigator.credentials.create() 返回的凭证
+}
+
+// 通行密钥登陆的第一步, 获取调用 navigator.credentials.get() 的参数
+// 不需要提供帐号, 由用户在认证器上选择通行密钥
+func SignInWithWebAuthnOptions(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data *nativeWebAuthn.RequestOptions
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	data, err = webauthn.BeginLogin(database.Db, webauthn.PurposeLogin, "", false)
+
+	return
+}
+
+// 通行密钥登陆的第二步, 校验认证器的签名之后生成身份令牌
+func SignInWithWebAuthn(c helper.Context, input SignInWithWebAuthnParams) (res schema.Response) {
+	var (
+		err       error
+		data      = &schema.ProfileWithToken{}
+		challenge *schema.TOTPChallenge
+		tx        *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		if challenge != nil {
+			helper.Response(&res, challenge, nil, err)
+		} else {
+			helper.Response(&res, data, nil, err)
+		}
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	g, err := guard.Load(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	// 检查 IP 是否被锁定, 并记录这次尝试
+	if err = g.Attempt(guard.Ip(c.Ip)); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	credential, userVerified, err := webauthn.FinishLogin(tx, webauthn.PurposeLogin, false, input.Credential)
+
+	if err != nil {
+		if err == exception.WebAuthnCredentialNotExist || err == exception.InvalidWebAuthnCredential {
+			if _, er := g.Fail(guard.Ip(c.Ip)); er != nil {
+				log.Println(er)
+			}
+		}
+		return
+	}
+
+	if er := g.Reset(guard.Ip(c.Ip)); er != nil {
+		log.Println(er)
+	}
+
+	userInfo := model.User{Id: credential.Uid}
+
+	if err = tx.Where(&userInfo).Preload("Wechat").Last(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	if err = userInfo.CheckStatusValid(); err != nil {
+		return
+	}
+
+	if err = mapstructure.Decode(userInfo, &data.ProfilePure); err != nil {
+		return
+	}
+
+	if userInfo.WechatOpenID != nil {
+		if err = mapstructure.Decode(userInfo.Wechat, &data.Wechat); err != nil {
+			return
+		}
+	}
+
+	data.PayPassword = userInfo.PayPassword != nil && len(*userInfo.PayPassword) != 0
+	data.CreatedAt = userInfo.CreatedAt.Format(time.RFC3339Nano)
+	data.UpdatedAt = userInfo.UpdatedAt.Format(time.RFC3339Nano)
+
+	var duration time.Duration
+
+	if input.Duration != nil {
+		duration = time.Duration(*input.Duration * int64(time.Second))
+	} else {
+		duration = time.Hour * 6
+	}
+
+	// 经过用户验证 (PIN/生物识别) 的通行密钥本身就是两个因素, 否则仍然需要动态密码
+	if userInfo.EnableTOTP && !userVerified {
+		challenge, err = newTOTPChallenge(userInfo.Id, duration, model.LoginLogTypeWebAuthn, guard.Account(guard.ScopeUser, userInfo.Username))
+		return
+	}
+
+	// generate token
+	if t, er := authentication.Gateway(false).Generate(userInfo.Id, duration); er != nil {
+		err = er
+		return
+	} else {
+		data.Token = t.Token
+		data.RefreshToken = t.RefreshToken
+	}
+
+	// 写入登陆记录
+	loginLog := model.LoginLog{
+		Uid:     userInfo.Id,                       // 用户ID
+		Type:    model.LoginLogTypeWebAuthn,        // 通行密钥登陆
+		Command: model.LoginLogCommandLoginSuccess, // 登陆成功
+		Client:  c.UserAgent,                       // 用户的 userAgent
+		LastIp:  c.Ip,                              // 用户的IP
+	}
+
+	if err = tx.Create(&loginLog).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+// 注册通行密钥的第一步, 获取调用 navigator.credentials.create() 的参数
+func RegisterWebAuthnOptions(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data *nativeWebAuthn.CreationOptions
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	userInfo := model.User{Id: c.Uid}
+
+	if err = database.Db.Where(&userInfo).Last(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
+		return
+	}
+
+	displayName := userInfo.Username
+
+	if userInfo.Nickname != nil && *userInfo.Nickname != "" {
+		displayName = *userInfo.Nickname
+	}
+
+	data, err = webauthn.BeginRegistration(database.Db, userInfo.Id, false, userInfo.Username, displayName)
+
+	return
+}
+
+// 注册通行密钥的第二步, 校验通过之后保存
+func RegisterWebAuthn(c helper.Context, input RegisterWebAuthnParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	credential, err := webauthn.FinishRegistration(tx, c.Uid, false, input.Name, input.Credential)
+
+	if err != nil {
+		return
+	}
+
+	data = schema.WebAuthnCredential{
+		Id:         credential.Id,
+		Name:       credential.Name,
+		AAGUID:     credential.AAGUID,
+		Transports: credential.Transports,
+		CreatedAt:  credential.CreatedAt.Format(time.RFC3339Nano),
+		UpdatedAt:  credential.UpdatedAt.Format(time.RFC3339Nano),
+	}
+
+	return
+}
+
+var SignInWithWebAuthnOptionsRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return SignInWithWebAuthnOptions(helper.NewContext(&c))
+	})
+})
+
+var SignInWithWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input SignInWithWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return SignInWithWebAuthn(helper.NewContext(&c), input)
+	})
+})
+
+var RegisterWebAuthnOptionsRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return RegisterWebAuthnOptions(helper.NewContext(&c))
+	})
+})
+
+var RegisterWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input RegisterWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RegisterWebAuthn(helper.NewContext(&c), input)
+	})
+})
@@ -0,0 +1,169 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package auth_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/user"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/internal/service/webauthn"
+	nativeWebAuthn "github.com/axetroy/go-server/pkg/webauthn"
+	"github.com/axetroy/go-server/pkg/webauthn/webauthntest"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSignInWithWebAuthn(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	var (
+		authenticator = webauthntest.NewAuthenticator()
+		origin        = webauthn.RelyingParty().Origins[0]
+		credential    = schema.WebAuthnCredential{}
+	)
+
+	// 注册通行密钥
+	{
+		r := auth.RegisterWebAuthnOptions(helper.Context{Uid: userInfo.Id})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Equal(t, "", r.Message)
+
+		options := nativeWebAuthn.CreationOptions{}
+
+		assert.Nil(t, r.Decode(&options))
+		assert.Equal(t, userInfo.Username, options.User.Name)
+		assert.Equal(t, []byte(userInfo.Id), []byte(options.User.Id))
+
+		res, err := authenticator.Create(origin, options)
+
+		assert.Nil(t, err)
+
+		r = auth.RegisterWebAuthn(helper.Context{Uid: userInfo.Id}, auth.RegisterWebAuthnParams{
+			Name:       "my key",
+			Credential: *res,
+		})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&credential))
+		assert.Equal(t, "my key", credential.Name)
+
+		// 同一个挑战不能使用两次
+		r = auth.RegisterWebAuthn(helper.Context{Uid: userInfo.Id}, auth.RegisterWebAuthnParams{
+			Name:       "my key",
+			Credential: *res,
+		})
+
+		assert.Equal(t, exception.WebAuthnChallengeExpired.Code(), r.Status)
+		assert.Equal(t, exception.WebAuthnChallengeExpired.Error(), r.Message)
+	}
+
+	defer user.DeleteWebAuthn(helper.Context{Uid: userInfo.Id}, credential.Id)
+
+	// 获取已注册的通行密钥
+	{
+		r := user.GetWebAuthnList(helper.Context{Uid: userInfo.Id})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+
+		list := make([]schema.WebAuthnCredential, 0)
+
+		assert.Nil(t, r.Decode(&list))
+		assert.Len(t, list, 1)
+		assert.Equal(t, credential.Id, list[0].Id)
+		assert.Nil(t, list[0].LastUsedAt)
+	}
+
+	// 使用通行密钥登陆
+	{
+		r := auth.SignInWithWebAuthnOptions(helper.Context{})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+
+		options := nativeWebAuthn.RequestOptions{}
+
+		assert.Nil(t, r.Decode(&options))
+		assert.Len(t, options.AllowCredentials, 0)
+
+		res, err := authenticator.Get(origin, options)
+
+		assert.Nil(t, err)
+
+		r = auth.SignInWithWebAuthn(helper.Context{UserAgent: "test", Ip: "127.0.0.1"}, auth.SignInWithWebAuthnParams{
+			Credential: *res,
+		})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Equal(t, "", r.Message)
+
+		profile := schema.ProfileWithToken{}
+
+		assert.Nil(t, r.Decode(&profile))
+		assert.Equal(t, userInfo.Id, profile.Id)
+
+		if c, er := token.Parse(token.Prefix+" "+profile.Token, token.StateUser); er != nil {
+			t.Error(er)
+		} else {
+			assert.Equal(t, userInfo.Id, c.Uid)
+		}
+
+		// 重放同一个签名
+		r = auth.SignInWithWebAuthn(helper.Context{UserAgent: "test", Ip: "127.0.0.1"}, auth.SignInWithWebAuthnParams{
+			Credential: *res,
+		})
+
+		assert.Equal(t, exception.WebAuthnChallengeExpired.Code(), r.Status)
+		assert.Equal(t, exception.WebAuthnChallengeExpired.Error(), r.Message)
+	}
+
+	// 重命名
+	{
+		r := user.RenameWebAuthn(helper.Context{Uid: userInfo.Id}, credential.Id, user.RenameWebAuthnParams{Name: "new name"})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+
+		info := schema.WebAuthnCredential{}
+
+		assert.Nil(t, r.Decode(&info))
+		assert.Equal(t, "new name", info.Name)
+		assert.NotNil(t, info.LastUsedAt)
+	}
+
+	// 其他用户不能删除
+	{
+		r := user.DeleteWebAuthn(helper.Context{Uid: "123"}, credential.Id)
+
+		assert.Equal(t, exception.WebAuthnCredentialNotExist.Code(), r.Status)
+		assert.Equal(t, exception.WebAuthnCredentialNotExist.Error(), r.Message)
+	}
+
+	// 删除之后不能再登陆
+	{
+		r := user.DeleteWebAuthn(helper.Context{Uid: userInfo.Id}, credential.Id)
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+
+		r = auth.SignInWithWebAuthnOptions(helper.Context{})
+
+		options := nativeWebAuthn.RequestOptions{}
+
+		assert.Nil(t, r.Decode(&options))
+
+		res, err := authenticator.Get(origin, options)
+
+		assert.Nil(t, err)
+
+		r = auth.SignInWithWebAuthn(helper.Context{UserAgent: "test", Ip: "127.0.0.1"}, auth.SignInWithWebAuthnParams{
+			Credential: *res,
+		})
+
+		assert.Equal(t, exception.WebAuthnCredentialNotExist.Code(), r.Status)
+		assert.Equal(t, exception.WebAuthnCredentialNotExist.Error(), r.Message)
+	}
+}
@@ -2,19 +2,15 @@
 package email
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/captcha"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/email"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/jinzhu/gorm"
-	"time"
 )
 
 type SendResetPasswordEmailParams struct {
@@ -63,26 +59,8 @@ func SendResetPasswordEmail(input SendResetPasswordEmailParams) (res schema.Resp
 		return
 	}
 
-	// 生成重置码
-	var code = captcha.GenerateResetCode(userInfo.Id)
-
-	// set activationCode to redis
-	if err = redis.ClientResetCode.Set(context.Background(), code, userInfo.Id, time.Minute*30).Err(); err != nil {
-		return
-	}
-
-	e, err := email.NewMailer()
-
-	if err != nil {
-		return
-	}
-
-	// send email
-	if err = e.SendForgotPasswordEmail(input.Email, code); err != nil {
-		// 邮件没发出去的话，删除redis的key
-		_ = redis.ClientResetCode.Del(context.Background(), code).Err()
-		return
-	}
+	// 发送重置码
+	err = verifier.Send(verifier.Email(input.Email), verifier.PurposeResetPassword)
 
 	return
 
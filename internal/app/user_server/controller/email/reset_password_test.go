@@ -6,24 +6,12 @@ import (
 	"github.com/axetroy/go-server/internal/app/user_server/controller/email"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/captcha"
 	"github.com/axetroy/go-server/tester"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
 )
 
-func TestGenerateResetCode(t *testing.T) {
-	user, _ := tester.CreateUser()
-
-	defer tester.DeleteUserByUserName(user.Username)
-
-	code := captcha.GenerateResetCode(user.Id)
-
-	assert.IsType(t, "", code)
-	assert.NotEmpty(t, code)
-}
-
 func TestSendResetPasswordEmail(t *testing.T) {
 
 	body, _ := json.Marshal(&email.SendResetPasswordEmailParams{
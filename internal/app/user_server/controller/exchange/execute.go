@@ -0,0 +1,166 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/logger"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/ledger"
+	"github.com/jinzhu/gorm"
+)
+
+type ExecuteParams struct {
+	QuoteId string `json:"quote_id" validate:"required" comment:"报价ID"` // 报价ID
+}
+
+// 按照报价进行兑换, 扣除兑出币种的余额并增加兑入币种的余额
+func Execute(c helper.Context, input ExecuteParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.Exchange
+		tx   *gorm.DB
+		q    *quote
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				logger.Infof("User %s exchange %v", c.Uid, input)
+				err = tx.Commit().Error
+			}
+		}
+
+		// 没有成交, 用户仍然可以使用这个报价
+		if err != nil && q != nil {
+			_ = restoreQuote(q)
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	if q, err = takeQuote(c.Uid, input.QuoteId); err != nil {
+		return
+	}
+
+	exchangeInfo := model.Exchange{
+		Uid:      c.Uid,
+		From:     q.From,
+		To:       q.To,
+		Amount:   decimal.RequireFromString(q.Amount),
+		Rate:     decimal.RequireFromString(q.Rate),
+		FeeRate:  decimal.RequireFromString(q.FeeRate),
+		Fee:      decimal.RequireFromString(q.Fee),
+		Received: decimal.RequireFromString(q.Received),
+	}
+
+	tx = database.Db.Begin()
+
+	// 报价之后币种可能被停用
+	if _, err = currency.GetEnabled(tx, q.From); err != nil {
+		return
+	}
+
+	if _, err = currency.GetEnabled(tx, q.To); err != nil {
+		return
+	}
+
+	// 报价之后汇率可能被停用, 停用之后报价也不能成交, 成交时仍然使用报价中锁定的汇率
+	if _, err = getRate(tx, q.From, q.To); err != nil {
+		return
+	}
+
+	// 两个钱包在不同的表, 按照币种代码的顺序加锁, 避免死锁
+	first, second := q.From, q.To
+
+	if first > second {
+		first, second = second, first
+	}
+
+	wallets := map[string]*model.Wallet{}
+
+	for _, code := range []string{first, second} {
+		locked, er := ledger.Lock(tx, code, c.Uid)
+
+		if er != nil {
+			err = er
+			return
+		}
+
+		wallets[code] = locked[c.Uid]
+	}
+
+	fromLog, err := ledger.Mutate(tx, wallets[q.From], exchangeInfo.Amount.Neg(), decimal.Zero)
+
+	if err != nil {
+		return
+	}
+
+	toLog, err := ledger.Mutate(tx, wallets[q.To], exchangeInfo.Received, decimal.Zero)
+
+	if err != nil {
+		return
+	}
+
+	if err = tx.Create(&exchangeInfo).Error; err != nil {
+		return
+	}
+
+	fromLog.Type = model.FinanceTypeExchangeOut
+	toLog.Type = model.FinanceTypeExchangeIn
+
+	if err = ledger.WriteLogs(tx, q.From, exchangeInfo.Id, fromLog); err != nil {
+		return
+	}
+
+	if err = ledger.WriteLogs(tx, q.To, exchangeInfo.Id, toLog); err != nil {
+		return
+	}
+
+	// 关联两条财务流水
+	if err = tx.Model(&exchangeInfo).Updates(map[string]interface{}{
+		"from_log_id": fromLog.Id,
+		"to_log_id":   toLog.Id,
+	}).Error; err != nil {
+		return
+	}
+
+	mapToSchema(exchangeInfo, &data)
+
+	return
+}
+
+var ExecuteRouter = router.Handler(func(c router.Context) {
+	var (
+		input ExecuteParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Execute(helper.NewContext(&c), input)
+	})
+})
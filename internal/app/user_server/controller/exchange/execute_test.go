@@ -0,0 +1,122 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange_test
+
+import (
+	"github.com/axetroy/go-server/internal/app/user_server/controller/exchange"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExecute(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	rate := model.ExchangeRate{
+		From:    model.WalletUSD,
+		To:      model.WalletCNY,
+		Rate:    decimal.RequireFromString("7"),
+		FeeRate: decimal.RequireFromString("0.01"),
+		Enabled: true,
+	}
+
+	assert.Nil(t, database.Db.Where("\"from\" = ? AND \"to\" = ?", rate.From, rate.To).Delete(model.ExchangeRate{}).Error)
+	assert.Nil(t, database.Db.Create(&rate).Error)
+
+	defer database.Db.Delete(&rate)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(model.WalletTableName(model.WalletUSD)).Where("id = ?", userInfo.Id).Update(model.Wallet{
+		Balance: decimal.NewFromInt(100),
+	}).Error)
+
+	// 不能兑换相同的币种
+	{
+		res := exchange.Quote(helper.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+			From:   model.WalletUSD,
+			To:     model.WalletUSD,
+			Amount: "10",
+		})
+
+		assert.Equal(t, exception.ExchangeSameCurrency.Error(), res.Message)
+	}
+
+	res := exchange.Quote(helper.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+		From:   model.WalletUSD,
+		To:     model.WalletCNY,
+		Amount: "10",
+	})
+
+	q := schema.ExchangeQuote{}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Nil(t, res.Decode(&q))
+	assert.Equal(t, "69.30000000", q.Received)
+
+	// 其他用户不能使用这个报价
+	{
+		res := exchange.Execute(helper.Context{Uid: "123123"}, exchange.ExecuteParams{QuoteId: q.Id})
+
+		assert.Equal(t, exception.ExchangeQuoteExpired.Error(), res.Message)
+	}
+
+	// 报价锁定之后修改汇率, 不影响成交
+	assert.Nil(t, database.Db.Model(&rate).Update("rate", decimal.NewFromInt(8)).Error)
+
+	res = exchange.Execute(helper.Context{Uid: userInfo.Id}, exchange.ExecuteParams{QuoteId: q.Id})
+
+	data := schema.Exchange{}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Nil(t, res.Decode(&data))
+	assert.Equal(t, "69.30000000", data.Received)
+	assert.NotEmpty(t, data.FromLogId)
+	assert.NotEmpty(t, data.ToLogId)
+
+	usd := model.Wallet{}
+	cny := model.Wallet{}
+
+	assert.Nil(t, database.Db.Table(model.WalletTableName(model.WalletUSD)).Where("id = ?", userInfo.Id).First(&usd).Error)
+	assert.Nil(t, database.Db.Table(model.WalletTableName(model.WalletCNY)).Where("id = ?", userInfo.Id).First(&cny).Error)
+
+	assert.Equal(t, "90.00000000", usd.Balance.String())
+	assert.Equal(t, "69.30000000", cny.Balance.String())
+
+	// 报价只能使用一次
+	{
+		res := exchange.Execute(helper.Context{Uid: userInfo.Id}, exchange.ExecuteParams{QuoteId: q.Id})
+
+		assert.Equal(t, exception.ExchangeQuoteExpired.Error(), res.Message)
+	}
+
+	// 兑换失败之后, 报价仍然可以使用
+	{
+		res := exchange.Quote(helper.Context{Uid: userInfo.Id}, exchange.QuoteParams{
+			From:   model.WalletUSD,
+			To:     model.WalletCNY,
+			Amount: "1000",
+		})
+
+		q := schema.ExchangeQuote{}
+
+		assert.Equal(t, "", res.Message)
+		assert.Nil(t, res.Decode(&q))
+
+		res = exchange.Execute(helper.Context{Uid: userInfo.Id}, exchange.ExecuteParams{QuoteId: q.Id})
+
+		assert.Equal(t, exception.NotEnoughBalance.Error(), res.Message)
+
+		res = exchange.Execute(helper.Context{Uid: userInfo.Id}, exchange.ExecuteParams{QuoteId: q.Id})
+
+		assert.Equal(t, exception.NotEnoughBalance.Error(), res.Message)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+)
+
+type Query struct {
+	schema.Query
+}
+
+// 获取可用的汇率列表
+func GetRates() (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.ExchangeRate, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list := make([]model.ExchangeRate, 0)
+
+	if err = database.Db.Where("enabled = ?", true).Order("\"from\"").Order("\"to\"").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.ExchangeRate{}
+		mapRateToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	return
+}
+
+// 获取我的兑换记录
+func GetHistory(c helper.Context, query Query) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Exchange, 0)
+		meta = &schema.Meta{}
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, meta, err)
+	}()
+
+	query.Normalize()
+
+	if err = query.Validate(); err != nil {
+		return
+	}
+
+	list := make([]model.Exchange, 0)
+
+	filter := model.Exchange{Uid: c.Uid}
+
+	var total int64
+
+	if err = query.Order(database.Db.Limit(query.Limit).Offset(query.Limit * query.Page)).Where(&filter).Find(&list).Error; err != nil {
+		return
+	}
+
+	if err = database.Db.Model(model.Exchange{}).Where(&filter).Count(&total).Error; err != nil {
+		return
+	}
+
+	for _, v := range list {
+		d := schema.Exchange{}
+		mapToSchema(v, &d)
+		data = append(data, d)
+	}
+
+	meta.Total = total
+	meta.Num = len(list)
+	meta.Page = query.Page
+	meta.Limit = query.Limit
+	meta.Sort = query.Sort
+
+	return
+}
+
+var GetRatesRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetRates()
+	})
+})
+
+var GetHistoryRouter = router.Handler(func(c router.Context) {
+	var (
+		query Query
+	)
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetHistory(helper.NewContext(&c), query)
+	})
+})
@@ -0,0 +1,197 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"time"
+)
+
+type QuoteParams struct {
+	From   string `json:"from" validate:"required" comment:"兑出的币种"`                // 兑出的币种
+	To     string `json:"to" validate:"required" comment:"兑入的币种"`                  // 兑入的币种
+	Amount string `json:"amount" validate:"required,numeric,gt=0" comment:"兑出的数量"` // 兑出的数量
+}
+
+// 存放在 redis 中的报价
+type quote struct {
+	schema.ExchangeQuote
+	Uid string `json:"uid"` // 报价属于哪个用户
+}
+
+// 获取兑换报价, 报价中的汇率在有效期内锁定
+func Quote(c helper.Context, input QuoteParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.ExchangeQuote
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	var amount decimal.Decimal
+
+	if amount, err = decimal.NewFromString(input.Amount); err != nil || !amount.IsPositive() {
+		err = exception.InvalidParams
+		return
+	}
+
+	fromCurrency, err := currency.GetEnabled(database.Db, input.From)
+
+	if err != nil {
+		return
+	}
+
+	toCurrency, err := currency.GetEnabled(database.Db, input.To)
+
+	if err != nil {
+		return
+	}
+
+	if fromCurrency.Code == toCurrency.Code {
+		err = exception.ExchangeSameCurrency
+		return
+	}
+
+	if !fromCurrency.ValidAmount(amount) {
+		err = exception.InvalidParams
+		return
+	}
+
+	rate, err := getRate(database.Db, fromCurrency.Code, toCurrency.Code)
+
+	if err != nil {
+		return
+	}
+
+	fee, received := rate.Quote(amount, toCurrency.Precision)
+
+	if !received.IsPositive() {
+		err = exception.ExchangeAmountTooSmall
+		return
+	}
+
+	q := quote{
+		ExchangeQuote: schema.ExchangeQuote{
+			Id:        util.GenerateId(),
+			From:      fromCurrency.Code,
+			To:        toCurrency.Code,
+			Amount:    amount.String(),
+			Rate:      rate.Rate.String(),
+			FeeRate:   rate.FeeRate.String(),
+			Fee:       fee.String(),
+			Received:  received.String(),
+			ExpiredAt: time.Now().Add(config.Exchange.QuoteTTL).Format(time.RFC3339Nano),
+		},
+		Uid: c.Uid,
+	}
+
+	b, err := json.Marshal(q)
+
+	if err != nil {
+		return
+	}
+
+	if err = redis.ClientExchangeQuote.Set(context.Background(), q.Id, string(b), config.Exchange.QuoteTTL).Err(); err != nil {
+		return
+	}
+
+	data = q.ExchangeQuote
+
+	return
+}
+
+// 取出报价, 每个报价只能使用一次
+func takeQuote(uid string, id string) (*quote, error) {
+	value, err := redis.ClientExchangeQuote.Get(context.Background(), id).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return nil, exception.ExchangeQuoteExpired
+		}
+		return nil, err
+	}
+
+	q := quote{}
+
+	if err := json.Unmarshal([]byte(value), &q); err != nil {
+		return nil, err
+	}
+
+	// 不能使用别人的报价
+	if q.Uid != uid {
+		return nil, exception.ExchangeQuoteExpired
+	}
+
+	// 删除成功的请求才能使用这个报价, 避免并发的请求重复兑换. 兑换失败时使用 restoreQuote 放回
+	if n, err := redis.ClientExchangeQuote.Del(context.Background(), id).Result(); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, exception.ExchangeQuoteExpired
+	}
+
+	return &q, nil
+}
+
+// 兑换失败时放回报价, 在有效期内仍然可以使用
+func restoreQuote(q *quote) error {
+	expiredAt, err := time.Parse(time.RFC3339Nano, q.ExpiredAt)
+
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(expiredAt)
+
+	// 已经过期了
+	if ttl <= 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(q)
+
+	if err != nil {
+		return err
+	}
+
+	return redis.ClientExchangeQuote.SetNX(context.Background(), q.Id, string(b), ttl).Err()
+}
+
+var QuoteRouter = router.Handler(func(c router.Context) {
+	var (
+		input QuoteParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Quote(helper.NewContext(&c), input)
+	})
+})
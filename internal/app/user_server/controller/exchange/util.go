@@ -0,0 +1,52 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package exchange
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// 获取已启用的汇率
+func getRate(db *gorm.DB, from string, to string) (*model.ExchangeRate, error) {
+	rate := model.ExchangeRate{}
+
+	if err := db.Where("\"from\" = ? AND \"to\" = ? AND enabled = ?", strings.ToUpper(from), strings.ToUpper(to), true).First(&rate).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.ExchangeRateNotExist
+		}
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func mapRateToSchema(r model.ExchangeRate, d *schema.ExchangeRate) {
+	d.Id = r.Id
+	d.From = r.From
+	d.To = r.To
+	d.Rate = r.Rate.String()
+	d.FeeRate = r.FeeRate.String()
+	d.Enabled = r.Enabled
+	d.CreatedAt = r.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = r.UpdatedAt.Format(time.RFC3339Nano)
+}
+
+func mapToSchema(e model.Exchange, d *schema.Exchange) {
+	d.Id = e.Id
+	d.Uid = e.Uid
+	d.From = e.From
+	d.To = e.To
+	d.Amount = e.Amount.String()
+	d.Rate = e.Rate.String()
+	d.FeeRate = e.FeeRate.String()
+	d.Fee = e.Fee.String()
+	d.Received = e.Received.String()
+	d.FromLogId = e.FromLogId
+	d.ToLogId = e.ToLogId
+	d.CreatedAt = e.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = e.UpdatedAt.Format(time.RFC3339Nano)
+}
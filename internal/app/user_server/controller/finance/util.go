@@ -1,8 +1,8 @@
 // Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
 package finance
 
-import "strings"
+import "github.com/axetroy/go-server/internal/model"
 
 func GetTableName(currency string) string {
-	return "finance_log_" + strings.ToLower(currency)
+	return model.FinanceLogTableName(currency)
 }
@@ -2,9 +2,9 @@
 package oauth2
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
+	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/library/util"
@@ -12,16 +12,62 @@ import (
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/axetroy/go-server/internal/service/dotenv"
-	"github.com/axetroy/go-server/internal/service/redis"
-	"github.com/gorilla/mux"
+	"github.com/axetroy/go-server/internal/service/oauth"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 	"github.com/markbates/goth"
-	"github.com/markbates/goth/gothic"
 	"net/http"
 	"net/url"
-	"time"
+	"strings"
 )
 
+// 存放认证会话 ID 的 cookie
+const sessionCookie = "oauth_session"
+
+func responseError(c *router.Context, err error) {
+	c.StatusCode(http.StatusBadRequest)
+	c.Response(nil, schema.Response{Message: err.Error()})
+}
+
+// 根据第三方帐号的信息创建一个本平台的帐号
+func createUser(tx *gorm.DB, user *goth.User) (*model.User, error) {
+	userName := user.Name
+
+	if userName == "" {
+		userName = user.NickName
+	}
+
+	if userName == "" {
+		userName = user.FirstName + user.LastName
+	}
+
+	if userName == "" {
+		userName = user.Provider + util.GenerateId()
+	}
+
+	hashed, err := password.Generate(util.GenerateId())
+
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := model.User{
+		Username:                userName,
+		Nickname:                &user.NickName,
+		Password:                hashed,
+		Email:                   nil,
+		Phone:                   nil,
+		Status:                  model.UserStatusInit,
+		UsernameRenameRemaining: 1,
+	}
+
+	if err := auth.CreateUserTx(tx, &userInfo, nil); err != nil {
+		return nil, err
+	}
+
+	return &userInfo, nil
+}
+
 func redirectToClient(c *router.Context, user *goth.User) {
 	var (
 		err      error
@@ -52,8 +98,7 @@ func redirectToClient(c *router.Context, user *goth.User) {
 		}
 
 		if err != nil {
-			c.StatusCode(http.StatusBadRequest)
-			c.Response(nil, schema.Response{Message: err.Error()})
+			responseError(c, err)
 		} else {
 			c.Redirect(http.StatusTemporaryRedirect, finalURL)
 		}
@@ -62,147 +107,124 @@ func redirectToClient(c *router.Context, user *goth.User) {
 	uri, err := url.Parse(frontendURL)
 
 	if err != nil {
-		c.StatusCode(http.StatusBadRequest)
-		c.Response(nil, schema.Response{Message: "Invalid callback url"})
+		err = errors.New("Invalid callback url")
 		return
 	}
 
 	tx = database.Db.Begin()
 
-	oAuthInfo := model.OAuth{UserID: user.UserID}
-	userInfo := model.User{}
+	// 同一个服务提供商的帐号只会绑定一个本平台的帐号
+	oAuthInfo := model.OAuth{Provider: model.OAuthProvider(user.Provider), UserID: user.UserID}
+	userInfo := &model.User{}
 
-	err = tx.Where(&oAuthInfo).First(&oAuthInfo).Error
+	if err = tx.Where(&oAuthInfo).First(&oAuthInfo).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
 
-	if err != nil {
 		// 如果没找到对应的记录，说明这个帐号还没有绑定，我们给他创建一个本平台的帐号
-		if err == gorm.ErrRecordNotFound {
-			userName := user.Name
-
-			if userName == "" {
-				userName = user.NickName
-			}
-
-			if userName == "" {
-				userName = user.FirstName + user.LastName
-			}
-
-			if userName == "" {
-				userName = user.Provider + util.GenerateId()
-			}
-
-			userInfo = model.User{
-				Username:                userName,
-				Nickname:                &user.NickName,
-				Password:                util.GeneratePassword(util.GenerateId()),
-				Email:                   nil,
-				Phone:                   nil,
-				Status:                  model.UserStatusInit,
-				UsernameRenameRemaining: 1,
-			}
-
-			// 创建一个用户
-			if err = auth.CreateUserTx(tx, &userInfo, nil); err != nil {
-				return
-			}
-
-			oAuthInfo.Uid = userInfo.Id
-			oAuthInfo.Provider = model.OAuthProvider(user.Provider)
-			oAuthInfo.Name = user.Name
-			oAuthInfo.Nickname = user.NickName
-			oAuthInfo.FirstName = user.FirstName
-			oAuthInfo.LastName = user.LastName
-			oAuthInfo.Description = user.Description
-			oAuthInfo.Email = user.Email
-			oAuthInfo.AvatarURL = user.AvatarURL
-			oAuthInfo.Location = user.Location
-			oAuthInfo.AccessToken = user.AccessToken
-			oAuthInfo.AccessTokenSecret = user.AccessTokenSecret
-			oAuthInfo.RefreshToken = user.RefreshToken
-			oAuthInfo.ExpiresAt = user.ExpiresAt
-
-			if err = tx.Create(&oAuthInfo).Error; err != nil {
-				return
-			}
-
-		} else {
+		if userInfo, err = createUser(tx, user); err != nil {
 			return
 		}
-	}
 
-	// 如果已经绑定帐号，则去查找帐号的相关信息
-	if oAuthInfo.Uid != "" && userInfo.Id == "" {
-		if err = tx.Where(&userInfo).First(&userInfo).Error; err != nil {
+		oAuthInfo.Uid = userInfo.Id
+		oAuthInfo.Name = user.Name
+		oAuthInfo.Nickname = user.NickName
+		oAuthInfo.FirstName = user.FirstName
+		oAuthInfo.LastName = user.LastName
+		oAuthInfo.Description = user.Description
+		oAuthInfo.Email = user.Email
+		oAuthInfo.AvatarURL = user.AvatarURL
+		oAuthInfo.Location = user.Location
+		oAuthInfo.AccessToken = user.AccessToken
+		oAuthInfo.AccessTokenSecret = user.AccessTokenSecret
+		oAuthInfo.RefreshToken = user.RefreshToken
+		oAuthInfo.ExpiresAt = user.ExpiresAt
+
+		if err = tx.Create(&oAuthInfo).Error; err != nil {
 			return
 		}
 	} else {
-		// 如果有这条 oAuth 记录，但是没有这条绑定，这创建这个帐号
-		userName := user.Name
-
-		if userName == "" {
-			userName = user.NickName
-		}
-
-		if userName == "" {
-			userName = user.FirstName + user.LastName
-		}
+		// 如果已经绑定帐号，则去查找帐号的相关信息
+		userInfo.Id = oAuthInfo.Uid
 
-		if userName == "" {
-			userName = user.Provider + util.GenerateId()
-		}
-
-		userInfo = model.User{
-			Username:                userName,
-			Nickname:                &user.NickName,
-			Password:                util.GeneratePassword(util.GenerateId()),
-			Email:                   nil,
-			Phone:                   nil,
-			Status:                  model.UserStatusInit,
-			UsernameRenameRemaining: 1,
-		}
-
-		// 创建一个用户
-		if err = auth.CreateUserTx(tx, &userInfo, nil); err != nil {
+		if err = tx.Where(userInfo).First(userInfo).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				err = exception.UserNotExist
+			}
 			return
 		}
 	}
 
-	hash := util.MD5(user.UserID)
+	code, err := oauth.NewSignInCode(userInfo.Id)
 
-	if err := redis.ClientOAuthCode.Set(context.Background(), hash, userInfo.Id, time.Minute*5).Err(); err != nil {
-		c.StatusCode(http.StatusBadRequest)
-		c.Response(nil, schema.Response{
-			Message: "Invalid callback url",
-		})
+	if err != nil {
 		return
 	}
 
-	uri.Query().Set("access_token", hash)
+	query := uri.Query()
+	query.Set("access_token", code)
+	uri.RawQuery = query.Encode()
 
 	finalURL = uri.String()
 }
 
+// 前去服务提供商进行认证
 var AuthRouter = router.Handler(func(c router.Context) {
-	provider := c.Param("provider")
+	provider, err := oauth.Get(c.Param("provider"))
 
-	c.ResetRequest(mux.SetURLVars(c.Request(), map[string]string{"provider": provider}))
-	// try to get the user without re-authenticating
-	if gothUser, err := gothic.CompleteUserAuth(c.Writer(), c.Request()); err == nil {
-		// 认证成功
-		redirectToClient(&c, &gothUser)
-	} else {
-		gothic.BeginAuthHandler(c.Writer(), c.Request())
+	if err != nil {
+		responseError(&c, err)
+		return
+	}
+
+	id, authURL, err := oauth.Begin(provider)
+
+	if err != nil {
+		responseError(&c, err)
+		return
 	}
+
+	http.SetCookie(c.Writer(), &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/v1/oauth2",
+		MaxAge:   int(oauth.SessionTTL.Seconds()),
+		Secure:   strings.HasPrefix(config.Common.Domain, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
 })
 
+// 服务提供商认证成功之后跳转回来
 var AuthCallbackRouter = router.Handler(func(c router.Context) {
-	provider := c.Param("provider")
-	c.ResetRequest(mux.SetURLVars(c.Request(), map[string]string{"provider": provider}))
-	gothUser, err := gothic.CompleteUserAuth(c.Writer(), c.Request())
+	provider, err := oauth.Get(c.Param("provider"))
+
+	if err != nil {
+		responseError(&c, err)
+		return
+	}
+
+	id := ""
+
+	if cookie, err := c.Request().Cookie(sessionCookie); err == nil {
+		id = cookie.Value
+	}
+
+	http.SetCookie(c.Writer(), &http.Cookie{
+		Name:   sessionCookie,
+		Path:   "/v1/oauth2",
+		MaxAge: -1,
+	})
+
+	user, err := oauth.Complete(provider, id, c.Request().URL.Query())
 
 	if err != nil {
+		responseError(&c, err)
 		return
 	}
 
-	redirectToClient(&c, &gothUser)
+	redirectToClient(&c, &user)
 })
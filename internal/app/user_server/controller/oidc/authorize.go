@@ -0,0 +1,255 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+	"net/url"
+)
+
+// 应用跳转到授权页面时携带的参数, 授权页面原样传给接口
+type AuthorizeQuery struct {
+	ResponseType        string `json:"response_type" url:"response_type" validate:"omitempty" comment:"响应类型"`
+	ClientId            string `json:"client_id" url:"client_id" validate:"required,max=32" comment:"应用ID"`
+	RedirectUri         string `json:"redirect_uri" url:"redirect_uri" validate:"required,url,max=255" comment:"回调地址"`
+	Scope               string `json:"scope" url:"scope" validate:"omitempty" comment:"授权范围"`
+	State               string `json:"state" url:"state" validate:"omitempty" comment:"状态"`
+	Nonce               string `json:"nonce" url:"nonce" validate:"omitempty,max=255" comment:"随机数"`
+	CodeChallenge       string `json:"code_challenge" url:"code_challenge" validate:"omitempty" comment:"PKCE"`
+	CodeChallengeMethod string `json:"code_challenge_method" url:"code_challenge_method" validate:"omitempty" comment:"PKCE"`
+}
+
+type AuthorizeParams struct {
+	AuthorizeQuery
+	Approve bool `json:"approve"` // 用户是否同意授权
+}
+
+func (q AuthorizeQuery) request() oidc.AuthorizeRequest {
+	return oidc.AuthorizeRequest{
+		ResponseType:        q.ResponseType,
+		ClientId:            q.ClientId,
+		RedirectUri:         q.RedirectUri,
+		Scope:               q.Scope,
+		State:               q.State,
+		Nonce:               q.Nonce,
+		CodeChallenge:       q.CodeChallenge,
+		CodeChallengeMethod: q.CodeChallengeMethod,
+	}
+}
+
+// 检查应用和回调地址. 这两项无效时不能跳转回应用, 直接返回错误
+func getClient(db *gorm.DB, q AuthorizeQuery) (*model.OAuthClient, error) {
+	client := model.OAuthClient{Id: q.ClientId}
+
+	if err := db.Where(&client).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.OAuthClientNotExist
+		}
+		return nil, err
+	}
+
+	if client.Status != model.OAuthClientStatusEnabled {
+		return nil, exception.OAuthClientDisabled
+	}
+
+	if !client.HasRedirectUri(q.RedirectUri) {
+		return nil, exception.InvalidRedirectUri
+	}
+
+	return &client, nil
+}
+
+// 获取授权页面需要的信息
+// 请求无效时返回带有错误信息的回调地址, 授权页面直接跳转回应用
+func GetAuthorize(c helper.Context, query AuthorizeQuery) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthAuthorize
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	if err = validator.ValidateStruct(query); err != nil {
+		return
+	}
+
+	client, err := getClient(database.Db, query)
+
+	if err != nil {
+		return
+	}
+
+	data.Client = clientToPublic(*client)
+	data.Scopes = make([]string, 0)
+
+	scopes, e := query.request().Validate(client)
+
+	if e != nil {
+		data.RedirectUri, err = oidc.ErrorRedirectUri(query.RedirectUri, query.State, e)
+		return
+	}
+
+	data.Scopes = scopes
+
+	consent := model.OAuthConsent{Uid: c.Uid, ClientId: client.Id}
+
+	if err = database.Db.Where(&consent).First(&consent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return
+	}
+
+	data.Consented = consent.Covers(scopes)
+
+	return
+}
+
+// 用户确认授权, 返回携带授权码的回调地址. 拒绝授权时回调地址携带 access_denied 错误
+func Authorize(c helper.Context, input AuthorizeParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.OAuthRedirect
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	client, err := getClient(database.Db, input.AuthorizeQuery)
+
+	if err != nil {
+		return
+	}
+
+	scopes, e := input.request().Validate(client)
+
+	if e == nil && !input.Approve {
+		e = oidc.ErrAccessDenied("the user denied the request")
+	}
+
+	if e != nil {
+		data.RedirectUri, err = oidc.ErrorRedirectUri(input.RedirectUri, input.State, e)
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if _, err = getUser(tx, c.Uid); err != nil {
+		return
+	}
+
+	// 记录用户的同意, 之后申请相同的 scope 不需要再次确认
+	consent := model.OAuthConsent{Uid: c.Uid, ClientId: client.Id}
+
+	if err = tx.Where(&consent).First(&consent).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return
+		}
+
+		consent.Scopes = scopes
+
+		if err = tx.Create(&consent).Error; err != nil {
+			return
+		}
+	} else if !consent.Covers(scopes) {
+		for _, scope := range scopes {
+			if !oidc.HasScope(consent.Scopes, scope) {
+				consent.Scopes = append(consent.Scopes, scope)
+			}
+		}
+
+		if err = tx.Model(&consent).Update("scopes", consent.Scopes).Error; err != nil {
+			return
+		}
+	}
+
+	code, err := oidc.IssueCode(oidc.Code{
+		Grant: oidc.Grant{
+			ClientId: client.Id,
+			Uid:      c.Uid,
+			Scopes:   scopes,
+			AuthTime: authTime(c),
+		},
+		RedirectUri:         input.RedirectUri,
+		Nonce:               input.Nonce,
+		CodeChallenge:       input.CodeChallenge,
+		CodeChallengeMethod: input.CodeChallengeMethod,
+	})
+
+	if err != nil {
+		return
+	}
+
+	data.RedirectUri, err = oidc.RedirectUri(input.RedirectUri, url.Values{
+		"code":  []string{code},
+		"state": []string{input.State},
+	})
+
+	return
+}
+
+var GetAuthorizeRouter = router.Handler(func(c router.Context) {
+	var (
+		query AuthorizeQuery
+	)
+
+	c.ResponseFunc(c.ShouldBindQuery(&query), func() schema.Response {
+		return GetAuthorize(helper.NewContext(&c), query)
+	})
+})
+
+var AuthorizeRouter = router.Handler(func(c router.Context) {
+	var (
+		input AuthorizeParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return Authorize(helper.NewContext(&c), input)
+	})
+})
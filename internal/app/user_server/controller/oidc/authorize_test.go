@@ -0,0 +1,284 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/oidc"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	oidcService "github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/tester"
+	"github.com/axetroy/mocker"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const redirectUri = "https://example.com/callback"
+
+func createClient(t *testing.T, public bool) (model.OAuthClient, string) {
+	secret, hash, err := oidcService.GenerateSecret()
+
+	assert.Nil(t, err)
+
+	c := model.OAuthClient{
+		Name:         "test",
+		Public:       public,
+		RedirectUris: []string{redirectUri},
+		Scopes:       []string{oidcService.ScopeOpenId, oidcService.ScopeProfile, oidcService.ScopeOfflineAccess},
+		Status:       model.OAuthClientStatusEnabled,
+	}
+
+	if !public {
+		c.Secret = hash
+	}
+
+	assert.Nil(t, database.Db.Create(&c).Error)
+
+	return c, secret
+}
+
+func deleteClient(id string) {
+	database.DeleteRowByTable("oauth_consent", "client_id", id)
+	database.DeleteRowByTable("oauth_client", "id", id)
+}
+
+// 跳转地址中的参数
+func redirectQuery(t *testing.T, r schema.Response) url.Values {
+	data := schema.OAuthRedirect{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Nil(t, r.Decode(&data))
+
+	u, err := url.Parse(data.RedirectUri)
+
+	assert.Nil(t, err)
+
+	return u.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	client, secret := createClient(t, false)
+
+	defer deleteClient(client.Id)
+
+	c := helper.Context{Uid: userInfo.Id}
+
+	query := oidc.AuthorizeQuery{
+		ResponseType: "code",
+		ClientId:     client.Id,
+		RedirectUri:  redirectUri,
+		Scope:        "openid profile offline_access",
+		State:        "state",
+		Nonce:        "nonce",
+	}
+
+	// 未登记的回调地址不能跳转
+	{
+		q := query
+		q.RedirectUri = "https://example.com/other"
+
+		r := oidc.GetAuthorize(c, q)
+
+		assert.Equal(t, exception.InvalidRedirectUri.Error(), r.Message)
+	}
+
+	// 还没有授权过
+	{
+		r := oidc.GetAuthorize(c, query)
+
+		data := schema.OAuthAuthorize{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&data))
+		assert.Equal(t, client.Id, data.Client.Id)
+		assert.Equal(t, []string{"openid", "profile", "offline_access"}, data.Scopes)
+		assert.False(t, data.Consented)
+	}
+
+	// 拒绝授权
+	{
+		values := redirectQuery(t, oidc.Authorize(c, oidc.AuthorizeParams{AuthorizeQuery: query, Approve: false}))
+
+		assert.Equal(t, "access_denied", values.Get("error"))
+		assert.Equal(t, "state", values.Get("state"))
+	}
+
+	values := redirectQuery(t, oidc.Authorize(c, oidc.AuthorizeParams{AuthorizeQuery: query, Approve: true}))
+
+	code := values.Get("code")
+
+	assert.NotEmpty(t, code)
+	assert.Equal(t, "state", values.Get("state"))
+
+	// 已经授权过
+	{
+		r := oidc.GetAuthorize(c, query)
+
+		data := schema.OAuthAuthorize{}
+
+		assert.Nil(t, r.Decode(&data))
+		assert.True(t, data.Consented)
+	}
+
+	requestToken := func(form url.Values, clientSecret string) (*http.Response, map[string]interface{}) {
+		req, _ := http.NewRequest(http.MethodPost, "", nil)
+		req.SetBasicAuth(url.QueryEscape(client.Id), url.QueryEscape(clientSecret))
+
+		w := tester.HttpUser.Post("/v1/oauth/token", []byte(form.Encode()), &mocker.Header{
+			"Content-Type":  "application/x-www-form-urlencoded",
+			"Authorization": req.Header.Get("Authorization"),
+		})
+
+		result := map[string]interface{}{}
+
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+		return w.Result(), result
+	}
+
+	form := url.Values{
+		"grant_type":   []string{"authorization_code"},
+		"code":         []string{code},
+		"redirect_uri": []string{redirectUri},
+	}
+
+	// 错误的密钥
+	{
+		res, result := requestToken(form, "invalid")
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "invalid_client", result["error"])
+	}
+
+	res, tokens := requestToken(form, secret)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "Bearer", tokens["token_type"])
+	assert.NotEmpty(t, tokens["id_token"])
+	assert.NotEmpty(t, tokens["refresh_token"])
+
+	// 授权码只能使用一次
+	{
+		res, result := requestToken(form, secret)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "invalid_grant", result["error"])
+	}
+
+	accessToken := tokens["access_token"].(string)
+
+	getUserInfo := func() (int, map[string]interface{}) {
+		w := tester.HttpUser.Get("/v1/oauth/userinfo", nil, &mocker.Header{
+			"Authorization": token.JoinPrefixToken(accessToken),
+		})
+
+		result := map[string]interface{}{}
+
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+		return w.Code, result
+	}
+
+	status, claims := getUserInfo()
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, userInfo.Id, claims["sub"])
+	assert.Equal(t, userInfo.Username, claims["preferred_username"])
+
+	// 刷新令牌
+	{
+		res, result := requestToken(url.Values{
+			"grant_type":    []string{"refresh_token"},
+			"refresh_token": []string{tokens["refresh_token"].(string)},
+		}, secret)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, tokens["refresh_token"], result["refresh_token"])
+	}
+
+	// 已授权的应用
+	{
+		r := oidc.GetConsentList(c)
+
+		list := make([]schema.OAuthConsent, 0)
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&list))
+		assert.Len(t, list, 1)
+		assert.Equal(t, client.Id, list[0].Client.Id)
+	}
+
+	// 撤销授权之后 token 失效
+	{
+		r := oidc.RevokeConsent(c, client.Id)
+
+		assert.Equal(t, "", r.Message)
+
+		status, claims := getUserInfo()
+
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "invalid_token", claims["error"])
+	}
+}
+
+func TestPublicClientRequirePKCE(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	client, _ := createClient(t, true)
+
+	defer deleteClient(client.Id)
+
+	c := helper.Context{Uid: userInfo.Id}
+
+	query := oidc.AuthorizeQuery{
+		ResponseType: "code",
+		ClientId:     client.Id,
+		RedirectUri:  redirectUri,
+		Scope:        "openid",
+	}
+
+	// 没有使用 PKCE
+	{
+		r := oidc.GetAuthorize(c, query)
+
+		data := schema.OAuthAuthorize{}
+
+		assert.Equal(t, "", r.Message)
+		assert.Nil(t, r.Decode(&data))
+		assert.True(t, strings.Contains(data.RedirectUri, "error=invalid_request"))
+	}
+
+	verifier := strings.Repeat("v", 64)
+
+	query.CodeChallenge = oidcService.CodeChallenge(verifier)
+	query.CodeChallengeMethod = oidcService.CodeChallengeMethodS256
+
+	code := redirectQuery(t, oidc.Authorize(c, oidc.AuthorizeParams{AuthorizeQuery: query, Approve: true})).Get("code")
+
+	form := url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"code":          []string{code},
+		"redirect_uri":  []string{redirectUri},
+		"client_id":     []string{client.Id},
+		"code_verifier": []string{verifier},
+	}
+
+	w := tester.HttpUser.Post("/v1/oauth/token", []byte(form.Encode()), &mocker.Header{
+		"Content-Type": "application/x-www-form-urlencoded",
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
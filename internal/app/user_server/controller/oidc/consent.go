@@ -0,0 +1,133 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+func consentToSchema(consent model.OAuthConsent, client model.OAuthClient) schema.OAuthConsent {
+	return schema.OAuthConsent{
+		Client:    clientToPublic(client),
+		Scopes:    consent.Scopes,
+		CreatedAt: consent.CreatedAt.Format(time.RFC3339Nano),
+		UpdatedAt: consent.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// 获取已授权的应用
+func GetConsentList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.OAuthConsent, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list := make([]model.OAuthConsent, 0)
+
+	if err = database.Db.Where("uid = ?", c.Uid).Order("updated_at DESC").Find(&list).Error; err != nil {
+		return
+	}
+
+	for _, consent := range list {
+		client := model.OAuthClient{Id: consent.ClientId}
+
+		if err = database.Db.Unscoped().Where(&client).First(&client).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				err = nil
+				continue
+			}
+			return
+		}
+
+		data = append(data, consentToSchema(consent, client))
+	}
+
+	return
+}
+
+// 撤销给应用的授权, 应用持有的 token 全部失效, 下次登陆需要重新授权
+func RevokeConsent(c helper.Context, clientId string) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	consent := model.OAuthConsent{Uid: c.Uid, ClientId: clientId}
+
+	if err = tx.Where(&consent).First(&consent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.OAuthConsentNotExist
+		}
+		return
+	}
+
+	if err = tx.Delete(&consent).Error; err != nil {
+		return
+	}
+
+	err = oidc.RevokeGrant(c.Uid, clientId)
+
+	return
+}
+
+var GetConsentListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetConsentList(helper.NewContext(&c))
+	})
+})
+
+var RevokeConsentRouter = router.Handler(func(c router.Context) {
+	id := c.Param("client_id")
+
+	c.ResponseFunc(nil, func() schema.Response {
+		return RevokeConsent(helper.NewContext(&c), id)
+	})
+})
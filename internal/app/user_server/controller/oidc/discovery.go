@@ -0,0 +1,75 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/axetroy/go-server/internal/service/token"
+)
+
+// 发现文档 (OpenID Connect Discovery 1.0)
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+func GetDiscovery() Discovery {
+	issuer := config.Oidc.Issuer
+
+	return Discovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             config.Oidc.AuthorizeUrl,
+		TokenEndpoint:                     issuer + "/v1/oauth/token",
+		UserinfoEndpoint:                  issuer + "/v1/oauth/userinfo",
+		JwksUri:                           issuer + "/v1/oauth/jwks",
+		IntrospectionEndpoint:             issuer + "/v1/oauth/introspect",
+		RevocationEndpoint:                issuer + "/v1/oauth/revoke",
+		ScopesSupported:                   oidc.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"ES256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oidc.CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash",
+			"name", "nickname", "preferred_username", "picture", "gender", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+}
+
+var DiscoveryRouter = router.Handler(func(c router.Context) {
+	w := c.Writer()
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	_ = json.NewEncoder(w).Encode(GetDiscovery())
+})
+
+// 用于验证 ID Token 和 access token 的公钥
+var JWKSRouter = router.Handler(func(c router.Context) {
+	w := c.Writer()
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	// 密钥轮换时新的公钥会提前发布, 允许短时间的缓存
+	w.Header().Set("Cache-Control", "public, max-age=60")
+
+	_ = json.NewEncoder(w).Encode(token.JWKS(token.StateOidc))
+})
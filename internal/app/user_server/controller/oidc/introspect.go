@@ -0,0 +1,47 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"net/http"
+)
+
+// 查询 access token 的状态 (RFC 7662), 只有私密应用 (例如其他服务的后端) 可以调用
+var IntrospectRouter = router.Handler(func(c router.Context) {
+	r := c.Request()
+
+	client, err := authenticateClient(database.Db, r)
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if client.Public {
+		writeError(c, oidc.ErrUnauthorizedClient("public clients cannot introspect tokens"))
+		return
+	}
+
+	info, err := oidc.Introspect(r.PostForm.Get("token"))
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// 签发 token 的应用已经被停用
+	if info.Active {
+		if _, err := oidc.GetClient(database.Db, info.ClientId); err != nil {
+			if _, ok := err.(*oidc.Error); !ok {
+				writeError(c, err)
+				return
+			}
+
+			info = &oidc.Introspection{Active: false}
+		}
+	}
+
+	writeJSON(c, http.StatusOK, info)
+})
@@ -0,0 +1,34 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"net/http"
+)
+
+// 撤销 token (RFC 7009), 无效的 token 也返回成功
+var RevokeRouter = router.Handler(func(c router.Context) {
+	r := c.Request()
+
+	client, err := authenticateClient(database.Db, r)
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if r.PostForm.Get("token") == "" {
+		writeError(c, oidc.ErrInvalidRequest("token is required"))
+		return
+	}
+
+	if err := oidc.Revoke(r.PostForm.Get("token"), client.Id, r.PostForm.Get("token_type_hint")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.StatusCode(http.StatusOK)
+})
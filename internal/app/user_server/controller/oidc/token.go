@@ -0,0 +1,95 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"net/http"
+)
+
+// 换取 token 时用户必须依然可以登陆, 应用也必须依然可以申请这些 scope
+func checkGrant(client *model.OAuthClient, g oidc.Grant) (*model.User, error) {
+	for _, scope := range g.Scopes {
+		if !client.HasScope(scope) {
+			return nil, oidc.ErrInvalidScope("scope " + scope + " is no longer allowed")
+		}
+	}
+
+	userInfo, err := getUser(database.Db, g.Uid)
+
+	if err != nil {
+		return nil, oidc.ErrInvalidGrant("the user is not available")
+	}
+
+	return userInfo, nil
+}
+
+// 使用授权码换取 token
+func exchangeCode(client *model.OAuthClient, r *http.Request) (*oidc.Tokens, error) {
+	code, err := oidc.UseCode(r.PostForm.Get("code"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if e := code.Verify(client.Id, r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier")); e != nil {
+		return nil, e
+	}
+
+	userInfo, err := checkGrant(client, code.Grant)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return oidc.Issue(code.Grant, *userInfo, code.Nonce)
+}
+
+// 使用刷新令牌换取 token
+func refreshToken(client *model.OAuthClient, r *http.Request) (*oidc.Tokens, error) {
+	g, err := oidc.Refresh(r.PostForm.Get("refresh_token"), client.Id, r.PostForm.Get("scope"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo, err := checkGrant(client, *g)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return oidc.Issue(*g, *userInfo, "")
+}
+
+// 应用换取 token 的接口 (RFC 6749 4.1.3, 6)
+var TokenRouter = router.Handler(func(c router.Context) {
+	r := c.Request()
+
+	client, err := authenticateClient(database.Db, r)
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	var tokens *oidc.Tokens
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		tokens, err = exchangeCode(client, r)
+	case "refresh_token":
+		tokens, err = refreshToken(client, r)
+	default:
+		err = oidc.ErrUnsupportedGrantType("only authorization_code and refresh_token are supported")
+	}
+
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, tokens)
+})
@@ -0,0 +1,73 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/axetroy/go-server/internal/service/token"
+	"net/http"
+	"strings"
+)
+
+// 获取 access token, 支持 Authorization 头部和表单参数 (RFC 6750 2.1, 2.2)
+func bearerToken(r *http.Request) string {
+	if s := r.Header.Get(token.AuthField); strings.HasPrefix(s, token.Prefix+" ") {
+		return strings.TrimSpace(strings.TrimPrefix(s, token.Prefix+" "))
+	}
+
+	if r.Method == http.MethodPost {
+		_ = r.ParseForm()
+
+		return r.PostForm.Get("access_token")
+	}
+
+	return ""
+}
+
+func getUserInfo(tokenString string) (map[string]interface{}, error) {
+	claims, err := oidc.ParseAccessToken(tokenString)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := claims.Scopes()
+
+	if !oidc.HasScope(scopes, oidc.ScopeOpenId) {
+		return nil, oidc.ErrInsufficientScope("openid scope is required")
+	}
+
+	// 应用被停用之后, 已签发的 token 也不能再使用
+	if _, err := oidc.GetClient(database.Db, claims.ClientId); err != nil {
+		if _, ok := err.(*oidc.Error); ok {
+			return nil, oidc.ErrInvalidToken("client is not available")
+		}
+		return nil, err
+	}
+
+	userInfo, err := getUser(database.Db, claims.Subject)
+
+	if err != nil {
+		return nil, oidc.ErrInvalidToken("user is not available")
+	}
+
+	return oidc.UserClaims(*userInfo, scopes), nil
+}
+
+// 获取用户信息 (OpenID Connect Core 5.3)
+var UserInfoRouter = router.Handler(func(c router.Context) {
+	claims, err := getUserInfo(bearerToken(c.Request()))
+
+	if err != nil {
+		if e, ok := err.(*oidc.Error); ok {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s", error_description="%s"`, e.Code, e.Description))
+		}
+
+		writeError(c, err)
+		return
+	}
+
+	writeJSON(c, http.StatusOK, claims)
+})
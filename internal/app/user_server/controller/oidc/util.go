@@ -0,0 +1,130 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/jinzhu/gorm"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func clientToPublic(c model.OAuthClient) schema.OAuthClientPublic {
+	return schema.OAuthClientPublic{
+		Id:          c.Id,
+		Name:        c.Name,
+		Description: c.Description,
+		Logo:        c.Logo,
+		Homepage:    c.Homepage,
+	}
+}
+
+// 用户登陆的时间, 即当前会话的创建时间
+func authTime(c helper.Context) int64 {
+	if list, err := authentication.Gateway(false).Sessions(c.Uid); err == nil {
+		for _, s := range list {
+			if s.Id == c.SessionId {
+				return s.CreatedAt.Unix()
+			}
+		}
+	}
+
+	return time.Now().Unix()
+}
+
+// 获取可以登陆的用户
+func getUser(db *gorm.DB, uid string) (*model.User, error) {
+	userInfo := model.User{Id: uid}
+
+	if err := db.First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.UserNotExist
+		}
+		return nil, err
+	}
+
+	if userInfo.Status == model.UserStatusBanned {
+		return nil, exception.UserHaveBeenBan
+	}
+
+	return &userInfo, nil
+}
+
+// 按照协议的格式输出, 不包装成通用的响应结构, 方便现成的 OAuth2/OpenID Connect 库直接使用
+func writeJSON(c router.Context, status int, v interface{}) {
+	w := c.Writer()
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(c router.Context, err error) {
+	e, ok := err.(*oidc.Error)
+
+	if !ok {
+		log.Println(err)
+		e = &oidc.Error{Code: "server_error", Status: http.StatusInternalServerError}
+	}
+
+	if e.Status == http.StatusUnauthorized && e.Code == "invalid_client" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	writeJSON(c, e.Status, e)
+}
+
+// 获取应用的身份 (RFC 6749 2.3.1), 支持 Basic 认证和表单参数两种方式, 但是不能同时使用
+func clientCredentials(r *http.Request) (clientId string, secret string, err error) {
+	if id, s, ok := r.BasicAuth(); ok {
+		if r.PostForm.Get("client_secret") != "" {
+			err = oidc.ErrInvalidRequest("multiple client authentication methods")
+			return
+		}
+
+		// Basic 认证的用户名和密码需要先进行 URL 编码
+		if clientId, err = url.QueryUnescape(id); err != nil {
+			err = oidc.ErrInvalidClient("invalid client_id")
+			return
+		}
+
+		if secret, err = url.QueryUnescape(s); err != nil {
+			err = oidc.ErrInvalidClient("invalid client_secret")
+			return
+		}
+
+		return
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), nil
+}
+
+// 解析表单并验证应用的身份
+func authenticateClient(db *gorm.DB, r *http.Request) (*model.OAuthClient, error) {
+	if r.Method != http.MethodPost {
+		return nil, oidc.ErrInvalidRequest("method must be POST")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, oidc.ErrInvalidRequest("invalid form body")
+	}
+
+	clientId, secret, err := clientCredentials(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return oidc.Authenticate(db, clientId, secret)
+}
@@ -0,0 +1,233 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package transfer
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/logger"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/invite"
+	"github.com/axetroy/go-server/internal/service/ledger"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 收款方接受转账
+func Accept(c helper.Context, transferId string) (res schema.Response) {
+	return confirm(c, transferId, model.TransferStatusConfirmed)
+}
+
+// 收款方拒绝转账, 冻结的金额退回给转账人
+func Reject(c helper.Context, transferId string) (res schema.Response) {
+	return confirm(c, transferId, model.TransferStatusReject)
+}
+
+func confirm(c helper.Context, transferId string, status model.TransferStatus) (res schema.Response) {
+	var (
+		err   error
+		tx    *gorm.DB
+		data  = schema.TransferLog{}
+		payer string // 完成了支付的转账人
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		// 收款人确认之后, 转账人才算完成了支付. 在转账的事务提交之后再发放奖励
+		if err == nil && payer != "" {
+			invite.AdvanceAfterCommit(database.Db, payer, model.StatusInvitePay)
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	currencies, err := currency.Codes(database.Db)
+
+	if err != nil {
+		return
+	}
+
+	log := model.TransferLog{}
+
+	sql := GenerateTransferLogSQL(QueryParams{
+		Id: &transferId,
+	}, currencies, 1, false)
+
+	if err = database.Db.Raw(sql).Scan(&log).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.TransferNotExist
+		}
+		return
+	}
+
+	// 只有收款人才能处理这笔转账
+	if log.To != c.Uid {
+		err = exception.NoPermission
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	if err = settle(tx, &log, status); err != nil {
+		return
+	}
+
+	if status == model.TransferStatusConfirmed {
+		payer = log.From
+	}
+
+	mapToSchema(log, &data)
+
+	return
+}
+
+// 结算一笔待确认的转账
+// 接受: 扣除转账人冻结的金额, 增加收款人的余额
+// 拒绝: 把转账人冻结的金额退回到余额
+func settle(tx *gorm.DB, log *model.TransferLog, status model.TransferStatus) (err error) {
+	now := time.Now()
+
+	// 只有待确认的转账才能被处理, 用带条件的更新防止重复结算
+	result := tx.Table(GetTransferTableName(log.Currency)).
+		Where("id = ? AND status = ?", log.Id, model.TransferStatusWaitForConfirm).
+		UpdateColumns(map[string]interface{}{
+			"status":     status,
+			"updated_at": now,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected != 1 {
+		return exception.TransferNotPending
+	}
+
+	log.Status = status
+	log.UpdatedAt = now
+
+	wallets, err := ledger.Lock(tx, log.Currency, log.From, log.To)
+
+	if err != nil {
+		return
+	}
+
+	var (
+		fromUserFinanceLog *model.FinanceLog
+		toUserFinanceLog   *model.FinanceLog
+	)
+
+	switch status {
+	case model.TransferStatusConfirmed:
+		// 扣除转账人冻结的钱
+		if fromUserFinanceLog, err = ledger.Mutate(tx, wallets[log.From], decimal.Zero, log.Amount.Neg()); err != nil {
+			return
+		}
+
+		// 给收款人加钱
+		if toUserFinanceLog, err = ledger.Mutate(tx, wallets[log.To], log.Amount, decimal.Zero); err != nil {
+			return
+		}
+
+		fromUserFinanceLog.Type = model.FinanceTypeTransferOut
+		toUserFinanceLog.Type = model.FinanceTypeTransferIn
+	case model.TransferStatusReject:
+		// 冻结的钱退回到转账人的余额
+		if fromUserFinanceLog, err = ledger.Mutate(tx, wallets[log.From], log.Amount, log.Amount.Neg()); err != nil {
+			return
+		}
+
+		fromUserFinanceLog.Type = model.FinanceTypeTransferReturn
+	default:
+		return exception.InvalidParams
+	}
+
+	err = ledger.WriteLogs(tx, log.Currency, log.Id, fromUserFinanceLog, toUserFinanceLog)
+
+	return
+}
+
+// 自动拒绝超时未处理的转账, 返回处理的数量
+// 由定时任务调用
+func RejectExpired(now time.Time) (int, error) {
+	deadline := now.Add(-config.Transfer.PendingTimeout)
+
+	count := 0
+
+	currencies, err := currency.Codes(database.Db)
+
+	if err != nil {
+		return count, err
+	}
+
+	for _, code := range currencies {
+		tableName := GetTransferTableName(code)
+
+		list := make([]model.TransferLog, 0)
+
+		if err := database.Db.Table(tableName).Where("status = ? AND created_at < ?", model.TransferStatusWaitForConfirm, deadline).Find(&list).Error; err != nil {
+			return count, err
+		}
+
+		for _, log := range list {
+			// 每一笔转账单独一个事务, 其中一笔失败不影响其他的
+			tx := database.Db.Begin()
+
+			if err := settle(tx, &log, model.TransferStatusReject); err != nil {
+				_ = tx.Rollback().Error
+
+				// 在查询之后被收款人处理了, 忽略即可
+				if err == exception.TransferNotPending {
+					continue
+				}
+
+				logger.Errorf("reject expired transfer %s fail: %s", log.Id, err.Error())
+				continue
+			}
+
+			if err := tx.Commit().Error; err != nil {
+				return count, err
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+var AcceptRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return Accept(helper.NewContext(&c), c.Param("transfer_id"))
+	})
+})
+
+var RejectRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return Reject(helper.NewContext(&c), c.Param("transfer_id"))
+	})
+})
@@ -0,0 +1,191 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package transfer_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/transfer"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// 创建一笔待确认的转账
+func createPendingTransfer(t *testing.T, from string, to string, amount string) schema.TransferLog {
+	input := transfer.ToParams{
+		Currency: "CNY",
+		To:       to,
+		Amount:   amount,
+		Pending:  true,
+	}
+
+	b, err := json.Marshal(input)
+
+	assert.Nil(t, err)
+
+	signature, err := util.Signature(string(b))
+
+	assert.Nil(t, err)
+
+	res := transfer.To(helper.Context{Uid: from}, input, signature, "")
+
+	data := schema.TransferLog{}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Nil(t, res.Decode(&data))
+	assert.Equal(t, model.TransferStatusWaitForConfirm, data.Status)
+
+	return data
+}
+
+func assertWallet(t *testing.T, uid string, balance string, frozen string) {
+	r := wallet.GetWallet(helper.Context{Uid: uid}, "CNY")
+	w := schema.Wallet{}
+
+	assert.Equal(t, "", r.Message)
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, r.Decode(&w))
+	assert.Equal(t, balance, w.Balance)
+	assert.Equal(t, frozen, w.Frozen)
+}
+
+func TestAccept(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userFrom.Username)
+	defer tester.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
+		Balance:  decimal.NewFromInt(100),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	log := createPendingTransfer(t, userFrom.Id, userTo.Id, "20")
+
+	// 转账的钱被冻结, 对方还没有收到
+	assertWallet(t, userFrom.Id, "80.00000000", "20.00000000")
+	assertWallet(t, userTo.Id, "0.00000000", "0.00000000")
+
+	// 转账人不能确认
+	{
+		res := transfer.Accept(helper.Context{Uid: userFrom.Id}, log.Id)
+
+		assert.Equal(t, exception.NoPermission.Error(), res.Message)
+		assert.Equal(t, exception.NoPermission.Code(), res.Status)
+	}
+
+	res := transfer.Accept(helper.Context{Uid: userTo.Id}, log.Id)
+
+	data := schema.TransferLog{}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Nil(t, res.Decode(&data))
+	assert.Equal(t, model.TransferStatusConfirmed, data.Status)
+
+	assertWallet(t, userFrom.Id, "80.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "20.00000000", "0.00000000")
+
+	// 不能重复处理
+	{
+		res := transfer.Reject(helper.Context{Uid: userTo.Id}, log.Id)
+
+		assert.Equal(t, exception.TransferNotPending.Error(), res.Message)
+		assert.Equal(t, exception.TransferNotPending.Code(), res.Status)
+	}
+
+	assertWallet(t, userFrom.Id, "80.00000000", "0.00000000")
+}
+
+func TestReject(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userFrom.Username)
+	defer tester.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
+		Balance:  decimal.NewFromInt(100),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	log := createPendingTransfer(t, userFrom.Id, userTo.Id, "20")
+
+	res := transfer.Reject(helper.Context{Uid: userTo.Id}, log.Id)
+
+	data := schema.TransferLog{}
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Nil(t, res.Decode(&data))
+	assert.Equal(t, model.TransferStatusReject, data.Status)
+
+	// 冻结的钱退回
+	assertWallet(t, userFrom.Id, "100.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "0.00000000", "0.00000000")
+
+	// 不存在的转账
+	{
+		res := transfer.Accept(helper.Context{Uid: userTo.Id}, "123123")
+
+		assert.Equal(t, exception.TransferNotExist.Error(), res.Message)
+		assert.Equal(t, exception.TransferNotExist.Code(), res.Status)
+	}
+}
+
+func TestRejectExpired(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userFrom.Username)
+	defer tester.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
+		Balance:  decimal.NewFromInt(100),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	log1 := createPendingTransfer(t, userFrom.Id, userTo.Id, "20")
+	log2 := createPendingTransfer(t, userFrom.Id, userTo.Id, "30")
+
+	assertWallet(t, userFrom.Id, "50.00000000", "50.00000000")
+
+	// 还没有超时
+	count, err := transfer.RejectExpired(time.Now())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	// 让第一笔转账超时
+	assert.Nil(t, database.Db.Table(transfer.GetTransferTableName("CNY")).Where("id = ?", log1.Id).UpdateColumn("created_at", time.Now().Add(-config.Transfer.PendingTimeout-time.Minute)).Error)
+
+	count, err = transfer.RejectExpired(time.Now())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	assertWallet(t, userFrom.Id, "70.00000000", "30.00000000")
+
+	// 第二笔转账不受影响
+	res := transfer.Accept(helper.Context{Uid: userTo.Id}, log2.Id)
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+
+	assertWallet(t, userFrom.Id, "70.00000000", "0.00000000")
+	assertWallet(t, userTo.Id, "30.00000000", "0.00000000")
+}
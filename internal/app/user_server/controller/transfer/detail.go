@@ -8,10 +8,9 @@ import (
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
-	"time"
 )
 
 func GetDetail(c helper.Context, transferId string) (res schema.Response) {
@@ -57,11 +56,17 @@ func GetDetail(c helper.Context, transferId string) (res schema.Response) {
 
 	log := model.TransferLog{}
 
+	currencies, err := currency.Codes(tx)
+
+	if err != nil {
+		return
+	}
+
 	// 联表查询
 	// 只能获取自己转给别人的
 	sql := GenerateTransferLogSQL(QueryParams{
 		Id: &transferId,
-	}, 1, false)
+	}, currencies, 1, false)
 
 	if err = tx.Raw(sql).Scan(&log).Error; err != nil {
 		return
@@ -75,12 +80,8 @@ func GetDetail(c helper.Context, transferId string) (res schema.Response) {
 		}
 	}
 
-	if err = mapstructure.Decode(log, &data.TransferLogPure); err != nil {
-		return
-	}
+	mapToSchema(log, &data)
 
-	data.CreatedAt = log.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = log.UpdatedAt.Format(time.RFC3339Nano)
 	return
 }
 
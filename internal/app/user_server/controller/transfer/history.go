@@ -8,10 +8,9 @@ import (
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
-	"time"
 )
 
 type Query struct {
@@ -72,9 +71,15 @@ func GetHistory(c helper.Context, query Query) (res schema.Response) {
 		From: &c.Uid,
 	}
 
+	currencies, err := currency.Codes(tx)
+
+	if err != nil {
+		return
+	}
+
 	// 联表查询
-	countSQL := GenerateTransferLogSQL(condition, query.Limit, true)
-	listSQL := GenerateTransferLogSQL(condition, query.Limit, false)
+	countSQL := GenerateTransferLogSQL(condition, currencies, query.Limit, true)
+	listSQL := GenerateTransferLogSQL(condition, currencies, query.Limit, false)
 
 	var total int64
 
@@ -88,12 +93,7 @@ func GetHistory(c helper.Context, query Query) (res schema.Response) {
 
 	for _, v := range list {
 		d := schema.TransferLog{}
-		if er := mapstructure.Decode(v, &d.TransferLogPure); er != nil {
-			err = er
-			return
-		}
-		d.CreatedAt = v.CreatedAt.Format(time.RFC3339Nano)
-		d.UpdatedAt = v.UpdatedAt.Format(time.RFC3339Nano)
+		mapToSchema(v, &d)
 		data = append(data, d)
 	}
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/transfer"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
+	"github.com/axetroy/go-server/internal/library/decimal"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
@@ -26,7 +27,7 @@ func TestGetHistory(t *testing.T) {
 
 	// 给账户充钱
 	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+		Balance:  decimal.NewFromInt(100),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -47,7 +48,7 @@ func TestGetHistory(t *testing.T) {
 
 	res2 := transfer.To(helper.Context{
 		Uid: userFrom.Id,
-	}, input, signature)
+	}, input, signature, "")
 
 	assert.Equal(t, "", res2.Message)
 	assert.Equal(t, schema.StatusSuccess, res2.Status)
@@ -89,7 +90,7 @@ func TestGetHistoryRouter(t *testing.T) {
 
 	// 给账户充钱
 	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+		Balance:  decimal.NewFromInt(100),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -110,7 +111,7 @@ func TestGetHistoryRouter(t *testing.T) {
 
 	res2 := transfer.To(helper.Context{
 		Uid: userFrom.Id,
-	}, input, signature)
+	}, input, signature, "")
 
 	assert.Equal(t, "", res2.Message)
 	assert.Equal(t, schema.StatusSuccess, res2.Status)
@@ -4,8 +4,7 @@ package transfer
 import (
 	"encoding/json"
 	"errors"
-	"github.com/axetroy/go-server/internal/app/user_server/controller/finance"
-	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
+	"github.com/axetroy/go-server/internal/library/decimal"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/logger"
@@ -15,12 +14,12 @@ import (
 	"github.com/axetroy/go-server/internal/middleware"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/invite"
+	"github.com/axetroy/go-server/internal/service/ledger"
 	"github.com/jinzhu/gorm"
-	"github.com/mitchellh/mapstructure"
-	"strconv"
-	"strings"
-	"time"
+	"github.com/lib/pq"
 )
 
 type ToParams struct {
@@ -28,13 +27,15 @@ type ToParams struct {
 	To       string  `json:"to" validate:"required,numeric" comment:"转账对象"`          // 转账给谁
 	Amount   string  `json:"amount" validate:"required,numeric,gt=0" comment:"转账数量"` // 转账数量
 	Note     *string `json:"note" validate:"omitempty" comment:"转账备注"`               // 转账备注
+	Pending  bool    `json:"pending,omitempty"`                                      // 是否需要收款方确认, 确认之前转账金额处于冻结状态
 }
 
-func To(c helper.Context, input ToParams, signature string) (res schema.Response) {
+func To(c helper.Context, input ToParams, signature string, idempotencyKey string) (res schema.Response) {
 	var (
 		err  error
 		tx   *gorm.DB
 		data = schema.TransferLog{}
+		paid bool // 转账是否已经完成
 	)
 
 	defer func() {
@@ -58,6 +59,11 @@ func To(c helper.Context, input ToParams, signature string) (res schema.Response
 			}
 		}
 
+		// 转账完成, 被邀请人完成了第一笔支付. 在转账的事务提交之后再发放奖励
+		if err == nil && paid {
+			invite.AdvanceAfterCommit(database.Db, c.Uid, model.StatusInvitePay)
+		}
+
 		helper.Response(&res, data, nil, err)
 	}()
 
@@ -85,100 +91,113 @@ func To(c helper.Context, input ToParams, signature string) (res schema.Response
 		}
 	}
 
-	tx = database.Db.Begin()
-
-	fromUserInfo := model.User{Id: c.Uid}
-	toUserInfo := model.User{Id: input.To}
+	var amount decimal.Decimal // 转账数量
 
-	if err = tx.Where(&fromUserInfo).Last(&fromUserInfo).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			err = exception.UserNotExist
-		}
+	if amount, err = decimal.NewFromString(input.Amount); err != nil || !amount.IsPositive() {
+		err = exception.InvalidParams
 		return
 	}
 
-	if err = tx.Where(&toUserInfo).Last(&toUserInfo).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			err = exception.UserNotExist
-		}
+	if input.To == c.Uid {
+		err = exception.TransferToSelf
 		return
 	}
 
-	walletTableName := wallet.GetTableName(input.Currency)      // 对应的钱包表名
-	transferTableName := GetTransferTableName(input.Currency)   // 对应的转账记录表名
-	financeLogTableName := finance.GetTableName(input.Currency) // 对应的财务日志表名
-
-	fromUserWallet := model.Wallet{
-		Id: c.Uid,
-	}
-
-	toUserWallet := model.Wallet{
-		Id: input.To,
-	}
+	currencyInfo, err := currency.GetEnabled(database.Db, input.Currency)
 
-	if err = tx.Table(walletTableName).Where("id = ?", fromUserWallet.Id).FirstOrInit(&fromUserWallet).Error; err != nil {
+	if err != nil {
 		return
 	}
 
-	if err = tx.Table(walletTableName).Where("id = ?", toUserWallet.Id).FirstOrInit(&toUserWallet).Error; err != nil {
+	// 金额的小数位数不能超过币种的精度
+	if !currencyInfo.ValidAmount(amount) {
+		err = exception.InvalidParams
 		return
 	}
 
-	var amount float64 // 转账数量
+	var idempotency *string // 幂等键
 
-	if amount, err = strconv.ParseFloat(input.Amount, 64); err != nil {
-		return
-	}
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > 64 {
+			err = exception.InvalidParams
+			return
+		}
 
-	if fromUserWallet.Balance < amount {
-		err = exception.NotEnoughBalance
-		return
+		key := c.Uid + ":" + idempotencyKey
+		idempotency = &key
+
+		// 重试的请求直接返回第一次请求的结果
+		if err = replayIdempotency(database.Db, key, currencyInfo.Code, input, amount, &data); err != errIdempotencyNotFound {
+			return
+		}
+
+		err = nil
 	}
 
-	// 变动前的余额/冻结
-	fromUserBeforeBalance := fromUserWallet.Balance
-	fromUserBeforeFrozen := fromUserWallet.Frozen
-	toUserBeforeBalance := toUserWallet.Balance
-	toUserBeforeFrozen := toUserWallet.Frozen
+	transferTableName := GetTransferTableName(currencyInfo.Code) // 对应的转账记录表名
 
-	fromUserWallet.Balance = fromUserWallet.Balance - amount // - 自己的钱包
-	toUserWallet.Balance = toUserWallet.Balance + amount     // + 对方的钱包
+	tx = database.Db.Begin()
 
-	// 变动后的余额/冻结
-	fromUserAfterBalance := fromUserWallet.Balance
-	fromUserAfterFrozen := fromUserWallet.Frozen
-	toUserAfterBalance := toUserWallet.Balance
-	toUserAfterFrozen := toUserWallet.Frozen
+	fromUserInfo := model.User{Id: c.Uid}
+	toUserInfo := model.User{Id: input.To}
 
-	// 余额不能为负数
-	if fromUserWallet.Balance < 0 {
-		err = exception.NotEnoughBalance
+	if err = tx.Where(&fromUserInfo).Last(&fromUserInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
 		return
 	}
 
-	// 扣除我方的钱
-	if err = tx.Table(walletTableName).Where("id = ?", fromUserWallet.Id).UpdateColumn("balance", fromUserWallet.Balance).Error; err != nil {
+	if err = tx.Where(&toUserInfo).Last(&toUserInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.UserNotExist
+		}
 		return
 	}
 
-	// 给对方加钱
-	if err = tx.Table(walletTableName).Where("id = ?", toUserWallet.Id).UpdateColumn("balance", toUserWallet.Balance).Error; err != nil {
+	// 按固定顺序锁定双方的钱包, 直到事务结束
+	wallets, err := ledger.Lock(tx, currencyInfo.Code, c.Uid, input.To)
+
+	if err != nil {
 		return
 	}
 
-	// 如果转账记录的表不存在的话，那么就生成这个表
-	if !tx.HasTable(transferTableName) {
-		if err = tx.CreateTable(model.TransferLogMap[strings.ToUpper(input.Currency)]).Error; err != nil {
+	var (
+		status             = model.TransferStatusConfirmed
+		fromUserFinanceLog *model.FinanceLog
+		toUserFinanceLog   *model.FinanceLog
+	)
+
+	if input.Pending {
+		status = model.TransferStatusWaitForConfirm
+
+		// 冻结我方的钱, 等待对方确认
+		if fromUserFinanceLog, err = ledger.Mutate(tx, wallets[c.Uid], amount.Neg(), amount); err != nil {
+			return
+		}
+
+		fromUserFinanceLog.Type = model.FinanceTypeTransferFreeze
+	} else {
+		// 扣除我方的钱, 余额不能为负数
+		if fromUserFinanceLog, err = ledger.Mutate(tx, wallets[c.Uid], amount.Neg(), decimal.Zero); err != nil {
+			return
+		}
+
+		// 给对方加钱
+		if toUserFinanceLog, err = ledger.Mutate(tx, wallets[input.To], amount, decimal.Zero); err != nil {
 			return
 		}
+
+		fromUserFinanceLog.Type = model.FinanceTypeTransferOut
+		toUserFinanceLog.Type = model.FinanceTypeTransferIn
 	}
 
 	transferLog := model.TransferLog{
-		Currency: strings.ToUpper(input.Currency),
+		Currency: currencyInfo.Code,
 		From:     c.Uid,
 		To:       input.To,
-		Status:   model.TransferStatusConfirmed,
-		Amount:   util.FloatToStr(amount), // 保留 8 未小数
+		Status:   status,
+		Amount:   amount,
 		Note:     input.Note,
 	}
 
@@ -186,55 +205,87 @@ func To(c helper.Context, input ToParams, signature string) (res schema.Response
 		return
 	}
 
-	if err = mapstructure.Decode(transferLog, &data.TransferLogPure); err != nil {
+	if idempotency != nil {
+		if err = tx.Create(&model.TransferIdempotency{
+			Id:         *idempotency,
+			TransferId: transferLog.Id,
+			Currency:   currencyInfo.Code,
+			To:         input.To,
+			Amount:     amount,
+			Pending:    input.Pending,
+			Note:       input.Note,
+		}).Error; err != nil {
+			// 相同幂等键的请求同时到达, 另一个请求已经完成了转账
+			if isUniqueViolation(err) {
+				_ = tx.Rollback().Error
+				tx = nil
+
+				if err = replayIdempotency(database.Db, *idempotency, currencyInfo.Code, input, amount, &data); err == errIdempotencyNotFound {
+					err = exception.IdempotencyKeyReused
+				}
+			}
+			return
+		}
+	}
+
+	mapToSchema(transferLog, &data)
+
+	// 写入双方的财务日志
+	if err = ledger.WriteLogs(tx, currencyInfo.Code, transferLog.Id, fromUserFinanceLog, toUserFinanceLog); err != nil {
 		return
 	}
 
-	data.CreatedAt = transferLog.CreatedAt.Format(time.RFC3339Nano)
-	data.UpdatedAt = transferLog.UpdatedAt.Format(time.RFC3339Nano)
+	paid = status == model.TransferStatusConfirmed
 
-	// 如果财务日志表不存在的话, 那么就生成这个表
-	if !tx.HasTable(financeLogTableName) {
-		if err = tx.CreateTable(model.FinanceLogMap[input.Currency]).Error; err != nil {
-			return
+	return
+}
+
+var errIdempotencyNotFound = errors.New("idempotency key not found")
+
+// 根据幂等键返回第一次请求的转账记录, 幂等键不存在时返回 errIdempotencyNotFound
+// 同一个幂等键只能用于同一笔转账, 任何参数不一致都说明客户端复用了幂等键
+func replayIdempotency(db *gorm.DB, idempotency string, currency string, input ToParams, amount decimal.Decimal, data *schema.TransferLog) error {
+	record := model.TransferIdempotency{}
+
+	if err := db.Where("id = ?", idempotency).First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errIdempotencyNotFound
 		}
+		return err
 	}
 
-	// 生成我的财务日志
-	fromUserFinanceLog := model.FinanceLog{
-		OrderId:         transferLog.Id, // 可用余额的变动
-		Uid:             c.Uid,
-		BeforeBalance:   fromUserBeforeBalance,
-		BalanceMutation: -amount,
-		AfterBalance:    fromUserAfterBalance,
-		BeforeFrozen:    fromUserBeforeFrozen, // 冻结余额的变动
-		FrozenMutation:  0,
-		AfterFrozen:     fromUserAfterFrozen,
-		Type:            model.FinanceTypeTransferOut,
-	}
-
-	// 生成对方的财务日志
-	toUserFinanceLog := model.FinanceLog{
-		OrderId:         transferLog.Id,
-		Uid:             input.To,
-		BeforeBalance:   toUserBeforeBalance, // 可用余额的变动
-		BalanceMutation: amount,
-		AfterBalance:    toUserAfterBalance,
-		BeforeFrozen:    toUserBeforeFrozen, // 冻结余额的变动
-		FrozenMutation:  0,
-		AfterFrozen:     toUserAfterFrozen,
-		Type:            model.FinanceTypeTransferIn,
-	}
-
-	if err = tx.Table(financeLogTableName).Create(&fromUserFinanceLog).Error; err != nil {
-		return
+	if record.Currency != currency ||
+		record.To != input.To ||
+		!record.Amount.Equal(amount) ||
+		record.Pending != input.Pending ||
+		!sameNote(record.Note, input.Note) {
+		return exception.IdempotencyKeyReused
 	}
 
-	if err = tx.Table(financeLogTableName).Create(&toUserFinanceLog).Error; err != nil {
-		return
+	log := model.TransferLog{}
+
+	if err := db.Table(GetTransferTableName(record.Currency)).Where("id = ?", record.TransferId).First(&log).Error; err != nil {
+		return err
 	}
 
-	return
+	mapToSchema(log, data)
+
+	return nil
+}
+
+func sameNote(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// 是否违反了唯一索引
+func isUniqueViolation(err error) bool {
+	if e, ok := err.(*pq.Error); ok {
+		return e.Code == "23505"
+	}
+	return false
 }
 
 var ToRouter = router.Handler(func(c router.Context) {
@@ -245,8 +296,11 @@ var ToRouter = router.Handler(func(c router.Context) {
 	// 获取数据签名
 	signature := c.GetHeader(middleware.SignatureHeader)
 
+	// 获取幂等键, 客户端重试时应该使用相同的值
+	idempotencyKey := c.GetHeader(middleware.IdempotencyKeyHeader)
+
 	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
-		return To(helper.NewContext(&c), input, signature)
+		return To(helper.NewContext(&c), input, signature, idempotencyKey)
 	})
 
 })
@@ -6,6 +6,7 @@ import (
 	"github.com/axetroy/go-server/internal/app/user_server/controller/transfer"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/user"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
+	"github.com/axetroy/go-server/internal/library/decimal"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/util"
@@ -18,6 +19,7 @@ import (
 	"github.com/axetroy/mocker"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"sync"
 	"testing"
 )
 
@@ -44,14 +46,14 @@ func TestTo(t *testing.T) {
 
 	res1 := transfer.To(helper.Context{
 		Uid: userFrom.Id,
-	}, input1, signature1)
+	}, input1, signature1, "")
 
 	assert.Equal(t, exception.NotEnoughBalance.Error(), res1.Message)
 	assert.Equal(t, exception.NotEnoughBalance.Code(), res1.Status)
 
 	// 给账户充钱
 	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+		Balance:  decimal.NewFromInt(100),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -71,7 +73,7 @@ func TestTo(t *testing.T) {
 
 	res2 := transfer.To(helper.Context{
 		Uid: userFrom.Id,
-	}, input2, signature2)
+	}, input2, signature2, "")
 	data := schema.TransferLog{}
 
 	assert.Equal(t, "", res2.Message)
@@ -111,7 +113,7 @@ func TestTo(t *testing.T) {
 
 		res := transfer.To(helper.Context{
 			Uid: userFrom.Id,
-		}, input, "Invalid signature")
+		}, input, "Invalid signature", "")
 
 		assert.Equal(t, exception.InvalidSignature.Error(), res.Message)
 		assert.Equal(t, exception.InvalidSignature.Code(), res.Status)
@@ -136,7 +138,7 @@ func TestToRouter(t *testing.T) {
 
 	// 给账户充钱
 	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
-		Balance:  100,
+		Balance:  decimal.NewFromInt(100),
 		Currency: model.WalletCNY,
 	}).Error)
 
@@ -188,3 +190,156 @@ func TestToRouter(t *testing.T) {
 		assert.Equal(t, "0.00000000", toUserWallet.Frozen)
 	}
 }
+
+func TestToWithIdempotencyKey(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userFrom.Username)
+	defer tester.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
+		Balance:  decimal.NewFromInt(100),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	input := transfer.ToParams{
+		Currency: "CNY",
+		To:       userTo.Id,
+		Amount:   "20",
+	}
+
+	b, err := json.Marshal(input)
+
+	assert.Nil(t, err)
+
+	signature, err := util.Signature(string(b))
+
+	assert.Nil(t, err)
+
+	idempotencyKey := util.GenerateId()
+
+	res1 := transfer.To(helper.Context{Uid: userFrom.Id}, input, signature, idempotencyKey)
+	data1 := schema.TransferLog{}
+
+	assert.Equal(t, "", res1.Message)
+	assert.Equal(t, schema.StatusSuccess, res1.Status)
+	assert.Nil(t, res1.Decode(&data1))
+
+	// 重试的请求返回原来的转账记录, 不会重复扣款
+	res2 := transfer.To(helper.Context{Uid: userFrom.Id}, input, signature, idempotencyKey)
+	data2 := schema.TransferLog{}
+
+	assert.Equal(t, "", res2.Message)
+	assert.Equal(t, schema.StatusSuccess, res2.Status)
+	assert.Nil(t, res2.Decode(&data2))
+	assert.Equal(t, data1.Id, data2.Id)
+
+	r := wallet.GetWallet(helper.Context{Uid: userFrom.Id}, "CNY")
+	fromUserWallet := schema.Wallet{}
+
+	assert.Nil(t, r.Decode(&fromUserWallet))
+	assert.Equal(t, "80.00000000", fromUserWallet.Balance)
+
+	// 相同的幂等键用于不同的转账
+	{
+		input := transfer.ToParams{
+			Currency: "CNY",
+			To:       userTo.Id,
+			Amount:   "30",
+		}
+
+		b, _ := json.Marshal(input)
+
+		signature, _ := util.Signature(string(b))
+
+		res := transfer.To(helper.Context{Uid: userFrom.Id}, input, signature, idempotencyKey)
+
+		assert.Equal(t, exception.IdempotencyKeyReused.Error(), res.Message)
+		assert.Equal(t, exception.IdempotencyKeyReused.Code(), res.Status)
+	}
+
+	// 金额相同, 但是币种, 是否需要确认或者备注不同, 也属于不同的转账
+	note := "note"
+
+	for _, input := range []transfer.ToParams{
+		{Currency: "USD", To: userTo.Id, Amount: "20"},
+		{Currency: "CNY", To: userTo.Id, Amount: "20", Pending: true},
+		{Currency: "CNY", To: userTo.Id, Amount: "20", Note: &note},
+	} {
+		b, _ := json.Marshal(input)
+
+		signature, _ := util.Signature(string(b))
+
+		res := transfer.To(helper.Context{Uid: userFrom.Id}, input, signature, idempotencyKey)
+
+		assert.Equal(t, exception.IdempotencyKeyReused.Error(), res.Message)
+		assert.Equal(t, exception.IdempotencyKeyReused.Code(), res.Status)
+	}
+
+	r = wallet.GetWallet(helper.Context{Uid: userFrom.Id}, "CNY")
+
+	assert.Nil(t, r.Decode(&fromUserWallet))
+	assert.Equal(t, "80.00000000", fromUserWallet.Balance)
+}
+
+func TestToConcurrent(t *testing.T) {
+	userFrom, _ := tester.CreateUser()
+	userTo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userFrom.Username)
+	defer tester.DeleteUserByUserName(userTo.Username)
+
+	// 给账户充钱
+	assert.Nil(t, database.Db.Table(wallet.GetTableName("CNY")).Where("id = ?", userFrom.Id).Update(model.Wallet{
+		Balance:  decimal.NewFromInt(100),
+		Currency: model.WalletCNY,
+	}).Error)
+
+	input := transfer.ToParams{
+		Currency: "CNY",
+		To:       userTo.Id,
+		Amount:   "30",
+	}
+
+	b, _ := json.Marshal(input)
+
+	signature, _ := util.Signature(string(b))
+
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		success = 0
+	)
+
+	// 同时发起 10 笔转账, 余额只够转 3 笔
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := transfer.To(helper.Context{Uid: userFrom.Id}, input, signature, "")
+			if res.Status == schema.StatusSuccess {
+				lock.Lock()
+				success++
+				lock.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 3, success)
+
+	r := wallet.GetWallet(helper.Context{Uid: userFrom.Id}, "CNY")
+	fromUserWallet := schema.Wallet{}
+
+	assert.Nil(t, r.Decode(&fromUserWallet))
+	assert.Equal(t, "10.00000000", fromUserWallet.Balance)
+
+	r2 := wallet.GetWallet(helper.Context{Uid: userTo.Id}, "CNY")
+	toUserWallet := schema.Wallet{}
+
+	assert.Nil(t, r2.Decode(&toUserWallet))
+	assert.Equal(t, "90.00000000", toUserWallet.Balance)
+}
@@ -5,13 +5,27 @@ import (
 	"fmt"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
 	"reflect"
 	"strings"
+	"time"
 )
 
 // 获取转账表名
 func GetTransferTableName(currency string) string {
-	return "transfer_log_" + strings.ToLower(currency)
+	return model.TransferLogTableName(currency)
+}
+
+func mapToSchema(model model.TransferLog, d *schema.TransferLog) {
+	d.Id = model.Id
+	d.Currency = model.Currency
+	d.From = model.From
+	d.To = model.To
+	d.Amount = model.Amount.String()
+	d.Status = model.Status
+	d.Note = model.Note
+	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
+	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
 }
 
 type QueryParams struct {
@@ -23,7 +37,8 @@ type QueryParams struct {
 	Status   *model.TransferStatus `json:"status"`   // 转账状态
 }
 
-func GenerateTransferLogSQL(filter QueryParams, limit int, count bool) string {
+// currencies 为需要查询的币种, 通常是币种注册表中的所有币种
+func GenerateTransferLogSQL(filter QueryParams, currencies []string, limit int, count bool) string {
 	suffix := `("deleted_at" IS NULL OR "deleted_at"='0001-01-01 00:00:00')`
 
 	filterArray := make([]string, 0)
@@ -74,8 +89,8 @@ func GenerateTransferLogSQL(filter QueryParams, limit int, count bool) string {
 		selected = "COUNT(*)"
 	}
 
-	for _, tableName := range model.TransferTableNames {
-		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, tableName, filterStr, suffix)
+	for _, currency := range currencies {
+		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, GetTransferTableName(currency), filterStr, suffix)
 		SQLs = append(SQLs, sql)
 	}
 
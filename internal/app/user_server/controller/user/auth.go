@@ -1,20 +1,15 @@
 package user
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/captcha"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/email"
-	"github.com/axetroy/go-server/internal/service/redis"
-	"github.com/axetroy/go-server/internal/service/telephone"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/jinzhu/gorm"
-	"time"
 )
 
 func SendAuthEmail(c helper.Context) (res schema.Response) {
@@ -49,7 +44,7 @@ func SendAuthEmail(c helper.Context) (res schema.Response) {
 
 	tx = database.Db.Begin()
 
-	userInfo := model.User{}
+	userInfo := model.User{Id: c.Uid}
 
 	if err = tx.Where(&userInfo).Find(&userInfo).Error; err != nil {
 		return
@@ -61,26 +56,8 @@ func SendAuthEmail(c helper.Context) (res schema.Response) {
 		return
 	}
 
-	// 生成验证码
-	activationCode := captcha.GenerateEmailCaptcha()
-
-	// 缓存验证码到 redis
-	if err = redis.ClientAuthEmailCode.Set(context.Background(), activationCode, *userInfo.Email, time.Minute*10).Err(); err != nil {
-		return
-	}
-
-	e, err := email.NewMailer()
-
-	if err != nil {
-		return
-	}
-
-	// send email
-	if err = e.SendAuthEmail(*userInfo.Email, activationCode); err != nil {
-		// 邮件没发出去的话，删除redis的key
-		_ = redis.ClientAuthEmailCode.Del(context.Background(), activationCode).Err()
-		return
-	}
+	// 用于解除绑定等需要验证身份的操作
+	err = verifier.Send(verifier.Email(*userInfo.Email), verifier.PurposeBind)
 
 	return
 }
@@ -117,7 +94,7 @@ func SendAuthPhone(c helper.Context) (res schema.Response) {
 
 	tx = database.Db.Begin()
 
-	userInfo := model.User{}
+	userInfo := model.User{Id: c.Uid}
 
 	if err = tx.Where(&userInfo).Find(&userInfo).Error; err != nil {
 		return
@@ -129,19 +106,8 @@ func SendAuthPhone(c helper.Context) (res schema.Response) {
 		return
 	}
 
-	// 生成验证码
-	activationCode := captcha.GeneratePhoneCaptcha()
-
-	// 缓存验证码到 redis
-	if err = redis.ClientAuthPhoneCode.Set(context.Background(), activationCode, *userInfo.Phone, time.Minute*10).Err(); err != nil {
-		return
-	}
-
-	if err = telephone.GetClient().SendAuthCode(*userInfo.Phone, activationCode); err != nil {
-		// 如果发送失败，则删除
-		_ = redis.ClientAuthPhoneCode.Del(context.Background(), activationCode).Err()
-		return
-	}
+	// 用于解除绑定等需要验证身份的操作
+	err = verifier.Send(verifier.Phone(*userInfo.Phone), verifier.PurposeBind)
 
 	return
 }
@@ -6,11 +6,11 @@ import (
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 )
 
@@ -74,12 +74,16 @@ func UpdatePassword(c helper.Context, input UpdatePasswordParams) (res schema.Re
 	}
 
 	// 验证密码是否正确
-	if userInfo.Password != util.GeneratePassword(input.OldPassword) {
+	if !password.Verify(input.OldPassword, userInfo.Password) {
 		err = exception.InvalidPassword
 		return
 	}
 
-	newPassword := util.GeneratePassword(input.NewPassword)
+	newPassword, err := password.Generate(input.NewPassword)
+
+	if err != nil {
+		return
+	}
 
 	if err = tx.Model(&userInfo).Update(model.User{Password: newPassword}).Error; err != nil {
 		return
@@ -149,7 +153,11 @@ func UpdatePasswordByAdmin(c helper.Context, userId string, input UpdatePassword
 		return
 	}
 
-	newPassword := util.GeneratePassword(input.NewPassword)
+	newPassword, err := password.Generate(input.NewPassword)
+
+	if err != nil {
+		return
+	}
 
 	if err = tx.Model(&userInfo).Update(model.User{Password: newPassword}).Error; err != nil {
 		return
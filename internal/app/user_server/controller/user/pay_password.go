@@ -2,21 +2,17 @@
 package user
 
 import (
-	"context"
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
-	"github.com/axetroy/go-server/internal/service/email"
-	"github.com/axetroy/go-server/internal/service/redis"
-	"github.com/axetroy/go-server/internal/service/telephone"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/jinzhu/gorm"
-	"time"
 )
 
 type SetPayPasswordParams struct {
@@ -34,9 +30,16 @@ type ResetPayPasswordParams struct {
 	NewPassword string `json:"new_password" validate:"required,numeric,len=6" comment:"新密码"` // 新的交易密码
 }
 
-func GenerateResetPayPasswordCode(uid string) string {
-	codeId := "reset-pay-" + util.GenerateId() + uid
-	return util.MD5(codeId)
+// 接收重置交易密码的验证码的对象, 优先使用邮箱
+func resetPayPasswordTarget(userInfo model.User) (verifier.Target, error) {
+	if userInfo.Email != nil {
+		return verifier.Email(*userInfo.Email), nil
+	} else if userInfo.Phone != nil {
+		return verifier.Phone(*userInfo.Phone), nil
+	}
+
+	// 无效的用户
+	return verifier.Target{}, exception.NoData
 }
 
 func SetPayPassword(c helper.Context, input SetPayPasswordParams) (res schema.Response) {
@@ -94,7 +97,11 @@ func SetPayPassword(c helper.Context, input SetPayPasswordParams) (res schema.Re
 		return
 	}
 
-	newPassword := util.GeneratePassword(input.Password)
+	newPassword, err := password.Generate(input.Password)
+
+	if err != nil {
+		return
+	}
 
 	// 更新交易密码
 	if err = database.Db.Model(userInfo).Update("pay_password", newPassword).Error; err != nil {
@@ -170,15 +177,17 @@ func UpdatePayPassword(c helper.Context, input UpdatePayPasswordParams) (res sch
 		return
 	}
 
-	oldPwd := util.GeneratePassword(input.OldPassword)
-
 	// 旧密码不匹配
-	if *userInfo.PayPassword != oldPwd {
+	if !password.Verify(input.OldPassword, *userInfo.PayPassword) {
 		err = exception.InvalidPassword
 		return
 	}
 
-	newPwd := util.GeneratePassword(input.NewPassword)
+	newPwd, err := password.Generate(input.NewPassword)
+
+	if err != nil {
+		return
+	}
 
 	// 更新交易密码
 	if err = database.Db.Model(userInfo).Update("pay_password", newPwd).Error; err != nil {
@@ -238,33 +247,14 @@ func SendResetPayPassword(c helper.Context) (res schema.Response) {
 		return
 	}
 
-	// 生成重置码
-	var resetCode = GenerateResetPayPasswordCode(userInfo.Id)
+	target, err := resetPayPasswordTarget(userInfo)
 
-	// redis缓存重置码
-	if err = redis.ClientResetCode.Set(context.Background(), resetCode, userInfo.Id, time.Minute*10).Err(); err != nil {
+	if err != nil {
 		return
 	}
 
-	if userInfo.Email != nil {
-		// 发送邮件
-		go func() {
-			if e, err := email.NewMailer(); err == nil {
-				_ = e.SendForgotTradePasswordEmail(*userInfo.Email, resetCode)
-			}
-		}()
-	} else if userInfo.Phone != nil {
-		go func() {
-			if err = telephone.GetClient().SendResetPasswordCode(*userInfo.Phone, resetCode); err != nil {
-				// 如果发送失败，则删除
-				_ = redis.ClientAuthPhoneCode.Del(context.Background(), resetCode).Err()
-				return
-			}
-		}()
-	} else {
-		// 无效的用户
-		err = exception.NoData
-	}
+	// 发送重置码
+	err = verifier.Send(target, verifier.PurposeResetPayPassword)
 
 	return
 }
@@ -279,7 +269,6 @@ func ResetPayPassword(c helper.Context, input ResetPayPasswordParams) (res schem
 	var (
 		err error
 		tx  *gorm.DB
-		uid string
 	)
 
 	defer func() {
@@ -327,24 +316,28 @@ func ResetPayPassword(c helper.Context, input ResetPayPasswordParams) (res schem
 		return
 	}
 
-	if uid, err = redis.ClientResetCode.Get(context.Background(), input.Code).Result(); err != nil {
-		err = exception.InvalidResetCode
+	target, err := resetPayPasswordTarget(userInfo)
+
+	if err != nil {
 		return
 	}
 
-	// 即使有了重置码，不是自己的账号也不能用
-	if userInfo.Id != uid {
-		err = exception.NoPermission
+	// 重置码只能发送到自己的邮箱或手机, 使用之后立即失效
+	if err = verifier.Verify(target, verifier.PurposeResetPayPassword, input.Code); err != nil {
+		if verifier.IsInvalid(err) {
+			err = exception.InvalidResetCode
+		}
 		return
 	}
 
 	// 更新交易密码
-	if err = database.Db.Model(userInfo).Update("pay_password", input.NewPassword).Error; err != nil {
+	var hashed string
+
+	if hashed, err = password.Generate(input.NewPassword); err != nil {
 		return
 	}
 
-	// 重置密码之后，删除重置码
-	if _, err = redis.ClientResetCode.Del(context.Background(), input.Code).Result(); err != nil {
+	if err = tx.Model(&userInfo).Update("pay_password", hashed).Error; err != nil {
 		return
 	}
 
@@ -2,18 +2,18 @@
 package user_test
 
 import (
-	"context"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/user"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
-	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/verifier"
 	"github.com/axetroy/go-server/tester"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"testing"
-	"time"
 )
 
 func TestSetPayPassword(t *testing.T) {
@@ -205,11 +205,15 @@ func TestResetPayPassword(t *testing.T) {
 		assert.False(t, testUser.PayPassword)
 	}
 
+	// 重置码会发送到绑定的手机号
+	phone := "13800138000"
+
+	assert.Nil(t, database.Db.Model(&model.User{Id: testUser.Id}).Update("phone", phone).Error)
+
 	// 生成重置码
-	resetCode := user.GenerateResetPayPasswordCode(testUser.Id)
+	resetCode, err := verifier.Issue(verifier.Phone(phone), verifier.PurposeResetPayPassword)
 
-	// redis缓存重置码
-	assert.Nil(t, redis.ClientResetCode.Set(context.Background(), resetCode, testUser.Id, time.Minute*10).Err())
+	assert.Nil(t, err)
 
 	{
 		// 2. 重置交易密码失败, 因为此时还没有交易密码
@@ -259,11 +263,37 @@ func TestResetPayPassword(t *testing.T) {
 			Uid: testUser.Id,
 		}, user.ResetPayPasswordParams{
 			Code:        resetCode,
-			NewPassword: "123123",
+			NewPassword: "321321",
 		})
 
 		assert.Equal(t, schema.StatusSuccess, r.Status)
 		assert.Equal(t, "", r.Message)
 		assert.Equal(t, nil, r.Data)
 	}
+
+	{
+		// 6. 重置码只能使用一次
+		r := user.ResetPayPassword(helper.Context{
+			Uid: testUser.Id,
+		}, user.ResetPayPasswordParams{
+			Code:        resetCode,
+			NewPassword: "123123",
+		})
+
+		assert.Equal(t, exception.InvalidResetCode.Code(), r.Status)
+		assert.Equal(t, exception.InvalidResetCode.Error(), r.Message)
+	}
+
+	{
+		// 7. 使用新的交易密码修改交易密码
+		r := user.UpdatePayPassword(helper.Context{
+			Uid: testUser.Id,
+		}, user.UpdatePayPasswordParams{
+			OldPassword: "321321",
+			NewPassword: "123123",
+		})
+
+		assert.Equal(t, schema.StatusSuccess, r.Status)
+		assert.Equal(t, "", r.Message)
+	}
 }
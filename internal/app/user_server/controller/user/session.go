@@ -0,0 +1,139 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"sort"
+	"time"
+)
+
+func sessionToSchema(info authentication.SessionInfo, currentId string) schema.Session {
+	s := schema.Session{
+		Id:        info.Id,
+		Ip:        info.Ip,
+		Device:    info.Device,
+		Current:   info.Id == currentId,
+		CreatedAt: info.CreatedAt.Format(time.RFC3339Nano),
+		ExpiredAt: info.ExpiredAt.Format(time.RFC3339Nano),
+	}
+
+	if !info.LastSeenAt.IsZero() {
+		s.LastSeenAt = info.LastSeenAt.Format(time.RFC3339Nano)
+	}
+
+	return s
+}
+
+// 获取用户所有有效的会话, 最近访问的排在前面
+func GetSessions(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.Session, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list, err := authentication.Gateway(false).Sessions(c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt.After(list[j].LastSeenAt)
+	})
+
+	for _, info := range list {
+		data = append(data, sessionToSchema(info, c.SessionId))
+	}
+
+	return
+}
+
+// 注销某个会话, 对应的设备需要重新登陆
+func RevokeSession(c helper.Context, sessionId string) (res schema.Response) {
+	var (
+		err error
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	err = authentication.Gateway(false).RemoveSession(c.Uid, sessionId)
+
+	return
+}
+
+// 注销除了当前会话之外的所有会话
+func RevokeOtherSessions(c helper.Context) (res schema.Response) {
+	var (
+		err error
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	_, err = authentication.Gateway(false).RemoveAllSessions(c.Uid, c.SessionId)
+
+	return
+}
+
+var GetSessionsRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetSessions(helper.NewContext(&c))
+	})
+})
+
+var RevokeSessionRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return RevokeSession(helper.NewContext(&c), c.Param("session_id"))
+	})
+})
+
+var RevokeOtherSessionsRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return RevokeOtherSessions(helper.NewContext(&c))
+	})
+})
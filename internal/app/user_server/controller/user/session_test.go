@@ -0,0 +1,116 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/axetroy/go-server/tester"
+	"github.com/axetroy/mocker"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func getSessions(t *testing.T, tokenString string) (res schema.Response, list []schema.Session) {
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + tokenString,
+	}
+
+	r := tester.HttpUser.Get("/v1/user/session", nil, &header)
+
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.Nil(t, json.Unmarshal(r.Body.Bytes(), &res))
+
+	if res.Status == schema.StatusSuccess {
+		assert.Nil(t, res.Decode(&list))
+	}
+
+	return
+}
+
+func TestSignOutRouter(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + userInfo.Token,
+	}
+
+	r := tester.HttpUser.Get("/v1/user/signout", nil, &header)
+
+	res := schema.Response{}
+
+	assert.Nil(t, json.Unmarshal(r.Body.Bytes(), &res))
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+
+	// 登出之后 token 失效
+	res, _ = getSessions(t, userInfo.Token)
+
+	assert.Equal(t, exception.InvalidToken.Code(), res.Status)
+}
+
+func TestRevokeSessionRouter(t *testing.T) {
+	userInfo, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(userInfo.Username)
+
+	// 在另外一个设备上登陆
+	r := auth.SignIn(helper.Context{}, auth.SignInParams{
+		Account:  userInfo.Username,
+		Password: "123123",
+	})
+
+	other := schema.ProfileWithToken{}
+
+	assert.Equal(t, schema.StatusSuccess, r.Status)
+	assert.Nil(t, r.Decode(&other))
+
+	res, list := getSessions(t, userInfo.Token)
+
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Len(t, list, 2)
+
+	var otherId string
+
+	for _, s := range list {
+		if !s.Current {
+			otherId = s.Id
+		}
+	}
+
+	assert.NotEmpty(t, otherId)
+
+	header := mocker.Header{
+		"Authorization": token.Prefix + " " + userInfo.Token,
+	}
+
+	// 注销另外一个会话
+	r2 := tester.HttpUser.Delete("/v1/user/session/"+otherId, nil, &header)
+
+	res2 := schema.Response{}
+
+	assert.Nil(t, json.Unmarshal(r2.Body.Bytes(), &res2))
+	assert.Equal(t, schema.StatusSuccess, res2.Status)
+
+	res, _ = getSessions(t, other.Token)
+	assert.Equal(t, exception.InvalidToken.Code(), res.Status)
+
+	// 当前的会话不受影响
+	res, list = getSessions(t, userInfo.Token)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+	assert.Len(t, list, 1)
+	assert.True(t, list[0].Current)
+
+	// 已经注销的会话不存在了
+	r3 := tester.HttpUser.Delete("/v1/user/session/"+otherId, nil, &header)
+
+	res3 := schema.Response{}
+
+	assert.Nil(t, json.Unmarshal(r3.Body.Bytes(), &res3))
+	assert.Equal(t, exception.SessionNotExist.Code(), res3.Status)
+}
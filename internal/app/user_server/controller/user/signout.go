@@ -2,12 +2,62 @@
 package user
 
 import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
 	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/middleware"
+	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/database"
 )
 
-var SignOut = router.Handler(func(c router.Context) {
+// 登出, 当前的 token 立即失效
+func SignOut(c helper.Context, tokenString string) (res schema.Response) {
+	var (
+		err error
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	if err = authentication.Gateway(false).Remove(tokenString); err != nil {
+		return
+	}
+
+	// 写入登出记录
+	loginLog := model.LoginLog{
+		Uid:     c.Uid,                              // 用户ID
+		Type:    model.LoginLogTypeUserName,         // 默认用户名登陆
+		Command: model.LoginLogCommandLogoutSuccess, // 登出成功
+		Client:  c.UserAgent,                        // 用户的 userAgent
+		LastIp:  c.Ip,                               // 用户的IP
+	}
+
+	if err = database.Db.Create(&loginLog).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+var SignOutRouter = router.Handler(func(c router.Context) {
 	c.ResponseFunc(nil, func() schema.Response {
-		return schema.Response{}
+		tokenString, _ := c.GetContext(middleware.ContextTokenField).(string)
+
+		return SignOut(helper.NewContext(&c), tokenString)
 	})
 })
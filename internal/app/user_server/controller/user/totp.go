@@ -0,0 +1,279 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/jinzhu/gorm"
+)
+
+type TOTPCodeParams struct {
+	Code string `json:"code" validate:"required,numeric,len=6" comment:"动态密码"` // 身份验证器上的动态密码
+}
+
+type DisableTOTPParams struct {
+	Code         string `json:"code" validate:"omitempty,numeric,len=6" comment:"动态密码"`  // 身份验证器上的动态密码
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=16" comment:"恢复码"` // 丢失身份验证器时可以使用恢复码
+}
+
+// 读取用户双重身份认证的设置
+func totpAccount(tx *gorm.DB, id string) (*totp.Account, error) {
+	userInfo := model.User{Id: id}
+
+	if err := tx.Where(&userInfo).Last(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.UserNotExist
+		}
+		return nil, err
+	}
+
+	return &totp.Account{
+		Model:   &model.User{},
+		Id:      userInfo.Id,
+		Name:    userInfo.Username,
+		Secret:  userInfo.Secret,
+		Enabled: userInfo.EnableTOTP,
+	}, nil
+}
+
+// 开始设置双重身份认证, 生成新的密钥
+// 需要用身份验证器扫码之后, 再调用 EnableTOTP 确认开启
+func EnrollTOTP(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPEnroll
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	enrollment, err := account.Enroll(tx)
+
+	if err != nil {
+		return
+	}
+
+	data.Secret = enrollment.Secret
+	data.URI = enrollment.URI
+	data.QRCode = enrollment.QRCode
+
+	return
+}
+
+// 用第一个动态密码确认开启双重身份认证, 并生成恢复码
+func EnableTOTP(c helper.Context, input TOTPCodeParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPRecoveryCodes
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	data.Codes, err = account.Enable(tx, input.Code)
+
+	return
+}
+
+// 关闭双重身份认证, 需要提供动态密码或者恢复码
+func DisableTOTP(c helper.Context, input DisableTOTPParams) (res schema.Response) {
+	var (
+		err error
+		tx  *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, nil, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	err = account.Disable(tx, input.Code, input.RecoveryCode)
+
+	return
+}
+
+// 重新生成恢复码, 旧的恢复码全部失效
+func RegenerateTOTPRecoveryCodes(c helper.Context, input TOTPCodeParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.TOTPRecoveryCodes
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	account, err := totpAccount(tx, c.Uid)
+
+	if err != nil {
+		return
+	}
+
+	data.Codes, err = account.RegenerateRecoveryCodes(tx, input.Code)
+
+	return
+}
+
+var EnrollTOTPRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return EnrollTOTP(helper.NewContext(&c))
+	})
+})
+
+var EnableTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input TOTPCodeParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return EnableTOTP(helper.NewContext(&c), input)
+	})
+})
+
+var DisableTOTPRouter = router.Handler(func(c router.Context) {
+	var (
+		input DisableTOTPParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return DisableTOTP(helper.NewContext(&c), input)
+	})
+})
+
+var RegenerateTOTPRecoveryCodesRouter = router.Handler(func(c router.Context) {
+	var (
+		input TOTPCodeParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RegenerateTOTPRecoveryCodes(helper.NewContext(&c), input)
+	})
+})
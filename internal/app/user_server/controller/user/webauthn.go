@@ -0,0 +1,192 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package user
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/helper"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/library/validator"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/webauthn"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type RenameWebAuthnParams struct {
+	Name string `json:"name" validate:"required,max=32" comment:"名称"` // 新的名称
+}
+
+func webAuthnToSchema(c model.WebAuthnCredential) schema.WebAuthnCredential {
+	s := schema.WebAuthnCredential{
+		Id:         c.Id,
+		Name:       c.Name,
+		AAGUID:     c.AAGUID,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt.Format(time.RFC3339Nano),
+		UpdatedAt:  c.UpdatedAt.Format(time.RFC3339Nano),
+	}
+
+	if s.Transports == nil {
+		s.Transports = []string{}
+	}
+
+	if c.LastUsedAt != nil {
+		t := c.LastUsedAt.Format(time.RFC3339Nano)
+		s.LastUsedAt = &t
+	}
+
+	return s
+}
+
+// 获取已注册的通行密钥
+func GetWebAuthnList(c helper.Context) (res schema.Response) {
+	var (
+		err  error
+		data = make([]schema.WebAuthnCredential, 0)
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	list, err := webauthn.Credentials(database.Db, c.Uid, false)
+
+	if err != nil {
+		return
+	}
+
+	for _, info := range list {
+		data = append(data, webAuthnToSchema(info))
+	}
+
+	return
+}
+
+// 重命名通行密钥
+func RenameWebAuthn(c helper.Context, id string, input RenameWebAuthnParams) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	// 参数校验
+	if err = validator.ValidateStruct(input); err != nil {
+		return
+	}
+
+	tx = database.Db.Begin()
+
+	info, err := webauthn.Rename(tx, c.Uid, false, id, input.Name)
+
+	if err != nil {
+		return
+	}
+
+	data = webAuthnToSchema(*info)
+
+	return
+}
+
+// 删除通行密钥
+func DeleteWebAuthn(c helper.Context, id string) (res schema.Response) {
+	var (
+		err  error
+		data schema.WebAuthnCredential
+		tx   *gorm.DB
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = exception.Unknown
+			}
+		}
+
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+
+		helper.Response(&res, data, nil, err)
+	}()
+
+	tx = database.Db.Begin()
+
+	info, err := webauthn.Delete(tx, c.Uid, false, id)
+
+	if err != nil {
+		return
+	}
+
+	data = webAuthnToSchema(*info)
+
+	return
+}
+
+var GetWebAuthnListRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return GetWebAuthnList(helper.NewContext(&c))
+	})
+})
+
+var RenameWebAuthnRouter = router.Handler(func(c router.Context) {
+	var (
+		input RenameWebAuthnParams
+	)
+
+	c.ResponseFunc(c.ShouldBindJSON(&input), func() schema.Response {
+		return RenameWebAuthn(helper.NewContext(&c), c.Param("webauthn_id"), input)
+	})
+})
+
+var DeleteWebAuthnRouter = router.Handler(func(c router.Context) {
+	c.ResponseFunc(nil, func() schema.Response {
+		return DeleteWebAuthn(helper.NewContext(&c), c.Param("webauthn_id"))
+	})
+})
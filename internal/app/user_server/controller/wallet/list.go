@@ -8,6 +8,7 @@ import (
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/jinzhu/gorm"
 )
@@ -55,9 +56,15 @@ func GetWallets(c helper.Context) (res schema.Response) {
 		return
 	}
 
+	currencies, err := currency.Codes(tx)
+
+	if err != nil {
+		return
+	}
+
 	sql := GenerateWalletSQL(QueryParams{
 		Id: &userInfo.Id,
-	}, 100, false)
+	}, currencies, 100, false)
 
 	if err = tx.Raw(sql).Scan(&list).Error; err != nil {
 		return
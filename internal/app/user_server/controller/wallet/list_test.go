@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/wallet"
 	"github.com/axetroy/go-server/internal/library/helper"
-	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/axetroy/go-server/internal/service/token"
 	"github.com/axetroy/go-server/tester"
 	"github.com/axetroy/mocker"
@@ -24,7 +25,10 @@ func TestGetWallets(t *testing.T) {
 	assert.Equal(t, schema.StatusSuccess, r.Status)
 	assert.Equal(t, "", r.Message)
 
-	assert.Len(t, r.Data, len(model.WalletTableNames))
+	codes, err := currency.Codes(database.Db)
+
+	assert.Nil(t, err)
+	assert.Len(t, r.Data, len(codes))
 
 	list := make([]schema.Wallet, 0)
 	assert.Nil(t, r.Decode(&list))
@@ -8,21 +8,11 @@ import (
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/database"
 	"github.com/jinzhu/gorm"
-	"strings"
 )
 
-func IsValidWallet(walletName string) bool {
-	for _, validWallet := range model.Wallets {
-		// 有效币种验证忽略大小写
-		if validWallet == strings.ToUpper(walletName) {
-			return true
-		}
-	}
-	return false
-}
-
 func GetWallet(c helper.Context, currencyName string) (res schema.Response) {
 	var (
 		err  error
@@ -69,13 +59,14 @@ func GetWallet(c helper.Context, currencyName string) (res schema.Response) {
 		Id: userInfo.Id,
 	}
 
-	// 检查是否是有效的钱包
-	if !IsValidWallet(strings.ToUpper(currencyName)) {
-		err = exception.InvalidWallet
+	// 检查是否是有效的钱包, 币种忽略大小写
+	currencyInfo, err := currency.Get(tx, currencyName)
+
+	if err != nil {
 		return
 	}
 
-	if err = tx.Table("wallet_"+strings.ToLower(currencyName)).Where("id = ?", c.Uid).Scan(&walletInfo).Error; err != nil {
+	if err = tx.Table(GetTableName(currencyInfo.Code)).Where("id = ?", c.Uid).Scan(&walletInfo).Error; err != nil {
 		return
 	}
 
@@ -12,14 +12,14 @@ import (
 )
 
 func GetTableName(currency string) string {
-	return "wallet_" + strings.ToLower(currency)
+	return model.WalletTableName(currency)
 }
 
 func mapToSchema(model model.Wallet, d *schema.Wallet) {
 	d.Id = model.Id
 	d.Currency = model.Currency
-	d.Balance = util.FloatToStr(model.Balance)
-	d.Frozen = util.FloatToStr(model.Frozen)
+	d.Balance = model.Balance.String()
+	d.Frozen = model.Frozen.String()
 	d.CreatedAt = model.CreatedAt.Format(time.RFC3339Nano)
 	d.UpdatedAt = model.UpdatedAt.Format(time.RFC3339Nano)
 }
@@ -29,7 +29,8 @@ type QueryParams struct {
 	Currency *string `json:"currency"` // 钱包币种
 }
 
-func GenerateWalletSQL(filter QueryParams, limit int, count bool) string {
+// currencies 为需要查询的币种, 通常是币种注册表中的所有币种
+func GenerateWalletSQL(filter QueryParams, currencies []string, limit int, count bool) string {
 	suffix := `("deleted_at" IS NULL OR "deleted_at"='0001-01-01 00:00:00')`
 
 	filterArray := make([]string, 0)
@@ -80,8 +81,8 @@ func GenerateWalletSQL(filter QueryParams, limit int, count bool) string {
 		selected = "COUNT(*)"
 	}
 
-	for _, tableName := range model.WalletTableNames {
-		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, tableName, filterStr, suffix)
+	for _, currency := range currencies {
+		sql := fmt.Sprintf(`SELECT %s FROM "%s" %s %s`, selected, GetTableName(currency), filterStr, suffix)
 		SQLs = append(SQLs, sql)
 	}
 
@@ -8,6 +8,7 @@ import (
 	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/banner"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/email"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/exchange"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/finance"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/help"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/invite"
@@ -15,6 +16,7 @@ import (
 	"github.com/axetroy/go-server/internal/app/user_server/controller/news"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/notification"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/oauth2"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/oidc"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/report"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/signature"
 	"github.com/axetroy/go-server/internal/app/user_server/controller/transfer"
@@ -24,10 +26,12 @@ import (
 	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/middleware"
 	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/pkg/rate_limit"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
 	"net/http"
+	"time"
 )
 
 var UserRouter *iris.Application
@@ -43,13 +47,19 @@ func init() {
 		c.JSON(fmt.Errorf("%d %s", code, http.StatusText(code)), nil, nil)
 	}))
 
+	// 用于离线验证用户 token 的公钥
+	app.Get("/.well-known/jwks.json", auth.JWKSRouter)
+
+	// 作为身份提供方 (OpenID Connect) 的发现文档
+	app.Get("/.well-known/openid-configuration", oidc.DiscoveryRouter)
+
 	v1 := app.Party("/v1").AllowMethods(iris.MethodOptions)
 
 	{
 		v1.Use(recover.New())
 		v1.Use(middleware.Common())
 		v1.Use(middleware.CORS())
-		v1.Use(middleware.RateLimit(30))
+		v1.Use(middleware.RateLimit("user", rate_limit.Policy{Limit: 5, Period: time.Second, Burst: 30})) // 每个 IP 每秒 5 个请求, 最多允许 30 个请求的突发
 
 		if config.Common.Mode != "production" {
 			v1.Use(logger.New())
@@ -64,20 +74,27 @@ func init() {
 
 		userAuthMiddleware := middleware.AuthenticateNew(false) // 用户Token的中间件
 
+		// 资金操作按照用户限流, 每个用户每秒 1 个请求, 最多允许 5 个请求的突发. 必须放在身份认证的中间件后面
+		tradeRateLimit := middleware.RateLimit("trade", rate_limit.Policy{Limit: 1, Period: time.Second, Burst: 5}, middleware.RateLimitByUid)
+
 		// 认证类
 		{
 			authRouter := v1.Party("/auth")
-			authRouter.Post("/signup/email", auth.SignUpWithEmailRouter)   // 注册账号，通过邮箱+验证码
-			authRouter.Post("/signup/phone", auth.SignUpWithPhoneRouter)   // 注册账号，通过手机+验证码
-			authRouter.Post("/signup", auth.SignUpWithUsernameRouter)      // 注册账号, 通过用户名+密码
-			authRouter.Post("/signin/email", auth.SignInWithEmailRouter)   // 邮箱+验证码 登陆
-			authRouter.Post("/signin/phone", auth.SignInWithPhoneRouter)   // 手机+验证码 登陆
-			authRouter.Post("/signin/wechat", auth.SignInWithWechatRouter) // 微信帐号登陆
-			authRouter.Post("/signin/oauth2", auth.SignInWithOAuthRouter)  // oAuth 码登陆
-			authRouter.Post("/signin", auth.SignInRouter)                  // 登陆账号
-			authRouter.Put("/password/reset", auth.ResetPasswordRouter)    // 密码重置
-			authRouter.Post("/code/email", auth.SendEmailAuthCodeRouter)   // 发送邮箱验证码，验证邮箱是否为用户所有 TODO: 缺少测试用例
-			authRouter.Post("/code/phone", auth.SendPhoneAuthCodeRouter)   // 发送手机验证码，验证手机是否为用户所有 TODO: 缺少测试用例
+			authRouter.Post("/signup/email", auth.SignUpWithEmailRouter)                      // 注册账号，通过邮箱+验证码
+			authRouter.Post("/signup/phone", auth.SignUpWithPhoneRouter)                      // 注册账号，通过手机+验证码
+			authRouter.Post("/signup", auth.SignUpWithUsernameRouter)                         // 注册账号, 通过用户名+密码
+			authRouter.Post("/signin/email", auth.SignInWithEmailRouter)                      // 邮箱+验证码 登陆
+			authRouter.Post("/signin/phone", auth.SignInWithPhoneRouter)                      // 手机+验证码 登陆
+			authRouter.Post("/signin/wechat", auth.SignInWithWechatRouter)                    // 微信帐号登陆
+			authRouter.Post("/signin/oauth2", auth.SignInWithOAuthRouter)                     // oAuth 码登陆
+			authRouter.Post("/signin", auth.SignInRouter)                                     // 登陆账号
+			authRouter.Post("/signin/totp", auth.SignInWithTOTPRouter)                        // 开启了双重身份认证的帐号, 登陆的第二步
+			authRouter.Post("/signin/webauthn/options", auth.SignInWithWebAuthnOptionsRouter) // 通行密钥登陆的第一步, 获取认证参数
+			authRouter.Post("/signin/webauthn", auth.SignInWithWebAuthnRouter)                // 通行密钥登陆的第二步, 提交认证器的签名
+			authRouter.Post("/token/refresh", auth.RefreshTokenRouter)                        // 使用刷新令牌换取新的身份令牌
+			authRouter.Put("/password/reset", auth.ResetPasswordRouter)                       // 密码重置
+			authRouter.Post("/code/email", auth.SendEmailAuthCodeRouter)                      // 发送邮箱验证码，验证邮箱是否为用户所有 TODO: 缺少测试用例
+			authRouter.Post("/code/phone", auth.SendPhoneAuthCodeRouter)                      // 发送手机验证码，验证手机是否为用户所有 TODO: 缺少测试用例
 		}
 
 		// oAuth2 认证
@@ -87,11 +104,26 @@ func init() {
 			oAuthRouter.Get("/{provider}/callback", oauth2.AuthCallbackRouter) // 认证成功后，跳转回来的回调地址
 		}
 
+		// 作为身份提供方, 让其他应用使用本平台的帐号登陆
+		{
+			oidcRouter := v1.Party("/oauth")
+			oidcRouter.Get("/authorize", userAuthMiddleware, oidc.GetAuthorizeRouter)               // 获取授权页面需要的信息
+			oidcRouter.Post("/authorize", userAuthMiddleware, oidc.AuthorizeRouter)                 // 同意/拒绝授权, 返回跳转回应用的地址
+			oidcRouter.Get("/consent", userAuthMiddleware, oidc.GetConsentListRouter)               // 获取已授权的应用
+			oidcRouter.Delete("/consent/{client_id}", userAuthMiddleware, oidc.RevokeConsentRouter) // 撤销给应用的授权
+			oidcRouter.Post("/token", oidc.TokenRouter)                                             // 应用使用授权码/刷新令牌换取 token
+			oidcRouter.Get("/userinfo", oidc.UserInfoRouter)                                        // 应用获取用户信息
+			oidcRouter.Post("/userinfo", oidc.UserInfoRouter)                                       // 应用获取用户信息
+			oidcRouter.Post("/introspect", oidc.IntrospectRouter)                                   // 查询 access token 的状态
+			oidcRouter.Post("/revoke", oidc.RevokeRouter)                                           // 撤销 token
+			oidcRouter.Get("/jwks", oidc.JWKSRouter)                                                // 验证 ID Token 的公钥
+		}
+
 		// 用户类
 		{
 			userRouter := v1.Party("/user")
 			userRouter.Use(userAuthMiddleware)
-			userRouter.Get("/signout", user.SignOut)                                                                              // 用户登出
+			userRouter.Get("/signout", user.SignOutRouter)                                                                        // 用户登出
 			userRouter.Get("/profile", user.GetProfileRouter)                                                                     // 获取用户详细信息
 			userRouter.Put("/profile", middleware.Permission(*accession.ProfileUpdate), user.UpdateProfileRouter)                 // 更新用户资料
 			userRouter.Put("/password", middleware.Permission(*accession.PasswordUpdate), user.UpdatePasswordRouter)              // 更新登陆密码
@@ -100,6 +132,33 @@ func init() {
 			userRouter.Put("/password2/reset", middleware.Permission(*accession.Password2Reset), user.ResetPayPasswordRouter)     // 重置交易密码
 			userRouter.Get("/password2/reset", middleware.Permission(*accession.Password2Reset), user.SendResetPayPasswordRouter) // 发送重置交易密码的邮件/短信 			// 上传用户头像
 
+			// 会话管理
+			{
+				sessionRouter := userRouter.Party("/session")
+				sessionRouter.Get("", user.GetSessionsRouter)                   // 获取所有登陆中的会话
+				sessionRouter.Delete("", user.RevokeOtherSessionsRouter)        // 注销除当前会话之外的所有会话
+				sessionRouter.Delete("/{session_id}", user.RevokeSessionRouter) // 注销某个会话
+			}
+
+			// 双重身份认证
+			{
+				totpRouter := userRouter.Party("/totp")
+				totpRouter.Post("", user.EnrollTOTPRouter)                           // 生成新的密钥, 返回 otpauth:// 地址和二维码
+				totpRouter.Post("/enable", user.EnableTOTPRouter)                    // 用第一个动态密码确认开启, 返回恢复码
+				totpRouter.Post("/disable", user.DisableTOTPRouter)                  // 关闭双重身份认证
+				totpRouter.Post("/recovery", user.RegenerateTOTPRecoveryCodesRouter) // 重新生成恢复码
+			}
+
+			// 通行密钥
+			{
+				webAuthnRouter := userRouter.Party("/webauthn")
+				webAuthnRouter.Get("", user.GetWebAuthnListRouter)                  // 获取已注册的通行密钥
+				webAuthnRouter.Post("/options", auth.RegisterWebAuthnOptionsRouter) // 注册的第一步, 获取注册参数
+				webAuthnRouter.Post("", auth.RegisterWebAuthnRouter)                // 注册的第二步, 提交认证器创建的凭证
+				webAuthnRouter.Put("/{webauthn_id}", user.RenameWebAuthnRouter)     // 重命名通行密钥
+				webAuthnRouter.Delete("/{webauthn_id}", user.DeleteWebAuthnRouter)  // 删除通行密钥
+			}
+
 			// 验证码类
 			{
 				authRouter := userRouter.Party("/auth")
@@ -152,9 +211,21 @@ func init() {
 		{
 			transferRouter := v1.Party("/transfer")
 			transferRouter.Use(userAuthMiddleware)
-			transferRouter.Get("", transfer.GetHistoryRouter)                                                                       // 获取我的转账记录
-			transferRouter.Post("", middleware.Permission(*accession.DoTransfer), middleware.AuthPayPasswordNew, transfer.ToRouter) // 转账给某人
-			transferRouter.Get("/{transfer_id}", transfer.GetDetailRouter)                                                          // 获取单条转账详情
+			transferRouter.Get("", transfer.GetHistoryRouter)                                                                                       // 获取我的转账记录
+			transferRouter.Post("", tradeRateLimit, middleware.Permission(*accession.DoTransfer), middleware.AuthPayPasswordNew, transfer.ToRouter) // 转账给某人
+			transferRouter.Get("/{transfer_id}", transfer.GetDetailRouter)                                                                          // 获取单条转账详情
+			transferRouter.Put("/{transfer_id}/accept", transfer.AcceptRouter)                                                                      // 收款方接受待确认的转账
+			transferRouter.Put("/{transfer_id}/reject", transfer.RejectRouter)                                                                      // 收款方拒绝待确认的转账
+		}
+
+		// 币种兑换
+		{
+			exchangeRouter := v1.Party("/exchange")
+			exchangeRouter.Get("/rate", exchange.GetRatesRouter) // 获取可用的汇率列表
+			exchangeRouter.Use(userAuthMiddleware)
+			exchangeRouter.Get("", exchange.GetHistoryRouter)                                              // 获取我的兑换记录
+			exchangeRouter.Post("/quote", tradeRateLimit, exchange.QuoteRouter)                            // 获取兑换报价, 报价的汇率在有效期内锁定
+			exchangeRouter.Post("", tradeRateLimit, middleware.AuthPayPasswordNew, exchange.ExecuteRouter) // 按照报价进行兑换
 		}
 
 		// 财务日志
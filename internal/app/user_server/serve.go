@@ -5,7 +5,9 @@ import (
 	"context"
 	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/oauth"
 	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/token_key"
 	"log"
 	"net"
 	"net/http"
@@ -29,6 +31,16 @@ func Serve(host string, port string) error {
 		database.Dispose()
 	}()
 
+	// 加载 token 的签名密钥, 并定时同步其他实例轮换的密钥
+	if err := token_key.Load(database.Db); err != nil {
+		return err
+	}
+
+	token_key.Watch(database.Db)
+
+	// 在后台加载第三方登陆的服务提供商, 并定时同步配置中心的修改
+	oauth.Watch(database.Db)
+
 	s := &http.Server{
 		Addr:           net.JoinHostPort(host, port),
 		Handler:        UserRouter,
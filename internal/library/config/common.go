@@ -3,12 +3,14 @@ package config
 
 import (
 	"github.com/axetroy/go-server/internal/service/dotenv"
+	"strings"
 )
 
 type common struct {
 	MachineId string `json:"machine_id"` // 机器 ID
 	Mode      string `json:"mode"`       // 运行模式, 开发模式还是生产模式
 	Exiting   bool   `json:"exiting"`    // 进程是否出于正在退出的状态，用户优雅的退出进程
+	Domain    string `json:"domain"`     // 服务对外的地址, 由启动参数 --domain 指定, 用于生成回调地址等
 }
 
 var Common *common
@@ -20,4 +22,9 @@ func init() {
 		Common.Mode = "development"
 	}
 	Common.MachineId = dotenv.GetByDefault("MACHINE_ID", "0")
+	Common.SetDomain(dotenv.GetByDefault("DOMAIN", "https://example.com"))
+}
+
+func (c *common) SetDomain(domain string) {
+	c.Domain = strings.TrimRight(domain, "/")
 }
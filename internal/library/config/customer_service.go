@@ -0,0 +1,17 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
+)
+
+type customerService struct {
+	ResumeGrace time.Duration `json:"resume_grace"` // 用户断线之后保留会话的时间, 在这段时间内重新连接可以恢复会话. 为 0 时不保留
+}
+
+var CustomerService customerService
+
+func init() {
+	CustomerService.ResumeGrace = time.Second * time.Duration(dotenv.GetInt64ByDefault("CUSTOMER_SERVICE_RESUME_GRACE", 60))
+}
@@ -0,0 +1,17 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
+)
+
+type exchange struct {
+	QuoteTTL time.Duration `json:"quote_ttl"` // 兑换报价的有效期, 在有效期内按照报价的汇率成交
+}
+
+var Exchange exchange
+
+func init() {
+	Exchange.QuoteTTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("EXCHANGE_QUOTE_TTL", 30))
+}
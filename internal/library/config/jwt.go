@@ -3,14 +3,24 @@ package config
 
 import (
 	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
 )
 
 type jwt struct {
-	Secret string `json:"secret"` // 用户端密钥，用于加密/解密 token
+	Secret       string        `json:"secret"`        // 旧版本 token 使用的共享密钥, 只用于验证之前签发的 token
+	AcceptLegacy bool          `json:"accept_legacy"` // 是否接受旧版本签发的用户 token, 所有旧 token 过期之后应该关闭. 管理员的 token 始终不接受
+	AccessTTL    time.Duration `json:"access_ttl"`    // JWT 模式下身份令牌的有效期, 过期之后使用刷新令牌换取新的身份令牌
+	KeyRotation  time.Duration `json:"key_rotation"`  // 签名密钥的轮换周期
+	// 加密数据库中签名私钥的密钥, 不能为空, 修改之后无法解密已经保存的私钥
+	KeyEncryptionKey string `json:"-"`
 }
 
 var Jwt jwt
 
 func init() {
 	Jwt.Secret = dotenv.GetByDefault("TOKEN_SECRET_KEY", "44JodlDOWk13f8a0&fKSDI*AP")
+	Jwt.AcceptLegacy = dotenv.GetByDefault("TOKEN_ACCEPT_LEGACY", "false") == "true"
+	Jwt.AccessTTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("TOKEN_ACCESS_TTL", 900))
+	Jwt.KeyRotation = time.Hour * 24 * time.Duration(dotenv.GetInt64ByDefault("TOKEN_KEY_ROTATION_DAYS", 30))
+	Jwt.KeyEncryptionKey = dotenv.GetByDefault("TOKEN_KEY_ENCRYPTION_KEY", "")
 }
@@ -0,0 +1,26 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"strings"
+	"time"
+)
+
+type oidc struct {
+	Issuer       string        `json:"issuer"`        // 身份提供方的地址, 即用户服务对外的地址, 写入 token 的 iss 字段
+	AuthorizeUrl string        `json:"authorize_url"` // 前端的授权页面, 用户在这里登陆并确认授权
+	CodeTTL      time.Duration `json:"code_ttl"`      // 授权码的有效期
+	AccessTTL    time.Duration `json:"access_ttl"`    // access token 和 ID Token 的有效期
+	RefreshTTL   time.Duration `json:"refresh_ttl"`   // refresh token 的有效期
+}
+
+var Oidc oidc
+
+func init() {
+	Oidc.Issuer = strings.TrimRight(dotenv.GetByDefault("OIDC_ISSUER", dotenv.GetByDefault("DOMAIN", "https://example.com")), "/")
+	Oidc.AuthorizeUrl = dotenv.GetByDefault("OIDC_AUTHORIZE_URL", Oidc.Issuer+"/oauth/authorize")
+	Oidc.CodeTTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("OIDC_CODE_TTL", 60))
+	Oidc.AccessTTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("OIDC_ACCESS_TTL", 3600))
+	Oidc.RefreshTTL = time.Hour * 24 * time.Duration(dotenv.GetInt64ByDefault("OIDC_REFRESH_TTL_DAYS", 30))
+}
@@ -0,0 +1,40 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import "github.com/axetroy/go-server/internal/service/dotenv"
+
+type passwordArgon2 struct {
+	Memory     int `json:"memory"`      // 使用的内存大小, 单位 KiB
+	Time       int `json:"time"`        // 迭代次数
+	Threads    int `json:"threads"`     // 并行度
+	KeyLength  int `json:"key_length"`  // 生成的 hash 长度, 单位 byte
+	SaltLength int `json:"salt_length"` // 盐的长度, 单位 byte
+}
+
+type passwordBcrypt struct {
+	Cost int `json:"cost"` // 计算成本, 范围 4-31
+}
+
+type password struct {
+	Algorithm string         `json:"algorithm"` // 使用的 hash 算法, 可选 argon2id/bcrypt
+	Argon2    passwordArgon2 `json:"argon2"`    // argon2id 相关配置
+	Bcrypt    passwordBcrypt `json:"bcrypt"`    // bcrypt 相关配置
+}
+
+var Password password
+
+func init() {
+	Password = password{
+		Algorithm: dotenv.GetByDefault("PASSWORD_ALGORITHM", "argon2id"),
+		Argon2: passwordArgon2{
+			Memory:     dotenv.GetIntByDefault("PASSWORD_ARGON2_MEMORY", 64*1024),
+			Time:       dotenv.GetIntByDefault("PASSWORD_ARGON2_TIME", 1),
+			Threads:    dotenv.GetIntByDefault("PASSWORD_ARGON2_THREADS", 2),
+			KeyLength:  32,
+			SaltLength: 16,
+		},
+		Bcrypt: passwordBcrypt{
+			Cost: dotenv.GetIntByDefault("PASSWORD_BCRYPT_COST", 10),
+		},
+	}
+}
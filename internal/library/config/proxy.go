@@ -0,0 +1,55 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"log"
+	"net"
+	"strings"
+)
+
+type proxy struct {
+	Trusted []*net.IPNet `json:"trusted"` // 信任的反向代理, 只采用这些地址发来的 X-Forwarded-For/X-Real-Ip 头部
+}
+
+var Proxy proxy
+
+func init() {
+	// 默认信任本机和内网地址, 即同一台机器或者同一个内网中的反向代理
+	list := dotenv.GetStrArrayByDefault("TRUSTED_PROXIES", []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"})
+
+	for _, s := range list {
+		if s == "" {
+			continue
+		}
+
+		// 单个 IP 视为只包含自己的网段
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(s)
+
+		if err != nil {
+			log.Printf("无效的代理地址 %s\n", s)
+			continue
+		}
+
+		Proxy.Trusted = append(Proxy.Trusted, ipNet)
+	}
+}
+
+// 是否是信任的反向代理
+func (p proxy) IsTrusted(ip net.IP) bool {
+	for _, n := range p.Trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
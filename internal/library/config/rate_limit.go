@@ -0,0 +1,16 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+)
+
+type rateLimit struct {
+	Store string `json:"store"` // 限流计数的存储方式, 可选 memory/redis. 多个实例时使用 redis 共享额度
+}
+
+var RateLimit rateLimit
+
+func init() {
+	RateLimit.Store = dotenv.GetByDefault("RATE_LIMIT_STORE", "redis")
+}
@@ -0,0 +1,46 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import "github.com/axetroy/go-server/internal/service/dotenv"
+
+type storageLocal struct {
+	RootPath string `json:"root_path"` // 文件存储的根目录
+	BaseURL  string `json:"base_url"`  // 文件对外访问的地址前缀, 默认由资源服务器提供访问
+}
+
+type storageS3 struct {
+	Endpoint        string `json:"endpoint"`          // S3 兼容服务的地址, 例如 https://s3.amazonaws.com 或 http://127.0.0.1:9000
+	Region          string `json:"region"`            // 存储桶所在的区域
+	Bucket          string `json:"bucket"`            // 存储桶名称
+	AccessKeyId     string `json:"access_key_id"`     // access key
+	SecretAccessKey string `json:"secret_access_key"` // secret key
+	PathStyle       bool   `json:"path_style"`        // 是否使用路径风格的访问地址, MinIO 等自建服务通常需要开启
+	PublicURL       string `json:"public_url"`        // 对外访问的地址前缀, 例如 CDN 的域名. 为空则使用 Endpoint
+}
+
+type storage struct {
+	Provider string       `json:"provider"` // 选用哪种文件存储, 可选 local/s3
+	Local    storageLocal `json:"local"`    // 本地存储相关配置
+	S3       storageS3    `json:"s3"`       // S3 兼容存储相关配置
+}
+
+var Storage storage
+
+func init() {
+	Storage = storage{
+		Provider: dotenv.GetByDefault("STORAGE_PROVIDER", "local"),
+		Local: storageLocal{
+			RootPath: dotenv.GetByDefault("STORAGE_LOCAL_ROOT", dotenv.GetByDefault("UPLOAD_DIR", "upload")),
+			BaseURL:  dotenv.GetByDefault("STORAGE_LOCAL_BASE_URL", "/v1/resource"),
+		},
+		S3: storageS3{
+			Endpoint:        dotenv.GetByDefault("STORAGE_S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          dotenv.GetByDefault("STORAGE_S3_REGION", "us-east-1"),
+			Bucket:          dotenv.Get("STORAGE_S3_BUCKET"),
+			AccessKeyId:     dotenv.Get("STORAGE_S3_ACCESS_KEY"),
+			SecretAccessKey: dotenv.Get("STORAGE_S3_SECRET_KEY"),
+			PathStyle:       dotenv.GetByDefault("STORAGE_S3_PATH_STYLE", "true") == "true",
+			PublicURL:       dotenv.Get("STORAGE_S3_PUBLIC_URL"),
+		},
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
+)
+
+type totp struct {
+	ChallengeTTL      time.Duration `json:"challenge_ttl"`       // 登陆时双重身份认证的有效期, 超时需要重新登陆
+	ChallengeAttempts int64         `json:"challenge_attempts"`  // 每次登陆最多可以尝试输入几次动态密码
+	RecoveryCodeCount int           `json:"recovery_code_count"` // 每次生成的恢复码数量
+}
+
+var TOTP totp
+
+func init() {
+	TOTP.ChallengeTTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("TOTP_CHALLENGE_TTL", 300))
+	TOTP.ChallengeAttempts = dotenv.GetInt64ByDefault("TOTP_CHALLENGE_ATTEMPTS", 5)
+	TOTP.RecoveryCodeCount = int(dotenv.GetInt64ByDefault("TOTP_RECOVERY_CODE_COUNT", 10))
+}
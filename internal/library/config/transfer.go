@@ -0,0 +1,17 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
+)
+
+type transfer struct {
+	PendingTimeout time.Duration `json:"pending_timeout"` // 待确认的转账超过这个时间没有被处理, 则自动拒绝
+}
+
+var Transfer transfer
+
+func init() {
+	Transfer.PendingTimeout = time.Second * time.Duration(dotenv.GetInt64ByDefault("TRANSFER_PENDING_TIMEOUT", 60*60*24))
+}
@@ -0,0 +1,23 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"time"
+)
+
+type verifyCode struct {
+	Length   int           `json:"length"`   // 验证码的长度
+	TTL      time.Duration `json:"ttl"`      // 验证码的有效期
+	Attempts int64         `json:"attempts"` // 每个验证码最多可以尝试几次, 超过之后需要重新发送
+	Cooldown time.Duration `json:"cooldown"` // 同一个用途和接收方, 重新发送需要间隔的时间
+}
+
+var VerifyCode verifyCode
+
+func init() {
+	VerifyCode.Length = int(dotenv.GetInt64ByDefault("VERIFY_CODE_LENGTH", 6))
+	VerifyCode.TTL = time.Second * time.Duration(dotenv.GetInt64ByDefault("VERIFY_CODE_TTL", 600))
+	VerifyCode.Attempts = dotenv.GetInt64ByDefault("VERIFY_CODE_ATTEMPTS", 5)
+	VerifyCode.Cooldown = time.Second * time.Duration(dotenv.GetInt64ByDefault("VERIFY_CODE_COOLDOWN", 60))
+}
@@ -0,0 +1,52 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package config
+
+import (
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type webAuthn struct {
+	RpId    string        `json:"rp_id"`   // 依赖方 ID, 即前端页面的域名. 为空则使用 Common.Domain 的域名
+	RpName  string        `json:"rp_name"` // 显示给用户的名称
+	Origins []string      `json:"origins"` // 允许发起请求的页面来源. 为空则使用 Common.Domain
+	Timeout time.Duration `json:"timeout"` // 注册/认证的有效期
+}
+
+var WebAuthn webAuthn
+
+func init() {
+	WebAuthn.RpId = dotenv.GetByDefault("WEBAUTHN_RP_ID", "")
+	WebAuthn.RpName = dotenv.GetByDefault("WEBAUTHN_RP_NAME", "go-server")
+	WebAuthn.Timeout = time.Second * time.Duration(dotenv.GetInt64ByDefault("WEBAUTHN_TIMEOUT", 300))
+
+	for _, origin := range strings.Split(dotenv.GetByDefault("WEBAUTHN_ORIGINS", ""), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			WebAuthn.Origins = append(WebAuthn.Origins, origin)
+		}
+	}
+}
+
+// 依赖方 ID, 默认为服务对外地址的域名
+func (w *webAuthn) GetRpId() string {
+	if w.RpId != "" {
+		return w.RpId
+	}
+
+	if u, err := url.Parse(Common.Domain); err == nil {
+		return u.Hostname()
+	}
+
+	return ""
+}
+
+// 允许的页面来源, 默认为服务对外的地址
+func (w *webAuthn) GetOrigins() []string {
+	if len(w.Origins) != 0 {
+		return w.Origins
+	}
+
+	return []string{Common.Domain}
+}
@@ -0,0 +1,334 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 精确的十进制数, 用于表示金额
+// 统一保留 8 位小数, 内部以最小单位 (1e-8) 的整数存储, 加减运算不会有精度丢失
+// 在数据库中对应 numeric 类型, 在 JSON 中序列化为字符串
+package decimal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const Places = 8 // 保留的小数位数
+
+var (
+	ErrInvalidFormat  = errors.New("invalid decimal format")
+	ErrOutOfPrecision = errors.New("decimal out of precision")
+
+	Zero = Decimal{}
+
+	scale = new(big.Int).Exp(big.NewInt(10), big.NewInt(Places), nil) // 10^8
+)
+
+type Decimal struct {
+	units *big.Int // 以 1e-8 为单位的整数, nil 表示 0
+}
+
+func (d Decimal) int() *big.Int {
+	if d.units == nil {
+		return new(big.Int)
+	}
+	return d.units
+}
+
+// 从整数创建
+func NewFromInt(i int64) Decimal {
+	return Decimal{units: new(big.Int).Mul(big.NewInt(i), scale)}
+}
+
+// 从字符串解析, 例如 "-12.345"
+// 小数位超过 8 位会返回错误, 避免金额被静默截断
+func NewFromString(s string) (Decimal, error) {
+	return parse(s, false)
+}
+
+// 和 NewFromString 一样, 但是解析失败时 panic, 只应用于常量
+func RequireFromString(s string) Decimal {
+	d, err := NewFromString(s)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+func parse(s string, round bool) (Decimal, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return Zero, ErrInvalidFormat
+	}
+
+	negative := false
+
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	if intPart == "" && fracPart == "" {
+		return Zero, ErrInvalidFormat
+	}
+
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Zero, ErrInvalidFormat
+	}
+
+	roundUp := false
+
+	if len(fracPart) > Places {
+		rest := fracPart[Places:]
+
+		if !round && strings.Trim(rest, "0") != "" {
+			return Zero, ErrOutOfPrecision
+		}
+
+		roundUp = rest[0] >= '5'
+		fracPart = fracPart[:Places]
+	}
+
+	fracPart = fracPart + strings.Repeat("0", Places-len(fracPart))
+
+	units, ok := new(big.Int).SetString(intPart+fracPart, 10)
+
+	if !ok {
+		return Zero, ErrInvalidFormat
+	}
+
+	// 四舍五入
+	if roundUp {
+		units.Add(units, big.NewInt(1))
+	}
+
+	if negative {
+		units.Neg(units)
+	}
+
+	return Decimal{units: units}, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) Add(d2 Decimal) Decimal {
+	return Decimal{units: new(big.Int).Add(d.int(), d2.int())}
+}
+
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	return Decimal{units: new(big.Int).Sub(d.int(), d2.int())}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: new(big.Int).Neg(d.int())}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{units: new(big.Int).Abs(d.int())}
+}
+
+// 乘法, 结果四舍五入保留 8 位小数
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	product := new(big.Int).Mul(d.int(), d2.int())
+
+	return Decimal{units: roundQuo(product, scale)}
+}
+
+// 除法, 结果四舍五入保留 8 位小数. 除数为 0 时 panic
+func (d Decimal) Div(d2 Decimal) Decimal {
+	if d2.IsZero() {
+		panic("decimal division by zero")
+	}
+
+	numerator := new(big.Int).Mul(d.int(), scale)
+
+	return Decimal{units: roundQuo(numerator, d2.int())}
+}
+
+// 截断到指定的小数位数, 多余的部分直接舍去 (向 0 取整)
+func (d Decimal) Truncate(places int) Decimal {
+	if places >= Places {
+		return d
+	}
+
+	if places < 0 {
+		places = 0
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Places-places)), nil)
+
+	units := new(big.Int).Quo(d.int(), unit)
+
+	return Decimal{units: units.Mul(units, unit)}
+}
+
+// 四舍五入到指定的小数位数
+func (d Decimal) Round(places int) Decimal {
+	if places >= Places {
+		return d
+	}
+
+	if places < 0 {
+		places = 0
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Places-places)), nil)
+
+	units := roundQuo(d.int(), unit)
+
+	return Decimal{units: units.Mul(units, unit)}
+}
+
+// 整数除法并四舍五入 (远离 0)
+func roundQuo(x *big.Int, y *big.Int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(x, y, new(big.Int))
+
+	// |rem| * 2 >= |y| 则进位
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(new(big.Int).Abs(y)) >= 0 {
+		if (x.Sign() < 0) != (y.Sign() < 0) {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return quo
+}
+
+// 比较大小, d < d2 返回 -1, d == d2 返回 0, d > d2 返回 1
+func (d Decimal) Cmp(d2 Decimal) int {
+	return d.int().Cmp(d2.int())
+}
+
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+func (d Decimal) LessThan(d2 Decimal) bool {
+	return d.Cmp(d2) < 0
+}
+
+func (d Decimal) GreaterThan(d2 Decimal) bool {
+	return d.Cmp(d2) > 0
+}
+
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+func (d Decimal) IsPositive() bool {
+	return d.Sign() > 0
+}
+
+// 格式化为字符串, 固定保留 8 位小数, 例如 "20.00000000"
+func (d Decimal) String() string {
+	units := d.int()
+
+	digits := new(big.Int).Abs(units).String()
+
+	if len(digits) <= Places {
+		digits = strings.Repeat("0", Places-len(digits)+1) + digits
+	}
+
+	s := digits[:len(digits)-Places] + "." + digits[len(digits)-Places:]
+
+	if units.Sign() < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+// 格式化为字符串, 保留指定的小数位数 (四舍五入)
+func (d Decimal) StringFixed(places int) string {
+	s := d.Round(places).String()
+
+	if places <= 0 {
+		return s[:strings.IndexByte(s, '.')]
+	}
+
+	if places >= Places {
+		return s
+	}
+
+	return s[:len(s)-(Places-places)]
+}
+
+// 实现 driver.Valuer 接口, 以字符串写入 numeric 字段
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// 实现 sql.Scanner 接口
+func (d *Decimal) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		*d = Zero
+	case []byte:
+		*d, err = parse(string(v), true)
+	case string:
+		*d, err = parse(v, true)
+	case int64:
+		*d = NewFromInt(v)
+	case float64:
+		*d, err = parse(strconv.FormatFloat(v, 'f', -1, 64), true)
+	default:
+		err = fmt.Errorf("could not convert %T to decimal", value)
+	}
+
+	return
+}
+
+// 序列化为 JSON 字符串, 避免前端以浮点数处理
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// 支持字符串和数字两种格式
+func (d *Decimal) UnmarshalJSON(b []byte) (err error) {
+	var s string
+
+	if string(b) == "null" {
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		if err = json.Unmarshal(b, &s); err != nil {
+			return
+		}
+	} else {
+		s = string(b)
+	}
+
+	*d, err = NewFromString(s)
+
+	return
+}
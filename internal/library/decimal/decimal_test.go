@@ -0,0 +1,127 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package decimal_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewFromString(t *testing.T) {
+	cases := map[string]string{
+		"0":           "0.00000000",
+		"20":          "20.00000000",
+		"0.1":         "0.10000000",
+		".5":          "0.50000000",
+		"-12.345":     "-12.34500000",
+		"+3":          "3.00000000",
+		"0.00000001":  "0.00000001",
+		"1.100000000": "1.10000000",
+		"123456789012345678901234567890.12345678": "123456789012345678901234567890.12345678",
+	}
+
+	for input, expect := range cases {
+		d, err := decimal.NewFromString(input)
+
+		assert.Nil(t, err, input)
+		assert.Equal(t, expect, d.String(), input)
+	}
+
+	for _, input := range []string{"", "-", ".", "abc", "1.2.3", "1e10", "0x10", " 1 2"} {
+		_, err := decimal.NewFromString(input)
+
+		assert.Equal(t, decimal.ErrInvalidFormat, err, input)
+	}
+
+	_, err := decimal.NewFromString("0.000000001")
+
+	assert.Equal(t, decimal.ErrOutOfPrecision, err)
+}
+
+func TestArithmetic(t *testing.T) {
+	a := decimal.RequireFromString("0.1")
+	b := decimal.RequireFromString("0.2")
+
+	// 浮点数 0.1 + 0.2 != 0.3
+	assert.True(t, a.Add(b).Equal(decimal.RequireFromString("0.3")))
+	assert.Equal(t, "-0.10000000", a.Sub(b).String())
+	assert.Equal(t, "-0.10000000", a.Neg().String())
+	assert.Equal(t, "0.10000000", a.Neg().Abs().String())
+	assert.Equal(t, "0.02000000", a.Mul(b).String())
+	assert.Equal(t, "0.50000000", a.Div(b).String())
+	assert.Equal(t, "0.33333333", decimal.NewFromInt(1).Div(decimal.NewFromInt(3)).String())
+	assert.Equal(t, "0.66666667", decimal.NewFromInt(2).Div(decimal.NewFromInt(3)).String())
+	assert.Equal(t, "-0.66666667", decimal.NewFromInt(-2).Div(decimal.NewFromInt(3)).String())
+
+	assert.Equal(t, -1, a.Cmp(b))
+	assert.Equal(t, 1, b.Cmp(a))
+	assert.Equal(t, 0, a.Cmp(a))
+	assert.True(t, a.LessThan(b))
+	assert.True(t, b.GreaterThan(a))
+
+	assert.True(t, decimal.Zero.IsZero())
+	assert.True(t, a.IsPositive())
+	assert.True(t, a.Neg().IsNegative())
+	assert.True(t, a.Sub(a).IsZero())
+}
+
+func TestRound(t *testing.T) {
+	d := decimal.RequireFromString("12.3456789")
+
+	assert.Equal(t, "12.35000000", d.Round(2).String())
+	assert.Equal(t, "12.34000000", d.Truncate(2).String())
+	assert.Equal(t, "12.00000000", d.Round(0).String())
+	assert.Equal(t, "-12.35000000", d.Neg().Round(2).String())
+	assert.Equal(t, "-12.34000000", d.Neg().Truncate(2).String())
+	assert.Equal(t, "12.35", d.StringFixed(2))
+	assert.Equal(t, "12", d.StringFixed(0))
+	assert.Equal(t, "12.34567890", d.StringFixed(10))
+}
+
+func TestScan(t *testing.T) {
+	var d decimal.Decimal
+
+	assert.Nil(t, d.Scan([]byte("12.5")))
+	assert.Equal(t, "12.50000000", d.String())
+
+	assert.Nil(t, d.Scan("0.123456789"))
+	assert.Equal(t, "0.12345679", d.String())
+
+	assert.Nil(t, d.Scan(int64(3)))
+	assert.Equal(t, "3.00000000", d.String())
+
+	assert.Nil(t, d.Scan(0.1))
+	assert.Equal(t, "0.10000000", d.String())
+
+	assert.Nil(t, d.Scan(nil))
+	assert.True(t, d.IsZero())
+
+	assert.NotNil(t, d.Scan(true))
+
+	v, err := decimal.RequireFromString("1.5").Value()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1.50000000", v)
+}
+
+func TestJSON(t *testing.T) {
+	type Body struct {
+		Amount decimal.Decimal `json:"amount"`
+	}
+
+	b, err := json.Marshal(Body{Amount: decimal.RequireFromString("20")})
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"amount":"20.00000000"}`, string(b))
+
+	body := Body{}
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":"0.3"}`), &body))
+	assert.Equal(t, "0.30000000", body.Amount.String())
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":1.25}`), &body))
+	assert.Equal(t, "1.25000000", body.Amount.String())
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"amount":"abc"}`), &body))
+}
@@ -12,6 +12,7 @@ var (
 	Duplicate        = New("重复操作", 100006)
 	NoConfig         = New("缺少配置", 100007)
 	ThirdParty       = New("第三方错误", 100008)
+	TooManyRequests  = New("请求过于频繁", 100009)
 	SendMsgFail      = New("发送短信失败", 101000)
 	SendEmailFail    = New("发送邮件失败", 101001)
 	UserNotLogin     = New("请先登陆", 999999)
@@ -38,13 +39,74 @@ var (
 	RequirePayPassword       = New("请输入交易密码", 200014)
 	RenameUserNameFail       = New("无法重命名用户名", 200016)
 
+	// 双重身份认证
+	TOTPAlreadyEnabled   = Duplicate.New("已开启双重身份认证")
+	TOTPNotEnabled       = InvalidParams.New("未开启双重身份认证")
+	InvalidTOTPCode      = InvalidParams.New("动态密码或恢复码错误")
+	TOTPChallengeExpired = InvalidParams.New("登陆验证已失效, 请重新登陆")
+
+	// 防暴力破解
+	AccountLocked       = TooManyRequests.New("失败次数过多, 帐号已被临时锁定, 请稍后再试")
+	TooManyAttempts     = TooManyRequests.New("尝试过于频繁, 请稍后再试")
+	SendCodeTooFrequent = TooManyRequests.New("发送验证码过于频繁, 请稍后再试")
+
+	// 验证码
+	InvalidVerifyCode = InvalidParams.New("验证码错误")
+	VerifyCodeExpired = InvalidParams.New("验证码已失效, 请重新获取")
+
+	// 会话
+	SessionNotExist     = NoData.New("会话不存在")
+	InvalidRefreshToken = InvalidToken.New("无效的刷新令牌")
+	RefreshTokenReused  = InvalidToken.New("刷新令牌已被使用, 请重新登陆")
+
+	// 第三方应用
+	OAuthClientNotExist  = NoData.New("应用不存在")
+	OAuthClientDisabled  = InvalidParams.New("应用已停用")
+	OAuthClientIsPublic  = InvalidParams.New("公开应用没有密钥")
+	InvalidRedirectUri   = InvalidParams.New("无效的回调地址")
+	InvalidOAuthScope    = InvalidParams.New("无效的授权范围")
+	OAuthConsentNotExist = NoData.New("授权记录不存在")
+
+	// 第三方登陆
+	OAuthProviderNotExist    = NoData.New("不支持该登陆方式")
+	OAuthProviderUnavailable = ThirdParty.New("该登陆方式暂时不可用")
+	InvalidOAuthSession      = InvalidParams.New("认证已失效, 请重新登陆")
+
+	// 通行密钥 (WebAuthn)
+	WebAuthnCredentialNotExist = NoData.New("通行密钥不存在")
+	WebAuthnCredentialExist    = Duplicate.New("通行密钥已注册")
+	InvalidWebAuthnCredential  = InvalidParams.New("通行密钥验证失败")
+	WebAuthnChallengeExpired   = InvalidParams.New("通行密钥验证已失效, 请重试")
+
 	// 钱包
 	NotEnoughBalance = New("钱包余额不足", 0)
+	NotEnoughFrozen  = New("冻结余额不足", 0)
 	InvalidWallet    = New("无效的钱包", 0)
 
+	// 币种
+	CurrencyDisabled    = InvalidParams.New("币种已停用")
+	CurrencyExist       = Duplicate.New("币种已存在")
+	CurrencyInvalidCode = InvalidParams.New("无效的币种代码")
+
+	// 兑换
+	ExchangeRateNotExist   = NoData.New("不支持该币种的兑换")
+	ExchangeSameCurrency   = InvalidParams.New("不能兑换相同的币种")
+	ExchangeAmountTooSmall = InvalidParams.New("兑换数量太小")
+	ExchangeQuoteExpired   = InvalidParams.New("报价已失效, 请重新获取报价")
+
+	// 对账
+	ReconciliationNotExist = NoData.New("对账记录不存在")
+
+	// 转账
+	TransferToSelf       = InvalidParams.New("不能转账给自己")
+	TransferNotExist     = NoData.New("转账记录不存在")
+	TransferNotPending   = InvalidParams.New("转账不是待确认状态")
+	IdempotencyKeyReused = Duplicate.New("Idempotency-Key 已被其他请求使用")
+
 	// 上传
 	NotSupportType = New("不支持该文件类型", 0)
 	OutOfSize      = New("超出文件大小限制", 0)
+	FileNotExist   = NoData.New("文件不存在")
 
 	// 地址
 	AddressDefaultNotExist = InvalidParams.New("默认地址不存在")
@@ -79,4 +141,11 @@ var (
 	// 新闻资讯
 	NewsInvalidType = New("错误的文章类型", 0)
 	NewsNotExist    = New("文章不存在", 0)
+
+	// 客服
+	CustomerNotConnected   = NoData.New("对方未连接")
+	CustomerSessionExpired = InvalidParams.New("会话已失效, 请重新连接")
+	CustomerNotServing     = InvalidParams.New("未接待该用户")
+	CustomerWaiterBusy     = InvalidParams.New("目标客服未就绪或已接满")
+	CustomerTicketClaimed  = InvalidParams.New("留言已由其他客服处理")
 )
@@ -10,6 +10,7 @@ type Context struct {
 	Uid       string `json:"uid"`        // 操作人的用户 ID
 	UserAgent string `json:"user_agent"` // 用户代理
 	Ip        string `json:"ip"`         // IP地址
+	SessionId string `json:"session_id"` // 当前的会话ID
 }
 
 func NewContext(c *router.Context) Context {
@@ -17,5 +18,6 @@ func NewContext(c *router.Context) Context {
 		Uid:       c.Uid(),
 		UserAgent: c.GetHeader("user-agent"),
 		Ip:        c.ClientIP(),
+		SessionId: c.SessionId(),
 	}
 }
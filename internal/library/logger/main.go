@@ -8,8 +8,9 @@ import (
 )
 
 var (
-	Info  = log.Info
-	Infof = log.Infof
+	Info   = log.Info
+	Infof  = log.Infof
+	Errorf = log.Errorf
 )
 
 func init() {
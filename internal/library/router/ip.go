@@ -0,0 +1,62 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package router
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 获取客户端的真实 IP
+// 客户端可以伪造 X-Forwarded-For 和 X-Real-Ip 头部, 所以只有请求来自信任的反向代理时才采用.
+// X-Forwarded-For 从右往左查找, 第一个不是信任的代理的地址就是客户端的地址
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote := net.ParseIP(host)
+
+	if remote == nil {
+		return host
+	}
+
+	if !config.Proxy.IsTrusted(remote) {
+		return remote.String()
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		list := strings.Split(forwarded, ",")
+
+		var first net.IP
+
+		for i := len(list) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(list[i]))
+
+			if ip == nil {
+				// 无法解析的地址之前的内容都不可信
+				break
+			}
+
+			if !config.Proxy.IsTrusted(ip) {
+				return ip.String()
+			}
+
+			first = ip
+		}
+
+		// 整条链路都是信任的代理, 例如内网中的请求
+		if first != nil {
+			return first.String()
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); ip != nil {
+		return ip.String()
+	}
+
+	return remote.String()
+}
@@ -0,0 +1,43 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package router_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	newRequest := func(remoteAddr string, headers map[string]string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+		r.RemoteAddr = remoteAddr
+
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		return r
+	}
+
+	// 直接连接的客户端
+	assert.Equal(t, "8.8.8.8", router.ClientIP(newRequest("8.8.8.8:1234", nil)))
+
+	// 不信任的地址发来的头部会被忽略
+	assert.Equal(t, "8.8.8.8", router.ClientIP(newRequest("8.8.8.8:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"})))
+	assert.Equal(t, "8.8.8.8", router.ClientIP(newRequest("8.8.8.8:1234", map[string]string{"X-Real-Ip": "1.1.1.1"})))
+
+	// 经过本机的反向代理
+	assert.Equal(t, "1.1.1.1", router.ClientIP(newRequest("127.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"})))
+	assert.Equal(t, "1.1.1.1", router.ClientIP(newRequest("127.0.0.1:1234", map[string]string{"X-Real-Ip": "1.1.1.1"})))
+
+	// 客户端伪造的地址在最左边, 从右往左取第一个不信任的地址
+	assert.Equal(t, "1.1.1.1", router.ClientIP(newRequest("127.0.0.1:1234", map[string]string{"X-Forwarded-For": "9.9.9.9, 1.1.1.1, 10.0.0.2"})))
+
+	// 整条链路都在内网中
+	assert.Equal(t, "10.0.0.3", router.ClientIP(newRequest("127.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"})))
+
+	// IPv6
+	assert.Equal(t, "2001:db8::1", router.ClientIP(newRequest("[::1]:1234", map[string]string{"X-Forwarded-For": "2001:db8::1"})))
+}
@@ -3,13 +3,26 @@ package util
 
 import (
 	"crypto"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	qrcode "github.com/sec51/qrcode"
 	"github.com/sec51/twofactor"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
 	issuer     = "go-server" // 签发者
 	encryption = crypto.SHA1 // 加密算法
 	digits     = 6           // 密码位数
+	period     = 30          // 动态密码的有效时间 (秒)
+	skew       = 1           // 允许前后偏差的周期数, 兼容客户端与服务器之间的时间误差
 	prefix     = "prefix"    // 用于UID的前缀, 不能暴露这个字段，否则用户私钥可能泄漏
 	suffix     = "suffix"    // 用户UID的后缀，不能暴露这个字段，否则用户私钥可能泄漏
 )
@@ -30,15 +43,98 @@ func Generate2FASecret(uid string) (secret string, err error) {
 	return otp.Secret(), nil
 }
 
-// 验证用户token是否正确
-func Verify2FA(uid string, token string) bool {
-	otp, err := twofactor.NewTOTP(generateAccount(uid), issuer, encryption, digits)
+// 解析 base32 编码的密钥
+func decode2FASecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
+
+// 计算某个周期的动态密码
+func generate2FACode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+
+	binary.BigEndian.PutUint64(msg, counter)
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	// RFC 4226 动态截取
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0"+strconv.Itoa(digits)+"d", code%mod)
+}
+
+// 生成某个时间点的动态密码
+func Generate2FACode(secret string, t time.Time) (string, error) {
+	key, err := decode2FASecret(secret)
 
 	if err != nil {
+		return "", err
+	}
+
+	return generate2FACode(key, uint64(t.Unix()/int64(period))), nil
+}
+
+// 验证动态密码是否正确
+func Verify2FA(secret string, token string) bool {
+	if len(token) != digits {
 		return false
 	}
 
-	err = otp.Validate(token)
+	key, err := decode2FASecret(secret)
+
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	counter := time.Now().Unix() / int64(period)
+
+	for i := -skew; i <= skew; i++ {
+		if hmac.Equal([]byte(generate2FACode(key, uint64(counter+int64(i)))), []byte(token)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 生成用于身份验证器扫码的地址
+// 例如 otpauth://totp/go-server:admin?secret=JBSWY3DPEHPK3PXP&issuer=go-server
+func Generate2FAURI(secret string, account string) string {
+	v := url.Values{}
+
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", strconv.Itoa(digits))
+	v.Set("period", strconv.Itoa(period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+
+	return u.String()
+}
+
+// 生成地址对应的二维码, 返回 data URI 格式的 PNG 图片, 可以直接用于 <img> 标签
+func Generate2FAQRCode(uri string) (string, error) {
+	code, err := qrcode.Encode(uri, qrcode.Q)
+
+	if err != nil {
+		return "", err
+	}
 
-	return err == nil
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()), nil
 }
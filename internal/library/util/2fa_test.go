@@ -4,7 +4,9 @@ package util_test
 import (
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerate2FASecret(t *testing.T) {
@@ -13,8 +15,52 @@ func TestGenerate2FASecret(t *testing.T) {
 	assert.Len(t, secret, 32)
 }
 
+func TestGenerate2FACode(t *testing.T) {
+	// RFC 6238 附录B 的测试向量, 密钥为 "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := util.Generate2FACode(secret, time.Unix(59, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = util.Generate2FACode(secret, time.Unix(1111111109, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, "081804", code)
+
+	_, err = util.Generate2FACode("!!!", time.Now())
+	assert.NotNil(t, err)
+}
+
 func TestVerify2FA(t *testing.T) {
-	_, err := util.Generate2FASecret("101645075095748608")
+	secret, err := util.Generate2FASecret("101645075095748608")
+	assert.Nil(t, err)
+
+	code, err := util.Generate2FACode(secret, time.Now())
+	assert.Nil(t, err)
+	assert.True(t, util.Verify2FA(secret, code))
+
+	// 允许相邻周期的误差
+	prev, err := util.Generate2FACode(secret, time.Now().Add(-30*time.Second))
+	assert.Nil(t, err)
+	assert.True(t, util.Verify2FA(secret, prev))
+
+	// 过期太久的动态密码
+	old, err := util.Generate2FACode(secret, time.Now().Add(-5*time.Minute))
+	assert.Nil(t, err)
+	assert.False(t, util.Verify2FA(secret, old))
+
+	assert.False(t, util.Verify2FA(secret, "12345678"))
+	assert.False(t, util.Verify2FA("101645075095748608", "123456"))
+}
+
+func TestGenerate2FAURI(t *testing.T) {
+	uri := util.Generate2FAURI("JBSWY3DPEHPK3PXP", "admin")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/go-server:admin?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=go-server")
+
+	img, err := util.Generate2FAQRCode(uri)
 	assert.Nil(t, err)
-	assert.False(t, util.Verify2FA("101645075095748608", "12345678"))
+	assert.True(t, strings.HasPrefix(img, "data:image/png;base64,"))
 }
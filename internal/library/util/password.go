@@ -6,6 +6,7 @@ const (
 	passwordSuffix = "suffix"
 )
 
+// 旧版本的密码 hash, 仅用于校验历史数据, 新的密码请使用 password.Generate
 func GeneratePassword(text string) string {
 	password := MD5(passwordPrefix + text + passwordSuffix)
 	return password
@@ -0,0 +1,53 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package middleware
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/rbac"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/kataras/iris/v12"
+)
+
+// 管理员的权限中间件, 拥有其中任意一个权限即可, 超级管理员拥有所有权限
+func AdminPermission(accessions ...accession.Accession) iris.Handler {
+	return func(c iris.Context) {
+		var (
+			err    error
+			status = schema.StatusFail
+			uid    = c.Values().GetString(ContextUidField) // 这个中间件必须安排在JWT的中间件后面, 所以这里是拿的到 UID 的
+			cc     *rbac.AdminController
+		)
+
+		defer func() {
+			if err != nil {
+				_, _ = c.JSON(schema.Response{
+					Status:  status,
+					Message: err.Error(),
+					Data:    nil,
+				})
+				return
+			}
+
+			c.Next()
+		}()
+
+		if uid == "" {
+			status = exception.NoPermission.Code()
+			err = exception.NoPermission
+			return
+		}
+
+		if cc, err = rbac.NewAdmin(uid); err != nil {
+			if e, ok := err.(exception.Error); ok {
+				status = e.Code()
+			}
+			return
+		}
+
+		if !cc.Require(accessions) {
+			status = exception.NoPermission.Code()
+			err = exception.NoPermission
+		}
+	}
+}
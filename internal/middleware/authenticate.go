@@ -3,6 +3,7 @@ package middleware
 
 import (
 	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/router"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/authentication"
 	"github.com/axetroy/go-server/internal/service/token"
@@ -10,7 +11,9 @@ import (
 )
 
 var (
-	ContextUidField = "uid"
+	ContextUidField     = "uid"
+	ContextSessionField = "session_id"
+	ContextTokenField   = "token"
 )
 
 func getToken(c iris.Context) (*string, error) {
@@ -65,7 +68,7 @@ func AuthenticateNew(isAdmin bool) iris.Handler {
 			return
 		}
 
-		userId, err := authentication.Gateway(isAdmin).Parse(*tokenString)
+		userId, sessionId, err := authentication.Gateway(isAdmin).Touch(*tokenString, router.ClientIP(c.Request()), c.GetHeader("user-agent"))
 
 		if err != nil {
 			status = exception.InvalidToken.Code()
@@ -73,5 +76,7 @@ func AuthenticateNew(isAdmin bool) iris.Handler {
 		}
 
 		c.Values().Set(ContextUidField, userId)
+		c.Values().Set(ContextSessionField, sessionId)
+		c.Values().Set(ContextTokenField, *tokenString)
 	}
 }
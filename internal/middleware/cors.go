@@ -18,8 +18,9 @@ var (
 		"Cache-Control",
 		"X-CSRF-Token",
 		"X-Requested-With",
-		SignatureHeader,   // 接受签名的 Header
-		PayPasswordHeader, // 接收交易密码的 Header
+		SignatureHeader,      // 接受签名的 Header
+		PayPasswordHeader,    // 接收交易密码的 Header
+		IdempotencyKeyHeader, // 接收幂等键的 Header
 	}
 	allowMethods = []string{
 		http.MethodOptions,
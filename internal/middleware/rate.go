@@ -2,19 +2,125 @@
 package middleware
 
 import (
-	"github.com/axetroy/go-server/pkg/ip_rate_limit"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/router"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/pkg/rate_limit"
+	nativeRedis "github.com/go-redis/redis/v8"
 	"github.com/kataras/iris/v12"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-func RateLimit(maxConcurrencyPerMs uint) iris.Handler {
-	limiter := ip_rate_limit.NewIPRateLimiter(5, int(maxConcurrencyPerMs))
+// 限流的 key, 多个 key 组合使用, 例如 IP + 路由
+type RateLimitKey func(c iris.Context) string
+
+var (
+	// 按照连接的远端地址, 不采用任何代理头部
+	RateLimitByIp RateLimitKey = func(c iris.Context) string {
+		host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+
+		if err != nil {
+			host = c.Request().RemoteAddr
+		}
+
+		return "ip:" + host
+	}
+
+	// 按照客户端的真实 IP, 只采用信任的反向代理发来的 X-Forwarded-For 头部
+	RateLimitByForwardedFor RateLimitKey = func(c iris.Context) string {
+		return "ip:" + router.ClientIP(c.Request())
+	}
+
+	// 按照登陆的用户, 必须放在身份认证的中间件后面. 未登陆的请求按照客户端的 IP
+	RateLimitByUid RateLimitKey = func(c iris.Context) string {
+		if uid := c.Values().GetString(ContextUidField); uid != "" {
+			return "uid:" + uid
+		}
+
+		return RateLimitByForwardedFor(c)
+	}
+
+	// 按照路由, 每个路由单独计算额度
+	RateLimitByRoute RateLimitKey = func(c iris.Context) string {
+		if route := c.GetCurrentRoute(); route != nil {
+			return "route:" + route.Method() + " " + route.Path()
+		}
+
+		return "route:" + c.Method() + " " + c.Path()
+	}
+)
+
+var (
+	limiterOnce sync.Once
+	limiter     rate_limit.Limiter
+)
+
+// 根据配置选择限流的存储方式
+func getLimiter() rate_limit.Limiter {
+	limiterOnce.Do(func() {
+		if config.RateLimit.Store == "memory" {
+			limiter = rate_limit.NewMemory()
+		} else {
+			limiter = rate_limit.NewRedis(func() nativeRedis.Cmdable {
+				return redis.Client
+			}, "rate:")
+		}
+	})
+
+	return limiter
+}
+
+// 向上取整到秒
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// 限流, name 用于区分不同的策略, 不指定 key 时按照客户端的 IP
+// 响应中带有 RateLimit-* 头部, 被限制时返回 429 和 Retry-After 头部, 返回体是统一的结构
+func RateLimit(name string, policy rate_limit.Policy, keys ...RateLimitKey) iris.Handler {
+	if len(keys) == 0 {
+		keys = []RateLimitKey{RateLimitByForwardedFor}
+	}
 
 	return func(c iris.Context) {
-		limiter := limiter.GetLimiter(c.RemoteAddr())
+		parts := make([]string, 0, len(keys)+1)
+
+		parts = append(parts, name)
+
+		for _, key := range keys {
+			parts = append(parts, key(c))
+		}
+
+		result, err := getLimiter().Allow(strings.Join(parts, ":"), policy)
+
+		// 限流的存储不可用时放行, 不影响正常的请求
+		if err != nil {
+			log.Println(err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.ResetAfter))
 
-		if !limiter.Allow() {
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
 			c.StatusCode(http.StatusTooManyRequests)
+			_, _ = c.JSON(schema.Response{
+				Status:  exception.TooManyRequests.Code(),
+				Message: exception.TooManyRequests.Error(),
+				Data:    nil,
+			})
 			c.StopExecution()
 			return
 		}
@@ -4,17 +4,18 @@ package middleware
 import (
 	"errors"
 	"github.com/axetroy/go-server/internal/library/exception"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/schema"
 	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 	"github.com/kataras/iris/v12"
 )
 
 var (
-	PayPasswordHeader = "X-Pay-Password"
-	SignatureHeader   = "X-Signature"
+	PayPasswordHeader    = "X-Pay-Password"
+	SignatureHeader      = "X-Signature"
+	IdempotencyKeyHeader = "Idempotency-Key"
 )
 
 // 交易密码的验证中间件
@@ -77,8 +78,21 @@ func AuthPayPasswordNew(c iris.Context) {
 	}
 
 	// 校验密码是否正确
-	if *userInfo.PayPassword != util.GeneratePassword(payPassword) {
+	if !password.Verify(payPassword, *userInfo.PayPassword) {
 		err = exception.InvalidPassword
 		return
 	}
+
+	// 旧的交易密码 hash 在校验成功后升级为当前的算法
+	if password.NeedRehash(*userInfo.PayPassword) {
+		var hashed string
+
+		if hashed, err = password.Generate(payPassword); err != nil {
+			return
+		}
+
+		if err = database.Db.Model(&userInfo).Update("pay_password", hashed).Error; err != nil {
+			return
+		}
+	}
 }
@@ -3,6 +3,7 @@ package model
 
 import (
 	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/rbac/accession"
 	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
 	"time"
@@ -17,16 +18,19 @@ const (
 )
 
 type Admin struct {
-	Id        string         `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 用户ID
-	Username  string         `gorm:"not null;unique;index;type:varchar(36)" json:"username"`       // 用户名, 用于登陆
-	Name      string         `gorm:"not null;index;type:varchar(36)" json:"name"`                  // 管理员名
-	Password  string         `gorm:"not null;type:varchar(36)" json:"password"`                    // 登陆密码
-	Accession pq.StringArray `gorm:"not null;type:varchar(64)[]" json:"accession"`                 // 管理员的权限, 超级管理员不依赖于这个字段
-	IsSuper   bool           `gorm:"not null;" json:"is_super"`                                    // 是否是超级管理员, 超级管理员全站应该只有一个
-	Status    AdminStatus    `gorm:"not null;" json:"status"`                                      // 状态
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time `sql:"index"`
+	Id            string         `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 用户ID
+	Username      string         `gorm:"not null;unique;index;type:varchar(36)" json:"username"`       // 用户名, 用于登陆
+	Name          string         `gorm:"not null;index;type:varchar(36)" json:"name"`                  // 管理员名
+	Password      string         `gorm:"not null;type:varchar(255)" json:"password"`                   // 登陆密码
+	Accession     pq.StringArray `gorm:"not null;type:varchar(64)[]" json:"accession"`                 // 管理员的权限, 超级管理员不依赖于这个字段
+	IsSuper       bool           `gorm:"not null;" json:"is_super"`                                    // 是否是超级管理员, 超级管理员全站应该只有一个
+	Status        AdminStatus    `gorm:"not null;" json:"status"`                                      // 状态
+	EnableTOTP    bool           `gorm:"not null;default:false" json:"enable_totp"`                    // 是否启用双重身份认证
+	Secret        string         `gorm:"not null;default:'';type:varchar(32)" json:"-"`                // 双重身份认证的密钥
+	RecoveryCodes pq.StringArray `gorm:"null;type:varchar(64)[]" json:"-"`                             // 双重身份认证的恢复码, 只存储 hash, 每个只能使用一次
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time `sql:"index"`
 }
 
 func (news *Admin) TableName() string {
@@ -36,3 +40,27 @@ func (news *Admin) TableName() string {
 func (news *Admin) BeforeCreate(scope *gorm.Scope) error {
 	return scope.SetColumn("id", util.GenerateId())
 }
+
+// 管理员是否拥有这些权限, 超级管理员拥有所有权限
+func (news *Admin) HasAccession(accessions ...*accession.Accession) bool {
+	if news.IsSuper {
+		return true
+	}
+
+	for _, a := range accessions {
+		has := false
+
+		for _, name := range news.Accession {
+			if name == a.Name {
+				has = true
+				break
+			}
+		}
+
+		if !has {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,23 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model_test
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdmin_HasAccession(t *testing.T) {
+	// 超级管理员拥有所有权限
+	superAdmin := model.Admin{IsSuper: true}
+
+	assert.True(t, superAdmin.HasAccession(accession.AdminWalletGet, accession.AdminWalletUpdate))
+
+	admin := model.Admin{Accession: []string{accession.AdminWalletGet.Name}}
+
+	assert.True(t, admin.HasAccession())
+	assert.True(t, admin.HasAccession(accession.AdminWalletGet))
+	assert.False(t, admin.HasAccession(accession.AdminWalletUpdate))
+	assert.False(t, admin.HasAccession(accession.AdminWalletGet, accession.AdminWalletUpdate))
+}
@@ -4,9 +4,11 @@ package model
 
 import (
 	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/decimal"
 	"github.com/axetroy/go-server/internal/library/exception"
 	"github.com/axetroy/go-server/internal/library/validator"
 	"github.com/jinzhu/gorm"
+	"regexp"
 	"time"
 )
 
@@ -16,10 +18,15 @@ type ConfigField struct {
 }
 
 var (
-	ConfigFieldNamePhone     = ConfigField{Field: "phone", Description: "手机相关的配置"}
-	ConfigFieldNameSMTP      = ConfigField{Field: "smtp", Description: "SMTP 邮件服务的配置"}
-	ConfigFieldNameWechatApp = ConfigField{Field: "wechat_app", Description: "微信小程序的相关配置"}
-	ConfigFields             = []ConfigField{ConfigFieldNamePhone, ConfigFieldNameSMTP, ConfigFieldNameWechatApp}
+	ConfigFieldNamePhone           = ConfigField{Field: "phone", Description: "手机相关的配置"}
+	ConfigFieldNameSMTP            = ConfigField{Field: "smtp", Description: "SMTP 邮件服务的配置"}
+	ConfigFieldNameWechatApp       = ConfigField{Field: "wechat_app", Description: "微信小程序的相关配置"}
+	ConfigFieldNameInviteReward    = ConfigField{Field: "invite_reward", Description: "邀请奖励的规则"}
+	ConfigFieldNameSignInGuard     = ConfigField{Field: "sign_in_guard", Description: "登陆和验证码的防暴力破解规则"}
+	ConfigFieldNameOAuth           = ConfigField{Field: "oauth", Description: "第三方帐号登陆的服务提供商"}
+	ConfigFieldNameCustomerService = ConfigField{Field: "customer_service", Description: "客服的技能, 接待人数和营业时间"}
+	ConfigFields                   = []ConfigField{ConfigFieldNamePhone, ConfigFieldNameSMTP, ConfigFieldNameWechatApp, ConfigFieldNameInviteReward, ConfigFieldNameSignInGuard, ConfigFieldNameOAuth, ConfigFieldNameCustomerService}
+	oAuthProviderNameRegexp        = regexp.MustCompile(`^[a-z0-9_-]+$`)
 )
 
 type ConfigFieldPhone struct {
@@ -40,6 +47,107 @@ type ConfigFieldSMTP struct {
 	FromEmail string `json:"from_email" validate:"required,email" comment:"发送者地址"`  // 邮件发送者的邮箱地址
 }
 
+type ConfigFieldInviteReward struct {
+	Rules []ConfigFieldInviteRewardRule `json:"rules" validate:"dive" comment:"奖励规则"` // 奖励规则, 同一个状态可以配置多个币种的奖励
+}
+
+type ConfigFieldInviteRewardRule struct {
+	Status   InviteStatus `json:"status" validate:"oneof=0 10 50" comment:"邀请状态"`        // 被邀请人达到这个状态时发放奖励
+	Currency string       `json:"currency" validate:"required,max=16" comment:"币种"`      // 奖励的币种
+	Inviter  string       `json:"inviter" validate:"omitempty,numeric" comment:"邀请人奖励"`  // 邀请人获得的奖励, 为空则不奖励
+	Invitee  string       `json:"invitee" validate:"omitempty,numeric" comment:"被邀请人奖励"` // 被邀请人获得的奖励, 为空则不奖励
+}
+
+// 次数为 0 表示不限制
+type ConfigFieldSignInGuard struct {
+	Window       int `json:"window" validate:"min=1" comment:"失败的统计周期"`              // 统计失败次数的周期, 单位秒, 周期内没有新的失败则重新计数
+	AccountLimit int `json:"account_limit" validate:"min=0" comment:"帐号失败次数"`        // 同一个帐号 (手机号/邮箱) 在周期内失败多少次之后锁定
+	IpLimit      int `json:"ip_limit" validate:"min=0" comment:"IP失败次数"`             // 同一个 IP 在周期内失败多少次之后锁定
+	LockDuration int `json:"lock_duration" validate:"min=1" comment:"锁定时长"`          // 锁定的时长, 单位秒
+	DelayAfter   int `json:"delay_after" validate:"min=0" comment:"开始延迟的失败次数"`       // 连续失败多少次之后, 每次失败都需要等待一段时间才能重试
+	DelayBase    int `json:"delay_base" validate:"min=0" comment:"延迟时间"`             // 第一次需要等待的时间, 单位秒, 之后每次失败翻倍
+	DelayMax     int `json:"delay_max" validate:"min=0" comment:"最长延迟时间"`            // 最长需要等待的时间, 单位秒
+	CodeWindow   int `json:"code_window" validate:"min=1" comment:"验证码的统计周期"`        // 统计验证码发送次数的周期, 单位秒
+	CodeLimit    int `json:"code_limit" validate:"min=0" comment:"验证码的发送次数"`         // 同一个手机号/邮箱在周期内最多发送多少次验证码
+	CodeIpLimit  int `json:"code_ip_limit" validate:"min=0" comment:"同一个IP验证码的发送次数"` // 同一个 IP 在周期内最多发送多少次验证码
+}
+
+type ConfigFieldOAuth struct {
+	Providers []ConfigFieldOAuthProvider `json:"providers" validate:"dive" comment:"服务提供商"` // 服务提供商, 修改之后不需要重启服务
+}
+
+type ConfigFieldOAuthProvider struct {
+	Name         string        `json:"name" validate:"required,max=32" comment:"名称"`                                                     // 名称, 用于登陆地址 /v1/oauth2/{name}, 同一个类型可以配置多个
+	Type         OAuthProvider `json:"type" validate:"required,oneof=github gitlab google facebook twitter openid-connect" comment:"类型"` // 服务提供商的类型
+	Key          string        `json:"key" validate:"required" comment:"Key"`                                                            // client id
+	Secret       string        `json:"secret" validate:"required" comment:"Secret"`                                                      // client secret
+	DiscoveryUrl string        `json:"discovery_url" validate:"omitempty,url" comment:"发现文档地址"`                                          // OpenID Connect 的发现文档地址, 类型为 openid-connect 时必填
+	Scopes       []string      `json:"scopes" comment:"授权范围"`                                                                            // 申请的 scope, 为空则使用默认的 scope
+	Enabled      bool          `json:"enabled" comment:"是否启用"`                                                                           // 是否启用
+}
+
+type ConfigFieldCustomerService struct {
+	Waiters       []ConfigFieldCustomerServiceWaiter       `json:"waiters" validate:"dive" comment:"客服"`               // 没有配置的客服没有技能, 只接待不指定话题的用户
+	OfflineAfter  int                                      `json:"offline_after" validate:"min=0" comment:"排队时长"`      // 排队超过多少秒之后, 用户的消息转为留言, 为 0 则一直排队
+	BusinessHours *ConfigFieldCustomerServiceBusinessHours `json:"business_hours" validate:"omitempty" comment:"营业时间"` // 营业时间之外连接客服, 用户的消息直接转为留言, 为空则不限制
+}
+
+// 每天的营业时间, 结束时间早于开始时间时营业到第二天
+type ConfigFieldCustomerServiceBusinessHours struct {
+	Timezone string `json:"timezone" validate:"omitempty,max=64" comment:"时区"`  // 例如 Asia/Shanghai, 为空则使用服务器的时区
+	Weekdays []int  `json:"weekdays" validate:"dive,min=0,max=6" comment:"营业日"` // 0 为周日, 为空则每天都营业
+	Start    string `json:"start" validate:"required" comment:"开始时间"`           // 格式为 15:04
+	End      string `json:"end" validate:"required" comment:"结束时间"`             // 格式为 15:04, 和开始时间相同则全天营业
+}
+
+type ConfigFieldCustomerServiceWaiter struct {
+	Uid      string   `json:"uid" validate:"required" comment:"客服ID"`              // 客服的用户 ID, 不能重复
+	Skills   []string `json:"skills" validate:"dive,required,max=32" comment:"技能"` // 技能标签, 例如语言或者产品线. 用户连接时指定的话题需要匹配
+	Capacity int      `json:"capacity" validate:"min=0,max=100" comment:"接待人数"`    // 最多同时接待多少个用户, 为 0 则使用默认值
+}
+
+// 某个时间是否在营业时间之内
+func (c *ConfigFieldCustomerServiceBusinessHours) IsOpen(t time.Time) bool {
+	if c.Timezone != "" {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			t = t.In(loc)
+		}
+	}
+
+	minutes := func(clock string) int {
+		v, _ := time.Parse("15:04", clock)
+		return v.Hour()*60 + v.Minute()
+	}
+
+	workday := func(day time.Weekday) bool {
+		if len(c.Weekdays) == 0 {
+			return true
+		}
+		for _, d := range c.Weekdays {
+			if time.Weekday(d) == day {
+				return true
+			}
+		}
+		return false
+	}
+
+	var (
+		now   = t.Hour()*60 + t.Minute()
+		start = minutes(c.Start)
+		end   = minutes(c.End)
+	)
+
+	switch {
+	case start == end:
+		return workday(t.Weekday())
+	case start < end:
+		return workday(t.Weekday()) && now >= start && now < end
+	default:
+		// 营业到第二天, 凌晨的部分属于前一天的营业时间
+		return (now >= start && workday(t.Weekday())) || (now < end && workday((t.Weekday()+6)%7))
+	}
+}
+
 type Config struct {
 	Name      string `gorm:"primary_key;unique;not null;type:varchar(32);index;" json:"name"` // 配置名称
 	Fields    string `gorm:"not null;type:text" json:"fields"`                                // 配置对应的字段
@@ -91,6 +199,77 @@ func (config *Config) IsValidConfigField() error {
 		if err := validator.ValidateStruct(c); err != nil {
 			return err
 		}
+	case ConfigFieldNameInviteReward.Field:
+		c := ConfigFieldInviteReward{}
+		if err := json.Unmarshal([]byte(config.Fields), &c); err != nil {
+			return exception.InvalidParams.New(err.Error())
+		}
+		if err := validator.ValidateStruct(c); err != nil {
+			return err
+		}
+		// 奖励不能为负数
+		for _, rule := range c.Rules {
+			for _, amount := range []string{rule.Inviter, rule.Invitee} {
+				if amount == "" {
+					continue
+				}
+				if d, err := decimal.NewFromString(amount); err != nil || d.IsNegative() {
+					return exception.InvalidParams
+				}
+			}
+		}
+	case ConfigFieldNameSignInGuard.Field:
+		c := ConfigFieldSignInGuard{}
+		if err := json.Unmarshal([]byte(config.Fields), &c); err != nil {
+			return exception.InvalidParams.New(err.Error())
+		}
+		if err := validator.ValidateStruct(c); err != nil {
+			return err
+		}
+	case ConfigFieldNameOAuth.Field:
+		c := ConfigFieldOAuth{}
+		if err := json.Unmarshal([]byte(config.Fields), &c); err != nil {
+			return exception.InvalidParams.New(err.Error())
+		}
+		if err := validator.ValidateStruct(c); err != nil {
+			return err
+		}
+		names := map[string]bool{}
+		for _, provider := range c.Providers {
+			// 名称会出现在地址中, 并且不能重复
+			if !oAuthProviderNameRegexp.MatchString(provider.Name) || names[provider.Name] {
+				return exception.InvalidParams
+			}
+			names[provider.Name] = true
+			if provider.Type == ProviderOpenIdConnect && provider.DiscoveryUrl == "" {
+				return exception.InvalidParams
+			}
+		}
+	case ConfigFieldNameCustomerService.Field:
+		c := ConfigFieldCustomerService{}
+		if err := json.Unmarshal([]byte(config.Fields), &c); err != nil {
+			return exception.InvalidParams.New(err.Error())
+		}
+		if err := validator.ValidateStruct(c); err != nil {
+			return err
+		}
+		uids := map[string]bool{}
+		for _, waiter := range c.Waiters {
+			if uids[waiter.Uid] {
+				return exception.InvalidParams
+			}
+			uids[waiter.Uid] = true
+		}
+		if c.BusinessHours != nil {
+			if _, err := time.LoadLocation(c.BusinessHours.Timezone); err != nil {
+				return exception.InvalidParams.New(err.Error())
+			}
+			for _, clock := range []string{c.BusinessHours.Start, c.BusinessHours.End} {
+				if _, err := time.Parse("15:04", clock); err != nil {
+					return exception.InvalidParams.New(err.Error())
+				}
+			}
+		}
 	default:
 		return exception.InvalidParams
 	}
@@ -0,0 +1,128 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model_test
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestConfig_IsValidConfigField_InviteReward(t *testing.T) {
+	valid := model.Config{
+		Name:   model.ConfigFieldNameInviteReward.Field,
+		Fields: `{"rules":[{"status":0,"currency":"COIN","inviter":"10","invitee":"5"},{"status":50,"currency":"CNY","inviter":"1.5"}]}`,
+	}
+
+	assert.Nil(t, valid.IsValidConfigName())
+	assert.Nil(t, valid.IsValidConfigField())
+
+	for _, fields := range []string{
+		`{"rules":[{"status":1,"currency":"COIN","inviter":"10"}]}`,  // 不存在的状态
+		`{"rules":[{"status":0,"currency":"","inviter":"10"}]}`,      // 缺少币种
+		`{"rules":[{"status":0,"currency":"COIN","inviter":"-10"}]}`, // 负数的奖励
+		`{"rules":[{"status":0,"currency":"COIN","invitee":"abc"}]}`, // 无效的数量
+	} {
+		c := model.Config{Name: model.ConfigFieldNameInviteReward.Field, Fields: fields}
+
+		assert.NotNil(t, c.IsValidConfigField(), fields)
+	}
+}
+
+func TestConfig_IsValidConfigField_SignInGuard(t *testing.T) {
+	valid := model.Config{
+		Name:   model.ConfigFieldNameSignInGuard.Field,
+		Fields: `{"window":900,"account_limit":5,"ip_limit":50,"lock_duration":900,"delay_after":3,"delay_base":1,"delay_max":30,"code_window":3600,"code_limit":10,"code_ip_limit":30}`,
+	}
+
+	assert.Nil(t, valid.IsValidConfigName())
+	assert.Nil(t, valid.IsValidConfigField())
+
+	for _, fields := range []string{
+		`{"window":0,"lock_duration":900,"code_window":3600}`,                   // 缺少统计周期
+		`{"window":900,"lock_duration":0,"code_window":3600}`,                   // 缺少锁定时长
+		`{"window":900,"lock_duration":900,"code_window":3600,"ip_limit":-1}`,   // 负数的次数
+		`{"window":900,"lock_duration":900,"code_window":3600,"delay_max":"1"}`, // 错误的类型
+	} {
+		c := model.Config{Name: model.ConfigFieldNameSignInGuard.Field, Fields: fields}
+
+		assert.NotNil(t, c.IsValidConfigField(), fields)
+	}
+}
+
+func TestConfig_IsValidConfigField_OAuth(t *testing.T) {
+	valid := model.Config{
+		Name:   model.ConfigFieldNameOAuth.Field,
+		Fields: `{"providers":[{"name":"github","type":"github","key":"key","secret":"secret","enabled":true},{"name":"keycloak","type":"openid-connect","key":"key","secret":"secret","discovery_url":"https://example.com/.well-known/openid-configuration","scopes":["openid","email"]}]}`,
+	}
+
+	assert.Nil(t, valid.IsValidConfigName())
+	assert.Nil(t, valid.IsValidConfigField())
+
+	for _, fields := range []string{
+		`{"providers":[{"name":"github","type":"unknown","key":"key","secret":"secret"}]}`,                                                                // 不支持的类型
+		`{"providers":[{"name":"github","type":"github","key":"","secret":"secret"}]}`,                                                                    // 缺少 key
+		`{"providers":[{"name":"Git Hub","type":"github","key":"key","secret":"secret"}]}`,                                                                // 名称不能出现在地址中
+		`{"providers":[{"name":"github","type":"github","key":"key","secret":"secret"},{"name":"github","type":"gitlab","key":"key","secret":"secret"}]}`, // 名称重复
+		`{"providers":[{"name":"keycloak","type":"openid-connect","key":"key","secret":"secret"}]}`,                                                       // 缺少发现文档地址
+	} {
+		c := model.Config{Name: model.ConfigFieldNameOAuth.Field, Fields: fields}
+
+		assert.NotNil(t, c.IsValidConfigField(), fields)
+	}
+}
+
+func TestConfig_IsValidConfigField_CustomerService(t *testing.T) {
+	valid := model.Config{
+		Name:   model.ConfigFieldNameCustomerService.Field,
+		Fields: `{"waiters":[{"uid":"1","skills":["en","wallet"],"capacity":10},{"uid":"2","skills":[]}],"offline_after":300,"business_hours":{"start":"09:00","end":"18:00","weekdays":[1,2,3,4,5]}}`,
+	}
+
+	assert.Nil(t, valid.IsValidConfigName())
+	assert.Nil(t, valid.IsValidConfigField())
+
+	for _, fields := range []string{
+		`{"waiters":[{"uid":"","skills":["en"]}]}`,                                  // 缺少客服 ID
+		`{"waiters":[{"uid":"1","skills":[""]}]}`,                                   // 空的技能
+		`{"waiters":[{"uid":"1","capacity":-1}]}`,                                   // 接待人数不能为负数
+		`{"waiters":[{"uid":"1","skills":["en"]},{"uid":"1"}]}`,                     // 客服重复
+		`{"offline_after":-1}`,                                                      // 排队时长不能为负数
+		`{"business_hours":{"start":"9:00"}}`,                                       // 缺少结束时间
+		`{"business_hours":{"start":"25:00","end":"18:00"}}`,                        // 时间格式不正确
+		`{"business_hours":{"start":"09:00","end":"18:00","weekdays":[7]}}`,         // 没有这一天
+		`{"business_hours":{"start":"09:00","end":"18:00","timezone":"Mars/Base"}}`, // 时区不存在
+	} {
+		c := model.Config{Name: model.ConfigFieldNameCustomerService.Field, Fields: fields}
+
+		assert.NotNil(t, c.IsValidConfigField(), fields)
+	}
+}
+
+func TestConfigFieldCustomerServiceBusinessHours_IsOpen(t *testing.T) {
+	at := func(s string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		return v
+	}
+
+	// 2020-06-01 是周一
+	day := model.ConfigFieldCustomerServiceBusinessHours{Start: "09:00", End: "18:00", Weekdays: []int{1, 2, 3, 4, 5}}
+
+	assert.True(t, day.IsOpen(at("2020-06-01 09:00")))
+	assert.True(t, day.IsOpen(at("2020-06-01 17:59")))
+	assert.False(t, day.IsOpen(at("2020-06-01 18:00")))
+	assert.False(t, day.IsOpen(at("2020-06-01 08:59")))
+	assert.False(t, day.IsOpen(at("2020-06-06 10:00"))) // 周六
+
+	// 营业到第二天, 周五晚上营业到周六凌晨
+	night := model.ConfigFieldCustomerServiceBusinessHours{Start: "22:00", End: "02:00", Weekdays: []int{5}}
+
+	assert.True(t, night.IsOpen(at("2020-06-05 23:00")))
+	assert.True(t, night.IsOpen(at("2020-06-06 01:00")))
+	assert.False(t, night.IsOpen(at("2020-06-06 23:00")))
+	assert.False(t, night.IsOpen(at("2020-06-05 01:00")))
+
+	// 全天营业
+	all := model.ConfigFieldCustomerServiceBusinessHours{Start: "00:00", End: "00:00"}
+
+	assert.True(t, all.IsOpen(at("2020-06-07 03:00")))
+}
@@ -0,0 +1,36 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"time"
+)
+
+// 币种注册表, 每个币种对应一组 钱包/转账记录/财务流水 表
+type Currency struct {
+	Code      string `gorm:"primary_key;not null;unique;index;type:varchar(16)" json:"code"` // 币种代码, 大写, 例如 CNY, 同时用于生成表名
+	Name      string `gorm:"not null;type:varchar(32)" json:"name"`                          // 显示名称
+	Precision int    `gorm:"not null" json:"precision"`                                      // 金额允许的最大小数位数, 不能超过 decimal.Places
+	Enabled   bool   `gorm:"not null" json:"enabled"`                                        // 是否启用, 停用的币种不能再产生新的交易
+	BuiltIn   bool   `gorm:"not null" json:"built_in"`                                       // 是否是内置的币种
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var (
+	// 内置的币种, 在数据库迁移时写入
+	DefaultCurrencies = []Currency{
+		{Code: WalletCNY, Name: "人民币", Precision: decimal.Places, Enabled: true, BuiltIn: true},
+		{Code: WalletUSD, Name: "美元", Precision: decimal.Places, Enabled: true, BuiltIn: true},
+		{Code: WalletCOIN, Name: "积分", Precision: decimal.Places, Enabled: true, BuiltIn: true},
+	}
+)
+
+func (news *Currency) TableName() string {
+	return "currency"
+}
+
+// 金额的小数位数是否在该币种允许的精度之内
+func (news *Currency) ValidAmount(amount decimal.Decimal) bool {
+	return amount.Truncate(news.Precision).Equal(amount)
+}
@@ -0,0 +1,31 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCurrency_ValidAmount(t *testing.T) {
+	c := model.Currency{Code: "CNY", Precision: 2}
+
+	assert.True(t, c.ValidAmount(decimal.RequireFromString("1")))
+	assert.True(t, c.ValidAmount(decimal.RequireFromString("1.2")))
+	assert.True(t, c.ValidAmount(decimal.RequireFromString("1.23")))
+	assert.True(t, c.ValidAmount(decimal.RequireFromString("-1.23")))
+	assert.False(t, c.ValidAmount(decimal.RequireFromString("1.234")))
+	assert.False(t, c.ValidAmount(decimal.RequireFromString("0.001")))
+
+	integer := model.Currency{Code: "COIN", Precision: 0}
+
+	assert.True(t, integer.ValidAmount(decimal.RequireFromString("100")))
+	assert.False(t, integer.ValidAmount(decimal.RequireFromString("0.5")))
+}
+
+func TestTableName(t *testing.T) {
+	assert.Equal(t, "wallet_usdt", model.WalletTableName("USDT"))
+	assert.Equal(t, "transfer_log_usdt", model.TransferLogTableName("USDT"))
+	assert.Equal(t, "finance_log_usdt", model.FinanceLogTableName("usdt"))
+}
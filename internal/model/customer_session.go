@@ -22,17 +22,21 @@ var (
 
 // 客服聊天的会话记录
 type CustomerSession struct {
-	Id        string                `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 会话 ID
-	Uid       string                `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 用户ID
-	User      User                  `gorm:"foreignkey:Uid" json:"user"`                                   // **外键**
-	WaiterID  string                `gorm:"not null;index;type:varchar(32)" json:"waiter_id"`             // 客服 ID
-	Waiter    User                  `gorm:"foreignkey:WaiterID" json:"waiter"`                            //  **外键**
-	Items     []CustomerSessionItem `gorm:"foreignkey:SessionID" json:"items"`                            //  **外键**
-	ClosedAt  *time.Time            `gorm:"null;index;" json:"closed_at"`                                 // 会话关闭时间
-	Rate      *uint                 `gorm:"null;index;" json:"rate"`                                      // 用户对于本次会话的评分
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time `sql:"index"`
+	Id             string                `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 会话 ID
+	Uid            string                `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 用户ID
+	User           User                  `gorm:"foreignkey:Uid" json:"user"`                                   // **外键**
+	WaiterID       string                `gorm:"not null;index;type:varchar(32)" json:"waiter_id"`             // 客服 ID
+	Waiter         User                  `gorm:"foreignkey:WaiterID" json:"waiter"`                            //  **外键**
+	Items          []CustomerSessionItem `gorm:"foreignkey:SessionID" json:"items"`                            //  **外键**
+	ClosedAt       *time.Time            `gorm:"null;index;" json:"closed_at"`                                 // 会话关闭时间
+	Rate           *uint                 `gorm:"null;index;" json:"rate"`                                      // 用户对于本次会话的评分
+	TransferFrom   *string               `gorm:"null;index;type:varchar(32)" json:"transfer_from"`             // 转接之前的会话 ID, 由其他客服转接过来的会话才有
+	TransferBy     *string               `gorm:"null;index;type:varchar(32)" json:"transfer_by"`               // 发起转接的客服或者主管的 ID
+	TransferReason *string               `gorm:"null;type:varchar(255)" json:"transfer_reason"`                // 转接的原因
+	TransferredAt  *time.Time            `gorm:"null;index;" json:"transferred_at"`                            // 会话被转接出去的时间, 同时会关闭会话
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time `sql:"index"`
 }
 
 func (c *CustomerSession) TableName() string {
@@ -0,0 +1,66 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 币种之间的兑换汇率, 由管理员设置
+// 每个方向单独设置, 例如 CNY->USD 和 USD->CNY 是两条记录
+type ExchangeRate struct {
+	Id        string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`              // 汇率ID
+	From      string          `gorm:"not null;unique_index:uix_exchange_rate_pair;type:varchar(16)" json:"from"` // 兑出的币种
+	To        string          `gorm:"not null;unique_index:uix_exchange_rate_pair;type:varchar(16)" json:"to"`   // 兑入的币种
+	Rate      decimal.Decimal `gorm:"not null;type:numeric" json:"rate"`                                         // 1 个单位的 From 可以兑换多少 To
+	FeeRate   decimal.Decimal `gorm:"not null;type:numeric" json:"fee_rate"`                                     // 手续费率, 从兑换所得中扣除, 例如 0.001 表示 0.1%
+	Enabled   bool            `gorm:"not null" json:"enabled"`                                                   // 是否启用
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// 兑换记录, 对应两条财务流水
+type Exchange struct {
+	Id        string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 兑换ID, 同时也是两条财务流水的订单ID
+	Uid       string          `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 用户ID
+	From      string          `gorm:"not null;type:varchar(16)" json:"from"`                        // 兑出的币种
+	To        string          `gorm:"not null;type:varchar(16)" json:"to"`                          // 兑入的币种
+	Amount    decimal.Decimal `gorm:"not null;type:numeric" json:"amount"`                          // 兑出的数量
+	Rate      decimal.Decimal `gorm:"not null;type:numeric" json:"rate"`                            // 成交的汇率
+	FeeRate   decimal.Decimal `gorm:"not null;type:numeric" json:"fee_rate"`                        // 成交的手续费率
+	Fee       decimal.Decimal `gorm:"not null;type:numeric" json:"fee"`                             // 手续费, 以兑入的币种计算
+	Received  decimal.Decimal `gorm:"not null;type:numeric" json:"received"`                        // 实际兑入的数量
+	FromLogId string          `gorm:"null;type:varchar(32)" json:"from_log_id"`                     // 兑出币种的财务流水ID
+	ToLogId   string          `gorm:"null;type:varchar(32)" json:"to_log_id"`                       // 兑入币种的财务流水ID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (news *ExchangeRate) TableName() string {
+	return "exchange_rate"
+}
+
+func (news *ExchangeRate) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+// 计算兑换 amount 个 From 能得到多少 To
+// 兑入的数量按照兑入币种的精度向下取整, 取整舍去的部分计入手续费
+func (news *ExchangeRate) Quote(amount decimal.Decimal, precision int) (fee decimal.Decimal, received decimal.Decimal) {
+	gross := amount.Mul(news.Rate)
+
+	received = gross.Sub(gross.Mul(news.FeeRate)).Truncate(precision)
+	fee = gross.Sub(received)
+
+	return
+}
+
+func (news *Exchange) TableName() string {
+	return "exchange"
+}
+
+func (news *Exchange) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
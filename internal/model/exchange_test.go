@@ -0,0 +1,32 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExchangeRate_Quote(t *testing.T) {
+	rate := model.ExchangeRate{
+		From:    "USD",
+		To:      "CNY",
+		Rate:    decimal.RequireFromString("7.1234"),
+		FeeRate: decimal.RequireFromString("0.001"),
+	}
+
+	fee, received := rate.Quote(decimal.RequireFromString("100"), 2)
+
+	// 712.34 - 0.71234 = 711.62766, 按精度向下取整
+	assert.Equal(t, "711.62000000", received.String())
+	assert.Equal(t, "0.72000000", fee.String())
+
+	// 没有手续费
+	free := model.ExchangeRate{Rate: decimal.RequireFromString("0.5"), FeeRate: decimal.Zero}
+
+	fee, received = free.Quote(decimal.RequireFromString("3"), 0)
+
+	assert.Equal(t, "1.00000000", received.String())
+	assert.Equal(t, "0.50000000", fee.String())
+}
@@ -0,0 +1,32 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 邀请奖励的发放记录
+// 同一个邀请在同一个状态下的同一个币种只能发放一次, 由唯一索引保证
+type InviteReward struct {
+	Id            string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`              // 奖励ID, 同时也是财务流水的订单ID
+	InviteId      string          `gorm:"not null;unique_index:uix_invite_reward;type:varchar(32)" json:"invite_id"` // 对应的邀请记录
+	Status        InviteStatus    `gorm:"not null;unique_index:uix_invite_reward" json:"status"`                     // 触发奖励的邀请状态
+	Currency      string          `gorm:"not null;unique_index:uix_invite_reward;type:varchar(16)" json:"currency"`  // 奖励的币种
+	Inviter       string          `gorm:"not null;index;type:varchar(32)" json:"inviter"`                            // 邀请人
+	Invitee       string          `gorm:"not null;index;type:varchar(32)" json:"invitee"`                            // 被邀请人
+	InviterAmount decimal.Decimal `gorm:"not null;type:numeric" json:"inviter_amount"`                               // 邀请人获得的奖励
+	InviteeAmount decimal.Decimal `gorm:"not null;type:numeric" json:"invitee_amount"`                               // 被邀请人获得的奖励
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (news *InviteReward) TableName() string {
+	return "invite_reward"
+}
+
+func (news *InviteReward) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
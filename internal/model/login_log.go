@@ -11,15 +11,19 @@ type LoginLogType int
 type LoginLogCommand int
 
 const (
-	LoginLogTypeUserName         LoginLogType    = 0 // 用户名登陆
-	LoginLogTypeTel                                  // 手机登陆
-	LoginLogTypeEmail                                // 邮箱登陆
-	LoginLogTypeThird                                // 第三方登陆
-	LoginLogTypeWechat                               // 微信登陆
-	LoginLogCommandLoginSuccess  LoginLogCommand = 0 // 登陆成功
-	LoginLogCommandLogoutSuccess                     // 登出成功
-	LoginLogCommandLoginFail                         // 登陆失败
-	LoginLogCommandLogoutFail                        // 登出失败
+	LoginLogTypeUserName LoginLogType = iota // 用户名登陆
+	LoginLogTypeTel                          // 手机登陆
+	LoginLogTypeEmail                        // 邮箱登陆
+	LoginLogTypeThird                        // 第三方登陆
+	LoginLogTypeWechat                       // 微信登陆
+	LoginLogTypeWebAuthn                     // 通行密钥 (WebAuthn) 登陆
+)
+
+const (
+	LoginLogCommandLoginSuccess  LoginLogCommand = iota // 登陆成功
+	LoginLogCommandLogoutSuccess                        // 登出成功
+	LoginLogCommandLoginFail                            // 登陆失败
+	LoginLogCommandLogoutFail                           // 登出失败
 )
 
 type LoginLog struct {
@@ -16,18 +16,21 @@ var (
 	ProviderTwitter  OAuthProvider = "twitter"
 	ProviderFacebook OAuthProvider = "facebook"
 	ProviderGoogle   OAuthProvider = "google"
-	providerMap                    = map[OAuthProvider]bool{
-		ProviderGithub:   true,
-		ProviderGitlab:   true,
-		ProviderTwitter:  true,
-		ProviderFacebook: true,
-		ProviderGoogle:   true,
+	// 通用的 OpenID Connect, 根据发现文档获取各个地址
+	ProviderOpenIdConnect OAuthProvider = "openid-connect"
+	providerMap                         = map[OAuthProvider]bool{
+		ProviderGithub:        true,
+		ProviderGitlab:        true,
+		ProviderTwitter:       true,
+		ProviderFacebook:      true,
+		ProviderGoogle:        true,
+		ProviderOpenIdConnect: true,
 	}
 )
 
 type OAuth struct {
 	Id       string        `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`    // ID
-	Provider OAuthProvider `gorm:"not null;index;unique(user_id);type:varchar(32)" json:"provider"` // 绑定的服务提供商
+	Provider OAuthProvider `gorm:"not null;index;unique(user_id);type:varchar(32)" json:"provider"` // 绑定的服务提供商, 即配置中心里服务提供商的名称
 	Uid      string        `gorm:"not null;index;unique(provider);type:varchar(32)" json:"uid"`     // 对应的平台 UID
 
 	// 以下是 oAuth 返回的字段
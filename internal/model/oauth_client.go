@@ -0,0 +1,100 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"time"
+)
+
+type OAuthClientStatus int
+
+const (
+	OAuthClientStatusDisabled OAuthClientStatus = -1 // 已停用, 不能再发起授权, 已签发的 token 也会失效
+	OAuthClientStatusEnabled  OAuthClientStatus = 1  // 正常
+)
+
+// 接入的第三方应用, 作为身份提供方 (OpenID Connect) 时使用
+type OAuthClient struct {
+	Id           string            `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 应用ID, 即 client_id
+	Name         string            `gorm:"not null;type:varchar(36)" json:"name"`                        // 应用名称, 显示在授权页面
+	Description  *string           `gorm:"null;type:varchar(255)" json:"description"`                    // 应用描述
+	Logo         *string           `gorm:"null;type:varchar(255)" json:"logo"`                           // 应用图标
+	Homepage     *string           `gorm:"null;type:varchar(255)" json:"homepage"`                       // 应用主页
+	Secret       string            `gorm:"not null;type:varchar(64)" json:"-"`                           // 应用密钥的 hash, 公开应用为空
+	Public       bool              `gorm:"not null;" json:"public"`                                      // 是否是公开应用 (单页应用/移动端), 公开应用没有密钥, 必须使用 PKCE
+	RedirectUris pq.StringArray    `gorm:"not null;type:varchar(255)[]" json:"redirect_uris"`            // 允许的回调地址, 必须完全匹配
+	Scopes       pq.StringArray    `gorm:"not null;type:varchar(32)[]" json:"scopes"`                    // 允许申请的 scope
+	Status       OAuthClientStatus `gorm:"not null;" json:"status"`                                      // 状态
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time `sql:"index"`
+}
+
+func (o *OAuthClient) TableName() string {
+	return "oauth_client"
+}
+
+func (o *OAuthClient) BeforeCreate(scope *gorm.Scope) (err error) {
+	err = scope.SetColumn("id", util.GenerateId())
+	return
+}
+
+// 回调地址是否已经登记
+func (o *OAuthClient) HasRedirectUri(uri string) bool {
+	for _, v := range o.RedirectUris {
+		if v == uri {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 是否允许申请这个 scope
+func (o *OAuthClient) HasScope(scope string) bool {
+	for _, v := range o.Scopes {
+		if v == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 用户同意给应用的授权, 再次授权相同或更少的 scope 时不需要用户确认
+type OAuthConsent struct {
+	Id        string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`                        // ID
+	Uid       string         `gorm:"not null;unique_index:oauth_consent_uid_client_id;type:varchar(32)" json:"uid"`       // 用户ID
+	ClientId  string         `gorm:"not null;unique_index:oauth_consent_uid_client_id;type:varchar(32)" json:"client_id"` // 应用ID
+	Scopes    pq.StringArray `gorm:"not null;type:varchar(32)[]" json:"scopes"`                                           // 已同意的 scope
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (o *OAuthConsent) TableName() string {
+	return "oauth_consent"
+}
+
+func (o *OAuthConsent) BeforeCreate(scope *gorm.Scope) (err error) {
+	err = scope.SetColumn("id", util.GenerateId())
+	return
+}
+
+// 是否已经同意过这些 scope
+func (o *OAuthConsent) Covers(scopes []string) bool {
+	granted := map[string]bool{}
+
+	for _, v := range o.Scopes {
+		granted[v] = true
+	}
+
+	for _, v := range scopes {
+		if !granted[v] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,72 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type ReconciliationStatus int
+
+const (
+	ReconciliationStatusRunning  ReconciliationStatus = iota // 对账中
+	ReconciliationStatusBalanced                             // 对账完成, 账目一致
+	ReconciliationStatusMismatch                             // 对账完成, 发现差异
+	ReconciliationStatusFailed                               // 对账过程中出错
+)
+
+type ReconciliationKind string
+
+const (
+	ReconciliationKindInvalidLog      ReconciliationKind = "invalid_log"      // 流水本身不平, before + mutation != after
+	ReconciliationKindChainGap        ReconciliationKind = "chain_gap"        // 流水的 before 与上一条流水的 after 不一致
+	ReconciliationKindBalanceMismatch ReconciliationKind = "balance_mismatch" // 钱包的余额与最后一条流水的 after 不一致
+	ReconciliationKindWalletMissing   ReconciliationKind = "wallet_missing"   // 存在流水, 但是找不到对应的钱包
+)
+
+// 对账记录, 每次对账产生一条
+type Reconciliation struct {
+	Id            string               `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 对账ID
+	Status        ReconciliationStatus `gorm:"not null;index" json:"status"`                                 // 对账状态
+	Wallets       int64                `gorm:"not null" json:"wallets"`                                      // 检查的钱包数量
+	Logs          int64                `gorm:"not null" json:"logs"`                                         // 检查的流水数量
+	Discrepancies int64                `gorm:"not null" json:"discrepancies"`                                // 发现的差异数量
+	Error         *string              `gorm:"null;type:text" json:"error"`                                  // 对账失败的原因
+	FinishedAt    *time.Time           `gorm:"null" json:"finished_at"`                                      // 对账完成的时间
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// 对账发现的差异, 一条差异对应一个钱包或者一条流水
+type ReconciliationItem struct {
+	Id               string             `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 差异ID
+	ReconciliationId string             `gorm:"not null;index;type:varchar(32)" json:"reconciliation_id"`     // 对应的对账ID
+	Currency         string             `gorm:"not null;type:varchar(16)" json:"currency"`                    // 币种
+	Uid              string             `gorm:"not null;index;type:varchar(32)" json:"uid"`                   // 钱包对应的用户
+	Kind             ReconciliationKind `gorm:"not null;type:varchar(32)" json:"kind"`                        // 差异类型
+	LogId            *string            `gorm:"null;type:varchar(32)" json:"log_id"`                          // 出现差异的流水, 钱包余额不一致时为空
+	ExpectedBalance  decimal.Decimal    `gorm:"not null;type:numeric" json:"expected_balance"`                // 根据流水推算的可用余额
+	ActualBalance    decimal.Decimal    `gorm:"not null;type:numeric" json:"actual_balance"`                  // 实际的可用余额
+	ExpectedFrozen   decimal.Decimal    `gorm:"not null;type:numeric" json:"expected_frozen"`                 // 根据流水推算的冻结余额
+	ActualFrozen     decimal.Decimal    `gorm:"not null;type:numeric" json:"actual_frozen"`                   // 实际的冻结余额
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (news *Reconciliation) TableName() string {
+	return "reconciliation"
+}
+
+func (news *Reconciliation) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
+
+func (news *ReconciliationItem) TableName() string {
+	return "reconciliation_item"
+}
+
+func (news *ReconciliationItem) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("id", util.GenerateId())
+}
@@ -17,11 +17,12 @@ type ReportTypeDetail struct {
 }
 
 var (
-	ReportTypeBug        ReportType = "bug"        // BUG 反馈
-	ReportTypeFeature    ReportType = "feature"    // 新功能请求
-	ReportTypeSuggestion ReportType = "suggestion" // 建议
-	ReportTypeOther      ReportType = "other"      // 其他
-	ReportTypes                     = []ReportTypeDetail{
+	ReportTypeBug             ReportType = "bug"              // BUG 反馈
+	ReportTypeFeature         ReportType = "feature"          // 新功能请求
+	ReportTypeSuggestion      ReportType = "suggestion"       // 建议
+	ReportTypeOther           ReportType = "other"            // 其他
+	ReportTypeCustomerService ReportType = "customer_service" // 客服留言, 没有客服接待时用户的消息转为留言
+	ReportTypes                          = []ReportTypeDetail{
 		{
 			Type:        ReportTypeBug,
 			Description: "BUG 反馈",
@@ -38,6 +39,10 @@ var (
 			Type:        ReportTypeOther,
 			Description: "其他",
 		},
+		{
+			Type:        ReportTypeCustomerService,
+			Description: "客服留言",
+		},
 	}
 
 	ReportStatusPending ReportStatus = 0 // 初始状态
@@ -54,6 +59,7 @@ type Report struct {
 	Status      ReportStatus   `gorm:"not null;" json:"status"`                                      // 当前报告的处理状态
 	Screenshots pq.StringArray `gorm:"type:varchar(255)[]" json:"screenshots"`                       // 反馈的截图
 	Locked      bool           `gorm:"not null;" json:"locked"`                                      // 是否已锁定，锁定之后用户不能再更改状态
+	WaiterID    *string        `gorm:"null;index;type:varchar(32)" json:"waiter_id"`                 // 回复该留言的客服 ID, 只有客服留言才有
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   *time.Time `sql:"index"`
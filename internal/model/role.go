@@ -20,6 +20,10 @@ var (
 	DefaultWaiter = role.New("waiter", "客服", []accession.Accession{
 		*accession.CustomerServiceConnect,
 	})
+	DefaultSupervisor = role.New("supervisor", "客服主管", []accession.Accession{
+		*accession.CustomerServiceConnect,
+		*accession.CustomerServiceSupervise,
+	})
 )
 
 type Role struct {
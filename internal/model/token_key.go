@@ -0,0 +1,21 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"time"
+)
+
+// token 的签名密钥, 用户和管理员各自轮换
+// 密钥轮换之后不会立即删除, 用于验证之前签发的 token
+type TokenKey struct {
+	Id          string    `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 密钥ID, 即 token 头部的 kid
+	State       string    `gorm:"not null;index;type:varchar(16)" json:"state"`                 // 用于签发哪种身份的 token
+	PrivateKey  string    `gorm:"not null;type:text" json:"-"`                                  // 使用 TOKEN_KEY_ENCRYPTION_KEY 加密的 PEM 格式私钥
+	ActivatedAt time.Time `gorm:"not null" json:"activated_at"`                                 // 开始签发的时间
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (news *TokenKey) TableName() string {
+	return "token_key"
+}
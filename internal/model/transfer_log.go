@@ -2,6 +2,7 @@
 package model
 
 import (
+	"github.com/axetroy/go-server/internal/library/decimal"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/jinzhu/gorm"
 	"strings"
@@ -15,63 +16,46 @@ var (
 	TransferStatusReject         TransferStatus = -1 // 收款方拒接接受
 	TransferStatusWaitForConfirm TransferStatus = 0  // 等待收款方确认
 	TransferStatusConfirmed      TransferStatus = 1  // 收款方已确认
-
-	TransferLogCnyTableName  = transferLogTablePrefix + strings.ToLower(WalletCNY)  // 人民币表名
-	TransferLogUsdTableName  = transferLogTablePrefix + strings.ToLower(WalletUSD)  // 美元表名
-	TransferLogCoinTableName = transferLogTablePrefix + strings.ToLower(WalletCOIN) // 积分表名
-
-	TransferTableNames = []string{ // 所有的表名
-		TransferLogCnyTableName,
-		TransferLogUsdTableName,
-		TransferLogCoinTableName,
-	}
-
-	TransferLogMap = map[string]interface{}{
-		WalletCNY:  TransferLogCny{},
-		WalletUSD:  TransferLogUsd{},
-		WalletCOIN: TransferLogCoin{},
-	}
 )
 
 type TransferLog struct {
-	Id           string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 转账ID
-	Currency     string         `gorm:"not null;index;" json:"currency"`                              // 转账币种
-	From         string         `gorm:"not null;index;type:varchar(32)" json:"from"`                  // 汇款人
-	To           string         `gorm:"not null;index;type:varchar(32)" json:"to"`                    // 收款人
-	Amount       string         `gorm:"not null;type:numeric" json:"amount"`                          // 转账数量
-	Status       TransferStatus `gorm:"not null" json:"status"`                                       // 转账状态
-	Note         *string        `gorm:"null;type:varchar(128)" json:"note"`                           // 转账备注
-	SnapshotFrom *string        `gorm:"null" json:"-"`                                                // 转账者的钱包快照
-	SnapshotTo   *string        `gorm:"null" json:"-"`                                                // 收款人的钱包快照
+	Id           string          `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"` // 转账ID
+	Currency     string          `gorm:"not null;index;" json:"currency"`                              // 转账币种
+	From         string          `gorm:"not null;index;type:varchar(32)" json:"from"`                  // 汇款人
+	To           string          `gorm:"not null;index;type:varchar(32)" json:"to"`                    // 收款人
+	Amount       decimal.Decimal `gorm:"not null;type:numeric" json:"amount"`                          // 转账数量
+	Status       TransferStatus  `gorm:"not null" json:"status"`                                       // 转账状态
+	Note         *string         `gorm:"null;type:varchar(128)" json:"note"`                           // 转账备注
+	SnapshotFrom *string         `gorm:"null" json:"-"`                                                // 转账者的钱包快照
+	SnapshotTo   *string         `gorm:"null" json:"-"`                                                // 收款人的钱包快照
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	DeletedAt    *time.Time `sql:"index" json:"-"`
 }
 
-type TransferLogCny struct {
-	TransferLog
-}
-
-type TransferLogUsd struct {
-	TransferLog
+// 转账的幂等键, 所有币种共用一张表, 记录第一次请求的参数
+// 客户端复用幂等键时, 参数必须完全一致才返回原来的转账记录
+type TransferIdempotency struct {
+	Id         string          `gorm:"primary_key;unique;not null;index;type:varchar(128)" json:"id"` // 幂等键, 格式为 "<汇款人>:<Idempotency-Key>"
+	TransferId string          `gorm:"not null;type:varchar(32)" json:"transfer_id"`                  // 转账ID
+	Currency   string          `gorm:"not null;type:varchar(16)" json:"currency"`                     // 转账币种
+	To         string          `gorm:"not null;type:varchar(32)" json:"to"`                           // 收款人
+	Amount     decimal.Decimal `gorm:"not null;type:numeric" json:"amount"`                           // 转账数量
+	Pending    bool            `gorm:"not null" json:"pending"`                                       // 是否需要收款方确认
+	Note       *string         `gorm:"null;type:varchar(128)" json:"note"`                            // 转账备注
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
-type TransferLogCoin struct {
-	TransferLog
+func (news *TransferIdempotency) TableName() string {
+	return "transfer_idempotency"
 }
 
 func (news *TransferLog) BeforeCreate(scope *gorm.Scope) error {
 	return scope.SetColumn("id", util.GenerateId())
 }
 
-func (news *TransferLogCny) TableName() string {
-	return TransferLogCnyTableName
-}
-
-func (news *TransferLogUsd) TableName() string {
-	return TransferLogUsdTableName
-}
-
-func (news *TransferLogCoin) TableName() string {
-	return TransferLogCoinTableName
+// 获取币种对应的转账记录表名
+func TransferLogTableName(currency string) string {
+	return transferLogTablePrefix + strings.ToLower(currency)
 }
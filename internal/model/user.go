@@ -29,8 +29,8 @@ const (
 type User struct {
 	Id                      string         `gorm:"primary_key;not null;unique;index;type:varchar(32)" json:"id"` // 用户ID
 	Username                string         `gorm:"not null;type:varchar(36)unique;index" json:"username"`        // 用户名
-	Password                string         `gorm:"not null;type:varchar(36);index" json:"password"`              // 登陆密码
-	PayPassword             *string        `gorm:"null;type:varchar(36)" json:"pay_password"`                    // 支付密码
+	Password                string         `gorm:"not null;type:varchar(255);index" json:"password"`             // 登陆密码
+	PayPassword             *string        `gorm:"null;type:varchar(255)" json:"pay_password"`                   // 支付密码
 	Nickname                *string        `gorm:"null;type:varchar(36)" json:"nickname"`                        // 昵称
 	Phone                   *string        `gorm:"null;unique;type:varchar(16);index" json:"phone"`              // 手机号
 	Email                   *string        `gorm:"null;unique;type:varchar(36);index" json:"email"`              // 邮箱
@@ -41,6 +41,7 @@ type User struct {
 	Gender                  Gender         `gorm:"default(0)" json:"gender"`                                     // 性别
 	EnableTOTP              bool           `gorm:"not null;" json:"enable_totp"`                                 // 是否启用双重身份认证
 	Secret                  string         `gorm:"not null;type:varchar(32)" json:"secret"`                      // 用户自己的密钥
+	RecoveryCodes           pq.StringArray `gorm:"null;type:varchar(64)[]" json:"-"`                             // 双重身份认证的恢复码, 只存储 hash, 每个只能使用一次
 	InviteCode              string         `gorm:"not null;unique;type:varchar(8)" json:"invite_code"`           // 用户的邀请码，邀请码唯一
 	UsernameRenameRemaining int            `gorm:"not null;" json:"username_rename_remaining"`                   // 用户名还有几次重新更改的机会， 主要是如果用第三方注册登陆，则用户名随机生成，这里给用户一个重新命名的机会
 
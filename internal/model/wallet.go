@@ -2,61 +2,64 @@
 package model
 
 import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
 	"strings"
 	"time"
 )
 
 var (
 	walletTablePrefix = "wallet_"
-	WalletCNY         = "CNY"
-	WalletUSD         = "USD"
-	WalletCOIN        = "COIN"
-	Wallets           = []string{WalletCNY, WalletUSD, WalletCOIN}
-
-	WalletCnyTableName  = walletTablePrefix + strings.ToLower(WalletCNY)  // 人民币表名
-	WalletUsdTableName  = walletTablePrefix + strings.ToLower(WalletUSD)  // 美元表名
-	WalletCoinTableName = walletTablePrefix + strings.ToLower(WalletCOIN) // 积分表名
-
-	WalletTableNames = []string{ // 所有的表名
-		WalletCnyTableName,
-		WalletUsdTableName,
-		WalletCoinTableName,
-	}
+
+	// 内置的币种, 其他币种可以由管理员在币种注册表中添加
+	WalletCNY  = "CNY"
+	WalletUSD  = "USD"
+	WalletCOIN = "COIN"
 )
 
 type Wallet struct {
-	Id        string  `gorm:"primary_key;unique;notnull;index;type:varchar(32)" json:"id"` // 用户ID
-	Currency  string  `gorm:"not null;type:varchar(12)" json:"currency"`                   // 钱包币种
-	Balance   float64 `gorm:"not null;type:numeric" json:"balance"`                        // 可用余额
-	Frozen    float64 `gorm:"not null;type:numeric" json:"frozen"`                         // 冻结余额
+	Id        string          `gorm:"primary_key;unique;notnull;index;type:varchar(32)" json:"id"` // 用户ID
+	Currency  string          `gorm:"not null;type:varchar(12)" json:"currency"`                   // 钱包币种
+	Balance   decimal.Decimal `gorm:"not null;type:numeric" json:"balance"`                        // 可用余额
+	Frozen    decimal.Decimal `gorm:"not null;type:numeric" json:"frozen"`                         // 冻结余额
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt *time.Time `sql:"index"`
 }
 
-// 人民币
-type WalletCny struct {
-	Wallet
+// 获取币种对应的钱包表名, 每个币种的钱包单独一个表
+func WalletTableName(currency string) string {
+	return walletTablePrefix + strings.ToLower(currency)
 }
 
-// 美元
-type WalletUsd struct {
-	Wallet
-}
+// 变动钱包的可用余额和冻结余额, 正数则为加, 负数为减
+// 返回记录了变动前后余额的流水, 调用方需要补充流水的类型/订单等信息后写入数据库
+// 变动后的余额不能为负数
+func (w *Wallet) Mutate(balanceMutation decimal.Decimal, frozenMutation decimal.Decimal) (*FinanceLog, error) {
+	afterBalance := w.Balance.Add(balanceMutation)
+	afterFrozen := w.Frozen.Add(frozenMutation)
 
-// 我们平台自己的币
-type WalletCoin struct {
-	Wallet
-}
+	if afterBalance.IsNegative() {
+		return nil, exception.NotEnoughBalance
+	}
 
-func (news *WalletCny) TableName() string {
-	return WalletCnyTableName
-}
+	if afterFrozen.IsNegative() {
+		return nil, exception.NotEnoughFrozen
+	}
 
-func (news *WalletUsd) TableName() string {
-	return WalletUsdTableName
-}
+	log := FinanceLog{
+		Currency:        w.Currency,
+		Uid:             w.Id,
+		BeforeBalance:   w.Balance,
+		BalanceMutation: balanceMutation,
+		AfterBalance:    afterBalance,
+		BeforeFrozen:    w.Frozen,
+		FrozenMutation:  frozenMutation,
+		AfterFrozen:     afterFrozen,
+	}
+
+	w.Balance = afterBalance
+	w.Frozen = afterFrozen
 
-func (news *WalletCoin) TableName() string {
-	return WalletCoinTableName
+	return &log, nil
 }
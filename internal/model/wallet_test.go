@@ -0,0 +1,142 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/quick"
+)
+
+// 以最小单位 (1e-8) 创建金额
+func units(i int64) decimal.Decimal {
+	return decimal.NewFromInt(i).Div(decimal.NewFromInt(100000000))
+}
+
+func TestWalletMutate(t *testing.T) {
+	w := model.Wallet{Id: "123", Currency: model.WalletCNY, Balance: decimal.NewFromInt(100)}
+
+	log, err := w.Mutate(decimal.RequireFromString("-20.5"), decimal.RequireFromString("20.5"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123", log.Uid)
+	assert.Equal(t, model.WalletCNY, log.Currency)
+	assert.Equal(t, "100.00000000", log.BeforeBalance.String())
+	assert.Equal(t, "-20.50000000", log.BalanceMutation.String())
+	assert.Equal(t, "79.50000000", log.AfterBalance.String())
+	assert.Equal(t, "0.00000000", log.BeforeFrozen.String())
+	assert.Equal(t, "20.50000000", log.FrozenMutation.String())
+	assert.Equal(t, "20.50000000", log.AfterFrozen.String())
+	assert.Equal(t, "79.50000000", w.Balance.String())
+	assert.Equal(t, "20.50000000", w.Frozen.String())
+
+	// 余额不足的时候钱包不变
+	_, err = w.Mutate(decimal.RequireFromString("-79.50000001"), decimal.Zero)
+
+	assert.Equal(t, exception.NotEnoughBalance, err)
+	assert.Equal(t, "79.50000000", w.Balance.String())
+
+	_, err = w.Mutate(decimal.Zero, decimal.RequireFromString("-21"))
+
+	assert.Equal(t, exception.NotEnoughFrozen, err)
+	assert.Equal(t, "20.50000000", w.Frozen.String())
+}
+
+// 任意的变动序列, 流水中的变动之和必定等于钱包余额的变化, 且每条流水前后衔接
+func TestWalletMutateProperty(t *testing.T) {
+	property := func(initialBalance uint32, initialFrozen uint32, balanceMutations []int64, frozenMutations []int32) bool {
+		w := model.Wallet{
+			Id:       "123",
+			Currency: model.WalletCOIN,
+			Balance:  units(int64(initialBalance)),
+			Frozen:   units(int64(initialFrozen)),
+		}
+
+		var (
+			logs          = make([]*model.FinanceLog, 0)
+			balanceBefore = w.Balance
+			frozenBefore  = w.Frozen
+		)
+
+		for i, b := range balanceMutations {
+			var f int64
+
+			if i < len(frozenMutations) {
+				f = int64(frozenMutations[i])
+			}
+
+			prevBalance, prevFrozen := w.Balance, w.Frozen
+
+			log, err := w.Mutate(units(b), units(f))
+
+			if err != nil {
+				// 失败的变动不能改变钱包
+				if !w.Balance.Equal(prevBalance) || !w.Frozen.Equal(prevFrozen) {
+					return false
+				}
+				continue
+			}
+
+			logs = append(logs, log)
+		}
+
+		balanceSum := decimal.Zero
+		frozenSum := decimal.Zero
+
+		for i, log := range logs {
+			// 每条流水自身是自洽的
+			if !log.BeforeBalance.Add(log.BalanceMutation).Equal(log.AfterBalance) {
+				return false
+			}
+
+			if !log.BeforeFrozen.Add(log.FrozenMutation).Equal(log.AfterFrozen) {
+				return false
+			}
+
+			// 前后两条流水是衔接的
+			if i > 0 && (!logs[i-1].AfterBalance.Equal(log.BeforeBalance) || !logs[i-1].AfterFrozen.Equal(log.BeforeFrozen)) {
+				return false
+			}
+
+			// 余额永远不会是负数
+			if log.AfterBalance.IsNegative() || log.AfterFrozen.IsNegative() {
+				return false
+			}
+
+			balanceSum = balanceSum.Add(log.BalanceMutation)
+			frozenSum = frozenSum.Add(log.FrozenMutation)
+		}
+
+		return balanceSum.Equal(w.Balance.Sub(balanceBefore)) && frozenSum.Equal(w.Frozen.Sub(frozenBefore))
+	}
+
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 1000}))
+}
+
+// 转账是两个钱包的对称变动, 总金额守恒
+func TestWalletTransferProperty(t *testing.T) {
+	property := func(fromBalance uint64, toBalance uint64, amounts []uint32) bool {
+		from := model.Wallet{Id: "from", Balance: units(int64(fromBalance >> 1))}
+		to := model.Wallet{Id: "to", Balance: units(int64(toBalance >> 1))}
+
+		total := from.Balance.Add(to.Balance)
+
+		for _, a := range amounts {
+			amount := units(int64(a))
+
+			if _, err := from.Mutate(amount.Neg(), decimal.Zero); err != nil {
+				continue
+			}
+
+			if _, err := to.Mutate(amount, decimal.Zero); err != nil {
+				return false
+			}
+		}
+
+		return from.Balance.Add(to.Balance).Equal(total)
+	}
+
+	assert.Nil(t, quick.Check(property, &quick.Config{MaxCount: 1000}))
+}
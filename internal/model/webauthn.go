@@ -0,0 +1,37 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package model
+
+import (
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"time"
+)
+
+// WebAuthn 凭证 (passkey / 安全密钥)
+// 用户的凭证用于登陆, 管理员的凭证用于登陆时的双重身份认证
+type WebAuthnCredential struct {
+	Id           string         `gorm:"primary_key;unique;not null;index;type:varchar(32)" json:"id"`  // ID
+	Uid          string         `gorm:"not null;index;type:varchar(32)" json:"uid"`                    // 用户ID, 管理员的凭证则为管理员ID
+	User         User           `gorm:"foreignkey:Uid" json:"-"`                                       // **外键**, 只对用户的凭证有效
+	IsAdmin      bool           `gorm:"not null;index;default:false" json:"is_admin"`                  // 是否是管理员的凭证
+	Name         string         `gorm:"not null;type:varchar(32)" json:"name"`                         // 名称, 由用户命名, 方便区分不同的设备
+	CredentialId string         `gorm:"not null;unique_index;type:varchar(1366)" json:"credential_id"` // 凭证ID, base64url 编码
+	PublicKey    []byte         `gorm:"not null;type:bytea" json:"-"`                                  // COSE 格式的公钥
+	Algorithm    int            `gorm:"not null" json:"algorithm"`                                     // 签名算法
+	SignCount    int64          `gorm:"not null;default:0" json:"sign_count"`                          // 签名计数器, 用于发现被克隆的认证器
+	AAGUID       string         `gorm:"not null;type:varchar(32)" json:"aaguid"`                       // 认证器的型号, hex 编码
+	Transports   pq.StringArray `gorm:"type:varchar(16)[]" json:"transports"`                          // 认证器支持的传输方式, 例如 usb/nfc/ble/internal
+	LastUsedAt   *time.Time     `gorm:"null" json:"last_used_at"`                                      // 最后一次使用的时间
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (w *WebAuthnCredential) TableName() string {
+	return "webauthn_credential"
+}
+
+func (w *WebAuthnCredential) BeforeCreate(scope *gorm.Scope) (err error) {
+	err = scope.SetColumn("id", util.GenerateId())
+	return
+}
@@ -12,10 +12,10 @@ var (
 	AdminNewsUpdate = New("news::update", "有权限修改新闻")
 	AdminNewsDelete = New("news::delete", "有权限删除新闻")
 
-	adminNotificationGet    = New("notification::get", "有权限获取公告")
-	adminNotificationCreate = New("notification::create", "有权限创建公告")
-	adminNotificationUpdate = New("notification::update", "有权限修改公告")
-	adminNotificationDelete = New("notification::delete", "有权限删除公告")
+	AdminNotificationGet    = New("notification::get", "有权限获取公告")
+	AdminNotificationCreate = New("notification::create", "有权限创建公告")
+	AdminNotificationUpdate = New("notification::update", "有权限修改公告")
+	AdminNotificationDelete = New("notification::delete", "有权限删除公告")
 
 	AdminUserGet    = New("user::get", "有权限获取用户信息")
 	AdminUserCreate = New("user::create", "有权限创建新用户")
@@ -37,6 +37,47 @@ var (
 	AdminReportUpdate = New("report::update", "有权限修改反馈信息")
 	AdminReportDelete = New("report::delete", "有权限删除反馈信息")
 
+	AdminWalletGet    = New("wallet::get", "有权限查看用户的钱包流水和转账记录")
+	AdminWalletUpdate = New("wallet::update", "有权限给用户加款/扣款/冻结/解冻")
+
+	AdminCurrencyGet    = New("currency::get", "有权限获取币种信息")
+	AdminCurrencyCreate = New("currency::create", "有权限添加新币种")
+	AdminCurrencyUpdate = New("currency::update", "有权限修改币种信息")
+
+	AdminExchangeGet    = New("exchange::get", "有权限获取兑换汇率和兑换记录")
+	AdminExchangeUpdate = New("exchange::update", "有权限设置兑换汇率")
+
+	AdminReconciliationGet = New("reconciliation::get", "有权限获取对账结果")
+
+	AdminOAuthClientGet    = New("oauth_client::get", "有权限获取接入的第三方应用")
+	AdminOAuthClientCreate = New("oauth_client::create", "有权限登记新的第三方应用")
+	AdminOAuthClientUpdate = New("oauth_client::update", "有权限修改第三方应用和重置应用密钥")
+	AdminOAuthClientDelete = New("oauth_client::delete", "有权限删除第三方应用")
+
+	AdminRoleGet    = New("role::get", "有权限获取角色信息")
+	AdminRoleCreate = New("role::create", "有权限创建新角色")
+	AdminRoleUpdate = New("role::update", "有权限修改角色信息")
+	AdminRoleDelete = New("role::delete", "有权限删除角色")
+
+	AdminMessageGet    = New("message::get", "有权限获取个人消息")
+	AdminMessageCreate = New("message::create", "有权限创建个人消息")
+	AdminMessageUpdate = New("message::update", "有权限修改个人消息")
+	AdminMessageDelete = New("message::delete", "有权限删除个人消息")
+
+	AdminHelpGet    = New("help::get", "有权限获取帮助中心")
+	AdminHelpCreate = New("help::create", "有权限创建帮助")
+	AdminHelpUpdate = New("help::update", "有权限修改帮助")
+	AdminHelpDelete = New("help::delete", "有权限删除帮助")
+
+	AdminLogGet = New("log::get", "有权限查看登陆日志")
+
+	AdminConfigGet    = New("config::get", "有权限获取系统配置")
+	AdminConfigUpdate = New("config::update", "有权限修改系统配置")
+
+	AdminPushCreate = New("push::create", "有权限推送通知给用户")
+
+	AdminSystemGet = New("system::get", "有权限查看系统信息")
+
 	// 管理员的所有权限
 	AdminList = []*Accession{
 		AdminAdminGet,
@@ -49,10 +90,10 @@ var (
 		AdminNewsUpdate,
 		AdminNewsDelete,
 
-		adminNotificationGet,
-		adminNotificationUpdate,
-		adminNotificationDelete,
-		adminNotificationCreate,
+		AdminNotificationGet,
+		AdminNotificationUpdate,
+		AdminNotificationDelete,
+		AdminNotificationCreate,
 
 		AdminUserGet,
 		AdminUserCreate,
@@ -73,6 +114,47 @@ var (
 		AdminReportGet,
 		AdminReportUpdate,
 		AdminReportDelete,
+
+		AdminWalletGet,
+		AdminWalletUpdate,
+
+		AdminCurrencyGet,
+		AdminCurrencyCreate,
+		AdminCurrencyUpdate,
+
+		AdminExchangeGet,
+		AdminExchangeUpdate,
+
+		AdminReconciliationGet,
+
+		AdminOAuthClientGet,
+		AdminOAuthClientCreate,
+		AdminOAuthClientUpdate,
+		AdminOAuthClientDelete,
+
+		AdminRoleGet,
+		AdminRoleCreate,
+		AdminRoleUpdate,
+		AdminRoleDelete,
+
+		AdminMessageGet,
+		AdminMessageCreate,
+		AdminMessageUpdate,
+		AdminMessageDelete,
+
+		AdminHelpGet,
+		AdminHelpCreate,
+		AdminHelpUpdate,
+		AdminHelpDelete,
+
+		AdminLogGet,
+
+		AdminConfigGet,
+		AdminConfigUpdate,
+
+		AdminPushCreate,
+
+		AdminSystemGet,
 	}
 
 	AdminMap = map[string]*Accession{}
@@ -20,6 +20,7 @@ var (
 		DoTransfer,
 		// 客服权限
 		CustomerServiceConnect,
+		CustomerServiceSupervise,
 	}
 
 	Map = map[string]*Accession{}
@@ -3,7 +3,8 @@ package accession
 
 var (
 	// 用户类
-	CustomerServiceConnect = New("customer_service::connect", "有权限连接客服")
+	CustomerServiceConnect   = New("customer_service::connect", "有权限连接客服")
+	CustomerServiceSupervise = New("customer_service::supervise", "有权限监控, 接管客服会话")
 
 	// 用户的所有的权限
 	//WaiterList = []*Accession{
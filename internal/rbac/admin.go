@@ -0,0 +1,86 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package rbac
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/rbac/accession"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/jinzhu/gorm"
+	"sync"
+)
+
+// 管理员的权限控制, 权限直接存放在管理员的 accession 字段, 超级管理员拥有所有权限
+type AdminController struct {
+	Admin model.Admin
+}
+
+func NewAdmin(adminId string) (c *AdminController, err error) {
+	c = &AdminController{
+		Admin: model.Admin{Id: adminId},
+	}
+
+	if err = database.Db.First(&c.Admin).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.AdminNotExist
+		}
+		return
+	}
+
+	return c, nil
+}
+
+// 验证是否有这些权限, 拥有其中任意一个即可
+func (c *AdminController) Require(a []accession.Accession) bool {
+	if len(a) == 0 {
+		return true
+	}
+
+	for _, v := range a {
+		if c.Has(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 检验是否拥有单独的权限
+func (c *AdminController) Has(a accession.Accession) bool {
+	return c.Admin.HasAccession(&a)
+}
+
+// 管理员路由需要的权限
+type AdminRoute struct {
+	Method    string                // 请求方法
+	Path      string                // 路由路径, 例如 /v1/news/{news_id}
+	Accession []accession.Accession // 需要的权限, 拥有其中任意一个即可
+}
+
+var (
+	adminRoutes     = make([]AdminRoute, 0)
+	adminRoutesLock sync.RWMutex
+)
+
+// 登记管理员路由需要的权限, 用于前端根据权限隐藏无法进行的操作
+func RegisterAdminRoute(method string, path string, a ...accession.Accession) {
+	adminRoutesLock.Lock()
+	defer adminRoutesLock.Unlock()
+
+	adminRoutes = append(adminRoutes, AdminRoute{
+		Method:    method,
+		Path:      path,
+		Accession: a,
+	})
+}
+
+// 获取所有已登记的管理员路由
+func AdminRoutes() []AdminRoute {
+	adminRoutesLock.RLock()
+	defer adminRoutesLock.RUnlock()
+
+	routes := make([]AdminRoute, len(adminRoutes))
+
+	copy(routes, adminRoutes)
+
+	return routes
+}
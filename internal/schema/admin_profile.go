@@ -6,17 +6,19 @@ import (
 )
 
 type AdminProfilePure struct {
-	Id        string            `json:"id"`        // 用户ID
-	Username  string            `json:"username"`  // 用户名, 用于登陆
-	Name      string            `json:"name"`      // 管理员名
-	Accession []string          `json:"accession"` // 管理员所拥有的权限
-	IsSuper   bool              `json:"is_super"`  // 是否是超级管理员, 超级管理员全站应该只有一个
-	Status    model.AdminStatus `json:"status"`    // 状态
+	Id         string            `json:"id"`          // 用户ID
+	Username   string            `json:"username"`    // 用户名, 用于登陆
+	Name       string            `json:"name"`        // 管理员名
+	Accession  []string          `json:"accession"`   // 管理员所拥有的权限
+	IsSuper    bool              `json:"is_super"`    // 是否是超级管理员, 超级管理员全站应该只有一个
+	Status     model.AdminStatus `json:"status"`      // 状态
+	EnableTOTP bool              `json:"enable_totp"` // 是否启用双重身份认证
 }
 
 type AdminProfileWithToken struct {
 	AdminProfile
-	Token string `json:"token"`
+	Token        string `json:"token"`                   // 身份令牌
+	RefreshToken string `json:"refresh_token,omitempty"` // 刷新令牌, 只有 JWT 模式才有, 用于换取新的身份令牌
 }
 
 type AdminProfile struct {
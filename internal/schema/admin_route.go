@@ -0,0 +1,9 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+type AdminRoute struct {
+	Method    string   `json:"method"`    // 请求方法
+	Path      string   `json:"path"`      // 路由路径, 例如 /v1/news/{news_id}
+	Accession []string `json:"accession"` // 需要的权限, 拥有其中任意一个即可
+	Allowed   bool     `json:"allowed"`   // 当前管理员是否可以访问
+}
@@ -10,3 +10,15 @@ type Name struct {
 	Field       string `json:"field"`       // 字段名称
 	Description string `json:"description"` // 配置描述
 }
+
+// 第三方登陆的服务提供商, 不包含密钥
+type OAuthProvider struct {
+	Name        string   `json:"name"`         // 名称
+	Type        string   `json:"type"`         // 类型
+	Enabled     bool     `json:"enabled"`      // 是否启用
+	CallbackUrl string   `json:"callback_url"` // 需要在服务提供商那里设置的回调地址
+	Scopes      []string `json:"scopes"`       // 申请的 scope
+	Healthy     bool     `json:"healthy"`      // 是否可用
+	Error       string   `json:"error"`        // 不可用的原因
+	Latency     int64    `json:"latency"`      // 检查耗时, 单位毫秒
+}
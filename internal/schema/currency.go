@@ -0,0 +1,16 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+type CurrencyPure struct {
+	Code      string `json:"code"`      // 币种代码
+	Name      string `json:"name"`      // 显示名称
+	Precision int    `json:"precision"` // 金额允许的最大小数位数
+	Enabled   bool   `json:"enabled"`   // 是否启用
+	BuiltIn   bool   `json:"built_in"`  // 是否是内置的币种
+}
+
+type Currency struct {
+	CurrencyPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+type ExchangeRatePure struct {
+	Id      string `json:"id"`       // 汇率ID
+	From    string `json:"from"`     // 兑出的币种
+	To      string `json:"to"`       // 兑入的币种
+	Rate    string `json:"rate"`     // 1 个单位的 From 可以兑换多少 To
+	FeeRate string `json:"fee_rate"` // 手续费率
+	Enabled bool   `json:"enabled"`  // 是否启用
+}
+
+type ExchangeRate struct {
+	ExchangeRatePure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ExchangeQuote struct {
+	Id        string `json:"id"`         // 报价ID, 用于确认兑换
+	From      string `json:"from"`       // 兑出的币种
+	To        string `json:"to"`         // 兑入的币种
+	Amount    string `json:"amount"`     // 兑出的数量
+	Rate      string `json:"rate"`       // 锁定的汇率
+	FeeRate   string `json:"fee_rate"`   // 手续费率
+	Fee       string `json:"fee"`        // 手续费
+	Received  string `json:"received"`   // 实际兑入的数量
+	ExpiredAt string `json:"expired_at"` // 报价的过期时间
+}
+
+type ExchangePure struct {
+	Id        string `json:"id"`          // 兑换ID
+	Uid       string `json:"uid"`         // 用户ID
+	From      string `json:"from"`        // 兑出的币种
+	To        string `json:"to"`          // 兑入的币种
+	Amount    string `json:"amount"`      // 兑出的数量
+	Rate      string `json:"rate"`        // 成交的汇率
+	FeeRate   string `json:"fee_rate"`    // 成交的手续费率
+	Fee       string `json:"fee"`         // 手续费
+	Received  string `json:"received"`    // 实际兑入的数量
+	FromLogId string `json:"from_log_id"` // 兑出币种的财务流水ID
+	ToLogId   string `json:"to_log_id"`   // 兑入币种的财务流水ID
+}
+
+type Exchange struct {
+	ExchangePure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -0,0 +1,54 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/internal/model"
+
+// 应用公开的信息, 显示在授权页面
+type OAuthClientPublic struct {
+	Id          string  `json:"id"`          // 应用ID, 即 client_id
+	Name        string  `json:"name"`        // 应用名称
+	Description *string `json:"description"` // 应用描述
+	Logo        *string `json:"logo"`        // 应用图标
+	Homepage    *string `json:"homepage"`    // 应用主页
+}
+
+type OAuthClientPure struct {
+	OAuthClientPublic
+	Public       bool                    `json:"public"`        // 是否是公开应用, 公开应用没有密钥, 必须使用 PKCE
+	RedirectUris []string                `json:"redirect_uris"` // 允许的回调地址
+	Scopes       []string                `json:"scopes"`        // 允许申请的 scope
+	Status       model.OAuthClientStatus `json:"status"`        // 状态
+}
+
+type OAuthClient struct {
+	OAuthClientPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// 创建应用/重置密钥时返回, 密钥只返回这一次
+type OAuthClientWithSecret struct {
+	OAuthClient
+	Secret string `json:"secret"` // 应用密钥, 即 client_secret. 公开应用为空
+}
+
+// 授权页面需要的信息
+type OAuthAuthorize struct {
+	Client      OAuthClientPublic `json:"client"`                 // 申请授权的应用
+	Scopes      []string          `json:"scopes"`                 // 申请的 scope
+	Consented   bool              `json:"consented"`              // 用户是否已经同意过这些 scope, 已同意时可以直接授权
+	RedirectUri string            `json:"redirect_uri,omitempty"` // 请求无效时携带错误信息的回调地址, 授权页面直接跳转到这个地址
+}
+
+// 授权之后跳转回应用的地址
+type OAuthRedirect struct {
+	RedirectUri string `json:"redirect_uri"` // 携带了 code 或者 error 的回调地址
+}
+
+// 用户给应用的授权
+type OAuthConsent struct {
+	Client    OAuthClientPublic `json:"client"` // 应用
+	Scopes    []string          `json:"scopes"` // 已同意的 scope
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+}
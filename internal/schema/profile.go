@@ -15,6 +15,7 @@ type ProfilePure struct {
 	Level                   int32    `json:"level"`
 	InviteCode              string   `json:"invite_code"`
 	UsernameRenameRemaining int      `json:"username_rename_remaining"`
+	EnableTOTP              bool     `json:"enable_totp"`
 }
 
 // 绑定的微信帐号信息
@@ -38,7 +39,8 @@ type ProfilePublic struct {
 
 type ProfileWithToken struct {
 	Profile
-	Token string `json:"token"`
+	Token        string `json:"token"`                   // 身份令牌
+	RefreshToken string `json:"refresh_token,omitempty"` // 刷新令牌, 只有 JWT 模式才有, 用于换取新的身份令牌
 }
 
 type Profile struct {
@@ -0,0 +1,39 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+import "github.com/axetroy/go-server/internal/model"
+
+type ReconciliationPure struct {
+	Id            string                     `json:"id"`            // 对账ID
+	Status        model.ReconciliationStatus `json:"status"`        // 对账状态
+	Wallets       int64                      `json:"wallets"`       // 检查的钱包数量
+	Logs          int64                      `json:"logs"`          // 检查的流水数量
+	Discrepancies int64                      `json:"discrepancies"` // 发现的差异数量
+	Error         *string                    `json:"error"`         // 对账失败的原因
+	FinishedAt    *string                    `json:"finished_at"`   // 对账完成的时间
+}
+
+type Reconciliation struct {
+	ReconciliationPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ReconciliationItemPure struct {
+	Id               string                   `json:"id"`                // 差异ID
+	ReconciliationId string                   `json:"reconciliation_id"` // 对应的对账ID
+	Currency         string                   `json:"currency"`          // 币种
+	Uid              string                   `json:"uid"`               // 钱包对应的用户
+	Kind             model.ReconciliationKind `json:"kind"`              // 差异类型
+	LogId            *string                  `json:"log_id"`            // 出现差异的流水
+	ExpectedBalance  string                   `json:"expected_balance"`  // 根据流水推算的可用余额
+	ActualBalance    string                   `json:"actual_balance"`    // 实际的可用余额
+	ExpectedFrozen   string                   `json:"expected_frozen"`   // 根据流水推算的冻结余额
+	ActualFrozen     string                   `json:"actual_frozen"`     // 实际的冻结余额
+}
+
+type ReconciliationItem struct {
+	ReconciliationItemPure
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
@@ -12,6 +12,7 @@ type ReportPure struct {
 	Status      model.ReportStatus `json:"status"`
 	Screenshots []string           `json:"screenshots"`
 	Locked      bool               `json:"locked"`
+	WaiterID    *string            `json:"waiter_id"`
 }
 
 type Report struct {
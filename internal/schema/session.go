@@ -0,0 +1,12 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+type Session struct {
+	Id         string `json:"id"`           // 会话ID
+	Ip         string `json:"ip"`           // 最后一次访问的IP
+	Device     string `json:"device"`       // 最后一次访问的设备 (user agent)
+	Current    bool   `json:"current"`      // 是否是当前请求所在的会话
+	CreatedAt  string `json:"created_at"`   // 登陆时间
+	LastSeenAt string `json:"last_seen_at"` // 最后一次访问的时间
+	ExpiredAt  string `json:"expired_at"`   // 过期时间
+}
@@ -0,0 +1,9 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 使用刷新令牌换取的新令牌
+type Token struct {
+	Token        string `json:"token"`         // 身份令牌
+	RefreshToken string `json:"refresh_token"` // 新的刷新令牌, 旧的刷新令牌已经失效
+	ExpiredAt    string `json:"expired_at"`    // 身份令牌的过期时间
+}
@@ -0,0 +1,19 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+type TOTPEnroll struct {
+	Secret string `json:"secret"` // 密钥, 用于手动输入到身份验证器
+	URI    string `json:"uri"`    // otpauth:// 地址
+	QRCode string `json:"qrcode"` // otpauth:// 地址对应的二维码, data URI 格式的 PNG 图片
+}
+
+type TOTPRecoveryCodes struct {
+	Codes []string `json:"codes"` // 恢复码, 只会展示一次, 每个只能使用一次
+}
+
+// 开启了双重身份认证的帐号, 登陆时先返回挑战, 再用挑战 + 动态密码换取身份令牌
+type TOTPChallenge struct {
+	Challenge string   `json:"challenge"`         // 挑战ID
+	ExpiredAt string   `json:"expired_at"`        // 挑战的过期时间
+	Methods   []string `json:"methods,omitempty"` // 可以使用的验证方式, totp: 动态密码, webauthn: 通行密钥. 只有管理员登陆才返回
+}
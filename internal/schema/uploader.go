@@ -8,4 +8,5 @@ type FileResponse struct {
 	Size         int64  `json:"size"`          // 文件大小
 	RawPath      string `json:"raw_path"`      // 纯文本的文件路径, 需要拼接上域名
 	DownloadPath string `json:"download_path"` // 下载的文件路径, 需要拼接上域名
+	URL          string `json:"url"`           // 文件在存储中的访问地址, 由存储服务决定
 }
@@ -0,0 +1,13 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package schema
+
+// 已注册的通行密钥
+type WebAuthnCredential struct {
+	Id         string   `json:"id"`           // ID
+	Name       string   `json:"name"`         // 名称
+	AAGUID     string   `json:"aaguid"`       // 认证器的型号, hex 编码, 全 0 表示未知
+	Transports []string `json:"transports"`   // 认证器支持的传输方式
+	LastUsedAt *string  `json:"last_used_at"` // 最后一次使用的时间, 从未使用过则为 null
+	CreatedAt  string   `json:"created_at"`   // 注册时间
+	UpdatedAt  string   `json:"updated_at"`   // 更新时间
+}
@@ -2,23 +2,30 @@
 package authentication
 
 import (
+	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/service/token"
 	"time"
 )
 
+// JWT 的认证方式
+// 身份令牌的有效期很短, 过期之后使用刷新令牌换取新的身份令牌, 登陆时指定的有效期为整个会话的有效期
 type Jwt struct {
 	isAdmin bool
+	store   store
 }
 
 func NewJwt(isAdmin bool) Jwt {
 	return Jwt{
 		isAdmin: isAdmin,
+		store:   store{isAdmin: isAdmin},
 	}
 }
 
 func (c Jwt) getState() token.State {
 	var state token.State
+
 	if c.isAdmin {
 		state = token.StateAdmin
 	} else {
@@ -28,22 +35,134 @@ func (c Jwt) getState() token.State {
 	return state
 }
 
-func (c Jwt) Generate(uid string, duration ...time.Duration) (string, error) {
-	state := c.getState()
+// 签发身份令牌, 有效期不超过会话的剩余时间
+func (c Jwt) issue(r refreshToken) (Token, error) {
+	duration := config.Jwt.AccessTTL
+
+	if remain := time.Until(time.Unix(r.ExpiredAt, 0)); remain < duration {
+		duration = remain
+	}
+
+	if duration <= 0 {
+		return Token{}, exception.InvalidRefreshToken
+	}
+
+	tokenStr, claims, err := token.Issue(r.Uid, r.Sid, c.getState(), duration)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	refreshStr, err := c.store.issueRefresh(r)
+
+	if err != nil {
+		return Token{}, err
+	}
 
-	return token.Generate(uid, state, duration...)
+	return Token{
+		Token:        tokenStr,
+		RefreshToken: refreshStr,
+		ExpiredAt:    time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
 
-func (c Jwt) Parse(tokenString string) (string, error) {
-	state := c.getState()
+func (c Jwt) Generate(uid string, durations ...time.Duration) (Token, error) {
+	var duration = time.Hour * 6
+
+	if len(durations) > 0 {
+		if durations[0] > 0 {
+			duration = durations[0]
+		}
+	}
 
-	if claims, err := token.Parse(tokenString, state); err != nil {
-		return "", exception.InvalidToken
+	if duration > maxDuration {
+		duration = maxDuration
+	}
+
+	now := time.Now()
+
+	r := refreshToken{
+		Uid:       uid,
+		Sid:       util.GenerateId(),
+		CreatedAt: now.Unix(),
+		ExpiredAt: now.Add(duration).Unix(),
+	}
+
+	if err := c.store.register(r.Uid, r.Sid, now, time.Unix(r.ExpiredAt, 0)); err != nil {
+		return Token{}, err
+	}
+
+	return c.issue(r)
+}
+
+func (c Jwt) Refresh(refreshToken string) (Token, error) {
+	r, err := c.store.useRefresh(refreshToken)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	// 会话已经被注销
+	if err := c.store.check(r.Uid, r.Sid, r.CreatedAt); err != nil {
+		return Token{}, exception.InvalidRefreshToken
+	}
+
+	return c.issue(*r)
+}
+
+func (c Jwt) claims(tokenString string) (token.Claims, error) {
+	claims, err := token.Parse(tokenString, c.getState())
+
+	if err != nil {
+		return claims, exception.InvalidToken
+	}
+
+	if err := c.store.check(claims.Uid, claims.Sid, claims.IssuedAt); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+func (c Jwt) Parse(tokenString string) (string, error) {
+	if claims, err := c.claims(tokenString); err != nil {
+		return "", err
 	} else {
 		return claims.Uid, nil
 	}
 }
 
-func (c Jwt) Remove(_ string) error {
-	return nil
+func (c Jwt) Touch(tokenString string, ip string, device string) (string, string, error) {
+	claims, err := c.claims(tokenString)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	// 只是记录访问信息, 失败了也不影响这次请求
+	_ = c.store.touch(claims.Sid, ip, device)
+
+	return claims.Uid, claims.Sid, nil
+}
+
+func (c Jwt) Remove(tokenString string) error {
+	claims, err := token.Parse(tokenString, c.getState())
+
+	if err != nil {
+		return exception.InvalidToken
+	}
+
+	return c.store.revokeToken(claims)
+}
+
+func (c Jwt) Sessions(uid string) ([]SessionInfo, error) {
+	return c.store.list(uid)
+}
+
+func (c Jwt) RemoveSession(uid string, sessionId string) error {
+	return c.store.revokeSession(uid, sessionId)
+}
+
+func (c Jwt) RemoveAllSessions(uid string, except string) (int, error) {
+	return c.store.revokeAll(uid, except)
 }
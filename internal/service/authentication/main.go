@@ -7,11 +7,22 @@ import (
 )
 
 // 该模块定义了 如何生成/解析 token
-
 type Authentication interface {
-	Generate(uid string, duration ...time.Duration) (string, error) // 生成身份认证的 token
-	Parse(token string) (string, error)                             // 解析 token，并且返回 uuid
-	Remove(token string) error                                      // 移除某个 token
+	Generate(uid string, duration ...time.Duration) (Token, error)        // 生成身份认证的 token
+	Refresh(refreshToken string) (Token, error)                           // 使用刷新令牌换取新的 token
+	Parse(token string) (string, error)                                   // 解析 token，并且返回 uuid
+	Remove(token string) error                                            // 移除某个 token
+	Touch(token string, ip string, device string) (string, string, error) // 解析 token 并记录最后一次访问的IP和设备, 返回 uuid 和会话ID
+	Sessions(uid string) ([]SessionInfo, error)                           // 获取用户所有有效的会话
+	RemoveSession(uid string, sessionId string) error                     // 注销用户的某个会话
+	RemoveAllSessions(uid string, except string) (int, error)             // 注销用户所有的会话, 可以保留当前的会话, 返回注销的数量
+}
+
+// 登陆成功之后签发的 token
+type Token struct {
+	Token        string    // 身份令牌
+	RefreshToken string    // 刷新令牌, 只有 JWT 模式才有
+	ExpiredAt    time.Time // 身份令牌的过期时间
 }
 
 var jwtUser Authentication = NewJwt(false)         // JWT 的认证方式
@@ -40,5 +51,4 @@ func init() {
 			}
 		}
 	}
-
 }
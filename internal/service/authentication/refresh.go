@@ -0,0 +1,112 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"time"
+)
+
+// 刷新令牌
+// 每个刷新令牌只能使用一次, 使用之后换取新的身份令牌和刷新令牌
+// 同一个会话的刷新令牌属于同一个家族, 已使用过的刷新令牌再次出现时, 说明令牌可能已经泄漏, 注销整个会话
+type refreshToken struct {
+	Uid       string `json:"uid"`        // 用户ID
+	Sid       string `json:"sid"`        // 所属的会话ID
+	CreatedAt int64  `json:"created_at"` // 会话的登陆时间
+	ExpiredAt int64  `json:"expired_at"` // 会话的过期时间, 刷新之后不会延长
+}
+
+func refreshKey(hash string) string {
+	return "refresh:" + hash
+}
+
+func refreshUsedKey(hash string) string {
+	return "refresh-used:" + hash
+}
+
+func hashRefreshToken(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// 签发一个新的刷新令牌, 服务端只存储 hash
+func (s store) issueRefresh(r refreshToken) (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	tokenString := base64.RawURLEncoding.EncodeToString(b)
+
+	value, err := json.Marshal(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	ttl := time.Until(time.Unix(r.ExpiredAt, 0))
+
+	if ttl <= 0 {
+		return "", exception.InvalidRefreshToken
+	}
+
+	if err := s.client().Set(context.Background(), refreshKey(hashRefreshToken(tokenString)), string(value), ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// 使用刷新令牌, 每个刷新令牌只能成功使用一次
+func (s store) useRefresh(tokenString string) (*refreshToken, error) {
+	ctx := context.Background()
+	hash := hashRefreshToken(tokenString)
+
+	value, err := s.client().Get(ctx, refreshKey(hash)).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return nil, exception.InvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	r := refreshToken{}
+
+	if err := json.Unmarshal([]byte(value), &r); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Until(time.Unix(r.ExpiredAt, 0))
+
+	if ttl <= 0 {
+		return nil, exception.InvalidRefreshToken
+	}
+
+	// 用带条件的写入标记为已使用, 保证并发时只有一个请求成功
+	ok, err := s.client().SetNX(ctx, refreshUsedKey(hash), 1, ttl).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		// 已使用过的刷新令牌再次被使用, 注销整个会话
+		if err := s.revoke(r.Uid, r.Sid, time.Unix(r.ExpiredAt, 0)); err != nil {
+			return nil, err
+		}
+
+		return nil, exception.RefreshTokenReused
+	}
+
+	return &r, nil
+}
@@ -4,36 +4,26 @@ package authentication
 import (
 	"context"
 	"github.com/axetroy/go-server/internal/library/exception"
-	"github.com/axetroy/go-server/internal/library/util"
-	"github.com/axetroy/go-server/internal/service/redis"
 	"github.com/axetroy/go-server/internal/service/token"
-	nativeRedis "github.com/go-redis/redis/v8"
 	"strings"
 	"time"
 )
 
 type Session struct {
 	isAdmin bool
-	client  *nativeRedis.Client
+	store   store
 }
 
 func NewSession(isAdmin bool) Session {
-	var client *nativeRedis.Client
-
-	if isAdmin {
-		client = redis.ClientTokenAdmin
-	} else {
-		client = redis.ClientTokenUser
-	}
-
 	return Session{
 		isAdmin: isAdmin,
-		client:  client,
+		store:   store{isAdmin: isAdmin},
 	}
 }
 
 func (c Session) getState() token.State {
 	var state token.State
+
 	if c.isAdmin {
 		state = token.StateAdmin
 	} else {
@@ -43,16 +33,9 @@ func (c Session) getState() token.State {
 	return state
 }
 
-func (c Session) Generate(uid string, durations ...time.Duration) (string, error) {
+func (c Session) Generate(uid string, durations ...time.Duration) (Token, error) {
 	state := c.getState()
 
-	tokenStr, err := token.Generate(uid, state)
-
-	if err != nil {
-		return "", err
-	}
-
-	var maxDuration = time.Hour * 24 * 30 // 最长的 token 为一个月
 	var duration = time.Hour * 24
 
 	if len(durations) > 0 {
@@ -65,34 +48,103 @@ func (c Session) Generate(uid string, durations ...time.Duration) (string, error
 		duration = maxDuration
 	}
 
+	tokenStr, claims, err := token.Issue(uid, "", state, duration)
+
+	if err != nil {
+		return Token{}, err
+	}
+
 	// 以 token 为 key
-	if err := c.client.Set(context.Background(), tokenStr, uid, duration).Err(); err != nil {
-		return "", err
+	if err := c.store.client().Set(context.Background(), tokenStr, uid, duration).Err(); err != nil {
+		return Token{}, err
 	}
 
-	// 以 user_id 为 key
-	if err := c.client.Set(context.Background(), "id-"+uid+util.RandomString(6), tokenStr, duration).Err(); err != nil {
-		return "", err
+	expiredAt := time.Unix(claims.ExpiresAt, 0)
+
+	if err := c.store.register(uid, claims.Sid, time.Unix(claims.IssuedAt, 0), expiredAt); err != nil {
+		return Token{}, err
 	}
 
-	return tokenStr, nil
+	return Token{
+		Token:     tokenStr,
+		ExpiredAt: expiredAt,
+	}, nil
 }
 
-func (c Session) Parse(tokenString string) (string, error) {
+// session 模式的 token 有效期较长, 不支持刷新令牌
+func (c Session) Refresh(_ string) (Token, error) {
+	return Token{}, exception.InvalidRefreshToken
+}
+
+func (c Session) claims(tokenString string) (token.Claims, error) {
+	var claims token.Claims
+
 	if !strings.HasPrefix(tokenString, token.Prefix+" ") {
-		return "", exception.InvalidAuth
+		return claims, exception.InvalidAuth
+	}
+
+	if _, err := c.store.client().Get(context.Background(), strings.TrimPrefix(tokenString, token.Prefix+" ")).Result(); err != nil {
+		return claims, exception.InvalidToken
 	}
-	tokenString = strings.TrimPrefix(tokenString, token.Prefix+" ")
 
-	uid, err := c.client.Get(context.Background(), tokenString).Result()
+	claims, err := token.Parse(tokenString, c.getState())
 
 	if err != nil {
-		return "", exception.InvalidToken
+		return claims, exception.InvalidToken
 	}
 
-	return uid, nil
+	if err := c.store.check(claims.Uid, claims.Sid, claims.IssuedAt); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+func (c Session) Parse(tokenString string) (string, error) {
+	if claims, err := c.claims(tokenString); err != nil {
+		return "", err
+	} else {
+		return claims.Uid, nil
+	}
+}
+
+func (c Session) Touch(tokenString string, ip string, device string) (string, string, error) {
+	claims, err := c.claims(tokenString)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	// 只是记录访问信息, 失败了也不影响这次请求
+	_ = c.store.touch(claims.Sid, ip, device)
+
+	return claims.Uid, claims.Sid, nil
 }
 
 func (c Session) Remove(tokenString string) error {
-	return c.client.Del(context.Background(), tokenString).Err()
+	tokenString = strings.TrimPrefix(tokenString, token.Prefix+" ")
+
+	if err := c.store.client().Del(context.Background(), tokenString).Err(); err != nil {
+		return err
+	}
+
+	claims, err := token.Parse(token.JoinPrefixToken(tokenString), c.getState())
+
+	if err != nil {
+		return nil
+	}
+
+	return c.store.revokeToken(claims)
+}
+
+func (c Session) Sessions(uid string) ([]SessionInfo, error) {
+	return c.store.list(uid)
+}
+
+func (c Session) RemoveSession(uid string, sessionId string) error {
+	return c.store.revokeSession(uid, sessionId)
+}
+
+func (c Session) RemoveAllSessions(uid string, except string) (int, error) {
+	return c.store.revokeAll(uid, except)
 }
@@ -0,0 +1,34 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package authentication_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/authentication"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRemoveAllSessions(t *testing.T) {
+	for _, auth := range []authentication.Authentication{authentication.NewSession(false), authentication.NewJwt(false)} {
+		uid := util.GenerateId()
+
+		before, err := auth.Generate(uid)
+		assert.Nil(t, err)
+
+		_, err = auth.RemoveAllSessions(uid, "")
+		assert.Nil(t, err)
+
+		_, err = auth.Parse(token.Prefix + " " + before.Token)
+		assert.Equal(t, exception.InvalidToken, err)
+
+		// 和强制下线同一秒的登陆依然有效
+		after, err := auth.Generate(uid)
+		assert.Nil(t, err)
+
+		parsed, err := auth.Parse(token.Prefix + " " + after.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, uid, parsed)
+	}
+}
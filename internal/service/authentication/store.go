@@ -0,0 +1,288 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package authentication
+
+import (
+	"context"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/internal/service/token"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// token 最长的有效期, 超过这个时间的黑名单不再需要保留
+var maxDuration = time.Hour * 24 * 30
+
+// 会话信息, 每次登陆对应一个会话, 刷新 token 之后会话不变
+type SessionInfo struct {
+	Id         string    // 会话ID
+	Uid        string    // 用户ID
+	Ip         string    // 最后一次访问的IP
+	Device     string    // 最后一次访问的设备 (user agent)
+	CreatedAt  time.Time // 登陆时间
+	LastSeenAt time.Time // 最后一次访问的时间
+	ExpiredAt  time.Time // 过期时间
+}
+
+// 会话和黑名单的存储
+// session:<sid>        会话详情
+// sessions:<uid>       用户所有的会话ID
+// refresh:<hash>       刷新令牌, 只存储 hash
+// deny:<sid>           已注销的会话, 保留到会话过期为止
+// revoke-before:<uid>  在这个时间之前签发的 token 全部失效, 用于强制下线
+type store struct {
+	isAdmin bool
+}
+
+// redis 在启动之后才连接, 所以不能在初始化时保存 client
+func (s store) client() *nativeRedis.Client {
+	if s.isAdmin {
+		return redis.ClientTokenAdmin
+	}
+
+	return redis.ClientTokenUser
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func sessionsKey(uid string) string {
+	return "sessions:" + uid
+}
+
+func denyKey(id string) string {
+	return "deny:" + id
+}
+
+func revokeBeforeKey(uid string) string {
+	return "revoke-before:" + uid
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func parseTime(s string) time.Time {
+	n, _ := strconv.ParseInt(s, 10, 64)
+
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(n, 0)
+}
+
+// 登陆之后记录会话
+func (s store) register(uid string, sessionId string, createdAt time.Time, expiredAt time.Time) error {
+	ctx := context.Background()
+
+	_, err := s.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(sessionId), map[string]interface{}{
+			"uid":          uid,
+			"created_at":   formatTime(createdAt),
+			"last_seen_at": formatTime(createdAt),
+			"expired_at":   formatTime(expiredAt),
+		})
+		pipe.ExpireAt(ctx, sessionKey(sessionId), expiredAt)
+		pipe.SAdd(ctx, sessionsKey(uid), sessionId)
+		pipe.Expire(ctx, sessionsKey(uid), maxDuration)
+		return nil
+	})
+
+	return err
+}
+
+// 检查会话是否已经被注销, issuedAt 为会话的登陆时间
+func (s store) check(uid string, sessionId string, issuedAt int64) error {
+	values, err := s.client().MGet(context.Background(), denyKey(sessionId), revokeBeforeKey(uid)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if values[0] != nil {
+		return exception.InvalidToken
+	}
+
+	before, ok := values[1].(string)
+
+	if !ok || issuedAt > parseTime(before).Unix() {
+		return nil
+	}
+
+	if issuedAt < parseTime(before).Unix() {
+		return exception.InvalidToken
+	}
+
+	// 签发时间只精确到秒, 和强制下线同一秒签发的 token 根据会话判断
+	// 强制下线时已有的会话都被删除了, 会话还存在说明是强制下线之后的登陆
+	n, err := s.client().Exists(context.Background(), sessionKey(sessionId)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return exception.InvalidToken
+	}
+
+	return nil
+}
+
+// 更新会话的最后访问时间和设备信息
+func (s store) touch(sessionId string, ip string, device string) error {
+	ctx := context.Background()
+
+	// 会话不存在时不再创建, 避免和注销操作同时发生时留下已注销的会话
+	if n, err := s.client().Exists(ctx, sessionKey(sessionId)).Result(); err != nil || n == 0 {
+		return err
+	}
+
+	return s.client().HSet(ctx, sessionKey(sessionId), map[string]interface{}{
+		"ip":           ip,
+		"device":       device,
+		"last_seen_at": formatTime(time.Now()),
+	}).Err()
+}
+
+// 获取某个会话
+func (s store) get(id string) (*SessionInfo, error) {
+	values, err := s.client().HGetAll(context.Background(), sessionKey(id)).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return &SessionInfo{
+		Id:         id,
+		Uid:        values["uid"],
+		Ip:         values["ip"],
+		Device:     values["device"],
+		CreatedAt:  parseTime(values["created_at"]),
+		LastSeenAt: parseTime(values["last_seen_at"]),
+		ExpiredAt:  parseTime(values["expired_at"]),
+	}, nil
+}
+
+// 列出用户所有的会话, 顺便清理已经过期的会话ID
+func (s store) list(uid string) ([]SessionInfo, error) {
+	ctx := context.Background()
+
+	ids, err := s.client().SMembers(ctx, sessionsKey(uid)).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]SessionInfo, 0)
+
+	for _, id := range ids {
+		info, err := s.get(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if info == nil {
+			_ = s.client().SRem(ctx, sessionsKey(uid), id).Err()
+			continue
+		}
+
+		list = append(list, *info)
+	}
+
+	return list, nil
+}
+
+// 注销某个会话, 把 token 加入黑名单直到它过期
+func (s store) revoke(uid string, id string, expiredAt time.Time) error {
+	ctx := context.Background()
+
+	ttl := time.Until(expiredAt)
+
+	if ttl > maxDuration {
+		ttl = maxDuration
+	}
+
+	_, err := s.client().TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		if ttl > 0 {
+			pipe.Set(ctx, denyKey(id), 1, ttl)
+		}
+		pipe.Del(ctx, sessionKey(id))
+		pipe.SRem(ctx, sessionsKey(uid), id)
+		return nil
+	})
+
+	return err
+}
+
+// 注销 token 所在的会话
+// 会话的有效期可能比 token 长 (例如 JWT 模式下的刷新令牌), 所以优先使用会话记录的过期时间
+func (s store) revokeToken(claims token.Claims) error {
+	expiredAt := time.Unix(claims.ExpiresAt, 0)
+
+	info, err := s.get(claims.Sid)
+
+	if err != nil {
+		return err
+	}
+
+	if info != nil {
+		expiredAt = info.ExpiredAt
+	}
+
+	return s.revoke(claims.Uid, claims.Sid, expiredAt)
+}
+
+// 注销用户的某个会话
+func (s store) revokeSession(uid string, id string) error {
+	info, err := s.get(id)
+
+	if err != nil {
+		return err
+	}
+
+	if info == nil || info.Uid != uid {
+		return exception.SessionNotExist
+	}
+
+	return s.revoke(uid, id, info.ExpiredAt)
+}
+
+// 注销用户的所有会话, except 为需要保留的会话ID
+// 没有保留任何会话时, 同时让这个时间之前签发的所有 token 失效, 包括没有记录会话的 token
+func (s store) revokeAll(uid string, except string) (int, error) {
+	list, err := s.list(uid)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, info := range list {
+		if info.Id == except {
+			continue
+		}
+
+		if err := s.revoke(uid, info.Id, info.ExpiredAt); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	if len(except) == 0 {
+		if err := s.client().Set(context.Background(), revokeBeforeKey(uid), formatTime(time.Now()), maxDuration).Err(); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 币种注册表
+// 币种存放在数据库中, 每个币种对应一组 钱包/转账记录/财务流水 表, 表结构共用 model.Wallet/model.TransferLog/model.FinanceLog
+// 新增币种时会自动建表并为已有的用户开通钱包
+package currency
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/jinzhu/gorm"
+	"regexp"
+	"strings"
+)
+
+var codeReg = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,11}$`) // 币种代码, 会被用于表名, 所以只允许大写字母和数字
+
+// 校验币种代码是否合法
+func ValidCode(code string) bool {
+	return codeReg.MatchString(code)
+}
+
+// 获取所有已注册的币种, 包括已停用的
+func List(db *gorm.DB) ([]model.Currency, error) {
+	list := make([]model.Currency, 0)
+
+	if err := db.Order("code").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// 获取所有已注册的币种代码
+func Codes(db *gorm.DB) ([]string, error) {
+	list, err := List(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, len(list))
+
+	for _, c := range list {
+		codes = append(codes, c.Code)
+	}
+
+	return codes, nil
+}
+
+// 获取币种, 币种代码忽略大小写
+func Get(db *gorm.DB, code string) (*model.Currency, error) {
+	code = strings.ToUpper(code)
+
+	if !ValidCode(code) {
+		return nil, exception.InvalidWallet
+	}
+
+	c := model.Currency{}
+
+	if err := db.Where("code = ?", code).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, exception.InvalidWallet
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// 获取已启用的币种, 用于产生新的交易
+func GetEnabled(db *gorm.DB, code string) (*model.Currency, error) {
+	c, err := Get(db, code)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Enabled {
+		return nil, exception.CurrencyDisabled
+	}
+
+	return c, nil
+}
+
+// 注册一个新的币种, 并且为所有已存在的用户开通钱包
+// 应该在事务中调用
+func Register(tx *gorm.DB, c model.Currency) (*model.Currency, error) {
+	c.Code = strings.ToUpper(c.Code)
+
+	if !ValidCode(c.Code) {
+		return nil, exception.CurrencyInvalidCode
+	}
+
+	if c.Precision < 0 || c.Precision > decimal.Places {
+		return nil, exception.InvalidParams
+	}
+
+	if _, err := Get(tx, c.Code); err == nil {
+		return nil, exception.CurrencyExist
+	} else if err != exception.InvalidWallet {
+		return nil, err
+	}
+
+	if err := tx.Create(&c).Error; err != nil {
+		return nil, err
+	}
+
+	if err := EnsureTables(tx, c.Code); err != nil {
+		return nil, err
+	}
+
+	// 为已有的用户开通钱包
+	raw := `INSERT INTO "` + model.WalletTableName(c.Code) + `" (id, currency, balance, frozen, created_at, updated_at)
+SELECT id, ?, 0, 0, NOW(), NOW() FROM "user" WHERE deleted_at IS NULL
+ON CONFLICT (id) DO NOTHING`
+
+	if err := tx.Exec(raw, c.Code).Error; err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// 确保币种对应的表存在, 并且同步表结构
+func EnsureTables(db *gorm.DB, code string) error {
+	tables := []struct {
+		name  string
+		value interface{}
+	}{
+		{model.WalletTableName(code), &model.Wallet{}},
+		{model.TransferLogTableName(code), &model.TransferLog{}},
+		{model.FinanceLogTableName(code), &model.FinanceLog{}},
+	}
+
+	for _, t := range tables {
+		if err := db.Table(t.name).AutoMigrate(t.value).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 为用户开通所有币种的钱包, 用于新用户注册
+func CreateWallets(tx *gorm.DB, uid string) error {
+	codes, err := Codes(tx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if err := tx.Table(model.WalletTableName(code)).Create(&model.Wallet{
+			Id:       uid,
+			Currency: code,
+			Balance:  decimal.Zero,
+			Frozen:   decimal.Zero,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 写入内置的币种, 并且同步所有币种的表结构
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(new(model.Currency)).Error; err != nil {
+		return err
+	}
+
+	for _, c := range model.DefaultCurrencies {
+		if err := db.Where(model.Currency{Code: c.Code}).FirstOrCreate(&c).Error; err != nil {
+			return err
+		}
+	}
+
+	codes, err := Codes(db)
+
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if err := EnsureTables(db, code); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
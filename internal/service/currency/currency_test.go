@@ -0,0 +1,18 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package currency_test
+
+import (
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidCode(t *testing.T) {
+	for _, code := range []string{"CNY", "USD", "COIN", "USDT", "B2", "ABCDEFGHIJKL"} {
+		assert.True(t, currency.ValidCode(code), code)
+	}
+
+	for _, code := range []string{"", "C", "cny", "2B", "US-D", "US D", `CNY"; DROP TABLE "user`, "ABCDEFGHIJKLM"} {
+		assert.False(t, currency.ValidCode(code), code)
+	}
+}
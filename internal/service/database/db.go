@@ -4,10 +4,11 @@ package database
 import (
 	"fmt"
 	"github.com/axetroy/go-server/internal/library/config"
-	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/axetroy/go-server/internal/model"
 	"github.com/axetroy/go-server/internal/rbac/role"
+	"github.com/axetroy/go-server/internal/service/currency"
 	"github.com/axetroy/go-server/internal/service/dotenv"
+	"github.com/axetroy/go-server/internal/service/password"
 	"github.com/jinzhu/gorm"
 	_ "github.com/lib/pq"
 	"log"
@@ -53,17 +54,9 @@ func Migrate(db *gorm.DB) error {
 		new(model.News),                // 新闻公告
 		new(model.Role),                // 角色表 - RBAC
 		new(model.User),                // 用户表
-		new(model.WalletCny),           // 钱包 - CNY
-		new(model.WalletUsd),           // 钱包 - USD
-		new(model.WalletCoin),          // 钱包 - COIN
 		new(model.InviteHistory),       // 邀请表
+		new(model.InviteReward),        // 邀请奖励的发放记录
 		new(model.LoginLog),            // 登陆成功表
-		new(model.TransferLogCny),      // 转账记录 - CNY
-		new(model.TransferLogUsd),      // 转账记录 - USD
-		new(model.TransferLogCoin),     // 转账记录 - COIN
-		new(model.FinanceLogCny),       // 流水列表 - CNY
-		new(model.FinanceLogUsd),       // 流水列表 - USD
-		new(model.FinanceLogCoin),      // 流水列表 - COIN
 		new(model.Notification),        // 系统消息
 		new(model.NotificationMark),    // 系统消息的已读记录
 		new(model.Message),             // 个人消息
@@ -76,10 +69,52 @@ func Migrate(db *gorm.DB) error {
 		new(model.OAuth),               // oAuth2 表
 		new(model.CustomerSession),     // 客服会话表
 		new(model.CustomerSessionItem), // 客服会话内容表
+		new(model.ExchangeRate),        // 币种兑换汇率
+		new(model.Exchange),            // 币种兑换记录
+		new(model.Reconciliation),      // 对账记录
+		new(model.ReconciliationItem),  // 对账发现的差异
+		new(model.TokenKey),            // token 的签名密钥
+		new(model.OAuthClient),         // 接入的第三方应用
+		new(model.OAuthConsent),        // 用户给第三方应用的授权
+		new(model.WebAuthnCredential),  // WebAuthn 凭证
+		new(model.TransferIdempotency), // 转账的幂等键
 	).Error; err != nil {
 		return err
 	}
 
+	// AutoMigrate 不会修改已存在的字段类型, 密码字段需要扩容以存放新的 hash 格式
+	if err := db.Model(new(model.Admin)).ModifyColumn("password", "varchar(255)").Error; err != nil {
+		return err
+	}
+
+	if err := db.Model(new(model.User)).ModifyColumn("password", "varchar(255)").Error; err != nil {
+		return err
+	}
+
+	if err := db.Model(new(model.User)).ModifyColumn("pay_password", "varchar(255)").Error; err != nil {
+		return err
+	}
+
+	// 币种注册表, 钱包/转账记录/财务流水 表根据注册的币种生成
+	if err := currency.Migrate(db); err != nil {
+		return err
+	}
+
+	codes, err := currency.Codes(db)
+
+	if err != nil {
+		return err
+	}
+
+	// 流水表的金额字段从浮点数改为 numeric, 避免精度丢失
+	for _, code := range codes {
+		for _, column := range []string{"before_balance", "balance_mutation", "after_balance", "before_frozen", "frozen_mutation", "after_frozen"} {
+			if err := db.Table(model.FinanceLogTableName(code)).ModifyColumn(column, "numeric").Error; err != nil {
+				return err
+			}
+		}
+	}
+
 	log.Println("数据库同步完成.")
 
 	superAdminInfo := model.Admin{Username: "admin", IsSuper: true}
@@ -87,10 +122,16 @@ func Migrate(db *gorm.DB) error {
 	// 确保超级管理员账号存在
 	if err := db.Where(&superAdminInfo).First(&superAdminInfo).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			hashed, err := password.Generate(dotenv.GetByDefault("ADMIN_DEFAULT_PASSWORD", "123456"))
+
+			if err != nil {
+				return err
+			}
+
 			err = db.Create(&model.Admin{
 				Username:  "admin",
 				Name:      "admin",
-				Password:  util.GeneratePassword(dotenv.GetByDefault("ADMIN_DEFAULT_PASSWORD", "123456")),
+				Password:  hashed,
 				Accession: []string{},
 				Status:    model.AdminStatusInit,
 				IsSuper:   true,
@@ -108,6 +149,7 @@ func Migrate(db *gorm.DB) error {
 	buildInRoles := []*role.Role{
 		model.DefaultUser,
 		model.DefaultWaiter,
+		model.DefaultSupervisor,
 	}
 
 	for _, buildInRole := range buildInRoles {
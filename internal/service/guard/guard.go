@@ -0,0 +1,327 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 防暴力破解
+// 按帐号, IP, 手机号/邮箱分别统计登陆失败和发送验证码的次数, 计数存放在 redis 中, 多个实例共享
+// 连续失败之后每次重试需要等待的时间逐渐变长, 达到上限之后临时锁定
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeUser  Scope = "user"  // 用户帐号
+	ScopeAdmin Scope = "admin" // 管理员帐号
+	ScopeEmail Scope = "email" // 邮箱
+	ScopePhone Scope = "phone" // 手机号
+	ScopeIp    Scope = "ip"    // IP 地址
+)
+
+// 没有在配置中心设置时使用的规则
+var DefaultRules = model.ConfigFieldSignInGuard{
+	Window:       900,
+	AccountLimit: 5,
+	IpLimit:      50,
+	LockDuration: 900,
+	DelayAfter:   3,
+	DelayBase:    1,
+	DelayMax:     30,
+	CodeWindow:   3600,
+	CodeLimit:    10,
+	CodeIpLimit:  30,
+}
+
+// 统计的对象
+type Target struct {
+	Scope Scope  `json:"scope"`
+	Id    string `json:"id"`
+}
+
+func Account(scope Scope, id string) Target {
+	return Target{Scope: scope, Id: id}
+}
+
+func Ip(ip string) Target {
+	return Target{Scope: ScopeIp, Id: ip}
+}
+
+func (t Target) key() string {
+	return string(t.Scope) + ":" + t.Id
+}
+
+func (t Target) IsIp() bool {
+	return t.Scope == ScopeIp
+}
+
+func failKey(t Target) string {
+	return "fail:" + t.key()
+}
+
+func delayKey(t Target) string {
+	return "delay:" + t.key()
+}
+
+func lockKey(t Target) string {
+	return "lock:" + t.key()
+}
+
+func sendKey(t Target) string {
+	return "send:" + t.key()
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+type Guard struct {
+	Rules model.ConfigFieldSignInGuard
+}
+
+func New(rules model.ConfigFieldSignInGuard) *Guard {
+	return &Guard{Rules: rules}
+}
+
+// 读取配置中心的规则, 没有配置则使用默认规则
+func Load(db *gorm.DB) (*Guard, error) {
+	c := model.Config{Name: model.ConfigFieldNameSignInGuard.Field}
+
+	if err := db.Model(&c).Where(&c).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return New(DefaultRules), nil
+		}
+		return nil, err
+	}
+
+	rules := model.ConfigFieldSignInGuard{}
+
+	if err := json.Unmarshal([]byte(c.Fields), &rules); err != nil {
+		return nil, err
+	}
+
+	return New(rules), nil
+}
+
+// 失败次数的上限
+func (g *Guard) limit(t Target) int64 {
+	if t.IsIp() {
+		return int64(g.Rules.IpLimit)
+	}
+
+	return int64(g.Rules.AccountLimit)
+}
+
+// 连续失败 n 次之后需要等待的时间
+func (g *Guard) Delay(n int64) time.Duration {
+	if g.Rules.DelayAfter <= 0 || g.Rules.DelayBase <= 0 || n < int64(g.Rules.DelayAfter) {
+		return 0
+	}
+
+	delay := seconds(g.Rules.DelayBase)
+	max := seconds(g.Rules.DelayMax)
+
+	for i := int64(g.Rules.DelayAfter); i < n; i++ {
+		delay *= 2
+
+		if max > 0 && delay >= max {
+			break
+		}
+	}
+
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// 检查所有对象, 都没有被锁定或者需要等待时才同时记录这次尝试, 检查和计数在同一个脚本中完成
+// KEYS 依次为每个对象的 lock, delay, fail, ARGV 为统计周期和每个对象的失败次数上限
+var attemptScript = nativeRedis.NewScript(`
+local n = #KEYS / 3
+
+for i = 1, n do
+  if redis.call("EXISTS", KEYS[i * 3 - 2]) == 1 then
+    return {1, i}
+  end
+
+  if redis.call("EXISTS", KEYS[i * 3 - 1]) == 1 then
+    return {2, i}
+  end
+
+  local limit = tonumber(ARGV[i + 1])
+
+  if limit > 0 and tonumber(redis.call("GET", KEYS[i * 3]) or "0") >= limit then
+    return {2, i}
+  end
+end
+
+for i = 1, n do
+  if redis.call("INCR", KEYS[i * 3]) == 1 and tonumber(ARGV[1]) > 0 then
+    redis.call("EXPIRE", KEYS[i * 3], ARGV[1])
+  end
+end
+
+return {0, 0}
+`)
+
+// 尝试之前检查是否被锁定或者需要等待, 允许尝试时先计入失败次数, 成功之后再通过 Reset 撤销
+// 并发的尝试在 redis 中串行执行, 周期内允许的尝试次数不会超过上限
+func (g *Guard) Attempt(targets ...Target) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(targets)*3)
+	args := []interface{}{g.Rules.Window}
+
+	for _, t := range targets {
+		keys = append(keys, lockKey(t), delayKey(t), failKey(t))
+		args = append(args, g.limit(t))
+	}
+
+	result, err := attemptScript.Run(context.Background(), redis.ClientGuard, keys, args...).Result()
+
+	if err != nil {
+		return err
+	}
+
+	values, ok := result.([]interface{})
+
+	if !ok || len(values) != 2 {
+		return exception.Unknown
+	}
+
+	code, _ := values[0].(int64)
+	index, _ := values[1].(int64)
+
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		if index >= 1 && int(index) <= len(targets) && !targets[index-1].IsIp() {
+			return exception.AccountLocked
+		}
+	}
+
+	return exception.TooManyAttempts
+}
+
+// 确认 Attempt 记录的尝试失败了, 返回这次失败之后被锁定的对象
+func (g *Guard) Fail(targets ...Target) ([]Target, error) {
+	var (
+		ctx    = context.Background()
+		locked = make([]Target, 0)
+	)
+
+	for _, t := range targets {
+		n, err := redis.ClientGuard.Get(ctx, failKey(t)).Int64()
+
+		if err != nil && err != redis.Nil {
+			return locked, err
+		}
+
+		if limit := g.limit(t); limit > 0 && n >= limit {
+			// 只有第一次达到上限的请求负责锁定, 并发的失败不会重复锁定
+			ok, err := redis.ClientGuard.SetNX(ctx, lockKey(t), n, seconds(g.Rules.LockDuration)).Result()
+
+			if err != nil {
+				return locked, err
+			}
+
+			// 锁定之后重新计数
+			_ = redis.ClientGuard.Del(ctx, failKey(t), delayKey(t)).Err()
+
+			if ok {
+				locked = append(locked, t)
+			}
+
+			continue
+		}
+
+		if delay := g.Delay(n); delay > 0 {
+			if err := redis.ClientGuard.Set(ctx, delayKey(t), n, delay).Err(); err != nil {
+				return locked, err
+			}
+		}
+	}
+
+	return locked, nil
+}
+
+// 成功之后撤销 Attempt 记录的尝试, 不会解除已经生效的锁定
+// 帐号清除连续失败的次数, IP 只撤销这一次尝试, 同一个 IP 上其他帐号的失败仍然计数
+func (g *Guard) Reset(targets ...Target) error {
+	ctx := context.Background()
+
+	for _, t := range targets {
+		if !t.IsIp() {
+			if err := redis.ClientGuard.Del(ctx, failKey(t), delayKey(t)).Err(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		n, err := redis.ClientGuard.Decr(ctx, failKey(t)).Result()
+
+		if err != nil {
+			return err
+		}
+
+		if n <= 0 {
+			_ = redis.ClientGuard.Del(ctx, failKey(t)).Err()
+		}
+	}
+
+	return nil
+}
+
+// 发送验证码之前检查并记录发送次数
+// 手机号/邮箱和 IP 在周期内的发送次数都有上限, 重新发送的间隔由 verifier 控制
+func (g *Guard) Send(targets ...Target) error {
+	ctx := context.Background()
+
+	for _, t := range targets {
+		limit := int64(g.Rules.CodeLimit)
+
+		if t.IsIp() {
+			limit = int64(g.Rules.CodeIpLimit)
+		}
+
+		if limit <= 0 {
+			continue
+		}
+
+		n, err := redis.ClientGuard.Get(ctx, sendKey(t)).Int64()
+
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		if n >= limit {
+			return exception.SendCodeTooFrequent
+		}
+	}
+
+	for _, t := range targets {
+		n, err := redis.ClientGuard.Incr(ctx, sendKey(t)).Result()
+
+		if err != nil {
+			return err
+		}
+
+		if n == 1 {
+			_ = redis.ClientGuard.Expire(ctx, sendKey(t), seconds(g.Rules.CodeWindow)).Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package guard_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuard_Delay(t *testing.T) {
+	g := guard.New(guard.DefaultRules)
+
+	assert.Equal(t, time.Duration(0), g.Delay(1))
+	assert.Equal(t, time.Duration(0), g.Delay(2))
+	assert.Equal(t, time.Second, g.Delay(3))
+	assert.Equal(t, time.Second*2, g.Delay(4))
+	assert.Equal(t, time.Second*4, g.Delay(5))
+	assert.Equal(t, time.Second*30, g.Delay(100))
+
+	// 不延迟
+	rules := guard.DefaultRules
+	rules.DelayAfter = 0
+
+	assert.Equal(t, time.Duration(0), guard.New(rules).Delay(100))
+}
+
+func TestGuard_Fail(t *testing.T) {
+	rules := guard.DefaultRules
+	rules.DelayAfter = 0
+
+	g := guard.New(rules)
+
+	account := guard.Account(guard.ScopeUser, "test-"+util.RandomString(8))
+	ip := guard.Ip(util.RandomString(8))
+
+	for i := 1; i < rules.AccountLimit; i++ {
+		assert.Nil(t, g.Attempt(account, ip))
+
+		locked, err := g.Fail(account, ip)
+
+		assert.Nil(t, err)
+		assert.Len(t, locked, 0)
+	}
+
+	// 达到上限之后锁定帐号, IP 没有达到上限
+	assert.Nil(t, g.Attempt(account, ip))
+
+	locked, err := g.Fail(account, ip)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []guard.Target{account}, locked)
+	assert.Equal(t, exception.AccountLocked, g.Attempt(account, ip))
+	assert.Nil(t, g.Attempt(ip))
+
+	// 成功不会解除锁定
+	assert.Nil(t, g.Reset(account))
+	assert.Equal(t, exception.AccountLocked, g.Attempt(account))
+}
+
+func TestGuard_FailWithDelay(t *testing.T) {
+	g := guard.New(guard.DefaultRules)
+
+	account := guard.Account(guard.ScopeUser, "test-"+util.RandomString(8))
+
+	for i := 1; i < guard.DefaultRules.DelayAfter; i++ {
+		assert.Nil(t, g.Attempt(account))
+
+		_, err := g.Fail(account)
+
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, g.Attempt(account))
+
+	_, err := g.Fail(account)
+
+	assert.Nil(t, err)
+	assert.Equal(t, exception.TooManyAttempts, g.Attempt(account))
+
+	// 成功之后重新计数
+	assert.Nil(t, g.Reset(account))
+	assert.Nil(t, g.Attempt(account))
+}
+
+func TestGuard_Reset(t *testing.T) {
+	rules := guard.DefaultRules
+	rules.IpLimit = 2
+
+	g := guard.New(rules)
+
+	ip := guard.Ip(util.RandomString(8))
+
+	// 成功的尝试不计入 IP 的次数
+	assert.Nil(t, g.Attempt(ip))
+	assert.Nil(t, g.Reset(ip))
+
+	assert.Nil(t, g.Attempt(ip))
+	assert.Nil(t, g.Attempt(ip))
+	assert.Equal(t, exception.TooManyAttempts, g.Attempt(ip))
+}
+
+func TestGuard_AttemptConcurrent(t *testing.T) {
+	rules := guard.DefaultRules
+	rules.DelayAfter = 0
+
+	g := guard.New(rules)
+
+	account := guard.Account(guard.ScopeUser, "test-"+util.RandomString(8))
+
+	var (
+		wg      sync.WaitGroup
+		allowed int32
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if g.Attempt(account) == nil {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// 并发的尝试不会超过上限
+	assert.Equal(t, int32(rules.AccountLimit), allowed)
+}
+
+func TestGuard_Send(t *testing.T) {
+	rules := guard.DefaultRules
+	rules.CodeLimit = 2
+
+	g := guard.New(rules)
+
+	phone := guard.Account(guard.ScopePhone, util.RandomNumeric(11))
+	ip := guard.Ip(util.RandomString(8))
+
+	assert.Nil(t, g.Send(phone, ip))
+	assert.Nil(t, g.Send(phone, ip))
+	assert.Equal(t, exception.SendCodeTooFrequent, g.Send(phone, ip))
+}
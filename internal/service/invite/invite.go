@@ -0,0 +1,266 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 邀请奖励
+// 被邀请人的状态只会前进, 每次前进时按照配置的规则给邀请人和被邀请人发放奖励
+// 状态的推进与奖励的发放在同一个事务中完成, 所以每个状态的奖励只会发放一次
+// 转账等业务在提交之后再推进状态, 奖励不会和业务在同一个事务中锁定钱包
+package invite
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/logger"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/axetroy/go-server/internal/service/ledger"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// 最终的状态, 达到这个状态之后不会再有奖励, reward_settled 会被标记为 true
+const FinalStatus = model.StatusInvitePay
+
+// 获取奖励规则, 没有配置则返回空
+func Rules(db *gorm.DB) ([]model.ConfigFieldInviteRewardRule, error) {
+	c := model.Config{Name: model.ConfigFieldNameInviteReward.Field}
+
+	if err := db.Model(&c).Where(&c).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	reward := model.ConfigFieldInviteReward{}
+
+	if err := json.Unmarshal([]byte(c.Fields), &reward); err != nil {
+		return nil, err
+	}
+
+	return reward.Rules, nil
+}
+
+// 被邀请人达到了新的状态, 没有被邀请或者已经达到该状态则什么都不做
+// 应该在事务中调用
+func Advance(tx *gorm.DB, invitee string, status model.InviteStatus) error {
+	inviteInfo := model.InviteHistory{}
+
+	if err := tx.Where("invitee = ?", invitee).First(&inviteInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if inviteInfo.Status >= status {
+		// 之前有规则配置错误没有结清, 规则修正之后在下一次触发时补发
+		if inviteInfo.Status >= FinalStatus && !inviteInfo.RewardSettled {
+			return settle(tx, &inviteInfo, nil)
+		}
+		return nil
+	}
+
+	// 带条件的更新, 并发的事务中只有一个能推进状态, 也只有这个事务会发放奖励
+	result := tx.Model(&model.InviteHistory{}).Where("id = ? AND status < ?", inviteInfo.Id, status).UpdateColumns(map[string]interface{}{
+		"status":     status,
+		"updated_at": time.Now(),
+	})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected != 1 {
+		return nil
+	}
+
+	previous := inviteInfo.Status
+
+	inviteInfo.Status = status
+
+	// 状态可能跳过了中间的阶段, 例如没有实名认证就完成了支付, 跳过的阶段的奖励也要发放
+	return settle(tx, &inviteInfo, &previous)
+}
+
+// 在单独的事务中推进状态, 应该在业务的事务提交之后调用
+// 发放奖励会锁定邀请人和被邀请人的钱包, 如果在已经锁定了其他钱包的事务中发放, 不同的加锁顺序可能导致死锁
+// 失败时状态没有推进, 下一次触发时会重新发放, 所以这里只记录日志, 不影响已经完成的业务
+func AdvanceAfterCommit(db *gorm.DB, invitee string, status model.InviteStatus) {
+	tx := db.Begin()
+
+	if err := Advance(tx, invitee, status); err != nil {
+		_ = tx.Rollback().Error
+		logger.Errorf("Advance invite of %s to %d failed: %s", invitee, status, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logger.Errorf("Advance invite of %s to %d failed: %s", invitee, status, err)
+	}
+}
+
+// 重新发放没有结清的奖励, 用于管理员修正规则配置之后补发, 已经发放过的阶段不会重复发放
+// 应该在事务中调用
+func Retry(tx *gorm.DB, invitee string) error {
+	inviteInfo := model.InviteHistory{}
+
+	if err := tx.Where("invitee = ?", invitee).First(&inviteInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return exception.NoData
+		}
+		return err
+	}
+
+	if inviteInfo.RewardSettled {
+		return nil
+	}
+
+	return settle(tx, &inviteInfo, nil)
+}
+
+// 邀请记录创建时发放奖励, 发放不超过当前状态的所有阶段的奖励
+// 应该在事务中调用
+func Settle(tx *gorm.DB, inviteInfo *model.InviteHistory) error {
+	return settle(tx, inviteInfo, nil)
+}
+
+// 发放 previous (不含) 到当前状态 (含) 之间的所有阶段的奖励, previous 为空则从头开始
+func settle(tx *gorm.DB, inviteInfo *model.InviteHistory, previous *model.InviteStatus) error {
+	rules, err := Rules(tx)
+
+	if err != nil {
+		return err
+	}
+
+	// 有规则配置错误没有发放, reward_settled 保持为 false, 由管理员处理
+	skipped := false
+
+	for _, rule := range rules {
+		if rule.Status > inviteInfo.Status || (previous != nil && rule.Status <= *previous) {
+			continue
+		}
+
+		if err := settleRule(tx, inviteInfo, rule); err != nil {
+			if err != errInvalidRule {
+				return err
+			}
+			skipped = true
+		}
+	}
+
+	if inviteInfo.Status >= FinalStatus && !inviteInfo.RewardSettled && !skipped {
+		if err := tx.Model(&model.InviteHistory{}).Where("id = ?", inviteInfo.Id).UpdateColumn("reward_settled", true).Error; err != nil {
+			return err
+		}
+
+		inviteInfo.RewardSettled = true
+	}
+
+	return nil
+}
+
+// 规则配置错误, 这条规则的奖励没有发放
+var errInvalidRule = exception.InvalidParams.New("邀请奖励规则配置错误")
+
+func settleRule(tx *gorm.DB, inviteInfo *model.InviteHistory, rule model.ConfigFieldInviteRewardRule) error {
+	// 规则配置错误不应该影响用户的正常操作, 记录下来由管理员处理
+	currencyInfo, err := currency.Get(tx, rule.Currency)
+
+	if err != nil {
+		if err == exception.InvalidWallet {
+			logger.Errorf("Invalid currency %s in invite reward rule", rule.Currency)
+			return errInvalidRule
+		}
+		return err
+	}
+
+	inviterAmount, err := parseAmount(rule.Inviter)
+
+	if err != nil || !currencyInfo.ValidAmount(inviterAmount) {
+		logger.Errorf("Invalid inviter amount %s in invite reward rule", rule.Inviter)
+		return errInvalidRule
+	}
+
+	inviteeAmount, err := parseAmount(rule.Invitee)
+
+	if err != nil || !currencyInfo.ValidAmount(inviteeAmount) {
+		logger.Errorf("Invalid invitee amount %s in invite reward rule", rule.Invitee)
+		return errInvalidRule
+	}
+
+	if inviterAmount.IsZero() && inviteeAmount.IsZero() {
+		return nil
+	}
+
+	reward := model.InviteReward{
+		InviteId:      inviteInfo.Id,
+		Status:        rule.Status,
+		Currency:      currencyInfo.Code,
+		Inviter:       inviteInfo.Inviter,
+		Invitee:       inviteInfo.Invitee,
+		InviterAmount: inviterAmount,
+		InviteeAmount: inviteeAmount,
+	}
+
+	// 已经发放过了
+	if err := tx.Where("invite_id = ? AND status = ? AND currency = ?", reward.InviteId, reward.Status, reward.Currency).First(&model.InviteReward{}).Error; err == nil {
+		return nil
+	} else if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	// 唯一索引保证了即使并发也不会重复发放, 冲突时整个事务回滚
+	if err := tx.Create(&reward).Error; err != nil {
+		return err
+	}
+
+	wallets, err := ledger.Lock(tx, currencyInfo.Code, reward.Inviter, reward.Invitee)
+
+	if err != nil {
+		return err
+	}
+
+	logs := make([]*model.FinanceLog, 0)
+
+	for _, r := range []struct {
+		uid    string
+		amount decimal.Decimal
+	}{
+		{reward.Inviter, inviterAmount},
+		{reward.Invitee, inviteeAmount},
+	} {
+		if !r.amount.IsPositive() {
+			continue
+		}
+
+		log, err := ledger.Mutate(tx, wallets[r.uid], r.amount, decimal.Zero)
+
+		if err != nil {
+			return err
+		}
+
+		log.Type = model.FinanceTypeInviteReward
+
+		logs = append(logs, log)
+	}
+
+	return ledger.WriteLogs(tx, currencyInfo.Code, reward.Id, logs...)
+}
+
+func parseAmount(amount string) (decimal.Decimal, error) {
+	if amount == "" {
+		return decimal.Zero, nil
+	}
+
+	d, err := decimal.NewFromString(amount)
+
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if d.IsNegative() {
+		return decimal.Zero, exception.InvalidParams
+	}
+
+	return d, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package invite_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/app/user_server/controller/auth"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/schema"
+	"github.com/axetroy/go-server/internal/service/database"
+	"github.com/axetroy/go-server/internal/service/invite"
+	"github.com/axetroy/go-server/tester"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getWallet(t *testing.T, uid string) model.Wallet {
+	w := model.Wallet{}
+
+	assert.Nil(t, database.Db.Table(model.WalletTableName(model.WalletCOIN)).Where("id = ?", uid).First(&w).Error)
+
+	return w
+}
+
+func TestAdvance(t *testing.T) {
+	fields, _ := json.Marshal(model.ConfigFieldInviteReward{
+		Rules: []model.ConfigFieldInviteRewardRule{
+			{Status: model.StatusInviteRegistered, Currency: model.WalletCOIN, Inviter: "10", Invitee: "5"},
+			{Status: model.StatusInviteAuth, Currency: model.WalletCOIN, Inviter: "3", Invitee: "1"},
+			{Status: model.StatusInvitePay, Currency: model.WalletCOIN, Inviter: "20"},
+		},
+	})
+
+	c := model.Config{Name: model.ConfigFieldNameInviteReward.Field, Fields: string(fields)}
+
+	database.DeleteRowByTable(c.TableName(), "name", c.Name)
+
+	assert.Nil(t, database.Db.Create(&c).Error)
+
+	defer database.DeleteRowByTable(c.TableName(), "name", c.Name)
+
+	inviter, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(inviter.Username)
+
+	username := "test-" + util.RandomString(6)
+
+	res := auth.SignUpWithUsername(auth.SignUpWithUsernameParams{
+		Username:   username,
+		Password:   "123123",
+		InviteCode: &inviter.InviteCode,
+	})
+
+	assert.Equal(t, "", res.Message)
+	assert.Equal(t, schema.StatusSuccess, res.Status)
+
+	defer tester.DeleteUserByUserName(username)
+
+	invitee := schema.Profile{}
+
+	assert.Nil(t, res.Decode(&invitee))
+
+	// 注册时发放奖励
+	assert.Equal(t, "10.00000000", getWallet(t, inviter.Id).Balance.String())
+	assert.Equal(t, "5.00000000", getWallet(t, invitee.Id).Balance.String())
+
+	// 没有实名认证就完成了支付, 实名认证的奖励也要发放. 重复调用只会发放一次
+	for i := 0; i < 2; i++ {
+		tx := database.Db.Begin()
+
+		assert.Nil(t, invite.Advance(tx, invitee.Id, model.StatusInvitePay))
+		assert.Nil(t, tx.Commit().Error)
+	}
+
+	assert.Equal(t, "33.00000000", getWallet(t, inviter.Id).Balance.String())
+	assert.Equal(t, "6.00000000", getWallet(t, invitee.Id).Balance.String())
+
+	inviteInfo := model.InviteHistory{}
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&inviteInfo).Error)
+	assert.Equal(t, model.StatusInvitePay, inviteInfo.Status)
+	assert.True(t, inviteInfo.RewardSettled)
+
+	// 状态不会后退
+	tx := database.Db.Begin()
+
+	assert.Nil(t, invite.Advance(tx, invitee.Id, model.StatusInviteAuth))
+	assert.Nil(t, tx.Commit().Error)
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&inviteInfo).Error)
+	assert.Equal(t, model.StatusInvitePay, inviteInfo.Status)
+
+	// 没有被邀请的用户
+	tx = database.Db.Begin()
+
+	assert.Nil(t, invite.Advance(tx, inviter.Id, model.StatusInvitePay))
+	assert.Nil(t, tx.Commit().Error)
+}
+
+// 规则配置错误时不影响用户的操作, 但是不会标记为已发放
+func TestAdvanceInvalidRule(t *testing.T) {
+	fields, _ := json.Marshal(model.ConfigFieldInviteReward{
+		Rules: []model.ConfigFieldInviteRewardRule{
+			{Status: model.StatusInvitePay, Currency: "NOT_EXIST", Inviter: "20"},
+		},
+	})
+
+	c := model.Config{Name: model.ConfigFieldNameInviteReward.Field, Fields: string(fields)}
+
+	database.DeleteRowByTable(c.TableName(), "name", c.Name)
+
+	assert.Nil(t, database.Db.Create(&c).Error)
+
+	defer database.DeleteRowByTable(c.TableName(), "name", c.Name)
+
+	inviter, _ := tester.CreateUser()
+
+	defer tester.DeleteUserByUserName(inviter.Username)
+
+	username := "test-" + util.RandomString(6)
+
+	res := auth.SignUpWithUsername(auth.SignUpWithUsernameParams{
+		Username:   username,
+		Password:   "123123",
+		InviteCode: &inviter.InviteCode,
+	})
+
+	assert.Equal(t, "", res.Message)
+
+	defer tester.DeleteUserByUserName(username)
+
+	invitee := schema.Profile{}
+
+	assert.Nil(t, res.Decode(&invitee))
+
+	tx := database.Db.Begin()
+
+	assert.Nil(t, invite.Advance(tx, invitee.Id, model.StatusInvitePay))
+	assert.Nil(t, tx.Commit().Error)
+
+	inviteInfo := model.InviteHistory{}
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&inviteInfo).Error)
+	assert.Equal(t, model.StatusInvitePay, inviteInfo.Status)
+	assert.False(t, inviteInfo.RewardSettled)
+
+	// 修正规则之后, 下一次触发时补发
+	fields, _ = json.Marshal(model.ConfigFieldInviteReward{
+		Rules: []model.ConfigFieldInviteRewardRule{
+			{Status: model.StatusInvitePay, Currency: model.WalletCOIN, Inviter: "20"},
+		},
+	})
+
+	assert.Nil(t, database.Db.Model(&c).Where("name = ?", c.Name).UpdateColumn("fields", string(fields)).Error)
+
+	before := getWallet(t, inviter.Id)
+
+	invite.AdvanceAfterCommit(database.Db, invitee.Id, model.StatusInvitePay)
+
+	assert.Nil(t, database.Db.Where("invitee = ?", invitee.Id).First(&inviteInfo).Error)
+	assert.True(t, inviteInfo.RewardSettled)
+	assert.Equal(t, before.Balance.Add(decimal.NewFromInt(20)).String(), getWallet(t, inviter.Id).Balance.String())
+
+	// 已经结清, 不会重复发放
+	tx = database.Db.Begin()
+
+	assert.Nil(t, invite.Retry(tx, invitee.Id))
+	assert.Nil(t, tx.Commit().Error)
+	assert.Equal(t, before.Balance.Add(decimal.NewFromInt(20)).String(), getWallet(t, inviter.Id).Balance.String())
+}
@@ -0,0 +1,95 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 钱包账本, 所有对钱包余额的变动都应该通过这里进行
+// 保证加锁顺序一致, 并且每一次变动都会产生对应的财务流水
+package ledger
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 在事务中锁定钱包 (SELECT ... FOR UPDATE), 直到事务结束
+// 多个钱包总是按照 id 从小到大的顺序加锁, 避免并发事务之间互相等待造成死锁
+func Lock(tx *gorm.DB, currency string, ids ...string) (map[string]*model.Wallet, error) {
+	var (
+		tableName = model.WalletTableName(currency)
+		sorted    = make([]string, len(ids))
+		result    = map[string]*model.Wallet{}
+	)
+
+	copy(sorted, ids)
+	sort.Strings(sorted)
+
+	for _, id := range sorted {
+		if _, ok := result[id]; ok {
+			continue
+		}
+
+		w := model.Wallet{}
+
+		if err := tx.Table(tableName).Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&w).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, exception.InvalidWallet
+			}
+			return nil, err
+		}
+
+		w.Currency = strings.ToUpper(currency)
+
+		result[id] = &w
+	}
+
+	return result, nil
+}
+
+// 变动已锁定的钱包, 返回对应的流水, 由调用方补充流水类型/订单号等信息后写入
+// 使用带条件的更新语句, 即使调用方忘记加锁, 余额也不会被扣成负数
+func Mutate(tx *gorm.DB, w *model.Wallet, balanceMutation decimal.Decimal, frozenMutation decimal.Decimal) (*model.FinanceLog, error) {
+	log, err := w.Mutate(balanceMutation, frozenMutation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := tx.Table(model.WalletTableName(w.Currency)).
+		Where("id = ? AND balance + ? >= 0 AND frozen + ? >= 0", w.Id, balanceMutation, frozenMutation).
+		UpdateColumns(map[string]interface{}{
+			"balance":    gorm.Expr("balance + ?", balanceMutation),
+			"frozen":     gorm.Expr("frozen + ?", frozenMutation),
+			"updated_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected != 1 {
+		return nil, exception.NotEnoughBalance
+	}
+
+	return log, nil
+}
+
+// 写入财务流水, 空的流水会被跳过
+func WriteLogs(tx *gorm.DB, currency string, orderId string, logs ...*model.FinanceLog) error {
+	tableName := model.FinanceLogTableName(currency)
+
+	for _, log := range logs {
+		if log == nil {
+			continue
+		}
+
+		log.OrderId = orderId
+
+		if err := tx.Table(tableName).Create(log).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
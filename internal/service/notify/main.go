@@ -17,6 +17,8 @@ type Notifier interface {
 	SendNotifySystemNotificationToUser(notificationId string) error                                              // 推送系统通知
 	SendNotifyUserNewMessage(messageId string) error                                                             // 推送用户消息
 	SendNotifyToUserForLoginStatus(userID string) error                                                          // 推送用户登录异常
+	SendNotifyToUserForAccountLocked(userID string, ip string) error                                             // 推送帐号因登陆失败次数过多被锁定
+	SendNotifyToAdminForAccountLocked(adminID string, ip string) error                                           // 推送管理员帐号因登陆失败次数过多被锁定
 }
 
 var Notify = NewNotifierOneSignal()
@@ -27,6 +27,7 @@ const (
 
 	NotificationClickEventNone                  NotificationClickEvent = "none"                    // 空事件，点击通知什么都不会发送
 	NotificationClickEventLoginAbnormal         NotificationClickEvent = "login_abnormal"          // 新的系统通知事件
+	NotificationClickEventAccountLocked         NotificationClickEvent = "account_locked"          // 帐号被临时锁定事件
 	NotificationClickEventNewSystemNotification NotificationClickEvent = "new_system_notification" // 新的系统通知事件
 	NotificationClickEventNewUserMessage        NotificationClickEvent = "new_user_message"        // 新的系统通知事件
 )
@@ -122,7 +123,7 @@ func (n *NotifierOneSignal) SendNotifySystemNotificationToUser(notificationId st
 func (n *NotifierOneSignal) SendNotifyUserNewMessage(messageId string) error {
 	messageInfo := model.Message{}
 
-	if err := database.Db.Model(messageInfo).Where("id = ?", messageInfo).First(&messageInfo).Error; err != nil {
+	if err := database.Db.Model(messageInfo).Where("id = ?", messageId).First(&messageInfo).Error; err != nil {
 		// 如果没有这条消息，则跳过
 		if err == gorm.ErrRecordNotFound {
 			return nil
@@ -131,9 +132,9 @@ func (n *NotifierOneSignal) SendNotifyUserNewMessage(messageId string) error {
 	}
 
 	err := sdk.CreateNotification(onesignal.CreateNotificationParams{
-		IncludedSegments: []string{string(SegmentSubscribedUsers)},
-		Headings:         map[string]string{"en": messageInfo.Title},
-		Contents:         map[string]string{"en": messageInfo.Content},
+		IncludeExternalUserIds: []string{messageInfo.Uid},
+		Headings:               map[string]string{"en": messageInfo.Title},
+		Contents:               map[string]string{"en": messageInfo.Content},
 		Data: NotificationBody{
 			Event: NotificationClickEventNewUserMessage,
 			Payload: map[string]interface{}{
@@ -174,7 +175,7 @@ func (n *NotifierOneSignal) SendNotifyToUserForLoginStatus(userID string) error
 	loginLogs := make([]model.LoginLog, 0)
 
 	// 查找用户过往的登录记录, 只查找最近的两条
-	if err := database.Db.Model(model.LoginLog{}).Where("uid = ?", userInfo.Id).Limit(2).Order("created_at DESC").Find(&loginLogs).Error; err != nil {
+	if err := database.Db.Model(model.LoginLog{}).Where("uid = ? AND command = ?", userInfo.Id, model.LoginLogCommandLoginSuccess).Limit(2).Order("created_at DESC").Find(&loginLogs).Error; err != nil {
 		// 如果没有之前的登录记录
 		// 那么跳过
 		if err == gorm.ErrRecordNotFound {
@@ -214,3 +215,73 @@ func (n *NotifierOneSignal) SendNotifyToUserForLoginStatus(userID string) error
 
 	return nil
 }
+
+func (n *NotifierOneSignal) SendNotifyToUserForAccountLocked(userID string, ip string) error {
+	var name string
+
+	var userInfo = model.User{}
+
+	if err := database.Db.Model(userInfo).Where("id = ?", userID).First(&userInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// 如果找不到用户，我们就跳过本次任务
+			return nil
+		}
+		return err
+	}
+
+	if userInfo.Nickname == nil {
+		name = userInfo.Username
+	} else {
+		name = *userInfo.Nickname
+	}
+
+	err := sdk.CreateNotification(onesignal.CreateNotificationParams{
+		IncludeExternalUserIds: []string{userInfo.Id},
+		Headings:               map[string]string{"en": "帐号已被临时锁定"},
+		Contents:               map[string]string{"en": fmt.Sprintf("您的帐号 [%s] 登录失败次数过多，已被临时锁定。如果不是您本人的操作，请及时修改密码", name)},
+		Data: NotificationBody{
+			Event: NotificationClickEventAccountLocked,
+			Payload: map[string]interface{}{
+				"ip": ip,
+			},
+		},
+	})
+
+	if err != nil {
+		err = exception.ThirdParty.New(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (n *NotifierOneSignal) SendNotifyToAdminForAccountLocked(adminID string, ip string) error {
+	var adminInfo = model.Admin{}
+
+	if err := database.Db.Model(adminInfo).Where("id = ?", adminID).First(&adminInfo).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// 如果找不到管理员，我们就跳过本次任务
+			return nil
+		}
+		return err
+	}
+
+	err := sdk.CreateNotification(onesignal.CreateNotificationParams{
+		IncludeExternalUserIds: []string{adminInfo.Id},
+		Headings:               map[string]string{"en": "管理员帐号已被临时锁定"},
+		Contents:               map[string]string{"en": fmt.Sprintf("管理员帐号 [%s] 登录失败次数过多，已被临时锁定。如果不是您本人的操作，请及时修改密码", adminInfo.Username)},
+		Data: NotificationBody{
+			Event: NotificationClickEventAccountLocked,
+			Payload: map[string]interface{}{
+				"ip": ip,
+			},
+		},
+	})
+
+	if err != nil {
+		err = exception.ThirdParty.New(err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -1,44 +1,185 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 第三方帐号登陆
+// 服务提供商在配置中心配置, 后台定时重新读取, 启用/停用/修改之后不需要重启服务
 package oauth
 
 import (
+	"encoding/json"
 	"fmt"
-	"github.com/axetroy/go-server/internal/service/dotenv"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/jinzhu/gorm"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/facebook"
 	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/gitlab"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
 	"github.com/markbates/goth/providers/twitter"
+	"log"
+	"sync"
+	"time"
 )
 
-const (
-	domain       = "http://localhost:3000"
-	callbackPath = "/v1/oauth2/%s/callback"
+const callbackPath = "/v1/oauth2/%s/callback"
+
+// 重新读取配置中心的间隔
+var ReloadInterval = time.Second * 10
+
+// 已初始化的服务提供商
+type Provider struct {
+	Config   model.ConfigFieldOAuthProvider
+	Provider goth.Provider // 初始化失败时为 nil
+	Error    error         // 初始化失败的原因, 例如 OpenID Connect 的发现文档无法访问
+}
+
+var (
+	mu        sync.RWMutex
+	reloadMu  sync.Mutex
+	providers = map[string]*Provider{}
+	version   time.Time // 当前使用的配置的更新时间
 )
 
-func generateCallbackURL(provider string) string {
-	return domain + fmt.Sprintf(callbackPath, provider)
+// 服务提供商认证成功之后跳转回来的地址
+func CallbackURL(name string) string {
+	return config.Common.Domain + fmt.Sprintf(callbackPath, name)
 }
 
-func init() {
+// 根据配置创建服务提供商, OpenID Connect 会请求发现文档
+func New(c model.ConfigFieldOAuthProvider) (goth.Provider, error) {
+	var (
+		p        goth.Provider
+		callback = CallbackURL(c.Name)
+	)
 
-	goth.UseProviders(
-		twitter.New(dotenv.Get("TWITTER_KEY"), dotenv.Get("TWITTER_SECRET"), generateCallbackURL("twitter")),
-		// If you'd like to use authenticate instead of authorize in Twitter provider, use this instead.
-		// twitter.NewAuthenticate(dotenv.Get("TWITTER_KEY"), dotenv.Get("TWITTER_SECRET"), "http://localhost:3000/auth/twitter/callback"),
+	switch c.Type {
+	case model.ProviderGithub:
+		p = github.New(c.Key, c.Secret, callback, c.Scopes...)
+	case model.ProviderGitlab:
+		p = gitlab.New(c.Key, c.Secret, callback, c.Scopes...)
+	case model.ProviderGoogle:
+		p = google.New(c.Key, c.Secret, callback, c.Scopes...)
+	case model.ProviderFacebook:
+		p = facebook.New(c.Key, c.Secret, callback, c.Scopes...)
+	case model.ProviderTwitter:
+		p = twitter.New(c.Key, c.Secret, callback)
+	case model.ProviderOpenIdConnect:
+		provider, err := openidConnect.New(c.Key, c.Secret, callback, c.DiscoveryUrl, c.Scopes...)
 
-		facebook.New(dotenv.Get("FACEBOOK_KEY"), dotenv.Get("FACEBOOK_SECRET"), generateCallbackURL("facebook")),
-		google.New(dotenv.Get("GOOGLE_KEY"), dotenv.Get("GOOGLE_SECRET"), generateCallbackURL("google")),
-		github.New("GITHUB_KEY", "GITHUB_SECRET", generateCallbackURL("github")),
-		gitlab.New(dotenv.Get("GITLAB_KEY"), dotenv.Get("GITLAB_SECRET"), generateCallbackURL("gitlab")),
+		if err != nil {
+			return nil, err
+		}
+
+		p = provider
+	default:
+		return nil, exception.OAuthProviderNotExist
+	}
+
+	p.SetName(c.Name)
+
+	return p, nil
+}
+
+// 读取配置中心的配置, 没有配置则为空
+func LoadConfig(db *gorm.DB) (model.ConfigFieldOAuth, time.Time, error) {
+	var (
+		c      = model.Config{Name: model.ConfigFieldNameOAuth.Field}
+		fields = model.ConfigFieldOAuth{}
 	)
 
-	// OpenID Connect is based on OpenID Connect Auto Discovery URL (https://openid.net/specs/openid-connect-discovery-1_0-17.html)
-	// because the OpenID Connect provider initialize it self in the New(), it can return an error which should be handled or ignored
-	// ignore the error for now
-	//connection, _ := openidConnect.New(dotenv.Get("OPENID_CONNECT_KEY"), dotenv.Get("OPENID_CONNECT_SECRET"), "http://localhost:3000/auth/openid-connect/callback", dotenv.Get("OPENID_CONNECT_DISCOVERY_URL"))
-	//
-	//if connection != nil {
-	//	goth.UseProviders(connection)
-	//}
+	if err := db.Model(&c).Where(&c).First(&c).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fields, time.Time{}, nil
+		}
+		return fields, time.Time{}, err
+	}
+
+	if err := json.Unmarshal([]byte(c.Fields), &fields); err != nil {
+		return fields, time.Time{}, err
+	}
+
+	return fields, c.UpdatedAt, nil
+}
+
+// 重新读取配置, 配置没有变化时不会重新初始化
+// OpenID Connect 会请求发现文档, 只在后台调用, 不会阻塞请求
+func Reload(db *gorm.DB) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	fields, updatedAt, err := LoadConfig(db)
+
+	if err != nil {
+		return err
+	}
+
+	mu.RLock()
+	unchanged := updatedAt.Equal(version) && !version.IsZero()
+	// 初始化失败的服务提供商需要重试
+	for _, p := range providers {
+		if p.Error != nil {
+			unchanged = false
+		}
+	}
+	mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	list := map[string]*Provider{}
+
+	for _, c := range fields.Providers {
+		if !c.Enabled {
+			continue
+		}
+
+		p, err := New(c)
+
+		if err != nil {
+			log.Printf("初始化第三方登陆 %s 失败: %v\n", c.Name, err)
+		}
+
+		list[c.Name] = &Provider{Config: c, Provider: p, Error: err}
+	}
+
+	mu.Lock()
+	providers = list
+	version = updatedAt
+	mu.Unlock()
+
+	return nil
+}
+
+// 在后台定时重新读取配置, 读取失败时继续使用之前的配置
+func Watch(db *gorm.DB) {
+	go func() {
+		if err := Reload(db); err != nil {
+			log.Println(err)
+		}
+
+		for range time.Tick(ReloadInterval) {
+			if err := Reload(db); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+}
+
+// 获取已启用的服务提供商, 只读取后台加载好的配置
+func Get(name string) (goth.Provider, error) {
+	mu.RLock()
+	p, ok := providers[name]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, exception.OAuthProviderNotExist
+	}
+
+	if p.Error != nil {
+		return nil, exception.OAuthProviderUnavailable
+	}
+
+	return p.Provider, nil
 }
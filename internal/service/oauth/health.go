@@ -0,0 +1,104 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/internal/model"
+	"net/http"
+	"time"
+)
+
+// 检查的超时时间
+var CheckTimeout = time.Second * 5
+
+type Health struct {
+	Healthy bool
+	Error   string
+	Latency time.Duration
+}
+
+// 检查服务提供商是否可用: 能否初始化, 授权页面能否访问
+func Check(c model.ConfigFieldOAuthProvider) error {
+	p, err := New(c)
+
+	if err != nil {
+		return err
+	}
+
+	// twitter 会在这里请求 request token, 可以检查出 key 是否正确
+	session, err := p.BeginAuth("health")
+
+	if err != nil {
+		return err
+	}
+
+	authURL, err := session.GetAuthURL()
+
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{
+		Timeout: CheckTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(authURL)
+
+	if err != nil {
+		return err
+	}
+
+	_ = res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s responded with status %d", res.Request.URL.Host, res.StatusCode)
+	}
+
+	return nil
+}
+
+// 并发检查多个服务提供商, 超时的视为不可用
+func CheckAll(list []model.ConfigFieldOAuthProvider) []Health {
+	type result struct {
+		index  int
+		health Health
+	}
+
+	var (
+		results = make([]Health, len(list))
+		ch      = make(chan result, len(list))
+		timeout = time.After(CheckTimeout)
+	)
+
+	for i, c := range list {
+		results[i] = Health{Error: "timeout", Latency: CheckTimeout}
+
+		go func(i int, c model.ConfigFieldOAuthProvider) {
+			start := time.Now()
+			h := Health{Healthy: true}
+
+			if err := Check(c); err != nil {
+				h.Healthy = false
+				h.Error = err.Error()
+			}
+
+			h.Latency = time.Since(start)
+
+			ch <- result{index: i, health: h}
+		}(i, c)
+	}
+
+	for range list {
+		select {
+		case r := <-ch:
+			results[r.index] = r.health
+		case <-timeout:
+			return results
+		}
+	}
+
+	return results
+}
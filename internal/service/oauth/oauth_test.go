@@ -0,0 +1,220 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/oauth"
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 模拟 OpenID Connect 的服务提供商
+func newDiscoveryServer(authorizeStatus int) *httptest.Server {
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"userinfo_endpoint":      server.URL + "/userinfo",
+			})
+		case "/authorize":
+			w.WriteHeader(authorizeStatus)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server
+}
+
+func TestNew(t *testing.T) {
+	for _, providerType := range []model.OAuthProvider{model.ProviderGithub, model.ProviderGitlab, model.ProviderGoogle, model.ProviderFacebook, model.ProviderTwitter} {
+		p, err := oauth.New(model.ConfigFieldOAuthProvider{
+			Name:   "test-" + string(providerType),
+			Type:   providerType,
+			Key:    "key",
+			Secret: "secret",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "test-"+string(providerType), p.Name())
+	}
+
+	// 不支持的类型
+	{
+		_, err := oauth.New(model.ConfigFieldOAuthProvider{Name: "test", Type: "unknown", Key: "key", Secret: "secret"})
+
+		assert.Equal(t, exception.OAuthProviderNotExist, err)
+	}
+
+	// OpenID Connect
+	{
+		server := newDiscoveryServer(http.StatusOK)
+		defer server.Close()
+
+		p, err := oauth.New(model.ConfigFieldOAuthProvider{
+			Name:         "keycloak",
+			Type:         model.ProviderOpenIdConnect,
+			Key:          "key",
+			Secret:       "secret",
+			DiscoveryUrl: server.URL + "/.well-known/openid-configuration",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "keycloak", p.Name())
+
+		session, err := p.BeginAuth("state")
+
+		assert.Nil(t, err)
+
+		authURL, err := session.GetAuthURL()
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(authURL, server.URL+"/authorize"))
+		assert.Contains(t, authURL, url.QueryEscape(oauth.CallbackURL("keycloak")))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	healthy := newDiscoveryServer(http.StatusOK)
+	defer healthy.Close()
+
+	unhealthy := newDiscoveryServer(http.StatusBadGateway)
+	defer unhealthy.Close()
+
+	list := []model.ConfigFieldOAuthProvider{
+		{Name: "healthy", Type: model.ProviderOpenIdConnect, Key: "key", Secret: "secret", DiscoveryUrl: healthy.URL + "/.well-known/openid-configuration"},
+		{Name: "unhealthy", Type: model.ProviderOpenIdConnect, Key: "key", Secret: "secret", DiscoveryUrl: unhealthy.URL + "/.well-known/openid-configuration"},
+		{Name: "unreachable", Type: model.ProviderOpenIdConnect, Key: "key", Secret: "secret", DiscoveryUrl: "http://127.0.0.1:1/.well-known/openid-configuration"},
+	}
+
+	results := oauth.CheckAll(list)
+
+	assert.Len(t, results, 3)
+	assert.True(t, results[0].Healthy)
+	assert.False(t, results[1].Healthy)
+	assert.Contains(t, results[1].Error, "502")
+	assert.False(t, results[2].Healthy)
+	assert.NotEmpty(t, results[2].Error)
+}
+
+// 不访问网络的服务提供商
+type fakeProvider struct {
+	name string
+}
+
+type fakeSession struct {
+	AuthURL     string `json:"auth_url"`
+	AccessToken string `json:"access_token"`
+}
+
+func (s *fakeSession) GetAuthURL() (string, error) {
+	return s.AuthURL, nil
+}
+
+func (s *fakeSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s *fakeSession) Authorize(p goth.Provider, params goth.Params) (string, error) {
+	s.AccessToken = "token:" + params.Get("code")
+	return s.AccessToken, nil
+}
+
+func (p *fakeProvider) Name() string                { return p.name }
+func (p *fakeProvider) SetName(name string)         { p.name = name }
+func (p *fakeProvider) Debug(bool)                  {}
+func (p *fakeProvider) RefreshTokenAvailable() bool { return false }
+
+func (p *fakeProvider) BeginAuth(state string) (goth.Session, error) {
+	return &fakeSession{AuthURL: "https://example.com/authorize?state=" + state}, nil
+}
+
+func (p *fakeProvider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &fakeSession{}
+	err := json.Unmarshal([]byte(data), s)
+	return s, err
+}
+
+func (p *fakeProvider) FetchUser(session goth.Session) (goth.User, error) {
+	return goth.User{Provider: p.name, UserID: "1", AccessToken: session.(*fakeSession).AccessToken}, nil
+}
+
+func (p *fakeProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, nil
+}
+
+func TestSession(t *testing.T) {
+	p := &fakeProvider{name: "fake"}
+
+	begin := func() (string, string) {
+		id, authURL, err := oauth.Begin(p)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, id)
+
+		u, err := url.Parse(authURL)
+
+		assert.Nil(t, err)
+
+		return id, u.Query().Get("state")
+	}
+
+	id, state := begin()
+
+	// state 不匹配
+	{
+		_, err := oauth.Complete(p, id, url.Values{"state": []string{"invalid"}, "code": []string{"code"}})
+
+		assert.Equal(t, exception.InvalidOAuthSession, err)
+	}
+
+	// 会话只能使用一次
+	{
+		_, err := oauth.Complete(p, id, url.Values{"state": []string{state}, "code": []string{"code"}})
+
+		assert.Equal(t, exception.InvalidOAuthSession, err)
+	}
+
+	id, state = begin()
+
+	user, err := oauth.Complete(p, id, url.Values{"state": []string{state}, "code": []string{"code"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "fake", user.Provider)
+	assert.Equal(t, "token:code", user.AccessToken)
+
+	// 登陆码只能使用一次
+	code, err := oauth.NewSignInCode("uid")
+
+	assert.Nil(t, err)
+
+	uid, err := oauth.UseSignInCode(code)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "uid", uid)
+
+	_, err = oauth.UseSignInCode(code)
+
+	assert.Equal(t, exception.InvalidOAuthSession, err)
+}
+
+func TestGet(t *testing.T) {
+	// 只读取后台加载好的配置, 不会访问数据库
+	_, err := oauth.Get("not-exist")
+
+	assert.Equal(t, exception.OAuthProviderNotExist, err)
+}
@@ -0,0 +1,165 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/markbates/goth"
+	"net/url"
+	"time"
+)
+
+// 跳转到服务提供商之后, 多久之内需要完成认证
+var SessionTTL = time.Minute * 10
+
+func sessionKey(provider string, id string) string {
+	return "session:" + provider + ":" + id
+}
+
+// 读取之后立即删除, 保证只能使用一次
+func take(key string) (string, error) {
+	var (
+		ctx = context.Background()
+		get *nativeRedis.StringCmd
+	)
+
+	_, err := redis.ClientOAuthCode.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return get.Val(), nil
+}
+
+func codeKey(code string) string {
+	return "code:" + code
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 开始认证, 返回会话 ID 和跳转到服务提供商的地址
+// 会话存放在 redis 中, 会话 ID 由调用者通过 cookie 交给浏览器, 回调时用来找回会话
+func Begin(p goth.Provider) (string, string, error) {
+	state, err := randomToken()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	session, err := p.BeginAuth(state)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := session.GetAuthURL()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	id, err := randomToken()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := redis.ClientOAuthCode.Set(context.Background(), sessionKey(p.Name(), id), session.Marshal(), SessionTTL).Err(); err != nil {
+		return "", "", err
+	}
+
+	return id, authURL, nil
+}
+
+// 服务提供商跳转回来之后完成认证, 获取用户信息. 会话只能使用一次
+func Complete(p goth.Provider, id string, query url.Values) (goth.User, error) {
+	var user goth.User
+
+	if id == "" {
+		return user, exception.InvalidOAuthSession
+	}
+
+	value, err := take(sessionKey(p.Name(), id))
+
+	if err != nil {
+		if err == redis.Nil {
+			err = exception.InvalidOAuthSession
+		}
+		return user, err
+	}
+
+	session, err := p.UnmarshalSession(value)
+
+	if err != nil {
+		return user, err
+	}
+
+	// 检查 state, 防止 CSRF. OAuth1 (twitter) 没有 state
+	authURL, err := session.GetAuthURL()
+
+	if err != nil {
+		return user, err
+	}
+
+	if u, err := url.Parse(authURL); err != nil {
+		return user, err
+	} else if state := u.Query().Get("state"); state != "" && state != query.Get("state") {
+		return user, exception.InvalidOAuthSession
+	}
+
+	if _, err := session.Authorize(p, query); err != nil {
+		return user, exception.ThirdParty.New(err.Error())
+	}
+
+	if user, err = p.FetchUser(session); err != nil {
+		return user, exception.ThirdParty.New(err.Error())
+	}
+
+	return user, nil
+}
+
+// 认证成功之后生成一次性的登陆码, 前端使用登陆码换取用户的 token
+func NewSignInCode(uid string) (string, error) {
+	code, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := redis.ClientOAuthCode.Set(context.Background(), codeKey(code), uid, time.Minute*5).Err(); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// 使用登陆码, 返回对应的用户 ID
+func UseSignInCode(code string) (string, error) {
+	uid, err := take(codeKey(code))
+
+	if err != nil {
+		if err == redis.Nil {
+			err = exception.InvalidOAuthSession
+		}
+		return "", err
+	}
+
+	return uid, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+	"net/url"
+)
+
+// 授权请求的参数 (RFC 6749 4.1.1, RFC 7636 4.3)
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectUri         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// 校验授权请求, 返回申请的 scope
+// 调用之前需要先确认 client_id 和 redirect_uri 有效, 这里的错误需要通过回调地址告诉应用
+func (r AuthorizeRequest) Validate(client *model.OAuthClient) ([]string, *Error) {
+	if r.ResponseType != "code" {
+		return nil, ErrUnsupportedResponseType("only response_type=code is supported")
+	}
+
+	scopes := ParseScope(r.Scope)
+
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope("scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !IsValidScope(scope) || !client.HasScope(scope) {
+			return nil, ErrInvalidScope("scope " + scope + " is not allowed")
+		}
+	}
+
+	if r.CodeChallenge == "" {
+		// 公开应用无法保管密钥, 只能依靠 PKCE 防止授权码被截获
+		if client.Public {
+			return nil, ErrInvalidRequest("code_challenge is required for public clients")
+		}
+	} else {
+		if r.CodeChallengeMethod != CodeChallengeMethodS256 {
+			return nil, ErrInvalidRequest("only code_challenge_method=S256 is supported")
+		}
+
+		if !IsValidCodeChallenge(r.CodeChallenge) {
+			return nil, ErrInvalidRequest("invalid code_challenge")
+		}
+	}
+
+	return scopes, nil
+}
+
+// 生成跳转回应用的地址, 保留回调地址原有的查询参数
+func RedirectUri(redirectUri string, params url.Values) (string, error) {
+	u, err := url.Parse(redirectUri)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+
+	for k, v := range params {
+		for _, s := range v {
+			if s != "" {
+				query.Add(k, s)
+			}
+		}
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// 授权失败时跳转回应用的地址
+func ErrorRedirectUri(redirectUri string, state string, e *Error) (string, error) {
+	return RedirectUri(redirectUri, url.Values{
+		"error":             []string{e.Code},
+		"error_description": []string{e.Description},
+		"state":             []string{state},
+	})
+}
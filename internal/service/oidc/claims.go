@@ -0,0 +1,50 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"github.com/axetroy/go-server/internal/model"
+)
+
+// 根据授权的 scope 返回用户的信息 (OpenID Connect Core 5.4), 用于 ID Token 和 userinfo 接口
+func UserClaims(user model.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": user.Id,
+	}
+
+	if HasScope(scopes, ScopeProfile) {
+		name := user.Username
+
+		if user.Nickname != nil && *user.Nickname != "" {
+			name = *user.Nickname
+			claims["nickname"] = *user.Nickname
+		}
+
+		claims["name"] = name
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+
+		if user.Avatar != "" {
+			claims["picture"] = user.Avatar
+		}
+
+		switch user.Gender {
+		case model.GenderMale:
+			claims["gender"] = "male"
+		case model.GenderFemale:
+			claims["gender"] = "female"
+		}
+	}
+
+	// 用户只能通过验证码绑定邮箱和手机号, 管理员创建帐号时填写的视为已经核实
+	if HasScope(scopes, ScopeEmail) && user.Email != nil && *user.Email != "" {
+		claims["email"] = *user.Email
+		claims["email_verified"] = true
+	}
+
+	if HasScope(scopes, ScopePhone) && user.Phone != nil && *user.Phone != "" {
+		claims["phone_number"] = *user.Phone
+		claims["phone_number_verified"] = true
+	}
+
+	return claims
+}
@@ -0,0 +1,74 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/jinzhu/gorm"
+)
+
+// 生成新的应用密钥, 返回明文和 hash. 明文只在创建/重置时返回一次
+func GenerateSecret() (secret string, hash string, err error) {
+	b := make([]byte, 32)
+
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	hash = HashSecret(secret)
+
+	return
+}
+
+// 应用密钥是随机生成的高强度字符串, 不需要使用密码那样的慢 hash, 每次换取 token 都需要校验
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// 获取可用的应用
+func GetClient(db *gorm.DB, clientId string) (*model.OAuthClient, error) {
+	if clientId == "" {
+		return nil, ErrInvalidClient("client not found")
+	}
+
+	client := model.OAuthClient{Id: clientId}
+
+	if err := db.Where(&client).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvalidClient("client not found")
+		}
+		return nil, err
+	}
+
+	if client.Status != model.OAuthClientStatusEnabled {
+		return nil, ErrInvalidClient("client is disabled")
+	}
+
+	return &client, nil
+}
+
+// 验证应用的身份, 公开应用没有密钥, 只需要 client_id
+func Authenticate(db *gorm.DB, clientId string, secret string) (*model.OAuthClient, error) {
+	client, err := GetClient(db, clientId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if secret == "" || subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(client.Secret)) != 1 {
+		return nil, ErrInvalidClient("client authentication failed")
+	}
+
+	return client, nil
+}
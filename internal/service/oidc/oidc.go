@@ -0,0 +1,95 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 作为身份提供方 (OpenID Connect), 让其他应用可以使用本平台的帐号登陆
+// 只支持授权码模式, 公开应用必须使用 PKCE. 授权码和刷新令牌存放在 redis 中, 服务端只存储 hash
+// access token 和 ID Token 使用轮换的签名密钥 (token.StateOidc) 签发
+package oidc
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeOpenId        = "openid"         // 必须, 签发 ID Token
+	ScopeProfile       = "profile"        // 昵称, 用户名, 头像, 性别
+	ScopeEmail         = "email"          // 邮箱
+	ScopePhone         = "phone"          // 手机号
+	ScopeOfflineAccess = "offline_access" // 签发刷新令牌
+)
+
+// 支持的 scope, 应用只能申请后台登记过的 scope
+var Scopes = []string{ScopeOpenId, ScopeProfile, ScopeEmail, ScopePhone, ScopeOfflineAccess}
+
+func IsValidScope(scope string) bool {
+	for _, v := range Scopes {
+		if v == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 解析以空格分隔的 scope, 去掉重复的
+func ParseScope(s string) []string {
+	var (
+		result = make([]string, 0)
+		exist  = map[string]bool{}
+	)
+
+	for _, v := range strings.Fields(s) {
+		if exist[v] {
+			continue
+		}
+
+		exist[v] = true
+		result = append(result, v)
+	}
+
+	return result
+}
+
+func HasScope(scopes []string, scope string) bool {
+	for _, v := range scopes {
+		if v == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OAuth2 协议规定的错误 (RFC 6749 5.2), 按照协议的格式返回给应用, 而不是通用的响应结构
+// 协议要求 error_description 只能是 ASCII 字符, 所以使用英文描述
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Description
+}
+
+func newError(code string, status int) func(description string) *Error {
+	return func(description string) *Error {
+		return &Error{Code: code, Description: description, Status: status}
+	}
+}
+
+var (
+	ErrInvalidRequest          = newError("invalid_request", http.StatusBadRequest)
+	ErrInvalidClient           = newError("invalid_client", http.StatusUnauthorized)
+	ErrInvalidGrant            = newError("invalid_grant", http.StatusBadRequest)
+	ErrUnauthorizedClient      = newError("unauthorized_client", http.StatusBadRequest)
+	ErrUnsupportedGrantType    = newError("unsupported_grant_type", http.StatusBadRequest)
+	ErrUnsupportedResponseType = newError("unsupported_response_type", http.StatusBadRequest)
+	ErrInvalidScope            = newError("invalid_scope", http.StatusBadRequest)
+	ErrAccessDenied            = newError("access_denied", http.StatusForbidden)
+	ErrInvalidToken            = newError("invalid_token", http.StatusUnauthorized)
+	ErrInsufficientScope       = newError("insufficient_scope", http.StatusForbidden)
+)
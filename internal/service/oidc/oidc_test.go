@@ -0,0 +1,261 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/oidc"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScope(t *testing.T) {
+	assert.Equal(t, []string{"openid", "profile"}, oidc.ParseScope(" openid  profile openid "))
+	assert.Equal(t, []string{}, oidc.ParseScope(""))
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+
+	// base64url 编码, 没有填充
+	assert.Len(t, oidc.CodeChallenge(verifier), 43)
+	assert.True(t, oidc.IsValidCodeChallenge(oidc.CodeChallenge(verifier)))
+
+	assert.True(t, oidc.VerifyCodeChallenge(verifier, oidc.CodeChallenge(verifier), oidc.CodeChallengeMethodS256))
+	assert.False(t, oidc.VerifyCodeChallenge(verifier, oidc.CodeChallenge(verifier), "plain"))
+	assert.False(t, oidc.VerifyCodeChallenge(strings.Repeat("b", 43), oidc.CodeChallenge(verifier), oidc.CodeChallengeMethodS256))
+	// 长度不足
+	assert.False(t, oidc.VerifyCodeChallenge("abc", oidc.CodeChallenge("abc"), oidc.CodeChallengeMethodS256))
+}
+
+func TestAuthorizeRequest_Validate(t *testing.T) {
+	client := &model.OAuthClient{
+		Public:       true,
+		RedirectUris: []string{"https://example.com/callback"},
+		Scopes:       []string{oidc.ScopeOpenId, oidc.ScopeProfile},
+	}
+
+	challenge := oidc.CodeChallenge(strings.Repeat("a", 43))
+
+	valid := oidc.AuthorizeRequest{
+		ResponseType:        "code",
+		Scope:               "openid profile",
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: oidc.CodeChallengeMethodS256,
+	}
+
+	scopes, e := valid.Validate(client)
+	assert.Nil(t, e)
+	assert.Equal(t, []string{"openid", "profile"}, scopes)
+
+	// 不支持的 response_type
+	r := valid
+	r.ResponseType = "token"
+	_, e = r.Validate(client)
+	assert.Equal(t, "unsupported_response_type", e.Code)
+
+	// 没有登记的 scope
+	r = valid
+	r.Scope = "openid email"
+	_, e = r.Validate(client)
+	assert.Equal(t, "invalid_scope", e.Code)
+
+	// 公开应用必须使用 PKCE
+	r = valid
+	r.CodeChallenge = ""
+	_, e = r.Validate(client)
+	assert.Equal(t, "invalid_request", e.Code)
+
+	// 只支持 S256
+	r = valid
+	r.CodeChallengeMethod = "plain"
+	_, e = r.Validate(client)
+	assert.Equal(t, "invalid_request", e.Code)
+
+	// 私密应用可以不使用 PKCE
+	client.Public = false
+	r = valid
+	r.CodeChallenge = ""
+	r.CodeChallengeMethod = ""
+	_, e = r.Validate(client)
+	assert.Nil(t, e)
+}
+
+func TestErrorRedirectUri(t *testing.T) {
+	uri, err := oidc.ErrorRedirectUri("https://example.com/callback?from=app", "xyz", oidc.ErrAccessDenied("user denied"))
+
+	assert.Nil(t, err)
+
+	u, _ := url.Parse(uri)
+
+	assert.Equal(t, "app", u.Query().Get("from"))
+	assert.Equal(t, "access_denied", u.Query().Get("error"))
+	assert.Equal(t, "user denied", u.Query().Get("error_description"))
+	assert.Equal(t, "xyz", u.Query().Get("state"))
+}
+
+func TestUserClaims(t *testing.T) {
+	nickname := "Nick"
+	email := "test@example.com"
+
+	user := model.User{Id: "1", Username: "test", Nickname: &nickname, Email: &email, Gender: model.GenderMale}
+
+	claims := oidc.UserClaims(user, []string{oidc.ScopeOpenId})
+	assert.Equal(t, map[string]interface{}{"sub": "1"}, claims)
+
+	claims = oidc.UserClaims(user, []string{oidc.ScopeOpenId, oidc.ScopeProfile, oidc.ScopeEmail, oidc.ScopePhone})
+	assert.Equal(t, "Nick", claims["name"])
+	assert.Equal(t, "test", claims["preferred_username"])
+	assert.Equal(t, "male", claims["gender"])
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	// 没有绑定手机号
+	assert.Nil(t, claims["phone_number"])
+}
+
+func TestCode(t *testing.T) {
+	verifier := strings.Repeat("c", 43)
+
+	c := oidc.Code{
+		Grant:               oidc.Grant{ClientId: "client", Uid: "user", Scopes: []string{oidc.ScopeOpenId}},
+		RedirectUri:         "https://example.com/callback",
+		CodeChallenge:       oidc.CodeChallenge(verifier),
+		CodeChallengeMethod: oidc.CodeChallengeMethodS256,
+	}
+
+	code, err := oidc.IssueCode(c)
+
+	assert.Nil(t, err)
+
+	got, err := oidc.UseCode(code)
+
+	assert.Nil(t, err)
+	assert.Equal(t, c, *got)
+
+	assert.Nil(t, got.Verify("client", "https://example.com/callback", verifier))
+	assert.Equal(t, "invalid_grant", got.Verify("other", "https://example.com/callback", verifier).Code)
+	assert.Equal(t, "invalid_grant", got.Verify("client", "https://example.com/other", verifier).Code)
+	assert.Equal(t, "invalid_grant", got.Verify("client", "https://example.com/callback", strings.Repeat("d", 43)).Code)
+
+	// 只能使用一次
+	_, err = oidc.UseCode(code)
+
+	assert.Equal(t, "invalid_grant", err.(*oidc.Error).Code)
+}
+
+func TestIssue(t *testing.T) {
+	defer token.SetKeys(nil)
+
+	clientId := util.GenerateId()
+	uid := util.GenerateId()
+
+	g := oidc.Grant{ClientId: clientId, Uid: uid, Scopes: []string{oidc.ScopeOpenId, oidc.ScopeProfile, oidc.ScopeOfflineAccess}, AuthTime: time.Now().Unix()}
+
+	tokens, err := oidc.Issue(g, model.User{Id: uid, Username: "test"}, "nonce")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, "openid profile offline_access", tokens.Scope)
+
+	// ID Token
+	{
+		claims := jwt.MapClaims{}
+
+		assert.Nil(t, token.Verify(tokens.IdToken, token.StateOidc, &claims))
+		assert.Equal(t, config.Oidc.Issuer, claims["iss"])
+		assert.Equal(t, uid, claims["sub"])
+		assert.Equal(t, clientId, claims["aud"])
+		assert.Equal(t, "nonce", claims["nonce"])
+		assert.Equal(t, "test", claims["preferred_username"])
+		assert.NotEmpty(t, claims["at_hash"])
+	}
+
+	// access token
+	{
+		claims, err := oidc.ParseAccessToken(tokens.AccessToken)
+
+		assert.Nil(t, err)
+		assert.Equal(t, uid, claims.Subject)
+		assert.Equal(t, clientId, claims.ClientId)
+
+		info, err := oidc.Introspect(tokens.AccessToken)
+
+		assert.Nil(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, tokens.Scope, info.Scope)
+	}
+
+	// 刷新令牌只能由签发的应用使用
+	{
+		_, err := oidc.Refresh(tokens.RefreshToken, "other", "")
+
+		assert.Equal(t, "invalid_grant", err.(*oidc.Error).Code)
+	}
+
+	// 不能申请更多的 scope
+	{
+		_, err := oidc.Refresh(tokens.RefreshToken, clientId, "openid email")
+
+		assert.Equal(t, "invalid_scope", err.(*oidc.Error).Code)
+	}
+
+	// 上面失败的请求已经使用了刷新令牌, 重新签发一个
+	tokens, err = oidc.Issue(g, model.User{Id: uid}, "")
+
+	assert.Nil(t, err)
+
+	refreshed, err := oidc.Refresh(tokens.RefreshToken, clientId, "openid")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, refreshed.Scopes)
+
+	// 刷新令牌只能使用一次
+	{
+		_, err := oidc.Refresh(tokens.RefreshToken, clientId, "")
+
+		assert.Equal(t, "invalid_grant", err.(*oidc.Error).Code)
+	}
+
+	// 撤销 access token
+	{
+		// 其他应用不能撤销
+		assert.Nil(t, oidc.Revoke(tokens.AccessToken, "other", ""))
+
+		_, err := oidc.ParseAccessToken(tokens.AccessToken)
+		assert.Nil(t, err)
+
+		assert.Nil(t, oidc.Revoke(tokens.AccessToken, clientId, ""))
+
+		_, err = oidc.ParseAccessToken(tokens.AccessToken)
+		assert.Equal(t, "invalid_token", err.(*oidc.Error).Code)
+
+		info, err := oidc.Introspect(tokens.AccessToken)
+
+		assert.Nil(t, err)
+		assert.False(t, info.Active)
+	}
+
+	// 撤销授权之后, 刷新令牌全部失效
+	{
+		tokens, err := oidc.Issue(g, model.User{Id: uid}, "")
+
+		assert.Nil(t, err)
+
+		assert.Nil(t, oidc.RevokeGrant(uid, clientId))
+
+		_, err = oidc.Refresh(tokens.RefreshToken, clientId, "")
+
+		assert.Equal(t, "invalid_grant", err.(*oidc.Error).Code)
+
+		// 和撤销同一秒签发的 access token 也失效
+		_, err = oidc.ParseAccessToken(tokens.AccessToken)
+		assert.Equal(t, "invalid_token", err.(*oidc.Error).Code)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// 只支持 S256, plain 方式在授权请求被截获时起不到保护作用
+const CodeChallengeMethodS256 = "S256"
+
+// code_verifier 和 code_challenge 的格式 (RFC 7636 4.1)
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+func IsValidCodeChallenge(challenge string) bool {
+	return codeVerifierPattern.MatchString(challenge)
+}
+
+// 根据 code_verifier 计算 code_challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// 换取 token 时验证 code_verifier 是否和授权时的 code_challenge 对应
+func VerifyCodeChallenge(verifier string, challenge string, method string) bool {
+	if method != CodeChallengeMethodS256 || !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(CodeChallenge(verifier)), []byte(challenge)) == 1
+}
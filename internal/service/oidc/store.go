@@ -0,0 +1,293 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// 授权码, 刷新令牌和撤销记录的存储
+// code:<hash>                   授权码, 只能使用一次
+// refresh:<hash>                刷新令牌, 每次使用之后换成新的
+// grants:<uid>:<client_id>      用户给某个应用签发的所有刷新令牌, 用于撤销授权
+// deny:<jti>                    已撤销的 access token, 保留到 token 过期为止
+// revoke-before:<uid>:<client>  在这个时间之前签发的 access token 全部失效
+
+// 用户授权之后, 签发 token 需要的信息
+type Grant struct {
+	ClientId string   `json:"client_id"` // 应用ID
+	Uid      string   `json:"uid"`       // 用户ID
+	Scopes   []string `json:"scopes"`    // 已授权的 scope
+	AuthTime int64    `json:"auth_time"` // 用户登陆的时间
+}
+
+// 授权码
+type Code struct {
+	Grant
+	RedirectUri         string `json:"redirect_uri"`          // 授权时的回调地址, 换取 token 时必须相同
+	Nonce               string `json:"nonce"`                 // 写入 ID Token, 防止重放
+	CodeChallenge       string `json:"code_challenge"`        // PKCE
+	CodeChallengeMethod string `json:"code_challenge_method"` // PKCE
+}
+
+func codeKey(hash string) string {
+	return "code:" + hash
+}
+
+func refreshKey(hash string) string {
+	return "refresh:" + hash
+}
+
+func grantsKey(uid string, clientId string) string {
+	return "grants:" + uid + ":" + clientId
+}
+
+func denyKey(jti string) string {
+	return "deny:" + jti
+}
+
+func revokeBeforeKey(uid string, clientId string) string {
+	return "revoke-before:" + uid + ":" + clientId
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 保存并返回一个随机生成的凭证, 服务端只存储 hash
+func put(key func(string) string, value interface{}, ttl time.Duration) (string, string, error) {
+	s, err := randomString()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err := json.Marshal(value)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	h := hash(s)
+
+	if err := redis.ClientOidc.Set(context.Background(), key(h), string(b), ttl).Err(); err != nil {
+		return "", "", err
+	}
+
+	return s, h, nil
+}
+
+// 读取并删除凭证, 并发的请求只有一个可以成功
+func take(key string, value interface{}) (bool, error) {
+	ctx := context.Background()
+
+	var get *nativeRedis.StringCmd
+
+	_, err := redis.ClientOidc.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := json.Unmarshal([]byte(get.Val()), value); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// 签发授权码
+func IssueCode(c Code) (string, error) {
+	code, _, err := put(codeKey, c, config.Oidc.CodeTTL)
+
+	return code, err
+}
+
+// 使用授权码, 每个授权码只能使用一次
+func UseCode(code string) (*Code, error) {
+	c := Code{}
+
+	if ok, err := take(codeKey(hash(code)), &c); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrInvalidGrant("invalid or expired code")
+	}
+
+	return &c, nil
+}
+
+// 签发刷新令牌, 并且记录到用户对这个应用的授权中
+func issueRefresh(g Grant) (string, error) {
+	ctx := context.Background()
+
+	tokenString, h, err := put(refreshKey, g, config.Oidc.RefreshTTL)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = redis.ClientOidc.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.SAdd(ctx, grantsKey(g.Uid, g.ClientId), h)
+		pipe.Expire(ctx, grantsKey(g.Uid, g.ClientId), config.Oidc.RefreshTTL)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// 使用刷新令牌, 使用之后旧的刷新令牌失效
+func useRefresh(tokenString string, clientId string) (*Grant, error) {
+	ctx := context.Background()
+	h := hash(tokenString)
+
+	value, err := redis.ClientOidc.Get(ctx, refreshKey(h)).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrInvalidGrant("invalid or expired refresh token")
+		}
+		return nil, err
+	}
+
+	g := Grant{}
+
+	if err := json.Unmarshal([]byte(value), &g); err != nil {
+		return nil, err
+	}
+
+	// 其他应用的刷新令牌, 不能使用也不能让它失效
+	if g.ClientId != clientId {
+		return nil, ErrInvalidGrant("refresh token was issued to another client")
+	}
+
+	if ok, err := take(refreshKey(h), &g); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrInvalidGrant("invalid or expired refresh token")
+	}
+
+	_ = redis.ClientOidc.SRem(ctx, grantsKey(g.Uid, g.ClientId), h).Err()
+
+	return &g, nil
+}
+
+// 撤销刷新令牌, 只能撤销签发给自己的刷新令牌. 返回是否是有效的刷新令牌
+func revokeRefresh(tokenString string, clientId string) (bool, error) {
+	ctx := context.Background()
+	h := hash(tokenString)
+
+	value, err := redis.ClientOidc.Get(ctx, refreshKey(h)).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+
+	g := Grant{}
+
+	if err := json.Unmarshal([]byte(value), &g); err != nil {
+		return false, err
+	}
+
+	if g.ClientId != clientId {
+		return true, nil
+	}
+
+	_, err = redis.ClientOidc.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.Del(ctx, refreshKey(h))
+		pipe.SRem(ctx, grantsKey(g.Uid, g.ClientId), h)
+		return nil
+	})
+
+	return true, err
+}
+
+// 撤销用户给某个应用的授权, 所有的刷新令牌和之前签发的 access token 全部失效
+func RevokeGrant(uid string, clientId string) error {
+	ctx := context.Background()
+
+	hashes, err := redis.ClientOidc.SMembers(ctx, grantsKey(uid, clientId)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = redis.ClientOidc.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		for _, h := range hashes {
+			pipe.Del(ctx, refreshKey(h))
+		}
+		pipe.Del(ctx, grantsKey(uid, clientId))
+		// access token 没有存储, 记录撤销的时间, 保留到最后一个 access token 过期为止
+		pipe.Set(ctx, revokeBeforeKey(uid, clientId), strconv.FormatInt(time.Now().Unix(), 10), config.Oidc.AccessTTL)
+		return nil
+	})
+
+	return err
+}
+
+// 撤销 access token
+func revokeAccess(claims *AccessClaims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	return redis.ClientOidc.Set(context.Background(), denyKey(claims.Id), 1, ttl).Err()
+}
+
+// 检查 access token 是否已经被撤销
+func checkAccess(claims *AccessClaims) error {
+	values, err := redis.ClientOidc.MGet(context.Background(), denyKey(claims.Id), revokeBeforeKey(claims.Subject, claims.ClientId)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if values[0] != nil {
+		return ErrInvalidToken("token has been revoked")
+	}
+
+	if before, ok := values[1].(string); ok {
+		// 签发时间只精确到秒, 和撤销同一秒签发的 token 也要失效
+		if n, _ := strconv.ParseInt(before, 10, 64); claims.IssuedAt <= n {
+			return ErrInvalidToken("token has been revoked")
+		}
+	}
+
+	return nil
+}
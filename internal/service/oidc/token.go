@@ -0,0 +1,261 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/dgrijalva/jwt-go"
+	"strings"
+	"time"
+)
+
+// access token 的内容 (RFC 9068)
+type AccessClaims struct {
+	Scope    string `json:"scope"`
+	ClientId string `json:"client_id"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	jwt.StandardClaims
+}
+
+func (c AccessClaims) Scopes() []string {
+	return ParseScope(c.Scope)
+}
+
+// token 接口返回的内容 (RFC 6749 5.1)
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// 签发 token, 申请了 openid 才会签发 ID Token, 申请了 offline_access 才会签发刷新令牌
+func Issue(g Grant, user model.User, nonce string) (*Tokens, error) {
+	now := time.Now()
+	scope := strings.Join(g.Scopes, " ")
+
+	accessToken, err := token.Sign(token.StateOidc, AccessClaims{
+		Scope:    scope,
+		ClientId: g.ClientId,
+		AuthTime: g.AuthTime,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  g.ClientId,
+			Id:        util.GenerateId(),
+			ExpiresAt: now.Add(config.Oidc.AccessTTL).Unix(),
+			Issuer:    config.Oidc.Issuer,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			Subject:   g.Uid,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := Tokens{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(config.Oidc.AccessTTL / time.Second),
+		Scope:       scope,
+	}
+
+	if HasScope(g.Scopes, ScopeOpenId) {
+		claims := jwt.MapClaims{}
+
+		for k, v := range UserClaims(user, g.Scopes) {
+			claims[k] = v
+		}
+
+		claims["iss"] = config.Oidc.Issuer
+		claims["sub"] = g.Uid
+		claims["aud"] = g.ClientId
+		claims["exp"] = now.Add(config.Oidc.AccessTTL).Unix()
+		claims["iat"] = now.Unix()
+		claims["at_hash"] = tokenHash(accessToken)
+
+		if g.AuthTime > 0 {
+			claims["auth_time"] = g.AuthTime
+		}
+
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+
+		if result.IdToken, err = token.Sign(token.StateOidc, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	if HasScope(g.Scopes, ScopeOfflineAccess) {
+		if result.RefreshToken, err = issueRefresh(g); err != nil {
+			return nil, err
+		}
+	}
+
+	return &result, nil
+}
+
+// at_hash, access token 的 SHA-256 的左半部分 (OpenID Connect Core 3.1.3.6)
+func tokenHash(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// 使用授权码换取 token 之前的校验
+func (c Code) Verify(clientId string, redirectUri string, codeVerifier string) *Error {
+	if c.ClientId != clientId {
+		return ErrInvalidGrant("code was issued to another client")
+	}
+
+	if c.RedirectUri != redirectUri {
+		return ErrInvalidGrant("redirect_uri mismatch")
+	}
+
+	if c.CodeChallenge != "" {
+		if !VerifyCodeChallenge(codeVerifier, c.CodeChallenge, c.CodeChallengeMethod) {
+			return ErrInvalidGrant("invalid code_verifier")
+		}
+	} else if codeVerifier != "" {
+		return ErrInvalidGrant("code_verifier was not expected")
+	}
+
+	return nil
+}
+
+// 使用刷新令牌换取新的 token, 可以申请更少的 scope
+func Refresh(refreshToken string, clientId string, scope string) (*Grant, error) {
+	g, err := useRefresh(refreshToken, clientId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if scope != "" {
+		scopes := ParseScope(scope)
+
+		for _, v := range scopes {
+			if !HasScope(g.Scopes, v) {
+				return nil, ErrInvalidScope("scope " + v + " was not granted")
+			}
+		}
+
+		// 刷新令牌是一次性的, 缩小范围之后的新刷新令牌依然需要 offline_access
+		if !HasScope(scopes, ScopeOfflineAccess) {
+			scopes = append(scopes, ScopeOfflineAccess)
+		}
+
+		g.Scopes = scopes
+	}
+
+	return g, nil
+}
+
+// 解析并验证 access token
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := AccessClaims{}
+
+	if err := token.Verify(tokenString, token.StateOidc, &claims); err != nil {
+		if err == exception.TokenExpired {
+			return nil, ErrInvalidToken("token is expired")
+		}
+		return nil, ErrInvalidToken("invalid token")
+	}
+
+	if claims.Issuer != config.Oidc.Issuer || claims.Subject == "" || claims.ClientId == "" {
+		return nil, ErrInvalidToken("invalid token")
+	}
+
+	if err := checkAccess(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+// 撤销 token (RFC 7009), 应用只能撤销签发给自己的 token, 无效的 token 不报错
+func Revoke(tokenString string, clientId string, hint string) error {
+	revokeAccessToken := func() (bool, error) {
+		claims, err := ParseAccessToken(tokenString)
+
+		if err != nil {
+			if _, ok := err.(*Error); ok {
+				return false, nil
+			}
+			return false, err
+		}
+
+		if claims.ClientId != clientId {
+			return true, nil
+		}
+
+		return true, revokeAccess(claims)
+	}
+
+	revokeRefreshToken := func() (bool, error) {
+		return revokeRefresh(tokenString, clientId)
+	}
+
+	order := []func() (bool, error){revokeAccessToken, revokeRefreshToken}
+
+	if hint == "refresh_token" {
+		order = []func() (bool, error){revokeRefreshToken, revokeAccessToken}
+	}
+
+	for _, fn := range order {
+		if found, err := fn(); err != nil || found {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// token 的状态 (RFC 7662)
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// 查询 access token 的状态, 刷新令牌只有签发它的应用才能使用, 不提供查询
+func Introspect(tokenString string) (*Introspection, error) {
+	claims, err := ParseAccessToken(tokenString)
+
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return &Introspection{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	return &Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Nbf:       claims.NotBefore,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.Id,
+	}, nil
+}
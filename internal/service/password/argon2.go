@@ -0,0 +1,122 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+type argon2idParams struct {
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+type Argon2id struct {
+	params argon2idParams
+}
+
+// memory 单位为 KiB, keyLength 和 saltLength 单位为 byte
+func NewArgon2id(memory int, time int, threads int, keyLength int, saltLength int) *Argon2id {
+	return &Argon2id{
+		params: argon2idParams{
+			Memory:     uint32(memory),
+			Time:       uint32(time),
+			Threads:    uint8(threads),
+			KeyLength:  uint32(keyLength),
+			SaltLength: uint32(saltLength),
+		},
+	}
+}
+
+func (a *Argon2id) Hash(text string) (string, error) {
+	salt := make([]byte, a.params.SaltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(text), salt, a.params.Time, a.params.Memory, a.params.Threads, a.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.params.Memory,
+		a.params.Time,
+		a.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(text string, hashed string) bool {
+	return argon2idVerify(text, hashed)
+}
+
+func (a *Argon2id) NeedRehash(hashed string) bool {
+	params, _, key, err := argon2idDecode(hashed)
+
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.params.Memory ||
+		params.Time != a.params.Time ||
+		params.Threads != a.params.Threads ||
+		uint32(len(key)) != a.params.KeyLength
+}
+
+// 解析 argon2id 的 hash 字符串
+func argon2idDecode(hashed string) (params argon2idParams, salt []byte, key []byte, err error) {
+	var version int
+
+	parts := strings.Split(hashed, "$")
+
+	if len(parts) != 6 || parts[1] != string(AlgorithmArgon2id) {
+		err = fmt.Errorf("invalid argon2id hash")
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+
+	if version != argon2.Version {
+		err = fmt.Errorf("incompatible argon2 version %d", version)
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return
+}
+
+func argon2idVerify(text string, hashed string) bool {
+	params, salt, key, err := argon2idDecode(hashed)
+
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(text), salt, params.Time, params.Memory, params.Threads, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
@@ -0,0 +1,48 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package password
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Bcrypt struct {
+	cost int
+}
+
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{
+		cost: cost,
+	}
+}
+
+func (b *Bcrypt) Hash(text string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(text), b.cost)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func (b *Bcrypt) Verify(text string, hashed string) bool {
+	return bcryptVerify(text, hashed)
+}
+
+func (b *Bcrypt) NeedRehash(hashed string) bool {
+	if Identify(hashed) != AlgorithmBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hashed))
+
+	if err != nil {
+		return true
+	}
+
+	return cost != b.cost
+}
+
+func bcryptVerify(text string, hashed string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(text)) == nil
+}
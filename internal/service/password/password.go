@@ -0,0 +1,99 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 密码 hash 模块
+// 生成的 hash 是自描述的, 包含了算法和参数, 例如:
+// argon2id: $argon2id$v=19$m=65536,t=1,p=2$<salt>$<hash>
+// bcrypt:   $2a$10$<salt+hash>
+// 旧版本的 MD5 hash 依旧可以校验, 但是应该在下次登录成功时重新 hash
+package password
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/util"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type algorithm string
+
+const (
+	AlgorithmArgon2id algorithm = "argon2id" // argon2id, 推荐
+	AlgorithmBcrypt   algorithm = "bcrypt"   // bcrypt
+	AlgorithmLegacy   algorithm = "md5"      // 旧版本的 MD5 hash, 仅用于校验
+)
+
+var (
+	hasher     Hasher // 当前用于生成 hash 的算法
+	legacyHash = regexp.MustCompile("^[0-9a-f]{32}$")
+	dummyOnce  sync.Once
+	dummyHash  string
+)
+
+type Hasher interface {
+	Hash(text string) (string, error)       // 生成 hash
+	Verify(text string, hashed string) bool // 校验密码和 hash 是否匹配
+	NeedRehash(hashed string) bool          // 当前的 hash 参数是否已过时
+}
+
+func init() {
+	switch algorithm(config.Password.Algorithm) {
+	case AlgorithmArgon2id:
+		c := config.Password.Argon2
+		hasher = NewArgon2id(c.Memory, c.Time, c.Threads, c.KeyLength, c.SaltLength)
+	case AlgorithmBcrypt:
+		hasher = NewBcrypt(config.Password.Bcrypt.Cost)
+	default:
+		log.Fatal(fmt.Sprintf(`Invalid password algorithm "%s"`, config.Password.Algorithm))
+	}
+}
+
+// 识别 hash 所使用的算法
+func Identify(hashed string) algorithm {
+	switch {
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"), strings.HasPrefix(hashed, "$2y$"):
+		return AlgorithmBcrypt
+	case legacyHash.MatchString(hashed):
+		return AlgorithmLegacy
+	default:
+		return ""
+	}
+}
+
+// 使用当前配置的算法生成密码 hash
+// 系统随机数不可用或者算法参数不合法 (例如 bcrypt 的 cost 超出范围) 时返回错误
+func Generate(text string) (string, error) {
+	return hasher.Hash(text)
+}
+
+// 帐号不存在时用于校验的 hash, 使响应时间和帐号存在时一致, 避免通过响应时间判断帐号是否存在
+func Dummy() string {
+	dummyOnce.Do(func() {
+		dummyHash, _ = hasher.Hash(util.RandomString(16))
+	})
+
+	return dummyHash
+}
+
+// 校验密码是否正确, 支持所有历史上使用过的算法
+func Verify(text string, hashed string) bool {
+	switch Identify(hashed) {
+	case AlgorithmArgon2id:
+		return argon2idVerify(text, hashed)
+	case AlgorithmBcrypt:
+		return bcryptVerify(text, hashed)
+	case AlgorithmLegacy:
+		return subtle.ConstantTimeCompare([]byte(util.GeneratePassword(text)), []byte(hashed)) == 1
+	default:
+		return false
+	}
+}
+
+// 判断 hash 是否需要使用当前的算法和参数重新生成
+func NeedRehash(hashed string) bool {
+	return hasher.NeedRehash(hashed)
+}
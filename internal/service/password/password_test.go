@@ -0,0 +1,93 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package password_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/password"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	hashed, err := password.Generate("123456")
+
+	assert.Nil(t, err)
+
+	assert.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=65536,t=1,p=2$"))
+	assert.Equal(t, password.AlgorithmArgon2id, password.Identify(hashed))
+
+	// 每次生成的 hash 都使用不同的盐
+	other, err := password.Generate("123456")
+
+	assert.Nil(t, err)
+	assert.NotEqual(t, hashed, other)
+
+	assert.True(t, password.Verify("123456", hashed))
+	assert.False(t, password.Verify("654321", hashed))
+	assert.False(t, password.NeedRehash(hashed))
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	hashed := util.GeneratePassword("123456")
+
+	assert.Equal(t, password.AlgorithmLegacy, password.Identify(hashed))
+	assert.True(t, password.Verify("123456", hashed))
+	assert.False(t, password.Verify("654321", hashed))
+	assert.True(t, password.NeedRehash(hashed))
+}
+
+func TestDummy(t *testing.T) {
+	hashed := password.Dummy()
+
+	assert.Equal(t, password.AlgorithmArgon2id, password.Identify(hashed))
+	assert.Equal(t, hashed, password.Dummy())
+	assert.False(t, password.Verify("123456", hashed))
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	assert.False(t, password.Verify("123456", ""))
+	assert.False(t, password.Verify("123456", "123456"))
+	assert.False(t, password.Verify("123456", "$argon2id$v=19$invalid"))
+	assert.True(t, password.NeedRehash("$argon2id$v=19$invalid"))
+}
+
+func TestArgon2id(t *testing.T) {
+	h := password.NewArgon2id(1024, 1, 1, 16, 8)
+
+	hashed, err := h.Hash("123456")
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, h.Verify("123456", hashed))
+	assert.True(t, password.Verify("123456", hashed))
+	assert.False(t, h.NeedRehash(hashed))
+
+	// 参数变化之后需要重新 hash
+	assert.True(t, password.NewArgon2id(1024, 2, 1, 16, 8).NeedRehash(hashed))
+	assert.True(t, password.NeedRehash(hashed))
+}
+
+func TestBcrypt(t *testing.T) {
+	h := password.NewBcrypt(4)
+
+	hashed, err := h.Hash("123456")
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hashed, "$2a$04$"))
+	assert.Equal(t, password.AlgorithmBcrypt, password.Identify(hashed))
+	assert.True(t, h.Verify("123456", hashed))
+	assert.True(t, password.Verify("123456", hashed))
+	assert.False(t, password.Verify("654321", hashed))
+	assert.False(t, h.NeedRehash(hashed))
+
+	// cost 变化或者算法变化之后需要重新 hash
+	assert.True(t, password.NewBcrypt(5).NeedRehash(hashed))
+	assert.True(t, password.NeedRehash(hashed))
+	assert.True(t, h.NeedRehash(util.GeneratePassword("123456")))
+
+	// 不合法的 cost 返回错误, 而不是 panic
+	_, err = password.NewBcrypt(100).Hash("123456")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,240 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 钱包对账
+// 逐个钱包检查财务流水是否首尾相接, 并且最后一条流水的余额与钱包一致
+// 发现的差异写入 reconciliation_item 表, 由管理员人工处理
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/currency"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+const batchSize = 500 // 每次读取的钱包数量
+
+// 检查单个钱包, logs 需要按照产生的顺序排列, 钱包不存在时 wallet 为 nil
+func Check(currency string, uid string, wallet *model.Wallet, logs []model.FinanceLog) []model.ReconciliationItem {
+	var (
+		items   = make([]model.ReconciliationItem, 0)
+		balance = decimal.Zero // 根据流水推算的可用余额, 钱包创建时余额为 0
+		frozen  = decimal.Zero // 根据流水推算的冻结余额
+	)
+
+	for _, log := range logs {
+		logId := log.Id
+
+		// 与上一条流水不相接
+		if !log.BeforeBalance.Equal(balance) || !log.BeforeFrozen.Equal(frozen) {
+			items = append(items, model.ReconciliationItem{
+				Currency:        currency,
+				Uid:             uid,
+				Kind:            model.ReconciliationKindChainGap,
+				LogId:           &logId,
+				ExpectedBalance: balance,
+				ActualBalance:   log.BeforeBalance,
+				ExpectedFrozen:  frozen,
+				ActualFrozen:    log.BeforeFrozen,
+			})
+		}
+
+		expectedBalance := log.BeforeBalance.Add(log.BalanceMutation)
+		expectedFrozen := log.BeforeFrozen.Add(log.FrozenMutation)
+
+		// 流水本身不平
+		if !log.AfterBalance.Equal(expectedBalance) || !log.AfterFrozen.Equal(expectedFrozen) {
+			items = append(items, model.ReconciliationItem{
+				Currency:        currency,
+				Uid:             uid,
+				Kind:            model.ReconciliationKindInvalidLog,
+				LogId:           &logId,
+				ExpectedBalance: expectedBalance,
+				ActualBalance:   log.AfterBalance,
+				ExpectedFrozen:  expectedFrozen,
+				ActualFrozen:    log.AfterFrozen,
+			})
+		}
+
+		// 以流水记录的余额继续往下检查, 同一个问题只报告一次
+		balance, frozen = log.AfterBalance, log.AfterFrozen
+	}
+
+	if wallet == nil {
+		items = append(items, model.ReconciliationItem{
+			Currency:        currency,
+			Uid:             uid,
+			Kind:            model.ReconciliationKindWalletMissing,
+			ExpectedBalance: balance,
+			ActualBalance:   decimal.Zero,
+			ExpectedFrozen:  frozen,
+			ActualFrozen:    decimal.Zero,
+		})
+	} else if !wallet.Balance.Equal(balance) || !wallet.Frozen.Equal(frozen) {
+		items = append(items, model.ReconciliationItem{
+			Currency:        currency,
+			Uid:             uid,
+			Kind:            model.ReconciliationKindBalanceMismatch,
+			ExpectedBalance: balance,
+			ActualBalance:   wallet.Balance,
+			ExpectedFrozen:  frozen,
+			ActualFrozen:    wallet.Frozen,
+		})
+	}
+
+	return items
+}
+
+// 对所有币种的钱包进行对账, 并且保存对账结果
+// 返回的 error 只表示对账过程出错, 发现差异不会返回 error
+func Run(db *gorm.DB) (*model.Reconciliation, error) {
+	r := model.Reconciliation{
+		Status: model.ReconciliationStatusRunning,
+	}
+
+	if err := db.Create(&r).Error; err != nil {
+		return nil, err
+	}
+
+	items, err := scan(db, &r)
+
+	now := time.Now()
+
+	r.FinishedAt = &now
+	r.Discrepancies = int64(len(items))
+
+	if err != nil {
+		msg := err.Error()
+		r.Status = model.ReconciliationStatusFailed
+		r.Error = &msg
+	} else if len(items) > 0 {
+		r.Status = model.ReconciliationStatusMismatch
+	} else {
+		r.Status = model.ReconciliationStatusBalanced
+	}
+
+	tx := db.Begin()
+
+	for _, item := range items {
+		item.ReconciliationId = r.Id
+
+		if err := tx.Create(&item).Error; err != nil {
+			_ = tx.Rollback().Error
+			return nil, err
+		}
+	}
+
+	if err := tx.Save(&r).Error; err != nil {
+		_ = tx.Rollback().Error
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// 在同一个快照中读取所有的钱包和流水, 避免对账过程中产生的新交易造成误报
+func scan(db *gorm.DB, r *model.Reconciliation) ([]model.ReconciliationItem, error) {
+	tx := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	defer tx.Rollback()
+
+	codes, err := currency.Codes(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]model.ReconciliationItem, 0)
+
+	for _, code := range codes {
+		result, err := scanCurrency(tx, code, r)
+
+		if err != nil {
+			return items, err
+		}
+
+		items = append(items, result...)
+	}
+
+	return items, nil
+}
+
+func scanCurrency(tx *gorm.DB, code string, r *model.Reconciliation) ([]model.ReconciliationItem, error) {
+	var (
+		walletTableName = model.WalletTableName(code)
+		logTableName    = model.FinanceLogTableName(code)
+		items           = make([]model.ReconciliationItem, 0)
+		lastId          = ""
+	)
+
+	// 按照 id 分批读取钱包
+	for {
+		wallets := make([]model.Wallet, 0)
+
+		if err := tx.Table(walletTableName).Where("id > ?", lastId).Order("id").Limit(batchSize).Find(&wallets).Error; err != nil {
+			return nil, err
+		}
+
+		if len(wallets) == 0 {
+			break
+		}
+
+		ids := make([]string, 0, len(wallets))
+
+		for _, w := range wallets {
+			ids = append(ids, w.Id)
+		}
+
+		logs := make([]model.FinanceLog, 0)
+
+		if err := tx.Table(logTableName).Where("uid IN (?)", ids).Order("uid").Order("created_at").Order("id").Find(&logs).Error; err != nil {
+			return nil, err
+		}
+
+		logMap := map[string][]model.FinanceLog{}
+
+		for _, log := range logs {
+			logMap[log.Uid] = append(logMap[log.Uid], log)
+		}
+
+		for i := range wallets {
+			items = append(items, Check(code, wallets[i].Id, &wallets[i], logMap[wallets[i].Id])...)
+		}
+
+		r.Wallets += int64(len(wallets))
+		r.Logs += int64(len(logs))
+
+		lastId = wallets[len(wallets)-1].Id
+	}
+
+	// 有流水但是没有钱包的用户
+	uids := make([]string, 0)
+
+	if err := tx.Table(logTableName).Where("uid NOT IN (?)", tx.Table(walletTableName).Select("id").SubQuery()).Pluck("DISTINCT uid", &uids).Error; err != nil {
+		return nil, err
+	}
+
+	for _, uid := range uids {
+		logs := make([]model.FinanceLog, 0)
+
+		if err := tx.Table(logTableName).Where("uid = ?", uid).Order("created_at").Order("id").Find(&logs).Error; err != nil {
+			return nil, err
+		}
+
+		items = append(items, Check(code, uid, nil, logs)...)
+
+		r.Logs += int64(len(logs))
+	}
+
+	return items, nil
+}
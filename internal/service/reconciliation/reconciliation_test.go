@@ -0,0 +1,111 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package reconciliation_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/decimal"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/reconciliation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newLog(id string, before string, mutation string, after string) model.FinanceLog {
+	return model.FinanceLog{
+		Id:              id,
+		BeforeBalance:   decimal.RequireFromString(before),
+		BalanceMutation: decimal.RequireFromString(mutation),
+		AfterBalance:    decimal.RequireFromString(after),
+		BeforeFrozen:    decimal.Zero,
+		FrozenMutation:  decimal.Zero,
+		AfterFrozen:     decimal.Zero,
+	}
+}
+
+func newWallet(balance string) *model.Wallet {
+	return &model.Wallet{
+		Id:      "1",
+		Balance: decimal.RequireFromString(balance),
+		Frozen:  decimal.Zero,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// 账目一致
+	{
+		logs := []model.FinanceLog{
+			newLog("a", "0", "100", "100"),
+			newLog("b", "100", "-30", "70"),
+		}
+
+		assert.Len(t, reconciliation.Check("CNY", "1", newWallet("70"), logs), 0)
+	}
+
+	// 没有流水的钱包, 余额应该为 0
+	{
+		assert.Len(t, reconciliation.Check("CNY", "1", newWallet("0"), nil), 0)
+
+		items := reconciliation.Check("CNY", "1", newWallet("10"), nil)
+
+		assert.Len(t, items, 1)
+		assert.Equal(t, model.ReconciliationKindBalanceMismatch, items[0].Kind)
+		assert.Equal(t, "0.00000000", items[0].ExpectedBalance.String())
+		assert.Equal(t, "10.00000000", items[0].ActualBalance.String())
+	}
+
+	// 钱包余额与最后一条流水不一致
+	{
+		logs := []model.FinanceLog{
+			newLog("a", "0", "100", "100"),
+		}
+
+		items := reconciliation.Check("CNY", "1", newWallet("99"), logs)
+
+		assert.Len(t, items, 1)
+		assert.Equal(t, model.ReconciliationKindBalanceMismatch, items[0].Kind)
+		assert.Nil(t, items[0].LogId)
+	}
+
+	// 流水之间出现断档, 只报告一次
+	{
+		logs := []model.FinanceLog{
+			newLog("a", "0", "100", "100"),
+			newLog("b", "120", "-20", "100"),
+			newLog("c", "100", "-10", "90"),
+		}
+
+		items := reconciliation.Check("CNY", "1", newWallet("90"), logs)
+
+		assert.Len(t, items, 1)
+		assert.Equal(t, model.ReconciliationKindChainGap, items[0].Kind)
+		assert.Equal(t, "b", *items[0].LogId)
+		assert.Equal(t, "100.00000000", items[0].ExpectedBalance.String())
+		assert.Equal(t, "120.00000000", items[0].ActualBalance.String())
+	}
+
+	// 流水本身不平
+	{
+		logs := []model.FinanceLog{
+			newLog("a", "0", "100", "110"),
+		}
+
+		items := reconciliation.Check("CNY", "1", newWallet("110"), logs)
+
+		assert.Len(t, items, 1)
+		assert.Equal(t, model.ReconciliationKindInvalidLog, items[0].Kind)
+		assert.Equal(t, "a", *items[0].LogId)
+		assert.Equal(t, "100.00000000", items[0].ExpectedBalance.String())
+	}
+
+	// 有流水但是没有钱包
+	{
+		logs := []model.FinanceLog{
+			newLog("a", "0", "100", "100"),
+		}
+
+		items := reconciliation.Check("CNY", "1", nil, logs)
+
+		assert.Len(t, items, 1)
+		assert.Equal(t, model.ReconciliationKindWalletMissing, items[0].Kind)
+		assert.Equal(t, "100.00000000", items[0].ExpectedBalance.String())
+	}
+}
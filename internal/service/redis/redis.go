@@ -12,11 +12,16 @@ var (
 	ClientTokenUser      *redis.Client // 存储用户 token 的地方
 	ClientTokenAdmin     *redis.Client // 存储管理员 token 的地方
 	ClientActivationCode *redis.Client // 存储帐号激活码的
-	ClientAuthEmailCode  *redis.Client // 存储邮箱验证码，存储结构 key: 验证码, value: 邮箱
-	ClientAuthPhoneCode  *redis.Client // 存储手机验证码，存储结构 key: 验证码, value: 手机号
-	ClientResetCode      *redis.Client // 存储重置密码的
+	ClientVerifyCode     *redis.Client // 存储手机/邮箱验证码, 存储结构 key: 用途 + 接收方, value: 验证码
 	ClientOAuthCode      *redis.Client // 存储 oAuth2 对应的激活码
+	ClientExchangeQuote  *redis.Client // 存储币种兑换的报价, 存储结构 key: 报价ID, value: 报价详情
+	ClientTOTPChallenge  *redis.Client // 存储双重身份认证的登陆挑战, 存储结构 key: 挑战ID, value: 挑战详情
+	ClientGuard          *redis.Client // 存储登陆失败/发送验证码的次数, 以及被锁定的帐号和IP
+	ClientOidc           *redis.Client // 存储作为身份提供方时签发的授权码, 刷新令牌和已撤销的 token
+	ClientWebAuthn       *redis.Client // 存储 WebAuthn 注册/认证的挑战, 存储结构 key: 挑战, value: 挑战详情
+	ClientCustomer       *redis.Client // 存储客服系统的排队/匹配状态和在线的连接, 多个客服服务实例共享
 	Config               = config.Redis
+	Nil                  = redis.Nil // key 不存在时返回的错误
 )
 
 func init() {
@@ -32,18 +37,30 @@ func Dispose() {
 	if ClientActivationCode != nil {
 		_ = ClientActivationCode.Close()
 	}
-	if ClientAuthEmailCode != nil {
-		_ = ClientAuthEmailCode.Close()
-	}
-	if ClientAuthPhoneCode != nil {
-		_ = ClientAuthPhoneCode.Close()
-	}
-	if ClientResetCode != nil {
-		_ = ClientResetCode.Close()
+	if ClientVerifyCode != nil {
+		_ = ClientVerifyCode.Close()
 	}
 	if ClientOAuthCode != nil {
 		_ = ClientOAuthCode.Close()
 	}
+	if ClientExchangeQuote != nil {
+		_ = ClientExchangeQuote.Close()
+	}
+	if ClientTOTPChallenge != nil {
+		_ = ClientTOTPChallenge.Close()
+	}
+	if ClientGuard != nil {
+		_ = ClientGuard.Close()
+	}
+	if ClientOidc != nil {
+		_ = ClientOidc.Close()
+	}
+	if ClientWebAuthn != nil {
+		_ = ClientWebAuthn.Close()
+	}
+	if ClientCustomer != nil {
+		_ = ClientCustomer.Close()
+	}
 	if ClientTokenUser != nil {
 		_ = ClientTokenUser.Close()
 	}
@@ -71,28 +88,40 @@ func Connect() {
 		DB:       1,
 	})
 
-	ClientResetCode = redis.NewClient(&redis.Options{
+	ClientVerifyCode = redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       2,
 	})
 
-	ClientAuthEmailCode = redis.NewClient(&redis.Options{
+	ClientOAuthCode = redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
-		DB:       3,
+		DB:       5,
 	})
 
-	ClientAuthPhoneCode = redis.NewClient(&redis.Options{
+	ClientExchangeQuote = redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
-		DB:       4,
+		DB:       6,
 	})
 
-	ClientOAuthCode = redis.NewClient(&redis.Options{
+	ClientTOTPChallenge = redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
-		DB:       5,
+		DB:       7,
+	})
+
+	ClientGuard = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       8,
+	})
+
+	ClientOidc = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       9,
 	})
 
 	ClientTokenUser = redis.NewClient(&redis.Options{
@@ -107,4 +136,16 @@ func Connect() {
 		DB:       11,
 	})
 
+	ClientWebAuthn = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       12,
+	})
+
+	ClientCustomer = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       13,
+	})
+
 }
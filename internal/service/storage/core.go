@@ -4,11 +4,27 @@ package storage
 import (
 	"fmt"
 	"github.com/axetroy/go-server/internal/library/config"
+	"io"
 	"log"
+	"path"
+	"strings"
+	"time"
 )
 
 type Storage interface {
-	Store(file []byte, filename string) (*string, error) // 存储文件
+	Store(file io.ReadSeeker, filename string) (*string, error) // 存储文件, 从 file 流式读取, 返回文件的存储路径
+	Open(filename string) (io.ReadCloser, error)                // 打开文件, 使用完毕后需要关闭
+	Delete(filename string) error                               // 删除文件
+	Stat(filename string) (*FileInfo, error)                    // 获取文件信息
+	URL(filename string) string                                 // 获取文件的访问地址
+}
+
+// 文件信息
+type FileInfo struct {
+	Name        string    `json:"name"`         // 文件名, 即存储时的 filename
+	Size        int64     `json:"size"`         // 文件大小, 单位 byte
+	ContentType string    `json:"content_type"` // 文件的 MIME 类型
+	ModTime     time.Time `json:"mod_time"`     // 最后修改时间
 }
 
 type provider string
@@ -17,16 +33,19 @@ var (
 	client        *Storage           // 文件存储的客户端
 	providerLocal provider = "local" // 本地存储
 	providerSFTP  provider = "sftp"  // SFTP 协议储存到远端
+	providerS3    provider = "s3"    // S3 兼容的对象存储, 例如 AWS S3/MinIO/阿里云 OSS
 )
 
 func init() {
-	switch provider(config.Telephone.Provider) {
+	switch provider(config.Storage.Provider) {
 	case providerLocal:
 		initClient(NewLocalStorage())
 	case providerSFTP:
 		initClient(NewSFTPStorage())
+	case providerS3:
+		initClient(NewS3Storage())
 	default:
-		log.Fatal(fmt.Sprintf(`Invalid storage provider "%s"`, config.Telephone.Provider))
+		log.Fatal(fmt.Sprintf(`Invalid storage provider "%s"`, config.Storage.Provider))
 	}
 }
 
@@ -37,3 +56,8 @@ func initClient(s Storage) {
 func GetClient() Storage {
 	return *client
 }
+
+// 规范化文件名, 防止通过 `../` 访问到存储目录以外的文件
+func normalize(filename string) string {
+	return strings.TrimPrefix(path.Clean("/"+filename), "/")
+}
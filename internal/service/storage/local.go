@@ -1,16 +1,110 @@
 // Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
 package storage
 
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
 func NewLocalStorage() *LocalStorage {
-	c := &LocalStorage{}
+	c := &LocalStorage{
+		RootPath: config.Storage.Local.RootPath,
+		BaseURL:  config.Storage.Local.BaseURL,
+	}
 
 	return c
 }
 
 type LocalStorage struct {
 	RootPath string `json:"root_path"` // 定义存储的根目录
+	BaseURL  string `json:"base_url"`  // 文件对外访问的地址前缀
+}
+
+// 获取文件在磁盘上的路径
+func (c *LocalStorage) filepath(filename string) string {
+	return filepath.Join(c.RootPath, filepath.FromSlash(normalize(filename)))
+}
+
+func (c *LocalStorage) Store(file io.ReadSeeker, filename string) (*string, error) {
+	distPath := c.filepath(filename)
+
+	if err := os.MkdirAll(filepath.Dir(distPath), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(distPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(f, file)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	// 写入失败时不留下不完整的文件
+	if err != nil {
+		_ = os.Remove(distPath)
+		return nil, err
+	}
+
+	return &distPath, nil
+}
+
+func (c *LocalStorage) Open(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(c.filepath(filename))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, exception.FileNotExist
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (c *LocalStorage) Delete(filename string) error {
+	if err := os.Remove(c.filepath(filename)); err != nil {
+		if os.IsNotExist(err) {
+			return exception.FileNotExist
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (c *LocalStorage) Stat(filename string) (*FileInfo, error) {
+	info, err := os.Stat(c.filepath(filename))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, exception.FileNotExist
+		}
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, exception.FileNotExist
+	}
+
+	return &FileInfo{
+		Name:        normalize(filename),
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(strings.ToLower(path.Ext(filename))),
+		ModTime:     info.ModTime(),
+	}, nil
 }
 
-func (c *LocalStorage) Store(file []byte, filename string) (*string, error) {
-	return nil, nil
+func (c *LocalStorage) URL(filename string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + normalize(filename)
 }
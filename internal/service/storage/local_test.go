@@ -0,0 +1,113 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package storage_test
+
+import (
+	"bytes"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root, err := ioutil.TempDir("", "storage")
+
+	assert.Nil(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(root)
+	}()
+
+	s := &storage.LocalStorage{
+		RootPath: root,
+		BaseURL:  "/v1/resource/",
+	}
+
+	content := []byte("hello world")
+
+	p, err := s.Store(bytes.NewReader(content), "file/hello.txt")
+
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "file", "hello.txt"), *p)
+
+	info, err := s.Stat("file/hello.txt")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "file/hello.txt", info.Name)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+
+	reader, err := s.Open("file/hello.txt")
+
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadAll(reader)
+
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, content, b)
+
+	assert.Equal(t, "/v1/resource/file/hello.txt", s.URL("file/hello.txt"))
+
+	// 不能访问到根目录以外的文件
+	_, err = s.Store(bytes.NewReader(content), "../../escape.txt")
+
+	assert.Nil(t, err)
+	assert.FileExists(t, filepath.Join(root, "escape.txt"))
+
+	assert.Nil(t, s.Delete("file/hello.txt"))
+
+	_, err = s.Stat("file/hello.txt")
+
+	assert.Equal(t, exception.FileNotExist, err)
+
+	_, err = s.Open("file/hello.txt")
+
+	assert.Equal(t, exception.FileNotExist, err)
+
+	assert.Equal(t, exception.FileNotExist, s.Delete("file/hello.txt"))
+
+	// 目录不是文件
+	_, err = s.Stat("file")
+
+	assert.Equal(t, exception.FileNotExist, err)
+}
+
+func TestServeAttachment(t *testing.T) {
+	root, err := ioutil.TempDir("", "storage")
+
+	assert.Nil(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(root)
+	}()
+
+	s := &storage.LocalStorage{RootPath: root}
+
+	_, err = s.Store(bytes.NewReader([]byte("hello world")), "file/hello.txt")
+
+	assert.Nil(t, err)
+
+	{
+		w := httptest.NewRecorder()
+
+		assert.Nil(t, storage.ServeAttachment(s, w, httptest.NewRequest(http.MethodGet, "/download/hello.txt", nil), "file/hello.txt", "hello.txt"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "attachment; filename=hello.txt", w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "hello world", w.Body.String())
+	}
+
+	// 文件不存在时不作为附件下载
+	{
+		w := httptest.NewRecorder()
+
+		assert.Nil(t, storage.ServeAttachment(s, w, httptest.NewRequest(http.MethodGet, "/download/none.txt", nil), "file/none.txt", "none.txt"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	}
+}
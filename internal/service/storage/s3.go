@@ -0,0 +1,360 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm    = "AWS4-HMAC-SHA256"
+	s3Service      = "s3"
+	s3TimeFormat   = "20060102T150405Z"
+	s3ShortTimeFmt = "20060102"
+)
+
+func NewS3Storage() *S3Storage {
+	c := &S3Storage{
+		Endpoint:        config.Storage.S3.Endpoint,
+		Region:          config.Storage.S3.Region,
+		Bucket:          config.Storage.S3.Bucket,
+		AccessKeyId:     config.Storage.S3.AccessKeyId,
+		SecretAccessKey: config.Storage.S3.SecretAccessKey,
+		PathStyle:       config.Storage.S3.PathStyle,
+		PublicURL:       config.Storage.S3.PublicURL,
+		Client:          &http.Client{Timeout: time.Minute},
+	}
+
+	return c
+}
+
+// S3 兼容的对象存储, 使用 AWS Signature Version 4 签名请求
+// 可对接 AWS S3/MinIO/阿里云 OSS/腾讯云 COS 等服务
+type S3Storage struct {
+	Endpoint        string       `json:"endpoint"`          // 服务地址
+	Region          string       `json:"region"`            // 区域
+	Bucket          string       `json:"bucket"`            // 存储桶
+	AccessKeyId     string       `json:"access_key_id"`     // access key
+	SecretAccessKey string       `json:"secret_access_key"` // secret key
+	PathStyle       bool         `json:"path_style"`        // 是否使用路径风格的地址
+	PublicURL       string       `json:"public_url"`        // 对外访问的地址前缀
+	Client          *http.Client `json:"-"`                 // 发送请求的客户端
+}
+
+// 获取对象的访问地址
+func (c *S3Storage) objectURL(filename string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := normalize(filename)
+
+	if c.PathStyle {
+		u.Path = u.Path + "/" + c.Bucket + "/" + key
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		u.Path = u.Path + "/" + key
+	}
+
+	return u, nil
+}
+
+// body 为 nil 时发送空的请求体
+// 签名需要请求体的 sha256, 所以先读一遍计算摘要和长度, 再回到开头发送, 不会整个读进内存
+func (c *S3Storage) do(method string, filename string, body io.ReadSeeker, header http.Header) (*http.Response, error) {
+	u, err := c.objectURL(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		body = bytes.NewReader(nil)
+	}
+
+	hash := sha256.New()
+
+	size, err := io.Copy(hash, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u.String(), ioutil.NopCloser(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	signS3Request(req, hex.EncodeToString(hash.Sum(nil)), c.AccessKeyId, c.SecretAccessKey, c.Region, time.Now())
+
+	return c.Client.Do(req)
+}
+
+// 将非 2xx 的响应转换为错误
+func s3ResponseError(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return exception.FileNotExist
+	}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+
+	return exception.ThirdParty.New(fmt.Sprintf("S3 %d: %s", res.StatusCode, strings.TrimSpace(string(b))))
+}
+
+func (c *S3Storage) Store(file io.ReadSeeker, filename string) (*string, error) {
+	header := http.Header{}
+
+	if contentType := mime.TypeByExtension(strings.ToLower(path.Ext(filename))); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.do(http.MethodPut, filename, file, header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode/100 != 2 {
+		return nil, s3ResponseError(res)
+	}
+
+	location := c.Bucket + "/" + normalize(filename)
+
+	return &location, nil
+}
+
+func (c *S3Storage) Open(filename string) (io.ReadCloser, error) {
+	res, err := c.do(http.MethodGet, filename, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode/100 != 2 {
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		return nil, s3ResponseError(res)
+	}
+
+	return res.Body, nil
+}
+
+func (c *S3Storage) Delete(filename string) error {
+	// S3 删除不存在的对象也会成功, 先确认对象存在, 和本地存储保持一致
+	if _, err := c.Stat(filename); err != nil {
+		return err
+	}
+
+	res, err := c.do(http.MethodDelete, filename, nil, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode/100 != 2 {
+		return s3ResponseError(res)
+	}
+
+	return nil
+}
+
+func (c *S3Storage) Stat(filename string) (*FileInfo, error) {
+	res, err := c.do(http.MethodHead, filename, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode/100 != 2 {
+		return nil, s3ResponseError(res)
+	}
+
+	info := FileInfo{
+		Name:        normalize(filename),
+		ContentType: res.Header.Get("Content-Type"),
+	}
+
+	if size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+
+	return &info, nil
+}
+
+func (c *S3Storage) URL(filename string) string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/") + "/" + normalize(filename)
+	}
+
+	u, err := c.objectURL(filename)
+
+	if err != nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 按照 AWS 的规则进行 URI 编码, 除了 unreserved 字符以外都需要编码
+func s3URIEncode(s string, encodeSlash bool) string {
+	var buf strings.Builder
+
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			buf.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+
+	return buf.String()
+}
+
+// 使用 AWS Signature Version 4 对请求进行签名
+// 文档: https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signS3Request(req *http.Request, payloadHash string, accessKeyId string, secretAccessKey string, region string, now time.Time) {
+	var (
+		amzDate   = now.UTC().Format(s3TimeFormat)
+		shortDate = now.UTC().Format(s3ShortTimeFmt)
+		scope     = strings.Join([]string{shortDate, region, s3Service, "aws4_request"}, "/")
+	)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// 参与签名的头部
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	headerNames := make([]string, 0, len(headers))
+
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+
+	sort.Strings(headerNames)
+
+	canonicalHeaders := strings.Builder{}
+
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(headerNames, ";")
+
+	// 参与签名的 query
+	query := req.URL.Query()
+	queryKeys := make([]string, 0, len(query))
+
+	for key := range query {
+		queryKeys = append(queryKeys, key)
+	}
+
+	sort.Strings(queryKeys)
+
+	queryPairs := make([]string, 0, len(queryKeys))
+
+	for _, key := range queryKeys {
+		for _, value := range query[key] {
+			queryPairs = append(queryPairs, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+
+	canonicalURI := req.URL.Path
+
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3URIEncode(canonicalURI, false),
+		strings.Join(queryPairs, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", s3Algorithm, accessKeyId, scope, signedHeaders, signature))
+}
@@ -0,0 +1,152 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package storage_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type s3Object struct {
+	content     []byte
+	contentType string
+	modTime     time.Time
+}
+
+// 一个简易的内存 S3 服务, 用于测试
+func newFakeS3Server(t *testing.T, accessKeyId string) *httptest.Server {
+	var (
+		lock    sync.Mutex
+		objects = map[string]s3Object{}
+	)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		auth := r.Header.Get("Authorization")
+
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+accessKeyId+"/") || r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+			return
+		}
+
+		key := r.URL.Path
+
+		switch r.Method {
+		case http.MethodPut:
+			b, err := ioutil.ReadAll(r.Body)
+			assert.Nil(t, err)
+			// 签名的摘要和长度需要和实际发送的内容一致
+			hash := sha256.Sum256(b)
+			assert.Equal(t, hex.EncodeToString(hash[:]), r.Header.Get("X-Amz-Content-Sha256"))
+			assert.Equal(t, int64(len(b)), r.ContentLength)
+			objects[key] = s3Object{content: b, contentType: r.Header.Get("Content-Type"), modTime: time.Now()}
+		case http.MethodGet, http.MethodHead:
+			obj, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", obj.contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(obj.content)))
+			w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(obj.content)
+			}
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestS3Storage(t *testing.T) {
+	server := newFakeS3Server(t, "test-access-key")
+
+	defer server.Close()
+
+	s := &storage.S3Storage{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "bucket",
+		AccessKeyId:     "test-access-key",
+		SecretAccessKey: "test-secret-key",
+		PathStyle:       true,
+		Client:          server.Client(),
+	}
+
+	content := []byte("hello world")
+
+	location, err := s.Store(bytes.NewReader(content), "image/hello.png")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "bucket/image/hello.png", *location)
+
+	info, err := s.Stat("image/hello.png")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "image/hello.png", info.Name)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.False(t, info.ModTime.IsZero())
+
+	reader, err := s.Open("image/hello.png")
+
+	assert.Nil(t, err)
+
+	b, err := ioutil.ReadAll(reader)
+
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, content, b)
+
+	assert.Equal(t, server.URL+"/bucket/image/hello.png", s.URL("image/hello.png"))
+
+	s.PublicURL = "https://cdn.example.com/"
+
+	assert.Equal(t, "https://cdn.example.com/image/hello.png", s.URL("image/hello.png"))
+
+	assert.Nil(t, s.Delete("image/hello.png"))
+
+	_, err = s.Stat("image/hello.png")
+
+	assert.Equal(t, exception.FileNotExist, err)
+
+	_, err = s.Open("image/hello.png")
+
+	assert.Equal(t, exception.FileNotExist, err)
+
+	assert.Equal(t, exception.FileNotExist, s.Delete("image/hello.png"))
+
+	// 错误的凭证
+	s.AccessKeyId = "invalid"
+
+	_, err = s.Store(bytes.NewReader(content), "image/hello.png")
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestS3StorageVirtualHostURL(t *testing.T) {
+	s := &storage.S3Storage{
+		Endpoint: "https://s3.amazonaws.com",
+		Bucket:   "bucket",
+	}
+
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/image/hello.png", s.URL("image/hello.png"))
+}
@@ -0,0 +1,75 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package storage
+
+import (
+	"fmt"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// 将存储中的文件输出到 http 响应中, 文件不存在时返回 404
+// 如果存储返回的文件支持 Seek (例如本地存储), 则支持 Range/If-Modified-Since 等请求头
+func Serve(s Storage, w http.ResponseWriter, r *http.Request, filename string) error {
+	return serve(s, w, r, filename, "")
+}
+
+// 以附件的形式输出文件, 浏览器会下载而不是直接打开
+// 只有文件存在时才设置 Content-Disposition, 404 的响应不会被当作附件下载
+func ServeAttachment(s Storage, w http.ResponseWriter, r *http.Request, filename string, name string) error {
+	return serve(s, w, r, filename, fmt.Sprintf("attachment; filename=%v", name))
+}
+
+func serve(s Storage, w http.ResponseWriter, r *http.Request, filename string, disposition string) error {
+	info, err := s.Stat(filename)
+
+	if err != nil {
+		if err == exception.FileNotExist {
+			http.NotFound(w, r)
+			return nil
+		}
+		return err
+	}
+
+	reader, err := s.Open(filename)
+
+	if err != nil {
+		if err == exception.FileNotExist {
+			http.NotFound(w, r)
+			return nil
+		}
+		return err
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+
+	if disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, info.Name, info.ModTime, seeker)
+		return nil
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		_, err = io.Copy(w, reader)
+	}
+
+	return err
+}
@@ -1,6 +1,13 @@
 // Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
 package storage
 
+import (
+	"errors"
+	"io"
+)
+
+var errSFTPNotImplemented = errors.New("sftp storage is not implemented yet")
+
 func NewSFTPStorage() *SFTPStorage {
 	c := &SFTPStorage{}
 
@@ -10,6 +17,22 @@ func NewSFTPStorage() *SFTPStorage {
 type SFTPStorage struct {
 }
 
-func (c *SFTPStorage) Store(file []byte, filename string) (*string, error) {
-	return nil, nil
+func (c *SFTPStorage) Store(file io.ReadSeeker, filename string) (*string, error) {
+	return nil, errSFTPNotImplemented
+}
+
+func (c *SFTPStorage) Open(filename string) (io.ReadCloser, error) {
+	return nil, errSFTPNotImplemented
+}
+
+func (c *SFTPStorage) Delete(filename string) error {
+	return errSFTPNotImplemented
+}
+
+func (c *SFTPStorage) Stat(filename string) (*FileInfo, error) {
+	return nil, errSFTPNotImplemented
+}
+
+func (c *SFTPStorage) URL(filename string) string {
+	return ""
 }
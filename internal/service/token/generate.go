@@ -2,7 +2,6 @@
 package token
 
 import (
-	"github.com/axetroy/go-server/internal/library/config"
 	"github.com/axetroy/go-server/internal/library/util"
 	"github.com/dgrijalva/jwt-go"
 	"time"
@@ -13,23 +12,25 @@ type State string
 const (
 	StateUser  State = "user"
 	StateAdmin State = "admin"
+	StateOidc  State = "oidc" // 作为身份提供方, 给其他应用签发的 token
 )
 
 // generate jwt token
 func Generate(userId string, state State, d ...time.Duration) (tokenString string, err error) {
-	var (
-		issuer string
-		key    string
-	)
-
 	var duration time.Duration
 
 	if len(d) > 0 {
 		duration = d[0]
-		if d[0] == 0 {
-			duration = time.Hour * time.Duration(6)
-		}
-	} else {
+	}
+
+	tokenString, _, err = Issue(userId, "", state, duration)
+
+	return
+}
+
+// 签发 token, sessionId 为 token 所属的会话, 为空则以 token 自己的ID作为会话ID
+func Issue(userId string, sessionId string, state State, duration time.Duration) (tokenString string, claims Claims, err error) {
+	if duration == 0 {
 		duration = time.Hour * time.Duration(6)
 	}
 
@@ -38,31 +39,45 @@ func Generate(userId string, state State, d ...time.Duration) (tokenString strin
 		duration = time.Hour * 24 * 30
 	}
 
-	switch state {
-	case StateAdmin:
-		issuer = "admin"
-		key = config.Jwt.Secret
-	case StateUser:
-		issuer = "user"
-		key = config.Jwt.Secret
+	key, err := signingKey(state)
+
+	if err != nil {
+		return
 	}
 
+	id := util.GenerateId() // 每个 token 唯一的ID (jti), 用于注销某个 token
+
+	if sessionId == "" {
+		sessionId = id
+	}
+
+	now := time.Now()
+
 	// 生成token
 	c := ClaimsInternal{
-		util.Base64Encode(userId),
-		jwt.StandardClaims{
+		Uid: util.Base64Encode(userId),
+		Sid: sessionId,
+		StandardClaims: jwt.StandardClaims{
 			Audience:  userId,
-			Id:        userId,
-			ExpiresAt: time.Now().Add(duration).Unix(),
-			Issuer:    issuer,
-			IssuedAt:  time.Now().Unix(),
-			NotBefore: time.Now().Unix(),
+			Id:        id,
+			ExpiresAt: now.Add(duration).Unix(),
+			Issuer:    string(state),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+
+	token.Header["kid"] = key.Id
+
+	if tokenString, err = token.SignedString(key.PrivateKey); err != nil {
+		return
+	}
 
-	tokenString, err = token.SignedString([]byte(key))
+	claims.Uid = userId
+	claims.Sid = sessionId
+	claims.StandardClaims = c.StandardClaims
 
 	return
 }
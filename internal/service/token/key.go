@@ -0,0 +1,301 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/dotenv"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 加密之后的私钥的前缀, 没有前缀的是旧版本保存的明文 PEM
+const encryptedPrefix = "enc:v1:"
+
+var ErrNoEncryptionKey = errors.New("TOKEN_KEY_ENCRYPTION_KEY is required to store the token signing keys")
+
+// 签名密钥, 用户和管理员使用各自的密钥 (ES256)
+// 写入 token 头部的 kid 用于找到对应的公钥, 轮换密钥之后旧的 token 依然可以验证
+type Key struct {
+	Id          string            // 密钥ID, 即 token 头部的 kid
+	State       State             // 用于签发哪种身份的 token
+	PrivateKey  *ecdsa.PrivateKey // 私钥
+	ActivatedAt time.Time         // 开始签发的时间, 新的密钥先发布, 等所有实例都加载之后才开始签发
+}
+
+// 公开的 JSON Web Key, 其他服务可以用来离线验证 token
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var (
+	keyLock sync.RWMutex
+	keys    = map[string]Key{} // 所有可用于验证的密钥, key 为 kid
+)
+
+// 生成一个新的密钥
+func NewKey(state State) (Key, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Id:         util.GenerateId(),
+		State:      state,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// 使用 TOKEN_KEY_ENCRYPTION_KEY 派生的 AES-256-GCM 加密私钥
+func newCipher() (cipher.AEAD, error) {
+	if config.Jwt.KeyEncryptionKey == "" {
+		return nil, ErrNoEncryptionKey
+	}
+
+	sum := sha256.Sum256([]byte(config.Jwt.KeyEncryptionKey))
+
+	block, err := aes.NewCipher(sum[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// 私钥是否已经加密
+func IsEncrypted(privateKey string) bool {
+	return strings.HasPrefix(privateKey, encryptedPrefix)
+}
+
+// 把私钥编码成 PEM 格式并加密, 用于存储
+// 测试环境没有设置加密密钥时保存明文
+func MarshalKey(k Key) (string, error) {
+	b, err := x509.MarshalECPrivateKey(k.PrivateKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
+
+	if config.Jwt.KeyEncryptionKey == "" && dotenv.Test {
+		return string(plaintext), nil
+	}
+
+	aead, err := newCipher()
+
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// 解密并解析私钥, 兼容旧版本保存的明文 PEM
+func ParseKey(id string, state State, privateKey string, activatedAt time.Time) (Key, error) {
+	if IsEncrypted(privateKey) {
+		aead, err := newCipher()
+
+		if err != nil {
+			return Key{}, err
+		}
+
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(privateKey, encryptedPrefix))
+
+		if err != nil {
+			return Key{}, err
+		}
+
+		if len(b) < aead.NonceSize() {
+			return Key{}, errors.New("invalid private key")
+		}
+
+		plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+
+		if err != nil {
+			return Key{}, err
+		}
+
+		privateKey = string(plaintext)
+	}
+
+	block, _ := pem.Decode([]byte(privateKey))
+
+	if block == nil {
+		return Key{}, errors.New("invalid private key")
+	}
+
+	k, err := x509.ParseECPrivateKey(block.Bytes)
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Id:          id,
+		State:       state,
+		PrivateKey:  k,
+		ActivatedAt: activatedAt,
+	}, nil
+}
+
+// 替换所有的密钥
+func SetKeys(list []Key) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	keys = map[string]Key{}
+
+	for _, k := range list {
+		keys[k.Id] = k
+	}
+}
+
+// 获取当前用于签发的密钥, 即已经开始签发的密钥中最新的一个
+func currentKey(state State, now time.Time) (Key, bool) {
+	var (
+		current Key
+		found   bool
+	)
+
+	for _, k := range keys {
+		if k.State != state || k.ActivatedAt.After(now) {
+			continue
+		}
+
+		if !found || k.ActivatedAt.After(current.ActivatedAt) {
+			current = k
+			found = true
+		}
+	}
+
+	return current, found
+}
+
+// 检查每种身份都有正在签发的密钥, 启动时调用, 避免运行之后才发现无法签发 token
+func CheckKeys(states ...State) error {
+	keyLock.RLock()
+	defer keyLock.RUnlock()
+
+	for _, state := range states {
+		if _, ok := currentKey(state, time.Now()); !ok {
+			return fmt.Errorf("no signing key for %s", state)
+		}
+	}
+
+	return nil
+}
+
+// 获取当前用于签发的密钥
+// 只有测试环境在没有设置过密钥时, 生成一个只存在于内存中的密钥
+func signingKey(state State) (Key, error) {
+	keyLock.RLock()
+	k, ok := currentKey(state, time.Now())
+	keyLock.RUnlock()
+
+	if ok {
+		return k, nil
+	}
+
+	if !dotenv.Test {
+		return Key{}, fmt.Errorf("no signing key for %s", state)
+	}
+
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	if k, ok := currentKey(state, time.Now()); ok {
+		return k, nil
+	}
+
+	k, err := NewKey(state)
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	keys[k.Id] = k
+
+	return k, nil
+}
+
+// 根据 kid 获取验证用的公钥, 用户的密钥不能用来验证管理员的 token, 反之亦然
+func verifyKey(state State, kid string) (*ecdsa.PublicKey, error) {
+	keyLock.RLock()
+	defer keyLock.RUnlock()
+
+	k, ok := keys[kid]
+
+	if !ok || k.State != state {
+		return nil, exception.InvalidToken
+	}
+
+	return &k.PrivateKey.PublicKey, nil
+}
+
+func encodeCoordinate(b []byte) string {
+	// P-256 的坐标固定 32 字节, 不足的在前面补 0
+	padded := make([]byte, 32)
+	copy(padded[32-len(b):], b)
+
+	return base64.RawURLEncoding.EncodeToString(padded)
+}
+
+// 获取某种身份所有可用于验证的公钥
+func JWKS(state State) JSONWebKeySet {
+	keyLock.RLock()
+	defer keyLock.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0)}
+
+	for _, k := range keys {
+		if k.State != state {
+			continue
+		}
+
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   encodeCoordinate(k.PrivateKey.X.Bytes()),
+			Y:   encodeCoordinate(k.PrivateKey.Y.Bytes()),
+			Kid: k.Id,
+			Use: "sig",
+			Alg: "ES256",
+		})
+	}
+
+	return set
+}
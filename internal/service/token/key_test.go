@@ -0,0 +1,234 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package token_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getKid(t *testing.T, tokenStr string) string {
+	tk, _, err := new(jwt.Parser).ParseUnverified(tokenStr, &token.ClaimsInternal{})
+
+	assert.Nil(t, err)
+
+	kid, _ := tk.Header["kid"].(string)
+
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	defer token.SetKeys(nil)
+
+	oldKey, err := token.NewKey(token.StateUser)
+	assert.Nil(t, err)
+
+	newKey, err := token.NewKey(token.StateUser)
+	assert.Nil(t, err)
+
+	adminKey, err := token.NewKey(token.StateAdmin)
+	assert.Nil(t, err)
+
+	// 新的密钥已经发布, 但是还没开始签发
+	oldKey.ActivatedAt = time.Now().Add(-time.Hour)
+	newKey.ActivatedAt = time.Now().Add(time.Hour)
+
+	token.SetKeys([]token.Key{oldKey, newKey, adminKey})
+
+	oldToken, err := token.Generate("123123", token.StateUser)
+
+	assert.Nil(t, err)
+	assert.Equal(t, oldKey.Id, getKid(t, oldToken))
+
+	// 公开两个用户的公钥, 不包含管理员的公钥
+	set := token.JWKS(token.StateUser)
+
+	assert.Len(t, set.Keys, 2)
+
+	for _, k := range set.Keys {
+		assert.NotEqual(t, adminKey.Id, k.Kid)
+		assert.Equal(t, "ES256", k.Alg)
+		assert.Len(t, k.X, 43)
+		assert.Len(t, k.Y, 43)
+	}
+
+	// 新的密钥开始签发
+	newKey.ActivatedAt = time.Now().Add(-time.Minute)
+
+	token.SetKeys([]token.Key{oldKey, newKey, adminKey})
+
+	newToken, err := token.Generate("123123", token.StateUser)
+
+	assert.Nil(t, err)
+	assert.Equal(t, newKey.Id, getKid(t, newToken))
+
+	// 旧的 token 依然有效
+	c, err := token.Parse(token.Prefix+" "+oldToken, token.StateUser)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123123", c.Uid)
+
+	// 旧的密钥被删除之后, 旧的 token 失效
+	token.SetKeys([]token.Key{newKey, adminKey})
+
+	_, err = token.Parse(token.Prefix+" "+oldToken, token.StateUser)
+
+	assert.Equal(t, exception.InvalidToken, err)
+
+	_, err = token.Parse(token.Prefix+" "+newToken, token.StateUser)
+
+	assert.Nil(t, err)
+}
+
+func TestKeyState(t *testing.T) {
+	defer token.SetKeys(nil)
+
+	userToken, err := token.Generate("123123", token.StateUser)
+	assert.Nil(t, err)
+
+	adminToken, err := token.Generate("123123", token.StateAdmin)
+	assert.Nil(t, err)
+
+	// 用户和管理员使用不同的密钥
+	assert.NotEqual(t, getKid(t, userToken), getKid(t, adminToken))
+
+	_, err = token.Parse(token.Prefix+" "+userToken, token.StateAdmin)
+	assert.Equal(t, exception.InvalidToken, err)
+
+	_, err = token.Parse(token.Prefix+" "+adminToken, token.StateUser)
+	assert.Equal(t, exception.InvalidToken, err)
+}
+
+func TestKeyMarshal(t *testing.T) {
+	k, err := token.NewKey(token.StateAdmin)
+	assert.Nil(t, err)
+
+	pem, err := token.MarshalKey(k)
+	assert.Nil(t, err)
+
+	parsed, err := token.ParseKey(k.Id, k.State, pem, time.Time{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, k.Id, parsed.Id)
+	assert.True(t, k.PrivateKey.Equal(parsed.PrivateKey))
+
+	_, err = token.ParseKey(k.Id, k.State, "invalid", time.Time{})
+	assert.NotNil(t, err)
+}
+
+func TestParseLegacyToken(t *testing.T) {
+	// 旧版本使用共享密钥签发的 token
+	c := token.ClaimsInternal{
+		Uid: util.Base64Encode("123123"),
+		StandardClaims: jwt.StandardClaims{
+			Id:        util.GenerateId(),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Issuer:    string(token.StateUser),
+			IssuedAt:  time.Now().Unix(),
+			NotBefore: time.Now().Unix(),
+		},
+	}
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(config.Jwt.Secret))
+	assert.Nil(t, err)
+
+	// 默认不接受
+	_, err = token.Parse(token.Prefix+" "+legacy, token.StateUser)
+
+	assert.Equal(t, exception.InvalidToken, err)
+
+	config.Jwt.AcceptLegacy = true
+
+	defer func() {
+		config.Jwt.AcceptLegacy = false
+	}()
+
+	claims, err := token.Parse(token.Prefix+" "+legacy, token.StateUser)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123123", claims.Uid)
+	assert.Equal(t, c.Id, claims.Sid)
+
+	// 其他密钥签发的 token 无效
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte("invalid secret"))
+	assert.Nil(t, err)
+
+	_, err = token.Parse(token.Prefix+" "+forged, token.StateUser)
+
+	assert.Equal(t, exception.InvalidToken, err)
+
+	// 管理员的 token 始终不接受
+	c.Issuer = string(token.StateAdmin)
+
+	admin, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(config.Jwt.Secret))
+	assert.Nil(t, err)
+
+	_, err = token.Parse(token.Prefix+" "+admin, token.StateAdmin)
+
+	assert.Equal(t, exception.InvalidToken, err)
+}
+
+func TestMarshalKey(t *testing.T) {
+	key, err := token.NewKey(token.StateUser)
+	assert.Nil(t, err)
+
+	// 测试环境没有设置加密密钥时保存明文
+	legacy, err := token.MarshalKey(key)
+	assert.Nil(t, err)
+	assert.False(t, token.IsEncrypted(legacy))
+
+	config.Jwt.KeyEncryptionKey = "encryption key"
+
+	defer func() {
+		config.Jwt.KeyEncryptionKey = ""
+	}()
+
+	encrypted, err := token.MarshalKey(key)
+	assert.Nil(t, err)
+	assert.True(t, token.IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "PRIVATE KEY")
+
+	parsed, err := token.ParseKey(key.Id, key.State, encrypted, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, key.PrivateKey.D, parsed.PrivateKey.D)
+
+	// 旧版本保存的明文私钥依然可以解析
+	parsed, err = token.ParseKey(key.Id, key.State, legacy, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, key.PrivateKey.D, parsed.PrivateKey.D)
+
+	// 加密密钥不对时无法解密
+	config.Jwt.KeyEncryptionKey = "other key"
+
+	_, err = token.ParseKey(key.Id, key.State, encrypted, time.Now())
+	assert.NotNil(t, err)
+
+	// 没有设置加密密钥时无法解密
+	config.Jwt.KeyEncryptionKey = ""
+
+	_, err = token.ParseKey(key.Id, key.State, encrypted, time.Now())
+	assert.Equal(t, token.ErrNoEncryptionKey, err)
+}
+
+func TestCheckKeys(t *testing.T) {
+	defer token.SetKeys(nil)
+
+	userKey, err := token.NewKey(token.StateUser)
+	assert.Nil(t, err)
+
+	// 还没开始签发的密钥不算
+	adminKey, err := token.NewKey(token.StateAdmin)
+	assert.Nil(t, err)
+
+	adminKey.ActivatedAt = time.Now().Add(time.Hour)
+
+	token.SetKeys([]token.Key{userKey, adminKey})
+
+	assert.Nil(t, token.CheckKeys(token.StateUser))
+	assert.NotNil(t, token.CheckKeys(token.StateUser, token.StateAdmin))
+}
@@ -33,7 +33,27 @@ func Parse(tokenString string, state State) (claims Claims, err error) {
 	c := ClaimsInternal{}
 
 	if token, err = jwt.ParseWithClaims(tokenString, &c, func(token *jwt.Token) (interface{}, error) {
-		return []byte(key), nil
+		kid, _ := token.Header["kid"].(string)
+
+		// 旧版本签发的 token 没有 kid, 使用共享的密钥签名
+		// 共享的密钥有公开的默认值, 并且用户和管理员共用, 所以默认不接受, 管理员的 token 始终不接受
+		if kid == "" {
+			if !config.Jwt.AcceptLegacy || state != StateUser {
+				return nil, exception.InvalidToken
+			}
+
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, exception.InvalidToken
+			}
+
+			return []byte(key), nil
+		}
+
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, exception.InvalidToken
+		}
+
+		return verifyKey(state, kid)
 	}); err != nil {
 		if strings.HasPrefix(err.Error(), "token is expired by") {
 			err = exception.TokenExpired
@@ -47,11 +67,18 @@ func Parse(tokenString string, state State) (claims Claims, err error) {
 			uid string
 		)
 
+		// 用户的 token 不能当作管理员的 token 使用, 反之亦然
+		if c.Issuer != string(state) {
+			err = exception.InvalidToken
+			return
+		}
+
 		if uid, err = util.Base64Decode(c.Uid); err != nil {
 			return
 		}
 
 		claims.Uid = uid
+		claims.Sid = c.Sid
 		claims.Audience = c.Audience
 		claims.Id = c.Id
 		claims.NotBefore = c.NotBefore
@@ -60,6 +87,11 @@ func Parse(tokenString string, state State) (claims Claims, err error) {
 		claims.IssuedAt = c.IssuedAt
 		claims.Subject = c.Subject
 
+		// 旧版本签发的 token 没有会话ID
+		if claims.Sid == "" {
+			claims.Sid = claims.Id
+		}
+
 		return
 	} else if ve, ok := err.(*jwt.ValidationError); ok {
 		if ve.Errors&jwt.ValidationErrorMalformed != 0 {
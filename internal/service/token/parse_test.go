@@ -18,5 +18,16 @@ func TestParse(t *testing.T) {
 
 	assert.Nil(t, err1)
 
-	assert.Equal(t, uid, c.Id)
+	assert.Equal(t, uid, c.Uid)
+	assert.NotEmpty(t, c.Id)
+
+	// 每个 token 的 ID 都不相同
+	tokenStr2, err := token.Generate(uid, token.StateUser)
+
+	assert.Nil(t, err)
+
+	c2, err2 := token.Parse(token.Prefix+" "+tokenStr2, token.StateUser)
+
+	assert.Nil(t, err2)
+	assert.NotEqual(t, c.Id, c2.Id)
 }
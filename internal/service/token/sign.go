@@ -0,0 +1,49 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package token
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 使用当前的签名密钥签发任意的 claims, 用于 Issue 之外的 token, 例如 OpenID Connect 的 ID Token
+func Sign(state State, claims jwt.Claims) (string, error) {
+	key, err := signingKey(state)
+
+	if err != nil {
+		return "", err
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+
+	t.Header["kid"] = key.Id
+
+	return t.SignedString(key.PrivateKey)
+}
+
+// 验证 Sign 签发的 token, 并且解析到 claims 中
+func Verify(tokenString string, state State, claims jwt.Claims) error {
+	t, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, exception.InvalidToken
+		}
+
+		kid, _ := t.Header["kid"].(string)
+
+		return verifyKey(state, kid)
+	})
+
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return exception.TokenExpired
+		}
+
+		return exception.InvalidToken
+	}
+
+	if !t.Valid {
+		return exception.InvalidToken
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package token_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	defer token.SetKeys(nil)
+
+	tokenString, err := token.Sign(token.StateOidc, jwt.StandardClaims{
+		Subject:   "123",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, getKid(t, tokenString))
+
+	claims := jwt.StandardClaims{}
+
+	assert.Nil(t, token.Verify(tokenString, token.StateOidc, &claims))
+	assert.Equal(t, "123", claims.Subject)
+
+	// 其他身份的密钥不能用来验证
+	assert.Equal(t, exception.InvalidToken, token.Verify(tokenString, token.StateUser, &jwt.StandardClaims{}))
+
+	// 过期
+	expired, err := token.Sign(token.StateOidc, jwt.StandardClaims{
+		Subject:   "123",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, exception.TokenExpired, token.Verify(expired, token.StateOidc, &jwt.StandardClaims{}))
+
+	// 被篡改
+	assert.Equal(t, exception.InvalidToken, token.Verify(tokenString+"a", token.StateOidc, &jwt.StandardClaims{}))
+}
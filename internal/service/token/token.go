@@ -12,11 +12,13 @@ const (
 
 type Claims struct {
 	Uid string `json:"uid"`
+	Sid string `json:"sid"` // 所属的会话ID, 刷新 token 之后会话ID不变
 	jwt.StandardClaims
 }
 
 type ClaimsInternal struct {
 	Uid string `json:"uid"` // base64 encode
+	Sid string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
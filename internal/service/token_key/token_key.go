@@ -0,0 +1,185 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// token 签名密钥的存储与轮换
+// 密钥保存在数据库中, 每个实例定时加载. 新的密钥先发布, 等所有实例都加载之后才开始签发,
+// 被替换的密钥继续保留一段时间, 用于验证之前签发的 token
+package token_key
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/token"
+	"github.com/jinzhu/gorm"
+	"log"
+	"time"
+)
+
+const (
+	ReloadInterval  = time.Minute         // 每个实例重新加载密钥的间隔
+	ActivationDelay = 2 * ReloadInterval  // 新的密钥发布之后多久开始签发
+	Retention       = time.Hour * 24 * 30 // 密钥被替换之后保留多久, 不能小于 token 的最长有效期
+	lockKey         = 0x746f6b656e5f6b65  // 轮换密钥时使用的 advisory lock, 防止多个实例同时轮换
+)
+
+var States = []token.State{token.StateUser, token.StateAdmin, token.StateOidc}
+
+// 从数据库加载所有的密钥, 如果还没有可用的密钥则先生成
+// 没有设置 TOKEN_KEY_ENCRYPTION_KEY 或者某种身份没有正在签发的密钥时返回错误, 服务不会启动
+func Load(db *gorm.DB) error {
+	if _, err := rotate(db, time.Now(), false); err != nil {
+		return err
+	}
+
+	list := make([]model.TokenKey, 0)
+
+	if err := db.Order("activated_at ASC").Find(&list).Error; err != nil {
+		return err
+	}
+
+	keys := make([]token.Key, 0, len(list))
+
+	for _, k := range list {
+		key, err := token.ParseKey(k.Id, token.State(k.State), k.PrivateKey, k.ActivatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		// 旧版本保存的明文私钥, 加密之后重新保存
+		if !token.IsEncrypted(k.PrivateKey) {
+			privateKey, err := token.MarshalKey(key)
+
+			if err != nil {
+				return err
+			}
+
+			if token.IsEncrypted(privateKey) {
+				if err := db.Model(&model.TokenKey{}).Where("id = ?", k.Id).Update("private_key", privateKey).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	token.SetKeys(keys)
+
+	return token.CheckKeys(States...)
+}
+
+// 定时重新加载密钥, 其他实例轮换的密钥可以及时生效
+func Watch(db *gorm.DB) {
+	go func() {
+		for range time.Tick(ReloadInterval) {
+			if err := Load(db); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+}
+
+// 立即轮换所有身份的密钥, 新的密钥在 ActivationDelay 之后开始签发
+func Rotate(db *gorm.DB) ([]model.TokenKey, error) {
+	return rotate(db, time.Now(), true)
+}
+
+// 轮换已经超过轮换周期的密钥
+func RotateExpired(db *gorm.DB, now time.Time) ([]model.TokenKey, error) {
+	return rotate(db, now, false)
+}
+
+func rotate(db *gorm.DB, now time.Time, force bool) (created []model.TokenKey, err error) {
+	var tx *gorm.DB
+
+	defer func() {
+		if tx != nil {
+			if err != nil {
+				_ = tx.Rollback().Error
+			} else {
+				err = tx.Commit().Error
+			}
+		}
+	}()
+
+	tx = db.Begin()
+
+	if err = tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+		return
+	}
+
+	created = make([]model.TokenKey, 0)
+
+	for _, state := range States {
+		latest := model.TokenKey{}
+		exist := true
+
+		if err = tx.Where("state = ?", string(state)).Order("activated_at DESC").First(&latest).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				return
+			}
+
+			err = nil
+			exist = false
+		}
+
+		if exist && !force && latest.ActivatedAt.Add(config.Jwt.KeyRotation).After(now) {
+			continue
+		}
+
+		var key token.Key
+
+		if key, err = token.NewKey(state); err != nil {
+			return
+		}
+
+		var privateKey string
+
+		if privateKey, err = token.MarshalKey(key); err != nil {
+			return
+		}
+
+		k := model.TokenKey{
+			Id:          key.Id,
+			State:       string(state),
+			PrivateKey:  privateKey,
+			ActivatedAt: now,
+		}
+
+		// 已经有密钥在签发时, 新的密钥需要等所有实例都加载之后才开始签发
+		if exist {
+			k.ActivatedAt = now.Add(ActivationDelay)
+		}
+
+		if err = tx.Create(&k).Error; err != nil {
+			return
+		}
+
+		created = append(created, k)
+
+		if err = prune(tx, state, now); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// 删除被替换超过 Retention 的密钥
+func prune(tx *gorm.DB, state token.State, now time.Time) error {
+	list := make([]model.TokenKey, 0)
+
+	if err := tx.Where("state = ?", string(state)).Order("activated_at ASC").Find(&list).Error; err != nil {
+		return err
+	}
+
+	for i := 0; i < len(list)-1; i++ {
+		// 下一个密钥开始签发的时间, 即当前密钥被替换的时间
+		if list[i+1].ActivatedAt.Add(Retention).Before(now) {
+			if err := tx.Delete(&model.TokenKey{Id: list[i].Id}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package totp
+
+import (
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// 设置双重身份认证的帐号
+// 用户和管理员的开启, 关闭, 恢复码等流程完全相同, 控制器只负责读取对应的 model
+type Account struct {
+	Model   interface{} // &model.User{} 或者 &model.Admin{}, 用于更新对应的表
+	Id      string      // 用户ID 或者 管理员ID
+	Name    string      // 显示在身份验证器上的帐号名
+	Secret  string      // 当前的密钥
+	Enabled bool        // 是否已经开启
+}
+
+// 身份验证器扫码需要的信息
+type Enrollment struct {
+	Secret string
+	URI    string
+	QRCode string
+}
+
+func (a Account) update(tx *gorm.DB, values map[string]interface{}) error {
+	return tx.Model(a.Model).Where("id = ?", a.Id).Updates(values).Error
+}
+
+// 开始设置双重身份认证, 生成新的密钥
+// 需要用身份验证器扫码之后, 再调用 Enable 确认开启
+func (a Account) Enroll(tx *gorm.DB) (*Enrollment, error) {
+	if a.Enabled {
+		return nil, exception.TOTPAlreadyEnabled
+	}
+
+	secret, err := util.Generate2FASecret(a.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e := Enrollment{
+		Secret: secret,
+		URI:    util.Generate2FAURI(secret, a.Name),
+	}
+
+	if e.QRCode, err = util.Generate2FAQRCode(e.URI); err != nil {
+		return nil, err
+	}
+
+	if err := a.update(tx, map[string]interface{}{"secret": secret}); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// 用第一个动态密码确认开启双重身份认证, 返回恢复码的明文
+func (a Account) Enable(tx *gorm.DB, code string) ([]string, error) {
+	if a.Enabled {
+		return nil, exception.TOTPAlreadyEnabled
+	}
+
+	if err := Verify(tx, a.Model, a.Id, a.Secret, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := GenerateRecoveryCodes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.update(tx, map[string]interface{}{
+		"enable_totp":    true,
+		"recovery_codes": pq.StringArray(hashes),
+	}); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// 关闭双重身份认证, 需要提供动态密码或者恢复码
+func (a Account) Disable(tx *gorm.DB, code string, recoveryCode string) error {
+	if !a.Enabled {
+		return exception.TOTPNotEnabled
+	}
+
+	if err := Verify(tx, a.Model, a.Id, a.Secret, code, recoveryCode); err != nil {
+		return err
+	}
+
+	// 同时更换密钥, 旧的身份验证器不再有效
+	secret, err := util.Generate2FASecret(a.Id)
+
+	if err != nil {
+		return err
+	}
+
+	return a.update(tx, map[string]interface{}{
+		"enable_totp":    false,
+		"secret":         secret,
+		"recovery_codes": gorm.Expr("NULL"),
+	})
+}
+
+// 重新生成恢复码, 旧的恢复码全部失效, 返回新的恢复码的明文
+func (a Account) RegenerateRecoveryCodes(tx *gorm.DB, code string) ([]string, error) {
+	if !a.Enabled {
+		return nil, exception.TOTPNotEnabled
+	}
+
+	if err := Verify(tx, a.Model, a.Id, a.Secret, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := GenerateRecoveryCodes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.update(tx, map[string]interface{}{"recovery_codes": pq.StringArray(hashes)}); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// 登陆的第二步, 校验挑战的动态密码或者恢复码
+// 失败时记录挑战的尝试次数, 通过之后销毁挑战, 保证每个挑战只能成功使用一次
+func (c *Challenge) Verify(tx *gorm.DB, a *Account, code string, recoveryCode string) error {
+	// 在登陆期间关闭了双重身份认证, 需要重新登陆
+	if !a.Enabled {
+		return exception.TOTPChallengeExpired
+	}
+
+	if err := Verify(tx, a.Model, a.Id, a.Secret, code, recoveryCode); err != nil {
+		if err == exception.InvalidTOTPCode {
+			if er := c.Fail(); er != nil {
+				return er
+			}
+		}
+		return err
+	}
+
+	return c.Done()
+}
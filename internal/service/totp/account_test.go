@@ -0,0 +1,56 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package totp_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAccount_State(t *testing.T) {
+	enabled := totp.Account{Model: &model.User{}, Id: util.GenerateId(), Enabled: true}
+	disabled := totp.Account{Model: &model.User{}, Id: util.GenerateId()}
+
+	// 状态不对时不会读写数据库
+	_, err := enabled.Enroll(nil)
+	assert.Equal(t, exception.TOTPAlreadyEnabled, err)
+
+	_, err = enabled.Enable(nil, "123456")
+	assert.Equal(t, exception.TOTPAlreadyEnabled, err)
+
+	assert.Equal(t, exception.TOTPNotEnabled, disabled.Disable(nil, "123456", ""))
+
+	_, err = disabled.RegenerateRecoveryCodes(nil, "123456")
+	assert.Equal(t, exception.TOTPNotEnabled, err)
+}
+
+func TestChallenge_Verify(t *testing.T) {
+	uid := util.GenerateId()
+	account := guard.Account(guard.ScopeUser, "test-"+util.RandomString(8))
+
+	c, err := totp.NewChallenge(uid, false, time.Hour, model.LoginLogTypeUserName, account)
+
+	assert.Nil(t, err)
+
+	// 登陆期间关闭了双重身份认证
+	assert.Equal(t, exception.TOTPChallengeExpired, c.Verify(nil, &totp.Account{Model: &model.User{}, Id: uid}, "", ""))
+
+	a := totp.Account{Model: &model.User{}, Id: uid, Enabled: true}
+
+	for i := int64(1); i < config.TOTP.ChallengeAttempts; i++ {
+		assert.Equal(t, exception.InvalidTOTPCode, c.Verify(nil, &a, "", ""))
+	}
+
+	// 尝试次数用完之后挑战失效
+	assert.Equal(t, exception.InvalidTOTPCode, c.Verify(nil, &a, "", ""))
+
+	_, err = totp.GetChallenge(c.Id, false)
+
+	assert.Equal(t, exception.TOTPChallengeExpired, err)
+}
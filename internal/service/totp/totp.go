@@ -0,0 +1,244 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 双重身份认证 (TOTP)
+// 用户和管理员共用同一套逻辑, 包括登陆时的二次验证, 以及一次性的恢复码
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/guard"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/jinzhu/gorm"
+)
+
+// 恢复码使用的字符, 去掉了容易混淆的 0/o 1/l
+const recoveryCodeLetters = "23456789abcdefghijkmnpqrstuvwxyz"
+
+// 登陆时的二次验证
+// 用户通过第一步验证 (密码/验证码等) 之后拿到挑战ID, 再用挑战ID + 动态密码换取身份令牌
+type Challenge struct {
+	Id        string             `json:"id"`         // 挑战ID
+	Uid       string             `json:"uid"`        // 用户ID 或者 管理员ID
+	IsAdmin   bool               `json:"is_admin"`   // 是否是管理员的挑战
+	Duration  time.Duration      `json:"duration"`   // 验证通过之后生成的身份令牌的有效期
+	Type      model.LoginLogType `json:"type"`       // 第一步使用的登陆方式, 用于写入登陆记录
+	Account   guard.Target       `json:"account"`    // 第一步使用的帐号, 第二步的失败次数计入这个帐号
+	ExpiredAt time.Time          `json:"expired_at"` // 挑战的过期时间
+}
+
+// 生成不可预测的随机字符串
+func randomString(letters string, length int) (string, error) {
+	b := make([]byte, length)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = letters[int(b[i])%len(letters)]
+	}
+
+	return string(b), nil
+}
+
+func attemptKey(id string) string {
+	return "attempt:" + id
+}
+
+func usedKey(uid string, code string) string {
+	return "used:" + uid + ":" + code
+}
+
+// 创建一个登陆挑战
+func NewChallenge(uid string, isAdmin bool, duration time.Duration, loginType model.LoginLogType, account guard.Target) (*Challenge, error) {
+	id, err := randomString(recoveryCodeLetters, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := Challenge{
+		Id:        id,
+		Uid:       uid,
+		IsAdmin:   isAdmin,
+		Duration:  duration,
+		Type:      loginType,
+		Account:   account,
+		ExpiredAt: time.Now().Add(config.TOTP.ChallengeTTL),
+	}
+
+	b, err := json.Marshal(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := redis.ClientTOTPChallenge.Set(context.Background(), c.Id, string(b), config.TOTP.ChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// 获取登陆挑战, 用户的挑战不能用于管理员登陆, 反之亦然
+func GetChallenge(id string, isAdmin bool) (*Challenge, error) {
+	value, err := redis.ClientTOTPChallenge.Get(context.Background(), id).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return nil, exception.TOTPChallengeExpired
+		}
+		return nil, err
+	}
+
+	c := Challenge{}
+
+	if err := json.Unmarshal([]byte(value), &c); err != nil {
+		return nil, err
+	}
+
+	if c.IsAdmin != isAdmin {
+		return nil, exception.TOTPChallengeExpired
+	}
+
+	return &c, nil
+}
+
+// 记录一次失败的尝试, 超过次数之后挑战失效, 需要重新登陆
+func (c *Challenge) Fail() error {
+	ctx := context.Background()
+
+	n, err := redis.ClientTOTPChallenge.Incr(ctx, attemptKey(c.Id)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 1 {
+		_ = redis.ClientTOTPChallenge.Expire(ctx, attemptKey(c.Id), config.TOTP.ChallengeTTL).Err()
+	}
+
+	if n >= config.TOTP.ChallengeAttempts {
+		return c.Done()
+	}
+
+	return nil
+}
+
+// 验证通过之后销毁挑战, 保证每个挑战只能成功使用一次
+func (c *Challenge) Done() error {
+	ctx := context.Background()
+
+	n, err := redis.ClientTOTPChallenge.Del(ctx, c.Id).Result()
+
+	if err != nil {
+		return err
+	}
+
+	_ = redis.ClientTOTPChallenge.Del(ctx, attemptKey(c.Id)).Err()
+
+	// 已经被其他请求使用了
+	if n != 1 {
+		return exception.TOTPChallengeExpired
+	}
+
+	return nil
+}
+
+// 校验动态密码, 同一个动态密码在有效期内只能使用一次, 防止被截获之后重放
+func VerifyCode(uid string, secret string, code string) (bool, error) {
+	if !util.Verify2FA(secret, code) {
+		return false, nil
+	}
+
+	// 动态密码允许前后一个周期的误差, 所以最长在 90 秒内有效
+	ok, err := redis.ClientTOTPChallenge.SetNX(context.Background(), usedKey(uid, code), 1, time.Second*90).Result()
+
+	if err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// 生成一组新的恢复码, 返回明文 (只展示给用户一次) 和用于存储的 hash
+func GenerateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < config.TOTP.RecoveryCodeCount; i++ {
+		var code string
+
+		if code, err = randomString(recoveryCodeLetters, 10); err != nil {
+			return nil, nil, err
+		}
+
+		code = code[:5] + "-" + code[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return
+}
+
+// 计算恢复码的 hash, 忽略大小写, 空格和分隔符
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// 使用一个恢复码, 使用之后从列表中移除
+// m 为 &model.User{} 或者 &model.Admin{}
+// 用带条件的更新保证并发时同一个恢复码只能被使用一次
+func UseRecoveryCode(tx *gorm.DB, m interface{}, id string, code string) (bool, error) {
+	hash := HashRecoveryCode(code)
+
+	result := tx.Model(m).
+		Where("id = ? AND ? = ANY(recovery_codes)", id, hash).
+		UpdateColumn("recovery_codes", gorm.Expr("array_remove(recovery_codes, ?)", hash))
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// 校验动态密码或者恢复码, 两者提供其一即可, 恢复码使用之后立即失效
+// m 为 &model.User{} 或者 &model.Admin{}
+func Verify(tx *gorm.DB, m interface{}, id string, secret string, code string, recoveryCode string) error {
+	if len(code) != 0 {
+		if ok, err := VerifyCode(id, secret, code); err != nil {
+			return err
+		} else if !ok {
+			return exception.InvalidTOTPCode
+		}
+
+		return nil
+	}
+
+	if len(recoveryCode) != 0 {
+		if ok, err := UseRecoveryCode(tx, m, id, recoveryCode); err != nil {
+			return err
+		} else if !ok {
+			return exception.InvalidTOTPCode
+		}
+
+		return nil
+	}
+
+	return exception.InvalidTOTPCode
+}
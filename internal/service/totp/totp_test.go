@@ -0,0 +1,35 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package totp_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/service/totp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+
+	assert.Nil(t, err)
+	assert.Len(t, codes, config.TOTP.RecoveryCodeCount)
+	assert.Len(t, hashes, config.TOTP.RecoveryCodeCount)
+
+	exist := map[string]bool{}
+
+	for i, code := range codes {
+		assert.Len(t, code, 11)
+		assert.Equal(t, byte('-'), code[5])
+		assert.Equal(t, totp.HashRecoveryCode(code), hashes[i])
+		assert.Len(t, hashes[i], 64)
+		assert.False(t, exist[code])
+		exist[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	// 忽略大小写, 空格和分隔符
+	assert.Equal(t, totp.HashRecoveryCode("abcde-fghjk"), totp.HashRecoveryCode("ABCDEFGHJK"))
+	assert.Equal(t, totp.HashRecoveryCode("abcde-fghjk"), totp.HashRecoveryCode(" abcde fghjk "))
+	assert.NotEqual(t, totp.HashRecoveryCode("abcde-fghjk"), totp.HashRecoveryCode("abcde-fghjm"))
+}
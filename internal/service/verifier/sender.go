@@ -0,0 +1,43 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package verifier
+
+import (
+	"github.com/axetroy/go-server/internal/service/email"
+	"github.com/axetroy/go-server/internal/service/telephone"
+)
+
+// 通过邮件发送, 根据用途选择邮件模版
+type emailSender struct{}
+
+func (emailSender) Send(target Target, purpose Purpose, code string) error {
+	e, err := email.NewMailer()
+
+	if err != nil {
+		return err
+	}
+
+	switch purpose {
+	case PurposeResetPassword:
+		return e.SendForgotPasswordEmail(target.Address, code)
+	case PurposeResetPayPassword:
+		return e.SendForgotTradePasswordEmail(target.Address, code)
+	default:
+		return e.SendAuthEmail(target.Address, code)
+	}
+}
+
+// 通过短信发送, 根据用途选择短信模版
+type phoneSender struct{}
+
+func (phoneSender) Send(target Target, purpose Purpose, code string) error {
+	client := telephone.GetClient()
+
+	switch purpose {
+	case PurposeRegister:
+		return client.SendRegisterCode(target.Address, code)
+	case PurposeResetPassword, PurposeResetPayPassword:
+		return client.SendResetPasswordCode(target.Address, code)
+	default:
+		return client.SendAuthCode(target.Address, code)
+	}
+}
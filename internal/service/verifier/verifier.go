@@ -0,0 +1,254 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 手机/邮箱验证码
+// 验证码按用途和接收方分别存放在 redis 中, 有效期内只能尝试有限的次数, 验证通过之后立即失效
+// 同一个用途和接收方需要间隔一段时间才能重新发送, 重新发送之后旧的验证码失效
+package verifier
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"math/big"
+	"sync"
+)
+
+// 验证码的用途, 不同用途的验证码不能混用
+type Purpose string
+
+const (
+	PurposeRegister         Purpose = "register"     // 注册帐号
+	PurposeSignIn           Purpose = "signin"       // 验证码登陆
+	PurposeResetPassword    Purpose = "reset"        // 重置登陆密码
+	PurposeBind             Purpose = "bind"         // 绑定/解绑手机号和邮箱
+	PurposeResetPayPassword Purpose = "pay_password" // 重置交易密码
+)
+
+// 接收验证码的渠道
+type Channel string
+
+const (
+	ChannelEmail Channel = "email" // 邮箱
+	ChannelPhone Channel = "phone" // 手机短信
+)
+
+// 接收验证码的对象
+type Target struct {
+	Channel Channel
+	Address string // 邮箱地址或者手机号
+}
+
+func Email(address string) Target {
+	return Target{Channel: ChannelEmail, Address: address}
+}
+
+func Phone(phone string) Target {
+	return Target{Channel: ChannelPhone, Address: phone}
+}
+
+func (t Target) key(purpose Purpose) string {
+	return string(purpose) + ":" + string(t.Channel) + ":" + t.Address
+}
+
+func codeKey(t Target, purpose Purpose) string {
+	return "code:" + t.key(purpose)
+}
+
+func attemptKey(t Target, purpose Purpose) string {
+	return "attempt:" + t.key(purpose)
+}
+
+func cooldownKey(t Target, purpose Purpose) string {
+	return "cooldown:" + t.key(purpose)
+}
+
+// 发送验证码的方式
+type Sender interface {
+	Send(target Target, purpose Purpose, code string) error
+}
+
+type SenderFunc func(target Target, purpose Purpose, code string) error
+
+func (f SenderFunc) Send(target Target, purpose Purpose, code string) error {
+	return f(target, purpose, code)
+}
+
+var (
+	mu      sync.RWMutex
+	senders = map[Channel]Sender{
+		ChannelEmail: emailSender{},
+		ChannelPhone: phoneSender{},
+	}
+)
+
+// 替换某个渠道的发送方式, 返回原来的发送方式
+func Register(channel Channel, s Sender) Sender {
+	mu.Lock()
+	defer mu.Unlock()
+
+	old := senders[channel]
+
+	senders[channel] = s
+
+	return old
+}
+
+func getSender(channel Channel) (Sender, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	s, ok := senders[channel]
+
+	return s, ok
+}
+
+// 生成随机的数字验证码
+func Generate() (string, error) {
+	const numbers = "0123456789"
+
+	b := make([]byte, config.VerifyCode.Length)
+
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(numbers))))
+
+		if err != nil {
+			return "", err
+		}
+
+		b[i] = numbers[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// 生成验证码并保存, 但是不发送. 旧的验证码和尝试次数会被清除
+func Issue(target Target, purpose Purpose) (string, error) {
+	code, err := Generate()
+
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+
+	if _, err := redis.ClientVerifyCode.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		pipe.Set(ctx, codeKey(target, purpose), code, config.VerifyCode.TTL)
+		pipe.Del(ctx, attemptKey(target, purpose))
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// 间隔内只有一个请求可以发送
+func cooldown(ctx context.Context, target Target, purpose Purpose) error {
+	if config.VerifyCode.Cooldown <= 0 {
+		return nil
+	}
+
+	ok, err := redis.ClientVerifyCode.SetNX(ctx, cooldownKey(target, purpose), 1, config.VerifyCode.Cooldown).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return exception.SendCodeTooFrequent
+	}
+
+	return nil
+}
+
+// 不需要发送验证码的请求同样记录发送间隔, 和真正发送时的结果保持一致
+func Skip(target Target, purpose Purpose) error {
+	return cooldown(context.Background(), target, purpose)
+}
+
+// 生成验证码并发送给接收方
+func Send(target Target, purpose Purpose) error {
+	s, ok := getSender(target.Channel)
+
+	if !ok || target.Address == "" {
+		return exception.InvalidParams
+	}
+
+	ctx := context.Background()
+
+	if err := cooldown(ctx, target, purpose); err != nil {
+		return err
+	}
+
+	code, err := Issue(target, purpose)
+
+	if err != nil {
+		_ = redis.ClientVerifyCode.Del(ctx, cooldownKey(target, purpose)).Err()
+		return err
+	}
+
+	if err := s.Send(target, purpose, code); err != nil {
+		// 没有发送出去, 允许立即重新发送
+		_ = redis.ClientVerifyCode.Del(ctx, codeKey(target, purpose), cooldownKey(target, purpose)).Err()
+		return err
+	}
+
+	return nil
+}
+
+// 校验验证码, 通过之后验证码立即失效
+func Verify(target Target, purpose Purpose, code string) error {
+	ctx := context.Background()
+
+	n, err := redis.ClientVerifyCode.Incr(ctx, attemptKey(target, purpose)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 1 {
+		_ = redis.ClientVerifyCode.Expire(ctx, attemptKey(target, purpose), config.VerifyCode.TTL).Err()
+	}
+
+	// 尝试次数用完, 验证码作废
+	if n > config.VerifyCode.Attempts {
+		_ = redis.ClientVerifyCode.Del(ctx, codeKey(target, purpose)).Err()
+		return exception.VerifyCodeExpired
+	}
+
+	value, err := redis.ClientVerifyCode.Get(ctx, codeKey(target, purpose)).Result()
+
+	if err != nil {
+		if err == redis.Nil {
+			return exception.VerifyCodeExpired
+		}
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(value), []byte(code)) != 1 {
+		return exception.InvalidVerifyCode
+	}
+
+	// 并发的请求只有一个可以删除成功
+	deleted, err := redis.ClientVerifyCode.Del(ctx, codeKey(target, purpose)).Result()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return exception.VerifyCodeExpired
+	}
+
+	_ = redis.ClientVerifyCode.Del(ctx, attemptKey(target, purpose)).Err()
+
+	return nil
+}
+
+// 是否是验证码不正确或者已失效
+func IsInvalid(err error) bool {
+	return err == exception.InvalidVerifyCode || err == exception.VerifyCodeExpired
+}
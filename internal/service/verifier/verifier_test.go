@@ -0,0 +1,120 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package verifier_test
+
+import (
+	"errors"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/verifier"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	code, err := verifier.Generate()
+
+	assert.Nil(t, err)
+	assert.Len(t, code, config.VerifyCode.Length)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9]+$`), code)
+}
+
+func TestSend(t *testing.T) {
+	var (
+		target = verifier.Email("test-" + util.RandomString(8) + "@example.com")
+		sent   = make(map[verifier.Purpose]string)
+	)
+
+	old := verifier.Register(verifier.ChannelEmail, verifier.SenderFunc(func(to verifier.Target, purpose verifier.Purpose, code string) error {
+		assert.Equal(t, target, to)
+		sent[purpose] = code
+		return nil
+	}))
+
+	defer verifier.Register(verifier.ChannelEmail, old)
+
+	assert.Nil(t, verifier.Send(target, verifier.PurposeSignIn))
+	assert.Len(t, sent[verifier.PurposeSignIn], config.VerifyCode.Length)
+
+	// 间隔内不能重复发送, 其他用途不受影响
+	assert.Equal(t, exception.SendCodeTooFrequent, verifier.Send(target, verifier.PurposeSignIn))
+	assert.Nil(t, verifier.Send(target, verifier.PurposeRegister))
+
+	// 不同用途的验证码不能混用
+	assert.Equal(t, exception.InvalidVerifyCode, verifier.Verify(target, verifier.PurposeRegister, sent[verifier.PurposeSignIn]))
+	assert.Equal(t, exception.VerifyCodeExpired, verifier.Verify(target, verifier.PurposeBind, sent[verifier.PurposeSignIn]))
+
+	// 验证通过之后立即失效
+	assert.Nil(t, verifier.Verify(target, verifier.PurposeSignIn, sent[verifier.PurposeSignIn]))
+	assert.Equal(t, exception.VerifyCodeExpired, verifier.Verify(target, verifier.PurposeSignIn, sent[verifier.PurposeSignIn]))
+
+	assert.Nil(t, verifier.Verify(target, verifier.PurposeRegister, sent[verifier.PurposeRegister]))
+}
+
+func TestSendFail(t *testing.T) {
+	var (
+		target = verifier.Phone(util.RandomNumeric(11))
+		fail   = errors.New("send fail")
+		code   string
+	)
+
+	old := verifier.Register(verifier.ChannelPhone, verifier.SenderFunc(func(to verifier.Target, purpose verifier.Purpose, c string) error {
+		code = c
+		return fail
+	}))
+
+	defer verifier.Register(verifier.ChannelPhone, old)
+
+	assert.Equal(t, fail, verifier.Send(target, verifier.PurposeSignIn))
+
+	// 没有发送出去的验证码不能使用
+	assert.Equal(t, exception.VerifyCodeExpired, verifier.Verify(target, verifier.PurposeSignIn, code))
+
+	// 可以立即重新发送
+	verifier.Register(verifier.ChannelPhone, verifier.SenderFunc(func(to verifier.Target, purpose verifier.Purpose, c string) error {
+		code = c
+		return nil
+	}))
+
+	assert.Nil(t, verifier.Send(target, verifier.PurposeSignIn))
+	assert.Nil(t, verifier.Verify(target, verifier.PurposeSignIn, code))
+}
+
+func TestSkip(t *testing.T) {
+	target := verifier.Phone(util.RandomNumeric(11))
+
+	// 不发送的请求和发送时一样受到间隔的限制
+	assert.Nil(t, verifier.Skip(target, verifier.PurposeSignIn))
+	assert.Equal(t, exception.SendCodeTooFrequent, verifier.Skip(target, verifier.PurposeSignIn))
+	assert.Equal(t, exception.SendCodeTooFrequent, verifier.Send(target, verifier.PurposeSignIn))
+	assert.Equal(t, exception.VerifyCodeExpired, verifier.Verify(target, verifier.PurposeSignIn, "000000"))
+}
+
+func TestVerify(t *testing.T) {
+	target := verifier.Email("test-" + util.RandomString(8) + "@example.com")
+
+	code, err := verifier.Issue(target, verifier.PurposeBind)
+
+	assert.Nil(t, err)
+
+	for i := int64(0); i < config.VerifyCode.Attempts-1; i++ {
+		assert.Equal(t, exception.InvalidVerifyCode, verifier.Verify(target, verifier.PurposeBind, "invalid"))
+	}
+
+	// 重新生成之后, 旧的验证码失效, 并且重新计算尝试次数
+	newCode, err := verifier.Issue(target, verifier.PurposeBind)
+
+	assert.Nil(t, err)
+
+	if newCode != code {
+		assert.Equal(t, exception.InvalidVerifyCode, verifier.Verify(target, verifier.PurposeBind, code))
+	}
+
+	for i := int64(0); i < config.VerifyCode.Attempts-1; i++ {
+		assert.Equal(t, exception.InvalidVerifyCode, verifier.Verify(target, verifier.PurposeBind, "invalid"))
+	}
+
+	// 尝试次数用完之后, 正确的验证码也不能使用
+	assert.Equal(t, exception.VerifyCodeExpired, verifier.Verify(target, verifier.PurposeBind, newCode))
+}
@@ -0,0 +1,345 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 通行密钥 (WebAuthn)
+// 用户使用通行密钥直接登陆, 管理员使用通行密钥作为登陆的第二步验证
+// 注册/认证的挑战存放在 redis 中, 每个挑战只能使用一次
+package webauthn
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/axetroy/go-server/internal/library/config"
+	"github.com/axetroy/go-server/internal/library/exception"
+	"github.com/axetroy/go-server/internal/model"
+	"github.com/axetroy/go-server/internal/service/redis"
+	nativeWebAuthn "github.com/axetroy/go-server/pkg/webauthn"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type Purpose string
+
+const (
+	PurposeRegister     Purpose = "register" // 注册凭证
+	PurposeLogin        Purpose = "login"    // 用户使用通行密钥登陆
+	PurposeSecondFactor Purpose = "2fa"      // 管理员登陆的第二步验证
+)
+
+// 每个帐号最多可以注册多少个凭证
+const MaxCredentials = 10
+
+// 进行中的注册/认证
+type session struct {
+	Purpose Purpose `json:"purpose"`
+	Uid     string  `json:"uid"` // 发起的帐号, 用户使用通行密钥登陆时为空, 由凭证决定是哪个帐号
+	IsAdmin bool    `json:"is_admin"`
+}
+
+// 当前配置的依赖方
+func RelyingParty() nativeWebAuthn.RelyingParty {
+	return nativeWebAuthn.RelyingParty{
+		Id:      config.WebAuthn.GetRpId(),
+		Name:    config.WebAuthn.RpName,
+		Origins: config.WebAuthn.GetOrigins(),
+	}
+}
+
+func sessionKey(purpose Purpose, challenge []byte) string {
+	return string(purpose) + ":" + nativeWebAuthn.Bytes(challenge).String()
+}
+
+func saveSession(challenge []byte, s session) error {
+	b, err := json.Marshal(s)
+
+	if err != nil {
+		return err
+	}
+
+	return redis.ClientWebAuthn.Set(context.Background(), sessionKey(s.Purpose, challenge), string(b), config.WebAuthn.Timeout).Err()
+}
+
+// 取出挑战对应的会话, 读取之后立即删除, 保证只能使用一次
+func takeSession(purpose Purpose, clientDataJSON []byte) (*session, []byte, error) {
+	clientData, err := nativeWebAuthn.ParseClientData(clientDataJSON)
+
+	if err != nil || len(clientData.Challenge) == 0 {
+		return nil, nil, exception.InvalidWebAuthnCredential
+	}
+
+	var (
+		ctx = context.Background()
+		key = sessionKey(purpose, clientData.Challenge)
+		get *nativeRedis.StringCmd
+	)
+
+	if _, err := redis.ClientWebAuthn.TxPipelined(ctx, func(pipe nativeRedis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	}); err != nil {
+		if err == redis.Nil {
+			err = exception.WebAuthnChallengeExpired
+		}
+		return nil, nil, err
+	}
+
+	s := session{}
+
+	if err := json.Unmarshal([]byte(get.Val()), &s); err != nil {
+		return nil, nil, err
+	}
+
+	return &s, clientData.Challenge, nil
+}
+
+// 帐号已注册的凭证
+func Credentials(db *gorm.DB, uid string, isAdmin bool) ([]model.WebAuthnCredential, error) {
+	list := make([]model.WebAuthnCredential, 0)
+
+	if err := db.Where("uid = ? AND is_admin = ?", uid, isAdmin).Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func descriptors(list []model.WebAuthnCredential) []nativeWebAuthn.CredentialDescriptor {
+	result := make([]nativeWebAuthn.CredentialDescriptor, 0, len(list))
+
+	for _, c := range list {
+		id, err := nativeWebAuthn.DecodeString(c.CredentialId)
+
+		if err != nil {
+			continue
+		}
+
+		result = append(result, nativeWebAuthn.CredentialDescriptor{
+			Type:       nativeWebAuthn.CredentialType,
+			Id:         id,
+			Transports: c.Transports,
+		})
+	}
+
+	return result
+}
+
+// 开始注册, 返回给前端调用 navigator.credentials.create() 的参数
+// 已注册的凭证会被排除, 避免同一个认证器重复注册
+func BeginRegistration(db *gorm.DB, uid string, isAdmin bool, name string, displayName string) (*nativeWebAuthn.CreationOptions, error) {
+	list, err := Credentials(db, uid, isAdmin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) >= MaxCredentials {
+		return nil, exception.InvalidParams.New("最多只能注册 10 个通行密钥")
+	}
+
+	user := nativeWebAuthn.UserEntity{Id: []byte(uid), Name: name, DisplayName: displayName}
+
+	options, err := RelyingParty().NewCreationOptions(user, descriptors(list), config.WebAuthn.Timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveSession(options.Challenge, session{Purpose: PurposeRegister, Uid: uid, IsAdmin: isAdmin}); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// 完成注册, 校验通过之后保存凭证
+func FinishRegistration(db *gorm.DB, uid string, isAdmin bool, name string, res nativeWebAuthn.AttestationResponse) (*model.WebAuthnCredential, error) {
+	s, challenge, err := takeSession(PurposeRegister, res.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// 挑战只能由发起注册的帐号使用
+	if s.Uid != uid || s.IsAdmin != isAdmin {
+		return nil, exception.WebAuthnChallengeExpired
+	}
+
+	credential, err := RelyingParty().VerifyRegistration(res, challenge, false)
+
+	if err != nil {
+		return nil, exception.InvalidWebAuthnCredential
+	}
+
+	credentialId := nativeWebAuthn.Bytes(credential.Id).String()
+
+	var count int
+
+	if err := db.Model(model.WebAuthnCredential{}).Where("credential_id = ?", credentialId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count != 0 {
+		return nil, exception.WebAuthnCredentialExist
+	}
+
+	info := model.WebAuthnCredential{
+		Uid:          uid,
+		IsAdmin:      isAdmin,
+		Name:         name,
+		CredentialId: credentialId,
+		PublicKey:    credential.PublicKey,
+		Algorithm:    credential.Algorithm,
+		SignCount:    int64(credential.SignCount),
+		AAGUID:       hex.EncodeToString(credential.AAGUID),
+		Transports:   credential.Transports,
+	}
+
+	if err := db.Create(&info).Error; err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// 开始认证, 返回给前端调用 navigator.credentials.get() 的参数
+// uid 为空时不限制凭证, 由用户在认证器上选择可发现的凭证 (passkey)
+func BeginLogin(db *gorm.DB, purpose Purpose, uid string, isAdmin bool) (*nativeWebAuthn.RequestOptions, error) {
+	var allow []nativeWebAuthn.CredentialDescriptor
+
+	if uid != "" {
+		list, err := Credentials(db, uid, isAdmin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) == 0 {
+			return nil, exception.WebAuthnCredentialNotExist
+		}
+
+		allow = descriptors(list)
+	}
+
+	options, err := RelyingParty().NewRequestOptions(allow, config.WebAuthn.Timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveSession(options.Challenge, session{Purpose: purpose, Uid: uid, IsAdmin: isAdmin}); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// 完成认证, 返回使用的凭证, 以及是否经过了用户验证 (PIN/生物识别)
+// 校验通过之后更新签名计数器
+func FinishLogin(db *gorm.DB, purpose Purpose, isAdmin bool, res nativeWebAuthn.AssertionResponse) (*model.WebAuthnCredential, bool, error) {
+	s, challenge, err := takeSession(purpose, res.Response.ClientDataJSON)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if s.IsAdmin != isAdmin {
+		return nil, false, exception.WebAuthnChallengeExpired
+	}
+
+	info := model.WebAuthnCredential{}
+
+	if err := db.Where("credential_id = ? AND is_admin = ?", nativeWebAuthn.Bytes(res.RawId).String(), isAdmin).First(&info).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.WebAuthnCredentialNotExist
+		}
+		return nil, false, err
+	}
+
+	// 挑战指定了帐号, 只能使用该帐号的凭证
+	if s.Uid != "" && s.Uid != info.Uid {
+		return nil, false, exception.InvalidWebAuthnCredential
+	}
+
+	// 认证器返回的用户句柄必须和凭证的所有者一致
+	if len(res.Response.UserHandle) != 0 && string(res.Response.UserHandle) != info.Uid {
+		return nil, false, exception.InvalidWebAuthnCredential
+	}
+
+	signCount, err := RelyingParty().VerifyAssertion(res, challenge, info.PublicKey, uint32(info.SignCount), false)
+
+	if err != nil {
+		return nil, false, exception.InvalidWebAuthnCredential
+	}
+
+	authData, err := nativeWebAuthn.ParseAuthenticatorData(res.Response.AuthenticatorData)
+
+	if err != nil {
+		return nil, false, exception.InvalidWebAuthnCredential
+	}
+
+	now := time.Now()
+
+	// 只有计数器没有被其他请求更新过才能成功, 防止同一个签名被并发使用
+	result := db.Model(&info).Where("sign_count = ?", info.SignCount).Updates(map[string]interface{}{
+		"sign_count":   int64(signCount),
+		"last_used_at": now,
+	})
+
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, false, exception.InvalidWebAuthnCredential
+	}
+
+	info.SignCount = int64(signCount)
+	info.LastUsedAt = &now
+
+	return &info, authData.UserVerified(), nil
+}
+
+// 获取帐号的某个凭证
+func Get(db *gorm.DB, uid string, isAdmin bool, id string) (*model.WebAuthnCredential, error) {
+	info := model.WebAuthnCredential{}
+
+	if err := db.Where("id = ? AND uid = ? AND is_admin = ?", id, uid, isAdmin).First(&info).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = exception.WebAuthnCredentialNotExist
+		}
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// 重命名凭证
+func Rename(db *gorm.DB, uid string, isAdmin bool, id string, name string) (*model.WebAuthnCredential, error) {
+	info, err := Get(db, uid, isAdmin, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(info).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// 删除凭证, 删除之后对应的认证器不能再用于登陆
+func Delete(db *gorm.DB, uid string, isAdmin bool, id string) (*model.WebAuthnCredential, error) {
+	info, err := Get(db, uid, isAdmin, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Delete(info).Error; err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,358 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// CBOR (RFC 7049) 的最小实现, 只支持 WebAuthn 用到的类型
+// 解码的结果:
+//
+//	无符号整数/负整数 -> int64
+//	字节串 -> []byte
+//	文本串 -> string
+//	数组 -> []interface{}
+//	映射 -> map[interface{}]interface{}
+//	true/false -> bool, null/undefined -> nil
+//	浮点数 -> float64
+//
+// 标签会被忽略, 只返回标签内的值
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	majorUint   = 0
+	majorNegint = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+
+	// 嵌套的最大深度, 防止恶意构造的数据导致栈溢出
+	maxDepth = 16
+)
+
+var (
+	ErrUnexpectedEOF = errors.New("cbor: unexpected end of data")
+	ErrTooDeep       = errors.New("cbor: nesting too deep")
+	ErrIndefinite    = errors.New("cbor: indefinite length is not supported")
+)
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+// 解码一个完整的值, 数据末尾不能有多余的字节
+func Unmarshal(data []byte) (interface{}, error) {
+	v, rest, err := Decode(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("cbor: %d bytes of trailing data", len(rest))
+	}
+
+	return v, nil
+}
+
+// 解码第一个值, 返回剩余的数据
+func Decode(data []byte) (interface{}, []byte, error) {
+	d := decoder{data: data}
+
+	v, err := d.value(0)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return v, data[d.off:], nil
+}
+
+func (d *decoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, ErrUnexpectedEOF
+	}
+
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+
+	return b, nil
+}
+
+// 读取头部, 返回主类型, 附加信息, 以及附加信息表示的数值
+func (d *decoder) head() (byte, byte, uint64, error) {
+	b, err := d.read(1)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		v, err := d.read(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(v[0]), nil
+	case info == 25:
+		v, err := d.read(2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint16(v)), nil
+	case info == 26:
+		v, err := d.read(4)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint32(v)), nil
+	case info == 27:
+		v, err := d.read(8)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, binary.BigEndian.Uint64(v), nil
+	case info == 31:
+		return 0, 0, 0, ErrIndefinite
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrTooDeep
+	}
+
+	major, info, n, err := d.head()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(n), nil
+	case majorNegint:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(n), nil
+	case majorBytes:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case majorText:
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		// 每个元素至少占一个字节
+		if n > uint64(len(d.data)-d.off) {
+			return nil, ErrUnexpectedEOF
+		}
+		list := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case majorMap:
+		if n > uint64(len(d.data)-d.off) {
+			return nil, ErrUnexpectedEOF
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.New("cbor: unsupported map key type")
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := m[k]; ok {
+				return nil, errors.New("cbor: duplicate map key")
+			}
+			m[k] = v
+		}
+		return m, nil
+	case majorTag:
+		return d.value(depth + 1)
+	default:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			return float64(float16(uint16(n))), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case 27:
+			return math.Float64frombits(n), nil
+		default:
+			return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+}
+
+// 半精度浮点数
+func float16(h uint16) float32 {
+	var (
+		sign     = uint32(h>>15) << 31
+		exponent = uint32(h>>10) & 0x1f
+		fraction = uint32(h & 0x3ff)
+	)
+
+	switch exponent {
+	case 0:
+		f := float32(fraction) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | fraction<<13)
+	default:
+		return math.Float32frombits(sign | (exponent+112)<<23 | fraction<<13)
+	}
+}
+
+func appendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(b, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		b = append(b, major|27)
+		return append(b, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func appendInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return appendHead(b, majorNegint, uint64(-1-n))
+	}
+
+	return appendHead(b, majorUint, uint64(n))
+}
+
+// 编码, 映射的 key 按照 RFC 7049 的规范排序 (先比较长度, 再比较字节)
+// 支持 int/int64/uint64/[]byte/string/bool/nil/[]interface{}/map[interface{}]interface{}/map[string]interface{}/map[int]interface{}
+func Marshal(v interface{}) ([]byte, error) {
+	return appendValue(nil, v)
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(b, 0xf6), nil
+	case bool:
+		if t {
+			return append(b, 0xf5), nil
+		}
+		return append(b, 0xf4), nil
+	case int:
+		return appendInt(b, int64(t)), nil
+	case int64:
+		return appendInt(b, t), nil
+	case uint64:
+		return appendHead(b, majorUint, t), nil
+	case []byte:
+		return append(appendHead(b, majorBytes, uint64(len(t))), t...), nil
+	case string:
+		return append(appendHead(b, majorText, uint64(len(t))), t...), nil
+	case []interface{}:
+		b = appendHead(b, majorArray, uint64(len(t)))
+		for _, item := range t {
+			var err error
+			if b, err = appendValue(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(t))
+		for k, item := range t {
+			m[k] = item
+		}
+		return appendValue(b, m)
+	case map[int]interface{}:
+		m := make(map[interface{}]interface{}, len(t))
+		for k, item := range t {
+			m[k] = item
+		}
+		return appendValue(b, m)
+	case map[interface{}]interface{}:
+		type entry struct {
+			key   []byte
+			value interface{}
+		}
+
+		entries := make([]entry, 0, len(t))
+
+		for k, item := range t {
+			key, err := appendValue(nil, k)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{key: key, value: item})
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			if len(entries[i].key) != len(entries[j].key) {
+				return len(entries[i].key) < len(entries[j].key)
+			}
+			return string(entries[i].key) < string(entries[j].key)
+		})
+
+		b = appendHead(b, majorMap, uint64(len(entries)))
+
+		for _, e := range entries {
+			var err error
+			b = append(b, e.key...)
+			if b, err = appendValue(b, e.value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported type %T", v)
+	}
+}
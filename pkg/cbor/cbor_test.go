@@ -0,0 +1,101 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package cbor_test
+
+import (
+	"encoding/hex"
+	"github.com/axetroy/go-server/pkg/cbor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	// RFC 7049 附录 A 的例子
+	cases := []struct {
+		hex   string
+		value interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f93c00", float64(1)},
+		{"fb3ff199999999999a", 1.1},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+	}
+
+	for _, c := range cases {
+		data, _ := hex.DecodeString(c.hex)
+
+		v, err := cbor.Unmarshal(data)
+
+		assert.Nil(t, err, c.hex)
+		assert.Equal(t, c.value, v, c.hex)
+	}
+
+	for _, invalid := range []string{
+		"",                   // 空数据
+		"19",                 // 数据不完整
+		"5f",                 // 不定长
+		"0000",               // 多余的数据
+		"a20102",             // 映射不完整
+		"9bffffffffffffffff", // 超长的数组
+		"a201020103",         // 重复的 key
+	} {
+		data, _ := hex.DecodeString(invalid)
+
+		_, err := cbor.Unmarshal(data)
+
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	value := map[interface{}]interface{}{
+		int64(1):   int64(2),
+		int64(-1):  int64(1),
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": []byte{1, 2, 3},
+		int64(-3):  []interface{}{true, false, nil},
+	}
+
+	data, err := cbor.Marshal(value)
+
+	assert.Nil(t, err)
+
+	decoded, err := cbor.Unmarshal(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[interface{}]interface{}{
+		int64(1):   int64(2),
+		int64(-1):  int64(1),
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": []byte{1, 2, 3},
+		int64(-3):  []interface{}{true, false, nil},
+	}, decoded)
+
+	// 按照规范排序
+	data, err = cbor.Marshal(map[interface{}]interface{}{"aa": int64(1), "b": int64(2), int64(10): int64(3)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a30a0361620262616101", hex.EncodeToString(data))
+
+	// 解码之后剩余的数据
+	v, rest, err := cbor.Decode([]byte{0x01, 0x02})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, []byte{0x02}, rest)
+}
@@ -0,0 +1,64 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package rate_limit
+
+import (
+	"sync"
+	"time"
+)
+
+// 内存中的限流, 只在单个实例中生效
+// 理论到达时间已经过去的 key 额度已经恢复满额, 与不存在没有区别, 定期清理
+type Memory struct {
+	sync.Mutex
+	tat       map[string]time.Time
+	interval  time.Duration // 清理的间隔
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tat:      map[string]time.Time{},
+		interval: time.Minute,
+		now:      time.Now,
+	}
+}
+
+func (m *Memory) Allow(key string, policy Policy) (Result, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := m.now()
+
+	// 在请求中顺便清理, 不需要额外的 goroutine
+	if now.Sub(m.lastSweep) >= m.interval {
+		m.sweep(now)
+	}
+
+	result, tat := gcra(policy, now, m.tat[key])
+
+	if result.Allowed {
+		m.tat[key] = tat
+	}
+
+	return result, nil
+}
+
+// 清理已经空闲的 key
+func (m *Memory) sweep(now time.Time) {
+	for key, tat := range m.tat {
+		if !tat.After(now) {
+			delete(m.tat, key)
+		}
+	}
+
+	m.lastSweep = now
+}
+
+// 当前记录的 key 数量
+func (m *Memory) Len() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return len(m.tat)
+}
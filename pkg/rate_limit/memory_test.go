@@ -0,0 +1,77 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package rate_limit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemory_Allow(t *testing.T) {
+	var (
+		now    = time.Now()
+		m      = NewMemory()
+		policy = Policy{Limit: 10, Period: time.Second, Burst: 3}
+	)
+
+	m.now = func() time.Time { return now }
+
+	// 突发 3 个请求
+	for i := 2; i >= 0; i-- {
+		r, err := m.Allow("a", policy)
+
+		assert.Nil(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 3, r.Limit)
+		assert.Equal(t, i, r.Remaining)
+	}
+
+	r, err := m.Allow("a", policy)
+
+	assert.Nil(t, err)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+	assert.Equal(t, time.Millisecond*100, r.RetryAfter)
+	assert.Equal(t, time.Millisecond*300, r.ResetAfter)
+
+	// 其他的 key 不受影响
+	r, _ = m.Allow("b", policy)
+
+	assert.True(t, r.Allowed)
+
+	// 等待一个间隔之后恢复 1 个额度
+	now = now.Add(time.Millisecond * 100)
+
+	r, _ = m.Allow("a", policy)
+
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+
+	r, _ = m.Allow("a", policy)
+
+	assert.False(t, r.Allowed)
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	var (
+		now    = time.Now()
+		m      = NewMemory()
+		policy = Policy{Limit: 1, Period: time.Second}
+	)
+
+	m.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = m.Allow(key, policy)
+	}
+
+	assert.Equal(t, 3, m.Len())
+
+	// 额度已经恢复的 key 在下一次清理时删除
+	now = now.Add(time.Minute)
+
+	r, _ := m.Allow("d", policy)
+
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 1, m.Len())
+}
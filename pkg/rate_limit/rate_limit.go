@@ -0,0 +1,79 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 限流
+// 使用 GCRA (通用信元速率算法) 实现, 每个 key 只需要存储一个时间 (理论到达时间, TAT)
+// 效果等同于滑动窗口: 长期来看每个周期最多 Limit 个请求, 短时间内最多允许 Burst 个请求的突发
+package rate_limit
+
+import (
+	"time"
+)
+
+// 限流策略
+type Policy struct {
+	Limit  int           // 每个周期允许的请求数
+	Period time.Duration // 周期
+	Burst  int           // 允许突发的请求数, 为 0 则等于 Limit
+}
+
+// 限流的结果
+type Result struct {
+	Allowed    bool          // 是否允许本次请求
+	Limit      int           // 突发的上限
+	Remaining  int           // 剩余可以立即发出的请求数
+	RetryAfter time.Duration // 被限制时, 需要等待多久才能重试
+	ResetAfter time.Duration // 多久之后恢复到满额
+}
+
+type Limiter interface {
+	Allow(key string, policy Policy) (Result, error) // 消耗一次请求的额度
+}
+
+// 每个请求之间的间隔
+func (p Policy) interval() time.Duration {
+	if p.Limit <= 0 {
+		return p.Period
+	}
+
+	return p.Period / time.Duration(p.Limit)
+}
+
+func (p Policy) burst() int {
+	if p.Burst <= 0 {
+		return p.Limit
+	}
+
+	return p.Burst
+}
+
+// GCRA 的计算过程, tat 为上一次记录的理论到达时间, 返回本次的结果和新的理论到达时间
+// 内存和 redis 的实现使用相同的计算过程
+func gcra(policy Policy, now time.Time, tat time.Time) (Result, time.Time) {
+	var (
+		interval = policy.interval()
+		burst    = policy.burst()
+	)
+
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-time.Duration(burst) * interval)
+
+	if now.Before(allowAt) {
+		return Result{
+			Allowed:    false,
+			Limit:      burst,
+			Remaining:  0,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, tat
+	}
+
+	return Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}, newTat
+}
@@ -0,0 +1,88 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package rate_limit
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// 与 gcra 函数相同的计算过程, 在 redis 中原子地执行
+// 时间使用毫秒, 返回 {是否允许, 剩余数量, 重试等待, 恢复满额等待}
+var script = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", new_tat - now)
+
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+// redis 中的限流, 多个实例共享额度
+type Redis struct {
+	client func() redis.Cmdable // redis 客户端在服务启动时才连接, 所以在使用时再获取
+	prefix string
+}
+
+func NewRedis(client func() redis.Cmdable, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r *Redis) Allow(key string, policy Policy) (Result, error) {
+	var (
+		interval = policy.interval().Milliseconds()
+		burst    = policy.burst()
+		now      = time.Now().UnixNano() / int64(time.Millisecond)
+	)
+
+	// 间隔小于 1 毫秒时按照 1 毫秒计算
+	if interval < 1 {
+		interval = 1
+	}
+
+	reply, err := script.Run(context.Background(), r.client(), []string{r.prefix + key}, now, interval, burst).Result()
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	list, ok := reply.([]interface{})
+
+	if !ok || len(list) != 4 {
+		return Result{}, errors.New("invalid rate limit reply")
+	}
+
+	values := make([]int64, len(list))
+
+	for i, v := range list {
+		if values[i], ok = v.(int64); !ok {
+			return Result{}, errors.New("invalid rate limit reply")
+		}
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package rate_limit_test
+
+import (
+	"github.com/axetroy/go-server/internal/library/util"
+	"github.com/axetroy/go-server/internal/service/redis"
+	"github.com/axetroy/go-server/pkg/rate_limit"
+	nativeRedis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRedis_Allow(t *testing.T) {
+	var (
+		limiter = rate_limit.NewRedis(func() nativeRedis.Cmdable { return redis.Client }, "test:rate:")
+		policy  = rate_limit.Policy{Limit: 1, Period: time.Minute, Burst: 2}
+		key     = util.RandomString(8)
+	)
+
+	r, err := limiter.Allow(key, policy)
+
+	assert.Nil(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 2, r.Limit)
+	assert.Equal(t, 1, r.Remaining)
+
+	r, err = limiter.Allow(key, policy)
+
+	assert.Nil(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+
+	r, err = limiter.Allow(key, policy)
+
+	assert.Nil(t, err)
+	assert.False(t, r.Allowed)
+	assert.True(t, r.RetryAfter > time.Second*50 && r.RetryAfter <= time.Minute)
+}
@@ -0,0 +1,202 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"github.com/axetroy/go-server/pkg/cbor"
+	"math/big"
+)
+
+// COSE 算法 (https://www.iana.org/assignments/cose/cose.xhtml#algorithms)
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE 密钥的参数
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // EC2/OKP 的曲线, RSA 的 n
+	coseX   = -2 // EC2/OKP 的 x, RSA 的 e
+	coseY   = -3
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("webauthn: unsupported public key")
+
+// 凭证的公钥
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+func intValue(m map[interface{}]interface{}, key int64) (int64, bool) {
+	v, ok := m[key].(int64)
+	return v, ok
+}
+
+func bytesValue(m map[interface{}]interface{}, key int64) ([]byte, bool) {
+	v, ok := m[key].([]byte)
+	return v, ok
+}
+
+// 解析 COSE 格式的公钥
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	v, err := cbor.Unmarshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePublicKey(v)
+}
+
+func parsePublicKey(v interface{}) (*PublicKey, error) {
+	m, ok := v.(map[interface{}]interface{})
+
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := intValue(m, coseKty)
+	alg, _ := intValue(m, coseAlg)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := intValue(m, coseCrv)
+		x, okX := bytesValue(m, coseX)
+		y, okY := bytesValue(m, coseY)
+
+		if crv != crvP256 || !okX || !okY || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: AlgES256, Key: key}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := intValue(m, coseCrv)
+		x, okX := bytesValue(m, coseX)
+
+		if crv != crvEd25519 || !okX || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, okN := bytesValue(m, coseCrv)
+		e, okE := bytesValue(m, coseX)
+
+		if !okN || !okE || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		return &PublicKey{Algorithm: AlgRS256, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// 校验签名
+func (k *PublicKey) Verify(data []byte, signature []byte) error {
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		sig := ecdsaSignature{}
+
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+			return ErrInvalidSignature
+		}
+
+		hash := sha256.Sum256(data)
+
+		if !ecdsa.Verify(key, hash[:], sig.R, sig.S) {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(data)
+
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+
+	return nil
+}
+
+// 证书中的公钥, 用于 packed 格式的证明
+func certificateKey(der []byte, alg int) (*PublicKey, error) {
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		return nil, ErrInvalidAttestation
+	}
+
+	switch cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 {
+			return nil, ErrInvalidAttestation
+		}
+	case *rsa.PublicKey:
+		if alg != AlgRS256 {
+			return nil, ErrInvalidAttestation
+		}
+	case ed25519.PublicKey:
+		if alg != AlgEdDSA {
+			return nil, ErrInvalidAttestation
+		}
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	return &PublicKey{Algorithm: alg, Key: cert.PublicKey}, nil
+}
+
+// 把 ES256 的公钥编码为 COSE 格式
+func MarshalES256PublicKey(key *ecdsa.PublicKey) ([]byte, error) {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+
+	// 补齐前导 0
+	xb := key.X.Bytes()
+	yb := key.Y.Bytes()
+	copy(x[32-len(xb):], xb)
+	copy(y[32-len(yb):], yb)
+
+	return cbor.Marshal(map[int]interface{}{
+		coseKty: ktyEC2,
+		coseAlg: AlgES256,
+		coseCrv: crvP256,
+		coseX:   x,
+		coseY:   y,
+	})
+}
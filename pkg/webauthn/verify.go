@@ -0,0 +1,302 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"github.com/axetroy/go-server/pkg/cbor"
+)
+
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtension    = 0x80
+)
+
+// 认证器数据
+type AuthenticatorData struct {
+	RpIdHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte      // 只有注册时才有
+	CredentialId []byte      // 只有注册时才有
+	PublicKey    []byte      // COSE 格式的公钥, 只有注册时才有
+	publicKey    interface{} // 解码之后的公钥
+}
+
+func (d *AuthenticatorData) UserPresent() bool {
+	return d.Flags&flagUserPresent != 0
+}
+
+func (d *AuthenticatorData) UserVerified() bool {
+	return d.Flags&flagUserVerified != 0
+}
+
+// 解析认证器数据
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidAuthData
+	}
+
+	d := AuthenticatorData{
+		RpIdHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rest := data[37:]
+
+	if d.Flags&flagAttested != 0 {
+		if len(rest) < 18 {
+			return nil, ErrInvalidAuthData
+		}
+
+		d.AAGUID = rest[:16]
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+
+		if len(rest) < length {
+			return nil, ErrInvalidAuthData
+		}
+
+		d.CredentialId = rest[:length]
+		rest = rest[length:]
+
+		key, remain, err := cbor.Decode(rest)
+
+		if err != nil {
+			return nil, ErrInvalidAuthData
+		}
+
+		d.PublicKey = rest[:len(rest)-len(remain)]
+		d.publicKey = key
+		rest = remain
+	}
+
+	// 扩展数据不做处理, 只检查格式
+	if d.Flags&flagExtension != 0 {
+		_, remain, err := cbor.Decode(rest)
+
+		if err != nil {
+			return nil, ErrInvalidAuthData
+		}
+
+		rest = remain
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrInvalidAuthData
+	}
+
+	return &d, nil
+}
+
+// 校验客户端数据
+func (rp RelyingParty) verifyClientData(raw []byte, typ string, challenge []byte) error {
+	c, err := ParseClientData(raw)
+
+	if err != nil {
+		return err
+	}
+
+	if c.Type != typ {
+		return ErrInvalidClientData
+	}
+
+	if len(challenge) == 0 || subtle.ConstantTimeCompare(c.Challenge, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range rp.Origins {
+		if c.Origin == origin {
+			return nil
+		}
+	}
+
+	return ErrOriginMismatch
+}
+
+// 校验认证器数据中的依赖方和用户标志
+func (rp RelyingParty) verifyAuthenticatorData(d *AuthenticatorData, requireUserVerification bool) error {
+	hash := sha256.Sum256([]byte(rp.Id))
+
+	if !bytes.Equal(d.RpIdHash, hash[:]) {
+		return ErrRpIdMismatch
+	}
+
+	if !d.UserPresent() {
+		return ErrUserNotPresent
+	}
+
+	if requireUserVerification && !d.UserVerified() {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+// 被签名的数据: authenticatorData || sha256(clientDataJSON)
+func signedData(authData []byte, clientDataJSON []byte) []byte {
+	hash := sha256.Sum256(clientDataJSON)
+
+	return append(append([]byte{}, authData...), hash[:]...)
+}
+
+// 校验注册的结果, 成功则返回需要保存的凭证
+func (rp RelyingParty) VerifyRegistration(res AttestationResponse, challenge []byte, requireUserVerification bool) (*Credential, error) {
+	if res.Type != CredentialType {
+		return nil, ErrInvalidType
+	}
+
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, err := cbor.Unmarshal(res.Response.AttestationObject)
+
+	if err != nil {
+		return nil, ErrInvalidAttestation
+	}
+
+	object, ok := v.(map[interface{}]interface{})
+
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+
+	format, _ := object["fmt"].(string)
+	rawAuthData, _ := object["authData"].([]byte)
+	statement, ok := object["attStmt"].(map[interface{}]interface{})
+
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+
+	if authData.CredentialId == nil || !bytes.Equal(authData.CredentialId, res.RawId) {
+		return nil, ErrInvalidAuthData
+	}
+
+	key, err := parsePublicKey(authData.publicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, ErrInvalidAttestation
+		}
+	case "packed":
+		if err := verifyPacked(statement, key, signedData(rawAuthData, res.Response.ClientDataJSON)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	return &Credential{
+		Id:           authData.CredentialId,
+		PublicKey:    authData.PublicKey,
+		Algorithm:    key.Algorithm,
+		SignCount:    authData.SignCount,
+		AAGUID:       authData.AAGUID,
+		Transports:   res.Response.Transports,
+		UserVerified: authData.UserVerified(),
+	}, nil
+}
+
+// packed 格式的证明, 有证书时使用证书的公钥校验, 否则为自证明, 使用凭证的公钥校验
+// 没有配置受信任的根证书, 所以不校验证书链
+func verifyPacked(statement map[interface{}]interface{}, credentialKey *PublicKey, data []byte) error {
+	alg, _ := statement["alg"].(int64)
+	sig, _ := statement["sig"].([]byte)
+
+	if sig == nil {
+		return ErrInvalidAttestation
+	}
+
+	key := credentialKey
+
+	if x5c, ok := statement["x5c"].([]interface{}); ok {
+		if len(x5c) == 0 {
+			return ErrInvalidAttestation
+		}
+
+		der, ok := x5c[0].([]byte)
+
+		if !ok {
+			return ErrInvalidAttestation
+		}
+
+		k, err := certificateKey(der, int(alg))
+
+		if err != nil {
+			return err
+		}
+
+		key = k
+	} else if int(alg) != credentialKey.Algorithm {
+		return ErrInvalidAttestation
+	}
+
+	if err := key.Verify(data, sig); err != nil {
+		return ErrInvalidAttestation
+	}
+
+	return nil
+}
+
+// 校验认证的结果, 成功则返回新的签名计数器, 需要由调用者保存
+func (rp RelyingParty) VerifyAssertion(res AssertionResponse, challenge []byte, publicKey []byte, signCount uint32, requireUserVerification bool) (uint32, error) {
+	if res.Type != CredentialType {
+		return 0, ErrInvalidType
+	}
+
+	if err := rp.verifyClientData(res.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(res.Response.AuthenticatorData)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := key.Verify(signedData(res.Response.AuthenticatorData, res.Response.ClientDataJSON), res.Response.Signature); err != nil {
+		return 0, err
+	}
+
+	// 计数器为 0 表示认证器不支持计数 (例如同步的 passkey), 否则必须递增
+	if (authData.SignCount != 0 || signCount != 0) && authData.SignCount <= signCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.SignCount, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// WebAuthn (https://www.w3.org/TR/webauthn-2/) 依赖方 (服务端) 的实现
+// 只做注册 (attestation) 和认证 (assertion) 两个仪式的校验, 挑战的存储由调用者负责
+// 支持的证明格式: none, packed. 支持的算法: ES256, RS256, EdDSA
+package webauthn
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	CredentialType = "public-key"
+
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+
+	ResidentKeyRequired    = "required"
+	ResidentKeyPreferred   = "preferred"
+	ResidentKeyDiscouraged = "discouraged"
+
+	AttestationNone = "none"
+
+	// 挑战的长度
+	challengeLength = 32
+)
+
+var (
+	ErrInvalidType        = errors.New("webauthn: invalid credential type")
+	ErrInvalidClientData  = errors.New("webauthn: invalid client data")
+	ErrChallengeMismatch  = errors.New("webauthn: challenge mismatch")
+	ErrOriginMismatch     = errors.New("webauthn: origin mismatch")
+	ErrRpIdMismatch       = errors.New("webauthn: rp id hash mismatch")
+	ErrUserNotPresent     = errors.New("webauthn: user not present")
+	ErrUserNotVerified    = errors.New("webauthn: user not verified")
+	ErrInvalidAuthData    = errors.New("webauthn: invalid authenticator data")
+	ErrInvalidAttestation = errors.New("webauthn: invalid attestation")
+	ErrUnsupportedFormat  = errors.New("webauthn: unsupported attestation format")
+	ErrInvalidSignature   = errors.New("webauthn: invalid signature")
+	ErrSignCount          = errors.New("webauthn: sign count did not increase, the authenticator may be cloned")
+)
+
+// base64url 编码的字节, 浏览器端使用 base64url 传递二进制数据
+type Bytes []byte
+
+func (b Bytes) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	raw, err := DecodeString(s)
+
+	if err != nil {
+		return err
+	}
+
+	*b = raw
+
+	return nil
+}
+
+// 解码 base64url 字符串, 兼容带填充和标准 base64 的写法
+func DecodeString(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// 依赖方, 即本服务
+type RelyingParty struct {
+	Id      string   // 依赖方 ID, 通常为域名, 例如 example.com
+	Name    string   // 显示给用户的名称
+	Origins []string // 允许发起请求的页面来源, 例如 https://example.com
+}
+
+type RpEntity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	Id          Bytes  `json:"id"` // 用户句柄, 不应该包含个人信息
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	Id         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey,omitempty"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification,omitempty"`
+}
+
+// 注册的参数, 对应 PublicKeyCredentialCreationOptions, 前端原样传给 navigator.credentials.create()
+type CreationOptions struct {
+	Rp                     RpEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              Bytes                  `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"` // 单位毫秒
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// 认证的参数, 对应 PublicKeyCredentialRequestOptions, 前端原样传给 navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"` // 单位毫秒
+	RpId             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification,omitempty"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    Bytes    `json:"clientDataJSON" validate:"required"`
+	AttestationObject Bytes    `json:"attestationObject" validate:"required"`
+	Transports        []string `json:"transports"`
+}
+
+// 注册时浏览器返回的凭证
+type AttestationResponse struct {
+	Id       string                           `json:"id"`
+	RawId    Bytes                            `json:"rawId" validate:"required"`
+	Type     string                           `json:"type" validate:"required"`
+	Response AuthenticatorAttestationResponse `json:"response" validate:"required"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData Bytes `json:"authenticatorData" validate:"required"`
+	Signature         Bytes `json:"signature" validate:"required"`
+	UserHandle        Bytes `json:"userHandle"`
+}
+
+// 认证时浏览器返回的凭证
+type AssertionResponse struct {
+	Id       string                         `json:"id"`
+	RawId    Bytes                          `json:"rawId" validate:"required"`
+	Type     string                         `json:"type" validate:"required"`
+	Response AuthenticatorAssertionResponse `json:"response" validate:"required"`
+}
+
+// 客户端数据, 由浏览器生成
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   Bytes  `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// 注册成功的凭证, 需要由调用者保存
+type Credential struct {
+	Id           []byte   // 凭证 ID
+	PublicKey    []byte   // COSE 格式的公钥
+	Algorithm    int      // 签名算法
+	SignCount    uint32   // 签名计数器
+	AAGUID       []byte   // 认证器的型号
+	Transports   []string // 认证器支持的传输方式
+	UserVerified bool     // 是否经过用户验证 (PIN/生物识别)
+}
+
+// 支持的算法, 按优先级排列
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// 生成随机的挑战
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, challengeLength)
+
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// 生成注册的参数
+func (rp RelyingParty) NewCreationOptions(user UserEntity, exclude []CredentialDescriptor, timeout time.Duration) (*CreationOptions, error) {
+	challenge, err := NewChallenge()
+
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: CredentialType, Alg: alg})
+	}
+
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return &CreationOptions{
+		Rp:                 RpEntity{Id: rp.Id, Name: rp.Name},
+		User:               user,
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			// 尽量创建可发现的凭证 (passkey), 这样登陆时不需要输入用户名
+			ResidentKey:      ResidentKeyPreferred,
+			UserVerification: UserVerificationPreferred,
+		},
+		Attestation: AttestationNone,
+	}, nil
+}
+
+// 生成认证的参数, allow 为空时允许使用任意可发现的凭证
+func (rp RelyingParty) NewRequestOptions(allow []CredentialDescriptor, timeout time.Duration) (*RequestOptions, error) {
+	challenge, err := NewChallenge()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout.Milliseconds(),
+		RpId:             rp.Id,
+		AllowCredentials: allow,
+		UserVerification: UserVerificationPreferred,
+	}, nil
+}
+
+// 解析客户端数据
+func ParseClientData(raw []byte) (*ClientData, error) {
+	c := ClientData{}
+
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidClientData
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+package webauthn_test
+
+import (
+	"encoding/json"
+	"github.com/axetroy/go-server/pkg/webauthn"
+	"github.com/axetroy/go-server/pkg/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var rp = webauthn.RelyingParty{
+	Id:      "example.com",
+	Name:    "example",
+	Origins: []string{"https://example.com"},
+}
+
+func register(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	options, err := rp.NewCreationOptions(webauthn.UserEntity{Id: []byte("123"), Name: "test", DisplayName: "test"}, nil, time.Minute)
+
+	assert.Nil(t, err)
+
+	res, err := authenticator.Create("https://example.com", *options)
+
+	assert.Nil(t, err)
+
+	credential, err := rp.VerifyRegistration(*res, options.Challenge, false)
+
+	assert.Nil(t, err)
+
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	authenticator := webauthntest.NewAuthenticator()
+
+	options, err := rp.NewCreationOptions(webauthn.UserEntity{Id: []byte("123"), Name: "test", DisplayName: "test"}, nil, time.Minute)
+
+	assert.Nil(t, err)
+	assert.Len(t, options.Challenge, 32)
+	assert.Equal(t, int64(60000), options.Timeout)
+
+	res, err := authenticator.Create("https://example.com", *options)
+
+	assert.Nil(t, err)
+
+	// 经过 JSON 序列化之后仍然可以校验
+	b, err := json.Marshal(res)
+
+	assert.Nil(t, err)
+
+	decoded := webauthn.AttestationResponse{}
+
+	assert.Nil(t, json.Unmarshal(b, &decoded))
+
+	credential, err := rp.VerifyRegistration(decoded, options.Challenge, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(res.RawId), credential.Id)
+	assert.Equal(t, webauthn.AlgES256, credential.Algorithm)
+	assert.Equal(t, uint32(0), credential.SignCount)
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+	assert.True(t, credential.UserVerified)
+
+	key, err := webauthn.ParsePublicKey(credential.PublicKey)
+
+	assert.Nil(t, err)
+	assert.Equal(t, webauthn.AlgES256, key.Algorithm)
+
+	// 挑战不正确
+	_, err = rp.VerifyRegistration(*res, []byte("invalid challenge"), false)
+	assert.Equal(t, webauthn.ErrChallengeMismatch, err)
+
+	// 来源不正确
+	_, err = webauthn.RelyingParty{Id: "example.com", Origins: []string{"https://evil.com"}}.VerifyRegistration(*res, options.Challenge, false)
+	assert.Equal(t, webauthn.ErrOriginMismatch, err)
+
+	// 依赖方不正确
+	_, err = webauthn.RelyingParty{Id: "evil.com", Origins: []string{"https://example.com"}}.VerifyRegistration(*res, options.Challenge, false)
+	assert.Equal(t, webauthn.ErrRpIdMismatch, err)
+
+	// 凭证 ID 被篡改
+	tampered := *res
+	tampered.RawId = []byte("invalid")
+	_, err = rp.VerifyRegistration(tampered, options.Challenge, false)
+	assert.Equal(t, webauthn.ErrInvalidAuthData, err)
+
+	// 认证的数据不能用于注册
+	assertion, err := authenticator.Get("https://example.com", webauthn.RequestOptions{Challenge: options.Challenge, RpId: rp.Id})
+	assert.Nil(t, err)
+	tampered = *res
+	tampered.Response.ClientDataJSON = assertion.Response.ClientDataJSON
+	_, err = rp.VerifyRegistration(tampered, options.Challenge, false)
+	assert.Equal(t, webauthn.ErrInvalidClientData, err)
+
+	// 要求用户验证
+	authenticator.UserVerified = false
+	res, err = authenticator.Create("https://example.com", *options)
+	assert.Nil(t, err)
+	_, err = rp.VerifyRegistration(*res, options.Challenge, true)
+	assert.Equal(t, webauthn.ErrUserNotVerified, err)
+}
+
+func TestAssertion(t *testing.T) {
+	authenticator := webauthntest.NewAuthenticator()
+
+	credential := register(t, authenticator)
+
+	options, err := rp.NewRequestOptions([]webauthn.CredentialDescriptor{{Type: webauthn.CredentialType, Id: credential.Id}}, time.Minute)
+
+	assert.Nil(t, err)
+
+	res, err := authenticator.Get("https://example.com", *options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("123"), []byte(res.Response.UserHandle))
+
+	count, err := rp.VerifyAssertion(*res, options.Challenge, credential.PublicKey, credential.SignCount, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	// 重放旧的签名, 计数器没有递增
+	_, err = rp.VerifyAssertion(*res, options.Challenge, credential.PublicKey, count, false)
+	assert.Equal(t, webauthn.ErrSignCount, err)
+
+	// 签名被篡改
+	tampered := *res
+	tampered.Response.Signature = append([]byte{}, res.Response.Signature...)
+	tampered.Response.Signature[len(tampered.Response.Signature)-1] ^= 0xff
+	_, err = rp.VerifyAssertion(tampered, options.Challenge, credential.PublicKey, credential.SignCount, false)
+	assert.Equal(t, webauthn.ErrInvalidSignature, err)
+
+	// 其他凭证的公钥
+	other := register(t, authenticator)
+	_, err = rp.VerifyAssertion(*res, options.Challenge, other.PublicKey, credential.SignCount, false)
+	assert.Equal(t, webauthn.ErrInvalidSignature, err)
+
+	// 挑战不正确
+	_, err = rp.VerifyAssertion(*res, []byte("invalid challenge"), credential.PublicKey, credential.SignCount, false)
+	assert.Equal(t, webauthn.ErrChallengeMismatch, err)
+
+	// 可发现的凭证, 不指定允许的凭证
+	options, err = rp.NewRequestOptions(nil, time.Minute)
+
+	assert.Nil(t, err)
+
+	res, err = authenticator.Get("https://example.com", *options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, other.Id, []byte(res.RawId))
+
+	count, err = rp.VerifyAssertion(*res, options.Challenge, other.PublicKey, other.SignCount, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	// 没有该依赖方的凭证
+	_, err = authenticator.Get("https://example.com", webauthn.RequestOptions{Challenge: options.Challenge, RpId: "other.com"})
+	assert.Equal(t, webauthntest.ErrNoCredential, err)
+}
+
+func TestBytes(t *testing.T) {
+	b := webauthn.Bytes{0xfb, 0xff, 0x01}
+
+	data, err := json.Marshal(b)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `"-_8B"`, string(data))
+
+	for _, s := range []string{`"-_8B"`, `"+/8B"`, `"-_8B=="`} {
+		decoded := webauthn.Bytes{}
+
+		assert.Nil(t, json.Unmarshal([]byte(s), &decoded))
+		assert.Equal(t, b, decoded)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2019-2020 Axetroy. All rights reserved. MIT license.
+// 软件实现的 WebAuthn 认证器, 仅用于测试
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/axetroy/go-server/pkg/cbor"
+	"github.com/axetroy/go-server/pkg/webauthn"
+	"math/big"
+	"sync"
+)
+
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var ErrNoCredential = errors.New("webauthn: no credential available")
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+type softCredential struct {
+	id         []byte
+	rpId       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// 软件实现的认证器, 使用 ES256 算法, 创建的凭证都是可发现的
+// 私钥保存在内存中
+type Authenticator struct {
+	AAGUID       []byte
+	UserVerified bool // 是否声明已经过用户验证
+	mu           sync.Mutex
+	credentials  []*softCredential
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		AAGUID:       make([]byte, 16),
+		UserVerified: true,
+	}
+}
+
+func (a *Authenticator) flags() byte {
+	flags := byte(flagUserPresent)
+
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+
+	return flags
+}
+
+func clientDataJSON(typ string, challenge []byte, origin string) ([]byte, error) {
+	return json.Marshal(webauthn.ClientData{
+		Type:      typ,
+		Challenge: challenge,
+		Origin:    origin,
+	})
+}
+
+func authenticatorData(rpId string, flags byte, signCount uint32) []byte {
+	hash := sha256.Sum256([]byte(rpId))
+
+	data := append([]byte{}, hash[:]...)
+	data = append(data, flags)
+
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, signCount)
+
+	return append(data, counter...)
+}
+
+// 创建凭证, 相当于 navigator.credentials.create()
+func (a *Authenticator) Create(origin string, options webauthn.CreationOptions) (*webauthn.AttestationResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	supported := false
+
+	for _, p := range options.PubKeyCredParams {
+		if p.Type == webauthn.CredentialType && p.Alg == webauthn.AlgES256 {
+			supported = true
+		}
+	}
+
+	if !supported {
+		return nil, webauthn.ErrUnsupportedKey
+	}
+
+	for _, exclude := range options.ExcludeCredentials {
+		for _, c := range a.credentials {
+			if c.rpId == options.Rp.Id && bytes.Equal(c.id, exclude.Id) {
+				return nil, errors.New("webauthn: credential already registered")
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthn.MarshalES256PublicKey(&key.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	authData := authenticatorData(options.Rp.Id, a.flags()|flagAttested, 0)
+	authData = append(authData, a.AAGUID...)
+	authData = append(authData, byte(len(id)>>8), byte(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, publicKey...)
+
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientData, err := clientDataJSON(typeCreate, options.Challenge, origin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, &softCredential{
+		id:         id,
+		rpId:       options.Rp.Id,
+		userHandle: options.User.Id,
+		key:        key,
+	})
+
+	return &webauthn.AttestationResponse{
+		Id:    webauthn.Bytes(id).String(),
+		RawId: id,
+		Type:  webauthn.CredentialType,
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientData,
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// 使用凭证签名, 相当于 navigator.credentials.get()
+// 没有指定允许的凭证时, 使用该依赖方最后创建的凭证
+func (a *Authenticator) Get(origin string, options webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var credential *softCredential
+
+	for _, c := range a.credentials {
+		if c.rpId != options.RpId {
+			continue
+		}
+
+		if len(options.AllowCredentials) == 0 {
+			credential = c
+			continue
+		}
+
+		for _, allow := range options.AllowCredentials {
+			if bytes.Equal(c.id, allow.Id) {
+				credential = c
+			}
+		}
+	}
+
+	if credential == nil {
+		return nil, ErrNoCredential
+	}
+
+	credential.signCount++
+
+	authData := authenticatorData(options.RpId, a.flags(), credential.signCount)
+
+	clientData, err := clientDataJSON(typeGet, options.Challenge, origin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+
+	// 被签名的数据: authenticatorData || sha256(clientDataJSON)
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	r, s, err := ecdsa.Sign(rand.Reader, credential.key, hash[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := asn1.Marshal(ecdsaSignature{R: r, S: s})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionResponse{
+		Id:    webauthn.Bytes(credential.id).String(),
+		RawId: credential.id,
+		Type:  webauthn.CredentialType,
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientData,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        credential.userHandle,
+		},
+	}, nil
+}
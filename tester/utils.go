@@ -127,7 +127,7 @@ func CreateWaiter() (profile schema.ProfileWithToken, err error) {
 
 // 登陆超级管理员
 func LoginAdmin() (profile schema.AdminProfileWithToken, err error) {
-	r := admin.Login(admin.SignInParams{
+	r := admin.Login(helper.Context{}, admin.SignInParams{
 		Username: "admin",
 		Password: "123456",
 	})